@@ -21,6 +21,9 @@ import (
 	"context"
 	"fmt"
 	"reflect"
+	"sort"
+	"strings"
+	"time"
 
 	"github.com/pkg/errors"
 	"github.com/prometheus/client_golang/prometheus"
@@ -29,7 +32,10 @@ import (
 	"github.com/submariner-io/admiral/pkg/syncer"
 	"github.com/submariner-io/admiral/pkg/syncer/broker"
 	"github.com/submariner-io/admiral/pkg/util"
+	"github.com/submariner-io/lighthouse/pkg/clockskew"
 	lhconstants "github.com/submariner-io/lighthouse/pkg/constants"
+	"github.com/submariner-io/lighthouse/pkg/endpointslice"
+	"github.com/submariner-io/lighthouse/pkg/serviceimport"
 	corev1 "k8s.io/api/core/v1"
 	discovery "k8s.io/api/discovery/v1beta1"
 	apierrors "k8s.io/apimachinery/pkg/api/errors"
@@ -38,15 +44,22 @@ import (
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/runtime/schema"
 	utilruntime "k8s.io/apimachinery/pkg/util/runtime"
+	"k8s.io/apimachinery/pkg/util/validation"
+	"k8s.io/apimachinery/pkg/util/wait"
 	"k8s.io/client-go/kubernetes"
 	"k8s.io/client-go/util/retry"
 	"k8s.io/klog"
+	utilnet "k8s.io/utils/net"
 	mcsv1a1 "sigs.k8s.io/mcs-api/pkg/apis/v1alpha1"
 )
 
 const (
 	serviceUnavailable = "ServiceUnavailable"
 	invalidServiceType = "UnsupportedServiceType"
+	invalidNamespace   = "InvalidImportNamespace"
+	unsupportedIPv4    = "UnsupportedIPv4ClusterIP"
+	invalidExportMode  = "InvalidExportMode"
+	awaitingSync       = "AwaitingSync"
 	clusterIP          = "cluster-ip"
 )
 
@@ -60,10 +73,54 @@ var MaxExportStatusConditions = 10
 func New(spec *AgentSpecification, syncerConf broker.SyncerConfig, kubeClientSet kubernetes.Interface,
 	syncerMetricNames AgentConfig) (*Controller, error) {
 	agentController := &Controller{
-		clusterID:        spec.ClusterID,
-		namespace:        spec.Namespace,
-		globalnetEnabled: spec.GlobalnetEnabled,
-		kubeClientSet:    kubeClientSet,
+		clusterID:               spec.ClusterID,
+		namespace:               spec.Namespace,
+		globalnetEnabled:        spec.GlobalnetEnabled,
+		ipv6Only:                spec.IPv6Only,
+		kubeClientSet:           kubeClientSet,
+		clustersetDomain:        spec.ClustersetDomain,
+		readOnly:                spec.ReadOnly,
+		dryRun:                  spec.DryRun,
+		hintsInterval:           spec.HintsInterval,
+		externalDNSInterval:     spec.ExternalDNSInterval,
+		serviceEntryInterval:    spec.ServiceEntryInterval,
+		gatewayBackendsInterval: spec.GatewayBackendsInterval,
+		brokerStatusInterval:    spec.BrokerStatusInterval,
+		lastBrokerSyncs:         map[string]time.Time{},
+		clockSkew:               clockskew.New(),
+	}
+
+	if len(spec.MetricsNamespaces) > 0 {
+		agentController.exported.allowed = map[string]bool{}
+		for _, ns := range spec.MetricsNamespaces {
+			agentController.exported.allowed[ns] = true
+		}
+	}
+
+	if agentController.clustersetDomain == "" {
+		agentController.clustersetDomain = lhconstants.DefaultClustersetDomain
+	}
+
+	if errs := validation.IsDNS1123Subdomain(agentController.clustersetDomain); len(errs) > 0 {
+		return nil, errors.Errorf("invalid clusterset domain %q: %v", agentController.clustersetDomain, errs)
+	}
+
+	if spec.IPv6Only && spec.GlobalnetEnabled {
+		return nil, errors.New("IPv6-only mode isn't supported with Globalnet")
+	}
+
+	if agentController.dryRun {
+		klog.Info("Running in dry-run mode - changes will be logged instead of written to the local cluster and the broker")
+
+		syncerConf.LocalClient = newInterceptedClient(syncerConf.LocalClient, logDryRunWrite)
+	}
+
+	if spec.Standby {
+		klog.Info("Starting on standby - writes will be held back until the agent is promoted")
+
+		agentController.standby = newWriteGate()
+		agentController.standbyClient = syncerConf.LocalClient
+		syncerConf.LocalClient = newInterceptedClient(syncerConf.LocalClient, agentController.standby.intercept)
 	}
 
 	_, gvr, err := util.ToUnstructuredResource(&mcsv1a1.ServiceExport{}, syncerConf.RestMapper)
@@ -71,50 +128,92 @@ func New(spec *AgentSpecification, syncerConf broker.SyncerConfig, kubeClientSet
 		return nil, err
 	}
 
+	agentController.eventBroadcaster, agentController.eventRecorder = newEventBroadcaster(syncerConf.Scheme)
 	agentController.serviceExportClient = syncerConf.LocalClient.Resource(*gvr)
+	agentController.localClient = syncerConf.LocalClient
+	agentController.restMapper = syncerConf.RestMapper
 
 	syncerConf.LocalNamespace = spec.Namespace
 	syncerConf.LocalClusterID = spec.ClusterID
 
-	syncerConf.ResourceConfigs = []broker.ResourceConfig{
-		{
-			LocalSourceNamespace: metav1.NamespaceAll,
-			LocalResourceType:    &mcsv1a1.ServiceImport{},
-			BrokerResourceType:   &mcsv1a1.ServiceImport{},
-			SyncCounterOpts: &prometheus.GaugeOpts{
-				Name: syncerMetricNames.ServiceImportCounterName,
-				Help: "Count of imported services",
-			},
-		},
+	localServiceImportTransform := syncer.TransformFunc(serviceImportToBroker)
+
+	localEndpointSliceTransform := agentController.filterLocalEndpointSlices
+
+	if agentController.readOnly {
+		klog.Info("Running in read-only mode - local services will not be exported")
+
+		localServiceImportTransform = dropLocalResources
+		localEndpointSliceTransform = dropLocalResources
+	}
+
+	if agentController.dryRun {
+		localServiceImportTransform = dryRunBrokerTransform(localServiceImportTransform)
+		localEndpointSliceTransform = dryRunBrokerTransform(localEndpointSliceTransform)
 	}
 
-	agentController.serviceImportSyncer, err = broker.NewSyncer(syncerConf)
+	if agentController.standby != nil {
+		localServiceImportTransform = standbyBrokerTransform(agentController.standby, localServiceImportTransform)
+		localEndpointSliceTransform = standbyBrokerTransform(agentController.standby, localEndpointSliceTransform)
+	}
+
+	agentController.localServiceImportTransform = localServiceImportTransform
+	agentController.localEndpointSliceTransform = localEndpointSliceTransform
+
+	brokerSelector, err := importSelector(spec)
 	if err != nil {
 		return nil, err
 	}
 
-	syncerConf.LocalNamespace = metav1.NamespaceAll
-	syncerConf.ResourceConfigs = []broker.ResourceConfig{
-		{
-			LocalSourceNamespace: metav1.NamespaceAll,
-			LocalResourceType:    &discovery.EndpointSlice{},
-			LocalTransform:       agentController.filterLocalEndpointSlices,
-			LocalResourcesEquivalent: func(obj1, obj2 *unstructured.Unstructured) bool {
-				return false
-			},
-			BrokerResourceType: &discovery.EndpointSlice{},
-			BrokerResourcesEquivalent: func(obj1, obj2 *unstructured.Unstructured) bool {
-				return false
-			},
-			BrokerTransform: agentController.remoteEndpointSliceToLocal,
-		},
+	err = resilientBrokerClient(&syncerConf, "primary", brokerSelector)
+	if err != nil {
+		return nil, err
 	}
 
-	agentController.endpointSliceSyncer, err = broker.NewSyncer(syncerConf)
+	agentController.serviceImportSyncer, agentController.endpointSliceSyncer, err = agentController.newBrokerSyncers(syncerConf,
+		localServiceImportTransform, localEndpointSliceTransform, &prometheus.GaugeOpts{
+			Name: syncerMetricNames.ServiceImportCounterName,
+			Help: "Count of imported services",
+		})
 	if err != nil {
 		return nil, err
 	}
 
+	for i := range spec.AdditionalBrokers {
+		brokerSpec := &spec.AdditionalBrokers[i]
+
+		brokerConf := syncerConf
+		brokerConf.BrokerNamespace = brokerSpec.RemoteNamespace
+		brokerConf.BrokerClient = brokerSpec.Client
+		brokerConf.BrokerRestConfig = nil
+
+		if brokerConf.BrokerClient == nil {
+			brokerConf.BrokerRestConfig, err = brokerSpec.RestConfig()
+			if err != nil {
+				return nil, errors.Wrapf(err, "invalid configuration for broker %q", brokerSpec.Name)
+			}
+		}
+
+		err = resilientBrokerClient(&brokerConf, brokerSpec.Name, brokerSelector)
+		if err != nil {
+			return nil, err
+		}
+
+		additional := &brokerSyncers{name: brokerSpec.Name}
+
+		additional.serviceImportSyncer, additional.endpointSliceSyncer, err = agentController.newBrokerSyncers(brokerConf,
+			localServiceImportTransform, localEndpointSliceTransform, nil)
+		if err != nil {
+			return nil, errors.Wrapf(err, "error creating the syncers for broker %q", brokerSpec.Name)
+		}
+
+		agentController.additionalBrokers = append(agentController.additionalBrokers, additional)
+	}
+
+	if agentController.readOnly {
+		return agentController, nil
+	}
+
 	agentController.serviceExportSyncer, err = syncer.NewResourceSyncer(&syncer.ResourceSyncerConfig{
 		Name:             "ServiceExport -> ServiceImport",
 		SourceClient:     syncerConf.LocalClient,
@@ -135,21 +234,23 @@ func New(spec *AgentSpecification, syncerConf broker.SyncerConfig, kubeClientSet
 	}
 
 	agentController.serviceSyncer, err = syncer.NewResourceSyncer(&syncer.ResourceSyncerConfig{
-		Name:            "Service deletion",
-		SourceClient:    syncerConf.LocalClient,
-		SourceNamespace: metav1.NamespaceAll,
-		RestMapper:      syncerConf.RestMapper,
-		Federator:       agentController.serviceImportSyncer.GetLocalFederator(),
-		ResourceType:    &corev1.Service{},
-		Transform:       agentController.serviceToRemoteServiceImport,
-		Scheme:          syncerConf.Scheme,
+		Name:             "Service deletion",
+		SourceClient:     syncerConf.LocalClient,
+		SourceNamespace:  metav1.NamespaceAll,
+		RestMapper:       syncerConf.RestMapper,
+		Federator:        agentController.serviceImportSyncer.GetLocalFederator(),
+		ResourceType:     &corev1.Service{},
+		Transform:        agentController.serviceToRemoteServiceImport,
+		OnSuccessfulSync: agentController.onSuccessfulServiceImportSync,
+		Scheme:           syncerConf.Scheme,
 	})
 	if err != nil {
 		return nil, err
 	}
 
 	agentController.serviceImportController, err = newServiceImportController(spec, agentController.serviceSyncer,
-		syncerConf.RestMapper, syncerConf.LocalClient, syncerConf.Scheme)
+		syncerConf.RestMapper, syncerConf.LocalClient, syncerConf.Scheme, agentController.updateExportedServiceStatus,
+		agentController.clearExportedServiceStatus)
 	if err != nil {
 		return nil, err
 	}
@@ -162,18 +263,28 @@ func New(spec *AgentSpecification, syncerConf broker.SyncerConfig, kubeClientSet
 	return agentController, nil
 }
 
+// ClockSkew returns the estimator of the offset of the broker's clock from the local clock, with which timestamps
+// propagated from other clusters should be interpreted.
+func (a *Controller) ClockSkew() *clockskew.Estimator {
+	return a.clockSkew
+}
+
 func (a *Controller) Start(stopCh <-chan struct{}) error {
 	defer utilruntime.HandleCrash()
 
 	// Start the informer factories to begin populating the informer caches
 	klog.Info("Starting Agent controller")
 
-	if err := a.serviceExportSyncer.Start(stopCh); err != nil {
-		return err
-	}
+	if !a.readOnly {
+		a.startEvents(a.kubeClientSet, stopCh)
 
-	if err := a.serviceSyncer.Start(stopCh); err != nil {
-		return err
+		if err := a.serviceExportSyncer.Start(stopCh); err != nil {
+			return err
+		}
+
+		if err := a.serviceSyncer.Start(stopCh); err != nil {
+			return err
+		}
 	}
 
 	if err := a.endpointSliceSyncer.Start(stopCh); err != nil {
@@ -184,10 +295,49 @@ func (a *Controller) Start(stopCh <-chan struct{}) error {
 		return err
 	}
 
+	for _, additional := range a.additionalBrokers {
+		go func(additional *brokerSyncers) {
+			if additional.start(stopCh) && !a.readOnly {
+				a.pruneStaleBrokerResources(additional.name, additional.serviceImportSyncer, stopCh)
+			}
+		}(additional)
+	}
+
+	if a.hintsInterval > 0 {
+		go wait.Until(a.publishHints, a.hintsInterval, stopCh)
+	}
+
+	if a.externalDNSInterval > 0 {
+		go wait.Until(a.publishExternalDNS, a.externalDNSInterval, stopCh)
+	}
+
+	if a.serviceEntryInterval > 0 {
+		go wait.Until(a.publishServiceEntries, a.serviceEntryInterval, stopCh)
+	}
+
+	if a.gatewayBackendsInterval > 0 {
+		go wait.Until(a.publishGatewayBackends, a.gatewayBackendsInterval, stopCh)
+	}
+
+	if a.brokerStatusInterval > 0 {
+		go wait.Until(a.publishBrokerStatus, a.brokerStatusInterval, stopCh)
+	}
+
+	a.startClusterMembershipWatch(stopCh)
+
+	if a.readOnly {
+		klog.Info("Agent controller started in read-only mode")
+		return nil
+	}
+
 	if err := a.serviceImportController.start(stopCh); err != nil {
 		return err
 	}
 
+	go wait.Until(a.deleteOrphanedEndpointSlices, orphanedEndpointSlicesInterval, stopCh)
+
+	go a.pruneStaleBrokerResources("primary", a.serviceImportSyncer, stopCh)
+
 	a.serviceExportSyncer.Reconcile(func() []runtime.Object {
 		return a.serviceImportLister(func(si *mcsv1a1.ServiceImport) runtime.Object {
 			return &mcsv1a1.ServiceExport{
@@ -215,6 +365,52 @@ func (a *Controller) Start(stopCh <-chan struct{}) error {
 	return nil
 }
 
+// newBrokerSyncers creates the syncers of the ServiceImports and EndpointSlices between the local cluster and the
+// broker configured in syncerConf; serviceImportCounterOpts, if set, configures the gauge of imported services.
+func (a *Controller) newBrokerSyncers(syncerConf broker.SyncerConfig, localServiceImportTransform,
+	localEndpointSliceTransform syncer.TransformFunc, serviceImportCounterOpts *prometheus.GaugeOpts) (
+	serviceImportSyncer, endpointSliceSyncer *broker.Syncer, err error) {
+	syncerConf.ResourceConfigs = []broker.ResourceConfig{
+		{
+			LocalSourceNamespace: metav1.NamespaceAll,
+			LocalResourceType:    &mcsv1a1.ServiceImport{},
+			LocalTransform:       localServiceImportTransform,
+			BrokerResourceType:   &mcsv1a1.ServiceImport{},
+			BrokerTransform:      a.remoteServiceImportToLocal,
+			SyncCounterOpts:      serviceImportCounterOpts,
+		},
+	}
+
+	serviceImportSyncer, err = broker.NewSyncer(syncerConf)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	syncerConf.LocalNamespace = metav1.NamespaceAll
+	syncerConf.ResourceConfigs = []broker.ResourceConfig{
+		{
+			LocalSourceNamespace: metav1.NamespaceAll,
+			LocalResourceType:    &discovery.EndpointSlice{},
+			LocalTransform:       localEndpointSliceTransform,
+			LocalResourcesEquivalent: func(obj1, obj2 *unstructured.Unstructured) bool {
+				return false
+			},
+			BrokerResourceType: &discovery.EndpointSlice{},
+			BrokerResourcesEquivalent: func(obj1, obj2 *unstructured.Unstructured) bool {
+				return false
+			},
+			BrokerTransform: a.remoteEndpointSliceToLocal,
+		},
+	}
+
+	endpointSliceSyncer, err = broker.NewSyncer(syncerConf)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return serviceImportSyncer, endpointSliceSyncer, nil
+}
+
 func (a *Controller) serviceImportLister(transform func(si *mcsv1a1.ServiceImport) runtime.Object) []runtime.Object {
 	siList, err := a.serviceImportSyncer.ListLocalResources(&mcsv1a1.ServiceImport{})
 	if err != nil {
@@ -237,8 +433,10 @@ func (a *Controller) serviceExportToServiceImport(obj runtime.Object, numRequeue
 	svcExport := obj.(*mcsv1a1.ServiceExport)
 
 	klog.V(log.DEBUG).Infof("ServiceExport %s/%s %sd", svcExport.Namespace, svcExport.Name, op)
+	serviceExportsCounter.WithLabelValues(operationVerb(op)).Inc()
 
 	if op == syncer.Delete {
+		a.exportAnnotations.forget(svcExport.Name, svcExport.Namespace)
 		return a.newServiceImport(svcExport.Name, svcExport.Namespace), false
 	}
 
@@ -260,13 +458,18 @@ func (a *Controller) serviceExportToServiceImport(obj runtime.Object, numRequeue
 		return nil, true
 	}
 
-	if op == syncer.Update && getLastExportConditionReason(svcExport) != serviceUnavailable {
+	// Updates only derive the ServiceImport again if the service has since been created, the annotations it's derived
+	// from changed, or they were invalid
+	reason := getLastExportConditionReason(svcExport)
+	if op == syncer.Update && reason != serviceUnavailable && !isInvalidAnnotationReason(reason) &&
+		!a.exportAnnotations.changed(svcExport) {
 		return nil, false
 	}
 
 	svc := obj.(*corev1.Service)
+	exportMode := svcExport.Annotations[lhconstants.ExportMode]
 
-	svcType, ok := getServiceImportType(svc)
+	svcType, ok := getServiceImportType(svc, exportMode)
 
 	if !ok {
 		a.updateExportedServiceStatus(svcExport.Name, svcExport.Namespace, mcsv1a1.ServiceExportValid,
@@ -276,14 +479,70 @@ func (a *Controller) serviceExportToServiceImport(obj runtime.Object, numRequeue
 		return nil, false
 	}
 
+	if msg := validateExportedNames(svcExport); msg != "" {
+		a.updateExportedServiceStatus(svcExport.Name, svcExport.Namespace, mcsv1a1.ServiceExportValid,
+			corev1.ConditionFalse, invalidName, msg)
+		klog.Errorf("ServiceExport %s/%s has an invalid name: %s", svcExport.Namespace, svcExport.Name, msg)
+
+		return nil, false
+	}
+
+	if msg := validateExportMode(svc, svcType, exportMode); msg != "" {
+		a.updateExportedServiceStatus(svcExport.Name, svcExport.Namespace, mcsv1a1.ServiceExportValid,
+			corev1.ConditionFalse, invalidExportMode, msg)
+		klog.Errorf("ServiceExport %s/%s has an invalid export mode: %s", svcExport.Namespace, svcExport.Name, msg)
+
+		return nil, false
+	}
+
 	serviceImport := a.newServiceImport(svcExport.Name, svcExport.Namespace)
+	serviceImport.Finalizers = []string{lhconstants.ServiceImportFinalizer}
+
+	if importNamespace, ok := svcExport.Annotations[lhconstants.ImportNamespace]; ok {
+		if errs := validation.IsDNS1123Label(importNamespace); len(errs) > 0 {
+			a.updateExportedServiceStatus(svcExport.Name, svcExport.Namespace, mcsv1a1.ServiceExportValid,
+				corev1.ConditionFalse, invalidNamespace, fmt.Sprintf("Invalid import namespace %q: %s", importNamespace,
+					strings.Join(errs, ", ")))
+			klog.Errorf("ServiceExport %s/%s has an invalid import namespace %q", svcExport.Namespace, svcExport.Name,
+				importNamespace)
+
+			return nil, false
+		}
+
+		serviceImport.Annotations[lhconstants.ImportNamespace] = importNamespace
+	}
+
+	for _, annotation := range []string{lhconstants.ClusterSelection, lhconstants.ExternalDNS,
+		lhconstants.PublishNotReadyAddresses, lhconstants.MinReadyEndpoints, lhconstants.ExportMode} {
+		if value, ok := svcExport.Annotations[annotation]; ok {
+			serviceImport.Annotations[annotation] = value
+		}
+	}
+
+	if value, ok := svcExport.Annotations[lhconstants.Aliases]; ok {
+		aliases, msg := encodeAliases(value)
+		if msg != "" {
+			a.updateExportedServiceStatus(svcExport.Name, svcExport.Namespace, mcsv1a1.ServiceExportValid,
+				corev1.ConditionFalse, invalidName, msg)
+			klog.Errorf("ServiceExport %s/%s has invalid aliases: %s", svcExport.Namespace, svcExport.Name, msg)
+
+			return nil, false
+		}
+
+		serviceImport.Annotations[lhconstants.Aliases] = aliases
+	}
 
 	serviceImport.Spec = mcsv1a1.ServiceImportSpec{
 		Ports:                 []mcsv1a1.ServicePort{},
 		Type:                  svcType,
+		SessionAffinity:       svc.Spec.SessionAffinity,
 		SessionAffinityConfig: new(corev1.SessionAffinityConfig),
 	}
 
+	if svc.Spec.SessionAffinityConfig != nil {
+		serviceImport.Spec.SessionAffinityConfig = svc.Spec.SessionAffinityConfig.DeepCopy()
+	}
+
 	serviceImport.Status = mcsv1a1.ServiceImportStatus{
 		Clusters: []mcsv1a1.ClusterStatus{
 			{
@@ -293,9 +552,21 @@ func (a *Controller) serviceExportToServiceImport(obj runtime.Object, numRequeue
 	}
 
 	if svcType == mcsv1a1.ClusterSetIP {
-		if a.globalnetEnabled {
-			ip, reason, msg := a.getGlobalIP(svc)
-			if ip == "" {
+		if isExternalExportMode(exportMode) {
+			ips, reason, msg := a.getExternalIPs(svc, exportMode)
+			if len(ips) == 0 {
+				klog.V(log.DEBUG).Infof("Service to be exported (%s/%s) doesn't have external IPs yet", svcExport.Namespace,
+					svcExport.Name)
+				a.updateExportedServiceStatus(svcExport.Name, svcExport.Namespace, mcsv1a1.ServiceExportValid,
+					corev1.ConditionFalse, reason, msg)
+
+				return nil, true
+			}
+
+			serviceImport.Spec.IPs = ips
+		} else if a.globalnetEnabled {
+			ips, reason, msg := a.getGlobalIPs(svc)
+			if len(ips) == 0 {
 				klog.V(log.DEBUG).Infof("Service to be exported (%s/%s) doesn't have a global IP yet", svcExport.Namespace, svcExport.Name)
 				// Globalnet enabled but service doesn't have globalIp yet, Update the status and requeue
 				a.updateExportedServiceStatus(svcExport.Name, svcExport.Namespace, mcsv1a1.ServiceExportValid,
@@ -304,12 +575,22 @@ func (a *Controller) serviceExportToServiceImport(obj runtime.Object, numRequeue
 				return nil, true
 			}
 
-			serviceImport.Spec.IPs = []string{ip}
+			serviceImport.Spec.IPs = ips
+		} else if a.ipv6Only && !utilnet.IsIPv6String(svc.Spec.ClusterIP) {
+			a.updateExportedServiceStatus(svcExport.Name, svcExport.Namespace, mcsv1a1.ServiceExportValid,
+				corev1.ConditionFalse, unsupportedIPv4, fmt.Sprintf("The clusterset is IPv6-only and the Service's cluster IP %q "+
+					"isn't an IPv6 address", svc.Spec.ClusterIP))
+			klog.Errorf("Service to be exported (%s/%s) doesn't have an IPv6 cluster IP", svcExport.Namespace, svcExport.Name)
+
+			return nil, false
 		} else {
 			serviceImport.Spec.IPs = []string{svc.Spec.ClusterIP}
 		}
 
 		serviceImport.Spec.Ports = a.getPortsForService(svc)
+		if exportMode == lhconstants.ExportModeNodePort {
+			serviceImport.Spec.Ports = getNodePortsForService(svc)
+		}
 		/* We also store the clusterIP in an annotation as an optimization to recover it in case the IPs are
 		cleared out when here's no backing Endpoint pods.
 		*/
@@ -317,10 +598,12 @@ func (a *Controller) serviceExportToServiceImport(obj runtime.Object, numRequeue
 	}
 
 	a.updateExportedServiceStatus(svcExport.Name, svcExport.Namespace, mcsv1a1.ServiceExportValid,
-		corev1.ConditionFalse, "AwaitingSync", "Awaiting sync of the ServiceImport to the broker")
+		corev1.ConditionFalse, awaitingSync, "Awaiting sync of the ServiceImport to the broker")
 
 	klog.V(log.DEBUG).Infof("Returning ServiceImport: %#v", serviceImport)
 
+	a.exportAnnotations.record(svcExport)
+
 	return serviceImport, false
 }
 
@@ -333,8 +616,17 @@ func getLastExportConditionReason(svcExport *mcsv1a1.ServiceExport) string {
 	return ""
 }
 
-func getServiceImportType(service *corev1.Service) (mcsv1a1.ServiceImportType, bool) {
-	if service.Spec.Type != "" && service.Spec.Type != corev1.ServiceTypeClusterIP {
+// getServiceImportType returns the type of the ServiceImport the given service is exported as, if its type can be
+// exported: LoadBalancer and NodePort services only can be in the external export modes, which validateExportMode
+// matches against the service's type.
+func getServiceImportType(service *corev1.Service, exportMode string) (mcsv1a1.ServiceImportType, bool) {
+	switch service.Spec.Type {
+	case "", corev1.ServiceTypeClusterIP:
+	case corev1.ServiceTypeLoadBalancer, corev1.ServiceTypeNodePort:
+		if !isExternalExportMode(exportMode) {
+			return "", false
+		}
+	default:
 		return "", false
 	}
 
@@ -346,16 +638,20 @@ func getServiceImportType(service *corev1.Service) (mcsv1a1.ServiceImportType, b
 }
 
 func (a *Controller) onSuccessfulServiceImportSync(synced runtime.Object, op syncer.Operation) {
+	serviceImport := synced.(*mcsv1a1.ServiceImport)
+	name := serviceImport.GetAnnotations()[lhconstants.OriginName]
+	namespace := serviceImport.GetAnnotations()[lhconstants.OriginNamespace]
+
+	serviceImportsCounter.WithLabelValues(operationVerb(op)).Inc()
+	a.exported.update(name, namespace, op)
+
 	if op == syncer.Delete {
 		return
 	}
 
-	serviceImport := synced.(*mcsv1a1.ServiceImport)
-
-	a.updateExportedServiceStatus(serviceImport.GetAnnotations()[lhconstants.OriginName],
-		serviceImport.GetAnnotations()[lhconstants.OriginNamespace],
-		mcsv1a1.ServiceExportValid, corev1.ConditionTrue,
-		"", "Service was successfully synced to the broker")
+	a.updateExportedServiceStatus(name, namespace, mcsv1a1.ServiceExportValid, corev1.ConditionTrue, "",
+		fmt.Sprintf("Service was successfully synced to the broker and is resolvable as %s.%s.svc.%s", name,
+			serviceimport.ImportNamespace(serviceImport), a.clustersetDomain))
 }
 
 func (a *Controller) serviceToRemoteServiceImport(obj runtime.Object, numRequeues int, op syncer.Operation) (runtime.Object, bool) {
@@ -393,6 +689,13 @@ func (a *Controller) updateExportedServiceStatus(name, namespace string, condTyp
 	klog.V(log.DEBUG).Infof("updateExportedServiceStatus for (%s/%s) - Type: %q, Status: %q, Reason: %q, Message: %q",
 		namespace, name, condType, status, reason, msg)
 
+	failed := status != corev1.ConditionTrue && reason != "" && reason != awaitingSync
+	if failed {
+		syncErrorsCounter.WithLabelValues(reason).Inc()
+	}
+
+	var updated *mcsv1a1.ServiceExport
+
 	retryErr := retry.RetryOnConflict(retry.DefaultRetry, func() error {
 		toUpdate, err := a.getServiceExport(name, namespace)
 		if apierrors.IsNotFound(err) {
@@ -432,14 +735,61 @@ func (a *Controller) updateExportedServiceStatus(name, namespace string, condTyp
 		}
 
 		_, err = a.serviceExportClient.Namespace(toUpdate.Namespace).UpdateStatus(context.TODO(), raw, metav1.UpdateOptions{})
+		if err == nil {
+			updated = toUpdate
+		}
 
 		return err
 	})
 	if retryErr != nil {
 		klog.Errorf("Error updating status for ServiceExport (%s/%s): %v", namespace, name, retryErr)
+		a.recordExportFailure(&mcsv1a1.ServiceExport{ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: namespace}},
+			statusUpdateFailed, fmt.Sprintf("Error updating the ServiceExport status: %v", retryErr))
+
+		return
+	}
+
+	// Only changes are recorded, so that retries which fail for the same reason don't repeat the Event
+	if updated != nil && failed {
+		a.recordExportFailure(updated, reason, msg)
 	}
 }
 
+// clearExportedServiceStatus marks the ServiceExport with the given name and namespace valid again, as it was last
+// synced, if its last condition flagged it invalid for the given reason.
+func (a *Controller) clearExportedServiceStatus(name, namespace, reason string) {
+	se, err := a.getServiceExport(name, namespace)
+	if err != nil {
+		if !apierrors.IsNotFound(err) {
+			klog.Errorf("Error retrieving ServiceExport (%s/%s): %v", namespace, name, err)
+		}
+
+		return
+	}
+
+	numCond := len(se.Status.Conditions)
+	if numCond == 0 {
+		return
+	}
+
+	last := &se.Status.Conditions[numCond-1]
+	if last.Type != mcsv1a1.ServiceExportValid || last.Reason == nil || *last.Reason != reason {
+		return
+	}
+
+	msg := "Service was successfully synced to the broker"
+
+	for i := numCond - 2; i >= 0; i-- {
+		c := &se.Status.Conditions[i]
+		if c.Type == mcsv1a1.ServiceExportValid && c.Status == corev1.ConditionTrue && c.Message != nil {
+			msg = *c.Message
+			break
+		}
+	}
+
+	a.updateExportedServiceStatus(name, namespace, mcsv1a1.ServiceExportValid, corev1.ConditionTrue, "", msg)
+}
+
 func (a *Controller) getServiceExport(name, namespace string) (*mcsv1a1.ServiceExport, error) {
 	obj, err := a.serviceExportClient.Namespace(namespace).Get(context.TODO(), name, metav1.GetOptions{})
 	if err != nil {
@@ -482,9 +832,10 @@ func (a *Controller) getPortsForService(service *corev1.Service) []mcsv1a1.Servi
 
 	for _, port := range service.Spec.Ports {
 		mcsPorts = append(mcsPorts, mcsv1a1.ServicePort{
-			Name:     port.Name,
-			Protocol: port.Protocol,
-			Port:     port.Port,
+			Name:        port.Name,
+			Protocol:    port.Protocol,
+			AppProtocol: port.AppProtocol,
+			Port:        port.Port,
 		})
 	}
 
@@ -497,11 +848,62 @@ func (a *Controller) getObjectNameWithClusterID(name, namespace string) string {
 
 func (a *Controller) remoteEndpointSliceToLocal(obj runtime.Object, numRequeues int, op syncer.Operation) (runtime.Object, bool) {
 	endpointSlice := obj.(*discovery.EndpointSlice)
-	endpointSlice.Namespace = endpointSlice.GetObjectMeta().GetLabels()[lhconstants.LabelSourceNamespace]
+
+	if a.ipv6Only && endpointSlice.AddressType != discovery.AddressTypeIPv6 {
+		klog.Warningf("Ignoring EndpointSlice %s/%s with address type %s in IPv6-only mode", endpointSlice.Namespace,
+			endpointSlice.Name, endpointSlice.AddressType)
+
+		return nil, false
+	}
+
+	endpointSlice.Namespace = endpointslice.ImportNamespace(endpointSlice)
+
+	// The creation timestamp is set by the broker API server, so newly created slices sample the skew from its clock
+	if op == syncer.Create {
+		a.clockSkew.Observe(endpointSlice.CreationTimestamp.Time, time.Now())
+		a.observeBrokerLatency("endpointslices", endpointSlice.CreationTimestamp.Time)
+	}
+
+	endpointSlicesCounter.WithLabelValues(directionImport, operationVerb(op)).Inc()
 
 	return endpointSlice, false
 }
 
+func (a *Controller) remoteServiceImportToLocal(obj runtime.Object, numRequeues int, op syncer.Operation) (runtime.Object, bool) {
+	serviceImport := obj.(*mcsv1a1.ServiceImport)
+
+	if a.ipv6Only {
+		for _, ip := range serviceImport.Spec.IPs {
+			if !utilnet.IsIPv6String(ip) {
+				klog.Warningf("Ignoring ServiceImport %s/%s with non-IPv6 IP %q in IPv6-only mode", serviceImport.Namespace,
+					serviceImport.Name, ip)
+
+				return nil, false
+			}
+		}
+	}
+
+	if op == syncer.Create {
+		a.observeBrokerLatency("serviceimports", serviceImport.CreationTimestamp.Time)
+	}
+
+	return serviceImport, false
+}
+
+// serviceImportToBroker strips the finalizer from the ServiceImports synced to the broker: it only guards the removal
+// of the exporting cluster's EndpointSlices, and nothing would remove it from the broker's copies.
+func serviceImportToBroker(obj runtime.Object, numRequeues int, op syncer.Operation) (runtime.Object, bool) {
+	serviceImport := obj.(*mcsv1a1.ServiceImport)
+	serviceImport.Finalizers = nil
+
+	return serviceImport, false
+}
+
+// dropLocalResources prevents local resources from being synced to the broker in read-only mode.
+func dropLocalResources(obj runtime.Object, numRequeues int, op syncer.Operation) (runtime.Object, bool) {
+	return nil, false
+}
+
 func (a *Controller) filterLocalEndpointSlices(obj runtime.Object, numRequeues int, op syncer.Operation) (runtime.Object, bool) {
 	endpointSlice := obj.(*discovery.EndpointSlice)
 	labels := endpointSlice.GetObjectMeta().GetLabels()
@@ -530,6 +932,38 @@ func (a *Controller) getGlobalIP(service *corev1.Service) (ip, reason, msg strin
 	return "", "GlobalnetDisabled", "Globalnet is not enabled"
 }
 
+// getGlobalIPs returns the global IPs allocated to the given service: that of the GlobalIngressIP named after it,
+// followed by those of any other GlobalIngressIPs targeting it, e.g. when it's allocated a global IP per IP family.
+func (a *Controller) getGlobalIPs(service *corev1.Service) (ips []string, reason, msg string) {
+	ip, reason, msg := a.getGlobalIP(service)
+	if ip == "" {
+		return nil, reason, msg
+	}
+
+	list, err := a.ingressIPClient.Namespace(service.Namespace).List(context.TODO(), metav1.ListOptions{})
+	if err != nil {
+		return nil, "GlobalIngressIPRetrievalFailed", err.Error()
+	}
+
+	others := []string{}
+
+	for i := range list.Items {
+		if list.Items[i].GetName() == service.Name {
+			continue
+		}
+
+		ingressIP := parseIngressIP(&list.Items[i])
+		if ingressIP != nil && ingressIP.target == ClusterIPService && ingressIP.svcName == service.Name &&
+			ingressIP.allocatedIP != "" && ingressIP.allocatedIP != ip {
+			others = append(others, ingressIP.allocatedIP)
+		}
+	}
+
+	sort.Strings(others)
+
+	return append([]string{ip}, others...), reason, msg
+}
+
 func (a *Controller) getIngressIP(name, namespace string) (*IngressIP, bool, error) {
 	obj, err := a.ingressIPClient.Namespace(namespace).Get(context.TODO(), name, metav1.GetOptions{})
 
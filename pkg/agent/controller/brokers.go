@@ -0,0 +1,137 @@
+/*
+SPDX-License-Identifier: Apache-2.0
+
+Copyright Contributors to the Submariner project.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package controller
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/pkg/errors"
+	"github.com/submariner-io/admiral/pkg/syncer/broker"
+	"k8s.io/apimachinery/pkg/util/validation"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/rest"
+	"k8s.io/klog"
+)
+
+// BrokerSpec configures a broker the agent exports services to and imports them from in addition to the primary one,
+// for clusters which belong to several clustersets. Its fields mirror the BROKER_K8S environment variables.
+type BrokerSpec struct {
+	// Name identifies the broker in logs
+	Name      string `json:"name"`
+	APIServer string `json:"apiServer"`
+	// APIServerToken is the token of the cluster's service account on the broker
+	APIServerToken  string `json:"apiServerToken"`
+	RemoteNamespace string `json:"remoteNamespace"`
+	// CA is the base64-encoded PEM bundle of the broker's certificate authorities
+	CA       string `json:"ca,omitempty"`
+	Insecure bool   `json:"insecure,omitempty"`
+	// Client, if set, is used to access the broker instead of a client built from the fields above
+	Client dynamic.Interface `json:"-" ignored:"true"`
+}
+
+// ParseBrokerSpecs parses and validates a JSON list of broker specifications.
+func ParseBrokerSpecs(data []byte) ([]BrokerSpec, error) {
+	var specs []BrokerSpec
+
+	if err := json.Unmarshal(data, &specs); err != nil {
+		return nil, errors.Wrap(err, "error parsing the broker specifications")
+	}
+
+	names := map[string]bool{}
+
+	for i := range specs {
+		spec := &specs[i]
+
+		if errs := validation.IsDNS1123Label(spec.Name); len(errs) > 0 {
+			return nil, fmt.Errorf("invalid broker name %q: %s", spec.Name, strings.Join(errs, ", "))
+		}
+
+		if names[spec.Name] {
+			return nil, fmt.Errorf("broker %q is specified more than once", spec.Name)
+		}
+
+		names[spec.Name] = true
+
+		if spec.APIServer == "" || spec.RemoteNamespace == "" {
+			return nil, fmt.Errorf("broker %q requires an apiServer and a remoteNamespace", spec.Name)
+		}
+	}
+
+	return specs, nil
+}
+
+// String describes the broker without its credentials, so that specifications can be logged.
+func (b BrokerSpec) String() string {
+	return fmt.Sprintf("%s (%s, namespace %s)", b.Name, b.APIServer, b.RemoteNamespace)
+}
+
+// RestConfig returns the configuration with which to access the broker.
+func (b *BrokerSpec) RestConfig() (*rest.Config, error) {
+	config := &rest.Config{
+		Host:        b.APIServer,
+		BearerToken: b.APIServerToken,
+		TLSClientConfig: rest.TLSClientConfig{
+			Insecure: b.Insecure,
+		},
+	}
+
+	if !strings.Contains(config.Host, "://") {
+		config.Host = "https://" + config.Host
+	}
+
+	if b.CA != "" && !b.Insecure {
+		ca, err := base64.StdEncoding.DecodeString(b.CA)
+		if err != nil {
+			return nil, errors.Wrap(err, "error decoding the CA")
+		}
+
+		config.TLSClientConfig.CAData = ca
+	}
+
+	return config, nil
+}
+
+// brokerSyncers syncs the ServiceImports and EndpointSlices with an additional broker.
+type brokerSyncers struct {
+	name                string
+	serviceImportSyncer *broker.Syncer
+	endpointSliceSyncer *broker.Syncer
+}
+
+// start starts syncing with the broker. It's run in its own goroutine since starting waits for the broker's resources
+// to be listed, so that an unreachable broker doesn't hold back the others. It returns whether the syncers started.
+func (b *brokerSyncers) start(stopCh <-chan struct{}) bool {
+	klog.Infof("Starting to sync with broker %q", b.name)
+
+	if err := b.endpointSliceSyncer.Start(stopCh); err != nil {
+		klog.Errorf("Error starting the EndpointSlice syncer for broker %q: %v", b.name, err)
+		return false
+	}
+
+	if err := b.serviceImportSyncer.Start(stopCh); err != nil {
+		klog.Errorf("Error starting the ServiceImport syncer for broker %q: %v", b.name, err)
+		return false
+	}
+
+	klog.Infof("Syncing with broker %q", b.name)
+
+	return true
+}
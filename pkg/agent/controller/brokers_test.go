@@ -0,0 +1,134 @@
+/*
+SPDX-License-Identifier: Apache-2.0
+
+Copyright Contributors to the Submariner project.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package controller_test
+
+import (
+	"encoding/base64"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/submariner-io/admiral/pkg/fake"
+	"github.com/submariner-io/admiral/pkg/syncer/test"
+	"github.com/submariner-io/lighthouse/pkg/agent/controller"
+	corev1 "k8s.io/api/core/v1"
+	discovery "k8s.io/api/discovery/v1beta1"
+	mcsv1a1 "sigs.k8s.io/mcs-api/pkg/apis/v1alpha1"
+)
+
+var _ = Describe("Additional brokers", func() {
+	Describe("syncing", func() {
+		var (
+			t                     *testDriver
+			secondBrokerSIClient  *fake.DynamicResourceClient
+			secondBrokerEPSClient *fake.DynamicResourceClient
+		)
+
+		BeforeEach(func() {
+			t = newTestDiver()
+			t.service.Spec.ClusterIP = corev1.ClusterIPNone
+
+			secondBroker := fake.NewDynamicClient(t.syncerConfig.Scheme)
+			secondBrokerSIClient = secondBroker.Resource(*test.GetGroupVersionResourceFor(t.syncerConfig.RestMapper,
+				&mcsv1a1.ServiceImport{})).Namespace("second-broker").(*fake.DynamicResourceClient)
+			secondBrokerEPSClient = secondBroker.Resource(*test.GetGroupVersionResourceFor(t.syncerConfig.RestMapper,
+				&discovery.EndpointSlice{})).Namespace("second-broker").(*fake.DynamicResourceClient)
+
+			t.cluster1.agentSpec.AdditionalBrokers = []controller.BrokerSpec{{
+				Name:            "second",
+				RemoteNamespace: "second-broker",
+				Client:          secondBroker,
+			}}
+		})
+
+		JustBeforeEach(func() {
+			t.justBeforeEach()
+			t.createService()
+		})
+
+		AfterEach(func() {
+			t.afterEach()
+		})
+
+		When("a ServiceExport is created", func() {
+			It("should sync the ServiceImport and EndpointSlice to every broker", func() {
+				t.createEndpoints()
+				t.createServiceExport()
+
+				t.awaitHeadlessServiceImport("")
+				t.awaitEndpointSlice()
+
+				awaitServiceImport(secondBrokerSIClient, t.service, mcsv1a1.Headless, "")
+				awaitEndpointSlice(secondBrokerEPSClient, t.endpoints, t.service, "second-broker", nil)
+			})
+		})
+
+		When("a ServiceExport is deleted", func() {
+			It("should delete the ServiceImport from every broker", func() {
+				t.createEndpoints()
+				t.createServiceExport()
+				awaitServiceImport(secondBrokerSIClient, t.service, mcsv1a1.Headless, "")
+
+				t.deleteServiceExport()
+				t.awaitHeadlessServiceUnexported()
+				t.awaitNoServiceImport(secondBrokerSIClient)
+			})
+		})
+	})
+
+	Describe("specifications", func() {
+		It("should parse a valid list", func() {
+			specs, err := controller.ParseBrokerSpecs([]byte(`[
+				{"name": "east", "apiServer": "east.example.com:6443", "apiServerToken": "secret", "remoteNamespace": "broker",
+				 "ca": "` + base64.StdEncoding.EncodeToString([]byte("ca")) + `"},
+				{"name": "west", "apiServer": "https://west.example.com", "remoteNamespace": "broker", "insecure": true}
+			]`))
+			Expect(err).To(Succeed())
+			Expect(specs).To(HaveLen(2))
+			Expect(specs[0].String()).ToNot(ContainSubstring("secret"))
+
+			config, err := specs[0].RestConfig()
+			Expect(err).To(Succeed())
+			Expect(config.Host).To(Equal("https://east.example.com:6443"))
+			Expect(config.BearerToken).To(Equal("secret"))
+			Expect(config.TLSClientConfig.CAData).To(Equal([]byte("ca")))
+
+			config, err = specs[1].RestConfig()
+			Expect(err).To(Succeed())
+			Expect(config.Host).To(Equal("https://west.example.com"))
+			Expect(config.TLSClientConfig.Insecure).To(BeTrue())
+		})
+
+		It("should reject duplicate names", func() {
+			_, err := controller.ParseBrokerSpecs([]byte(`[
+				{"name": "east", "apiServer": "east", "remoteNamespace": "broker"},
+				{"name": "east", "apiServer": "east2", "remoteNamespace": "broker"}
+			]`))
+			Expect(err).To(HaveOccurred())
+		})
+
+		It("should reject invalid names", func() {
+			_, err := controller.ParseBrokerSpecs([]byte(`[{"name": "East_1", "apiServer": "east", "remoteNamespace": "broker"}]`))
+			Expect(err).To(HaveOccurred())
+		})
+
+		It("should reject a missing API server", func() {
+			_, err := controller.ParseBrokerSpecs([]byte(`[{"name": "east", "remoteNamespace": "broker"}]`))
+			Expect(err).To(HaveOccurred())
+		})
+	})
+})
@@ -0,0 +1,147 @@
+/*
+SPDX-License-Identifier: Apache-2.0
+
+Copyright Contributors to the Submariner project.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package controller
+
+import (
+	"context"
+	"time"
+
+	"github.com/submariner-io/admiral/pkg/syncer/broker"
+	"github.com/submariner-io/admiral/pkg/util"
+	"github.com/submariner-io/lighthouse/pkg/brokerstatus"
+	discovery "k8s.io/api/discovery/v1beta1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/klog"
+	mcsv1a1 "sigs.k8s.io/mcs-api/pkg/apis/v1alpha1"
+)
+
+// publishBrokerStatus refreshes the BrokerSyncStatus of each broker the agent syncs with, describing whether it can
+// reach the broker, when it was last in sync with it and how many resources it syncs, so that the DNS servers can tell
+// whether the services they answer for are up to date.
+func (a *Controller) publishBrokerStatus() {
+	if !a.isActive() {
+		return
+	}
+
+	brokers := map[string]*broker.Syncer{"primary": a.serviceImportSyncer}
+	endpointSliceSyncers := map[string]*broker.Syncer{"primary": a.endpointSliceSyncer}
+
+	for _, additional := range a.additionalBrokers {
+		brokers[additional.name] = additional.serviceImportSyncer
+		endpointSliceSyncers[additional.name] = additional.endpointSliceSyncer
+	}
+
+	for name, serviceImportSyncer := range brokers {
+		status := a.brokerStatus(name, serviceImportSyncer)
+		status.ServiceImports = countLocalResources(serviceImportSyncer, &mcsv1a1.ServiceImport{})
+		status.EndpointSlices = countLocalResources(endpointSliceSyncers[name], &discovery.EndpointSlice{})
+
+		if err := a.updateBrokerStatus(name, status); err != nil {
+			klog.Errorf("Error publishing the sync status of broker %q: %v", name, err)
+		}
+	}
+}
+
+// brokerStatus checks the connectivity with the given broker. The agent is in sync with it if it can be reached and
+// none of its watches are failing.
+func (a *Controller) brokerStatus(name string, brokerSyncer *broker.Syncer) *brokerstatus.Status {
+	status := &brokerstatus.Status{}
+
+	_, gvr, err := util.ToUnstructuredResource(&mcsv1a1.ServiceImport{}, a.restMapper)
+	if err != nil {
+		status.LastError = err.Error()
+		return status
+	}
+
+	// The broker is probed directly so that the probe neither waits for nor adds to the backoff of failing watches
+	client := brokerSyncer.GetBrokerClient()
+	healthy := true
+
+	if watchClient, ok := client.(*resilientWatchClient); ok {
+		client = watchClient.Interface
+		healthy = !watchClient.failing()
+	}
+
+	_, err = client.Resource(*gvr).Namespace(brokerSyncer.GetBrokerNamespace()).List(context.TODO(), metav1.ListOptions{Limit: 1})
+	if err != nil {
+		status.LastError = err.Error()
+	}
+
+	status.Connected = err == nil
+	status.InSync = status.Connected && healthy
+
+	if status.InSync {
+		a.lastBrokerSyncs[name] = time.Now()
+	}
+
+	status.LastSuccessfulSync = a.lastBrokerSyncs[name]
+
+	return status
+}
+
+func countLocalResources(brokerSyncer *broker.Syncer, resourceType runtime.Object) int64 {
+	list, err := brokerSyncer.ListLocalResources(resourceType)
+	if err != nil {
+		klog.Errorf("Error listing the local %T resources: %v", resourceType, err)
+		return 0
+	}
+
+	return int64(len(list))
+}
+
+// updateBrokerStatus writes the BrokerSyncStatus of the given broker, keeping the last successful sync it records if
+// the agent hasn't been in sync since it started.
+func (a *Controller) updateBrokerStatus(name string, status *brokerstatus.Status) error {
+	client := a.localClient.Resource(brokerstatus.GVR).Namespace(a.namespace)
+
+	obj, err := client.Get(context.TODO(), name, metav1.GetOptions{})
+	if apierrors.IsNotFound(err) {
+		obj = &unstructured.Unstructured{}
+		obj.SetAPIVersion(brokerstatus.GVR.GroupVersion().String())
+		obj.SetKind(brokerstatus.Kind)
+		obj.SetName(name)
+		obj.SetNamespace(a.namespace)
+
+		if err := brokerstatus.SetStatus(obj, status); err != nil {
+			return err
+		}
+
+		_, err = client.Create(context.TODO(), obj, metav1.CreateOptions{})
+
+		return err
+	}
+
+	if err != nil {
+		return err
+	}
+
+	if status.LastSuccessfulSync.IsZero() {
+		status.LastSuccessfulSync = brokerstatus.GetStatus(obj).LastSuccessfulSync
+	}
+
+	if err := brokerstatus.SetStatus(obj, status); err != nil {
+		return err
+	}
+
+	_, err = client.Update(context.TODO(), obj, metav1.UpdateOptions{})
+
+	return err
+}
@@ -0,0 +1,68 @@
+/*
+SPDX-License-Identifier: Apache-2.0
+
+Copyright Contributors to the Submariner project.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package controller_test
+
+import (
+	"context"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/submariner-io/admiral/pkg/syncer/test"
+	"github.com/submariner-io/lighthouse/pkg/brokerstatus"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+var _ = Describe("Broker sync status", func() {
+	var t *testDriver
+
+	BeforeEach(func() {
+		t = newTestDiver()
+		t.cluster2.agentSpec.BrokerStatusInterval = 50 * time.Millisecond
+	})
+
+	JustBeforeEach(func() {
+		t.justBeforeEach()
+		t.createService()
+		t.createServiceExport()
+	})
+
+	AfterEach(func() {
+		t.afterEach()
+	})
+
+	When("the agent is connected to the broker", func() {
+		It("should publish that it's in sync, along with the synced resources", func() {
+			t.awaitServiceExported(t.service.Spec.ClusterIP, 0)
+
+			Eventually(func() *brokerstatus.Status {
+				obj, err := t.cluster2.localDynClient.Resource(brokerstatus.GVR).Namespace(test.LocalNamespace).Get(context.TODO(),
+					"primary", metav1.GetOptions{})
+				if err != nil {
+					return nil
+				}
+
+				status := brokerstatus.GetStatus(obj)
+				status.LastSuccessfulSync = time.Time{}
+
+				return status
+			}, 5*time.Second, 50*time.Millisecond).Should(Equal(&brokerstatus.Status{Connected: true, InSync: true,
+				ServiceImports: 1}))
+		})
+	})
+})
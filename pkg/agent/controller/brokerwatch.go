@@ -0,0 +1,373 @@
+/*
+SPDX-License-Identifier: Apache-2.0
+
+Copyright Contributors to the Submariner project.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package controller
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/kelseyhightower/envconfig"
+	"github.com/pkg/errors"
+	"github.com/submariner-io/admiral/pkg/log"
+	"github.com/submariner-io/admiral/pkg/syncer/broker"
+	lhconstants "github.com/submariner-io/lighthouse/pkg/constants"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/selection"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/apimachinery/pkg/watch"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/klog"
+)
+
+const (
+	// relistBackoffBase and relistBackoffCap bound the delay before relisting a broker resource whose watch failed,
+	// which doubles with each consecutive failure and is jittered so that the clusters of a large clusterset don't
+	// all relist at once after a broker disruption.
+	relistBackoffBase = time.Second
+	relistBackoffCap  = 2 * time.Minute
+
+	// brokerListPageSize is the number of resources listed from the broker at once, so that the broker's apiserver
+	// doesn't build the lists of the largest clustersets in a single response.
+	brokerListPageSize = 500
+
+	watchEndClosed  = "closed"
+	watchEndExpired = "expired"
+	watchEndError   = "error"
+)
+
+// brokerWatchState tracks the watches of a broker resource.
+type brokerWatchState struct {
+	started bool
+	// failures counts the consecutive watch and list failures, reset by a successful list or the first event of a
+	// successful watch, since quiet resources may get no event for a long time
+	failures int
+	// ended is the reason the last watch ended, if it failed
+	ended string
+}
+
+// resilientWatchClient is a dynamic client for a broker which requests watch bookmarks, so that restarted watches
+// resume from a recent resource version rather than requiring a relist, and which delays the relists following watch
+// failures, e.g. once the resource version a watch resumes from is too old, with a jittered exponential backoff.
+// Its lists are paginated, and its lists and watches are narrowed to the resources matching its selector.
+type resilientWatchClient struct {
+	dynamic.Interface
+	brokerName string
+	selector   labels.Selector
+	mutex      sync.Mutex
+	watches    map[string]*brokerWatchState
+}
+
+func newResilientWatchClient(client dynamic.Interface, brokerName string, selector labels.Selector) dynamic.Interface {
+	return &resilientWatchClient{Interface: client, brokerName: brokerName, selector: selector,
+		watches: map[string]*brokerWatchState{}}
+}
+
+// unnarrowedBrokerClient returns a client for the same broker as the given one whose lists aren't narrowed to the
+// resources the agent imports, e.g. to find all the resources this cluster exported, whichever their namespace.
+func unnarrowedBrokerClient(client dynamic.Interface) dynamic.Interface {
+	if c, ok := client.(*resilientWatchClient); ok {
+		return newResilientWatchClient(c.Interface, c.brokerName, labels.Everything())
+	}
+
+	return client
+}
+
+// narrow adds the client's selector to the given options.
+func (c *resilientWatchClient) narrow(opts *metav1.ListOptions) error {
+	requirements, selectable := c.selector.Requirements()
+	if !selectable || len(requirements) == 0 {
+		return nil
+	}
+
+	selector, err := labels.Parse(opts.LabelSelector)
+	if err != nil {
+		return errors.Wrapf(err, "invalid label selector %q", opts.LabelSelector)
+	}
+
+	opts.LabelSelector = selector.Add(requirements...).String()
+
+	return nil
+}
+
+func (c *resilientWatchClient) Resource(gvr schema.GroupVersionResource) dynamic.NamespaceableResourceInterface {
+	resource := c.Interface.Resource(gvr)
+
+	return &resilientWatchResource{ResourceInterface: resource, base: resource, gvr: gvr, client: c}
+}
+
+// state returns the state of the watches of the given resource. It must be called with the lock held.
+func (c *resilientWatchClient) state(key string) *brokerWatchState {
+	state, ok := c.watches[key]
+	if !ok {
+		state = &brokerWatchState{}
+		c.watches[key] = state
+	}
+
+	return state
+}
+
+// relistDelay returns how long to wait before listing the given resource, given its consecutive failures.
+func (c *resilientWatchClient) relistDelay(key string) time.Duration {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	failures := c.state(key).failures
+	if failures == 0 {
+		return 0
+	}
+
+	delay := relistBackoffBase
+	for i := 1; i < failures && delay < relistBackoffCap; i++ {
+		delay *= 2
+	}
+
+	if delay > relistBackoffCap {
+		delay = relistBackoffCap
+	}
+
+	return wait.Jitter(delay, 1.0)
+}
+
+func (c *resilientWatchClient) watchStarted(resource, key string) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	state := c.state(key)
+
+	if state.started {
+		ended := state.ended
+		if ended == "" {
+			ended = watchEndClosed
+		}
+
+		brokerWatchRestartsCounter.WithLabelValues(c.brokerName, resource, ended).Inc()
+	}
+
+	state.started = true
+	state.ended = ""
+}
+
+// failing returns whether any of the client's watches is failing.
+func (c *resilientWatchClient) failing() bool {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	for _, state := range c.watches {
+		if state.failures > 0 {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (c *resilientWatchClient) failed(key, reason string) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	state := c.state(key)
+	state.failures++
+	state.ended = reason
+}
+
+func (c *resilientWatchClient) succeeded(key string) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	c.state(key).failures = 0
+}
+
+type resilientWatchResource struct {
+	dynamic.ResourceInterface
+	base      dynamic.NamespaceableResourceInterface
+	gvr       schema.GroupVersionResource
+	namespace string
+	client    *resilientWatchClient
+}
+
+func (r *resilientWatchResource) Namespace(namespace string) dynamic.ResourceInterface {
+	return &resilientWatchResource{ResourceInterface: r.base.Namespace(namespace), base: r.base, gvr: r.gvr, namespace: namespace,
+		client: r.client}
+}
+
+func (r *resilientWatchResource) key() string {
+	return r.gvr.String() + "/" + r.namespace
+}
+
+func (r *resilientWatchResource) List(ctx context.Context, opts metav1.ListOptions) (*unstructured.UnstructuredList, error) {
+	if err := r.client.narrow(&opts); err != nil {
+		return nil, err
+	}
+
+	if delay := r.client.relistDelay(r.key()); delay > 0 {
+		klog.V(log.DEBUG).Infof("Delaying the relist of %s from broker %q by %v", r.gvr.Resource, r.client.brokerName, delay)
+
+		select {
+		case <-time.After(delay):
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+
+	list, err := r.listPages(ctx, opts)
+	if err != nil {
+		r.client.failed(r.key(), watchEndError)
+		return nil, err
+	}
+
+	r.client.succeeded(r.key())
+
+	return list, nil
+}
+
+// listPages lists the resources a page at a time, unless the caller paginates itself, and returns them all. Lists at
+// resource version 0 are served from the apiserver's watch cache, which doesn't paginate them, so they're made at the
+// latest resource version instead.
+func (r *resilientWatchResource) listPages(ctx context.Context, opts metav1.ListOptions) (*unstructured.UnstructuredList, error) {
+	if opts.Limit > 0 || opts.Continue != "" {
+		return r.ResourceInterface.List(ctx, opts)
+	}
+
+	if opts.ResourceVersion == "0" {
+		opts.ResourceVersion = ""
+	}
+
+	opts.Limit = brokerListPageSize
+
+	list, err := r.ResourceInterface.List(ctx, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	// The following pages are consistent with the first, whose resource version the whole list has
+	opts.ResourceVersion = ""
+
+	for list.GetContinue() != "" {
+		opts.Continue = list.GetContinue()
+
+		page, err := r.ResourceInterface.List(ctx, opts)
+		if err != nil {
+			return nil, err
+		}
+
+		list.Items = append(list.Items, page.Items...)
+		list.SetContinue(page.GetContinue())
+	}
+
+	return list, nil
+}
+
+func (r *resilientWatchResource) Watch(ctx context.Context, opts metav1.ListOptions) (watch.Interface, error) {
+	if err := r.client.narrow(&opts); err != nil {
+		return nil, err
+	}
+
+	opts.AllowWatchBookmarks = true
+
+	key := r.key()
+	r.client.watchStarted(r.gvr.Resource, key)
+
+	w, err := r.ResourceInterface.Watch(ctx, opts)
+	if err != nil {
+		r.client.failed(key, watchEndError)
+		return nil, err
+	}
+
+	return watch.Filter(w, func(event watch.Event) (watch.Event, bool) {
+		if event.Type != watch.Error {
+			r.client.succeeded(key)
+			return event, true
+		}
+
+		err := apierrors.FromObject(event.Object)
+		if apierrors.IsResourceExpired(err) || apierrors.IsGone(err) {
+			klog.Infof("The watch of %s on broker %q expired, they will be relisted: %v", r.gvr.Resource, r.client.brokerName, err)
+			r.client.failed(key, watchEndExpired)
+		} else {
+			r.client.failed(key, watchEndError)
+		}
+
+		return event, true
+	}), nil
+}
+
+// resilientBrokerClient sets the client of the broker configured in syncerConf to one with resilient watches, whose lists
+// and watches are narrowed to the resources matching the given selector, building it from the BROKER_K8S environment
+// variables if needed.
+func resilientBrokerClient(syncerConf *broker.SyncerConfig, brokerName string, selector labels.Selector) error {
+	if syncerConf.BrokerClient == nil {
+		if syncerConf.BrokerRestConfig == nil {
+			spec := &BrokerSpec{Name: brokerName}
+			if err := envconfig.Process("broker_k8s", spec); err != nil {
+				return errors.Wrap(err, "error reading the broker configuration")
+			}
+
+			restConfig, err := spec.RestConfig()
+			if err != nil {
+				return errors.Wrapf(err, "invalid configuration for broker %q", brokerName)
+			}
+
+			syncerConf.BrokerRestConfig = restConfig
+			syncerConf.BrokerNamespace = spec.RemoteNamespace
+		}
+
+		client, err := dynamic.NewForConfig(syncerConf.BrokerRestConfig)
+		if err != nil {
+			return errors.Wrapf(err, "error creating the client for broker %q", brokerName)
+		}
+
+		syncerConf.BrokerClient = client
+	}
+
+	syncerConf.BrokerClient = newResilientWatchClient(syncerConf.BrokerClient, brokerName, selector)
+
+	return nil
+}
+
+// importSelector returns the selector of the broker resources the agent imports, exported from the namespaces and by
+// the clusters it's configured to import from, if any.
+func importSelector(spec *AgentSpecification) (labels.Selector, error) {
+	selector := labels.Everything()
+
+	if len(spec.ImportNamespaces) > 0 {
+		requirement, err := labels.NewRequirement(lhconstants.LabelSourceNamespace, selection.In, spec.ImportNamespaces)
+		if err != nil {
+			return nil, errors.Wrap(err, "invalid import namespaces")
+		}
+
+		selector = selector.Add(*requirement)
+	}
+
+	if len(spec.ImportClusters) > 0 {
+		// The agent syncs the resources this cluster exports too
+		requirement, err := labels.NewRequirement(lhconstants.LabelSourceCluster, selection.In,
+			append([]string{spec.ClusterID}, spec.ImportClusters...))
+		if err != nil {
+			return nil, errors.Wrap(err, "invalid import clusters")
+		}
+
+		selector = selector.Add(*requirement)
+	}
+
+	return selector, nil
+}
@@ -0,0 +1,313 @@
+/*
+SPDX-License-Identifier: Apache-2.0
+
+Copyright Contributors to the Submariner project.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package controller
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	lhconstants "github.com/submariner-io/lighthouse/pkg/constants"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/watch"
+	"k8s.io/client-go/dynamic"
+)
+
+var _ = Describe("Resilient broker watches", func() {
+	const brokerName = "watch-test"
+
+	var (
+		resource *fakeBrokerResource
+		client   *resilientWatchClient
+		gvr      schema.GroupVersionResource
+		key      string
+	)
+
+	BeforeEach(func() {
+		resource = &fakeBrokerResource{watcher: watch.NewFakeWithChanSize(1, false)}
+		client = newResilientWatchClient(&fakeBrokerClient{resource: resource}, brokerName,
+			labels.Everything()).(*resilientWatchClient)
+		gvr = schema.GroupVersionResource{Group: "multicluster.x-k8s.io", Version: "v1alpha1", Resource: "serviceimports"}
+		key = gvr.String() + "/broker-ns"
+	})
+
+	failTimes := func(n int) {
+		for i := 0; i < n; i++ {
+			client.failed(key, watchEndError)
+		}
+	}
+
+	When("a watch hasn't failed", func() {
+		It("should not delay the relist", func() {
+			Expect(client.relistDelay(key)).To(BeZero())
+			Expect(client.failing()).To(BeFalse())
+		})
+	})
+
+	When("a watch fails repeatedly", func() {
+		It("should double the jittered relist delay with each failure", func() {
+			for failures, base := range map[int]time.Duration{1: relistBackoffBase, 2: 2 * relistBackoffBase,
+				4: 8 * relistBackoffBase} {
+				client.watches = map[string]*brokerWatchState{}
+				failTimes(failures)
+
+				delay := client.relistDelay(key)
+				Expect(delay).To(BeNumerically(">=", base))
+				Expect(delay).To(BeNumerically("<=", 2*base))
+			}
+
+			Expect(client.failing()).To(BeTrue())
+		})
+
+		It("should cap the relist delay", func() {
+			failTimes(30)
+
+			delay := client.relistDelay(key)
+			Expect(delay).To(BeNumerically(">=", relistBackoffCap))
+			Expect(delay).To(BeNumerically("<=", 2*relistBackoffCap))
+		})
+	})
+
+	When("the relist following a failure succeeds", func() {
+		It("should reset the backoff even if no watch event follows", func() {
+			failTimes(1)
+
+			_, err := client.Resource(gvr).Namespace("broker-ns").List(context.TODO(), metav1.ListOptions{})
+			Expect(err).To(Succeed())
+
+			Expect(client.relistDelay(key)).To(BeZero())
+			Expect(client.failing()).To(BeFalse())
+		})
+	})
+
+	When("a watch expires", func() {
+		It("should count the restarted watch as following an expiry", func() {
+			restarts := brokerWatchRestartsCounter.WithLabelValues(brokerName, gvr.Resource, watchEndExpired)
+			before := testutil.ToFloat64(restarts)
+
+			w, err := client.Resource(gvr).Namespace("broker-ns").Watch(context.TODO(), metav1.ListOptions{})
+			Expect(err).To(Succeed())
+
+			expired := apierrors.NewResourceExpired("too old resource version")
+			resource.watcher.Error(&expired.ErrStatus)
+			Eventually(w.ResultChan()).Should(Receive())
+			Expect(client.failing()).To(BeTrue())
+
+			_, err = client.Resource(gvr).Namespace("broker-ns").Watch(context.TODO(), metav1.ListOptions{})
+			Expect(err).To(Succeed())
+			Expect(testutil.ToFloat64(restarts) - before).To(Equal(float64(1)))
+		})
+	})
+
+	When("the resources span several pages", func() {
+		BeforeEach(func() {
+			resource.pages = [][]unstructured.Unstructured{newBrokerResources("a", 2), newBrokerResources("b", 2),
+				newBrokerResources("c", 1)}
+		})
+
+		It("should list all the pages", func() {
+			list, err := client.Resource(gvr).Namespace("broker-ns").List(context.TODO(), metav1.ListOptions{})
+			Expect(err).To(Succeed())
+			Expect(list.Items).To(HaveLen(5))
+			Expect(list.GetContinue()).To(BeEmpty())
+
+			Expect(resource.lists).To(HaveLen(3))
+			for i, opts := range resource.lists {
+				Expect(opts.Limit).To(Equal(int64(brokerListPageSize)))
+
+				if i == 0 {
+					Expect(opts.Continue).To(BeEmpty())
+				} else {
+					Expect(opts.Continue).To(Equal(strconv.Itoa(i)))
+				}
+			}
+		})
+
+		It("should list the latest resource version instead of the watch cache's", func() {
+			_, err := client.Resource(gvr).Namespace("broker-ns").List(context.TODO(), metav1.ListOptions{ResourceVersion: "0"})
+			Expect(err).To(Succeed())
+
+			Expect(resource.lists).To(HaveLen(3))
+			for _, opts := range resource.lists {
+				Expect(opts.ResourceVersion).To(BeEmpty())
+			}
+		})
+
+		It("should list the following pages consistently with the first", func() {
+			_, err := client.Resource(gvr).Namespace("broker-ns").List(context.TODO(), metav1.ListOptions{ResourceVersion: "10"})
+			Expect(err).To(Succeed())
+
+			Expect(resource.lists).To(HaveLen(3))
+			Expect(resource.lists[0].ResourceVersion).To(Equal("10"))
+			Expect(resource.lists[1].ResourceVersion).To(BeEmpty())
+			Expect(resource.lists[2].ResourceVersion).To(BeEmpty())
+		})
+
+		Context("and the caller paginates itself", func() {
+			It("should list the requested page only", func() {
+				list, err := client.Resource(gvr).Namespace("broker-ns").List(context.TODO(),
+					metav1.ListOptions{Limit: 2, Continue: "1"})
+				Expect(err).To(Succeed())
+				Expect(list.Items).To(Equal(resource.pages[1]))
+				Expect(list.GetContinue()).To(Equal("2"))
+
+				Expect(resource.lists).To(HaveLen(1))
+				Expect(resource.lists[0].Limit).To(Equal(int64(2)))
+				Expect(resource.lists[0].Continue).To(Equal("1"))
+			})
+		})
+
+		Context("and listing a page fails", func() {
+			It("should fail the list and back off", func() {
+				resource.failContinue = "1"
+
+				_, err := client.Resource(gvr).Namespace("broker-ns").List(context.TODO(), metav1.ListOptions{})
+				Expect(err).To(HaveOccurred())
+				Expect(client.relistDelay(key)).To(BeNumerically(">", 0))
+			})
+		})
+	})
+
+	When("the client is narrowed by a selector", func() {
+		BeforeEach(func() {
+			selector, err := importSelector(&AgentSpecification{ClusterID: "east", ImportNamespaces: []string{"ns1", "ns2"}})
+			Expect(err).To(Succeed())
+
+			client = newResilientWatchClient(&fakeBrokerClient{resource: resource}, brokerName, selector).(*resilientWatchClient)
+		})
+
+		It("should add it to the selector of the lists and watches", func() {
+			opts := metav1.ListOptions{LabelSelector: lhconstants.LabelSourceCluster + "=west"}
+
+			_, err := client.Resource(gvr).Namespace("broker-ns").List(context.TODO(), opts)
+			Expect(err).To(Succeed())
+
+			_, err = client.Resource(gvr).Namespace("broker-ns").Watch(context.TODO(), opts)
+			Expect(err).To(Succeed())
+
+			for _, opts := range []metav1.ListOptions{resource.lists[0], resource.watches[0]} {
+				selector, err := labels.Parse(opts.LabelSelector)
+				Expect(err).To(Succeed())
+				Expect(selector.Matches(labels.Set{lhconstants.LabelSourceCluster: "west",
+					lhconstants.LabelSourceNamespace: "ns1"})).To(BeTrue())
+				Expect(selector.Matches(labels.Set{lhconstants.LabelSourceCluster: "west",
+					lhconstants.LabelSourceNamespace: "ns3"})).To(BeFalse())
+				Expect(selector.Matches(labels.Set{lhconstants.LabelSourceCluster: "east",
+					lhconstants.LabelSourceNamespace: "ns1"})).To(BeFalse())
+			}
+		})
+
+		It("should fail the lists given an invalid selector", func() {
+			_, err := client.Resource(gvr).Namespace("broker-ns").List(context.TODO(), metav1.ListOptions{LabelSelector: "x in ("})
+			Expect(err).To(HaveOccurred())
+			Expect(resource.lists).To(BeEmpty())
+		})
+
+		It("should not narrow the unnarrowed client", func() {
+			_, err := unnarrowedBrokerClient(client).Resource(gvr).Namespace("broker-ns").List(context.TODO(), metav1.ListOptions{})
+			Expect(err).To(Succeed())
+			Expect(resource.lists[0].LabelSelector).To(BeEmpty())
+		})
+	})
+})
+
+func newBrokerResources(prefix string, n int) []unstructured.Unstructured {
+	resources := make([]unstructured.Unstructured, n)
+	for i := range resources {
+		resources[i].SetName(prefix + strconv.Itoa(i))
+	}
+
+	return resources
+}
+
+// fakeBrokerClient serves a single fake broker resource, whatever the resource requested.
+type fakeBrokerClient struct {
+	dynamic.Interface
+	resource *fakeBrokerResource
+}
+
+func (c *fakeBrokerClient) Resource(schema.GroupVersionResource) dynamic.NamespaceableResourceInterface {
+	return c.resource
+}
+
+// fakeBrokerResource serves its pages of resources to the lists requesting a limit, and all of them otherwise, and
+// records the options it's listed and watched with.
+type fakeBrokerResource struct {
+	dynamic.NamespaceableResourceInterface
+	pages   [][]unstructured.Unstructured
+	lists   []metav1.ListOptions
+	watches []metav1.ListOptions
+	watcher *watch.FakeWatcher
+	// failContinue is the continue token whose page fails to list, if any
+	failContinue string
+}
+
+func (r *fakeBrokerResource) Namespace(string) dynamic.ResourceInterface {
+	return r
+}
+
+func (r *fakeBrokerResource) List(_ context.Context, opts metav1.ListOptions) (*unstructured.UnstructuredList, error) {
+	r.lists = append(r.lists, opts)
+
+	list := &unstructured.UnstructuredList{}
+	list.SetResourceVersion("1")
+
+	if opts.Limit == 0 {
+		for _, page := range r.pages {
+			list.Items = append(list.Items, page...)
+		}
+
+		return list, nil
+	}
+
+	index := 0
+
+	if r.failContinue != "" && opts.Continue == r.failContinue {
+		return nil, apierrors.NewInternalError(errors.New("fake page failure"))
+	}
+
+	if opts.Continue != "" {
+		var err error
+		if index, err = strconv.Atoi(opts.Continue); err != nil {
+			return nil, apierrors.NewBadRequest("invalid continue token")
+		}
+	}
+
+	if index < len(r.pages) {
+		list.Items = r.pages[index]
+	}
+
+	if index+1 < len(r.pages) {
+		list.SetContinue(strconv.Itoa(index + 1))
+	}
+
+	return list, nil
+}
+
+func (r *fakeBrokerResource) Watch(_ context.Context, opts metav1.ListOptions) (watch.Interface, error) {
+	r.watches = append(r.watches, opts)
+	return r.watcher, nil
+}
@@ -0,0 +1,75 @@
+/*
+SPDX-License-Identifier: Apache-2.0
+
+Copyright Contributors to the Submariner project.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package controller_test
+
+import (
+	"context"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/submariner-io/admiral/pkg/syncer/test"
+	"github.com/submariner-io/lighthouse/pkg/agent/controller"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	mcsv1a1 "sigs.k8s.io/mcs-api/pkg/apis/v1alpha1"
+)
+
+var _ = Describe("Cluster membership", func() {
+	var t *testDriver
+
+	BeforeEach(func() {
+		t = newTestDiver()
+
+		t.cluster2.createCluster(clusterID1)
+		t.cluster2.createCluster(clusterID2)
+	})
+
+	JustBeforeEach(func() {
+		t.justBeforeEach()
+		t.createService()
+		t.createServiceExport()
+	})
+
+	AfterEach(func() {
+		t.afterEach()
+	})
+
+	When("a cluster leaves the clusterset", func() {
+		It("should prune the services imported from it", func() {
+			t.cluster2.awaitServiceImport(t.service, mcsv1a1.ClusterSetIP, t.service.Spec.ClusterIP)
+
+			Expect(t.cluster2.localDynClient.Resource(controller.ClusterGVR).Namespace(test.LocalNamespace).Delete(
+				context.TODO(), clusterID1, metav1.DeleteOptions{})).To(Succeed())
+
+			t.awaitNoServiceImport(t.cluster2.localServiceImportClient)
+			t.cluster1.awaitServiceImport(t.service, mcsv1a1.ClusterSetIP, t.service.Spec.ClusterIP)
+		})
+	})
+})
+
+func (c *cluster) createCluster(clusterID string) {
+	cluster := &unstructured.Unstructured{}
+	cluster.SetAPIVersion("submariner.io/v1")
+	cluster.SetKind("Cluster")
+	cluster.SetName(clusterID)
+	Expect(unstructured.SetNestedField(cluster.Object, clusterID, "spec", "cluster_id")).To(Succeed())
+
+	_, err := c.localDynClient.Resource(controller.ClusterGVR).Namespace(test.LocalNamespace).Create(context.TODO(), cluster,
+		metav1.CreateOptions{})
+	Expect(err).To(Succeed())
+}
@@ -0,0 +1,177 @@
+/*
+SPDX-License-Identifier: Apache-2.0
+
+Copyright Contributors to the Submariner project.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package controller
+
+import (
+	"context"
+
+	"github.com/submariner-io/admiral/pkg/log"
+	"github.com/submariner-io/admiral/pkg/util"
+	lhconstants "github.com/submariner-io/lighthouse/pkg/constants"
+	discovery "k8s.io/api/discovery/v1beta1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/watch"
+	"k8s.io/client-go/tools/cache"
+	"k8s.io/klog"
+	mcsv1a1 "sigs.k8s.io/mcs-api/pkg/apis/v1alpha1"
+)
+
+// ClusterGVR identifies the Submariner Cluster resources, synced from the broker into the agent's namespace, which
+// list the members of the clusterset.
+var ClusterGVR = schema.GroupVersionResource{Group: "submariner.io", Version: "v1", Resource: "clusters"}
+
+// startClusterMembershipWatch watches the clusterset's members and prunes the imported ServiceImports and
+// EndpointSlices of clusters which leave it, so that DNS doesn't keep answering with their unreachable IPs. Imports
+// from clusters which left while the agent wasn't running are pruned once the members are known.
+func (a *Controller) startClusterMembershipWatch(stopCh <-chan struct{}) {
+	client := a.localClient.Resource(ClusterGVR).Namespace(a.namespace)
+
+	if _, err := client.List(context.TODO(), metav1.ListOptions{}); err != nil {
+		klog.Warningf("Unable to list the Cluster resources, imports won't be pruned when clusters leave the clusterset: %v",
+			err)
+		return
+	}
+
+	store, informer := cache.NewInformer(&cache.ListWatch{
+		ListFunc: func(options metav1.ListOptions) (runtime.Object, error) {
+			return client.List(context.TODO(), options)
+		},
+		WatchFunc: func(options metav1.ListOptions) (watch.Interface, error) {
+			return client.Watch(context.TODO(), options)
+		},
+	}, &unstructured.Unstructured{}, 0, cache.ResourceEventHandlerFuncs{
+		DeleteFunc: func(obj interface{}) {
+			if tombstone, ok := obj.(cache.DeletedFinalStateUnknown); ok {
+				obj = tombstone.Obj
+			}
+
+			if cluster, ok := obj.(*unstructured.Unstructured); ok {
+				a.pruneClusterImports(getClusterID(cluster))
+			}
+		},
+	})
+
+	go informer.Run(stopCh)
+
+	go func() {
+		if !cache.WaitForCacheSync(stopCh, informer.HasSynced) {
+			return
+		}
+
+		a.pruneDepartedClusterImports(store.List())
+	}()
+}
+
+// pruneDepartedClusterImports prunes the imports of every cluster which isn't one of the given members. Nothing is
+// pruned if there are no members, since that more likely means they haven't been synced than that none are left.
+func (a *Controller) pruneDepartedClusterImports(members []interface{}) {
+	if len(members) == 0 {
+		return
+	}
+
+	memberIDs := map[string]bool{a.clusterID: true}
+	for _, obj := range members {
+		memberIDs[getClusterID(obj.(*unstructured.Unstructured))] = true
+	}
+
+	departed := map[string]bool{}
+
+	for _, resource := range []runtime.Object{&mcsv1a1.ServiceImport{}, &discovery.EndpointSlice{}} {
+		gvr, err := a.importGVR(resource)
+		if err != nil {
+			klog.Errorf("Error retrieving the resource for %T: %v", resource, err)
+			continue
+		}
+
+		list, err := a.localClient.Resource(*gvr).Namespace(metav1.NamespaceAll).List(context.TODO(), metav1.ListOptions{
+			LabelSelector: lhconstants.LabelSourceCluster,
+		})
+		if err != nil {
+			klog.Errorf("Error listing the imported %s: %v", gvr.Resource, err)
+			continue
+		}
+
+		for i := range list.Items {
+			if clusterID := list.Items[i].GetLabels()[lhconstants.LabelSourceCluster]; !memberIDs[clusterID] {
+				departed[clusterID] = true
+			}
+		}
+	}
+
+	for clusterID := range departed {
+		a.pruneClusterImports(clusterID)
+	}
+}
+
+// pruneClusterImports deletes the local copies of the ServiceImports and EndpointSlices exported by the given cluster.
+func (a *Controller) pruneClusterImports(clusterID string) {
+	if clusterID == "" || clusterID == a.clusterID {
+		return
+	}
+
+	klog.Infof("Cluster %q left the clusterset, pruning its imported services", clusterID)
+
+	selector := labels.SelectorFromSet(map[string]string{lhconstants.LabelSourceCluster: clusterID}).String()
+
+	for _, resource := range []runtime.Object{&mcsv1a1.ServiceImport{}, &discovery.EndpointSlice{}} {
+		gvr, err := a.importGVR(resource)
+		if err != nil {
+			klog.Errorf("Error retrieving the resource for %T: %v", resource, err)
+			continue
+		}
+
+		client := a.localClient.Resource(*gvr)
+
+		list, err := client.Namespace(metav1.NamespaceAll).List(context.TODO(), metav1.ListOptions{LabelSelector: selector})
+		if err != nil {
+			klog.Errorf("Error listing the %s imported from cluster %q: %v", gvr.Resource, clusterID, err)
+			continue
+		}
+
+		for i := range list.Items {
+			obj := &list.Items[i]
+
+			err := client.Namespace(obj.GetNamespace()).Delete(context.TODO(), obj.GetName(), metav1.DeleteOptions{})
+			if err != nil && !apierrors.IsNotFound(err) {
+				klog.Errorf("Error deleting %s %s/%s: %v", gvr.Resource, obj.GetNamespace(), obj.GetName(), err)
+				continue
+			}
+
+			klog.V(log.DEBUG).Infof("Deleted %s %s/%s imported from cluster %q", gvr.Resource, obj.GetNamespace(),
+				obj.GetName(), clusterID)
+		}
+	}
+}
+
+func (a *Controller) importGVR(resource runtime.Object) (*schema.GroupVersionResource, error) {
+	_, gvr, err := util.ToUnstructuredResource(resource, a.restMapper)
+	return gvr, err
+}
+
+func getClusterID(cluster *unstructured.Unstructured) string {
+	if clusterID, found, _ := unstructured.NestedString(cluster.Object, "spec", "cluster_id"); found && clusterID != "" {
+		return clusterID
+	}
+
+	return cluster.GetName()
+}
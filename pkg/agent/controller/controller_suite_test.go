@@ -86,6 +86,7 @@ type cluster struct {
 	localEndpointSliceClient dynamic.ResourceInterface
 	localKubeClient          kubernetes.Interface
 	endpointsReactor         *fake.FailingReactor
+	agentController          *controller.Controller
 }
 
 type testDriver struct {
@@ -270,6 +271,8 @@ func (c *cluster) start(t *testDriver, syncerConfig broker.SyncerConfig) {
 
 	Expect(err).To(Succeed())
 	Expect(agentController.Start(t.stopCh)).To(Succeed())
+
+	c.agentController = agentController
 }
 
 func awaitServiceImport(client dynamic.ResourceInterface, service *corev1.Service, sType mcsv1a1.ServiceImportType,
@@ -298,6 +301,7 @@ func awaitServiceImport(client dynamic.ResourceInterface, service *corev1.Servic
 		Expect(serviceImport.Spec.Ports[i].Name).To(Equal(service.Spec.Ports[i].Name))
 		Expect(serviceImport.Spec.Ports[i].Protocol).To(Equal(service.Spec.Ports[i].Protocol))
 		Expect(serviceImport.Spec.Ports[i].Port).To(Equal(service.Spec.Ports[i].Port))
+		Expect(serviceImport.Spec.Ports[i].AppProtocol).To(Equal(service.Spec.Ports[i].AppProtocol))
 	}
 
 	labels := serviceImport.GetObjectMeta().GetLabels()
@@ -664,6 +668,23 @@ func (t *testDriver) awaitServiceUnavailableStatus(atIndex int) {
 		corev1.ConditionFalse, "ServiceUnavailable"))
 }
 
+func (t *testDriver) awaitExportFailureEvents(reason string, kinds ...string) {
+	Eventually(func() []string {
+		events, err := t.cluster1.localKubeClient.CoreV1().Events(serviceNamespace).List(context.TODO(), metav1.ListOptions{})
+		Expect(err).To(Succeed())
+
+		found := []string{}
+
+		for i := range events.Items {
+			if events.Items[i].Reason == reason && events.Items[i].Type == corev1.EventTypeWarning {
+				found = append(found, events.Items[i].InvolvedObject.Kind)
+			}
+		}
+
+		return found
+	}, 5).Should(ConsistOf(kinds))
+}
+
 func (t *testDriver) endpointIPs() []string {
 	ips := []string{}
 	for _, a := range t.endpoints.Subsets[0].Addresses {
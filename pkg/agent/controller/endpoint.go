@@ -19,6 +19,8 @@ package controller
 
 import (
 	"context"
+	"fmt"
+	"time"
 
 	"github.com/submariner-io/admiral/pkg/log"
 	"github.com/submariner-io/admiral/pkg/syncer"
@@ -34,15 +36,38 @@ import (
 	"k8s.io/apimachinery/pkg/labels"
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/watch"
 	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/tools/cache"
 	"k8s.io/klog"
 	utilnet "k8s.io/utils/net"
 	mcsv1a1 "sigs.k8s.io/mcs-api/pkg/apis/v1alpha1"
 )
 
+const (
+	unsupportedHostNetwork = "UnsupportedHostNetworkEndpoints"
+	unsupportedExternal    = "UnsupportedExternalEndpoints"
+	// nodeGlobalIPAnnotation holds the global IP Globalnet allocates to a node, through which the gateway forwards
+	// traffic to its host network
+	nodeGlobalIPAnnotation = "submariner.io/globalIp"
+	// podCacheSyncTimeout bounds the wait for the pods of a headless service's namespace to be cached
+	podCacheSyncTimeout = 30 * time.Second
+)
+
+var endpointSliceGVR = schema.GroupVersionResource{Group: "discovery.k8s.io", Version: "v1beta1", Resource: "endpointslices"}
+
+// hostNetworkEndpoints tracks the host-networked pods found while building an EndpointSlice, along with the addresses
+// which aren't pods', e.g. those of the manually managed Endpoints of services without selectors.
+type hostNetworkEndpoints struct {
+	exported    bool
+	unsupported []string
+	external    []string
+}
+
 func startEndpointController(localClient dynamic.Interface, restMapper meta.RESTMapper, scheme *runtime.Scheme,
 	serviceImport *mcsv1a1.ServiceImport, serviceImportNameSpace, serviceName, clusterID string,
-	globalnetEnabled bool) (*EndpointController, error) {
+	globalnetEnabled, ipv6Only, selectorless bool, updateExportStatus exportStatusUpdater,
+	clearExportStatus exportStatusClearer) (*EndpointController, error) {
 	klog.V(log.DEBUG).Infof("Starting Endpoints controller for service %s/%s", serviceImportNameSpace, serviceName)
 
 	globalIngressIPGVR, _ := schema.ParseResourceArg("globalingressips.v1.submariner.io")
@@ -52,12 +77,25 @@ func startEndpointController(localClient dynamic.Interface, restMapper meta.REST
 		serviceImportUID:             serviceImport.UID,
 		serviceImportName:            serviceImport.Name,
 		serviceImportSourceNameSpace: serviceImportNameSpace,
+		importNamespace:              serviceImport.Annotations[lhconstants.ImportNamespace],
 		serviceName:                  serviceName,
 		stopCh:                       make(chan struct{}),
 		isHeadless:                   serviceImport.Spec.Type == mcsv1a1.Headless,
+		publishNotReady:              serviceImport.Annotations[lhconstants.PublishNotReadyAddresses] == "true",
 		globalnetEnabled:             globalnetEnabled,
+		ipv6Only:                     ipv6Only,
 		localClient:                  localClient,
 		ingressIPClient:              localClient.Resource(*globalIngressIPGVR),
+		podClient:                    localClient.Resource(schema.GroupVersionResource{Version: "v1", Resource: "pods"}),
+		nodeClient:                   localClient.Resource(schema.GroupVersionResource{Version: "v1", Resource: "nodes"}),
+		updateExportStatus:           updateExportStatus,
+		clearExportStatus:            clearExportStatus,
+	}
+
+	// The endpoints of headless services are their pods' IPs, unless the pods use the host's network
+	if controller.isHeadless && !controller.startPodInformer() {
+		close(controller.stopCh)
+		return nil, fmt.Errorf("timed out waiting for the pods of namespace %q to be cached", serviceImportNameSpace)
 	}
 
 	nameSelector := fields.OneTermEqualSelector("metadata.name", serviceName)
@@ -72,6 +110,7 @@ func startEndpointController(localClient dynamic.Interface, restMapper meta.REST
 		Federator:           broker.NewFederator(localClient, restMapper, serviceImportNameSpace, "", "ownerReferences"),
 		ResourceType:        &corev1.Endpoints{},
 		Transform:           controller.endpointsToEndpointSlice,
+		OnSuccessfulSync:    controller.onSuccessfulEndpointSliceSync,
 		Scheme:              scheme,
 	})
 	if err != nil {
@@ -82,6 +121,14 @@ func startEndpointController(localClient dynamic.Interface, restMapper meta.REST
 		return nil, err
 	}
 
+	// The endpoints of services without selectors are managed manually, either as Endpoints, which Kubernetes mirrors
+	// as EndpointSlices, or directly as EndpointSlices, which are mirrored to the clusterset too
+	if selectorless {
+		if err := controller.startEndpointSliceMirror(restMapper, scheme); err != nil {
+			return nil, err
+		}
+	}
+
 	return controller, nil
 }
 
@@ -91,19 +138,31 @@ func (e *EndpointController) stop() {
 }
 
 func (e *EndpointController) cleanup() {
-	resourceClient := e.localClient.Resource(schema.GroupVersionResource{Group: "discovery.k8s.io",
-		Version: "v1beta1", Resource: "endpointslices"}).Namespace(e.serviceImportSourceNameSpace)
+	err := deleteEndpointSlices(e.localClient, e.serviceImportSourceNameSpace, e.serviceImportName)
+	if err != nil {
+		klog.Errorf("Error deleting the EndpointSlices associated with serviceImport %q: %v", e.serviceImportName, err)
+	}
+}
+
+// deleteEndpointSlices deletes the EndpointSlices created in the given namespace for the given ServiceImport.
+func deleteEndpointSlices(localClient dynamic.Interface, namespace, serviceImportName string) error {
+	resourceClient := localClient.Resource(endpointSliceGVR).Namespace(namespace)
 
-	endpointSliceLabels := labels.SelectorFromSet(map[string]string{lhconstants.LabelServiceImportName: e.serviceImportName})
+	endpointSliceLabels := labels.SelectorFromSet(map[string]string{lhconstants.LabelServiceImportName: serviceImportName})
 	listEndpointSliceOptions := metav1.ListOptions{
 		LabelSelector: endpointSliceLabels.String(),
 	}
 
 	err := resourceClient.DeleteCollection(context.TODO(), metav1.DeleteOptions{}, listEndpointSliceOptions)
-
 	if err != nil && !errors.IsNotFound(err) {
-		klog.Errorf("Error deleting the EndpointSlices associated with serviceImport %q: %v", e.serviceImportName, err)
+		return err
 	}
+
+	return nil
+}
+
+func (e *EndpointController) onSuccessfulEndpointSliceSync(synced runtime.Object, op syncer.Operation) {
+	endpointSlicesCounter.WithLabelValues(directionExport, operationVerb(op)).Inc()
 }
 
 func (e *EndpointController) endpointsToEndpointSlice(obj runtime.Object, numRequeues int, op syncer.Operation) (runtime.Object, bool) {
@@ -136,45 +195,63 @@ func (e *EndpointController) endpointSliceFromEndpoints(endpoints *corev1.Endpoi
 	endpointSlice := &discovery.EndpointSlice{}
 
 	endpointSlice.Name = endpoints.Name + "-" + e.clusterID
-	endpointSlice.Labels = map[string]string{
-		lhconstants.LabelServiceImportName: e.serviceImportName,
-		discovery.LabelManagedBy:           lhconstants.LabelValueManagedBy,
-		lhconstants.LabelSourceNamespace:   e.serviceImportSourceNameSpace,
-		lhconstants.LabelSourceCluster:     e.clusterID,
-		lhconstants.LabelSourceName:        e.serviceName,
-	}
-
+	endpointSlice.Labels = e.endpointSliceLabels()
 	endpointSlice.AddressType = discovery.AddressTypeIPv4
 
+	hostNetwork := &hostNetworkEndpoints{}
+
 	if len(endpoints.Subsets) > 0 {
 		subset := endpoints.Subsets[0]
 		for i := range subset.Ports {
 			endpointSlice.Ports = append(endpointSlice.Ports, discovery.EndpointPort{
-				Port:     &subset.Ports[i].Port,
-				Name:     &subset.Ports[i].Name,
-				Protocol: &subset.Ports[i].Protocol,
+				Port:        &subset.Ports[i].Port,
+				Name:        &subset.Ports[i].Name,
+				Protocol:    &subset.Ports[i].Protocol,
+				AppProtocol: subset.Ports[i].AppProtocol,
 			})
 		}
 
-		if allAddressesIPv6(append(subset.Addresses, subset.NotReadyAddresses...)) {
+		// IPv6-only clustersets only export the IPv6 addresses of dual-stack endpoints
+		if e.ipv6Only || allAddressesIPv6(append(subset.Addresses, subset.NotReadyAddresses...)) {
 			endpointSlice.AddressType = discovery.AddressTypeIPv6
 		}
 
-		newEndpoints, retry := e.getEndpointsFromAddresses(subset.Addresses, endpointSlice.AddressType, true)
+		newEndpoints, retry := e.getEndpointsFromAddresses(subset.Addresses, endpointSlice.AddressType, true, hostNetwork)
 		if retry {
 			return nil, true
 		}
 
 		endpointSlice.Endpoints = append(endpointSlice.Endpoints, newEndpoints...)
-		newEndpoints, retry = e.getEndpointsFromAddresses(subset.NotReadyAddresses, endpointSlice.AddressType, false)
+
+		// A service publishing its not-ready addresses already has them listed as ready by Kubernetes; the ServiceExport
+		// can request the same, e.g. so that the peers of a StatefulSet resolve each other while they bootstrap
+		newEndpoints, retry = e.getEndpointsFromAddresses(subset.NotReadyAddresses, endpointSlice.AddressType, e.publishNotReady,
+			hostNetwork)
 		if retry {
-			// TODO: We may not want unready endpoints at all
 			return nil, true
 		}
 
 		endpointSlice.Endpoints = append(endpointSlice.Endpoints, newEndpoints...)
 	}
 
+	// Host-networked pods are reached via their node's IP rather than the pod network, let consumers know
+	if hostNetwork.exported {
+		endpointSlice.Labels[lhconstants.LabelHostNetwork] = "true"
+	}
+
+	if len(hostNetwork.external) > 0 {
+		e.reportExternalEndpoints(hostNetwork.external)
+	}
+
+	if len(hostNetwork.unsupported) > 0 {
+		klog.Warningf("Host-networked pods %v backing service %s/%s can't be exported with Globalnet",
+			hostNetwork.unsupported, e.serviceImportSourceNameSpace, e.serviceName)
+		e.flagUnsupported(unsupportedHostNetwork, fmt.Sprintf(
+			"Host-networked pods %v and their nodes have no global IP, they are not exported", hostNetwork.unsupported))
+	} else {
+		e.clearUnsupported(unsupportedHostNetwork)
+	}
+
 	if op == syncer.Create {
 		klog.V(log.DEBUG).Infof("Returning EndpointSlice: %#v", endpointSlice)
 	} else {
@@ -184,37 +261,81 @@ func (e *EndpointController) endpointSliceFromEndpoints(endpoints *corev1.Endpoi
 	return endpointSlice, false
 }
 
+// endpointSliceLabels returns the labels of the EndpointSlices exported for the service.
+func (e *EndpointController) endpointSliceLabels() map[string]string {
+	endpointSliceLabels := map[string]string{
+		lhconstants.LabelServiceImportName: e.serviceImportName,
+		discovery.LabelManagedBy:           lhconstants.LabelValueManagedBy,
+		lhconstants.LabelSourceNamespace:   e.serviceImportSourceNameSpace,
+		lhconstants.LabelSourceCluster:     e.clusterID,
+		lhconstants.LabelSourceName:        e.serviceName,
+	}
+
+	if e.importNamespace != "" {
+		endpointSliceLabels[lhconstants.ImportNamespace] = e.importNamespace
+	}
+
+	return endpointSliceLabels
+}
+
 func (e *EndpointController) getEndpointsFromAddresses(addresses []corev1.EndpointAddress, addressType discovery.AddressType,
-	ready bool) ([]discovery.Endpoint, bool) {
+	ready bool, hostNetwork *hostNetworkEndpoints) ([]discovery.Endpoint, bool) {
 	endpoints := []discovery.Endpoint{}
 	isIPv6AddressType := addressType == discovery.AddressTypeIPv6
 
 	for _, address := range addresses {
 		if utilnet.IsIPv6String(address.IP) == isIPv6AddressType {
-			endpoint, retry := e.endpointFromAddress(address, ready)
+			endpoint, retry := e.endpointFromAddress(address, ready, hostNetwork)
 			if retry {
 				return nil, true
 			}
 
-			endpoints = append(endpoints, *endpoint)
+			if endpoint != nil {
+				endpoints = append(endpoints, *endpoint)
+			}
 		}
 	}
 
 	return endpoints, false
 }
 
-func (e *EndpointController) endpointFromAddress(address corev1.EndpointAddress, ready bool) (*discovery.Endpoint, bool) {
+func (e *EndpointController) endpointFromAddress(address corev1.EndpointAddress, ready bool,
+	hostNetwork *hostNetworkEndpoints) (*discovery.Endpoint, bool) {
 	topology := map[string]string{}
 	if address.NodeName != nil {
-		topology["kubernetes.io/hostname"] = *address.NodeName
+		topology[corev1.LabelHostname] = *address.NodeName
+		e.addNodeTopology(*address.NodeName, topology)
 	}
 
+	isHostNetwork := e.isHeadless && e.isHostNetwork(address)
+
 	ip := e.getIP(address)
 
+	// Host-networked pods without a global IP of their own are reached through their node's, if it has one
+	if ip == "" && isHostNetwork && address.NodeName != nil {
+		ip = e.nodeGlobalIP(*address.NodeName)
+	}
+
 	if ip == "" {
+		// Globalnet doesn't allocate global IPs to host-networked pods, nor to addresses which aren't pods' so, unless
+		// one was provided, skip the endpoint rather than waiting for an IP that won't come.
+		if address.TargetRef == nil {
+			hostNetwork.external = append(hostNetwork.external, address.IP)
+			return nil, false
+		}
+
+		if isHostNetwork {
+			hostNetwork.unsupported = append(hostNetwork.unsupported, address.TargetRef.Name)
+			return nil, false
+		}
+
 		return nil, true
 	}
 
+	if isHostNetwork {
+		hostNetwork.exported = true
+	}
+
 	endpoint := &discovery.Endpoint{
 		Addresses:  []string{ip},
 		Conditions: discovery.EndpointConditions{Ready: &ready},
@@ -252,8 +373,120 @@ func allAddressesIPv6(addresses []corev1.EndpointAddress) bool {
 	return true
 }
 
+// addNodeTopology adds the zone and region of the given node to the topology, so that the DNS server can prefer the
+// endpoints of headless services closest to it, and importing clusters can make topology-aware routing decisions.
+// Nodes only labelled with the deprecated beta labels have their zone and region exported under the stable ones.
+func (e *EndpointController) addNodeTopology(nodeName string, topology map[string]string) {
+	obj, err := e.nodeClient.Get(context.TODO(), nodeName, metav1.GetOptions{})
+	if err != nil {
+		if !errors.IsNotFound(err) {
+			klog.Errorf("Error retrieving node %s: %v", nodeName, err)
+		}
+
+		return
+	}
+
+	labels := obj.GetLabels()
+	for key, deprecated := range map[string]string{
+		corev1.LabelZoneFailureDomainStable: corev1.LabelZoneFailureDomain,
+		corev1.LabelZoneRegionStable:        corev1.LabelZoneRegion,
+	} {
+		if value, ok := labels[key]; ok {
+			topology[key] = value
+		} else if value, ok := labels[deprecated]; ok {
+			topology[key] = value
+		}
+	}
+}
+
+// startPodInformer starts caching the pods of the service's namespace, and returns whether they were cached in time.
+func (e *EndpointController) startPodInformer() bool {
+	client := e.podClient.Namespace(e.serviceImportSourceNameSpace)
+
+	var informer cache.Controller
+
+	e.pods, informer = cache.NewInformer(&cache.ListWatch{
+		ListFunc: func(options metav1.ListOptions) (runtime.Object, error) {
+			return client.List(context.TODO(), options)
+		},
+		WatchFunc: func(options metav1.ListOptions) (watch.Interface, error) {
+			return client.Watch(context.TODO(), options)
+		},
+	}, &unstructured.Unstructured{}, 0, cache.ResourceEventHandlerFuncs{})
+
+	go informer.Run(e.stopCh)
+
+	ctx, cancel := context.WithTimeout(context.Background(), podCacheSyncTimeout)
+	defer cancel()
+
+	return cache.WaitForCacheSync(ctx.Done(), informer.HasSynced)
+}
+
+// isHostNetwork checks whether the address belongs to a pod using the host's network namespace, in which case the
+// address is its node's IP.
+func (e *EndpointController) isHostNetwork(address corev1.EndpointAddress) bool {
+	if address.TargetRef == nil || (address.TargetRef.Kind != "" && address.TargetRef.Kind != "Pod") {
+		return false
+	}
+
+	obj, found, err := e.pods.GetByKey(e.serviceImportSourceNameSpace + "/" + address.TargetRef.Name)
+	if err != nil || !found {
+		// The pod may be listed in the Endpoints before it's cached
+		obj, err = e.podClient.Namespace(e.serviceImportSourceNameSpace).Get(context.TODO(), address.TargetRef.Name,
+			metav1.GetOptions{})
+		if err != nil {
+			if !errors.IsNotFound(err) {
+				klog.Errorf("Error retrieving pod %s/%s: %v", e.serviceImportSourceNameSpace, address.TargetRef.Name, err)
+			}
+
+			return false
+		}
+	}
+
+	hostNetwork, _, _ := unstructured.NestedBool(obj.(*unstructured.Unstructured).Object, "spec", "hostNetwork")
+
+	return hostNetwork
+}
+
+// nodeGlobalIP returns the global IP Globalnet allocated to the given node, if any.
+func (e *EndpointController) nodeGlobalIP(nodeName string) string {
+	obj, err := e.nodeClient.Get(context.TODO(), nodeName, metav1.GetOptions{})
+	if err != nil {
+		if !errors.IsNotFound(err) {
+			klog.Errorf("Error retrieving node %s: %v", nodeName, err)
+		}
+
+		return ""
+	}
+
+	return obj.GetAnnotations()[nodeGlobalIPAnnotation]
+}
+
+// flagUnsupported flags the ServiceExport invalid for the given reason.
+func (e *EndpointController) flagUnsupported(reason, msg string) {
+	e.cleared.Store(reason, false)
+	e.updateExportStatus(e.serviceName, e.serviceImportSourceNameSpace, mcsv1a1.ServiceExportValid, corev1.ConditionFalse,
+		reason, msg)
+}
+
+// clearUnsupported marks the ServiceExport valid again if it was flagged invalid for the given reason, which no longer
+// applies. The ServiceExport is checked once after the controller starts, since a previous controller may have flagged
+// it, and then only after the controller flags it.
+func (e *EndpointController) clearUnsupported(reason string) {
+	if cleared, found := e.cleared.Load(reason); found && cleared.(bool) {
+		return
+	}
+
+	e.cleared.Store(reason, true)
+	e.clearExportStatus(e.serviceName, e.serviceImportSourceNameSpace, reason)
+}
+
 func (e *EndpointController) getIP(address corev1.EndpointAddress) string {
 	if e.isHeadless && e.globalnetEnabled {
+		if address.TargetRef == nil {
+			return ""
+		}
+
 		var ip string
 
 		name := "pod-" + address.TargetRef.Name
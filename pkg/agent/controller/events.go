@@ -0,0 +1,89 @@
+/*
+SPDX-License-Identifier: Apache-2.0
+
+Copyright Contributors to the Submariner project.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package controller
+
+import (
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes"
+	typedcorev1 "k8s.io/client-go/kubernetes/typed/core/v1"
+	"k8s.io/client-go/tools/record"
+	"k8s.io/klog"
+	mcsv1a1 "sigs.k8s.io/mcs-api/pkg/apis/v1alpha1"
+)
+
+const (
+	eventSourceComponent = "lighthouse-agent"
+	statusUpdateFailed   = "StatusUpdateFailed"
+)
+
+func newEventBroadcaster(scheme *runtime.Scheme) (record.EventBroadcaster, record.EventRecorder) {
+	broadcaster := record.NewBroadcaster()
+
+	return broadcaster, broadcaster.NewRecorder(scheme, corev1.EventSource{Component: eventSourceComponent})
+}
+
+// startEvents starts delivering the recorded Events until the given channel is closed. In dry-run mode they're only
+// logged.
+func (a *Controller) startEvents(kubeClientSet kubernetes.Interface, stopCh <-chan struct{}) {
+	if a.dryRun {
+		a.eventBroadcaster.StartLogging(klog.Infof)
+	} else {
+		a.eventBroadcaster.StartRecordingToSink(&typedcorev1.EventSinkImpl{Interface: kubeClientSet.CoreV1().Events("")})
+	}
+
+	go func() {
+		<-stopCh
+		a.eventBroadcaster.Shutdown()
+	}()
+}
+
+// recordExportFailure emits a warning Event on the given ServiceExport and, if it exists, the Service it exports, so
+// that failures to export it show up alongside the resources involved.
+func (a *Controller) recordExportFailure(serviceExport *mcsv1a1.ServiceExport, reason, msg string) {
+	if !a.isActive() {
+		return
+	}
+
+	a.eventRecorder.Event(&corev1.ObjectReference{
+		APIVersion: mcsv1a1.SchemeGroupVersion.String(),
+		Kind:       "ServiceExport",
+		Namespace:  serviceExport.Namespace,
+		Name:       serviceExport.Name,
+		UID:        serviceExport.UID,
+	}, corev1.EventTypeWarning, reason, msg)
+
+	if a.serviceSyncer == nil {
+		return
+	}
+
+	obj, found, err := a.serviceSyncer.GetResource(serviceExport.Name, serviceExport.Namespace)
+	if err != nil || !found {
+		return
+	}
+
+	service := obj.(*corev1.Service)
+
+	a.eventRecorder.Event(&corev1.ObjectReference{
+		APIVersion: corev1.SchemeGroupVersion.String(),
+		Kind:       "Service",
+		Namespace:  service.Namespace,
+		Name:       service.Name,
+		UID:        service.UID,
+	}, corev1.EventTypeWarning, reason, msg)
+}
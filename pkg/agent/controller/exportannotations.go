@@ -0,0 +1,77 @@
+/*
+SPDX-License-Identifier: Apache-2.0
+
+Copyright Contributors to the Submariner project.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package controller
+
+import (
+	"reflect"
+	"sync"
+
+	mcsv1a1 "sigs.k8s.io/mcs-api/pkg/apis/v1alpha1"
+)
+
+// exportAnnotations records the annotations of the ServiceExports whose ServiceImport was last derived from them, so
+// that updates changing them derive the ServiceImport again.
+type exportAnnotations struct {
+	mutex  sync.Mutex
+	byName map[string]map[string]string
+}
+
+// changed returns whether the given ServiceExport's annotations differ from those its ServiceImport was derived from,
+// which is the case if none was since the agent started.
+func (e *exportAnnotations) changed(svcExport *mcsv1a1.ServiceExport) bool {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+
+	annotations, found := e.byName[svcExport.Namespace+"/"+svcExport.Name]
+
+	return !found || !reflect.DeepEqual(annotations, copyAnnotations(svcExport.Annotations))
+}
+
+// record records the annotations the given ServiceExport's ServiceImport was derived from.
+func (e *exportAnnotations) record(svcExport *mcsv1a1.ServiceExport) {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+
+	if e.byName == nil {
+		e.byName = map[string]map[string]string{}
+	}
+
+	e.byName[svcExport.Namespace+"/"+svcExport.Name] = copyAnnotations(svcExport.Annotations)
+}
+
+func (e *exportAnnotations) forget(name, namespace string) {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+
+	delete(e.byName, namespace+"/"+name)
+}
+
+func copyAnnotations(annotations map[string]string) map[string]string {
+	copied := make(map[string]string, len(annotations))
+	for k, v := range annotations {
+		copied[k] = v
+	}
+
+	return copied
+}
+
+// isInvalidAnnotationReason returns whether the given export condition reason flags an annotation of the
+// ServiceExport, which the user may since have fixed.
+func isInvalidAnnotationReason(reason string) bool {
+	return reason == invalidNamespace || reason == invalidExportMode || reason == invalidName
+}
@@ -0,0 +1,184 @@
+/*
+SPDX-License-Identifier: Apache-2.0
+
+Copyright Contributors to the Submariner project.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package controller
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"time"
+
+	lhconstants "github.com/submariner-io/lighthouse/pkg/constants"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/klog"
+	utilnet "k8s.io/utils/net"
+	mcsv1a1 "sigs.k8s.io/mcs-api/pkg/apis/v1alpha1"
+)
+
+const (
+	awaitingExternalIPs = "AwaitingExternalIPs"
+	lookupTimeout       = 5 * time.Second
+)
+
+// LookupHost resolves the hostnames of load balancer ingresses; it's an indirection hook for unit tests.
+var LookupHost = net.DefaultResolver.LookupHost
+
+func isExternalExportMode(mode string) bool {
+	return mode == lhconstants.ExportModeLoadBalancer || mode == lhconstants.ExportModeNodePort
+}
+
+// validateExportMode returns why the given service can't be exported in the given mode, if it can't.
+func validateExportMode(svc *corev1.Service, svcType mcsv1a1.ServiceImportType, mode string) string {
+	switch mode {
+	case "", lhconstants.ExportModeClusterIP:
+		return ""
+	case lhconstants.ExportModeLoadBalancer, lhconstants.ExportModeNodePort:
+	default:
+		return fmt.Sprintf("Unknown export mode %q", mode)
+	}
+
+	switch {
+	case svcType != mcsv1a1.ClusterSetIP:
+		return fmt.Sprintf("Headless services can't be exported in the %s mode", mode)
+	case mode == lhconstants.ExportModeLoadBalancer && svc.Spec.Type != corev1.ServiceTypeLoadBalancer:
+		return fmt.Sprintf("Services of type %v can't be exported in the %s mode", svc.Spec.Type, mode)
+	case mode == lhconstants.ExportModeNodePort && svc.Spec.Type != corev1.ServiceTypeNodePort &&
+		svc.Spec.Type != corev1.ServiceTypeLoadBalancer:
+		return fmt.Sprintf("Services of type %v can't be exported in the %s mode", svc.Spec.Type, mode)
+	}
+
+	return ""
+}
+
+// getExternalIPs returns the IPs under which the given service is reachable from other clusters in the given external
+// export mode, or the reason and message of the export condition if there are none yet. They aren't refreshed until
+// the service is exported again, e.g. when the nodes' IPs change.
+func (a *Controller) getExternalIPs(svc *corev1.Service, mode string) (ips []string, reason, msg string) {
+	if mode == lhconstants.ExportModeLoadBalancer {
+		ips = getLoadBalancerIPs(svc)
+		msg = "The Service's load balancer has no ingress IP yet"
+	} else {
+		ips = a.getNodeIPs()
+		msg = "The cluster has no ready node with an IP"
+	}
+
+	if a.ipv6Only {
+		ipv6 := ips[:0]
+
+		for _, ip := range ips {
+			if utilnet.IsIPv6String(ip) {
+				ipv6 = append(ipv6, ip)
+			}
+		}
+
+		ips = ipv6
+		msg += " in the IPv6 family"
+	}
+
+	if len(ips) == 0 {
+		return nil, awaitingExternalIPs, msg
+	}
+
+	return uniqueSorted(ips), "", ""
+}
+
+func getLoadBalancerIPs(svc *corev1.Service) []string {
+	var ips []string
+
+	for _, ingress := range svc.Status.LoadBalancer.Ingress {
+		if ingress.IP != "" {
+			ips = append(ips, ingress.IP)
+			continue
+		}
+
+		if ingress.Hostname == "" {
+			continue
+		}
+
+		ctx, cancel := context.WithTimeout(context.TODO(), lookupTimeout)
+		addresses, err := LookupHost(ctx, ingress.Hostname)
+
+		cancel()
+
+		if err != nil {
+			klog.Errorf("Error resolving the load balancer ingress %q of Service %s/%s: %v", ingress.Hostname, svc.Namespace,
+				svc.Name, err)
+			continue
+		}
+
+		ips = append(ips, addresses...)
+	}
+
+	return ips
+}
+
+// getNodeIPs returns the external IPs of the cluster's ready nodes or, if none has any, their internal IPs.
+func (a *Controller) getNodeIPs() []string {
+	nodes, err := a.kubeClientSet.CoreV1().Nodes().List(context.TODO(), metav1.ListOptions{})
+	if err != nil {
+		klog.Errorf("Error listing the nodes: %v", err)
+		return nil
+	}
+
+	ips := map[corev1.NodeAddressType][]string{}
+
+	for i := range nodes.Items {
+		node := &nodes.Items[i]
+		if !isNodeReady(node) {
+			continue
+		}
+
+		for _, address := range node.Status.Addresses {
+			ips[address.Type] = append(ips[address.Type], address.Address)
+		}
+	}
+
+	if len(ips[corev1.NodeExternalIP]) > 0 {
+		return ips[corev1.NodeExternalIP]
+	}
+
+	return ips[corev1.NodeInternalIP]
+}
+
+func isNodeReady(node *corev1.Node) bool {
+	for i := range node.Status.Conditions {
+		if node.Status.Conditions[i].Type == corev1.NodeReady {
+			return node.Status.Conditions[i].Status == corev1.ConditionTrue
+		}
+	}
+
+	return false
+}
+
+// getNodePortsForService returns the service's ports with their node ports as numbers, which clients in other clusters
+// connect to in the NodePort export mode.
+func getNodePortsForService(service *corev1.Service) []mcsv1a1.ServicePort {
+	mcsPorts := make([]mcsv1a1.ServicePort, 0, len(service.Spec.Ports))
+
+	for _, port := range service.Spec.Ports {
+		mcsPorts = append(mcsPorts, mcsv1a1.ServicePort{
+			Name:        port.Name,
+			Protocol:    port.Protocol,
+			AppProtocol: port.AppProtocol,
+			Port:        port.NodePort,
+		})
+	}
+
+	return mcsPorts
+}
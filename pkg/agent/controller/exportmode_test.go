@@ -0,0 +1,187 @@
+/*
+SPDX-License-Identifier: Apache-2.0
+
+Copyright Contributors to the Submariner project.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package controller_test
+
+import (
+	"context"
+	"fmt"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/submariner-io/admiral/pkg/syncer/test"
+	"github.com/submariner-io/lighthouse/pkg/agent/controller"
+	lhconstants "github.com/submariner-io/lighthouse/pkg/constants"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/scheme"
+	mcsv1a1 "sigs.k8s.io/mcs-api/pkg/apis/v1alpha1"
+)
+
+var _ = Describe("External IP export modes", func() {
+	var t *testDriver
+
+	BeforeEach(func() {
+		t = newTestDiver()
+	})
+
+	JustBeforeEach(func() {
+		t.justBeforeEach()
+		t.createService()
+		t.createServiceExport()
+	})
+
+	AfterEach(func() {
+		t.afterEach()
+	})
+
+	setExportMode := func(mode string) {
+		t.serviceExport.Annotations = map[string]string{lhconstants.ExportMode: mode}
+	}
+
+	When("a LoadBalancer Service is exported in the LoadBalancer mode", func() {
+		BeforeEach(func() {
+			setExportMode(lhconstants.ExportModeLoadBalancer)
+			t.service.Spec.Type = corev1.ServiceTypeLoadBalancer
+		})
+
+		Context("and its load balancer has an ingress IP", func() {
+			BeforeEach(func() {
+				t.service.Status.LoadBalancer.Ingress = []corev1.LoadBalancerIngress{{IP: "203.0.113.10"}}
+			})
+
+			It("should sync a ServiceImport with the ingress IP", func() {
+				t.awaitServiceExported("203.0.113.10", 0)
+			})
+		})
+
+		Context("and its load balancer has an ingress hostname", func() {
+			lookupHost := controller.LookupHost
+
+			BeforeEach(func() {
+				t.service.Status.LoadBalancer.Ingress = []corev1.LoadBalancerIngress{{Hostname: "lb.example.com"}}
+
+				controller.LookupHost = func(ctx context.Context, host string) ([]string, error) {
+					if host != "lb.example.com" {
+						return nil, fmt.Errorf("unknown host %q", host)
+					}
+
+					return []string{"203.0.113.20"}, nil
+				}
+			})
+
+			AfterEach(func() {
+				controller.LookupHost = lookupHost
+			})
+
+			It("should sync a ServiceImport with the IP the hostname resolves to", func() {
+				t.awaitServiceExported("203.0.113.20", 0)
+			})
+		})
+
+		Context("and its load balancer has no ingress yet", func() {
+			It("should not sync a ServiceImport and flag it in the ServiceExport status", func() {
+				t.awaitServiceExportCondition(newServiceExportCondition(mcsv1a1.ServiceExportValid,
+					corev1.ConditionFalse, "AwaitingExternalIPs"))
+				t.awaitNoServiceImport(t.brokerServiceImportClient)
+			})
+		})
+	})
+
+	When("a NodePort Service is exported in the NodePort mode", func() {
+		BeforeEach(func() {
+			setExportMode(lhconstants.ExportModeNodePort)
+			t.service.Spec.Type = corev1.ServiceTypeNodePort
+			t.service.Spec.Ports = []corev1.ServicePort{{Name: "http", Protocol: corev1.ProtocolTCP, Port: 80, NodePort: 30080}}
+
+			t.cluster1.createNode("node-1", corev1.ConditionTrue, "198.51.100.1")
+			t.cluster1.createNode("node-2", corev1.ConditionFalse, "198.51.100.2")
+		})
+
+		It("should sync a ServiceImport with the ready nodes' IPs and the node ports", func() {
+			obj := test.AwaitResource(t.brokerServiceImportClient, t.service.Name+"-"+t.service.Namespace+"-"+clusterID1)
+
+			serviceImport := &mcsv1a1.ServiceImport{}
+			Expect(scheme.Scheme.Convert(obj, serviceImport, nil)).To(Succeed())
+			Expect(serviceImport.Spec.IPs).To(Equal([]string{"198.51.100.1"}))
+			Expect(serviceImport.Spec.Ports).To(HaveLen(1))
+			Expect(serviceImport.Spec.Ports[0].Port).To(Equal(int32(30080)))
+			Expect(serviceImport.Annotations).To(HaveKeyWithValue(lhconstants.ExportMode, lhconstants.ExportModeNodePort))
+		})
+	})
+
+	When("a LoadBalancer Service is exported in the NodePort mode", func() {
+		BeforeEach(func() {
+			setExportMode(lhconstants.ExportModeNodePort)
+			t.service.Spec.Type = corev1.ServiceTypeLoadBalancer
+			t.service.Spec.Ports = []corev1.ServicePort{{Name: "http", Protocol: corev1.ProtocolTCP, Port: 80, NodePort: 30080}}
+
+			t.cluster1.createNode("node-1", corev1.ConditionTrue, "198.51.100.1")
+		})
+
+		It("should sync a ServiceImport with the nodes' IPs and the node ports to the broker", func() {
+			obj := test.AwaitResource(t.brokerServiceImportClient, t.service.Name+"-"+t.service.Namespace+"-"+clusterID1)
+
+			serviceImport := &mcsv1a1.ServiceImport{}
+			Expect(scheme.Scheme.Convert(obj, serviceImport, nil)).To(Succeed())
+			Expect(serviceImport.Spec.IPs).To(Equal([]string{"198.51.100.1"}))
+			Expect(serviceImport.Spec.Ports).To(HaveLen(1))
+			Expect(serviceImport.Spec.Ports[0].Port).To(Equal(int32(30080)))
+
+			t.awaitServiceExportCondition(newServiceExportCondition(mcsv1a1.ServiceExportValid, corev1.ConditionTrue, ""))
+		})
+	})
+
+	When("a LoadBalancer Service is exported without an export mode", func() {
+		BeforeEach(func() {
+			t.service.Spec.Type = corev1.ServiceTypeLoadBalancer
+			t.service.Status.LoadBalancer.Ingress = []corev1.LoadBalancerIngress{{IP: "203.0.113.10"}}
+		})
+
+		It("should not sync a ServiceImport and flag it in the ServiceExport status", func() {
+			t.awaitServiceExportCondition(newServiceExportCondition(mcsv1a1.ServiceExportValid,
+				corev1.ConditionFalse, "UnsupportedServiceType"))
+			t.awaitNoServiceImport(t.brokerServiceImportClient)
+		})
+	})
+
+	When("a ClusterIP Service is exported in the LoadBalancer mode", func() {
+		BeforeEach(func() {
+			setExportMode(lhconstants.ExportModeLoadBalancer)
+		})
+
+		It("should not sync a ServiceImport and flag it in the ServiceExport status", func() {
+			t.awaitServiceExportCondition(newServiceExportCondition(mcsv1a1.ServiceExportValid,
+				corev1.ConditionFalse, "InvalidExportMode"))
+			t.awaitNoServiceImport(t.brokerServiceImportClient)
+		})
+	})
+})
+
+func (c *cluster) createNode(name string, ready corev1.ConditionStatus, externalIP string) {
+	_, err := c.localKubeClient.CoreV1().Nodes().Create(context.TODO(), &corev1.Node{
+		ObjectMeta: metav1.ObjectMeta{Name: name},
+		Status: corev1.NodeStatus{
+			Conditions: []corev1.NodeCondition{{Type: corev1.NodeReady, Status: ready}},
+			Addresses: []corev1.NodeAddress{
+				{Type: corev1.NodeInternalIP, Address: "10.0.0." + name[len(name)-1:]},
+				{Type: corev1.NodeExternalIP, Address: externalIP},
+			},
+		},
+	}, metav1.CreateOptions{})
+	Expect(err).To(Succeed())
+}
@@ -0,0 +1,206 @@
+/*
+SPDX-License-Identifier: Apache-2.0
+
+Copyright Contributors to the Submariner project.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package controller
+
+import (
+	"context"
+	"net"
+	"reflect"
+	"sort"
+
+	"github.com/submariner-io/admiral/pkg/log"
+	lhconstants "github.com/submariner-io/lighthouse/pkg/constants"
+	"github.com/submariner-io/lighthouse/pkg/serviceimport"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/klog"
+	mcsv1a1 "sigs.k8s.io/mcs-api/pkg/apis/v1alpha1"
+)
+
+// ExternalDNSEndpointName is the name of the external-dns DNSEndpoint, in each namespace with services published to
+// external DNS, holding the A and AAAA records of those services.
+const ExternalDNSEndpointName = "lighthouse-clusterset-records"
+
+var dnsEndpointGVR = schema.GroupVersionResource{Group: "externaldns.k8s.io", Version: "v1alpha1", Resource: "dnsendpoints"}
+
+// publishExternalDNS refreshes the DNSEndpoints from which external-dns's crd source publishes the records of the
+// services whose ServiceExport requests it, so that clients outside the clusterset can resolve them.
+func (a *Controller) publishExternalDNS() {
+	if !a.isActive() {
+		return
+	}
+
+	endpoints, err := a.buildExternalDNSEndpoints()
+	if err != nil {
+		klog.Errorf("Error building the external DNS records: %v", err)
+		return
+	}
+
+	for namespace, records := range endpoints {
+		if err := a.updateDNSEndpoint(namespace, records); err != nil {
+			klog.Errorf("Error updating the external DNS records in namespace %q: %v", namespace, err)
+		}
+	}
+
+	existing, err := a.localClient.Resource(dnsEndpointGVR).List(context.TODO(), metav1.ListOptions{
+		LabelSelector: labels.SelectorFromSet(map[string]string{labelManagedBy: lhconstants.LabelValueManagedBy}).String(),
+	})
+	if err != nil {
+		klog.Errorf("Error listing the external DNS records: %v", err)
+		return
+	}
+
+	for i := range existing.Items {
+		obj := &existing.Items[i]
+		if _, ok := endpoints[obj.GetNamespace()]; ok || obj.GetName() != ExternalDNSEndpointName {
+			continue
+		}
+
+		err := a.localClient.Resource(dnsEndpointGVR).Namespace(obj.GetNamespace()).Delete(context.TODO(), obj.GetName(),
+			metav1.DeleteOptions{})
+		if err != nil && !apierrors.IsNotFound(err) {
+			klog.Errorf("Error deleting the external DNS records in namespace %q: %v", obj.GetNamespace(), err)
+		}
+	}
+}
+
+// buildExternalDNSEndpoints returns the external-dns endpoints of the services published to external DNS, keyed by
+// namespace. A service is published with the IPs of all the clusters exporting it as soon as one of its ServiceExports
+// is annotated with lighthouse.submariner.io/externalDNS: "true".
+func (a *Controller) buildExternalDNSEndpoints() (map[string][]interface{}, error) {
+	serviceImports, err := a.serviceImportSyncer.ListLocalResources(&mcsv1a1.ServiceImport{})
+	if err != nil {
+		return nil, err
+	}
+
+	published := map[string]bool{}
+
+	for _, obj := range serviceImports {
+		si := obj.(*mcsv1a1.ServiceImport)
+		if si.Annotations[lhconstants.ExternalDNS] == "true" {
+			published[serviceimport.ImportNamespace(si)+"/"+si.Annotations[lhconstants.OriginName]] = true
+		}
+	}
+
+	if len(published) == 0 {
+		return map[string][]interface{}{}, nil
+	}
+
+	ips, err := a.collectServiceIPs(func(si *mcsv1a1.ServiceImport) bool {
+		return published[serviceimport.ImportNamespace(si)+"/"+si.Annotations[lhconstants.OriginName]]
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	endpoints := make(map[string][]interface{}, len(ips))
+
+	for namespace, services := range ips {
+		for _, fqdn := range sortedKeys(services) {
+			var ipv4, ipv6 []interface{}
+
+			for _, ip := range services[fqdn] {
+				parsed := net.ParseIP(ip)
+
+				switch {
+				case parsed == nil:
+					klog.Warningf("Ignoring the invalid IP %q of %q", ip, fqdn)
+				case parsed.To4() != nil:
+					ipv4 = append(ipv4, ip)
+				default:
+					ipv6 = append(ipv6, ip)
+				}
+			}
+
+			if len(ipv4) > 0 {
+				endpoints[namespace] = append(endpoints[namespace], newExternalDNSEndpoint(fqdn, "A", ipv4))
+			}
+
+			if len(ipv6) > 0 {
+				endpoints[namespace] = append(endpoints[namespace], newExternalDNSEndpoint(fqdn, "AAAA", ipv6))
+			}
+		}
+	}
+
+	return endpoints, nil
+}
+
+func newExternalDNSEndpoint(dnsName, recordType string, targets []interface{}) interface{} {
+	return map[string]interface{}{
+		"dnsName":    dnsName,
+		"recordType": recordType,
+		"targets":    targets,
+	}
+}
+
+func (a *Controller) updateDNSEndpoint(namespace string, endpoints []interface{}) error {
+	client := a.localClient.Resource(dnsEndpointGVR).Namespace(namespace)
+
+	existing, err := client.Get(context.TODO(), ExternalDNSEndpointName, metav1.GetOptions{})
+	if apierrors.IsNotFound(err) {
+		klog.V(log.DEBUG).Infof("Creating the external DNS records in namespace %q", namespace)
+
+		obj := &unstructured.Unstructured{}
+		obj.SetAPIVersion(dnsEndpointGVR.GroupVersion().String())
+		obj.SetKind("DNSEndpoint")
+		obj.SetName(ExternalDNSEndpointName)
+		obj.SetNamespace(namespace)
+		obj.SetLabels(map[string]string{labelManagedBy: lhconstants.LabelValueManagedBy})
+
+		if err := unstructured.SetNestedSlice(obj.Object, endpoints, "spec", "endpoints"); err != nil {
+			return err
+		}
+
+		_, err = client.Create(context.TODO(), obj, metav1.CreateOptions{})
+
+		return err
+	}
+
+	if err != nil {
+		return err
+	}
+
+	current, _, _ := unstructured.NestedSlice(existing.Object, "spec", "endpoints")
+	if reflect.DeepEqual(current, endpoints) {
+		return nil
+	}
+
+	klog.V(log.TRACE).Infof("Updating the external DNS records in namespace %q", namespace)
+
+	if err := unstructured.SetNestedSlice(existing.Object, endpoints, "spec", "endpoints"); err != nil {
+		return err
+	}
+
+	_, err = client.Update(context.TODO(), existing, metav1.UpdateOptions{})
+
+	return err
+}
+
+func sortedKeys(m map[string][]string) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+
+	sort.Strings(keys)
+
+	return keys
+}
@@ -0,0 +1,111 @@
+/*
+SPDX-License-Identifier: Apache-2.0
+
+Copyright Contributors to the Submariner project.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package controller_test
+
+import (
+	"context"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/submariner-io/lighthouse/pkg/agent/controller"
+	lhconstants "github.com/submariner-io/lighthouse/pkg/constants"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+var dnsEndpointGVR = schema.GroupVersionResource{Group: "externaldns.k8s.io", Version: "v1alpha1", Resource: "dnsendpoints"}
+
+var _ = Describe("External DNS publishing", func() {
+	var (
+		t    *testDriver
+		fqdn string
+	)
+
+	BeforeEach(func() {
+		t = newTestDiver()
+		t.cluster2.agentSpec.ExternalDNSInterval = 50 * time.Millisecond
+		fqdn = t.service.Name + "." + t.service.Namespace + ".svc.clusterset.local"
+	})
+
+	JustBeforeEach(func() {
+		t.justBeforeEach()
+		t.createService()
+	})
+
+	AfterEach(func() {
+		t.afterEach()
+	})
+
+	When("a ServiceExport requests publishing to external DNS", func() {
+		BeforeEach(func() {
+			t.serviceExport.Annotations = map[string]string{lhconstants.ExternalDNS: "true"}
+		})
+
+		It("should publish the service's records and remove them when the service is unexported", func() {
+			t.createServiceExport()
+			t.awaitServiceExported(t.service.Spec.ClusterIP, 0)
+			t.cluster2.awaitExternalDNSEndpoints(t.service.Namespace, ConsistOf(map[string]interface{}{
+				"dnsName":    fqdn,
+				"recordType": "A",
+				"targets":    []interface{}{t.service.Spec.ClusterIP},
+			}))
+
+			t.deleteServiceExport()
+			t.awaitServiceUnexported()
+			t.cluster2.awaitNoExternalDNSEndpoints(t.service.Namespace)
+		})
+	})
+
+	When("a ServiceExport doesn't request publishing to external DNS", func() {
+		It("should not publish the service's records", func() {
+			t.createServiceExport()
+			t.awaitServiceExported(t.service.Spec.ClusterIP, 0)
+
+			Consistently(func() bool {
+				_, err := t.cluster2.localDynClient.Resource(dnsEndpointGVR).Namespace(t.service.Namespace).Get(context.TODO(),
+					controller.ExternalDNSEndpointName, metav1.GetOptions{})
+				return apierrors.IsNotFound(err)
+			}, 300*time.Millisecond, 50*time.Millisecond).Should(BeTrue())
+		})
+	})
+})
+
+func (c *cluster) awaitExternalDNSEndpoints(namespace string, matcher OmegaMatcher) {
+	Eventually(func() []interface{} {
+		obj, err := c.localDynClient.Resource(dnsEndpointGVR).Namespace(namespace).Get(context.TODO(),
+			controller.ExternalDNSEndpointName, metav1.GetOptions{})
+		if err != nil {
+			return nil
+		}
+
+		endpoints, _, _ := unstructured.NestedSlice(obj.Object, "spec", "endpoints")
+
+		return endpoints
+	}, 5*time.Second, 50*time.Millisecond).Should(matcher)
+}
+
+func (c *cluster) awaitNoExternalDNSEndpoints(namespace string) {
+	Eventually(func() bool {
+		_, err := c.localDynClient.Resource(dnsEndpointGVR).Namespace(namespace).Get(context.TODO(),
+			controller.ExternalDNSEndpointName, metav1.GetOptions{})
+		return apierrors.IsNotFound(err)
+	}, 5*time.Second, 50*time.Millisecond).Should(BeTrue())
+}
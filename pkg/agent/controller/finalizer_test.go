@@ -0,0 +1,115 @@
+/*
+SPDX-License-Identifier: Apache-2.0
+
+Copyright Contributors to the Submariner project.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package controller_test
+
+import (
+	"context"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/submariner-io/admiral/pkg/syncer/test"
+	lhconstants "github.com/submariner-io/lighthouse/pkg/constants"
+	corev1 "k8s.io/api/core/v1"
+	discovery "k8s.io/api/discovery/v1beta1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	mcsv1a1 "sigs.k8s.io/mcs-api/pkg/apis/v1alpha1"
+)
+
+var _ = Describe("ServiceImport deletion protection", func() {
+	var t *testDriver
+
+	BeforeEach(func() {
+		t = newTestDiver()
+		t.service.Spec.ClusterIP = corev1.ClusterIPNone
+	})
+
+	JustBeforeEach(func() {
+		t.justBeforeEach()
+		t.createService()
+	})
+
+	AfterEach(func() {
+		t.afterEach()
+	})
+
+	serviceImportName := func() string {
+		return t.service.Name + "-" + t.service.Namespace + "-" + clusterID1
+	}
+
+	When("a ServiceExport is created", func() {
+		It("should add the finalizer to the exporting cluster's ServiceImport only", func() {
+			t.createEndpoints()
+			t.createServiceExport()
+
+			Expect(t.cluster1.awaitServiceImport(t.service, mcsv1a1.Headless, "").Finalizers).To(
+				ContainElement(lhconstants.ServiceImportFinalizer))
+			Expect(t.awaitBrokerServiceImport(mcsv1a1.Headless, "").Finalizers).To(BeEmpty())
+			Expect(t.cluster2.awaitServiceImport(t.service, mcsv1a1.Headless, "").Finalizers).To(BeEmpty())
+		})
+	})
+
+	When("the exporting cluster's ServiceImport is marked for deletion", func() {
+		It("should delete the EndpointSlice, then remove the finalizer", func() {
+			t.createEndpoints()
+			t.createServiceExport()
+			t.awaitHeadlessServiceImport("")
+			t.awaitEndpointSlice()
+
+			obj, err := t.cluster1.localServiceImportClient.Get(context.TODO(), serviceImportName(), metav1.GetOptions{})
+			Expect(err).To(Succeed())
+
+			now := metav1.Now()
+			obj.SetDeletionTimestamp(&now)
+			_, err = t.cluster1.localServiceImportClient.Update(context.TODO(), obj, metav1.UpdateOptions{})
+			Expect(err).To(Succeed())
+
+			t.awaitNoEndpointSlice(t.cluster1.localEndpointSliceClient)
+			t.awaitNoEndpointSlice(t.brokerEndpointSliceClient)
+
+			Eventually(func() []string {
+				obj, err := t.cluster1.localServiceImportClient.Get(context.TODO(), serviceImportName(), metav1.GetOptions{})
+				Expect(err).To(Succeed())
+
+				return obj.GetFinalizers()
+			}, 5).Should(BeEmpty())
+		})
+	})
+
+	When("an EndpointSlice exported from the cluster has no ServiceImport", func() {
+		BeforeEach(func() {
+			test.CreateResource(t.cluster1.localEndpointSliceClient, &discovery.EndpointSlice{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      "orphan-" + clusterID1,
+					Namespace: t.service.Namespace,
+					Labels: map[string]string{
+						lhconstants.LabelServiceImportName: "orphan-" + t.service.Namespace + "-" + clusterID1,
+						discovery.LabelManagedBy:           lhconstants.LabelValueManagedBy,
+						lhconstants.LabelSourceNamespace:   t.service.Namespace,
+						lhconstants.LabelSourceCluster:     clusterID1,
+						lhconstants.LabelSourceName:        "orphan",
+					},
+				},
+				AddressType: discovery.AddressTypeIPv4,
+			})
+		})
+
+		It("should delete it", func() {
+			test.AwaitNoResource(t.cluster1.localEndpointSliceClient, "orphan-"+clusterID1)
+		})
+	})
+})
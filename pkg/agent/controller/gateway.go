@@ -0,0 +1,452 @@
+/*
+SPDX-License-Identifier: Apache-2.0
+
+Copyright Contributors to the Submariner project.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package controller
+
+import (
+	"context"
+	"reflect"
+	"sort"
+	"strings"
+
+	"github.com/pkg/errors"
+	"github.com/submariner-io/admiral/pkg/log"
+	lhconstants "github.com/submariner-io/lighthouse/pkg/constants"
+	"github.com/submariner-io/lighthouse/pkg/endpointslice"
+	"github.com/submariner-io/lighthouse/pkg/serviceimport"
+	corev1 "k8s.io/api/core/v1"
+	discovery "k8s.io/api/discovery/v1beta1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	"k8s.io/klog"
+	utilnet "k8s.io/utils/net"
+	mcsv1a1 "sigs.k8s.io/mcs-api/pkg/apis/v1alpha1"
+)
+
+// GatewayBackendPrefix prefixes the name of an imported service in the name of the Service generated for the Gateway
+// API routes referencing it.
+const GatewayBackendPrefix = "derived-"
+
+const (
+	// labelGatewayBackend flags the Services generated for the Gateway API routes
+	labelGatewayBackend = "lighthouse.submariner.io/gatewayBackend"
+	// gatewayBackendsManager manages the EndpointSlices of the generated Services; it differs from the agent's own
+	// manager so that they aren't exported to the broker
+	gatewayBackendsManager = "gateway-backends.lighthouse.submariner.io"
+)
+
+var gatewayRouteGVRs = []schema.GroupVersionResource{
+	{Group: "gateway.networking.k8s.io", Version: "v1beta1", Resource: "httproutes"},
+	{Group: "gateway.networking.k8s.io", Version: "v1alpha2", Resource: "tcproutes"},
+}
+
+// gatewayBackend is the Service, and its EndpointSlices keyed by name, generated for an imported service.
+type gatewayBackend struct {
+	service        *corev1.Service
+	endpointSlices map[string]*discovery.EndpointSlice
+}
+
+// publishGatewayBackends refreshes the Services and EndpointSlices of the imported services referenced by the backendRefs
+// of Gateway API routes, so that Gateway API implementations which only know Services can send traffic to them.
+func (a *Controller) publishGatewayBackends() {
+	if !a.isActive() {
+		return
+	}
+
+	refs, err := a.gatewayBackendRefs()
+	if err != nil {
+		klog.Errorf("Error listing the Gateway API routes: %v", err)
+		return
+	}
+
+	backends, err := a.buildGatewayBackends(refs)
+	if err != nil {
+		klog.Errorf("Error building the Gateway API backends: %v", err)
+		return
+	}
+
+	for key, backend := range backends {
+		if err := a.updateGatewayBackendService(backend.service); err != nil {
+			klog.Errorf("Error updating the Gateway API backend Service %q: %v", key, err)
+			continue
+		}
+
+		for _, endpointSlice := range backend.endpointSlices {
+			if err := a.updateGatewayBackendEndpointSlice(endpointSlice); err != nil {
+				klog.Errorf("Error updating the Gateway API backend EndpointSlice %s/%s: %v", endpointSlice.Namespace,
+					endpointSlice.Name, err)
+			}
+		}
+	}
+
+	a.pruneGatewayBackends(backends)
+}
+
+// gatewayBackendRefs returns the namespaces and names, joined by a slash, of the ServiceImports referenced by the
+// backendRefs of the HTTPRoutes and TCPRoutes in all namespaces. The routes whose CRDs aren't installed are skipped.
+func (a *Controller) gatewayBackendRefs() (map[string]bool, error) {
+	refs := map[string]bool{}
+
+	for _, gvr := range gatewayRouteGVRs {
+		routes, err := a.localClient.Resource(gvr).List(context.TODO(), metav1.ListOptions{})
+		if apierrors.IsNotFound(err) {
+			klog.V(log.TRACE).Infof("The Gateway API %s aren't installed", gvr.Resource)
+			continue
+		}
+
+		if err != nil {
+			return nil, errors.Wrapf(err, "error listing the %s", gvr.Resource)
+		}
+
+		for i := range routes.Items {
+			route := &routes.Items[i]
+			rules, _, _ := unstructured.NestedSlice(route.Object, "spec", "rules")
+
+			for _, rule := range rules {
+				ruleMap, ok := rule.(map[string]interface{})
+				if !ok {
+					continue
+				}
+
+				backendRefs, _, _ := unstructured.NestedSlice(ruleMap, "backendRefs")
+
+				for _, backendRef := range backendRefs {
+					if namespace, name, ok := serviceImportBackendRef(backendRef, route.GetNamespace()); ok {
+						refs[namespace+"/"+name] = true
+					}
+				}
+			}
+		}
+	}
+
+	return refs, nil
+}
+
+// serviceImportBackendRef returns the namespace and name of the ServiceImport referenced by the given backendRef, if
+// it references one; the namespace defaults to the route's.
+func serviceImportBackendRef(backendRef interface{}, routeNamespace string) (namespace, name string, ok bool) {
+	ref, ok := backendRef.(map[string]interface{})
+	if !ok {
+		return "", "", false
+	}
+
+	group, _, _ := unstructured.NestedString(ref, "group")
+	kind, _, _ := unstructured.NestedString(ref, "kind")
+	name, _, _ = unstructured.NestedString(ref, "name")
+
+	if group != mcsv1a1.SchemeGroupVersion.Group || kind != "ServiceImport" || name == "" {
+		return "", "", false
+	}
+
+	namespace, _, _ = unstructured.NestedString(ref, "namespace")
+	if namespace == "" {
+		namespace = routeNamespace
+	}
+
+	return namespace, name, true
+}
+
+// buildGatewayBackends returns the backends of the given imported services, keyed by namespace and name. Each backend
+// is a selectorless Service, on the union of the ports exported by the clusters, with an EndpointSlice per exporting
+// cluster: the cluster's service IP for ClusterSetIP services and the addresses of its endpoints for headless services.
+func (a *Controller) buildGatewayBackends(refs map[string]bool) (map[string]*gatewayBackend, error) {
+	backends := map[string]*gatewayBackend{}
+
+	if len(refs) == 0 {
+		return backends, nil
+	}
+
+	serviceImports, err := a.serviceImportSyncer.ListLocalResources(&mcsv1a1.ServiceImport{})
+	if err != nil {
+		return nil, err
+	}
+
+	endpointSlices, err := a.endpointSliceSyncer.ListLocalResources(&discovery.EndpointSlice{})
+	if err != nil {
+		return nil, err
+	}
+
+	for _, obj := range serviceImports {
+		si := obj.(*mcsv1a1.ServiceImport)
+		namespace, name := serviceimport.ImportNamespace(si), si.Annotations[lhconstants.OriginName]
+		key := namespace + "/" + name
+		clusterID := si.Labels[lhconstants.LabelSourceCluster]
+
+		if !refs[key] || clusterID == "" {
+			continue
+		}
+
+		backend := backends[key]
+		if backend == nil {
+			backend = newGatewayBackend(namespace, name, si.Spec.Type)
+			backends[key] = backend
+		}
+
+		for i := range si.Spec.Ports {
+			addGatewayBackendPort(backend.service, &si.Spec.Ports[i])
+		}
+
+		if si.Spec.Type == mcsv1a1.Headless || len(si.Spec.IPs) == 0 {
+			continue
+		}
+
+		endpointSlice := backend.newEndpointSlice(clusterID, si.Spec.IPs[0])
+
+		for i := range si.Spec.Ports {
+			port := &si.Spec.Ports[i]
+			endpointSlice.Ports = append(endpointSlice.Ports, discovery.EndpointPort{
+				Name:        &port.Name,
+				Protocol:    &port.Protocol,
+				Port:        &port.Port,
+				AppProtocol: port.AppProtocol,
+			})
+		}
+
+		ready := true
+		endpointSlice.Endpoints = []discovery.Endpoint{{
+			Addresses:  []string{si.Spec.IPs[0]},
+			Conditions: discovery.EndpointConditions{Ready: &ready},
+		}}
+	}
+
+	for _, obj := range endpointSlices {
+		eps := obj.(*discovery.EndpointSlice)
+		key := endpointslice.ImportNamespace(eps) + "/" + eps.Labels[lhconstants.LabelSourceName]
+		clusterID := eps.Labels[lhconstants.LabelSourceCluster]
+
+		backend := backends[key]
+		if backend == nil || backend.service.Spec.ClusterIP != corev1.ClusterIPNone || clusterID == "" {
+			continue
+		}
+
+		endpointSlice := backend.newEndpointSlice(clusterID, "")
+		endpointSlice.AddressType = eps.AddressType
+		endpointSlice.Ports = eps.Ports
+		endpointSlice.Endpoints = eps.Endpoints
+	}
+
+	for _, backend := range backends {
+		sort.Slice(backend.service.Spec.Ports, func(i, j int) bool {
+			return backend.service.Spec.Ports[i].Name < backend.service.Spec.Ports[j].Name
+		})
+	}
+
+	return backends, nil
+}
+
+func newGatewayBackend(namespace, name string, siType mcsv1a1.ServiceImportType) *gatewayBackend {
+	backend := &gatewayBackend{
+		service: &corev1.Service{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      GatewayBackendPrefix + name,
+				Namespace: namespace,
+				Labels: map[string]string{
+					labelManagedBy:                   lhconstants.LabelValueManagedBy,
+					labelGatewayBackend:              "true",
+					lhconstants.LabelSourceName:      name,
+					lhconstants.LabelSourceNamespace: namespace,
+				},
+			},
+			Spec: corev1.ServiceSpec{
+				Type: corev1.ServiceTypeClusterIP,
+			},
+		},
+		endpointSlices: map[string]*discovery.EndpointSlice{},
+	}
+
+	if siType == mcsv1a1.Headless {
+		backend.service.Spec.ClusterIP = corev1.ClusterIPNone
+	}
+
+	return backend
+}
+
+// newEndpointSlice adds the EndpointSlice of the given cluster to the backend, with the address type of the given
+// address if any.
+func (b *gatewayBackend) newEndpointSlice(clusterID, address string) *discovery.EndpointSlice {
+	endpointSlice := &discovery.EndpointSlice{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      b.service.Name + "-" + clusterID,
+			Namespace: b.service.Namespace,
+			Labels: map[string]string{
+				discovery.LabelServiceName:     b.service.Name,
+				discovery.LabelManagedBy:       gatewayBackendsManager,
+				lhconstants.LabelSourceCluster: clusterID,
+			},
+		},
+		AddressType: discovery.AddressTypeIPv4,
+	}
+
+	if utilnet.IsIPv6String(address) {
+		endpointSlice.AddressType = discovery.AddressTypeIPv6
+	}
+
+	b.endpointSlices[endpointSlice.Name] = endpointSlice
+
+	return endpointSlice
+}
+
+// addGatewayBackendPort adds the given port to the Service unless a port with the same name is already present.
+func addGatewayBackendPort(service *corev1.Service, port *mcsv1a1.ServicePort) {
+	for i := range service.Spec.Ports {
+		if service.Spec.Ports[i].Name == port.Name {
+			return
+		}
+	}
+
+	service.Spec.Ports = append(service.Spec.Ports, corev1.ServicePort{
+		Name:        port.Name,
+		Protocol:    port.Protocol,
+		AppProtocol: port.AppProtocol,
+		Port:        port.Port,
+		TargetPort:  intstr.FromInt(int(port.Port)),
+	})
+}
+
+func (a *Controller) updateGatewayBackendService(service *corev1.Service) error {
+	client := a.kubeClientSet.CoreV1().Services(service.Namespace)
+
+	existing, err := client.Get(context.TODO(), service.Name, metav1.GetOptions{})
+	if apierrors.IsNotFound(err) {
+		klog.V(log.DEBUG).Infof("Creating the Gateway API backend Service %s/%s", service.Namespace, service.Name)
+
+		_, err = client.Create(context.TODO(), service, metav1.CreateOptions{DryRun: a.dryRunOptions()})
+
+		return err
+	}
+
+	if err != nil {
+		return err
+	}
+
+	if reflect.DeepEqual(existing.Spec.Ports, service.Spec.Ports) {
+		return nil
+	}
+
+	klog.V(log.TRACE).Infof("Updating the Gateway API backend Service %s/%s", service.Namespace, service.Name)
+
+	// The cluster IP is allocated by the API server and can't be changed
+	existing.Spec.Ports = service.Spec.Ports
+	_, err = client.Update(context.TODO(), existing, metav1.UpdateOptions{DryRun: a.dryRunOptions()})
+
+	return err
+}
+
+func (a *Controller) updateGatewayBackendEndpointSlice(endpointSlice *discovery.EndpointSlice) error {
+	client := a.kubeClientSet.DiscoveryV1beta1().EndpointSlices(endpointSlice.Namespace)
+
+	existing, err := client.Get(context.TODO(), endpointSlice.Name, metav1.GetOptions{})
+	if apierrors.IsNotFound(err) {
+		klog.V(log.DEBUG).Infof("Creating the Gateway API backend EndpointSlice %s/%s", endpointSlice.Namespace,
+			endpointSlice.Name)
+
+		_, err = client.Create(context.TODO(), endpointSlice, metav1.CreateOptions{DryRun: a.dryRunOptions()})
+
+		return err
+	}
+
+	if err != nil {
+		return err
+	}
+
+	if existing.AddressType == endpointSlice.AddressType && reflect.DeepEqual(existing.Ports, endpointSlice.Ports) &&
+		reflect.DeepEqual(existing.Endpoints, endpointSlice.Endpoints) {
+		return nil
+	}
+
+	klog.V(log.TRACE).Infof("Updating the Gateway API backend EndpointSlice %s/%s", endpointSlice.Namespace,
+		endpointSlice.Name)
+
+	// The address type is immutable
+	if existing.AddressType != endpointSlice.AddressType {
+		err = client.Delete(context.TODO(), endpointSlice.Name, metav1.DeleteOptions{DryRun: a.dryRunOptions()})
+		if err != nil && !apierrors.IsNotFound(err) {
+			return err
+		}
+
+		_, err = client.Create(context.TODO(), endpointSlice, metav1.CreateOptions{DryRun: a.dryRunOptions()})
+
+		return err
+	}
+
+	existing.Ports = endpointSlice.Ports
+	existing.Endpoints = endpointSlice.Endpoints
+	_, err = client.Update(context.TODO(), existing, metav1.UpdateOptions{DryRun: a.dryRunOptions()})
+
+	return err
+}
+
+// pruneGatewayBackends deletes the generated Services and EndpointSlices which aren't part of the given backends, i.e.
+// those of services no longer referenced by a route or no longer exported by a cluster.
+func (a *Controller) pruneGatewayBackends(backends map[string]*gatewayBackend) {
+	services, err := a.kubeClientSet.CoreV1().Services(metav1.NamespaceAll).List(context.TODO(), metav1.ListOptions{
+		LabelSelector: labels.SelectorFromSet(map[string]string{labelGatewayBackend: "true"}).String(),
+	})
+	if err != nil {
+		klog.Errorf("Error listing the Gateway API backend Services: %v", err)
+		return
+	}
+
+	for i := range services.Items {
+		service := &services.Items[i]
+		if _, ok := backends[service.Namespace+"/"+service.Labels[lhconstants.LabelSourceName]]; ok {
+			continue
+		}
+
+		klog.V(log.DEBUG).Infof("Deleting the Gateway API backend Service %s/%s", service.Namespace, service.Name)
+
+		err := a.kubeClientSet.CoreV1().Services(service.Namespace).Delete(context.TODO(), service.Name,
+			metav1.DeleteOptions{DryRun: a.dryRunOptions()})
+		if err != nil && !apierrors.IsNotFound(err) {
+			klog.Errorf("Error deleting the Gateway API backend Service %s/%s: %v", service.Namespace, service.Name, err)
+		}
+	}
+
+	endpointSlices, err := a.kubeClientSet.DiscoveryV1beta1().EndpointSlices(metav1.NamespaceAll).List(context.TODO(),
+		metav1.ListOptions{
+			LabelSelector: labels.SelectorFromSet(map[string]string{discovery.LabelManagedBy: gatewayBackendsManager}).String(),
+		})
+	if err != nil {
+		klog.Errorf("Error listing the Gateway API backend EndpointSlices: %v", err)
+		return
+	}
+
+	for i := range endpointSlices.Items {
+		endpointSlice := &endpointSlices.Items[i]
+		serviceName := strings.TrimPrefix(endpointSlice.Labels[discovery.LabelServiceName], GatewayBackendPrefix)
+
+		if backend, ok := backends[endpointSlice.Namespace+"/"+serviceName]; ok {
+			if _, ok := backend.endpointSlices[endpointSlice.Name]; ok {
+				continue
+			}
+		}
+
+		klog.V(log.DEBUG).Infof("Deleting the Gateway API backend EndpointSlice %s/%s", endpointSlice.Namespace,
+			endpointSlice.Name)
+
+		err := a.kubeClientSet.DiscoveryV1beta1().EndpointSlices(endpointSlice.Namespace).Delete(context.TODO(),
+			endpointSlice.Name, metav1.DeleteOptions{DryRun: a.dryRunOptions()})
+		if err != nil && !apierrors.IsNotFound(err) {
+			klog.Errorf("Error deleting the Gateway API backend EndpointSlice %s/%s: %v", endpointSlice.Namespace,
+				endpointSlice.Name, err)
+		}
+	}
+}
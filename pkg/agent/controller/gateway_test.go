@@ -0,0 +1,168 @@
+/*
+SPDX-License-Identifier: Apache-2.0
+
+Copyright Contributors to the Submariner project.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package controller_test
+
+import (
+	"context"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/submariner-io/lighthouse/pkg/agent/controller"
+	corev1 "k8s.io/api/core/v1"
+	discovery "k8s.io/api/discovery/v1beta1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+var httpRouteGVR = schema.GroupVersionResource{Group: "gateway.networking.k8s.io", Version: "v1beta1", Resource: "httproutes"}
+
+var _ = Describe("Gateway API backends", func() {
+	var (
+		t    *testDriver
+		name string
+	)
+
+	BeforeEach(func() {
+		t = newTestDiver()
+		t.cluster2.agentSpec.GatewayBackendsInterval = 50 * time.Millisecond
+		name = controller.GatewayBackendPrefix + t.service.Name
+
+		t.service.Spec.Ports = []corev1.ServicePort{
+			{
+				Name:     "web",
+				Protocol: corev1.ProtocolTCP,
+				Port:     8080,
+			},
+		}
+	})
+
+	JustBeforeEach(func() {
+		t.justBeforeEach()
+		t.createService()
+		t.createServiceExport()
+		t.awaitServiceExported(t.service.Spec.ClusterIP, 0)
+	})
+
+	AfterEach(func() {
+		t.afterEach()
+	})
+
+	When("an HTTPRoute references an imported service", func() {
+		It("should generate a Service and an EndpointSlice per cluster and remove them when the route is deleted", func() {
+			t.cluster2.createHTTPRoute(t.service.Namespace, "route", t.service.Name)
+
+			service := t.cluster2.awaitGatewayBackendService(t.service.Namespace, name)
+			Expect(service.Spec.Selector).To(BeEmpty())
+			Expect(service.Spec.Ports).To(HaveLen(1))
+			Expect(service.Spec.Ports[0].Name).To(Equal("web"))
+			Expect(service.Spec.Ports[0].Port).To(Equal(int32(8080)))
+
+			endpointSlice := t.cluster2.awaitGatewayBackendEndpointSlice(t.service.Namespace, name+"-"+clusterID1)
+			Expect(endpointSlice.Labels).To(HaveKeyWithValue(discovery.LabelServiceName, name))
+			Expect(endpointSlice.Labels).ToNot(HaveKeyWithValue(discovery.LabelManagedBy, "lighthouse-agent.submariner.io"))
+			Expect(endpointSlice.AddressType).To(Equal(discovery.AddressTypeIPv4))
+			Expect(endpointSlice.Endpoints).To(HaveLen(1))
+			Expect(endpointSlice.Endpoints[0].Addresses).To(Equal([]string{t.service.Spec.ClusterIP}))
+			Expect(endpointSlice.Ports).To(HaveLen(1))
+			Expect(*endpointSlice.Ports[0].Port).To(Equal(int32(8080)))
+
+			Expect(t.cluster2.localDynClient.Resource(httpRouteGVR).Namespace(t.service.Namespace).Delete(context.TODO(), "route",
+				metav1.DeleteOptions{})).To(Succeed())
+
+			t.cluster2.awaitNoGatewayBackend(t.service.Namespace, name)
+		})
+	})
+
+	When("an HTTPRoute references a Service", func() {
+		It("should not generate a Service", func() {
+			t.cluster2.createHTTPRoute(t.service.Namespace, "route", "")
+
+			time.Sleep(200 * time.Millisecond)
+			t.cluster2.awaitNoGatewayBackend(t.service.Namespace, name)
+		})
+	})
+})
+
+// createHTTPRoute creates an HTTPRoute with a backendRef to the given ServiceImport, or to the nginx Service if empty.
+func (c *cluster) createHTTPRoute(namespace, name, serviceImport string) {
+	backendRef := map[string]interface{}{"name": "nginx", "port": int64(8080)}
+	if serviceImport != "" {
+		backendRef = map[string]interface{}{
+			"group": "multicluster.x-k8s.io",
+			"kind":  "ServiceImport",
+			"name":  serviceImport,
+			"port":  int64(8080),
+		}
+	}
+
+	route := &unstructured.Unstructured{}
+	route.SetAPIVersion(httpRouteGVR.GroupVersion().String())
+	route.SetKind("HTTPRoute")
+	route.SetName(name)
+	route.SetNamespace(namespace)
+	Expect(unstructured.SetNestedSlice(route.Object, []interface{}{
+		map[string]interface{}{"backendRefs": []interface{}{backendRef}},
+	}, "spec", "rules")).To(Succeed())
+
+	_, err := c.localDynClient.Resource(httpRouteGVR).Namespace(namespace).Create(context.TODO(), route, metav1.CreateOptions{})
+	Expect(err).To(Succeed())
+}
+
+func (c *cluster) awaitGatewayBackendService(namespace, name string) *corev1.Service {
+	var service *corev1.Service
+
+	Eventually(func() error {
+		var err error
+		service, err = c.localKubeClient.CoreV1().Services(namespace).Get(context.TODO(), name, metav1.GetOptions{})
+
+		return err
+	}, 5*time.Second, 50*time.Millisecond).Should(Succeed(), "Service %s/%s not found", namespace, name)
+
+	return service
+}
+
+func (c *cluster) awaitGatewayBackendEndpointSlice(namespace, name string) *discovery.EndpointSlice {
+	var endpointSlice *discovery.EndpointSlice
+
+	Eventually(func() error {
+		var err error
+		endpointSlice, err = c.localKubeClient.DiscoveryV1beta1().EndpointSlices(namespace).Get(context.TODO(), name,
+			metav1.GetOptions{})
+
+		return err
+	}, 5*time.Second, 50*time.Millisecond).Should(Succeed(), "EndpointSlice %s/%s not found", namespace, name)
+
+	return endpointSlice
+}
+
+func (c *cluster) awaitNoGatewayBackend(namespace, name string) {
+	Eventually(func() bool {
+		_, err := c.localKubeClient.CoreV1().Services(namespace).Get(context.TODO(), name, metav1.GetOptions{})
+		return apierrors.IsNotFound(err)
+	}, 5*time.Second, 50*time.Millisecond).Should(BeTrue())
+
+	Eventually(func() []discovery.EndpointSlice {
+		list, err := c.localKubeClient.DiscoveryV1beta1().EndpointSlices(namespace).List(context.TODO(), metav1.ListOptions{})
+		Expect(err).To(Succeed())
+
+		return list.Items
+	}, 5*time.Second, 50*time.Millisecond).Should(BeEmpty())
+}
@@ -18,9 +18,11 @@ limitations under the License.
 package controller_test
 
 import (
+	"context"
 	"time"
 
 	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
 	"github.com/submariner-io/admiral/pkg/syncer/test"
 	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
@@ -64,6 +66,31 @@ var _ = Describe("Globalnet enabled", func() {
 					t.awaitServiceExported(globalIP1, 0)
 				})
 			})
+
+			Context("via several GlobalIngressIPs", func() {
+				BeforeEach(func() {
+					t.createGlobalIngressIP(ingressIP)
+					t.createGlobalIngressIP(t.newGlobalIngressIP(t.service.Name+"-ipv6", globalIP2))
+
+					other := t.newGlobalIngressIP("other-service", globalIP3)
+					Expect(unstructured.SetNestedField(other.Object, "other-service", "spec", "serviceRef", "name")).To(Succeed())
+					t.createGlobalIngressIP(other)
+				})
+
+				It("should sync a ServiceImport with all the service's global IPs", func() {
+					Eventually(func() []string {
+						obj, err := t.cluster2.localServiceImportClient.Get(context.TODO(),
+							t.service.Name+"-"+t.service.Namespace+"-"+clusterID1, metav1.GetOptions{})
+						if err != nil {
+							return nil
+						}
+
+						ips, _, _ := unstructured.NestedStringSlice(obj.Object, "spec", "ips")
+
+						return ips
+					}, 5).Should(Equal([]string{globalIP1, globalIP2}))
+				})
+			})
 		})
 
 		Context("and it does not initially have a global IP", func() {
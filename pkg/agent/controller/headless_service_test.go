@@ -19,7 +19,12 @@ package controller_test
 
 import (
 	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/submariner-io/admiral/pkg/syncer/test"
+	lhconstants "github.com/submariner-io/lighthouse/pkg/constants"
 	corev1 "k8s.io/api/core/v1"
+	discovery "k8s.io/api/discovery/v1beta1"
+	"k8s.io/client-go/kubernetes/scheme"
 )
 
 var _ = Describe("Headless service syncing", func() {
@@ -77,6 +82,26 @@ var _ = Describe("Headless service syncing", func() {
 		})
 	})
 
+	When("a ServiceExport requests publishing not-ready addresses", func() {
+		BeforeEach(func() {
+			t.serviceExport.Annotations = map[string]string{lhconstants.PublishNotReadyAddresses: "true"}
+		})
+
+		It("should export the not-ready endpoints as ready", func() {
+			t.createEndpoints()
+			t.createServiceExport()
+			t.awaitHeadlessServiceImport("")
+
+			endpointSlice := &discovery.EndpointSlice{}
+			Expect(scheme.Scheme.Convert(test.AwaitResource(t.cluster1.localEndpointSliceClient, t.endpoints.Name+"-"+clusterID1),
+				endpointSlice, nil)).To(Succeed())
+
+			Expect(endpointSlice.Endpoints).To(HaveLen(3))
+			Expect(endpointSlice.Endpoints[2].Addresses).To(Equal([]string{t.endpoints.Subsets[0].NotReadyAddresses[0].IP}))
+			Expect(endpointSlice.Endpoints[2].Conditions.Ready).To(Equal(&ready))
+		})
+	})
+
 	When("a ServiceExport is deleted", func() {
 		It("should delete the ServiceImport and EndpointSlice", func() {
 			t.createEndpoints()
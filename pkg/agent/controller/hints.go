@@ -0,0 +1,243 @@
+/*
+SPDX-License-Identifier: Apache-2.0
+
+Copyright Contributors to the Submariner project.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package controller
+
+import (
+	"context"
+	"reflect"
+	"sort"
+	"strings"
+
+	"github.com/submariner-io/admiral/pkg/log"
+	lhconstants "github.com/submariner-io/lighthouse/pkg/constants"
+	"github.com/submariner-io/lighthouse/pkg/endpointslice"
+	"github.com/submariner-io/lighthouse/pkg/serviceimport"
+	corev1 "k8s.io/api/core/v1"
+	discovery "k8s.io/api/discovery/v1beta1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/klog"
+	mcsv1a1 "sigs.k8s.io/mcs-api/pkg/apis/v1alpha1"
+)
+
+const (
+	// HintsConfigMapName is the name of the ConfigMap, in each namespace with imported services, mapping the FQDN of
+	// each service to a comma-separated list of its candidate IPs across the clusterset.
+	HintsConfigMapName = "lighthouse-dns-hints"
+
+	labelManagedBy = "app.kubernetes.io/managed-by"
+)
+
+// publishHints refreshes the DNS pre-resolution hints so that sidecars can pre-populate their caches without waiting
+// for the first query to be resolved.
+func (a *Controller) publishHints() {
+	if !a.isActive() {
+		return
+	}
+
+	hints, err := a.buildHints()
+	if err != nil {
+		klog.Errorf("Error building the DNS hints: %v", err)
+		return
+	}
+
+	for namespace, data := range hints {
+		if err := a.updateHintsConfigMap(namespace, data); err != nil {
+			klog.Errorf("Error updating the DNS hints in namespace %q: %v", namespace, err)
+		}
+	}
+
+	existing, err := a.kubeClientSet.CoreV1().ConfigMaps(metav1.NamespaceAll).List(context.TODO(), metav1.ListOptions{
+		LabelSelector: labels.SelectorFromSet(map[string]string{labelManagedBy: lhconstants.LabelValueManagedBy}).String(),
+	})
+	if err != nil {
+		klog.Errorf("Error listing the DNS hints ConfigMaps: %v", err)
+		return
+	}
+
+	for i := range existing.Items {
+		cm := &existing.Items[i]
+		if _, ok := hints[cm.Namespace]; ok || cm.Name != HintsConfigMapName {
+			continue
+		}
+
+		if a.dryRun {
+			klog.Infof("Dry run: would delete the DNS hints in namespace %q", cm.Namespace)
+		}
+
+		err := a.kubeClientSet.CoreV1().ConfigMaps(cm.Namespace).Delete(context.TODO(), cm.Name,
+			metav1.DeleteOptions{DryRun: a.dryRunOptions()})
+		if err != nil && !apierrors.IsNotFound(err) {
+			klog.Errorf("Error deleting the DNS hints in namespace %q: %v", cm.Namespace, err)
+		}
+	}
+}
+
+// buildHints returns the candidate IPs of every imported service, keyed by namespace then FQDN.
+func (a *Controller) buildHints() (map[string]map[string]string, error) {
+	ips, err := a.collectServiceIPs(func(*mcsv1a1.ServiceImport) bool {
+		return true
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	hints := make(map[string]map[string]string, len(ips))
+
+	for namespace, services := range ips {
+		hints[namespace] = make(map[string]string, len(services))
+
+		for fqdn, addresses := range services {
+			hints[namespace][fqdn] = strings.Join(addresses, ",")
+		}
+	}
+
+	return hints, nil
+}
+
+// collectServiceIPs returns the unique sorted IPs of the imported services accepted by the given filter, keyed by
+// namespace then FQDN. ClusterSetIP services contribute each cluster's service IP and headless services the addresses
+// of their ready endpoints.
+func (a *Controller) collectServiceIPs(accept func(*mcsv1a1.ServiceImport) bool) (map[string]map[string][]string, error) {
+	serviceImports, err := a.serviceImportSyncer.ListLocalResources(&mcsv1a1.ServiceImport{})
+	if err != nil {
+		return nil, err
+	}
+
+	endpointSlices, err := a.endpointSliceSyncer.ListLocalResources(&discovery.EndpointSlice{})
+	if err != nil {
+		return nil, err
+	}
+
+	ips := map[string]map[string][]string{}
+
+	addIPs := func(namespace, name string, addresses ...string) {
+		if name == "" || namespace == "" || len(addresses) == 0 {
+			return
+		}
+
+		if ips[namespace] == nil {
+			ips[namespace] = map[string][]string{}
+		}
+
+		fqdn := name + "." + namespace + ".svc." + a.clustersetDomain
+		ips[namespace][fqdn] = append(ips[namespace][fqdn], addresses...)
+	}
+
+	headless := map[string]bool{}
+
+	for _, obj := range serviceImports {
+		si := obj.(*mcsv1a1.ServiceImport)
+		namespace, name := serviceimport.ImportNamespace(si), si.Annotations[lhconstants.OriginName]
+
+		if !accept(si) {
+			continue
+		}
+
+		if si.Spec.Type == mcsv1a1.Headless {
+			headless[namespace+"/"+name] = true
+		} else {
+			addIPs(namespace, name, si.Spec.IPs...)
+		}
+	}
+
+	for _, obj := range endpointSlices {
+		eps := obj.(*discovery.EndpointSlice)
+		namespace, name := endpointslice.ImportNamespace(eps), eps.Labels[lhconstants.LabelSourceName]
+
+		if !headless[namespace+"/"+name] {
+			continue
+		}
+
+		var addresses []string
+
+		for i := range eps.Endpoints {
+			if eps.Endpoints[i].Conditions.Ready == nil || *eps.Endpoints[i].Conditions.Ready {
+				addresses = append(addresses, eps.Endpoints[i].Addresses...)
+			}
+		}
+
+		addIPs(namespace, name, addresses...)
+	}
+
+	for _, services := range ips {
+		for fqdn, addresses := range services {
+			services[fqdn] = uniqueSorted(addresses)
+		}
+	}
+
+	return ips, nil
+}
+
+func (a *Controller) updateHintsConfigMap(namespace string, data map[string]string) error {
+	client := a.kubeClientSet.CoreV1().ConfigMaps(namespace)
+
+	existing, err := client.Get(context.TODO(), HintsConfigMapName, metav1.GetOptions{})
+	if apierrors.IsNotFound(err) {
+		klog.V(log.DEBUG).Infof("Creating the DNS hints in namespace %q", namespace)
+
+		if a.dryRun {
+			klog.Infof("Dry run: would create the DNS hints in namespace %q", namespace)
+		}
+
+		_, err = client.Create(context.TODO(), &corev1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      HintsConfigMapName,
+				Namespace: namespace,
+				Labels:    map[string]string{labelManagedBy: lhconstants.LabelValueManagedBy},
+			},
+			Data: data,
+		}, metav1.CreateOptions{DryRun: a.dryRunOptions()})
+
+		return err
+	}
+
+	if err != nil {
+		return err
+	}
+
+	if reflect.DeepEqual(existing.Data, data) {
+		return nil
+	}
+
+	klog.V(log.TRACE).Infof("Updating the DNS hints in namespace %q", namespace)
+
+	if a.dryRun {
+		klog.Infof("Dry run: would update the DNS hints in namespace %q", namespace)
+	}
+
+	existing.Data = data
+	_, err = client.Update(context.TODO(), existing, metav1.UpdateOptions{DryRun: a.dryRunOptions()})
+
+	return err
+}
+
+func uniqueSorted(from []string) []string {
+	sort.Strings(from)
+
+	to := from[:0]
+
+	for i := range from {
+		if i == 0 || from[i] != from[i-1] {
+			to = append(to, from[i])
+		}
+	}
+
+	return to
+}
@@ -0,0 +1,97 @@
+/*
+SPDX-License-Identifier: Apache-2.0
+
+Copyright Contributors to the Submariner project.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package controller_test
+
+import (
+	"context"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/submariner-io/lighthouse/pkg/agent/controller"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+var _ = Describe("DNS pre-resolution hints", func() {
+	var (
+		t    *testDriver
+		fqdn string
+	)
+
+	BeforeEach(func() {
+		t = newTestDiver()
+		t.cluster2.agentSpec.HintsInterval = 50 * time.Millisecond
+		fqdn = t.service.Name + "." + t.service.Namespace + ".svc.clusterset.local"
+	})
+
+	JustBeforeEach(func() {
+		t.justBeforeEach()
+		t.createService()
+	})
+
+	AfterEach(func() {
+		t.afterEach()
+	})
+
+	When("a ClusterIP Service is exported", func() {
+		It("should publish the service IP and remove the hints when the service is unexported", func() {
+			t.createServiceExport()
+			t.awaitServiceExported(t.service.Spec.ClusterIP, 0)
+			t.cluster2.awaitHints(t.service.Namespace, HaveKeyWithValue(fqdn, t.service.Spec.ClusterIP))
+
+			t.deleteServiceExport()
+			t.awaitServiceUnexported()
+			t.cluster2.awaitNoHints(t.service.Namespace)
+		})
+	})
+
+	When("a headless Service is exported", func() {
+		BeforeEach(func() {
+			t.service.Spec.ClusterIP = corev1.ClusterIPNone
+		})
+
+		It("should publish the ready endpoint addresses", func() {
+			t.createEndpoints()
+			t.createServiceExport()
+			t.awaitHeadlessServiceImport("")
+			t.cluster2.awaitHints(t.service.Namespace, HaveKeyWithValue(fqdn, "192.168.5.1,192.168.5.2"))
+		})
+	})
+})
+
+func (c *cluster) awaitHints(namespace string, matcher OmegaMatcher) {
+	Eventually(func() map[string]string {
+		cm, err := c.localKubeClient.CoreV1().ConfigMaps(namespace).Get(context.TODO(), controller.HintsConfigMapName,
+			metav1.GetOptions{})
+		if err != nil {
+			return nil
+		}
+
+		return cm.Data
+	}, 5*time.Second, 50*time.Millisecond).Should(matcher)
+}
+
+func (c *cluster) awaitNoHints(namespace string) {
+	Eventually(func() bool {
+		_, err := c.localKubeClient.CoreV1().ConfigMaps(namespace).Get(context.TODO(), controller.HintsConfigMapName,
+			metav1.GetOptions{})
+		return apierrors.IsNotFound(err)
+	}, 5*time.Second, 50*time.Millisecond).Should(BeTrue())
+}
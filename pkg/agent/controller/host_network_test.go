@@ -0,0 +1,195 @@
+/*
+SPDX-License-Identifier: Apache-2.0
+
+Copyright Contributors to the Submariner project.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package controller_test
+
+import (
+	"context"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/submariner-io/admiral/pkg/syncer/test"
+	lhconstants "github.com/submariner-io/lighthouse/pkg/constants"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/kubernetes/scheme"
+	mcsv1a1 "sigs.k8s.io/mcs-api/pkg/apis/v1alpha1"
+)
+
+var _ = Describe("Host-networked endpoints", func() {
+	var t *testDriver
+
+	BeforeEach(func() {
+		t = newTestDiver()
+		t.service.Spec.ClusterIP = corev1.ClusterIPNone
+		t.createHostNetworkPod("two")
+	})
+
+	JustBeforeEach(func() {
+		t.justBeforeEach()
+		t.createService()
+		t.createEndpoints()
+		t.createServiceExport()
+	})
+
+	AfterEach(func() {
+		t.afterEach()
+	})
+
+	When("a headless Service backed by a host-networked pod is exported", func() {
+		It("should sync an EndpointSlice with the node IP flagged as host-networked", func() {
+			t.awaitHeadlessServiceImport("")
+			endpointSlice := t.cluster1.awaitEndpointSlice(t)
+			Expect(endpointSlice.Labels).To(HaveKeyWithValue(lhconstants.LabelHostNetwork, "true"))
+		})
+	})
+
+	When("Globalnet is enabled", func() {
+		BeforeEach(func() {
+			t.cluster1.agentSpec.GlobalnetEnabled = true
+			t.cluster2.agentSpec.GlobalnetEnabled = true
+		})
+
+		Context("and the host-networked pod has a global IP", func() {
+			BeforeEach(func() {
+				t.createEndpointIngressIPs()
+			})
+
+			It("should sync an EndpointSlice with the global IPs", func() {
+				t.awaitHeadlessServiceImport("")
+				t.awaitEndpointSlice()
+			})
+		})
+
+		Context("and the host-networked pod doesn't have a global IP", func() {
+			BeforeEach(func() {
+				t.createGlobalIngressIP(t.newGlobalIngressIP("pod-one", globalIP1))
+				t.createGlobalIngressIP(t.newGlobalIngressIP("pod-not-ready", globalIP3))
+			})
+
+			It("should sync an EndpointSlice without the host-networked pod and flag it in the ServiceExport status", func() {
+				t.awaitHeadlessServiceImport("")
+				test.AwaitResource(t.cluster1.localEndpointSliceClient, t.endpoints.Name+"-"+clusterID1)
+				t.cluster1.awaitUpdatedEndpointSlice(t.endpoints, []string{globalIP1, globalIP3})
+				t.awaitServiceExportCondition(newServiceExportCondition(mcsv1a1.ServiceExportValid,
+					corev1.ConditionFalse, "UnsupportedHostNetworkEndpoints"))
+			})
+
+			It("should clear the flag once the host-networked pod is exported", func() {
+				t.awaitServiceExportCondition(newServiceExportCondition(mcsv1a1.ServiceExportValid,
+					corev1.ConditionFalse, "UnsupportedHostNetworkEndpoints"))
+
+				t.createGlobalIngressIP(t.newGlobalIngressIP("pod-two", globalIP2))
+				t.endpoints.Labels["updated"] = "true"
+				t.updateEndpoints()
+
+				t.cluster1.awaitUpdatedEndpointSlice(t.endpoints, []string{globalIP1, globalIP2, globalIP3})
+				t.awaitLastServiceExportCondition(newServiceExportCondition(mcsv1a1.ServiceExportValid,
+					corev1.ConditionTrue, ""))
+			})
+
+			Context("but its node has one", func() {
+				BeforeEach(func() {
+					t.createNodeWithGlobalIP(nodeName, globalIP2)
+				})
+
+				It("should sync an EndpointSlice with the node's global IP for the host-networked pod", func() {
+					t.awaitHeadlessServiceImport("")
+					test.AwaitResource(t.cluster1.localEndpointSliceClient, t.endpoints.Name+"-"+clusterID1)
+					t.cluster1.awaitUpdatedEndpointSlice(t.endpoints, []string{globalIP1, globalIP2, globalIP3})
+
+					endpointSlice := t.cluster1.awaitEndpointSlice(t)
+					Expect(endpointSlice.Labels).To(HaveKeyWithValue(lhconstants.LabelHostNetwork, "true"))
+					t.awaitLastServiceExportCondition(newServiceExportCondition(mcsv1a1.ServiceExportValid,
+						corev1.ConditionTrue, ""))
+				})
+			})
+		})
+	})
+})
+
+func (t *testDriver) createHostNetworkPod(name string) {
+	test.CreateResource(t.cluster1.localDynClient.Resource(schema.GroupVersionResource{Version: "v1", Resource: "pods"}).
+		Namespace(t.service.Namespace), &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      name,
+			Namespace: t.service.Namespace,
+		},
+		Spec: corev1.PodSpec{
+			HostNetwork: true,
+		},
+	})
+}
+
+func (t *testDriver) createNodeWithGlobalIP(name, globalIP string) {
+	test.CreateResource(t.cluster1.localDynClient.Resource(schema.GroupVersionResource{Version: "v1", Resource: "nodes"}),
+		&corev1.Node{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:        name,
+				Annotations: map[string]string{"submariner.io/globalIp": globalIP},
+			},
+		})
+}
+
+// awaitLastServiceExportCondition waits for the last condition of the ServiceExport status to be the given one.
+func (t *testDriver) awaitLastServiceExportCondition(expCond *mcsv1a1.ServiceExportCondition) {
+	Eventually(func() *mcsv1a1.ServiceExportCondition {
+		obj, err := t.cluster1.localServiceExportClient.Get(context.TODO(), t.service.Name, metav1.GetOptions{})
+		if err != nil {
+			return nil
+		}
+
+		se := &mcsv1a1.ServiceExport{}
+		Expect(scheme.Scheme.Convert(obj, se, nil)).To(Succeed())
+
+		if len(se.Status.Conditions) == 0 {
+			return nil
+		}
+
+		c := &se.Status.Conditions[len(se.Status.Conditions)-1]
+		if c.Type == expCond.Type && c.Status == expCond.Status && c.Reason != nil && *c.Reason == *expCond.Reason {
+			return c
+		}
+
+		return nil
+	}, 5*time.Second, 50*time.Millisecond).ShouldNot(BeNil())
+}
+
+// awaitServiceExportCondition waits for the ServiceExport status to contain the given condition, regardless of its
+// position relative to the other conditions.
+func (t *testDriver) awaitServiceExportCondition(expCond *mcsv1a1.ServiceExportCondition) {
+	Eventually(func() *mcsv1a1.ServiceExportCondition {
+		obj, err := t.cluster1.localServiceExportClient.Get(context.TODO(), t.service.Name, metav1.GetOptions{})
+		if err != nil {
+			return nil
+		}
+
+		se := &mcsv1a1.ServiceExport{}
+		Expect(scheme.Scheme.Convert(obj, se, nil)).To(Succeed())
+
+		for i := range se.Status.Conditions {
+			c := &se.Status.Conditions[i]
+			if c.Type == expCond.Type && c.Status == expCond.Status && c.Reason != nil && *c.Reason == *expCond.Reason {
+				return c
+			}
+		}
+
+		return nil
+	}, 5*time.Second, 50*time.Millisecond).ShouldNot(BeNil())
+}
@@ -0,0 +1,239 @@
+/*
+SPDX-License-Identifier: Apache-2.0
+
+Copyright Contributors to the Submariner project.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package controller
+
+import (
+	"context"
+
+	"github.com/submariner-io/admiral/pkg/log"
+	"github.com/submariner-io/admiral/pkg/syncer"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/klog"
+)
+
+type writeKind int
+
+const (
+	writeCreate writeKind = iota
+	writeUpdate
+	writeUpdateStatus
+	writeDelete
+	writeDeleteCollection
+	writePatch
+)
+
+var writeVerbs = map[writeKind]string{
+	writeCreate:           "create",
+	writeUpdate:           "update",
+	writeUpdateStatus:     "update the status of",
+	writeDelete:           "delete",
+	writeDeleteCollection: "delete the matching",
+	writePatch:            "patch",
+}
+
+// write describes a write made through an interceptedClient.
+type write struct {
+	kind         writeKind
+	resource     schema.GroupVersionResource
+	namespace    string
+	name         string
+	obj          *unstructured.Unstructured
+	listOptions  metav1.ListOptions
+	patchType    types.PatchType
+	patch        []byte
+	subresources []string
+}
+
+func (w *write) String() string {
+	if w.kind == writeDeleteCollection {
+		return writeVerbs[w.kind] + " " + w.resource.Resource + " " + w.listOptions.LabelSelector + " in namespace " + w.namespace
+	}
+
+	return writeVerbs[w.kind] + " " + w.resource.Resource + " " + w.name + " in namespace " + w.namespace
+}
+
+// writeInterceptor returns whether the given write should be performed.
+type writeInterceptor func(w *write) bool
+
+// interceptedClient is a dynamic client which passes each write to an interceptor before performing it. Writes the
+// interceptor holds back are reported as successful, returning the object as written. Reads are passed through so
+// that the agent computes its changes against the actual state of the cluster.
+type interceptedClient struct {
+	dynamic.Interface
+	intercept writeInterceptor
+}
+
+func newInterceptedClient(client dynamic.Interface, intercept writeInterceptor) dynamic.Interface {
+	return &interceptedClient{Interface: client, intercept: intercept}
+}
+
+func (c *interceptedClient) Resource(gvr schema.GroupVersionResource) dynamic.NamespaceableResourceInterface {
+	resource := c.Interface.Resource(gvr)
+
+	return &interceptedResource{ResourceInterface: resource, base: resource, gvr: gvr, intercept: c.intercept}
+}
+
+type interceptedResource struct {
+	dynamic.ResourceInterface
+	base      dynamic.NamespaceableResourceInterface
+	gvr       schema.GroupVersionResource
+	namespace string
+	intercept writeInterceptor
+}
+
+func (r *interceptedResource) Namespace(namespace string) dynamic.ResourceInterface {
+	return &interceptedResource{ResourceInterface: r.base.Namespace(namespace), base: r.base, gvr: r.gvr, namespace: namespace,
+		intercept: r.intercept}
+}
+
+func (r *interceptedResource) newWrite(kind writeKind, name string, obj *unstructured.Unstructured) *write {
+	return &write{kind: kind, resource: r.gvr, namespace: r.namespace, name: name, obj: obj}
+}
+
+func (r *interceptedResource) Create(ctx context.Context, obj *unstructured.Unstructured, options metav1.CreateOptions,
+	subresources ...string) (*unstructured.Unstructured, error) {
+	w := r.newWrite(writeCreate, obj.GetName(), obj)
+	w.subresources = subresources
+
+	if r.intercept(w) {
+		return r.ResourceInterface.Create(ctx, obj, options, subresources...)
+	}
+
+	return obj, nil
+}
+
+func (r *interceptedResource) Update(ctx context.Context, obj *unstructured.Unstructured, options metav1.UpdateOptions,
+	subresources ...string) (*unstructured.Unstructured, error) {
+	w := r.newWrite(writeUpdate, obj.GetName(), obj)
+	w.subresources = subresources
+
+	if r.intercept(w) {
+		return r.ResourceInterface.Update(ctx, obj, options, subresources...)
+	}
+
+	return obj, nil
+}
+
+func (r *interceptedResource) UpdateStatus(ctx context.Context, obj *unstructured.Unstructured,
+	options metav1.UpdateOptions) (*unstructured.Unstructured, error) {
+	if r.intercept(r.newWrite(writeUpdateStatus, obj.GetName(), obj)) {
+		return r.ResourceInterface.UpdateStatus(ctx, obj, options)
+	}
+
+	return obj, nil
+}
+
+func (r *interceptedResource) Delete(ctx context.Context, name string, options metav1.DeleteOptions, subresources ...string) error {
+	w := r.newWrite(writeDelete, name, nil)
+	w.subresources = subresources
+
+	if r.intercept(w) {
+		return r.ResourceInterface.Delete(ctx, name, options, subresources...)
+	}
+
+	return nil
+}
+
+func (r *interceptedResource) DeleteCollection(ctx context.Context, options metav1.DeleteOptions, listOptions metav1.ListOptions) error {
+	w := r.newWrite(writeDeleteCollection, "", nil)
+	w.listOptions = listOptions
+
+	if r.intercept(w) {
+		return r.ResourceInterface.DeleteCollection(ctx, options, listOptions)
+	}
+
+	return nil
+}
+
+func (r *interceptedResource) Patch(ctx context.Context, name string, pt types.PatchType, data []byte, options metav1.PatchOptions,
+	subresources ...string) (*unstructured.Unstructured, error) {
+	w := r.newWrite(writePatch, name, nil)
+	w.patchType = pt
+	w.patch = data
+	w.subresources = subresources
+
+	if r.intercept(w) {
+		return r.ResourceInterface.Patch(ctx, name, pt, data, options, subresources...)
+	}
+
+	return r.Get(ctx, name, metav1.GetOptions{}, subresources...)
+}
+
+// logDryRunWrite logs the writes the agent would make in dry-run mode, and holds them all back.
+func logDryRunWrite(w *write) bool {
+	klog.Infof("Dry run: would %s", w)
+
+	switch {
+	case w.obj != nil:
+		klog.V(log.DEBUG).Infof("Dry run: %s %q: %#v", w.resource.Resource, w.name, w.obj.Object)
+	case w.patch != nil:
+		klog.V(log.DEBUG).Infof("Dry run: %s %q patch: %s", w.resource.Resource, w.name, w.patch)
+	}
+
+	return false
+}
+
+// dryRunBrokerTransform wraps the transform of resources synced from the local cluster to the broker, logging the
+// resources which would be synced and dropping them.
+func dryRunBrokerTransform(transform syncer.TransformFunc) syncer.TransformFunc {
+	return func(obj runtime.Object, numRequeues int, op syncer.Operation) (runtime.Object, bool) {
+		if transform != nil {
+			var requeue bool
+
+			obj, requeue = transform(obj, numRequeues, op)
+			if obj == nil {
+				return nil, requeue
+			}
+		}
+
+		if accessor, ok := obj.(metav1.Object); ok {
+			klog.Infof("Dry run: would %s %T %q on the broker", operationVerb(op), obj, accessor.GetName())
+			klog.V(log.DEBUG).Infof("Dry run: %#v", obj)
+		}
+
+		return nil, false
+	}
+}
+
+func operationVerb(op syncer.Operation) string {
+	switch op {
+	case syncer.Create:
+		return "create"
+	case syncer.Update:
+		return "update"
+	case syncer.Delete:
+		return "delete"
+	}
+
+	return "sync"
+}
+
+// dryRunOptions returns the dry-run directive for writes made through typed clients, which the API server validates
+// without persisting them.
+func (a *Controller) dryRunOptions() []string {
+	if a.dryRun {
+		return []string{metav1.DryRunAll}
+	}
+
+	return nil
+}
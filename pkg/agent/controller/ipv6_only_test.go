@@ -0,0 +1,101 @@
+/*
+SPDX-License-Identifier: Apache-2.0
+
+Copyright Contributors to the Submariner project.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package controller_test
+
+import (
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/submariner-io/admiral/pkg/syncer/test"
+	corev1 "k8s.io/api/core/v1"
+	discovery "k8s.io/api/discovery/v1beta1"
+	"k8s.io/client-go/kubernetes/scheme"
+	mcsv1a1 "sigs.k8s.io/mcs-api/pkg/apis/v1alpha1"
+)
+
+var _ = Describe("IPv6-only mode", func() {
+	var t *testDriver
+
+	BeforeEach(func() {
+		t = newTestDiver()
+		t.cluster1.agentSpec.IPv6Only = true
+		t.cluster2.agentSpec.IPv6Only = true
+	})
+
+	JustBeforeEach(func() {
+		t.justBeforeEach()
+		t.createService()
+		t.createEndpoints()
+		t.createServiceExport()
+	})
+
+	AfterEach(func() {
+		t.afterEach()
+	})
+
+	When("a Service with an IPv6 cluster IP is exported", func() {
+		BeforeEach(func() {
+			t.service.Spec.ClusterIP = "fd00:10::1"
+		})
+
+		It("should sync a ServiceImport with the IPv6 cluster IP", func() {
+			t.awaitServiceExported(t.service.Spec.ClusterIP, 0)
+		})
+	})
+
+	When("a Service with an IPv4 cluster IP is exported", func() {
+		It("should not sync a ServiceImport and flag it in the ServiceExport status", func() {
+			t.awaitServiceExportCondition(newServiceExportCondition(mcsv1a1.ServiceExportValid,
+				corev1.ConditionFalse, "UnsupportedIPv4ClusterIP"))
+			t.awaitNoServiceImport(t.brokerServiceImportClient)
+		})
+	})
+
+	When("a headless Service with dual-stack endpoints is exported", func() {
+		BeforeEach(func() {
+			t.service.Spec.ClusterIP = corev1.ClusterIPNone
+			t.endpoints.Subsets[0].Addresses[0].IP = "fd00:5::1"
+		})
+
+		It("should sync an IPv6 EndpointSlice with only the IPv6 endpoints", func() {
+			t.awaitHeadlessServiceImport("")
+
+			obj := test.AwaitResource(t.cluster2.localEndpointSliceClient, t.endpoints.Name+"-"+clusterID1)
+
+			endpointSlice := &discovery.EndpointSlice{}
+			Expect(scheme.Scheme.Convert(obj, endpointSlice, nil)).To(Succeed())
+			Expect(endpointSlice.AddressType).To(Equal(discovery.AddressTypeIPv6))
+			Expect(endpointSlice.Endpoints).To(HaveLen(1))
+			Expect(endpointSlice.Endpoints[0].Addresses).To(Equal([]string{"fd00:5::1"}))
+		})
+	})
+
+	When("only the importing cluster is IPv6-only", func() {
+		BeforeEach(func() {
+			t.cluster1.agentSpec.IPv6Only = false
+		})
+
+		It("should not import the IPv4 ServiceImport", func() {
+			t.awaitBrokerServiceImport(mcsv1a1.ClusterSetIP, t.service.Spec.ClusterIP)
+
+			time.Sleep(200 * time.Millisecond)
+			t.awaitNoServiceImport(t.cluster2.localServiceImportClient)
+		})
+	})
+})
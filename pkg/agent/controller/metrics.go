@@ -0,0 +1,142 @@
+/*
+SPDX-License-Identifier: Apache-2.0
+
+Copyright Contributors to the Submariner project.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package controller
+
+import (
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/submariner-io/admiral/pkg/syncer"
+)
+
+const (
+	directionExport = "export"
+	directionImport = "import"
+)
+
+// otherNamespaces is the namespace label under which the namespaces not in the metrics allow-list are aggregated.
+const otherNamespaces = "other"
+
+var (
+	serviceExportsCounter = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "lighthouse_agent_service_exports_processed_total",
+		Help: "Number of ServiceExport changes processed, by operation",
+	}, []string{"operation"})
+
+	serviceImportsCounter = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "lighthouse_agent_service_imports_synced_total",
+		Help: "Number of ServiceImports created, updated or deleted for exported services, by operation",
+	}, []string{"operation"})
+
+	endpointSlicesCounter = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "lighthouse_agent_endpoint_slices_synced_total",
+		Help: "Number of EndpointSlices synced, by direction (export or import) and operation",
+	}, []string{"direction", "operation"})
+
+	syncErrorsCounter = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "lighthouse_agent_sync_errors_total",
+		Help: "Number of failed attempts to export a service, by reason",
+	}, []string{"reason"})
+
+	brokerLatencyHistogram = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "lighthouse_agent_broker_latency_seconds",
+		Help:    "Time from the creation of a resource on the broker to its sync to this cluster, by resource",
+		Buckets: prometheus.ExponentialBuckets(0.05, 2, 12),
+	}, []string{"resource"})
+
+	orphanedEndpointSlicesCounter = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "lighthouse_agent_orphaned_endpoint_slices_deleted_total",
+		Help: "Number of exported EndpointSlices deleted because their ServiceImport no longer existed",
+	})
+
+	staleBrokerResourcesCounter = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "lighthouse_agent_stale_broker_resources_deleted_total",
+		Help: "Number of resources deleted from the broker on startup because their service was no longer exported, by kind",
+	}, []string{"kind"})
+
+	brokerWatchRestartsCounter = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "lighthouse_agent_broker_watch_restarts_total",
+		Help: "Number of restarts of the broker watches, by broker, resource and how the previous watch ended",
+	}, []string{"broker", "resource", "reason"})
+
+	exportedServicesGauge = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "lighthouse_agent_exported_services",
+		Help: "Number of services currently exported, per namespace",
+	}, []string{"namespace"})
+)
+
+// exportedServices tracks the services successfully exported, to report them per namespace.
+type exportedServices struct {
+	mutex       sync.Mutex
+	byNamespace map[string]map[string]bool
+	// allowed, if set, lists the namespaces reported individually; the others are reported as otherNamespaces
+	allowed map[string]bool
+}
+
+func (e *exportedServices) label(namespace string) string {
+	if len(e.allowed) == 0 || e.allowed[namespace] {
+		return namespace
+	}
+
+	return otherNamespaces
+}
+
+func (e *exportedServices) update(name, namespace string, op syncer.Operation) {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+
+	if e.byNamespace == nil {
+		e.byNamespace = map[string]map[string]bool{}
+	}
+
+	names := e.byNamespace[namespace]
+
+	if op == syncer.Delete {
+		delete(names, name)
+	} else {
+		if names == nil {
+			names = map[string]bool{}
+			e.byNamespace[namespace] = names
+		}
+
+		names[name] = true
+	}
+
+	label := e.label(namespace)
+	count := 0
+
+	for ns, names := range e.byNamespace {
+		if e.label(ns) == label {
+			count += len(names)
+		}
+	}
+
+	exportedServicesGauge.WithLabelValues(label).Set(float64(count))
+}
+
+// observeBrokerLatency records the time it took a resource created on the broker at the given time to reach this
+// cluster, in the broker's clock.
+func (a *Controller) observeBrokerLatency(resource string, created time.Time) {
+	if created.IsZero() {
+		return
+	}
+
+	brokerLatencyHistogram.WithLabelValues(resource).Observe(a.clockSkew.Age(created).Seconds())
+}
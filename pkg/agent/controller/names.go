@@ -0,0 +1,67 @@
+/*
+SPDX-License-Identifier: Apache-2.0
+
+Copyright Contributors to the Submariner project.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package controller
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/submariner-io/lighthouse/pkg/dnsname"
+	"k8s.io/apimachinery/pkg/util/validation"
+	mcsv1a1 "sigs.k8s.io/mcs-api/pkg/apis/v1alpha1"
+)
+
+const invalidName = "InvalidName"
+
+// validateExportedNames returns why the names the given ServiceExport's service would be served as can't be, if they
+// can't: its name and namespace must be valid A-labels if they're internationalized, since resolvers reject the others.
+func validateExportedNames(svcExport *mcsv1a1.ServiceExport) string {
+	for _, name := range []string{svcExport.Name, svcExport.Namespace} {
+		if _, err := dnsname.ToASCII(name); err != nil {
+			return err.Error()
+		}
+	}
+
+	return ""
+}
+
+// encodeAliases returns the given comma-separated aliases with the internationalized ones encoded as A-labels, or why
+// they can't be served if an alias isn't a valid label once encoded.
+func encodeAliases(annotation string) (string, string) {
+	var aliases []string
+
+	for _, alias := range strings.Split(annotation, ",") {
+		alias = strings.TrimSpace(alias)
+		if alias == "" {
+			continue
+		}
+
+		encoded, err := dnsname.ToASCII(alias)
+		if err != nil {
+			return "", err.Error()
+		}
+
+		if errs := validation.IsDNS1123Label(encoded); len(errs) > 0 {
+			return "", fmt.Sprintf("invalid alias %q: %s", alias, strings.Join(errs, ", "))
+		}
+
+		aliases = append(aliases, encoded)
+	}
+
+	return strings.Join(aliases, ","), ""
+}
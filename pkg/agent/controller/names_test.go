@@ -0,0 +1,81 @@
+/*
+SPDX-License-Identifier: Apache-2.0
+
+Copyright Contributors to the Submariner project.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package controller_test
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/submariner-io/admiral/pkg/syncer/test"
+	lhconstants "github.com/submariner-io/lighthouse/pkg/constants"
+	corev1 "k8s.io/api/core/v1"
+	mcsv1a1 "sigs.k8s.io/mcs-api/pkg/apis/v1alpha1"
+)
+
+var _ = Describe("Internationalized names", func() {
+	var t *testDriver
+
+	BeforeEach(func() {
+		t = newTestDiver()
+	})
+
+	JustBeforeEach(func() {
+		t.justBeforeEach()
+		t.createService()
+		t.createServiceExport()
+	})
+
+	AfterEach(func() {
+		t.afterEach()
+	})
+
+	When("a Service is exported with internationalized aliases", func() {
+		BeforeEach(func() {
+			t.serviceExport.Annotations = map[string]string{lhconstants.Aliases: "Café, xn--nxasmq6b, web"}
+		})
+
+		It("should sync a ServiceImport with the aliases encoded as A-labels", func() {
+			obj := test.AwaitResource(t.brokerServiceImportClient, t.service.Name+"-"+t.service.Namespace+"-"+clusterID1)
+			Expect(obj.GetAnnotations()).To(HaveKeyWithValue(lhconstants.Aliases, "xn--caf-dma,xn--nxasmq6b,web"))
+		})
+	})
+
+	When("a Service is exported with an invalid internationalized alias", func() {
+		BeforeEach(func() {
+			t.serviceExport.Annotations = map[string]string{lhconstants.Aliases: "xn--b"}
+		})
+
+		It("should not sync a ServiceImport and flag it in the ServiceExport status", func() {
+			t.awaitServiceExportCondition(newServiceExportCondition(mcsv1a1.ServiceExportValid,
+				corev1.ConditionFalse, "InvalidName"))
+			t.awaitNoServiceImport(t.brokerServiceImportClient)
+		})
+	})
+
+	When("a Service with an invalid A-label name is exported", func() {
+		BeforeEach(func() {
+			t.service.Name = "xn--b"
+			t.serviceExport.Name = t.service.Name
+		})
+
+		It("should not sync a ServiceImport and flag it in the ServiceExport status", func() {
+			t.awaitServiceExportCondition(newServiceExportCondition(mcsv1a1.ServiceExportValid,
+				corev1.ConditionFalse, "InvalidName"))
+			t.awaitNoServiceImport(t.brokerServiceImportClient)
+		})
+	})
+})
@@ -0,0 +1,186 @@
+/*
+SPDX-License-Identifier: Apache-2.0
+
+Copyright Contributors to the Submariner project.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package controller
+
+import (
+	"context"
+	"time"
+
+	"github.com/submariner-io/admiral/pkg/syncer"
+	"github.com/submariner-io/admiral/pkg/syncer/broker"
+	"github.com/submariner-io/admiral/pkg/util"
+	lhconstants "github.com/submariner-io/lighthouse/pkg/constants"
+	discovery "k8s.io/api/discovery/v1beta1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/klog"
+	mcsv1a1 "sigs.k8s.io/mcs-api/pkg/apis/v1alpha1"
+)
+
+// orphanedEndpointSlicesInterval is the interval at which the EndpointSlices exported from this cluster are checked
+// for ServiceImports removed without their finalizer running, e.g. by an agent which predates it.
+const orphanedEndpointSlicesInterval = 10 * time.Minute
+
+// deleteOrphanedEndpointSlices deletes the EndpointSlices exported from this cluster whose ServiceImport no longer
+// exists; their deletion is then synced to the broker and the other clusters.
+func (a *Controller) deleteOrphanedEndpointSlices() {
+	_, gvr, err := util.ToUnstructuredResource(&mcsv1a1.ServiceImport{}, a.restMapper)
+	if err != nil {
+		klog.Errorf("Error getting the ServiceImport resource: %v", err)
+		return
+	}
+
+	serviceImportClient := a.localClient.Resource(*gvr).Namespace(a.namespace)
+	endpointSliceClient := a.localClient.Resource(endpointSliceGVR)
+
+	endpointSlices, err := endpointSliceClient.Namespace(metav1.NamespaceAll).List(context.TODO(), metav1.ListOptions{
+		LabelSelector: labels.SelectorFromSet(map[string]string{
+			discovery.LabelManagedBy:       lhconstants.LabelValueManagedBy,
+			lhconstants.LabelSourceCluster: a.clusterID,
+		}).String(),
+	})
+	if err != nil {
+		klog.Errorf("Error listing the exported EndpointSlices: %v", err)
+		return
+	}
+
+	for i := range endpointSlices.Items {
+		endpointSlice := &endpointSlices.Items[i]
+
+		serviceImportName := endpointSlice.GetLabels()[lhconstants.LabelServiceImportName]
+		if serviceImportName == "" {
+			continue
+		}
+
+		_, err := serviceImportClient.Get(context.TODO(), serviceImportName, metav1.GetOptions{})
+		if !errors.IsNotFound(err) {
+			if err != nil {
+				klog.Errorf("Error retrieving ServiceImport %q: %v", serviceImportName, err)
+			}
+
+			continue
+		}
+
+		klog.Infof("Deleting EndpointSlice %s/%s whose ServiceImport %q no longer exists", endpointSlice.GetNamespace(),
+			endpointSlice.GetName(), serviceImportName)
+
+		err = endpointSliceClient.Namespace(endpointSlice.GetNamespace()).Delete(context.TODO(), endpointSlice.GetName(),
+			metav1.DeleteOptions{})
+		if err != nil && !errors.IsNotFound(err) {
+			klog.Errorf("Error deleting EndpointSlice %s/%s: %v", endpointSlice.GetNamespace(), endpointSlice.GetName(), err)
+			continue
+		}
+
+		orphanedEndpointSlicesCounter.Inc()
+	}
+}
+
+// pruneStaleBrokerResources deletes the ServiceImports and EndpointSlices exported from this cluster to the broker the
+// given syncer syncs with whose Service or ServiceExport no longer exists locally, e.g. because they were deleted while
+// the agent was down, rather than leaving them behind until they're cleaned up manually. It waits for a standby agent
+// to be promoted.
+func (a *Controller) pruneStaleBrokerResources(brokerName string, brokerSyncer *broker.Syncer, stopCh <-chan struct{}) {
+	err := wait.PollImmediateUntil(time.Second, func() (bool, error) {
+		return a.isActive(), nil
+	}, stopCh)
+	if err != nil {
+		return
+	}
+
+	_, serviceImportGVR, err := util.ToUnstructuredResource(&mcsv1a1.ServiceImport{}, a.restMapper)
+	if err != nil {
+		klog.Errorf("Error getting the ServiceImport resource: %v", err)
+		return
+	}
+
+	// The resources this cluster exports from namespaces it doesn't import from are pruned too
+	brokerClient := unnarrowedBrokerClient(brokerSyncer.GetBrokerClient())
+	brokerNamespace := brokerSyncer.GetBrokerNamespace()
+
+	a.pruneStaleBrokerResourcesOf(brokerName, "ServiceImport", brokerClient.Resource(*serviceImportGVR).Namespace(brokerNamespace),
+		labels.SelectorFromSet(map[string]string{lhconstants.LabelSourceCluster: a.clusterID}),
+		func(obj *unstructured.Unstructured) (string, string) {
+			return obj.GetAnnotations()[lhconstants.OriginName], obj.GetAnnotations()[lhconstants.OriginNamespace]
+		})
+
+	a.pruneStaleBrokerResourcesOf(brokerName, "EndpointSlice", brokerClient.Resource(endpointSliceGVR).Namespace(brokerNamespace),
+		labels.SelectorFromSet(map[string]string{
+			discovery.LabelManagedBy:       lhconstants.LabelValueManagedBy,
+			lhconstants.LabelSourceCluster: a.clusterID,
+		}),
+		func(obj *unstructured.Unstructured) (string, string) {
+			return obj.GetLabels()[lhconstants.LabelSourceName], obj.GetLabels()[lhconstants.LabelSourceNamespace]
+		})
+}
+
+// pruneStaleBrokerResourcesOf deletes the broker resources matching the given selector whose source service, as
+// returned by sourceOf, is no longer exported.
+func (a *Controller) pruneStaleBrokerResourcesOf(brokerName, kind string, client dynamic.ResourceInterface,
+	selector labels.Selector, sourceOf func(obj *unstructured.Unstructured) (string, string)) {
+	list, err := client.List(context.TODO(), metav1.ListOptions{LabelSelector: selector.String()})
+	if err != nil {
+		klog.Errorf("Error listing the %ss exported to broker %q: %v", kind, brokerName, err)
+		return
+	}
+
+	for i := range list.Items {
+		obj := &list.Items[i]
+
+		name, namespace := sourceOf(obj)
+		if name == "" || namespace == "" {
+			continue
+		}
+
+		exported, err := a.isExportedLocally(name, namespace)
+		if err != nil {
+			klog.Errorf("Error checking whether service %s/%s is exported: %v", namespace, name, err)
+			continue
+		}
+
+		if exported {
+			continue
+		}
+
+		klog.Infof("Deleting %s %s/%s from broker %q as service %s/%s is no longer exported", kind, obj.GetNamespace(),
+			obj.GetName(), brokerName, namespace, name)
+
+		err = client.Delete(context.TODO(), obj.GetName(), metav1.DeleteOptions{DryRun: a.dryRunOptions()})
+		if err != nil && !errors.IsNotFound(err) {
+			klog.Errorf("Error deleting %s %s/%s from broker %q: %v", kind, obj.GetNamespace(), obj.GetName(), brokerName, err)
+			continue
+		}
+
+		staleBrokerResourcesCounter.WithLabelValues(kind).Inc()
+	}
+}
+
+// isExportedLocally returns whether the given service and its ServiceExport both exist in this cluster.
+func (a *Controller) isExportedLocally(name, namespace string) (bool, error) {
+	for _, resourceSyncer := range []syncer.Interface{a.serviceExportSyncer, a.serviceSyncer} {
+		_, found, err := resourceSyncer.GetResource(name, namespace)
+		if err != nil || !found {
+			return false, err
+		}
+	}
+
+	return true, nil
+}
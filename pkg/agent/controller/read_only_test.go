@@ -0,0 +1,79 @@
+/*
+SPDX-License-Identifier: Apache-2.0
+
+Copyright Contributors to the Submariner project.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package controller_test
+
+import (
+	"context"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+var _ = Describe("Read-only mode", func() {
+	var t *testDriver
+
+	BeforeEach(func() {
+		t = newTestDiver()
+	})
+
+	JustBeforeEach(func() {
+		t.justBeforeEach()
+	})
+
+	AfterEach(func() {
+		t.afterEach()
+	})
+
+	When("the exporting cluster's agent is read-only", func() {
+		BeforeEach(func() {
+			t.cluster1.agentSpec.ReadOnly = true
+		})
+
+		It("should not sync a ServiceImport nor update the ServiceExport status", func() {
+			t.createService()
+			t.createServiceExport()
+
+			time.Sleep(300 * time.Millisecond)
+			t.awaitNoServiceImport(t.cluster1.localServiceImportClient)
+			t.awaitNoServiceImport(t.brokerServiceImportClient)
+			t.awaitNoServiceImport(t.cluster2.localServiceImportClient)
+
+			obj, err := t.cluster1.localServiceExportClient.Get(context.TODO(), t.service.Name, metav1.GetOptions{})
+			Expect(err).To(Succeed())
+
+			_, found, err := unstructured.NestedSlice(obj.Object, "status", "conditions")
+			Expect(err).To(Succeed())
+			Expect(found).To(BeFalse())
+		})
+	})
+
+	When("a consuming cluster's agent is read-only", func() {
+		BeforeEach(func() {
+			t.cluster2.agentSpec.ReadOnly = true
+		})
+
+		It("should still import services exported by other clusters", func() {
+			t.createService()
+			t.createServiceExport()
+			t.awaitServiceExported(t.service.Spec.ClusterIP, 0)
+		})
+	})
+})
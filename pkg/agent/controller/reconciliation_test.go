@@ -133,6 +133,32 @@ var _ = Describe("Reconciliation", func() {
 		})
 	})
 
+	When("the exported service was deleted while the agent was down", func() {
+		BeforeEach(func() {
+			t.service.Spec.ClusterIP = corev1.ClusterIPNone
+		})
+
+		JustBeforeEach(func() {
+			t.createEndpoints()
+		})
+
+		It("should prune its ServiceImport and EndpointSlice from the broker datastore on startup", func() {
+			serviceImport := t.awaitBrokerServiceImport(mcsv1a1.Headless, "")
+			endpointSlice := t.awaitBrokerEndpointSlice()
+
+			t.afterEach()
+			t = newTestDiver()
+
+			test.CreateResource(t.brokerServiceImportClient, serviceImport)
+			test.CreateResource(t.brokerEndpointSliceClient, endpointSlice)
+			t.createServiceExport()
+			t.cluster1.start(t, *t.syncerConfig)
+
+			test.AwaitNoResource(t.brokerServiceImportClient, serviceImport.Name)
+			t.awaitNoEndpointSlice(t.brokerEndpointSliceClient)
+		})
+	})
+
 	When("a synced remote EndpointSlice is stale in the local datastore on startup", func() {
 		BeforeEach(func() {
 			t.service.Spec.ClusterIP = corev1.ClusterIPNone
@@ -0,0 +1,135 @@
+/*
+SPDX-License-Identifier: Apache-2.0
+
+Copyright Contributors to the Submariner project.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package controller
+
+import (
+	"fmt"
+
+	"github.com/submariner-io/admiral/pkg/log"
+	"github.com/submariner-io/admiral/pkg/syncer"
+	"github.com/submariner-io/admiral/pkg/syncer/broker"
+	lhconstants "github.com/submariner-io/lighthouse/pkg/constants"
+	discovery "k8s.io/api/discovery/v1beta1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/klog"
+)
+
+// endpointSliceMirrorManager manages the EndpointSlices Kubernetes mirrors from the Endpoints of services without
+// selectors; those are exported from the Endpoints instead.
+const endpointSliceMirrorManager = "endpointslicemirroring-controller.k8s.io"
+
+// startEndpointSliceMirror starts mirroring the manually managed EndpointSlices of the service, which has no selector,
+// to the clusterset.
+func (e *EndpointController) startEndpointSliceMirror(restMapper meta.RESTMapper, scheme *runtime.Scheme) error {
+	mirrorSyncer, err := syncer.NewResourceSyncer(&syncer.ResourceSyncerConfig{
+		Name:                "EndpointSlice -> EndpointSlice",
+		SourceClient:        e.localClient,
+		SourceNamespace:     e.serviceImportSourceNameSpace,
+		SourceLabelSelector: labels.SelectorFromSet(map[string]string{discovery.LabelServiceName: e.serviceName}).String(),
+		Direction:           syncer.LocalToRemote,
+		RestMapper:          restMapper,
+		Federator: broker.NewFederator(e.localClient, restMapper, e.serviceImportSourceNameSpace, "",
+			"ownerReferences"),
+		ResourceType:     &discovery.EndpointSlice{},
+		Transform:        e.mirrorEndpointSlice,
+		OnSuccessfulSync: e.onSuccessfulEndpointSliceSync,
+		Scheme:           scheme,
+	})
+	if err != nil {
+		return err
+	}
+
+	return mirrorSyncer.Start(e.stopCh)
+}
+
+// mirrorEndpointSlice returns the EndpointSlice exported for the given manually managed EndpointSlice of a service
+// without selector. The EndpointSlices mirrored by Kubernetes from Endpoints, and those of FQDN endpoints, which can't
+// be resolved to addresses, aren't exported.
+func (e *EndpointController) mirrorEndpointSlice(obj runtime.Object, numRequeues int, op syncer.Operation) (runtime.Object, bool) {
+	source := obj.(*discovery.EndpointSlice)
+
+	switch source.Labels[discovery.LabelManagedBy] {
+	case endpointSliceMirrorManager, lhconstants.LabelValueManagedBy:
+		return nil, false
+	}
+
+	endpointSlice := &discovery.EndpointSlice{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      source.Name + "-" + e.clusterID,
+			Namespace: source.Namespace,
+		},
+	}
+
+	if op == syncer.Delete {
+		klog.V(log.DEBUG).Infof("EndpointSlice %s/%s deleted", source.Namespace, source.Name)
+
+		return endpointSlice, false
+	}
+
+	if source.AddressType == discovery.AddressTypeFQDN ||
+		(e.ipv6Only && source.AddressType != discovery.AddressTypeIPv6) {
+		klog.V(log.DEBUG).Infof("Not exporting EndpointSlice %s/%s with address type %s", source.Namespace, source.Name,
+			source.AddressType)
+
+		return nil, false
+	}
+
+	endpointSlice.Labels = e.endpointSliceLabels()
+	endpointSlice.AddressType = source.AddressType
+	endpointSlice.Ports = source.Ports
+	endpointSlice.Endpoints = []discovery.Endpoint{}
+
+	var external []string
+
+	for i := range source.Endpoints {
+		endpoint := &source.Endpoints[i]
+
+		// The addresses of manually managed endpoints aren't pods', so Globalnet can't allocate them global IPs
+		if e.isHeadless && e.globalnetEnabled {
+			external = append(external, endpoint.Addresses...)
+			continue
+		}
+
+		ready := endpoint.Conditions.Ready == nil || *endpoint.Conditions.Ready || e.publishNotReady
+		endpointSlice.Endpoints = append(endpointSlice.Endpoints, discovery.Endpoint{
+			Addresses:  endpoint.Addresses,
+			Conditions: discovery.EndpointConditions{Ready: &ready},
+			Hostname:   endpoint.Hostname,
+			Topology:   endpoint.Topology,
+		})
+	}
+
+	if len(external) > 0 {
+		e.reportExternalEndpoints(external)
+	}
+
+	klog.V(log.TRACE).Infof("Returning EndpointSlice: %#v", endpointSlice)
+
+	return endpointSlice, false
+}
+
+// reportExternalEndpoints flags the given addresses, which aren't pods' and so have no global IP, in the ServiceExport
+// status.
+func (e *EndpointController) reportExternalEndpoints(external []string) {
+	klog.Warningf("Addresses %v backing service %s/%s aren't pods' and can't be exported with Globalnet",
+		external, e.serviceImportSourceNameSpace, e.serviceName)
+	e.flagUnsupported(unsupportedExternal, fmt.Sprintf("Addresses %v have no global IP and are not exported", external))
+}
@@ -0,0 +1,95 @@
+/*
+SPDX-License-Identifier: Apache-2.0
+
+Copyright Contributors to the Submariner project.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package controller_test
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/submariner-io/admiral/pkg/syncer/test"
+	corev1 "k8s.io/api/core/v1"
+	discovery "k8s.io/api/discovery/v1beta1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/scheme"
+)
+
+var _ = Describe("Services without selectors", func() {
+	var t *testDriver
+
+	BeforeEach(func() {
+		t = newTestDiver()
+		t.service.Spec.Selector = nil
+	})
+
+	JustBeforeEach(func() {
+		t.justBeforeEach()
+		t.createService()
+		t.createServiceExport()
+	})
+
+	AfterEach(func() {
+		t.afterEach()
+	})
+
+	When("a ClusterIP Service with manually managed Endpoints is exported", func() {
+		JustBeforeEach(func() {
+			t.createEndpoints()
+		})
+
+		It("should sync a ServiceImport and an EndpointSlice", func() {
+			t.awaitServiceExported(t.service.Spec.ClusterIP, 0)
+			t.cluster1.awaitEndpointSlice(t)
+		})
+	})
+
+	When("a headless Service with a manually managed EndpointSlice is exported", func() {
+		BeforeEach(func() {
+			t.service.Spec.ClusterIP = corev1.ClusterIPNone
+		})
+
+		It("should mirror the EndpointSlice to the other clusters", func() {
+			t.awaitHeadlessServiceImport("")
+
+			hostname := "db-0"
+			port, protocol := int32(5432), corev1.ProtocolTCP
+			test.CreateResource(t.cluster1.localEndpointSliceClient, &discovery.EndpointSlice{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      "external-db",
+					Namespace: t.service.Namespace,
+					Labels:    map[string]string{discovery.LabelServiceName: t.service.Name},
+				},
+				AddressType: discovery.AddressTypeIPv4,
+				Endpoints: []discovery.Endpoint{
+					{
+						Addresses: []string{"192.168.100.10"},
+						Hostname:  &hostname,
+					},
+				},
+				Ports: []discovery.EndpointPort{{Port: &port, Protocol: &protocol}},
+			})
+
+			obj := test.AwaitResource(t.cluster2.localEndpointSliceClient, "external-db-"+clusterID1)
+
+			endpointSlice := &discovery.EndpointSlice{}
+			Expect(scheme.Scheme.Convert(obj, endpointSlice, nil)).To(Succeed())
+			Expect(endpointSlice.Endpoints).To(HaveLen(1))
+			Expect(endpointSlice.Endpoints[0].Addresses).To(Equal([]string{"192.168.100.10"}))
+			Expect(*endpointSlice.Endpoints[0].Conditions.Ready).To(BeTrue())
+			Expect(endpointSlice.Ports).To(HaveLen(1))
+		})
+	})
+})
@@ -18,9 +18,17 @@ limitations under the License.
 package controller_test
 
 import (
+	"context"
+	"fmt"
+	"time"
+
 	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
 	"github.com/submariner-io/lighthouse/pkg/agent/controller"
+	lhconstants "github.com/submariner-io/lighthouse/pkg/constants"
 	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/util/retry"
 	mcsv1a1 "sigs.k8s.io/mcs-api/pkg/apis/v1alpha1"
 )
 
@@ -143,15 +151,132 @@ var _ = Describe("ServiceImport syncing", func() {
 				corev1.ConditionFalse, "UnsupportedServiceType"))
 			t.awaitNoServiceImport(t.brokerServiceImportClient)
 		})
+
+		It("should emit warning Events on the ServiceExport and the Service", func() {
+			t.createService()
+			t.createServiceExport()
+
+			t.awaitExportFailureEvents("UnsupportedServiceType", "ServiceExport", "Service")
+		})
+	})
+
+	When("a ServiceExport maps the Service to another namespace", func() {
+		BeforeEach(func() {
+			t.serviceExport.Annotations = map[string]string{lhconstants.ImportNamespace: "shared"}
+		})
+
+		It("should sync a ServiceImport annotated with the mapped namespace", func() {
+			t.createService()
+			t.createServiceExport()
+			t.awaitServiceExported(t.service.Spec.ClusterIP, 0)
+
+			serviceImport := t.cluster2.awaitServiceImport(t.service, mcsv1a1.ClusterSetIP, t.service.Spec.ClusterIP)
+			Expect(serviceImport.Annotations[lhconstants.ImportNamespace]).To(Equal("shared"))
+		})
+	})
+
+	When("a ServiceExport sets the cluster selection policy", func() {
+		BeforeEach(func() {
+			t.serviceExport.Annotations = map[string]string{lhconstants.ClusterSelection: "all"}
+		})
+
+		It("should sync a ServiceImport annotated with the policy", func() {
+			t.createService()
+			t.createServiceExport()
+			t.awaitServiceExported(t.service.Spec.ClusterIP, 0)
+
+			serviceImport := t.cluster2.awaitServiceImport(t.service, mcsv1a1.ClusterSetIP, t.service.Spec.ClusterIP)
+			Expect(serviceImport.Annotations[lhconstants.ClusterSelection]).To(Equal("all"))
+		})
+	})
+
+	When("a ServiceExport sets the minimum number of ready endpoints", func() {
+		BeforeEach(func() {
+			t.serviceExport.Annotations = map[string]string{lhconstants.MinReadyEndpoints: "3"}
+		})
+
+		It("should sync a ServiceImport annotated with the minimum", func() {
+			t.createService()
+			t.createServiceExport()
+			t.awaitServiceExported(t.service.Spec.ClusterIP, 0)
+
+			serviceImport := t.cluster2.awaitServiceImport(t.service, mcsv1a1.ClusterSetIP, t.service.Spec.ClusterIP)
+			Expect(serviceImport.Annotations[lhconstants.MinReadyEndpoints]).To(Equal("3"))
+		})
+	})
+
+	When("a ServiceExport maps the Service to an invalid namespace", func() {
+		BeforeEach(func() {
+			t.serviceExport.Annotations = map[string]string{lhconstants.ImportNamespace: "Not_Valid"}
+		})
+
+		It("should update the ServiceExport status and not sync a ServiceImport", func() {
+			t.createService()
+			t.createServiceExport()
+
+			t.awaitServiceExportStatus(0, newServiceExportCondition(mcsv1a1.ServiceExportValid,
+				corev1.ConditionFalse, "InvalidImportNamespace"))
+			t.awaitNoServiceImport(t.brokerServiceImportClient)
+		})
+	})
+
+	When("a ServiceExport's annotations are updated", func() {
+		for annotation, value := range map[string]string{
+			lhconstants.ImportNamespace:          "shared",
+			lhconstants.ClusterSelection:         "all",
+			lhconstants.MinReadyEndpoints:        "3",
+			lhconstants.PublishNotReadyAddresses: "true",
+			lhconstants.ExternalDNS:              "true",
+			lhconstants.Aliases:                  "alias1",
+			lhconstants.ExportMode:               lhconstants.ExportModeClusterIP,
+		} {
+			annotation, value := annotation, value
+
+			It(fmt.Sprintf("should sync the ServiceImport with the updated %q annotation", annotation), func() {
+				t.createService()
+				t.createServiceExport()
+				t.awaitServiceExported(t.service.Spec.ClusterIP, 0)
+
+				t.updateServiceExportAnnotations(map[string]string{annotation: value})
+				t.awaitBrokerServiceImportAnnotation(annotation, value)
+			})
+		}
+	})
+
+	When("a ServiceExport's invalid annotation is fixed", func() {
+		for reason, annotations := range map[string][2]map[string]string{
+			"InvalidImportNamespace": {{lhconstants.ImportNamespace: "Not_Valid"}, {lhconstants.ImportNamespace: "shared"}},
+			"InvalidExportMode":      {{lhconstants.ExportMode: "Bogus"}, {lhconstants.ExportMode: lhconstants.ExportModeClusterIP}},
+			"InvalidName":            {{lhconstants.Aliases: "Not_Valid"}, {lhconstants.Aliases: "alias1"}},
+		} {
+			reason, annotations := reason, annotations
+
+			It(fmt.Sprintf("should sync a ServiceImport once the annotation flagged as %s is fixed", reason), func() {
+				t.serviceExport.Annotations = annotations[0]
+				t.createService()
+				t.createServiceExport()
+
+				t.awaitServiceExportCondition(newServiceExportCondition(mcsv1a1.ServiceExportValid,
+					corev1.ConditionFalse, reason))
+				t.awaitNoServiceImport(t.brokerServiceImportClient)
+
+				t.updateServiceExportAnnotations(annotations[1])
+				t.awaitBrokerServiceImport(mcsv1a1.ClusterSetIP, t.service.Spec.ClusterIP)
+				t.awaitServiceExportCondition(newServiceExportCondition(mcsv1a1.ServiceExportValid,
+					corev1.ConditionTrue, ""))
+			})
+		}
 	})
 
 	When("a Service has port information", func() {
 		BeforeEach(func() {
+			appProtocol := "kubernetes.io/h2c"
 			t.service.Spec.Ports = []corev1.ServicePort{
 				{
-					Name:     "eth0",
-					Protocol: corev1.ProtocolTCP,
-					Port:     123,
+					Name:        "eth0",
+					Protocol:    corev1.ProtocolTCP,
+					AppProtocol: &appProtocol,
+					Port:        123,
 				},
 				{
 					Name:     "eth1",
@@ -168,3 +293,31 @@ var _ = Describe("ServiceImport syncing", func() {
 		})
 	})
 })
+
+func (t *testDriver) updateServiceExportAnnotations(annotations map[string]string) {
+	Expect(retry.RetryOnConflict(retry.DefaultRetry, func() error {
+		obj, err := t.cluster1.localServiceExportClient.Get(context.TODO(), t.serviceExport.Name, metav1.GetOptions{})
+		if err != nil {
+			return err
+		}
+
+		obj.SetAnnotations(annotations)
+
+		_, err = t.cluster1.localServiceExportClient.Update(context.TODO(), obj, metav1.UpdateOptions{})
+
+		return err
+	})).To(Succeed())
+}
+
+func (t *testDriver) awaitBrokerServiceImportAnnotation(annotation, value string) {
+	name := t.service.Name + "-" + t.service.Namespace + "-" + clusterID1
+
+	Eventually(func() string {
+		obj, err := t.brokerServiceImportClient.Get(context.TODO(), name, metav1.GetOptions{})
+		if err != nil {
+			return ""
+		}
+
+		return obj.GetAnnotations()[annotation]
+	}, 5*time.Second, 50*time.Millisecond).Should(Equal(value))
+}
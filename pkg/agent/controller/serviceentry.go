@@ -0,0 +1,229 @@
+/*
+SPDX-License-Identifier: Apache-2.0
+
+Copyright Contributors to the Submariner project.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package controller
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+
+	"github.com/submariner-io/admiral/pkg/log"
+	lhconstants "github.com/submariner-io/lighthouse/pkg/constants"
+	"github.com/submariner-io/lighthouse/pkg/serviceimport"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/klog"
+	mcsv1a1 "sigs.k8s.io/mcs-api/pkg/apis/v1alpha1"
+)
+
+// ServiceEntryPrefix prefixes the name of the imported service in the name of the Istio ServiceEntry generated for it.
+const ServiceEntryPrefix = "lighthouse-"
+
+var serviceEntryGVR = schema.GroupVersionResource{Group: "networking.istio.io", Version: "v1beta1", Resource: "serviceentries"}
+
+// publishServiceEntries refreshes the Istio ServiceEntries of the imported services, so that meshes can route to the
+// clusters exporting them without manual configuration.
+func (a *Controller) publishServiceEntries() {
+	if !a.isActive() {
+		return
+	}
+
+	specs, err := a.buildServiceEntrySpecs()
+	if err != nil {
+		klog.Errorf("Error building the Istio ServiceEntries: %v", err)
+		return
+	}
+
+	for key, spec := range specs {
+		namespace, name := splitServiceEntryKey(key)
+		if err := a.updateServiceEntry(namespace, name, spec); err != nil {
+			klog.Errorf("Error updating the Istio ServiceEntry %q: %v", key, err)
+		}
+	}
+
+	existing, err := a.localClient.Resource(serviceEntryGVR).List(context.TODO(), metav1.ListOptions{
+		LabelSelector: labels.SelectorFromSet(map[string]string{labelManagedBy: lhconstants.LabelValueManagedBy}).String(),
+	})
+	if err != nil {
+		klog.Errorf("Error listing the Istio ServiceEntries: %v", err)
+		return
+	}
+
+	for i := range existing.Items {
+		obj := &existing.Items[i]
+		if _, ok := specs[obj.GetNamespace()+"/"+obj.GetName()]; ok {
+			continue
+		}
+
+		err := a.localClient.Resource(serviceEntryGVR).Namespace(obj.GetNamespace()).Delete(context.TODO(), obj.GetName(),
+			metav1.DeleteOptions{})
+		if err != nil && !apierrors.IsNotFound(err) {
+			klog.Errorf("Error deleting the Istio ServiceEntry %s/%s: %v", obj.GetNamespace(), obj.GetName(), err)
+		}
+	}
+}
+
+// buildServiceEntrySpecs returns the specs of the ServiceEntries of the imported services, keyed by the ServiceEntries'
+// namespace and name. Each ServiceEntry resolves the service's clusterset name statically to the IPs of all the
+// clusters exporting it, on the ports they export.
+func (a *Controller) buildServiceEntrySpecs() (map[string]map[string]interface{}, error) {
+	ips, err := a.collectServiceIPs(func(*mcsv1a1.ServiceImport) bool {
+		return true
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	serviceImports, err := a.serviceImportSyncer.ListLocalResources(&mcsv1a1.ServiceImport{})
+	if err != nil {
+		return nil, err
+	}
+
+	ports := map[string]map[string]interface{}{}
+
+	for _, obj := range serviceImports {
+		si := obj.(*mcsv1a1.ServiceImport)
+		fqdn := si.Annotations[lhconstants.OriginName] + "." + serviceimport.ImportNamespace(si) + ".svc." + a.clustersetDomain
+
+		if ports[fqdn] == nil {
+			ports[fqdn] = map[string]interface{}{}
+		}
+
+		for i := range si.Spec.Ports {
+			port := &si.Spec.Ports[i]
+			name := port.Name
+
+			if name == "" {
+				name = fmt.Sprintf("%s-%d", strings.ToLower(string(port.Protocol)), port.Port)
+			}
+
+			ports[fqdn][name] = map[string]interface{}{
+				"name":     name,
+				"number":   int64(port.Port),
+				"protocol": serviceEntryProtocol(port),
+			}
+		}
+	}
+
+	specs := map[string]map[string]interface{}{}
+
+	for namespace, services := range ips {
+		for fqdn, addresses := range services {
+			endpoints := make([]interface{}, 0, len(addresses))
+			for _, address := range addresses {
+				endpoints = append(endpoints, map[string]interface{}{"address": address})
+			}
+
+			name := ServiceEntryPrefix + strings.SplitN(fqdn, ".", 2)[0]
+			specs[namespace+"/"+name] = map[string]interface{}{
+				"hosts":      []interface{}{fqdn},
+				"location":   "MESH_INTERNAL",
+				"resolution": "STATIC",
+				"ports":      sortedPorts(ports[fqdn]),
+				"endpoints":  endpoints,
+			}
+		}
+	}
+
+	return specs, nil
+}
+
+// serviceEntryProtocol returns the Istio protocol of the given port, its application protocol if Istio knows it.
+func serviceEntryProtocol(port *mcsv1a1.ServicePort) string {
+	if port.AppProtocol != nil {
+		switch protocol := strings.ToUpper(*port.AppProtocol); protocol {
+		case "HTTP", "HTTPS", "HTTP2", "GRPC", "TLS", "MONGO", "MYSQL", "REDIS":
+			return protocol
+		}
+	}
+
+	if port.Protocol == "" {
+		return "TCP"
+	}
+
+	return string(port.Protocol)
+}
+
+func sortedPorts(ports map[string]interface{}) []interface{} {
+	names := make([]string, 0, len(ports))
+	for name := range ports {
+		names = append(names, name)
+	}
+
+	sort.Strings(names)
+
+	sorted := make([]interface{}, 0, len(names))
+	for _, name := range names {
+		sorted = append(sorted, ports[name])
+	}
+
+	return sorted
+}
+
+func splitServiceEntryKey(key string) (namespace, name string) {
+	parts := strings.SplitN(key, "/", 2)
+	return parts[0], parts[1]
+}
+
+func (a *Controller) updateServiceEntry(namespace, name string, spec map[string]interface{}) error {
+	client := a.localClient.Resource(serviceEntryGVR).Namespace(namespace)
+
+	existing, err := client.Get(context.TODO(), name, metav1.GetOptions{})
+	if apierrors.IsNotFound(err) {
+		klog.V(log.DEBUG).Infof("Creating the Istio ServiceEntry %s/%s", namespace, name)
+
+		obj := &unstructured.Unstructured{}
+		obj.SetAPIVersion(serviceEntryGVR.GroupVersion().String())
+		obj.SetKind("ServiceEntry")
+		obj.SetName(name)
+		obj.SetNamespace(namespace)
+		obj.SetLabels(map[string]string{labelManagedBy: lhconstants.LabelValueManagedBy})
+
+		if err := unstructured.SetNestedMap(obj.Object, spec, "spec"); err != nil {
+			return err
+		}
+
+		_, err = client.Create(context.TODO(), obj, metav1.CreateOptions{})
+
+		return err
+	}
+
+	if err != nil {
+		return err
+	}
+
+	current, _, _ := unstructured.NestedMap(existing.Object, "spec")
+	if reflect.DeepEqual(current, spec) {
+		return nil
+	}
+
+	klog.V(log.TRACE).Infof("Updating the Istio ServiceEntry %s/%s", namespace, name)
+
+	if err := unstructured.SetNestedMap(existing.Object, spec, "spec"); err != nil {
+		return err
+	}
+
+	_, err = client.Update(context.TODO(), existing, metav1.UpdateOptions{})
+
+	return err
+}
@@ -0,0 +1,112 @@
+/*
+SPDX-License-Identifier: Apache-2.0
+
+Copyright Contributors to the Submariner project.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package controller_test
+
+import (
+	"context"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/submariner-io/lighthouse/pkg/agent/controller"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+var serviceEntryGVR = schema.GroupVersionResource{Group: "networking.istio.io", Version: "v1beta1", Resource: "serviceentries"}
+
+var _ = Describe("Istio ServiceEntry generation", func() {
+	var (
+		t    *testDriver
+		name string
+	)
+
+	BeforeEach(func() {
+		t = newTestDiver()
+		t.cluster2.agentSpec.ServiceEntryInterval = 50 * time.Millisecond
+		name = controller.ServiceEntryPrefix + t.service.Name
+
+		appProtocol := "http"
+		t.service.Spec.Ports = []corev1.ServicePort{
+			{
+				Name:        "web",
+				Protocol:    corev1.ProtocolTCP,
+				AppProtocol: &appProtocol,
+				Port:        8080,
+			},
+		}
+	})
+
+	JustBeforeEach(func() {
+		t.justBeforeEach()
+		t.createService()
+	})
+
+	AfterEach(func() {
+		t.afterEach()
+	})
+
+	When("a ClusterIP Service is exported", func() {
+		It("should generate a ServiceEntry and remove it when the service is unexported", func() {
+			t.createServiceExport()
+			t.awaitServiceExported(t.service.Spec.ClusterIP, 0)
+
+			spec := t.cluster2.awaitServiceEntry(t.service.Namespace, name)
+			Expect(spec).To(HaveKeyWithValue("hosts", []interface{}{t.service.Name + "." + t.service.Namespace +
+				".svc.clusterset.local"}))
+			Expect(spec).To(HaveKeyWithValue("resolution", "STATIC"))
+			Expect(spec).To(HaveKeyWithValue("endpoints", []interface{}{
+				map[string]interface{}{"address": t.service.Spec.ClusterIP},
+			}))
+			Expect(spec).To(HaveKeyWithValue("ports", []interface{}{
+				map[string]interface{}{"name": "web", "number": int64(8080), "protocol": "HTTP"},
+			}))
+
+			t.deleteServiceExport()
+			t.awaitServiceUnexported()
+			t.cluster2.awaitNoServiceEntry(t.service.Namespace, name)
+		})
+	})
+})
+
+func (c *cluster) awaitServiceEntry(namespace, name string) map[string]interface{} {
+	var spec map[string]interface{}
+
+	Eventually(func() bool {
+		obj, err := c.localDynClient.Resource(serviceEntryGVR).Namespace(namespace).Get(context.TODO(), name, metav1.GetOptions{})
+		if err != nil {
+			return false
+		}
+
+		spec, _, _ = unstructured.NestedMap(obj.Object, "spec")
+
+		return true
+	}, 5*time.Second, 50*time.Millisecond).Should(BeTrue(), "ServiceEntry %s/%s not found", namespace, name)
+
+	return spec
+}
+
+func (c *cluster) awaitNoServiceEntry(namespace, name string) {
+	Eventually(func() bool {
+		_, err := c.localDynClient.Resource(serviceEntryGVR).Namespace(namespace).Get(context.TODO(), name, metav1.GetOptions{})
+		return apierrors.IsNotFound(err)
+	}, 5*time.Second, 50*time.Millisecond).Should(BeTrue())
+}
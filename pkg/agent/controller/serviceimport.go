@@ -18,12 +18,18 @@ limitations under the License.
 package controller
 
 import (
+	"context"
+
 	"github.com/submariner-io/admiral/pkg/federate"
 	"github.com/submariner-io/admiral/pkg/log"
 	"github.com/submariner-io/admiral/pkg/syncer"
+	"github.com/submariner-io/admiral/pkg/util"
 	lhconstants "github.com/submariner-io/lighthouse/pkg/constants"
 	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
 	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/client-go/dynamic"
 	"k8s.io/client-go/tools/cache"
@@ -32,17 +38,26 @@ import (
 )
 
 func newServiceImportController(spec *AgentSpecification, serviceSyncer syncer.Interface, restMapper meta.RESTMapper,
-	localClient dynamic.Interface, scheme *runtime.Scheme) (*ServiceImportController, error) {
+	localClient dynamic.Interface, scheme *runtime.Scheme, updateExportStatus exportStatusUpdater,
+	clearExportStatus exportStatusClearer) (*ServiceImportController, error) {
 	controller := &ServiceImportController{
-		serviceSyncer:    serviceSyncer,
-		localClient:      localClient,
-		restMapper:       restMapper,
-		clusterID:        spec.ClusterID,
-		scheme:           scheme,
-		globalnetEnabled: spec.GlobalnetEnabled,
+		serviceSyncer:      serviceSyncer,
+		localClient:        localClient,
+		restMapper:         restMapper,
+		clusterID:          spec.ClusterID,
+		scheme:             scheme,
+		globalnetEnabled:   spec.GlobalnetEnabled,
+		ipv6Only:           spec.IPv6Only,
+		updateExportStatus: updateExportStatus,
+		clearExportStatus:  clearExportStatus,
+	}
+
+	_, gvr, err := util.ToUnstructuredResource(&mcsv1a1.ServiceImport{}, restMapper)
+	if err != nil {
+		return nil, err
 	}
 
-	var err error
+	controller.serviceImportClient = localClient.Resource(*gvr)
 
 	controller.serviceImportSyncer, err = syncer.NewResourceSyncer(&syncer.ResourceSyncerConfig{
 		Name:            "ServiceImport watcher",
@@ -54,6 +69,10 @@ func newServiceImportController(spec *AgentSpecification, serviceSyncer syncer.I
 		ResourceType:    &mcsv1a1.ServiceImport{},
 		Transform:       controller.serviceImportToEndpointController,
 		Scheme:          scheme,
+		// A ServiceImport marked for deletion only differs in its metadata, and updates are cheap to process
+		ResourcesEquivalent: func(obj1, obj2 *unstructured.Unstructured) bool {
+			return false
+		},
 	})
 	if err != nil {
 		return nil, err
@@ -107,13 +126,10 @@ func (c *ServiceImportController) serviceImportCreatedOrUpdated(serviceImport *m
 	}
 
 	service := obj.(*corev1.Service)
-	if service.Spec.Selector == nil {
-		klog.Errorf("The service %s/%s without a Selector is not supported", serviceNameSpace, serviceName)
-		return false
-	}
 
 	endpointController, err := startEndpointController(c.localClient, c.restMapper, c.scheme,
-		serviceImport, serviceNameSpace, serviceName, c.clusterID, c.globalnetEnabled)
+		serviceImport, serviceNameSpace, serviceName, c.clusterID, c.globalnetEnabled, c.ipv6Only,
+		len(service.Spec.Selector) == 0, c.updateExportStatus, c.clearExportStatus)
 	if err != nil {
 		klog.Errorf(err.Error())
 		return true
@@ -138,6 +154,66 @@ func (c *ServiceImportController) serviceImportDeleted(serviceImport *mcsv1a1.Se
 	return false
 }
 
+// serviceImportDeleting removes the EndpointSlices of a local ServiceImport marked for deletion, then its finalizer, so
+// that the ServiceImport is only removed, locally and from the broker, once no EndpointSlice references it.
+func (c *ServiceImportController) serviceImportDeleting(serviceImport *mcsv1a1.ServiceImport, key string) bool {
+	if serviceImport.GetLabels()[lhconstants.LabelSourceCluster] != c.clusterID ||
+		!hasFinalizer(serviceImport.Finalizers, lhconstants.ServiceImportFinalizer) {
+		return false
+	}
+
+	if obj, found := c.endpointControllers.Load(key); found {
+		close(obj.(*EndpointController).stopCh)
+		c.endpointControllers.Delete(key)
+	}
+
+	err := deleteEndpointSlices(c.localClient, serviceImport.Annotations[lhconstants.OriginNamespace], serviceImport.Name)
+	if err != nil {
+		klog.Errorf("Error deleting the EndpointSlices of ServiceImport %q: %v", key, err)
+		return true
+	}
+
+	obj, err := c.serviceImportClient.Namespace(serviceImport.Namespace).Get(context.TODO(), serviceImport.Name, metav1.GetOptions{})
+	if errors.IsNotFound(err) {
+		return false
+	}
+
+	if err != nil {
+		klog.Errorf("Error retrieving ServiceImport %q: %v", key, err)
+		return true
+	}
+
+	finalizers := []string{}
+
+	for _, finalizer := range obj.GetFinalizers() {
+		if finalizer != lhconstants.ServiceImportFinalizer {
+			finalizers = append(finalizers, finalizer)
+		}
+	}
+
+	obj.SetFinalizers(finalizers)
+
+	_, err = c.serviceImportClient.Namespace(serviceImport.Namespace).Update(context.TODO(), obj, metav1.UpdateOptions{})
+	if err != nil && !errors.IsNotFound(err) {
+		klog.Errorf("Error removing the finalizer from ServiceImport %q: %v", key, err)
+		return true
+	}
+
+	klog.V(log.DEBUG).Infof("Removed the EndpointSlices and finalizer of ServiceImport %q", key)
+
+	return false
+}
+
+func hasFinalizer(finalizers []string, finalizer string) bool {
+	for _, f := range finalizers {
+		if f == finalizer {
+			return true
+		}
+	}
+
+	return false
+}
+
 func (c *ServiceImportController) serviceImportToEndpointController(obj runtime.Object, numRequeues int,
 	op syncer.Operation) (runtime.Object, bool) {
 	serviceImport := obj.(*mcsv1a1.ServiceImport)
@@ -145,6 +221,10 @@ func (c *ServiceImportController) serviceImportToEndpointController(obj runtime.
 
 	klog.V(log.DEBUG).Infof("ServiceImport %q %sd", key, op)
 
+	if serviceImport.DeletionTimestamp != nil && op != syncer.Delete {
+		return nil, c.serviceImportDeleting(serviceImport, key)
+	}
+
 	if op == syncer.Create || op == syncer.Update {
 		return nil, c.serviceImportCreatedOrUpdated(serviceImport, key)
 	}
@@ -0,0 +1,78 @@
+/*
+SPDX-License-Identifier: Apache-2.0
+
+Copyright Contributors to the Submariner project.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package controller_test
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+	mcsv1a1 "sigs.k8s.io/mcs-api/pkg/apis/v1alpha1"
+)
+
+var _ = Describe("Session affinity propagation", func() {
+	var t *testDriver
+
+	BeforeEach(func() {
+		t = newTestDiver()
+	})
+
+	JustBeforeEach(func() {
+		t.justBeforeEach()
+		t.createService()
+		t.createServiceExport()
+	})
+
+	AfterEach(func() {
+		t.afterEach()
+	})
+
+	When("an exported Service has ClientIP session affinity", func() {
+		timeout := int32(600)
+
+		BeforeEach(func() {
+			t.service.Spec.SessionAffinity = corev1.ServiceAffinityClientIP
+			t.service.Spec.SessionAffinityConfig = &corev1.SessionAffinityConfig{
+				ClientIP: &corev1.ClientIPConfig{TimeoutSeconds: &timeout},
+			}
+		})
+
+		It("should copy it into the ServiceImports", func() {
+			t.awaitServiceExported(t.service.Spec.ClusterIP, 0)
+
+			for _, c := range []*cluster{&t.cluster1, &t.cluster2} {
+				serviceImport := c.awaitServiceImport(t.service, mcsv1a1.ClusterSetIP, t.service.Spec.ClusterIP)
+				Expect(serviceImport.Spec.SessionAffinity).To(Equal(corev1.ServiceAffinityClientIP))
+				Expect(serviceImport.Spec.SessionAffinityConfig).To(Equal(t.service.Spec.SessionAffinityConfig))
+			}
+		})
+	})
+
+	When("an exported Service has no session affinity", func() {
+		BeforeEach(func() {
+			t.service.Spec.SessionAffinity = corev1.ServiceAffinityNone
+		})
+
+		It("should sync ServiceImports without session affinity", func() {
+			t.awaitServiceExported(t.service.Spec.ClusterIP, 0)
+
+			serviceImport := t.cluster2.awaitServiceImport(t.service, mcsv1a1.ClusterSetIP, t.service.Spec.ClusterIP)
+			Expect(serviceImport.Spec.SessionAffinity).To(Equal(corev1.ServiceAffinityNone))
+			Expect(serviceImport.Spec.SessionAffinityConfig).To(Equal(new(corev1.SessionAffinityConfig)))
+		})
+	})
+})
@@ -0,0 +1,233 @@
+/*
+SPDX-License-Identifier: Apache-2.0
+
+Copyright Contributors to the Submariner project.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package controller
+
+import (
+	"context"
+	"sync"
+
+	"github.com/submariner-io/admiral/pkg/log"
+	"github.com/submariner-io/admiral/pkg/syncer"
+	"github.com/submariner-io/admiral/pkg/syncer/broker"
+	discovery "k8s.io/api/discovery/v1beta1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/klog"
+	mcsv1a1 "sigs.k8s.io/mcs-api/pkg/apis/v1alpha1"
+)
+
+// writeGate holds back the writes of a standby replica, which keeps its caches warm and computes the same changes as
+// the leader without making them. The latest write held back for each resource is replayed once the gate is opened,
+// so that changes the previous leader didn't get to make before failing over aren't lost.
+type writeGate struct {
+	mutex   sync.Mutex
+	opened  bool
+	pending map[string]*write
+	order   []string
+}
+
+func newWriteGate() *writeGate {
+	return &writeGate{pending: map[string]*write{}}
+}
+
+func (g *writeGate) isOpen() bool {
+	g.mutex.Lock()
+	defer g.mutex.Unlock()
+
+	return g.opened
+}
+
+func (g *writeGate) intercept(w *write) bool {
+	g.mutex.Lock()
+	defer g.mutex.Unlock()
+
+	if g.opened {
+		return true
+	}
+
+	klog.V(log.TRACE).Infof("Standby: holding back the write to %s", w)
+
+	key := w.kind.class() + "/" + w.resource.String() + "/" + w.namespace + "/" + w.name + "/" + w.listOptions.LabelSelector
+	if _, ok := g.pending[key]; !ok {
+		g.order = append(g.order, key)
+	}
+
+	g.pending[key] = w
+
+	return false
+}
+
+// open replays the pending writes through the given client, then lets subsequent writes through. Writes made
+// meanwhile wait for the replay so that they aren't overwritten by older ones.
+func (g *writeGate) open(client dynamic.Interface) {
+	g.mutex.Lock()
+	defer g.mutex.Unlock()
+
+	if g.opened {
+		return
+	}
+
+	klog.Infof("Replaying %d writes held back while on standby", len(g.order))
+
+	for _, key := range g.order {
+		if err := replay(client, g.pending[key]); err != nil {
+			klog.Errorf("Error replaying the write to %s: %v", g.pending[key], err)
+		}
+	}
+
+	g.opened = true
+	g.pending = nil
+	g.order = nil
+}
+
+// class groups the kinds of writes which supersede each other.
+func (k writeKind) class() string {
+	switch k {
+	case writeUpdateStatus:
+		return "status"
+	case writeDeleteCollection:
+		return "collection"
+	case writePatch:
+		return "patch"
+	}
+
+	return "object"
+}
+
+// replay makes a held-back write against the current state of the resource, which may have been changed by the
+// previous leader since.
+func replay(client dynamic.Interface, w *write) error {
+	resource := client.Resource(w.resource).Namespace(w.namespace)
+
+	switch w.kind {
+	case writeCreate, writeUpdate:
+		existing, err := resource.Get(context.TODO(), w.name, metav1.GetOptions{})
+		if apierrors.IsNotFound(err) {
+			w.obj.SetResourceVersion("")
+			_, err = resource.Create(context.TODO(), w.obj, metav1.CreateOptions{}, w.subresources...)
+
+			return err
+		}
+
+		if err != nil {
+			return err
+		}
+
+		w.obj.SetResourceVersion(existing.GetResourceVersion())
+		_, err = resource.Update(context.TODO(), w.obj, metav1.UpdateOptions{}, w.subresources...)
+
+		return err
+	case writeUpdateStatus:
+		existing, err := resource.Get(context.TODO(), w.name, metav1.GetOptions{})
+		if err != nil {
+			return ignoreNotFound(err)
+		}
+
+		w.obj.SetResourceVersion(existing.GetResourceVersion())
+		_, err = resource.UpdateStatus(context.TODO(), w.obj, metav1.UpdateOptions{})
+
+		return err
+	case writeDelete:
+		return ignoreNotFound(resource.Delete(context.TODO(), w.name, metav1.DeleteOptions{}, w.subresources...))
+	case writeDeleteCollection:
+		return resource.DeleteCollection(context.TODO(), metav1.DeleteOptions{}, w.listOptions)
+	case writePatch:
+		_, err := resource.Patch(context.TODO(), w.name, w.patchType, w.patch, metav1.PatchOptions{}, w.subresources...)
+		return ignoreNotFound(err)
+	}
+
+	return nil
+}
+
+func ignoreNotFound(err error) error {
+	if apierrors.IsNotFound(err) {
+		return nil
+	}
+
+	return err
+}
+
+// standbyBrokerTransform wraps the transform of resources synced from the local cluster to the broker, dropping them
+// while the given gate is closed. The leader syncs them; a replica promoted after the leader failed resyncs them all.
+func standbyBrokerTransform(gate *writeGate, transform syncer.TransformFunc) syncer.TransformFunc {
+	return func(obj runtime.Object, numRequeues int, op syncer.Operation) (runtime.Object, bool) {
+		if !gate.isOpen() {
+			return nil, false
+		}
+
+		if transform == nil {
+			return obj, false
+		}
+
+		return transform(obj, numRequeues, op)
+	}
+}
+
+// Promote makes a standby agent the active one, making the writes it held back and letting subsequent ones through.
+// It does nothing if the agent wasn't started as a standby.
+func (a *Controller) Promote() {
+	if a.standby == nil {
+		return
+	}
+
+	klog.Info("Promoting the standby agent to active")
+
+	a.standby.open(a.standbyClient)
+
+	// The changes dropped while on standby may not have been synced by the previous leader before it failed
+	a.resyncToBroker("primary", a.serviceImportSyncer, a.endpointSliceSyncer)
+
+	for _, additional := range a.additionalBrokers {
+		a.resyncToBroker(additional.name, additional.serviceImportSyncer, additional.endpointSliceSyncer)
+	}
+}
+
+// resyncToBroker syncs all the local ServiceImports and EndpointSlices to the broker the given syncers sync with.
+func (a *Controller) resyncToBroker(brokerName string, serviceImportSyncer, endpointSliceSyncer *broker.Syncer) {
+	resyncResources(brokerName, serviceImportSyncer, &mcsv1a1.ServiceImport{}, a.localServiceImportTransform)
+	resyncResources(brokerName, endpointSliceSyncer, &discovery.EndpointSlice{}, a.localEndpointSliceTransform)
+}
+
+func resyncResources(brokerName string, brokerSyncer *broker.Syncer, resourceType runtime.Object,
+	transform syncer.TransformFunc) {
+	resources, err := brokerSyncer.ListLocalResources(resourceType)
+	if err != nil {
+		klog.Errorf("Error listing the local %T resources to resync to broker %q: %v", resourceType, brokerName, err)
+		return
+	}
+
+	klog.Infof("Resyncing %d local %T resources to broker %q", len(resources), resourceType, brokerName)
+
+	for _, resource := range resources {
+		obj, _ := transform(resource, 0, syncer.Update)
+		if obj == nil {
+			continue
+		}
+
+		if err := brokerSyncer.GetBrokerFederator().Distribute(obj); err != nil {
+			klog.Errorf("Error resyncing %T to broker %q: %v", obj, brokerName, err)
+		}
+	}
+}
+
+// isActive returns whether the agent may write, i.e. it isn't a standby waiting to be promoted.
+func (a *Controller) isActive() bool {
+	return a.standby == nil || a.standby.isOpen()
+}
@@ -0,0 +1,121 @@
+/*
+SPDX-License-Identifier: Apache-2.0
+
+Copyright Contributors to the Submariner project.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package controller_test
+
+import (
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	"github.com/submariner-io/admiral/pkg/syncer/test"
+	lhconstants "github.com/submariner-io/lighthouse/pkg/constants"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	mcsv1a1 "sigs.k8s.io/mcs-api/pkg/apis/v1alpha1"
+)
+
+var _ = Describe("Standby mode", func() {
+	var t *testDriver
+
+	BeforeEach(func() {
+		t = newTestDiver()
+		t.cluster1.agentSpec.Standby = true
+	})
+
+	JustBeforeEach(func() {
+		t.justBeforeEach()
+	})
+
+	AfterEach(func() {
+		t.afterEach()
+	})
+
+	When("a Service is exported while the agent is on standby", func() {
+		It("should only sync the ServiceImport once the agent is promoted", func() {
+			t.createService()
+			t.createServiceExport()
+
+			time.Sleep(300 * time.Millisecond)
+			t.awaitNoServiceImport(t.cluster1.localServiceImportClient)
+			t.awaitNoServiceImport(t.brokerServiceImportClient)
+
+			t.cluster1.agentController.Promote()
+
+			t.cluster1.awaitServiceImport(t.service, mcsv1a1.ClusterSetIP, t.service.Spec.ClusterIP)
+			t.awaitBrokerServiceImport(mcsv1a1.ClusterSetIP, t.service.Spec.ClusterIP)
+			t.cluster2.awaitServiceImport(t.service, mcsv1a1.ClusterSetIP, t.service.Spec.ClusterIP)
+
+			// Only the latest status update held back is made
+			t.awaitServiceExportStatus(0, newServiceExportCondition(mcsv1a1.ServiceExportValid, corev1.ConditionTrue, ""))
+		})
+	})
+
+	When("a local ServiceImport wasn't synced to the broker before the agent is promoted", func() {
+		It("should sync it once the agent is promoted", func() {
+			// The previous leader created the ServiceImport but failed before syncing it to the broker
+			serviceImport := &mcsv1a1.ServiceImport{
+				ObjectMeta: metav1.ObjectMeta{
+					Name: t.service.Name + "-" + t.service.Namespace + "-" + clusterID1,
+					Annotations: map[string]string{
+						lhconstants.OriginName:      t.service.Name,
+						lhconstants.OriginNamespace: t.service.Namespace,
+					},
+					Labels: map[string]string{
+						lhconstants.LabelSourceName:      t.service.Name,
+						lhconstants.LabelSourceNamespace: t.service.Namespace,
+						lhconstants.LabelSourceCluster:   clusterID1,
+					},
+				},
+				Spec: mcsv1a1.ServiceImportSpec{
+					Type: mcsv1a1.ClusterSetIP,
+					IPs:  []string{t.service.Spec.ClusterIP},
+				},
+				Status: mcsv1a1.ServiceImportStatus{
+					Clusters: []mcsv1a1.ClusterStatus{{Cluster: clusterID1}},
+				},
+			}
+
+			for _, port := range t.service.Spec.Ports {
+				serviceImport.Spec.Ports = append(serviceImport.Spec.Ports, mcsv1a1.ServicePort{
+					Name:        port.Name,
+					Protocol:    port.Protocol,
+					Port:        port.Port,
+					AppProtocol: port.AppProtocol,
+				})
+			}
+
+			test.CreateResource(t.cluster1.localServiceImportClient, serviceImport)
+
+			time.Sleep(300 * time.Millisecond)
+			t.awaitNoServiceImport(t.brokerServiceImportClient)
+
+			t.cluster1.agentController.Promote()
+
+			t.awaitBrokerServiceImport(mcsv1a1.ClusterSetIP, t.service.Spec.ClusterIP)
+		})
+	})
+
+	When("a Service is exported after the agent is promoted", func() {
+		It("should sync the ServiceImport", func() {
+			t.cluster1.agentController.Promote()
+
+			t.createService()
+			t.createServiceExport()
+			t.awaitServiceExported(t.service.Spec.ClusterIP, 0)
+		})
+	})
+})
@@ -0,0 +1,112 @@
+/*
+SPDX-License-Identifier: Apache-2.0
+
+Copyright Contributors to the Submariner project.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package controller_test
+
+import (
+	"context"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/submariner-io/admiral/pkg/syncer/test"
+	corev1 "k8s.io/api/core/v1"
+	discovery "k8s.io/api/discovery/v1beta1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/kubernetes/scheme"
+)
+
+var _ = Describe("EndpointSlice topology", func() {
+	var (
+		t          *testDriver
+		nodeLabels map[string]string
+	)
+
+	BeforeEach(func() {
+		t = newTestDiver()
+	})
+
+	JustBeforeEach(func() {
+		test.CreateResource(t.cluster1.localDynClient.Resource(schema.GroupVersionResource{Version: "v1", Resource: "nodes"}),
+			&corev1.Node{ObjectMeta: metav1.ObjectMeta{Name: nodeName, Labels: nodeLabels}})
+
+		t.justBeforeEach()
+		t.createService()
+		t.createEndpoints()
+		t.createServiceExport()
+	})
+
+	AfterEach(func() {
+		t.afterEach()
+	})
+
+	expectedTopology := map[string]string{
+		corev1.LabelHostname:                nodeName,
+		corev1.LabelZoneFailureDomainStable: "east-1a",
+		corev1.LabelZoneRegionStable:        "east-1",
+	}
+
+	When("the endpoints of an exported Service run on a node with zone and region labels", func() {
+		BeforeEach(func() {
+			nodeLabels = map[string]string{
+				corev1.LabelZoneFailureDomainStable: "east-1a",
+				corev1.LabelZoneRegionStable:        "east-1",
+			}
+		})
+
+		It("should preserve the node name, zone and region in the EndpointSlices", func() {
+			t.awaitServiceExported(t.service.Spec.ClusterIP, 0)
+			awaitEndpointTopology(t.brokerEndpointSliceClient, t.endpoints, expectedTopology)
+			awaitEndpointTopology(t.cluster2.localEndpointSliceClient, t.endpoints, expectedTopology)
+		})
+	})
+
+	When("the endpoints of an exported Service run on a node with the deprecated zone and region labels", func() {
+		BeforeEach(func() {
+			nodeLabels = map[string]string{
+				corev1.LabelZoneFailureDomain: "east-1a",
+				corev1.LabelZoneRegion:        "east-1",
+			}
+		})
+
+		It("should export them under the stable topology keys", func() {
+			t.awaitServiceExported(t.service.Spec.ClusterIP, 0)
+			awaitEndpointTopology(t.cluster2.localEndpointSliceClient, t.endpoints, expectedTopology)
+		})
+	})
+})
+
+func awaitEndpointTopology(client dynamic.ResourceInterface, endpoints *corev1.Endpoints, expected map[string]string) {
+	Eventually(func() map[string]string {
+		obj, err := client.Get(context.TODO(), endpoints.Name+"-"+clusterID1, metav1.GetOptions{})
+		if err != nil {
+			return nil
+		}
+
+		endpointSlice := &discovery.EndpointSlice{}
+		Expect(scheme.Scheme.Convert(obj, endpointSlice, nil)).To(Succeed())
+
+		for i := range endpointSlice.Endpoints {
+			if endpointSlice.Endpoints[i].Addresses[0] == endpoints.Subsets[0].Addresses[1].IP {
+				return endpointSlice.Endpoints[i].Topology
+			}
+		}
+
+		return nil
+	}, 5).Should(Equal(expected))
+}
@@ -19,20 +19,27 @@ package controller
 
 import (
 	"sync"
+	"time"
 
 	"k8s.io/client-go/kubernetes"
 
 	"github.com/submariner-io/admiral/pkg/syncer"
 	"github.com/submariner-io/admiral/pkg/syncer/broker"
+	"github.com/submariner-io/lighthouse/pkg/clockskew"
+	corev1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/api/meta"
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/types"
 	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/tools/cache"
+	"k8s.io/client-go/tools/record"
+	mcsv1a1 "sigs.k8s.io/mcs-api/pkg/apis/v1alpha1"
 )
 
 type Controller struct {
 	clusterID               string
 	globalnetEnabled        bool
+	ipv6Only                bool
 	namespace               string
 	kubeClientSet           kubernetes.Interface
 	serviceExportClient     dynamic.NamespaceableResourceInterface
@@ -42,14 +49,85 @@ type Controller struct {
 	serviceSyncer           syncer.Interface
 	serviceImportController *ServiceImportController
 	ingressIPClient         dynamic.NamespaceableResourceInterface
+	clustersetDomain        string
+	readOnly                bool
+	exported                exportedServices
+	exportAnnotations       exportAnnotations
+	eventBroadcaster        record.EventBroadcaster
+	eventRecorder           record.EventRecorder
+	dryRun                  bool
+	standby                 *writeGate
+	standbyClient           dynamic.Interface
+	hintsInterval           time.Duration
+	externalDNSInterval     time.Duration
+	serviceEntryInterval    time.Duration
+	gatewayBackendsInterval time.Duration
+	brokerStatusInterval    time.Duration
+	// lastBrokerSyncs records when the agent was last found in sync with each broker; it's only accessed when
+	// publishing the brokers' status
+	lastBrokerSyncs map[string]time.Time
+	// clockSkew estimates the offset from the broker's clock, for comparing timestamps written by other clusters
+	clockSkew   *clockskew.Estimator
+	localClient dynamic.Interface
+	restMapper  meta.RESTMapper
+	// additionalBrokers sync the same resources with the brokers of the other clustersets the cluster belongs to
+	additionalBrokers []*brokerSyncers
+	// localServiceImportTransform and localEndpointSliceTransform transform the local resources synced to the brokers,
+	// so that they can be resynced when a standby agent is promoted
+	localServiceImportTransform syncer.TransformFunc
+	localEndpointSliceTransform syncer.TransformFunc
 }
 
 type AgentSpecification struct {
 	ClusterID        string
 	Namespace        string
-	GlobalnetEnabled bool `split_words:"true"`
+	GlobalnetEnabled bool   `split_words:"true"`
+	ClustersetDomain string `split_words:"true"`
+	// IPv6Only restricts the clusterset to IPv6: only IPv6 service and endpoint addresses are exported, and only
+	// ServiceImports and EndpointSlices carrying IPv6 addresses are imported
+	IPv6Only bool `envconfig:"IPV6_ONLY"`
+	// ReadOnly disables the export path so the cluster only consumes services exported by other clusters
+	ReadOnly bool `split_words:"true"`
+	// DryRun logs the resources the agent would create, update or delete locally and on the broker instead of writing them
+	DryRun bool `split_words:"true"`
+	// Standby starts the agent with its caches warm but its writes held back until it's promoted, for leader election
+	Standby bool
+	// HintsInterval is the interval at which the DNS pre-resolution hints ConfigMaps are refreshed; 0 disables them
+	HintsInterval time.Duration `split_words:"true"`
+	// ExternalDNSInterval is the interval at which the external-dns DNSEndpoints publishing the services requesting it
+	// are refreshed; 0 disables them
+	ExternalDNSInterval time.Duration `split_words:"true"`
+	// ServiceEntryInterval is the interval at which the Istio ServiceEntries of the imported services are refreshed;
+	// 0 disables them
+	ServiceEntryInterval time.Duration `split_words:"true"`
+	// GatewayBackendsInterval is the interval at which the Services and EndpointSlices of the imported services
+	// referenced by Gateway API routes are refreshed; 0 disables them
+	GatewayBackendsInterval time.Duration `split_words:"true"`
+	// BrokerStatusInterval is the interval at which the BrokerSyncStatus resources describing the agent's connectivity
+	// with its brokers are refreshed; 0 disables them
+	BrokerStatusInterval time.Duration `split_words:"true"`
+	// MetricsNamespaces, if set, limits the namespaces reported individually in the per-namespace metrics; the others
+	// are aggregated under the "other" namespace label
+	MetricsNamespaces []string `split_words:"true"`
+	// ImportNamespaces, if set, limits the services imported from the broker to those exported from these namespaces,
+	// including the services this cluster exports
+	ImportNamespaces []string `split_words:"true"`
+	// ImportClusters, if set, limits the services imported from the broker to those exported by these clusters, besides
+	// this one
+	ImportClusters []string `split_words:"true"`
+	// AdditionalBrokers are the brokers, besides the one configured by the BROKER_K8S environment variables, with
+	// which services are also exported and imported
+	AdditionalBrokers []BrokerSpec `ignored:"true"`
 }
 
+// exportStatusUpdater records a condition on the ServiceExport with the given name and namespace.
+type exportStatusUpdater func(name, namespace string, condType mcsv1a1.ServiceExportConditionType,
+	status corev1.ConditionStatus, reason, msg string)
+
+// exportStatusClearer marks the ServiceExport with the given name and namespace valid again if it was last flagged
+// invalid for the given reason, which no longer applies.
+type exportStatusClearer func(name, namespace, reason string)
+
 // The ServiceImportController listens for ServiceImport resources created in the target namespace
 // and creates an EndpointController in response. The EndpointController will use the app label as filter
 // to listen only for the endpoints event related to ServiceImport created
@@ -58,10 +136,14 @@ type ServiceImportController struct {
 	localClient         dynamic.Interface
 	restMapper          meta.RESTMapper
 	serviceImportSyncer syncer.Interface
+	serviceImportClient dynamic.NamespaceableResourceInterface
 	endpointControllers sync.Map
 	clusterID           string
 	scheme              *runtime.Scheme
 	globalnetEnabled    bool
+	ipv6Only            bool
+	updateExportStatus  exportStatusUpdater
+	clearExportStatus   exportStatusClearer
 }
 
 // Each EndpointController listens for the endpoints that backs a service and have a ServiceImport
@@ -73,9 +155,21 @@ type EndpointController struct {
 	serviceImportName            string
 	serviceName                  string
 	serviceImportSourceNameSpace string
+	importNamespace              string
 	stopCh                       chan struct{}
 	localClient                  dynamic.Interface
 	ingressIPClient              dynamic.NamespaceableResourceInterface
+	podClient                    dynamic.NamespaceableResourceInterface
+	nodeClient                   dynamic.NamespaceableResourceInterface
 	isHeadless                   bool
+	publishNotReady              bool
 	globalnetEnabled             bool
+	ipv6Only                     bool
+	updateExportStatus           exportStatusUpdater
+	clearExportStatus            exportStatusClearer
+
+	// pods caches the pods of the service's namespace, if it's headless, to find those using the host's network
+	pods cache.Store
+	// cleared holds, by reason, whether the ServiceExport's conditions flagged for that reason have been cleared
+	cleared sync.Map
 }
@@ -21,27 +21,44 @@ import (
 	"context"
 	"flag"
 	"fmt"
+	"io/ioutil"
 	"net/http"
 	"os"
+	"time"
 
 	"github.com/kelseyhightower/envconfig"
 	"github.com/prometheus/client_golang/prometheus/promhttp"
 	"github.com/submariner-io/admiral/pkg/syncer/broker"
 	"github.com/submariner-io/admiral/pkg/util"
 	"github.com/submariner-io/lighthouse/pkg/agent/controller"
+	lhconstants "github.com/submariner-io/lighthouse/pkg/constants"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/client-go/dynamic"
 	"k8s.io/client-go/kubernetes"
 	"k8s.io/client-go/kubernetes/scheme"
 
 	"k8s.io/client-go/tools/clientcmd"
+	"k8s.io/client-go/tools/leaderelection"
+	"k8s.io/client-go/tools/leaderelection/resourcelock"
 	"k8s.io/klog"
 	"sigs.k8s.io/controller-runtime/pkg/manager/signals"
 	mcsv1a1 "sigs.k8s.io/mcs-api/pkg/apis/v1alpha1"
 )
 
 var (
-	masterURL  string
-	kubeConfig string
+	masterURL        string
+	kubeConfig       string
+	clustersetDomain string
+	dryRun           bool
+	leaderElect      bool
+	leaseDuration    time.Duration
+	brokersFile      string
+)
+
+const (
+	leaseName     = "lighthouse-agent"
+	renewDeadline = 10 * time.Second
+	retryPeriod   = 2 * time.Second
 )
 
 func main() {
@@ -67,6 +84,32 @@ func main() {
 		klog.Fatal(err)
 	}
 
+	if clustersetDomain != "" {
+		agentSpec.ClustersetDomain = clustersetDomain
+	}
+
+	if dryRun {
+		agentSpec.DryRun = true
+	}
+
+	if leaderElect && leaseDuration <= renewDeadline {
+		klog.Fatalf("The leader election lease duration must be greater than %v", renewDeadline)
+	}
+
+	agentSpec.Standby = leaderElect
+
+	if brokersFile != "" {
+		data, err := ioutil.ReadFile(brokersFile)
+		if err != nil {
+			klog.Fatalf("Error reading the additional brokers file: %v", err)
+		}
+
+		agentSpec.AdditionalBrokers, err = controller.ParseBrokerSpecs(data)
+		if err != nil {
+			klog.Fatal(err)
+		}
+	}
+
 	klog.Infof("Arguments: %v", os.Args)
 	klog.Infof("AgentSpec: %v", agentSpec)
 
@@ -119,6 +162,10 @@ func main() {
 		klog.Fatalf("Failed to start lighthouse agent: %v", err)
 	}
 
+	if leaderElect {
+		go runLeaderElection(kubeClientSet, agentSpec.Namespace, lightHouseAgent.Promote, stopCh)
+	}
+
 	<-stopCh
 
 	klog.Info("All controllers stopped or exited. Stopping main loop")
@@ -132,6 +179,66 @@ func init() {
 	flag.StringVar(&kubeConfig, "kubeconfig", "", "Path to a kubeconfig. Only required if out-of-cluster.")
 	flag.StringVar(&masterURL, "master", "",
 		"The address of the Kubernetes API server. Overrides any value in kubeconfig. Only required if out-of-cluster.")
+	flag.StringVar(&clustersetDomain, "clusterset-domain", "",
+		"The clusterset domain suffix used for exported services. Overrides SUBMARINER_CLUSTERSET_DOMAIN; defaults to "+
+			lhconstants.DefaultClustersetDomain+".")
+	flag.BoolVar(&dryRun, "dry-run", false,
+		"Log the ServiceImports and EndpointSlices the agent would create, update or delete on the broker and the local "+
+			"cluster instead of writing them. Overrides SUBMARINER_DRY_RUN.")
+	flag.BoolVar(&leaderElect, "leader-elect", false,
+		"Elect a leader among the agent replicas using a Lease in the agent's namespace. Standby replicas keep their caches "+
+			"warm and take over syncing when the leader fails.")
+	flag.StringVar(&brokersFile, "additional-brokers", "",
+		"Path to a JSON list of additional brokers to export services to and import them from, each with a name, apiServer, "+
+			"apiServerToken, remoteNamespace and optional ca and insecure fields, for clusters in several clustersets.")
+	flag.DurationVar(&leaseDuration, "leader-elect-lease-duration", 15*time.Second,
+		"How long standby replicas wait after the leader last renewed its Lease before taking over.")
+}
+
+// runLeaderElection promotes the agent once it acquires the Lease, and exits if it loses it so that it restarts on
+// standby. The Lease is released on shutdown so that a standby replica takes over without waiting for it to expire.
+func runLeaderElection(kubeClientSet kubernetes.Interface, namespace string, promote func(), stopCh <-chan struct{}) {
+	id, err := os.Hostname()
+	if err != nil {
+		klog.Fatalf("Error retrieving the hostname for leader election: %v", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+
+	go func() {
+		<-stopCh
+		cancel()
+	}()
+
+	leaderelection.RunOrDie(ctx, leaderelection.LeaderElectionConfig{
+		Lock: &resourcelock.LeaseLock{
+			LeaseMeta:  metav1.ObjectMeta{Name: leaseName, Namespace: namespace},
+			Client:     kubeClientSet.CoordinationV1(),
+			LockConfig: resourcelock.ResourceLockConfig{Identity: id},
+		},
+		ReleaseOnCancel: true,
+		LeaseDuration:   leaseDuration,
+		RenewDeadline:   renewDeadline,
+		RetryPeriod:     retryPeriod,
+		Callbacks: leaderelection.LeaderCallbacks{
+			OnStartedLeading: func(ctx context.Context) {
+				klog.Infof("Acquired the %q Lease as %q", leaseName, id)
+				promote()
+			},
+			OnStoppedLeading: func() {
+				if ctx.Err() == nil {
+					klog.Fatalf("Lost the %q Lease", leaseName)
+				}
+
+				klog.Infof("Released the %q Lease", leaseName)
+			},
+			OnNewLeader: func(identity string) {
+				if identity != id {
+					klog.Infof("The agent leader is now %q", identity)
+				}
+			},
+		},
+	})
 }
 
 func startHTTPServer() *http.Server {
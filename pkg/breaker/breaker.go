@@ -0,0 +1,163 @@
+/*
+SPDX-License-Identifier: Apache-2.0
+
+Copyright Contributors to the Submariner project.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package breaker
+
+import (
+	"sync"
+	"time"
+
+	"k8s.io/klog"
+)
+
+const (
+	DefaultThreshold = 5
+	DefaultWindow    = 30 * time.Second
+	DefaultCooldown  = 30 * time.Second
+)
+
+// Breaker tracks connection failures reported per (service, cluster) and opens the circuit for a service in a cluster
+// when Threshold failures are reported within Window. While open, Allow returns false for Cooldown, after which the
+// failure count starts afresh. This is independent of gateway connectivity, which may look healthy while a particular
+// service is unreachable. Circuits which are closed and have no failures within Window are evicted as failures are
+// reported, so that those of services which stopped failing without a success being reported don't accumulate.
+type Breaker struct {
+	Threshold int
+	Window    time.Duration
+	Cooldown  time.Duration
+	mutex     sync.Mutex
+	circuits  map[circuitKey]*circuit
+	lastSweep time.Time
+}
+
+type circuitKey struct {
+	name      string
+	namespace string
+	clusterID string
+}
+
+type circuit struct {
+	failures  []time.Time
+	openUntil time.Time
+}
+
+func New(threshold int, window, cooldown time.Duration) *Breaker {
+	return &Breaker{
+		Threshold: threshold,
+		Window:    window,
+		Cooldown:  cooldown,
+		circuits:  make(map[circuitKey]*circuit),
+	}
+}
+
+// RecordFailure records a failure to connect to the given service in the given cluster.
+func (b *Breaker) RecordFailure(name, namespace, clusterID string) {
+	key := circuitKey{name: name, namespace: namespace, clusterID: clusterID}
+	now := time.Now()
+
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	b.sweep(now)
+
+	c, ok := b.circuits[key]
+	if !ok {
+		c = &circuit{}
+		b.circuits[key] = c
+	}
+
+	if now.Before(c.openUntil) {
+		return
+	}
+
+	c.failures = append(pruneFailures(c.failures, now.Add(-b.Window)), now)
+
+	if len(c.failures) >= b.Threshold {
+		klog.Warningf("Opening the circuit for service \"%s/%s\" in cluster %q for %v after %d failures", namespace, name,
+			clusterID, b.Cooldown, len(c.failures))
+
+		c.openUntil = now.Add(b.Cooldown)
+		c.failures = nil
+	}
+}
+
+// RecordSuccess records a successful connection to the given service in the given cluster, closing its circuit.
+func (b *Breaker) RecordSuccess(name, namespace, clusterID string) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	delete(b.circuits, circuitKey{name: name, namespace: namespace, clusterID: clusterID})
+}
+
+// Allow returns false if the circuit for the given service in the given cluster is open.
+func (b *Breaker) Allow(name, namespace, clusterID string) bool {
+	if b == nil {
+		return true
+	}
+
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	c, ok := b.circuits[circuitKey{name: name, namespace: namespace, clusterID: clusterID}]
+	if !ok {
+		return true
+	}
+
+	now := time.Now()
+	if now.Before(c.openUntil) {
+		return false
+	}
+
+	if len(pruneFailures(c.failures, now.Add(-b.Window))) == 0 {
+		delete(b.circuits, circuitKey{name: name, namespace: namespace, clusterID: clusterID})
+	}
+
+	return true
+}
+
+// Circuits returns the number of circuits tracked.
+func (b *Breaker) Circuits() int {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	return len(b.circuits)
+}
+
+// sweep evicts the circuits which are closed and have no failures within the window, at most once per window.
+func (b *Breaker) sweep(now time.Time) {
+	if now.Sub(b.lastSweep) < b.Window {
+		return
+	}
+
+	b.lastSweep = now
+	since := now.Add(-b.Window)
+
+	for key, c := range b.circuits {
+		if !now.Before(c.openUntil) && len(pruneFailures(c.failures, since)) == 0 {
+			delete(b.circuits, key)
+		}
+	}
+}
+
+func pruneFailures(failures []time.Time, since time.Time) []time.Time {
+	i := 0
+	for i < len(failures) && failures[i].Before(since) {
+		i++
+	}
+
+	return failures[i:]
+}
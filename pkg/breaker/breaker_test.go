@@ -0,0 +1,114 @@
+/*
+SPDX-License-Identifier: Apache-2.0
+
+Copyright Contributors to the Submariner project.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package breaker_test
+
+import (
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/submariner-io/lighthouse/pkg/breaker"
+)
+
+var _ = Describe("Breaker", func() {
+	const (
+		service1   = "service1"
+		namespace1 = "namespace1"
+		clusterID1 = "clusterID1"
+		clusterID2 = "clusterID2"
+		threshold  = 3
+		cooldown   = 300 * time.Millisecond
+	)
+
+	var b *breaker.Breaker
+
+	BeforeEach(func() {
+		b = breaker.New(threshold, time.Minute, cooldown)
+	})
+
+	recordFailures := func(n int, clusterID string) {
+		for i := 0; i < n; i++ {
+			b.RecordFailure(service1, namespace1, clusterID)
+		}
+	}
+
+	When("no failures are reported", func() {
+		It("should allow the cluster", func() {
+			Expect(b.Allow(service1, namespace1, clusterID1)).To(BeTrue())
+		})
+	})
+
+	When("fewer failures than the threshold are reported", func() {
+		It("should allow the cluster", func() {
+			recordFailures(threshold-1, clusterID1)
+			Expect(b.Allow(service1, namespace1, clusterID1)).To(BeTrue())
+		})
+	})
+
+	When("failures reach the threshold", func() {
+		BeforeEach(func() {
+			recordFailures(threshold, clusterID1)
+		})
+
+		It("should not allow the cluster until the cooldown expires", func() {
+			Expect(b.Allow(service1, namespace1, clusterID1)).To(BeFalse())
+			Expect(b.Allow(service1, namespace1, clusterID2)).To(BeTrue())
+			Expect(b.Allow("service2", namespace1, clusterID1)).To(BeTrue())
+
+			Eventually(func() bool {
+				return b.Allow(service1, namespace1, clusterID1)
+			}, 2*cooldown).Should(BeTrue())
+
+			recordFailures(threshold-1, clusterID1)
+			Expect(b.Allow(service1, namespace1, clusterID1)).To(BeTrue())
+		})
+
+		It("should allow the cluster once a success is reported", func() {
+			b.RecordSuccess(service1, namespace1, clusterID1)
+			Expect(b.Allow(service1, namespace1, clusterID1)).To(BeTrue())
+		})
+	})
+
+	When("failures are spread beyond the window", func() {
+		It("should allow the cluster", func() {
+			b = breaker.New(threshold, 100*time.Millisecond, cooldown)
+			recordFailures(threshold-1, clusterID1)
+			time.Sleep(200 * time.Millisecond)
+			recordFailures(1, clusterID1)
+			Expect(b.Allow(service1, namespace1, clusterID1)).To(BeTrue())
+		})
+
+		It("should evict the circuits without failures within the window", func() {
+			b = breaker.New(threshold, 100*time.Millisecond, cooldown)
+			recordFailures(1, clusterID1)
+			recordFailures(1, clusterID2)
+			Expect(b.Circuits()).To(Equal(2))
+
+			time.Sleep(200 * time.Millisecond)
+			b.RecordFailure("service2", namespace1, clusterID1)
+			Expect(b.Circuits()).To(Equal(1))
+		})
+	})
+
+	When("the breaker is nil", func() {
+		It("should allow the cluster", func() {
+			var nilBreaker *breaker.Breaker
+			Expect(nilBreaker.Allow(service1, namespace1, clusterID1)).To(BeTrue())
+		})
+	})
+})
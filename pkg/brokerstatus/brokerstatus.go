@@ -0,0 +1,152 @@
+/*
+SPDX-License-Identifier: Apache-2.0
+
+Copyright Contributors to the Submariner project.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package brokerstatus
+
+import (
+	"context"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/watch"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/tools/cache"
+	"k8s.io/klog"
+)
+
+const (
+	Kind = "BrokerSyncStatus"
+	// DefaultNamespace is the namespace the agent usually runs in, and publishes its BrokerSyncStatus resources to
+	DefaultNamespace = "submariner-operator"
+)
+
+// GVR identifies the BrokerSyncStatus resources the agent maintains in its namespace, one per broker, named after it.
+var GVR = schema.GroupVersionResource{
+	Group:    "lighthouse.submariner.io",
+	Version:  "v1alpha1",
+	Resource: "brokersyncstatuses",
+}
+
+// Status describes the agent's connectivity with a broker.
+type Status struct {
+	// Connected is whether the broker could be reached the last time the agent checked
+	Connected bool
+	// InSync is whether the broker could be reached and the agent's watches of its resources were healthy
+	InSync bool
+	// LastSuccessfulSync is the last time the agent was found in sync, zero if it never was
+	LastSuccessfulSync time.Time
+	// LastError describes why the broker couldn't be reached, if it couldn't
+	LastError string
+	// ServiceImports and EndpointSlices count the ServiceImports and EndpointSlices synced with the clusterset
+	ServiceImports int64
+	EndpointSlices int64
+}
+
+// SetStatus sets the status of the given BrokerSyncStatus resource.
+func SetStatus(obj *unstructured.Unstructured, status *Status) error {
+	fields := map[string]interface{}{
+		"connected":      status.Connected,
+		"inSync":         status.InSync,
+		"serviceImports": status.ServiceImports,
+		"endpointSlices": status.EndpointSlices,
+		"lastUpdated":    time.Now().UTC().Format(time.RFC3339),
+	}
+
+	if !status.LastSuccessfulSync.IsZero() {
+		fields["lastSuccessfulSync"] = status.LastSuccessfulSync.UTC().Format(time.RFC3339)
+	}
+
+	if status.LastError != "" {
+		fields["lastError"] = status.LastError
+	}
+
+	return unstructured.SetNestedMap(obj.Object, fields, "status")
+}
+
+// GetStatus returns the status of the given BrokerSyncStatus resource.
+func GetStatus(obj *unstructured.Unstructured) *Status {
+	status := &Status{}
+
+	status.Connected, _, _ = unstructured.NestedBool(obj.Object, "status", "connected")
+	status.InSync, _, _ = unstructured.NestedBool(obj.Object, "status", "inSync")
+	status.LastError, _, _ = unstructured.NestedString(obj.Object, "status", "lastError")
+	status.ServiceImports, _, _ = unstructured.NestedInt64(obj.Object, "status", "serviceImports")
+	status.EndpointSlices, _, _ = unstructured.NestedInt64(obj.Object, "status", "endpointSlices")
+
+	if lastSync, found, _ := unstructured.NestedString(obj.Object, "status", "lastSuccessfulSync"); found {
+		status.LastSuccessfulSync, _ = time.Parse(time.RFC3339, lastSync)
+	}
+
+	return status
+}
+
+// Watcher tracks the BrokerSyncStatus resources the agent publishes, so that consumers can tell whether the imported
+// services are up to date.
+type Watcher struct {
+	client   dynamic.ResourceInterface
+	store    cache.Store
+	informer cache.Controller
+}
+
+func NewWatcher(client dynamic.Interface, namespace string) *Watcher {
+	w := &Watcher{client: client.Resource(GVR).Namespace(namespace)}
+
+	w.store, w.informer = cache.NewInformer(&cache.ListWatch{
+		ListFunc: func(options metav1.ListOptions) (runtime.Object, error) {
+			return w.client.List(context.TODO(), options)
+		},
+		WatchFunc: func(options metav1.ListOptions) (watch.Interface, error) {
+			return w.client.Watch(context.TODO(), options)
+		},
+	}, &unstructured.Unstructured{}, 0, cache.ResourceEventHandlerFuncs{})
+
+	return w
+}
+
+// Start starts watching the BrokerSyncStatus resources. It doesn't wait for them to be listed, so that an agent which
+// doesn't publish them, or a missing CRD, doesn't hold back the caller; until they are, the agent is considered in sync.
+func (w *Watcher) Start(stopCh <-chan struct{}) {
+	klog.Infof("Watching the broker sync status")
+
+	go w.informer.Run(stopCh)
+}
+
+// OutOfSyncSince returns whether the agent reports it's out of sync with any of its brokers, and since when, i.e. the
+// earliest last successful sync of those it's out of sync with.
+func (w *Watcher) OutOfSyncSince() (time.Time, bool) {
+	var since time.Time
+
+	outOfSync := false
+
+	for _, obj := range w.store.List() {
+		status := GetStatus(obj.(*unstructured.Unstructured))
+		if status.InSync {
+			continue
+		}
+
+		if !outOfSync || status.LastSuccessfulSync.Before(since) {
+			since = status.LastSuccessfulSync
+		}
+
+		outOfSync = true
+	}
+
+	return since, outOfSync
+}
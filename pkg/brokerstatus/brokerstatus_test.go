@@ -0,0 +1,118 @@
+/*
+SPDX-License-Identifier: Apache-2.0
+
+Copyright Contributors to the Submariner project.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package brokerstatus_test
+
+import (
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/submariner-io/lighthouse/pkg/brokerstatus"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	fakeClient "k8s.io/client-go/dynamic/fake"
+)
+
+const namespace = "submariner"
+
+var _ = Describe("BrokerSyncStatus", func() {
+	newStatus := func(name string, status *brokerstatus.Status) *unstructured.Unstructured {
+		obj := &unstructured.Unstructured{}
+		obj.SetAPIVersion(brokerstatus.GVR.GroupVersion().String())
+		obj.SetKind(brokerstatus.Kind)
+		obj.SetName(name)
+		obj.SetNamespace(namespace)
+		Expect(brokerstatus.SetStatus(obj, status)).To(Succeed())
+
+		return obj
+	}
+
+	lastSync := time.Date(2026, 10, 1, 12, 0, 0, 0, time.UTC)
+
+	When("a status is set", func() {
+		It("should be read back", func() {
+			status := &brokerstatus.Status{Connected: true, LastSuccessfulSync: lastSync, LastError: "timeout",
+				ServiceImports: 3, EndpointSlices: 5}
+
+			Expect(brokerstatus.GetStatus(newStatus("primary", status))).To(Equal(status))
+		})
+	})
+
+	Context("Watcher", func() {
+		var (
+			watcher *brokerstatus.Watcher
+			stopCh  chan struct{}
+			objs    []runtime.Object
+		)
+
+		BeforeEach(func() {
+			objs = nil
+			stopCh = make(chan struct{})
+		})
+
+		JustBeforeEach(func() {
+			watcher = brokerstatus.NewWatcher(fakeClient.NewSimpleDynamicClient(runtime.NewScheme(), objs...), namespace)
+			watcher.Start(stopCh)
+		})
+
+		AfterEach(func() {
+			close(stopCh)
+		})
+
+		When("no status is published", func() {
+			It("should report the agent in sync", func() {
+				Consistently(func() bool {
+					_, outOfSync := watcher.OutOfSyncSince()
+					return outOfSync
+				}).Should(BeFalse())
+			})
+		})
+
+		When("the agent is in sync with all its brokers", func() {
+			BeforeEach(func() {
+				objs = append(objs, newStatus("primary", &brokerstatus.Status{Connected: true, InSync: true,
+					LastSuccessfulSync: lastSync}))
+			})
+
+			It("should report the agent in sync", func() {
+				Consistently(func() bool {
+					_, outOfSync := watcher.OutOfSyncSince()
+					return outOfSync
+				}).Should(BeFalse())
+			})
+		})
+
+		When("the agent is out of sync with some of its brokers", func() {
+			BeforeEach(func() {
+				objs = append(objs,
+					newStatus("primary", &brokerstatus.Status{InSync: true, LastSuccessfulSync: lastSync.Add(time.Hour)}),
+					newStatus("other", &brokerstatus.Status{LastSuccessfulSync: lastSync}))
+			})
+
+			It("should report it out of sync since its earliest last successful sync", func() {
+				Eventually(func() bool {
+					_, outOfSync := watcher.OutOfSyncSince()
+					return outOfSync
+				}).Should(BeTrue())
+
+				since, _ := watcher.OutOfSyncSince()
+				Expect(since).To(Equal(lastSync))
+			})
+		})
+	})
+})
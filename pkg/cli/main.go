@@ -0,0 +1,476 @@
+/*
+SPDX-License-Identifier: Apache-2.0
+
+Copyright Contributors to the Submariner project.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package main
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/submariner-io/lighthouse/pkg/diagnose"
+	"github.com/submariner-io/lighthouse/pkg/gather"
+	"github.com/submariner-io/lighthouse/pkg/resolver"
+	"github.com/submariner-io/lighthouse/pkg/serviceimport"
+	"github.com/submariner-io/lighthouse/pkg/verify"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/tools/clientcmd"
+)
+
+const usage = `Usage: lighthouse <command> [flags]
+
+Commands:
+  gather    Gather the Lighthouse state of a cluster, its broker and its DNS server into an archive for support bundles
+  hot       List the most queried names, whose answers the DNS server prefetches
+  imports   List the services the DNS server resolves from
+  resolve   Show the endpoints the DNS server resolves a service to
+  verify    Check that the services of a namespace resolve through a DNS server
+  why-not   Explain how the DNS server resolves a service, or why it doesn't
+`
+
+const (
+	defaultEndpoint = "localhost:9053"
+	callTimeout     = 10 * time.Second
+)
+
+func main() {
+	if len(os.Args) < 2 {
+		fmt.Fprint(os.Stderr, usage)
+		os.Exit(2)
+	}
+
+	var err error
+
+	switch os.Args[1] {
+	case "gather":
+		err = runGather(os.Args[2:])
+	case "hot":
+		err = runHot(os.Args[2:])
+	case "imports":
+		err = runImports(os.Args[2:])
+	case "resolve":
+		err = runResolve(os.Args[2:])
+	case "verify":
+		err = runVerify(os.Args[2:])
+	case "why-not":
+		err = runWhyNot(os.Args[2:])
+	default:
+		fmt.Fprintf(os.Stderr, "Unknown command %q\n\n%s", os.Args[1], usage)
+		os.Exit(2)
+	}
+
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+func runGather(args []string) error {
+	flags := flag.NewFlagSet("gather", flag.ExitOnError)
+	kubeConfig := flags.String("kubeconfig", os.Getenv("KUBECONFIG"), "Path to the kubeconfig of the cluster to gather.")
+	brokerKubeConfig := flags.String("broker-kubeconfig", "",
+		"Path to the kubeconfig of the broker cluster. The broker view is omitted if not set.")
+	output := flags.String("output", "lighthouse-gather.tar.gz", "Path of the archive to write.")
+	redactNames := flags.String("redact-names", "", "Comma-separated strings, e.g. cluster or namespace names, to redact.")
+	// The state the DNS server resolves from is only gathered if its grpc-endpoint is given
+	endpoint := addEndpointFlags(flags, "")
+	options := gather.Options{}
+
+	flags.StringVar(&options.Namespace, "namespace", gather.DefaultNamespace,
+		"Namespace in which the Lighthouse components run.")
+	flags.StringVar(&options.BrokerNamespace, "broker-namespace", gather.DefaultBrokerNamespace, "Namespace of the broker.")
+	flags.StringVar(&options.AgentSelector, "agent-selector", gather.DefaultAgentSelector,
+		"Label selector for the Lighthouse agent pods.")
+	flags.StringVar(&options.CoreDNSSelector, "coredns-selector", gather.DefaultCoreDNSSelector,
+		"Label selector for the Lighthouse CoreDNS pods.")
+	flags.Int64Var(&options.LogLines, "log-lines", gather.DefaultLogLines, "Number of recent log lines to gather per pod.")
+	flags.BoolVar(&options.RedactIPs, "redact-ips", false, "Replace IP addresses with placeholders.")
+
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	if *redactNames != "" {
+		options.RedactNames = strings.Split(*redactNames, ",")
+	}
+
+	cfg, err := clientcmd.BuildConfigFromFlags("", *kubeConfig)
+	if err != nil {
+		return fmt.Errorf("error building kubeconfig: %v", err)
+	}
+
+	kubeClient, err := kubernetes.NewForConfig(cfg)
+	if err != nil {
+		return fmt.Errorf("error creating client set: %v", err)
+	}
+
+	dynClient, err := dynamic.NewForConfig(cfg)
+	if err != nil {
+		return fmt.Errorf("error creating dynamic client: %v", err)
+	}
+
+	var brokerClient dynamic.Interface
+
+	if *brokerKubeConfig != "" {
+		brokerCfg, err := clientcmd.BuildConfigFromFlags("", *brokerKubeConfig)
+		if err != nil {
+			return fmt.Errorf("error building broker kubeconfig: %v", err)
+		}
+
+		brokerClient, err = dynamic.NewForConfig(brokerCfg)
+		if err != nil {
+			return fmt.Errorf("error creating broker dynamic client: %v", err)
+		}
+	}
+
+	if endpoint.address != "" {
+		client, err := endpoint.dial()
+		if err != nil {
+			return err
+		}
+
+		defer client.Close()
+
+		options.DNSServer = client
+	}
+
+	file, err := os.Create(*output)
+	if err != nil {
+		return fmt.Errorf("error creating %q: %v", *output, err)
+	}
+
+	defer file.Close()
+
+	if err := gather.New(options, kubeClient, dynClient, brokerClient).Write(context.TODO(), file); err != nil {
+		return err
+	}
+
+	fmt.Printf("Lighthouse state gathered in %s\n", *output)
+
+	return nil
+}
+
+func runHot(args []string) error {
+	flags := flag.NewFlagSet("hot", flag.ExitOnError)
+	endpoint := addEndpointFlags(flags, defaultEndpoint)
+
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	state, err := dump(endpoint)
+	if err != nil {
+		return err
+	}
+
+	if len(state.HotNames) == 0 {
+		fmt.Println("No hot names; prefetching may be disabled")
+		return nil
+	}
+
+	for _, name := range state.HotNames {
+		fmt.Printf("%s %s: %d queries\n", name.Name, name.Type, name.Queries)
+	}
+
+	return nil
+}
+
+func runImports(args []string) error {
+	flags := flag.NewFlagSet("imports", flag.ExitOnError)
+	endpoint := addEndpointFlags(flags, defaultEndpoint)
+
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	state, err := dump(endpoint)
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("Local cluster: %s\n", state.LocalClusterID)
+
+	for _, cluster := range state.Clusters {
+		fmt.Printf("Cluster %s: connected=%t latency=%s\n", cluster.ID, cluster.Connected, cluster.Latency)
+	}
+
+	for _, services := range [][]serviceimport.ServiceState{state.ServiceImports, state.EndpointSlices} {
+		for i := range services {
+			printService(&services[i])
+		}
+	}
+
+	return nil
+}
+
+func runResolve(args []string) error {
+	flags := flag.NewFlagSet("resolve", flag.ExitOnError)
+	endpoint := addEndpointFlags(flags, defaultEndpoint)
+
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	if flags.NArg() != 1 {
+		return fmt.Errorf("a service name, as service.namespace, is required")
+	}
+
+	client, err := endpoint.dial()
+	if err != nil {
+		return err
+	}
+
+	defer client.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), callTimeout)
+	defer cancel()
+
+	found, records, err := client.Resolve(ctx, flags.Arg(0))
+	if err != nil {
+		return fmt.Errorf("error resolving %q: %v", flags.Arg(0), err)
+	}
+
+	if !found {
+		fmt.Printf("%s isn't known to the DNS server\n", flags.Arg(0))
+		return nil
+	}
+
+	for i := range records {
+		printRecord(&records[i])
+	}
+
+	return nil
+}
+
+func runVerify(args []string) error {
+	flags := flag.NewFlagSet("verify", flag.ExitOnError)
+	options := verify.Options{}
+
+	flags.StringVar(&options.Server, "server", "", "Address of the DNS server to query, the first nameserver of "+
+		verify.DefaultResolvConf+" if not set.")
+	flags.StringVar(&options.Domain, "domain", "", "Clusterset domain.")
+	flags.StringVar(&options.Namespace, "namespace", "", "Namespace of the services.")
+	flags.StringVar(&options.Service, "service", "", "Exported ClusterSetIP service to check.")
+	flags.StringVar(&options.HeadlessService, "headless-service", "", "Exported headless service to check.")
+	flags.StringVar(&options.ClusterID, "cluster-id", "", "ID of a cluster exporting the ClusterSetIP service.")
+	flags.DurationVar(&options.Timeout, "timeout", verify.DefaultTimeout, "Timeout of each query.")
+
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	verifier, err := verify.New(options)
+	if err != nil {
+		return err
+	}
+
+	failed := 0
+
+	for _, result := range verifier.Run(context.Background()) {
+		switch {
+		case result.Skipped:
+			fmt.Printf("SKIP %s: %s\n", result.Check, result.Message)
+		case result.Passed:
+			fmt.Printf("PASS %s: %s %s %s in %v\n", result.Check, result.Name, result.Type, result.Rcode, result.Duration)
+		default:
+			failed++
+
+			fmt.Printf("FAIL %s: %s %s: %s\n", result.Check, result.Name, result.Type, result.Message)
+		}
+
+		for _, answer := range result.Answers {
+			fmt.Printf("     %s\n", answer)
+		}
+	}
+
+	if failed > 0 {
+		return fmt.Errorf("%d check(s) failed", failed)
+	}
+
+	return nil
+}
+
+func runWhyNot(args []string) error {
+	flags := flag.NewFlagSet("why-not", flag.ExitOnError)
+	endpoint := addEndpointFlags(flags, defaultEndpoint)
+	kubeConfig := flags.String("kubeconfig", os.Getenv("KUBECONFIG"),
+		"Path to the kubeconfig of the DNS server's cluster. The cluster's resources aren't checked if not set.")
+
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	labels := strings.Split(strings.TrimSuffix(flags.Arg(0), "."), ".")
+	if flags.NArg() != 1 || len(labels) < 2 || labels[0] == "" || labels[1] == "" {
+		return fmt.Errorf("a service name, as service.namespace, is required")
+	}
+
+	name, namespace := labels[0], labels[1]
+
+	state, err := dump(endpoint)
+	if err != nil {
+		return err
+	}
+
+	findings := diagnose.Explain(state, name, namespace)
+
+	if *kubeConfig != "" {
+		cfg, err := clientcmd.BuildConfigFromFlags("", *kubeConfig)
+		if err != nil {
+			return fmt.Errorf("error building kubeconfig: %v", err)
+		}
+
+		dynClient, err := dynamic.NewForConfig(cfg)
+		if err != nil {
+			return fmt.Errorf("error creating dynamic client: %v", err)
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), callTimeout)
+		defer cancel()
+
+		clusterFindings, err := diagnose.ExplainCluster(ctx, dynClient, state, name, namespace)
+		if err != nil {
+			return fmt.Errorf("error checking the cluster's resources: %v", err)
+		}
+
+		findings = append(findings, clusterFindings...)
+	}
+
+	for _, finding := range findings {
+		fmt.Printf("- %s\n", finding)
+	}
+
+	return nil
+}
+
+func dump(endpoint *endpointFlags) (*resolver.State, error) {
+	client, err := endpoint.dial()
+	if err != nil {
+		return nil, err
+	}
+
+	defer client.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), callTimeout)
+	defer cancel()
+
+	state, err := client.Dump(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("error dumping the DNS server's state: %v", err)
+	}
+
+	return state, nil
+}
+
+func printService(service *serviceimport.ServiceState) {
+	kind := "ClusterSetIP"
+	if service.Headless {
+		kind = "Headless"
+	}
+
+	affinity := ""
+	if service.SessionAffinity == corev1.ServiceAffinityClientIP {
+		affinity = fmt.Sprintf(" sessionAffinity=%s timeout=%ds", service.SessionAffinity, service.SessionAffinityTimeoutSeconds)
+	}
+
+	fmt.Printf("%s/%s (%s)%s\n", service.Namespace, service.Name, kind, affinity)
+
+	for i := range service.Records {
+		fmt.Print("  ")
+		printRecord(&service.Records[i])
+	}
+}
+
+func printRecord(record *serviceimport.DNSRecord) {
+	ports := make([]string, 0, len(record.Ports))
+	for _, port := range record.Ports {
+		ports = append(ports, fmt.Sprintf("%s:%d/%s", port.Name, port.Port, port.Protocol))
+	}
+
+	host := ""
+	if record.HostName != "" {
+		host = record.HostName + " "
+	}
+
+	fmt.Printf("%scluster=%s ips=%s ports=%s\n", host, record.ClusterName, strings.Join(record.Addresses(), ","),
+		strings.Join(ports, ","))
+}
+
+// endpointFlags select the DNS server's grpc-endpoint and, if it's served with tls, the client certificate.
+type endpointFlags struct {
+	address    string
+	cert       string
+	key        string
+	ca         string
+	serverName string
+}
+
+func addEndpointFlags(flags *flag.FlagSet, defaultAddress string) *endpointFlags {
+	endpoint := &endpointFlags{}
+
+	flags.StringVar(&endpoint.address, "endpoint", defaultAddress, "Address of the DNS server's grpc-endpoint.")
+	flags.StringVar(&endpoint.cert, "tls-cert", "", "Path to the client certificate, if the grpc-endpoint is served with tls.")
+	flags.StringVar(&endpoint.key, "tls-key", "", "Path to the client certificate's key.")
+	flags.StringVar(&endpoint.ca, "tls-ca", "", "Path to the CA bundle the server's certificate is verified against.")
+	flags.StringVar(&endpoint.serverName, "tls-server-name", "",
+		"Name the server's certificate is verified for. Defaults to the endpoint's host.")
+
+	return endpoint
+}
+
+func (e *endpointFlags) dial() (*resolver.Client, error) {
+	var tlsConfig *tls.Config
+
+	if e.cert != "" || e.key != "" || e.ca != "" {
+		certificate, err := tls.LoadX509KeyPair(e.cert, e.key)
+		if err != nil {
+			return nil, fmt.Errorf("error loading the client certificate: %v", err)
+		}
+
+		ca, err := ioutil.ReadFile(e.ca)
+		if err != nil {
+			return nil, fmt.Errorf("error reading the CA bundle: %v", err)
+		}
+
+		roots := x509.NewCertPool()
+		if !roots.AppendCertsFromPEM(ca) {
+			return nil, fmt.Errorf("no CA certificate found in %q", e.ca)
+		}
+
+		tlsConfig = &tls.Config{
+			MinVersion:   tls.VersionTLS12,
+			Certificates: []tls.Certificate{certificate},
+			RootCAs:      roots,
+			ServerName:   e.serverName,
+		}
+	}
+
+	client, err := resolver.Dial(e.address, tlsConfig)
+	if err != nil {
+		return nil, fmt.Errorf("error connecting to %q: %v", e.address, err)
+	}
+
+	return client, nil
+}
@@ -0,0 +1,118 @@
+/*
+SPDX-License-Identifier: Apache-2.0
+
+Copyright Contributors to the Submariner project.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package clockskew
+
+import (
+	"sync"
+	"time"
+
+	"k8s.io/klog"
+)
+
+const (
+	// DefaultTolerance is the skew within which clocks are considered to agree.
+	DefaultTolerance = 2 * time.Second
+	// DefaultMaxSkew bounds the offsets taken as skew; larger offsets come from objects written long before they
+	// were observed, e.g. when informers resync, rather than from clock differences.
+	DefaultMaxSkew = time.Minute
+	maxSamples     = 16
+)
+
+// Estimator estimates the offset of the local clock from the broker's, using timestamps set by the broker API server
+// on objects as they are observed. Broker timestamps can then be aged in broker time, so that a skewed local clock
+// doesn't distort the measured propagation latencies, nor make resources appear to come from the future.
+//
+// Each sample is the server timestamp minus the local time it was observed at, i.e. the skew minus the propagation
+// delay; the estimate is the largest recent sample, which has the smallest delay. No skew is assumed until samples
+// are observed.
+type Estimator struct {
+	Tolerance time.Duration
+	MaxSkew   time.Duration
+	mutex     sync.Mutex
+	samples   []time.Duration
+	next      int
+	skew      time.Duration
+	warned    bool
+}
+
+func New() *Estimator {
+	return &Estimator{
+		Tolerance: DefaultTolerance,
+		MaxSkew:   DefaultMaxSkew,
+	}
+}
+
+// Observe records a timestamp set by the broker API server on an object observed locally at the given time.
+func (e *Estimator) Observe(serverTime, localTime time.Time) {
+	if serverTime.IsZero() {
+		return
+	}
+
+	sample := serverTime.Sub(localTime)
+	if sample > e.MaxSkew || sample < -e.MaxSkew {
+		return
+	}
+
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+
+	if len(e.samples) < maxSamples {
+		e.samples = append(e.samples, sample)
+	} else {
+		e.samples[e.next] = sample
+		e.next = (e.next + 1) % maxSamples
+	}
+
+	e.skew = e.samples[0]
+	for _, s := range e.samples[1:] {
+		if s > e.skew {
+			e.skew = s
+		}
+	}
+
+	exceeded := e.skew > e.Tolerance || e.skew < -e.Tolerance
+	if exceeded && !e.warned {
+		klog.Warningf("The broker's clock is estimated to be offset by %v from the local clock, more than the tolerance of %v;"+
+			" timestamps will be adjusted", e.skew, e.Tolerance)
+	}
+
+	e.warned = exceeded
+}
+
+// Skew returns the estimated offset of the broker's clock from the local clock.
+func (e *Estimator) Skew() time.Duration {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+
+	return e.skew
+}
+
+// Now returns the current time according to the broker's clock.
+func (e *Estimator) Now() time.Time {
+	return time.Now().Add(e.Skew())
+}
+
+// Age returns how long ago the given broker time was, never less than zero, as timestamps written by clusters whose
+// clocks are ahead can lie in the future.
+func (e *Estimator) Age(t time.Time) time.Duration {
+	if age := e.Now().Sub(t); age > 0 {
+		return age
+	}
+
+	return 0
+}
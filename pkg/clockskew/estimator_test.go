@@ -0,0 +1,81 @@
+/*
+SPDX-License-Identifier: Apache-2.0
+
+Copyright Contributors to the Submariner project.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package clockskew_test
+
+import (
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/submariner-io/lighthouse/pkg/clockskew"
+)
+
+var _ = Describe("Estimator", func() {
+	var e *clockskew.Estimator
+
+	BeforeEach(func() {
+		e = clockskew.New()
+	})
+
+	When("no samples were observed", func() {
+		It("should assume no skew", func() {
+			Expect(e.Skew()).To(BeZero())
+		})
+	})
+
+	When("samples were observed", func() {
+		BeforeEach(func() {
+			now := time.Now()
+			e.Observe(now.Add(10*time.Second), now.Add(time.Second))
+			e.Observe(now.Add(10*time.Second), now)
+			e.Observe(now.Add(10*time.Second), now.Add(3*time.Second))
+		})
+
+		It("should estimate the skew from the sample with the smallest delay", func() {
+			Expect(e.Skew()).To(Equal(10 * time.Second))
+		})
+
+		It("should return the current time according to the broker", func() {
+			Expect(e.Now()).To(BeTemporally("~", time.Now().Add(10*time.Second), time.Second))
+		})
+	})
+
+	When("a sample exceeds the maximum skew", func() {
+		It("should ignore it", func() {
+			now := time.Now()
+			e.Observe(now.Add(-time.Hour), now)
+			Expect(e.Skew()).To(BeZero())
+		})
+	})
+
+	When("a timestamp lies in the future", func() {
+		It("should consider it current", func() {
+			Expect(e.Age(time.Now().Add(time.Minute))).To(BeZero())
+		})
+	})
+
+	When("a timestamp was written by a cluster whose clock is behind", func() {
+		It("should return its age in broker time", func() {
+			now := time.Now()
+			e.Observe(now.Add(-20*time.Second), now)
+
+			Expect(e.Age(now.Add(-25 * time.Second))).To(BeNumerically("~", 5*time.Second, time.Second))
+			Expect(e.Age(now.Add(-40 * time.Second))).To(BeNumerically("~", 20*time.Second, time.Second))
+		})
+	})
+})
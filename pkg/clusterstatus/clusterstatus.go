@@ -0,0 +1,46 @@
+/*
+SPDX-License-Identifier: Apache-2.0
+
+Copyright Contributors to the Submariner project.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+// Package clusterstatus provides the backends reporting the connectivity with the clusters of the clusterset for
+// deployments which don't use the Submariner Gateway resources: every cluster always connected, the connected clusters
+// listed in a file, or a health endpoint probed for each cluster.
+package clusterstatus
+
+import (
+	"os"
+)
+
+// LocalClusterIDEnv is the environment variable giving the ID of the local cluster, which is always connected.
+const LocalClusterIDEnv = "SUBMARINER_CLUSTERID"
+
+// AlwaysConnected reports every cluster as connected, for clustersets which only use the multi-cluster services DNS
+// and whose connectivity isn't managed by Submariner.
+type AlwaysConnected struct {
+	localClusterID string
+}
+
+func NewAlwaysConnected() *AlwaysConnected {
+	return &AlwaysConnected{localClusterID: os.Getenv(LocalClusterIDEnv)}
+}
+
+func (a *AlwaysConnected) IsConnected(clusterID string) bool {
+	return true
+}
+
+func (a *AlwaysConnected) LocalClusterID() string {
+	return a.localClusterID
+}
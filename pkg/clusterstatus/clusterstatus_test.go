@@ -0,0 +1,149 @@
+/*
+SPDX-License-Identifier: Apache-2.0
+
+Copyright Contributors to the Submariner project.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package clusterstatus_test
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/submariner-io/lighthouse/pkg/clusterstatus"
+)
+
+const (
+	localClusterID   = "east"
+	remoteClusterID1 = "west"
+	remoteClusterID2 = "south"
+)
+
+var _ = Describe("AlwaysConnected", func() {
+	BeforeEach(func() {
+		os.Setenv(clusterstatus.LocalClusterIDEnv, localClusterID)
+	})
+
+	AfterEach(func() {
+		os.Unsetenv(clusterstatus.LocalClusterIDEnv)
+	})
+
+	It("should report every cluster as connected", func() {
+		backend := clusterstatus.NewAlwaysConnected()
+		Expect(backend.LocalClusterID()).To(Equal(localClusterID))
+		Expect(backend.IsConnected(localClusterID)).To(BeTrue())
+		Expect(backend.IsConnected(remoteClusterID1)).To(BeTrue())
+	})
+})
+
+var _ = Describe("File", func() {
+	var (
+		dir     string
+		path    string
+		backend *clusterstatus.File
+	)
+
+	BeforeEach(func() {
+		os.Setenv(clusterstatus.LocalClusterIDEnv, localClusterID)
+
+		var err error
+		dir, err = ioutil.TempDir("", "clusterstatus")
+		Expect(err).To(Succeed())
+
+		path = filepath.Join(dir, "connected")
+		Expect(ioutil.WriteFile(path, []byte(remoteClusterID1+"\n"), 0o600)).To(Succeed())
+
+		backend = clusterstatus.NewFile(path)
+		Expect(backend.Start()).To(Succeed())
+	})
+
+	AfterEach(func() {
+		backend.Stop()
+		os.RemoveAll(dir)
+		os.Unsetenv(clusterstatus.LocalClusterIDEnv)
+	})
+
+	It("should report the listed clusters and the local cluster as connected", func() {
+		Expect(backend.IsConnected(localClusterID)).To(BeTrue())
+		Expect(backend.IsConnected(remoteClusterID1)).To(BeTrue())
+		Expect(backend.IsConnected(remoteClusterID2)).To(BeFalse())
+	})
+
+	When("the file is updated", func() {
+		It("should report the newly listed clusters as connected", func() {
+			Expect(ioutil.WriteFile(path, []byte(remoteClusterID2), 0o600)).To(Succeed())
+			Eventually(func() bool {
+				return backend.IsConnected(remoteClusterID2)
+			}, 5).Should(BeTrue())
+			Expect(backend.IsConnected(remoteClusterID1)).To(BeFalse())
+		})
+	})
+
+	When("the file doesn't exist", func() {
+		It("should fail to start", func() {
+			Expect(clusterstatus.NewFile(filepath.Join(dir, "missing")).Start()).ToNot(Succeed())
+		})
+	})
+})
+
+var _ = Describe("HTTP", func() {
+	var (
+		server  *httptest.Server
+		backend *clusterstatus.HTTP
+	)
+
+	BeforeEach(func() {
+		os.Setenv(clusterstatus.LocalClusterIDEnv, localClusterID)
+
+		server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.URL.Path == "/"+remoteClusterID1+"/healthz" {
+				w.WriteHeader(http.StatusOK)
+				return
+			}
+
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}))
+
+		backend = clusterstatus.NewHTTP(server.URL+"/"+clusterstatus.ClusterPlaceholder+"/healthz", 50*time.Millisecond)
+		Expect(backend.Start()).To(Succeed())
+	})
+
+	AfterEach(func() {
+		backend.Stop()
+		server.Close()
+		os.Unsetenv(clusterstatus.LocalClusterIDEnv)
+	})
+
+	It("should report the local cluster as connected", func() {
+		Expect(backend.IsConnected(localClusterID)).To(BeTrue())
+	})
+
+	It("should report the clusters according to their health endpoint once probed", func() {
+		Expect(backend.IsConnected(remoteClusterID1)).To(BeTrue())
+		Expect(backend.IsConnected(remoteClusterID2)).To(BeTrue())
+
+		Eventually(func() bool {
+			return backend.IsConnected(remoteClusterID2)
+		}, 5).Should(BeFalse())
+		Consistently(func() bool {
+			return backend.IsConnected(remoteClusterID1)
+		}, 0.2).Should(BeTrue())
+	})
+})
@@ -0,0 +1,125 @@
+/*
+SPDX-License-Identifier: Apache-2.0
+
+Copyright Contributors to the Submariner project.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package clusterstatus
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"unicode"
+
+	"github.com/fsnotify/fsnotify"
+	"github.com/pkg/errors"
+	"k8s.io/klog"
+)
+
+// File reports the clusters listed in a file, separated by commas or white space, as connected, and the others as
+// disconnected. The file is read again whenever it changes, e.g. when the ConfigMap it's mounted from is updated.
+type File struct {
+	path           string
+	localClusterID string
+	connected      atomic.Value
+	watcher        *fsnotify.Watcher
+}
+
+func NewFile(path string) *File {
+	f := &File{path: path, localClusterID: os.Getenv(LocalClusterIDEnv)}
+	f.connected.Store(map[string]bool{})
+
+	return f
+}
+
+// Start reads the file and watches it for changes until Stop is called.
+func (f *File) Start() error {
+	if err := f.load(); err != nil {
+		return err
+	}
+
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return errors.Wrap(err, "error creating the cluster status file watcher")
+	}
+
+	// Mounted ConfigMaps are updated by atomically swapping a symlink in the directory, so the directory is watched
+	if err := watcher.Add(filepath.Dir(f.path)); err != nil {
+		_ = watcher.Close()
+		return errors.Wrapf(err, "error watching %q", f.path)
+	}
+
+	f.watcher = watcher
+
+	go f.watch()
+
+	return nil
+}
+
+func (f *File) Stop() {
+	if f.watcher != nil {
+		_ = f.watcher.Close()
+	}
+}
+
+func (f *File) watch() {
+	for {
+		select {
+		case _, ok := <-f.watcher.Events:
+			if !ok {
+				return
+			}
+
+			if err := f.load(); err != nil {
+				klog.Errorf("Error reloading the cluster status from %q, keeping the previous one: %v", f.path, err)
+			}
+		case err, ok := <-f.watcher.Errors:
+			if !ok {
+				return
+			}
+
+			klog.Errorf("Error watching the cluster status in %q: %v", f.path, err)
+		}
+	}
+}
+
+func (f *File) load() error {
+	data, err := ioutil.ReadFile(f.path)
+	if err != nil {
+		return errors.Wrapf(err, "error reading %q", f.path)
+	}
+
+	connected := map[string]bool{}
+
+	for _, clusterID := range strings.FieldsFunc(string(data), func(r rune) bool {
+		return r == ',' || unicode.IsSpace(r)
+	}) {
+		connected[clusterID] = true
+	}
+
+	f.connected.Store(connected)
+
+	return nil
+}
+
+func (f *File) IsConnected(clusterID string) bool {
+	return clusterID == f.localClusterID || f.connected.Load().(map[string]bool)[clusterID]
+}
+
+func (f *File) LocalClusterID() string {
+	return f.localClusterID
+}
@@ -0,0 +1,149 @@
+/*
+SPDX-License-Identifier: Apache-2.0
+
+Copyright Contributors to the Submariner project.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package clusterstatus
+
+import (
+	"context"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/klog"
+)
+
+// ClusterPlaceholder is replaced by the cluster ID in the URL of the clusters' health endpoint.
+const ClusterPlaceholder = "{cluster}"
+
+const (
+	DefaultProbeInterval = 10 * time.Second
+	probeTimeout         = 5 * time.Second
+)
+
+// HTTP probes a health endpoint for each cluster, reporting the cluster as connected while it answers with a 2xx
+// status. Clusters are probed once they're first asked about, and are assumed to be connected until then.
+type HTTP struct {
+	url            string
+	interval       time.Duration
+	localClusterID string
+	client         *http.Client
+	stopCh         chan struct{}
+	mutex          sync.Mutex
+	connected      map[string]bool
+}
+
+// NewHTTP returns a backend probing the given URL, in which ClusterPlaceholder is replaced by each cluster's ID, at the
+// given interval.
+func NewHTTP(url string, interval time.Duration) *HTTP {
+	return &HTTP{
+		url:            url,
+		interval:       interval,
+		localClusterID: os.Getenv(LocalClusterIDEnv),
+		client:         &http.Client{Timeout: probeTimeout},
+		stopCh:         make(chan struct{}),
+		connected:      map[string]bool{},
+	}
+}
+
+// Start probes the clusters periodically until Stop is called.
+func (h *HTTP) Start() error {
+	go wait.Until(h.probeAll, h.interval, h.stopCh)
+
+	return nil
+}
+
+func (h *HTTP) Stop() {
+	close(h.stopCh)
+}
+
+func (h *HTTP) probeAll() {
+	h.mutex.Lock()
+
+	clusterIDs := make([]string, 0, len(h.connected))
+	for clusterID := range h.connected {
+		clusterIDs = append(clusterIDs, clusterID)
+	}
+
+	h.mutex.Unlock()
+
+	var wg sync.WaitGroup
+
+	for _, clusterID := range clusterIDs {
+		wg.Add(1)
+
+		go func(clusterID string) {
+			defer wg.Done()
+
+			connected := h.probe(clusterID)
+
+			h.mutex.Lock()
+			defer h.mutex.Unlock()
+
+			if h.connected[clusterID] != connected {
+				klog.Infof("Cluster %q health endpoint reports it connected: %v", clusterID, connected)
+			}
+
+			h.connected[clusterID] = connected
+		}(clusterID)
+	}
+
+	wg.Wait()
+}
+
+func (h *HTTP) probe(clusterID string) bool {
+	ctx, cancel := context.WithTimeout(context.Background(), probeTimeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, strings.ReplaceAll(h.url, ClusterPlaceholder, clusterID), http.NoBody)
+	if err != nil {
+		klog.Errorf("Error creating the health probe of cluster %q: %v", clusterID, err)
+		return false
+	}
+
+	resp, err := h.client.Do(req)
+	if err != nil {
+		return false
+	}
+
+	_ = resp.Body.Close()
+
+	return resp.StatusCode >= 200 && resp.StatusCode < 300
+}
+
+func (h *HTTP) IsConnected(clusterID string) bool {
+	if clusterID == h.localClusterID {
+		return true
+	}
+
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+
+	connected, probed := h.connected[clusterID]
+	if !probed {
+		h.connected[clusterID] = true
+		return true
+	}
+
+	return connected
+}
+
+func (h *HTTP) LocalClusterID() string {
+	return h.localClusterID
+}
@@ -23,6 +23,47 @@ const (
 	LabelSourceName        = "lighthouse.submariner.io/sourceName"
 	LabelSourceNamespace   = "lighthouse.submariner.io/sourceNamespace"
 	LabelSourceCluster     = "lighthouse.submariner.io/sourceCluster"
+	LabelHostNetwork       = "lighthouse.submariner.io/hostNetwork"
 	LabelServiceImportName = "multicluster.kubernetes.io/service-name"
 	LabelValueManagedBy    = "lighthouse-agent.submariner.io"
+	// ImportNamespace, set on a ServiceExport, is the namespace in which importing clusters serve the service instead
+	// of its own; it's propagated as an annotation on the ServiceImport and a label on the EndpointSlices
+	ImportNamespace = "lighthouse.submariner.io/importNamespace"
+	// ClusterSelection, set on a ServiceExport, overrides the policy selecting the clusters answered for the service;
+	// it's propagated as an annotation on the ServiceImport
+	ClusterSelection = "lighthouse.submariner.io/clusterSelection"
+	// ExternalDNS, set to "true" on a ServiceExport, publishes the service's records to external-dns; it's propagated
+	// as an annotation on the ServiceImport
+	ExternalDNS = "lighthouse.submariner.io/externalDNS"
+	// Aliases, set on a ServiceExport to a comma-separated list of names, makes the service also answer for these names
+	// in its namespace; it's propagated as an annotation on the ServiceImport
+	Aliases = "lighthouse.submariner.io/aliases"
+	// PublishNotReadyAddresses, set to "true" on the ServiceExport of a headless service, exports its endpoints as ready
+	// before their pods are, like the service's own publishNotReadyAddresses; it's propagated as an annotation on the
+	// ServiceImport
+	PublishNotReadyAddresses = "lighthouse.submariner.io/publishNotReadyAddresses"
+	// MinReadyEndpoints, set on a ServiceExport to a number, is the number of ready endpoints the service must have in
+	// a cluster for the cluster to be answered with; it's propagated as an annotation on the ServiceImport
+	MinReadyEndpoints = "lighthouse.submariner.io/minReadyEndpoints"
+	// ExportMode, set on the ServiceExport of a ClusterSetIP service, selects the IPs exported for the service: its
+	// cluster IP by default, or one of the external modes below for clustersets which route between clusters over public
+	// networks rather than Submariner; it's propagated as an annotation on the ServiceImport
+	ExportMode = "lighthouse.submariner.io/exportMode"
+	// ExportModeClusterIP exports the service's cluster IP, or its global IP with Globalnet
+	ExportModeClusterIP = "ClusterIP"
+	// ExportModeLoadBalancer exports the ingress IPs of the service's load balancer, resolving ingress hostnames
+	ExportModeLoadBalancer = "LoadBalancer"
+	// ExportModeNodePort exports the IPs of the cluster's ready nodes, external ones if any, with the service's node
+	// ports as ports
+	ExportModeNodePort = "NodePort"
+	// Maintenance, set to "true" on a Submariner Cluster resource, excludes the cluster from the DNS answers while it's
+	// in maintenance, even though it's connected, so that cross-cluster traffic can be drained before e.g. upgrading its
+	// gateways
+	Maintenance = "lighthouse.submariner.io/maintenance"
+	// ServiceImportFinalizer holds the deletion of an exporting cluster's local ServiceImport until the EndpointSlices
+	// which reference it have been removed
+	ServiceImportFinalizer = "lighthouse.submariner.io/endpointslice-cleanup"
 )
+
+// DefaultClustersetDomain is the domain suffix used for multi-cluster services unless configured otherwise.
+const DefaultClustersetDomain = "clusterset.local"
@@ -0,0 +1,211 @@
+/*
+SPDX-License-Identifier: Apache-2.0
+
+Copyright Contributors to the Submariner project.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package diagnose
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	lhconstants "github.com/submariner-io/lighthouse/pkg/constants"
+	"github.com/submariner-io/lighthouse/pkg/resolver"
+	"github.com/submariner-io/lighthouse/pkg/serviceimport"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/dynamic"
+	mcsv1a1 "sigs.k8s.io/mcs-api/pkg/apis/v1alpha1"
+)
+
+var (
+	serviceExportGVR = schema.GroupVersionResource{Group: "multicluster.x-k8s.io", Version: "v1alpha1",
+		Resource: "serviceexports"}
+	serviceImportGVR = schema.GroupVersionResource{Group: "multicluster.x-k8s.io", Version: "v1alpha1",
+		Resource: "serviceimports"}
+)
+
+// Explain returns findings explaining what the DNS server with the given state answers for the given service, and
+// why, or why it doesn't answer anything.
+func Explain(state *resolver.State, name, namespace string) []string {
+	service := fmt.Sprintf("%s/%s", namespace, name)
+
+	clusterSetIP := findService(state.ServiceImports, name, namespace)
+	headless := findService(state.EndpointSlices, name, namespace)
+
+	if clusterSetIP == nil && headless == nil {
+		return []string{fmt.Sprintf("No ServiceImport or EndpointSlice for %s has been loaded by the DNS server, so queries "+
+			"get NXDOMAIN: check that the service is exported from at least one cluster and that the agents sync it "+
+			"through the broker", service)}
+	}
+
+	connected := map[string]bool{}
+	for _, cluster := range state.Clusters {
+		connected[cluster.ID] = cluster.Connected
+	}
+
+	var findings []string
+
+	if clusterSetIP != nil && !clusterSetIP.Headless {
+		findings = append(findings, fmt.Sprintf("%s is a ClusterSetIP service exported from %d cluster(s)", service,
+			len(clusterSetIP.Records)))
+		findings = append(findings, explainRecords(clusterSetIP.Records, connected, state.LocalClusterID, false)...)
+
+		return findings
+	}
+
+	if headless == nil {
+		return append(findings, fmt.Sprintf("%s is a headless service but none of its EndpointSlices has been loaded by "+
+			"the DNS server, so queries get NXDOMAIN: check that the exporting clusters' agents sync them through the broker",
+			service))
+	}
+
+	findings = append(findings, fmt.Sprintf("%s is a headless service with %d endpoint(s)", service, len(headless.Records)))
+
+	return append(findings, explainRecords(headless.Records, connected, state.LocalClusterID, true)...)
+}
+
+func explainRecords(records []serviceimport.DNSRecord, connected map[string]bool, localClusterID string,
+	isHeadless bool) []string {
+	var (
+		findings []string
+		eligible int
+	)
+
+	for i := range records {
+		record := &records[i]
+
+		what := fmt.Sprintf("cluster %q exports it with IP(s) %s", record.ClusterName, strings.Join(record.Addresses(), ", "))
+		if isHeadless {
+			what = fmt.Sprintf("endpoint %q in cluster %q has IP(s) %s", record.HostName, record.ClusterName,
+				strings.Join(record.Addresses(), ", "))
+		}
+
+		switch {
+		case len(record.Addresses()) == 0:
+			findings = append(findings, fmt.Sprintf("%s but has no IP, so it isn't answered", what))
+		case !connected[record.ClusterName] && record.ClusterName != localClusterID:
+			findings = append(findings, fmt.Sprintf("%s but isn't connected, so it isn't answered", what))
+		case record.ClusterName == localClusterID && !isHeadless:
+			eligible++
+
+			findings = append(findings, fmt.Sprintf("%s and is the local cluster, whose service is preferred while its "+
+				"endpoints are healthy, depending on the cluster selection policy", what))
+		default:
+			eligible++
+
+			findings = append(findings, fmt.Sprintf("%s and is eligible", what))
+		}
+	}
+
+	if eligible == 0 {
+		findings = append(findings, "No cluster is eligible, so queries get an empty answer")
+	}
+
+	return findings
+}
+
+func findService(services []serviceimport.ServiceState, name, namespace string) *serviceimport.ServiceState {
+	for i := range services {
+		if services[i].Name == name && services[i].Namespace == namespace {
+			return &services[i]
+		}
+	}
+
+	return nil
+}
+
+// ExplainCluster returns findings about the given service from the API of the cluster accessed by the given client:
+// the status of its ServiceExport, if any, and the ServiceImports the DNS server with the given state should have
+// loaded.
+func ExplainCluster(ctx context.Context, client dynamic.Interface, state *resolver.State, name, namespace string) ([]string,
+	error) {
+	service := fmt.Sprintf("%s/%s", namespace, name)
+
+	var findings []string
+
+	obj, err := client.Resource(serviceExportGVR).Namespace(namespace).Get(ctx, name, metav1.GetOptions{})
+
+	switch {
+	case apierrors.IsNotFound(err):
+		findings = append(findings, fmt.Sprintf("%s isn't exported from this cluster", service))
+	case err != nil:
+		return nil, err
+	default:
+		export := &mcsv1a1.ServiceExport{}
+		if err := runtime.DefaultUnstructuredConverter.FromUnstructured(obj.Object, export); err != nil {
+			return nil, err
+		}
+
+		for i := range export.Status.Conditions {
+			condition := &export.Status.Conditions[i]
+			findings = append(findings, fmt.Sprintf("The ServiceExport of %s has condition %s %s: %s", service,
+				condition.Type, condition.Status, strings.TrimSpace(stringValue(condition.Reason)+" "+stringValue(condition.Message))))
+		}
+	}
+
+	list, err := client.Resource(serviceImportGVR).Namespace(metav1.NamespaceAll).List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return nil, err
+	}
+
+	var clusters []string
+
+	for i := range list.Items {
+		serviceImport := &mcsv1a1.ServiceImport{}
+		if err := runtime.DefaultUnstructuredConverter.FromUnstructured(list.Items[i].Object, serviceImport); err != nil {
+			return nil, err
+		}
+
+		if serviceImport.Annotations[lhconstants.OriginName] != name || serviceimport.ImportNamespace(serviceImport) != namespace {
+			continue
+		}
+
+		clusters = append(clusters, serviceImport.Labels[lhconstants.LabelSourceCluster])
+	}
+
+	sort.Strings(clusters)
+
+	if len(clusters) == 0 {
+		return append(findings, fmt.Sprintf("No ServiceImport for %s exists in this cluster: the service isn't imported "+
+			"from any cluster yet", service)), nil
+	}
+
+	findings = append(findings, fmt.Sprintf("ServiceImports for %s exist in this cluster from cluster(s) %s", service,
+		strings.Join(clusters, ", ")))
+
+	loaded := findService(state.ServiceImports, name, namespace)
+	if loaded == nil {
+		findings = append(findings, fmt.Sprintf("The DNS server hasn't loaded the ServiceImports of %s: check its "+
+			"connection to the API server", service))
+	} else if len(loaded.Records) < len(clusters) && !loaded.Headless {
+		findings = append(findings, fmt.Sprintf("The DNS server has only loaded %d of the %d ServiceImports of %s",
+			len(loaded.Records), len(clusters), service))
+	}
+
+	return findings, nil
+}
+
+func stringValue(s *string) string {
+	if s == nil {
+		return ""
+	}
+
+	return *s
+}
@@ -0,0 +1,142 @@
+/*
+SPDX-License-Identifier: Apache-2.0
+
+Copyright Contributors to the Submariner project.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package diagnose_test
+
+import (
+	"context"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/submariner-io/admiral/pkg/fake"
+	lhconstants "github.com/submariner-io/lighthouse/pkg/constants"
+	"github.com/submariner-io/lighthouse/pkg/diagnose"
+	"github.com/submariner-io/lighthouse/pkg/resolver"
+	"github.com/submariner-io/lighthouse/pkg/serviceimport"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/dynamic"
+	mcsv1a1 "sigs.k8s.io/mcs-api/pkg/apis/v1alpha1"
+)
+
+const (
+	service1   = "nginx"
+	namespace1 = "default"
+	clusterID1 = "east"
+	clusterID2 = "west"
+	serviceIP1 = "100.96.156.101"
+	serviceIP2 = "100.96.156.102"
+)
+
+var _ = Describe("Explain", func() {
+	var state *resolver.State
+
+	BeforeEach(func() {
+		state = &resolver.State{
+			LocalClusterID: clusterID1,
+			Clusters: []resolver.ClusterState{
+				{ID: clusterID1, Connected: true},
+				{ID: clusterID2, Connected: false},
+			},
+		}
+	})
+
+	When("the service hasn't been loaded", func() {
+		It("should explain that queries get NXDOMAIN", func() {
+			Expect(diagnose.Explain(state, service1, namespace1)).To(ConsistOf(ContainSubstring("NXDOMAIN")))
+		})
+	})
+
+	When("a ClusterSetIP service has been loaded", func() {
+		BeforeEach(func() {
+			state.ServiceImports = []serviceimport.ServiceState{{
+				Name:      service1,
+				Namespace: namespace1,
+				Records: []serviceimport.DNSRecord{
+					{IP: serviceIP1, ClusterName: clusterID1},
+					{IP: serviceIP2, ClusterName: clusterID2},
+				},
+			}}
+		})
+
+		It("should explain which clusters are answered", func() {
+			Expect(diagnose.Explain(state, service1, namespace1)).To(ConsistOf(
+				ContainSubstring("exported from 2 cluster(s)"),
+				And(ContainSubstring(serviceIP1), ContainSubstring("local cluster")),
+				And(ContainSubstring(serviceIP2), ContainSubstring("isn't connected")),
+			))
+		})
+	})
+
+	When("a headless service has no endpoints loaded", func() {
+		BeforeEach(func() {
+			state.ServiceImports = []serviceimport.ServiceState{{Name: service1, Namespace: namespace1, Headless: true}}
+		})
+
+		It("should explain that its EndpointSlices are missing", func() {
+			Expect(diagnose.Explain(state, service1, namespace1)).To(ConsistOf(ContainSubstring("EndpointSlices")))
+		})
+	})
+})
+
+var _ = Describe("ExplainCluster", func() {
+	var (
+		client dynamic.Interface
+		state  *resolver.State
+	)
+
+	BeforeEach(func() {
+		scheme := runtime.NewScheme()
+		Expect(mcsv1a1.AddToScheme(scheme)).To(Succeed())
+
+		client = fake.NewDynamicClient(scheme)
+		state = &resolver.State{LocalClusterID: clusterID1}
+	})
+
+	When("the service isn't exported or imported", func() {
+		It("should say so", func() {
+			findings, err := diagnose.ExplainCluster(context.TODO(), client, state, service1, namespace1)
+			Expect(err).To(Succeed())
+			Expect(findings).To(ConsistOf(ContainSubstring("isn't exported"), ContainSubstring("No ServiceImport")))
+		})
+	})
+
+	When("a ServiceImport exists that the DNS server hasn't loaded", func() {
+		BeforeEach(func() {
+			si := &unstructured.Unstructured{}
+			si.SetAPIVersion("multicluster.x-k8s.io/v1alpha1")
+			si.SetKind("ServiceImport")
+			si.SetNamespace("submariner-operator")
+			si.SetName(service1 + "-" + namespace1 + "-" + clusterID2)
+			si.SetAnnotations(map[string]string{lhconstants.OriginName: service1, lhconstants.OriginNamespace: namespace1})
+			si.SetLabels(map[string]string{lhconstants.LabelSourceCluster: clusterID2})
+
+			_, err := client.Resource(schema.GroupVersionResource{Group: "multicluster.x-k8s.io", Version: "v1alpha1",
+				Resource: "serviceimports"}).Namespace(si.GetNamespace()).Create(context.TODO(), si, metav1.CreateOptions{})
+			Expect(err).To(Succeed())
+		})
+
+		It("should report it", func() {
+			findings, err := diagnose.ExplainCluster(context.TODO(), client, state, service1, namespace1)
+			Expect(err).To(Succeed())
+			Expect(findings).To(ContainElement(ContainSubstring("from cluster(s) " + clusterID2)))
+			Expect(findings).To(ContainElement(ContainSubstring("hasn't loaded")))
+		})
+	})
+})
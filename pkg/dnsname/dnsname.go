@@ -0,0 +1,60 @@
+/*
+SPDX-License-Identifier: Apache-2.0
+
+Copyright Contributors to the Submariner project.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+// Package dnsname handles the internationalized labels of the names served, which are exported, stored and matched in
+// their ASCII form: "xn--" A-labels, as encoded by IDNA (RFC 5891).
+package dnsname
+
+import (
+	"strings"
+
+	"github.com/pkg/errors"
+	"golang.org/x/net/idna"
+)
+
+const aceprefix = "xn--"
+
+// ToASCII returns the given label lower-cased, encoded as an A-label if it's internationalized. An error is returned if
+// it's an invalid internationalized label: an A-label which doesn't decode to a valid U-label, or a U-label which can't
+// be encoded. Other labels are only lower-cased, so that ASCII names are handled exactly as before.
+func ToASCII(label string) (string, error) {
+	lower := strings.ToLower(label)
+	if isASCII(lower) && !strings.HasPrefix(lower, aceprefix) {
+		return lower, nil
+	}
+
+	encoded, err := idna.Lookup.ToASCII(lower)
+	if err != nil {
+		return "", errors.Wrapf(err, "invalid internationalized label %q", label)
+	}
+
+	if strings.Contains(encoded, ".") {
+		return "", errors.Errorf("internationalized label %q maps to several labels", label)
+	}
+
+	return strings.ToLower(encoded), nil
+}
+
+func isASCII(s string) bool {
+	for i := 0; i < len(s); i++ {
+		if s[i] >= 0x80 {
+			return false
+		}
+	}
+
+	return true
+}
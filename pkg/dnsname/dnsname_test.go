@@ -0,0 +1,48 @@
+/*
+SPDX-License-Identifier: Apache-2.0
+
+Copyright Contributors to the Submariner project.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package dnsname_test
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/submariner-io/lighthouse/pkg/dnsname"
+)
+
+var _ = Describe("ToASCII", func() {
+	It("should lower-case ASCII labels", func() {
+		Expect(dnsname.ToASCII("My-Service")).To(Equal("my-service"))
+	})
+
+	It("should keep ASCII labels which IDNA would reject", func() {
+		Expect(dnsname.ToASCII("ab--cd")).To(Equal("ab--cd"))
+	})
+
+	It("should encode U-labels as A-labels", func() {
+		Expect(dnsname.ToASCII("café")).To(Equal("xn--caf-dma"))
+		Expect(dnsname.ToASCII("CAFÉ")).To(Equal("xn--caf-dma"))
+	})
+
+	It("should keep valid A-labels", func() {
+		Expect(dnsname.ToASCII("XN--caf-dma")).To(Equal("xn--caf-dma"))
+	})
+
+	It("should reject A-labels which don't decode", func() {
+		_, err := dnsname.ToASCII("xn--b")
+		Expect(err).To(HaveOccurred())
+	})
+})
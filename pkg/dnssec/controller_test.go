@@ -0,0 +1,126 @@
+/*
+SPDX-License-Identifier: Apache-2.0
+
+Copyright Contributors to the Submariner project.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package dnssec_test
+
+import (
+	"context"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/submariner-io/lighthouse/pkg/dnssec"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+	fakeKubeClient "k8s.io/client-go/kubernetes/fake"
+	"k8s.io/client-go/rest"
+)
+
+const (
+	secretNamespace = "kube-system"
+	secretName      = "lighthouse-dnssec"
+)
+
+var _ = Describe("DNSSEC key controller", func() {
+	var (
+		controller *dnssec.Controller
+		kubeClient *fakeKubeClient.Clientset
+		secret     *corev1.Secret
+	)
+
+	BeforeEach(func() {
+		data, _ := newKeyData("zsk", zone)
+		secret = &corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{Name: secretName, Namespace: secretNamespace},
+			Data:       data,
+		}
+
+		kubeClient = fakeKubeClient.NewSimpleClientset()
+
+		controller = dnssec.NewController(secretNamespace, secretName)
+		controller.NewClientset = func(kubeConfig *rest.Config) (kubernetes.Interface, error) {
+			return kubeClient, nil
+		}
+
+		Expect(controller.Start(&rest.Config{})).To(Succeed())
+	})
+
+	AfterEach(func() {
+		controller.Stop()
+	})
+
+	awaitKeys := func(count int) {
+		Eventually(func() int {
+			return len(controller.Signer.DNSKEYs(zone, zone, 30))
+		}).Should(Equal(count))
+	}
+
+	When("the Secret is created", func() {
+		It("should load its keys", func() {
+			_, err := kubeClient.CoreV1().Secrets(secretNamespace).Create(context.TODO(), secret, metav1.CreateOptions{})
+			Expect(err).To(Succeed())
+
+			awaitKeys(1)
+			Expect(controller.HasSynced()).To(BeTrue())
+		})
+	})
+
+	When("the Secret is updated", func() {
+		BeforeEach(func() {
+			_, err := kubeClient.CoreV1().Secrets(secretNamespace).Create(context.TODO(), secret, metav1.CreateOptions{})
+			Expect(err).To(Succeed())
+			awaitKeys(1)
+		})
+
+		It("should load the new keys", func() {
+			next, _ := newKeyData("zsk-next", zone)
+			for entry, value := range next {
+				secret.Data[entry] = value
+			}
+
+			_, err := kubeClient.CoreV1().Secrets(secretNamespace).Update(context.TODO(), secret, metav1.UpdateOptions{})
+			Expect(err).To(Succeed())
+
+			awaitKeys(2)
+		})
+
+		Context("with invalid keys", func() {
+			It("should keep the previous keys", func() {
+				secret.Data = map[string][]byte{"zsk.key": []byte("invalid")}
+
+				_, err := kubeClient.CoreV1().Secrets(secretNamespace).Update(context.TODO(), secret, metav1.UpdateOptions{})
+				Expect(err).To(Succeed())
+
+				Consistently(func() int {
+					return len(controller.Signer.DNSKEYs(zone, zone, 30))
+				}).Should(Equal(1))
+			})
+		})
+	})
+
+	When("another Secret is created", func() {
+		It("should not load its keys", func() {
+			secret.Name = "other"
+			_, err := kubeClient.CoreV1().Secrets(secretNamespace).Create(context.TODO(), secret, metav1.CreateOptions{})
+			Expect(err).To(Succeed())
+
+			Consistently(func() int {
+				return len(controller.Signer.DNSKEYs(zone, zone, 30))
+			}).Should(BeZero())
+		})
+	})
+})
@@ -0,0 +1,108 @@
+/*
+SPDX-License-Identifier: Apache-2.0
+
+Copyright Contributors to the Submariner project.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package dnssec
+
+import (
+	"bytes"
+	"crypto"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/miekg/dns"
+)
+
+const (
+	publicKeySuffix  = ".key"
+	privateKeySuffix = ".private"
+)
+
+// Key is a zone signing key: its DNSKEY record, whose owner is the zone it signs, and its private key.
+type Key struct {
+	DNSKEY *dns.DNSKEY
+	signer crypto.Signer
+}
+
+// ParseKeys parses the keys in the given Secret data. Each key is a pair of entries sharing a base name, e.g.
+// "zsk.key" and "zsk.private", holding the public key as a DNSKEY record and the private key in the BIND private key
+// format, as written by dnssec-keygen.
+func ParseKeys(data map[string][]byte) ([]Key, error) {
+	names := []string{}
+
+	for entry := range data {
+		if strings.HasSuffix(entry, publicKeySuffix) {
+			names = append(names, strings.TrimSuffix(entry, publicKeySuffix))
+		} else if !strings.HasSuffix(entry, privateKeySuffix) {
+			return nil, fmt.Errorf("unexpected entry %q, keys must be named NAME%s and NAME%s", entry, publicKeySuffix,
+				privateKeySuffix)
+		}
+	}
+
+	if len(names) == 0 {
+		return nil, fmt.Errorf("no %s entry found", publicKeySuffix)
+	}
+
+	sort.Strings(names)
+
+	keys := make([]Key, 0, len(names))
+
+	for _, name := range names {
+		key, err := parseKey(name, data[name+publicKeySuffix], data[name+privateKeySuffix])
+		if err != nil {
+			return nil, fmt.Errorf("error parsing key %q: %v", name, err)
+		}
+
+		keys = append(keys, key)
+	}
+
+	return keys, nil
+}
+
+func parseKey(name string, public, private []byte) (Key, error) {
+	rr, err := dns.NewRR(string(public))
+	if err != nil {
+		return Key{}, err
+	}
+
+	dnskey, ok := rr.(*dns.DNSKEY)
+	if !ok {
+		return Key{}, fmt.Errorf("%s%s doesn't hold a DNSKEY record", name, publicKeySuffix)
+	}
+
+	if dnskey.Flags&dns.ZONE == 0 {
+		return Key{}, fmt.Errorf("%s%s isn't a zone key", name, publicKeySuffix)
+	}
+
+	if private == nil {
+		return Key{}, fmt.Errorf("%s%s is missing", name, privateKeySuffix)
+	}
+
+	privateKey, err := dnskey.ReadPrivateKey(bytes.NewReader(private), name+privateKeySuffix)
+	if err != nil {
+		return Key{}, err
+	}
+
+	signer, ok := privateKey.(crypto.Signer)
+	if !ok {
+		return Key{}, fmt.Errorf("unsupported algorithm %s", dns.AlgorithmToString[dnskey.Algorithm])
+	}
+
+	dnskey.Hdr.Name = strings.ToLower(dnskey.Hdr.Name)
+
+	return Key{DNSKEY: dnskey, signer: signer}, nil
+}
@@ -0,0 +1,202 @@
+/*
+SPDX-License-Identifier: Apache-2.0
+
+Copyright Contributors to the Submariner project.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package dnssec
+
+import (
+	"hash/fnv"
+	"sort"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/coredns/coredns/plugin/pkg/cache"
+	"github.com/miekg/dns"
+	"k8s.io/klog"
+)
+
+const (
+	// Signatures are valid for a week from an hour ago, allowing for clock skew between the resolvers and us
+	validity  = 7 * 24 * time.Hour
+	inception = time.Hour
+	// Cached signatures are replaced once they have less than this left, well before resolvers caching them would see
+	// them expire
+	refresh = 3 * 24 * time.Hour
+
+	signatureCacheSize = 10000
+)
+
+// Signer signs resource record sets with the keys of their zone, caching the signatures so that each record set is only
+// signed once per key while its signatures are fresh.
+type Signer struct {
+	keys       atomic.Value // []Key
+	signatures *cache.Cache
+	now        func() time.Time
+}
+
+func NewSigner() *Signer {
+	s := &Signer{
+		signatures: cache.New(signatureCacheSize),
+		now:        time.Now,
+	}
+
+	s.keys.Store([]Key{})
+
+	return s
+}
+
+// SetKeys replaces the signing keys. Record sets are signed with every key of their zone, so a new key can be rolled
+// in alongside the old one, which is removed once the signatures it made have expired from the resolvers' caches.
+func (s *Signer) SetKeys(keys []Key) {
+	s.keys.Store(keys)
+}
+
+func (s *Signer) zoneKeys(zone string) []Key {
+	zoneKeys := []Key{}
+
+	for _, key := range s.keys.Load().([]Key) {
+		if strings.EqualFold(key.DNSKEY.Hdr.Name, zone) {
+			zoneKeys = append(zoneKeys, key)
+		}
+	}
+
+	return zoneKeys
+}
+
+// DNSKEYs returns the DNSKEY records of the given zone, owned by the given name, which is the zone with the case of the
+// query.
+func (s *Signer) DNSKEYs(name, zone string, ttl uint32) []dns.RR {
+	if s == nil {
+		return nil
+	}
+
+	zoneKeys := s.zoneKeys(zone)
+	records := make([]dns.RR, len(zoneKeys))
+
+	for i := range zoneKeys {
+		dnskey := *zoneKeys[i].DNSKEY
+		dnskey.Hdr.Name = name
+		dnskey.Hdr.Ttl = ttl
+		records[i] = &dnskey
+	}
+
+	return records
+}
+
+// Signs checks whether the record sets of the given zone are signed, that is whether there are keys for the zone.
+func (s *Signer) Signs(zone string) bool {
+	return s != nil && len(s.zoneKeys(zone)) > 0
+}
+
+// Sign returns the given records followed by the signatures of each of their record sets with the keys of the given
+// zone. The records are returned unchanged if there are no keys for the zone.
+func (s *Signer) Sign(records []dns.RR, zone string) []dns.RR {
+	if s == nil || len(records) == 0 {
+		return records
+	}
+
+	zoneKeys := s.zoneKeys(zone)
+	if len(zoneKeys) == 0 {
+		return records
+	}
+
+	signed := append([]dns.RR{}, records...)
+
+	for _, rrset := range splitRRsets(records) {
+		for i := range zoneKeys {
+			if sig := s.signature(rrset, &zoneKeys[i]); sig != nil {
+				signed = append(signed, sig)
+			}
+		}
+	}
+
+	return signed
+}
+
+func (s *Signer) signature(rrset []dns.RR, key *Key) *dns.RRSIG {
+	now := s.now()
+	hash := rrsetHash(rrset, key.DNSKEY)
+
+	if cached, ok := s.signatures.Get(hash); ok {
+		sig := cached.(*dns.RRSIG)
+		if time.Unix(int64(sig.Expiration), 0).Sub(now) > refresh {
+			return sig
+		}
+	}
+
+	sig := &dns.RRSIG{
+		Hdr:        dns.RR_Header{Ttl: rrset[0].Header().Ttl},
+		Algorithm:  key.DNSKEY.Algorithm,
+		Inception:  uint32(now.Add(-inception).Unix()),
+		Expiration: uint32(now.Add(validity).Unix()),
+		KeyTag:     key.DNSKEY.KeyTag(),
+		SignerName: key.DNSKEY.Hdr.Name,
+	}
+
+	if err := sig.Sign(key.signer, rrset); err != nil {
+		klog.Errorf("Error signing %q records of %q with key %d: %v", dns.TypeToString[rrset[0].Header().Rrtype],
+			rrset[0].Header().Name, sig.KeyTag, err)
+		return nil
+	}
+
+	s.signatures.Add(hash, sig)
+
+	return sig
+}
+
+// splitRRsets groups the records by owner name and type, in the order they first appear.
+func splitRRsets(records []dns.RR) [][]dns.RR {
+	rrsets := [][]dns.RR{}
+	index := map[string]int{}
+
+	for _, rr := range records {
+		key := strings.ToLower(rr.Header().Name) + "/" + dns.TypeToString[rr.Header().Rrtype]
+
+		i, ok := index[key]
+		if !ok {
+			i = len(rrsets)
+			index[key] = i
+			rrsets = append(rrsets, nil)
+		}
+
+		rrsets[i] = append(rrsets[i], rr)
+	}
+
+	return rrsets
+}
+
+// rrsetHash identifies the signature of a record set with a key, told apart by its public key rather than its tag,
+// which isn't unique. The records are hashed in a canonical order, as the answers may be shuffled, and with the owner
+// name's case, which the signature's owner name echoes.
+func rrsetHash(rrset []dns.RR, key *dns.DNSKEY) uint64 {
+	texts := make([]string, len(rrset))
+	for i, rr := range rrset {
+		texts[i] = rr.String()
+	}
+
+	sort.Strings(texts)
+
+	h := fnv.New64a()
+	_, _ = h.Write([]byte(key.PublicKey))
+
+	for _, text := range texts {
+		_, _ = h.Write([]byte(text))
+		_, _ = h.Write([]byte{0})
+	}
+
+	return h.Sum64()
+}
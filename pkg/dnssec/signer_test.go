@@ -0,0 +1,203 @@
+/*
+SPDX-License-Identifier: Apache-2.0
+
+Copyright Contributors to the Submariner project.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package dnssec_test
+
+import (
+	"net"
+	"time"
+
+	"github.com/miekg/dns"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/submariner-io/lighthouse/pkg/dnssec"
+)
+
+const zone = "clusterset.local."
+
+var _ = Describe("ParseKeys", func() {
+	When("the data holds key pairs", func() {
+		It("should return the keys", func() {
+			data, dnskey := newKeyData("zsk", zone)
+			next, nextDNSKEY := newKeyData("zsk-next", zone)
+
+			for entry, value := range next {
+				data[entry] = value
+			}
+
+			keys, err := dnssec.ParseKeys(data)
+			Expect(err).To(Succeed())
+			Expect(keys).To(HaveLen(2))
+			Expect(keys[0].DNSKEY.PublicKey).To(Equal(dnskey.PublicKey))
+			Expect(keys[1].DNSKEY.PublicKey).To(Equal(nextDNSKEY.PublicKey))
+		})
+	})
+
+	When("a private key is missing", func() {
+		It("should return an error", func() {
+			data, _ := newKeyData("zsk", zone)
+			delete(data, "zsk.private")
+
+			_, err := dnssec.ParseKeys(data)
+			Expect(err).To(HaveOccurred())
+		})
+	})
+
+	When("an entry isn't part of a key pair", func() {
+		It("should return an error", func() {
+			data, _ := newKeyData("zsk", zone)
+			data["README"] = []byte("keys")
+
+			_, err := dnssec.ParseKeys(data)
+			Expect(err).To(HaveOccurred())
+		})
+	})
+
+	When("a public key isn't a DNSKEY record", func() {
+		It("should return an error", func() {
+			data, _ := newKeyData("zsk", zone)
+			data["zsk.key"] = []byte(zone + " 3600 IN A 10.0.0.1")
+
+			_, err := dnssec.ParseKeys(data)
+			Expect(err).To(HaveOccurred())
+		})
+	})
+
+	When("there are no keys", func() {
+		It("should return an error", func() {
+			_, err := dnssec.ParseKeys(map[string][]byte{})
+			Expect(err).To(HaveOccurred())
+		})
+	})
+})
+
+var _ = Describe("Signer", func() {
+	var (
+		signer  *dnssec.Signer
+		dnskeys []*dns.DNSKEY
+		records []dns.RR
+	)
+
+	BeforeEach(func() {
+		data, dnskey := newKeyData("zsk", zone)
+		dnskeys = []*dns.DNSKEY{dnskey}
+
+		keys, err := dnssec.ParseKeys(data)
+		Expect(err).To(Succeed())
+
+		signer = dnssec.NewSigner()
+		signer.SetKeys(keys)
+
+		records = []dns.RR{
+			newARecord("nginx.default.svc."+zone, "10.0.0.1"),
+			newARecord("nginx.default.svc."+zone, "10.0.0.2"),
+			newARecord("web-0.nginx.default.svc."+zone, "10.0.0.3"),
+		}
+	})
+
+	When("records are signed", func() {
+		It("should append a valid signature for each record set", func() {
+			signed := signer.Sign(records, zone)
+			Expect(signed[:len(records)]).To(Equal(records))
+
+			sigs := signed[len(records):]
+			Expect(sigs).To(HaveLen(2))
+			verify(sigs[0].(*dns.RRSIG), dnskeys[0], records[:2])
+			verify(sigs[1].(*dns.RRSIG), dnskeys[0], records[2:])
+		})
+	})
+
+	When("the same record set is signed again in another order", func() {
+		It("should return the cached signature", func() {
+			sig := signer.Sign(records[:2], zone)[2]
+			Expect(signer.Sign([]dns.RR{records[1], records[0]}, zone)[2]).To(BeIdenticalTo(sig))
+		})
+	})
+
+	When("the records are in another zone", func() {
+		It("should return them unsigned", func() {
+			Expect(signer.Sign(records, "example.org.")).To(Equal(records))
+			Expect(signer.Signs("example.org.")).To(BeFalse())
+			Expect(signer.Signs(zone)).To(BeTrue())
+		})
+	})
+
+	When("a new key is rolled in", func() {
+		BeforeEach(func() {
+			data, dnskey := newKeyData("zsk", zone)
+			next, nextDNSKEY := newKeyData("zsk-next", zone)
+
+			for entry, value := range next {
+				data[entry] = value
+			}
+
+			dnskeys = []*dns.DNSKEY{dnskey, nextDNSKEY}
+
+			keys, err := dnssec.ParseKeys(data)
+			Expect(err).To(Succeed())
+			signer.SetKeys(keys)
+		})
+
+		It("should sign with both keys", func() {
+			sigs := signer.Sign(records[:2], zone)[2:]
+			Expect(sigs).To(HaveLen(2))
+			verify(sigs[0].(*dns.RRSIG), dnskeys[0], records[:2])
+			verify(sigs[1].(*dns.RRSIG), dnskeys[1], records[:2])
+		})
+
+		It("should return both DNSKEY records", func() {
+			Expect(signer.DNSKEYs(zone, zone, 30)).To(HaveLen(2))
+		})
+	})
+
+	When("there's no signer", func() {
+		It("should return the records unsigned", func() {
+			var noSigner *dnssec.Signer
+			Expect(noSigner.Sign(records, zone)).To(Equal(records))
+			Expect(noSigner.DNSKEYs(zone, zone, 30)).To(BeEmpty())
+			Expect(noSigner.Signs(zone)).To(BeFalse())
+		})
+	})
+})
+
+func verify(sig *dns.RRSIG, dnskey *dns.DNSKEY, rrset []dns.RR) {
+	Expect(sig.KeyTag).To(Equal(dnskey.KeyTag()))
+	Expect(sig.SignerName).To(Equal(zone))
+	Expect(sig.Verify(dnskey, rrset)).To(Succeed())
+	Expect(sig.ValidityPeriod(time.Now())).To(BeTrue())
+}
+
+func newARecord(name, ip string) dns.RR {
+	return &dns.A{Hdr: dns.RR_Header{Name: name, Rrtype: dns.TypeA, Class: dns.ClassINET, Ttl: 5}, A: net.ParseIP(ip).To4()}
+}
+
+func newKeyData(name, zone string) (map[string][]byte, *dns.DNSKEY) {
+	dnskey := &dns.DNSKEY{
+		Hdr:       dns.RR_Header{Name: zone, Rrtype: dns.TypeDNSKEY, Class: dns.ClassINET, Ttl: 3600},
+		Flags:     dns.ZONE,
+		Protocol:  3,
+		Algorithm: dns.ECDSAP256SHA256,
+	}
+
+	privateKey, err := dnskey.Generate(256)
+	Expect(err).To(Succeed())
+
+	return map[string][]byte{
+		name + ".key":     []byte(dnskey.String()),
+		name + ".private": []byte(dnskey.PrivateKeyString(privateKey)),
+	}, dnskey
+}
@@ -0,0 +1,138 @@
+/*
+SPDX-License-Identifier: Apache-2.0
+
+Copyright Contributors to the Submariner project.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package dnstls
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"sync/atomic"
+
+	v1 "k8s.io/api/core/v1"
+)
+
+const caCertKey = "ca.crt"
+
+// Certificates holds the certificate presented to peers and the CA bundle peers are verified against, loaded from a
+// kubernetes.io/tls Secret. Both can be replaced while connections are made, e.g. when the Secret is renewed.
+type Certificates struct {
+	certificate atomic.Value // *tls.Certificate
+	roots       atomic.Value // *x509.CertPool
+}
+
+// Set replaces the certificates with those in the given Secret data. The certificates are unchanged if the data is
+// invalid.
+func (c *Certificates) Set(data map[string][]byte) error {
+	certificate, err := tls.X509KeyPair(data[v1.TLSCertKey], data[v1.TLSPrivateKeyKey])
+	if err != nil {
+		return fmt.Errorf("error parsing the certificate and key: %v", err)
+	}
+
+	roots := x509.NewCertPool()
+	if !roots.AppendCertsFromPEM(data[caCertKey]) {
+		return fmt.Errorf("no CA certificate found in %s", caCertKey)
+	}
+
+	c.certificate.Store(&certificate)
+	c.roots.Store(roots)
+
+	return nil
+}
+
+// Loaded returns true once certificates have been set.
+func (c *Certificates) Loaded() bool {
+	return c.certificate.Load() != nil
+}
+
+func (c *Certificates) getCertificate() (*tls.Certificate, error) {
+	certificate, _ := c.certificate.Load().(*tls.Certificate)
+	if certificate == nil {
+		return nil, errors.New("no certificate loaded")
+	}
+
+	return certificate, nil
+}
+
+// ServerConfig returns the TLS configuration of a server requiring clients to present a certificate signed by the CA.
+func (c *Certificates) ServerConfig() *tls.Config {
+	return &tls.Config{
+		MinVersion: tls.VersionTLS12,
+		GetCertificate: func(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+			return c.getCertificate()
+		},
+		// The CA bundle can change, so clients are verified against the current one rather than a fixed ClientCAs pool
+		ClientAuth: tls.RequireAnyClientCert,
+		VerifyPeerCertificate: func(rawCerts [][]byte, _ [][]*x509.Certificate) error {
+			return c.verify(rawCerts, "", x509.ExtKeyUsageClientAuth)
+		},
+	}
+}
+
+// ClientConfig returns the TLS configuration of a client connecting to the given server name, which is sent as SNI and
+// must match the server's certificate, itself signed by the CA.
+func (c *Certificates) ClientConfig(serverName string) *tls.Config {
+	return &tls.Config{
+		MinVersion: tls.VersionTLS12,
+		ServerName: serverName,
+		GetClientCertificate: func(*tls.CertificateRequestInfo) (*tls.Certificate, error) {
+			return c.getCertificate()
+		},
+		// The server is verified against the current CA bundle below rather than a fixed RootCAs pool
+		InsecureSkipVerify: true, // #nosec G402
+		VerifyPeerCertificate: func(rawCerts [][]byte, _ [][]*x509.Certificate) error {
+			return c.verify(rawCerts, serverName, x509.ExtKeyUsageServerAuth)
+		},
+	}
+}
+
+func (c *Certificates) verify(rawCerts [][]byte, serverName string, usage x509.ExtKeyUsage) error {
+	roots, _ := c.roots.Load().(*x509.CertPool)
+	if roots == nil {
+		return errors.New("no CA certificate loaded")
+	}
+
+	if len(rawCerts) == 0 {
+		return errors.New("no peer certificate presented")
+	}
+
+	certs := make([]*x509.Certificate, len(rawCerts))
+
+	for i, raw := range rawCerts {
+		cert, err := x509.ParseCertificate(raw)
+		if err != nil {
+			return fmt.Errorf("error parsing the peer certificate: %v", err)
+		}
+
+		certs[i] = cert
+	}
+
+	intermediates := x509.NewCertPool()
+	for _, cert := range certs[1:] {
+		intermediates.AddCert(cert)
+	}
+
+	_, err := certs[0].Verify(x509.VerifyOptions{
+		DNSName:       serverName,
+		Roots:         roots,
+		Intermediates: intermediates,
+		KeyUsages:     []x509.ExtKeyUsage{usage},
+	})
+
+	return err
+}
@@ -0,0 +1,119 @@
+/*
+SPDX-License-Identifier: Apache-2.0
+
+Copyright Contributors to the Submariner project.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package dnstls
+
+import (
+	"context"
+	"fmt"
+
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/fields"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/watch"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/tools/cache"
+	"k8s.io/klog"
+)
+
+// Controller loads the certificates from a Secret, reloading them whenever the Secret changes.
+type Controller struct {
+	// Indirection hook for unit tests to supply fake client sets
+	NewClientset func(kubeConfig *rest.Config) (kubernetes.Interface, error)
+	Certificates *Certificates
+	namespace    string
+	name         string
+	informer     cache.Controller
+	stopCh       chan struct{}
+}
+
+func NewController(namespace, name string) *Controller {
+	return &Controller{
+		NewClientset: func(c *rest.Config) (kubernetes.Interface, error) {
+			return kubernetes.NewForConfig(c)
+		},
+		Certificates: &Certificates{},
+		namespace:    namespace,
+		name:         name,
+		stopCh:       make(chan struct{}),
+	}
+}
+
+func (c *Controller) Start(kubeConfig *rest.Config) error {
+	klog.Infof("Starting TLS certificate Controller for Secret \"%s/%s\"", c.namespace, c.name)
+
+	clientSet, err := c.NewClientset(kubeConfig)
+	if err != nil {
+		return fmt.Errorf("error creating client set: %v", err)
+	}
+
+	fieldSelector := fields.OneTermEqualSelector("metadata.name", c.name).String()
+
+	_, c.informer = cache.NewInformer(
+		&cache.ListWatch{
+			ListFunc: func(options metav1.ListOptions) (runtime.Object, error) {
+				options.FieldSelector = fieldSelector
+				return clientSet.CoreV1().Secrets(c.namespace).List(context.TODO(), options)
+			},
+			WatchFunc: func(options metav1.ListOptions) (watch.Interface, error) {
+				options.FieldSelector = fieldSelector
+				return clientSet.CoreV1().Secrets(c.namespace).Watch(context.TODO(), options)
+			},
+		},
+		&v1.Secret{},
+		0,
+		cache.ResourceEventHandlerFuncs{
+			AddFunc: c.secretCreatedOrUpdated,
+			UpdateFunc: func(old interface{}, new interface{}) {
+				c.secretCreatedOrUpdated(new)
+			},
+		},
+	)
+
+	go c.informer.Run(c.stopCh)
+
+	return nil
+}
+
+func (c *Controller) Stop() {
+	close(c.stopCh)
+
+	klog.Infof("TLS certificate Controller stopped")
+}
+
+func (c *Controller) HasSynced() bool {
+	return c.informer != nil && c.informer.HasSynced()
+}
+
+// Invalid certificates are ignored, keeping those loaded previously, and so are deletions, so that connections keep
+// being made while the Secret is being replaced.
+func (c *Controller) secretCreatedOrUpdated(obj interface{}) {
+	secret := obj.(*v1.Secret)
+	if secret.Name != c.name {
+		return
+	}
+
+	if err := c.Certificates.Set(secret.Data); err != nil {
+		klog.Errorf("Error loading the TLS certificates from Secret \"%s/%s\", keeping the previous ones: %v", secret.Namespace,
+			secret.Name, err)
+		return
+	}
+
+	klog.Infof("Loaded the TLS certificates from Secret \"%s/%s\"", secret.Namespace, secret.Name)
+}
@@ -0,0 +1,301 @@
+/*
+SPDX-License-Identifier: Apache-2.0
+
+Copyright Contributors to the Submariner project.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package dnstls_test
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"io/ioutil"
+	"math/big"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"time"
+
+	"github.com/miekg/dns"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/submariner-io/lighthouse/pkg/dnstls"
+	v1 "k8s.io/api/core/v1"
+)
+
+const (
+	zone   = "east.clusterset.local."
+	qname  = "nginx.default.svc.east.clusterset.local."
+	answer = "100.96.157.101"
+)
+
+var _ = Describe("Certificates", func() {
+	var ca *testCA
+
+	BeforeEach(func() {
+		ca = newTestCA()
+	})
+
+	When("the Secret data is valid", func() {
+		It("should load the certificates", func() {
+			certificates := &dnstls.Certificates{}
+			Expect(certificates.Loaded()).To(BeFalse())
+			Expect(certificates.Set(ca.secretData("east.clusterset.local"))).To(Succeed())
+			Expect(certificates.Loaded()).To(BeTrue())
+		})
+	})
+
+	When("the CA bundle is missing", func() {
+		It("should return an error", func() {
+			data := ca.secretData("east.clusterset.local")
+			delete(data, "ca.crt")
+
+			Expect((&dnstls.Certificates{}).Set(data)).ToNot(Succeed())
+		})
+	})
+
+	When("the private key doesn't match the certificate", func() {
+		It("should return an error", func() {
+			data := ca.secretData("east.clusterset.local")
+			data[v1.TLSPrivateKeyKey] = ca.secretData("east.clusterset.local")[v1.TLSPrivateKeyKey]
+
+			Expect((&dnstls.Certificates{}).Set(data)).ToNot(Succeed())
+		})
+	})
+})
+
+var _ = Describe("Forwarding", func() {
+	var (
+		ca                 *testCA
+		certificates       *dnstls.Certificates
+		clientCertificates *dnstls.Certificates
+	)
+
+	BeforeEach(func() {
+		ca = newTestCA()
+		certificates = &dnstls.Certificates{}
+		Expect(certificates.Set(ca.secretData("east.clusterset.local", "west.clusterset.local"))).To(Succeed())
+		clientCertificates = certificates
+	})
+
+	forward := func(upstream, serverName string) (*dns.Msg, error) {
+		forwarder, err := dnstls.NewForwarder(zone, upstream, serverName, clientCertificates)
+		Expect(err).To(Succeed())
+
+		m := new(dns.Msg)
+		m.SetQuestion(qname, dns.TypeA)
+
+		return forwarder.Forward(context.TODO(), m)
+	}
+
+	Context("over TLS", func() {
+		var (
+			server  *dnstls.Server
+			address string
+			zones   map[string]string
+		)
+
+		BeforeEach(func() {
+			zones = map[string]string{}
+		})
+
+		JustBeforeEach(func() {
+			address = freeAddress()
+			server = dnstls.NewServer(dns.HandlerFunc(answerHandler), certificates, zones)
+			Expect(server.Start(address)).To(Succeed())
+		})
+
+		AfterEach(func() {
+			server.Stop()
+		})
+
+		It("should return the upstream's answer", func() {
+			resp, err := forward("tls://"+address, "")
+			Expect(err).To(Succeed())
+			Expect(resp.Answer).To(HaveLen(1))
+			Expect(resp.Answer[0].(*dns.A).A.String()).To(Equal(answer))
+		})
+
+		When("the upstream's certificate doesn't match the server name", func() {
+			It("should return an error", func() {
+				_, err := forward("tls://"+address, "south.clusterset.local")
+				Expect(err).To(HaveOccurred())
+			})
+		})
+
+		When("the upstream's certificate isn't signed by the client's CA", func() {
+			It("should return an error", func() {
+				clientCertificates = &dnstls.Certificates{}
+				Expect(clientCertificates.Set(newTestCA().secretData("east.clusterset.local"))).To(Succeed())
+
+				_, err := forward("tls://"+address, "")
+				Expect(err).To(HaveOccurred())
+			})
+		})
+
+		When("zones are routed by server name", func() {
+			BeforeEach(func() {
+				zones["east.clusterset.local"] = "east.clusterset.local."
+				zones["west.clusterset.local"] = "west.clusterset.local."
+			})
+
+			It("should answer queries in the zone of the server name", func() {
+				resp, err := forward("tls://"+address, "")
+				Expect(err).To(Succeed())
+				Expect(resp.Rcode).To(Equal(dns.RcodeSuccess))
+				Expect(resp.Answer).To(HaveLen(1))
+			})
+
+			It("should refuse queries in other zones", func() {
+				resp, err := forward("tls://"+address, "west.clusterset.local")
+				Expect(err).To(Succeed())
+				Expect(resp.Rcode).To(Equal(dns.RcodeRefused))
+			})
+		})
+	})
+
+	Context("over HTTPS", func() {
+		var server *httptest.Server
+
+		BeforeEach(func() {
+			server = httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				body, err := ioutil.ReadAll(r.Body)
+				Expect(err).To(Succeed())
+				Expect(r.Header.Get("Content-Type")).To(Equal("application/dns-message"))
+
+				query := new(dns.Msg)
+				Expect(query.Unpack(body)).To(Succeed())
+				Expect(query.Id).To(BeZero())
+
+				packed, err := newAnswer(query).Pack()
+				Expect(err).To(Succeed())
+
+				w.Header().Set("Content-Type", "application/dns-message")
+				_, _ = w.Write(packed)
+			}))
+			server.TLS = certificates.ServerConfig()
+			server.StartTLS()
+		})
+
+		AfterEach(func() {
+			server.Close()
+		})
+
+		It("should return the upstream's answer with the query's ID", func() {
+			forwarder, err := dnstls.NewForwarder(zone, server.URL+"/dns-query", "", certificates)
+			Expect(err).To(Succeed())
+
+			m := new(dns.Msg)
+			m.SetQuestion(qname, dns.TypeA)
+
+			resp, err := forwarder.Forward(context.TODO(), m)
+			Expect(err).To(Succeed())
+			Expect(resp.Id).To(Equal(m.Id))
+			Expect(resp.Answer).To(HaveLen(1))
+		})
+	})
+
+	When("the upstream's scheme isn't supported", func() {
+		It("should return an error", func() {
+			_, err := dnstls.NewForwarder(zone, "udp://10.0.0.1:53", "", certificates)
+			Expect(err).To(HaveOccurred())
+		})
+	})
+})
+
+func answerHandler(w dns.ResponseWriter, r *dns.Msg) {
+	_ = w.WriteMsg(newAnswer(r))
+}
+
+func newAnswer(r *dns.Msg) *dns.Msg {
+	m := new(dns.Msg)
+	m.SetReply(r)
+	m.Answer = []dns.RR{&dns.A{
+		Hdr: dns.RR_Header{Name: r.Question[0].Name, Rrtype: dns.TypeA, Class: dns.ClassINET, Ttl: 5},
+		A:   net.ParseIP(answer).To4(),
+	}}
+
+	return m
+}
+
+func freeAddress() string {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	Expect(err).To(Succeed())
+
+	defer listener.Close()
+
+	return listener.Addr().String()
+}
+
+type testCA struct {
+	cert *x509.Certificate
+	key  *ecdsa.PrivateKey
+	pem  []byte
+}
+
+func newTestCA() *testCA {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	Expect(err).To(Succeed())
+
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "lighthouse-ca"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		KeyUsage:              x509.KeyUsageCertSign,
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	Expect(err).To(Succeed())
+
+	cert, err := x509.ParseCertificate(der)
+	Expect(err).To(Succeed())
+
+	return &testCA{cert: cert, key: key, pem: pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})}
+}
+
+// secretData returns the data of a Secret holding a certificate for the given names, for both servers and clients.
+func (ca *testCA) secretData(dnsNames ...string) map[string][]byte {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	Expect(err).To(Succeed())
+
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(time.Now().UnixNano()),
+		Subject:      pkix.Name{CommonName: dnsNames[0]},
+		DNSNames:     dnsNames,
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, template, ca.cert, &key.PublicKey, ca.key)
+	Expect(err).To(Succeed())
+
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	Expect(err).To(Succeed())
+
+	return map[string][]byte{
+		v1.TLSCertKey:       pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}),
+		v1.TLSPrivateKeyKey: pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}),
+		"ca.crt":            ca.pem,
+	}
+}
@@ -0,0 +1,143 @@
+/*
+SPDX-License-Identifier: Apache-2.0
+
+Copyright Contributors to the Submariner project.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package dnstls
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/miekg/dns"
+)
+
+const (
+	defaultTLSPort  = "853"
+	forwardTimeout  = 5 * time.Second
+	dohContentType  = "application/dns-message"
+	dohMaxRespBytes = dns.MaxMsgSize
+)
+
+// Forwarder forwards the queries for the names in a zone to an upstream resolver, over TLS (DoT) or HTTPS (DoH),
+// presenting the certificate loaded from the Secret and verifying the upstream's against the CA bundle.
+type Forwarder struct {
+	Zone       string
+	address    string
+	client     *dns.Client
+	httpClient *http.Client
+}
+
+// NewForwarder returns a forwarder for the given zone to upstream, either tls://HOST[:PORT] or https://HOST[:PORT]/PATH.
+// The server name, sent as SNI and expected in the upstream's certificate, defaults to the zone, so that an upstream
+// serving several zones can route the connection to the zone it's for.
+func NewForwarder(zone, upstream, serverName string, certificates *Certificates) (*Forwarder, error) {
+	u, err := url.Parse(upstream)
+	if err != nil {
+		return nil, fmt.Errorf("invalid upstream %q: %v", upstream, err)
+	}
+
+	if serverName == "" {
+		serverName = strings.TrimSuffix(zone, ".")
+	}
+
+	f := &Forwarder{Zone: zone}
+
+	switch u.Scheme {
+	case "tls":
+		f.address = u.Host
+		if u.Port() == "" {
+			f.address = net.JoinHostPort(u.Host, defaultTLSPort)
+		}
+
+		f.client = &dns.Client{Net: "tcp-tls", TLSConfig: certificates.ClientConfig(serverName), Timeout: forwardTimeout}
+	case "https":
+		f.address = u.String()
+		f.httpClient = &http.Client{
+			Transport: &http.Transport{TLSClientConfig: certificates.ClientConfig(serverName), ForceAttemptHTTP2: true},
+			Timeout:   forwardTimeout,
+		}
+	default:
+		return nil, fmt.Errorf("unsupported upstream %q, the scheme must be tls or https", upstream)
+	}
+
+	return f, nil
+}
+
+// Forward sends the query upstream and returns its response.
+func (f *Forwarder) Forward(ctx context.Context, r *dns.Msg) (*dns.Msg, error) {
+	if f.httpClient != nil {
+		return f.forwardHTTPS(ctx, r)
+	}
+
+	resp, _, err := f.client.ExchangeContext(ctx, r, f.address)
+	if err != nil {
+		return nil, fmt.Errorf("error forwarding to %s: %v", f.address, err)
+	}
+
+	return resp, nil
+}
+
+func (f *Forwarder) forwardHTTPS(ctx context.Context, r *dns.Msg) (*dns.Msg, error) {
+	// RFC 8484 recommends a zero ID, so that identical queries can be cached by HTTP caches
+	query := r.Copy()
+	query.Id = 0
+
+	packed, err := query.Pack()
+	if err != nil {
+		return nil, fmt.Errorf("error packing the query: %v", err)
+	}
+
+	request, err := http.NewRequestWithContext(ctx, http.MethodPost, f.address, bytes.NewReader(packed))
+	if err != nil {
+		return nil, fmt.Errorf("error creating the request to %s: %v", f.address, err)
+	}
+
+	request.Header.Set("Content-Type", dohContentType)
+	request.Header.Set("Accept", dohContentType)
+
+	response, err := f.httpClient.Do(request)
+	if err != nil {
+		return nil, fmt.Errorf("error forwarding to %s: %v", f.address, err)
+	}
+
+	defer response.Body.Close()
+
+	if response.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("error forwarding to %s: %s", f.address, response.Status)
+	}
+
+	body, err := ioutil.ReadAll(io.LimitReader(response.Body, dohMaxRespBytes))
+	if err != nil {
+		return nil, fmt.Errorf("error reading the response from %s: %v", f.address, err)
+	}
+
+	resp := new(dns.Msg)
+	if err := resp.Unpack(body); err != nil {
+		return nil, fmt.Errorf("error unpacking the response from %s: %v", f.address, err)
+	}
+
+	resp.Id = r.Id
+
+	return resp, nil
+}
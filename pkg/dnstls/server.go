@@ -0,0 +1,102 @@
+/*
+SPDX-License-Identifier: Apache-2.0
+
+Copyright Contributors to the Submariner project.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package dnstls
+
+import (
+	"crypto/tls"
+	"fmt"
+	"strings"
+
+	"github.com/miekg/dns"
+	"k8s.io/klog"
+)
+
+// Server answers DNS queries over TLS, from clients presenting a certificate signed by the CA. If zones are routed by
+// server name, queries are only answered on connections whose server name (SNI) is routed to a zone holding the name
+// queried, so that a listener shared by several zones only answers each client for the zone it connected to.
+type Server struct {
+	handler      dns.Handler
+	certificates *Certificates
+	zones        map[string]string
+	server       *dns.Server
+}
+
+// NewServer returns a server answering queries with the given handler. zones maps the server names clients may
+// connect to, in lower case, to the zone each may query; if it's empty, queries for any name are answered.
+func NewServer(handler dns.Handler, certificates *Certificates, zones map[string]string) *Server {
+	return &Server{
+		handler:      handler,
+		certificates: certificates,
+		zones:        zones,
+	}
+}
+
+func (s *Server) Start(address string) error {
+	listener, err := tls.Listen("tcp", address, s.certificates.ServerConfig())
+	if err != nil {
+		return fmt.Errorf("error listening on %q: %v", address, err)
+	}
+
+	s.server = &dns.Server{Listener: listener, Net: "tcp-tls", Handler: dns.HandlerFunc(s.serveDNS)}
+
+	klog.Infof("Starting DNS over TLS server on %q", listener.Addr())
+
+	go func() {
+		if err := s.server.ActivateAndServe(); err != nil {
+			klog.Errorf("Error serving DNS over TLS: %v", err)
+		}
+	}()
+
+	return nil
+}
+
+func (s *Server) Stop() {
+	if s.server != nil {
+		if err := s.server.Shutdown(); err != nil {
+			klog.Errorf("Error stopping the DNS over TLS server: %v", err)
+		}
+
+		klog.Infof("DNS over TLS server stopped")
+	}
+}
+
+func (s *Server) serveDNS(w dns.ResponseWriter, r *dns.Msg) {
+	if len(s.zones) > 0 && !s.isRouted(w, r) {
+		m := new(dns.Msg)
+		m.SetRcode(r, dns.RcodeRefused)
+
+		if err := w.WriteMsg(m); err != nil {
+			klog.Errorf("Error writing the response to %s: %v", w.RemoteAddr(), err)
+		}
+
+		return
+	}
+
+	s.handler.ServeDNS(w, r)
+}
+
+func (s *Server) isRouted(w dns.ResponseWriter, r *dns.Msg) bool {
+	stater, ok := w.(dns.ConnectionStater)
+	if !ok || stater.ConnectionState() == nil || len(r.Question) != 1 {
+		return false
+	}
+
+	zone, ok := s.zones[strings.ToLower(stater.ConnectionState().ServerName)]
+
+	return ok && dns.IsSubDomain(zone, strings.ToLower(r.Question[0].Name))
+}
@@ -20,8 +20,11 @@ package endpointslice
 import (
 	"context"
 	"fmt"
+	"sync/atomic"
+	"time"
 
 	lhconstants "github.com/submariner-io/lighthouse/pkg/constants"
+	"github.com/submariner-io/lighthouse/pkg/fairqueue"
 	discovery "k8s.io/api/discovery/v1beta1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/labels"
@@ -45,6 +48,11 @@ type Controller struct {
 	stopCh       chan struct{}
 	store        Store
 	clientSet    kubernetes.Interface
+	// Queue, if set, processes the changes fairly across namespaces instead of as they are received
+	Queue  *fairqueue.Queue
+	synced int32
+	// disconnectedSince holds the time.Time since which EndpointSlices can't be listed or watched, zero while they can
+	disconnectedSince atomic.Value
 }
 
 func NewController(endpointSliceStore Store) *Controller {
@@ -83,21 +91,27 @@ func (c *Controller) Start(kubeConfig *rest.Config) error {
 		&cache.ListWatch{
 			ListFunc: func(options metav1.ListOptions) (runtime.Object, error) {
 				options.LabelSelector = labelSelector
-				return clientSet.DiscoveryV1beta1().EndpointSlices(metav1.NamespaceAll).List(context.TODO(), options)
+				list, err := clientSet.DiscoveryV1beta1().EndpointSlices(metav1.NamespaceAll).List(context.TODO(), options)
+				c.recordConnectivity(err)
+
+				return list, err
 			},
 			WatchFunc: func(options metav1.ListOptions) (watch.Interface, error) {
 				options.LabelSelector = labelSelector
-				return clientSet.DiscoveryV1beta1().EndpointSlices(metav1.NamespaceAll).Watch(context.TODO(), options)
+				w, err := clientSet.DiscoveryV1beta1().EndpointSlices(metav1.NamespaceAll).Watch(context.TODO(), options)
+				c.recordConnectivity(err)
+
+				return w, err
 			},
 		},
 		&discovery.EndpointSlice{},
 		0,
 		cache.ResourceEventHandlerFuncs{
 			AddFunc: func(obj interface{}) {
-				c.store.Put(obj.(*discovery.EndpointSlice))
+				c.put(obj.(*discovery.EndpointSlice))
 			},
 			UpdateFunc: func(old interface{}, new interface{}) {
-				c.store.Put(new.(*discovery.EndpointSlice))
+				c.put(new.(*discovery.EndpointSlice))
 			},
 			DeleteFunc: func(obj interface{}) {
 				var endpointSlice *discovery.EndpointSlice
@@ -116,7 +130,9 @@ func (c *Controller) Start(kubeConfig *rest.Config) error {
 						return
 					}
 				}
-				c.store.Remove(endpointSlice)
+				c.process(endpointSlice, func() {
+					c.store.Remove(endpointSlice)
+				})
 			},
 		},
 	)
@@ -126,21 +142,85 @@ func (c *Controller) Start(kubeConfig *rest.Config) error {
 	return nil
 }
 
+// HasSynced returns true once the initial list of EndpointSlices has been received and applied to the store.
+func (c *Controller) HasSynced() bool {
+	if atomic.LoadInt32(&c.synced) == 1 {
+		return true
+	}
+
+	if c.epsInformer == nil || !c.epsInformer.HasSynced() || (c.Queue != nil && !c.Queue.Idle()) {
+		return false
+	}
+
+	atomic.StoreInt32(&c.synced, 1)
+
+	return true
+}
+
+// DisconnectedSince returns the time since which EndpointSlices can't be listed or watched, and whether they can't.
+func (c *Controller) DisconnectedSince() (time.Time, bool) {
+	since, _ := c.disconnectedSince.Load().(time.Time)
+	return since, !since.IsZero()
+}
+
+func (c *Controller) recordConnectivity(err error) {
+	since, _ := c.disconnectedSince.Load().(time.Time)
+
+	switch {
+	case err != nil && since.IsZero():
+		klog.Warningf("Lost access to the EndpointSlices: %v", err)
+		c.disconnectedSince.Store(time.Now())
+	case err == nil && !since.IsZero():
+		klog.Infof("Regained access to the EndpointSlices after %v", time.Since(since))
+		c.disconnectedSince.Store(time.Time{})
+	}
+}
+
+func (c *Controller) put(endpointSlice *discovery.EndpointSlice) {
+	c.process(endpointSlice, func() {
+		c.store.Put(endpointSlice)
+	})
+}
+
+func (c *Controller) process(endpointSlice *discovery.EndpointSlice, change func()) {
+	if c.Queue == nil {
+		change()
+		return
+	}
+
+	c.Queue.Add(ImportNamespace(endpointSlice), change)
+}
+
 func (c *Controller) Stop() {
 	close(c.stopCh)
 
 	klog.Infof("EndpointSlice Controller stopped")
 }
 
-func (c *Controller) IsHealthy(name, namespace, clusterID string) bool {
+// HealthScore returns the share of the service's endpoints in the given cluster which are ready, or answered anyway
+// because the service publishes its not-ready addresses.
+func (c *Controller) HealthScore(name, namespace, clusterID string) float64 {
 	key := keyFunc(name, namespace)
 	endpointInfo := c.store.Get(key)
 	if endpointInfo != nil && endpointInfo.clusterInfo != nil {
 		info := endpointInfo.clusterInfo[clusterID]
-		if info != nil {
-			return len(info.recordList) > 0
+		if info != nil && info.endpointCount > 0 {
+			return float64(len(info.recordList)) / float64(info.endpointCount)
+		}
+	}
+
+	return 0
+}
+
+// ReadyEndpoints returns the number of the service's endpoints in the given cluster which are ready, or answered anyway
+// because the service publishes its not-ready addresses.
+func (c *Controller) ReadyEndpoints(name, namespace, clusterID string) int {
+	endpointInfo := c.store.Get(keyFunc(name, namespace))
+	if endpointInfo != nil && endpointInfo.clusterInfo != nil {
+		if info := endpointInfo.clusterInfo[clusterID]; info != nil {
+			return len(info.recordList)
 		}
 	}
 
-	return false
+	return 0
 }
@@ -49,35 +49,50 @@ var _ = Describe("EndpointSlice controller", func() {
 	t := newEndpointSliceTestDiver()
 
 	When("a service has a valid endpoint", func() {
-		When("IsHealthy is called for the service with a valid cluster", func() {
-			It("should return true", func() {
+		When("HealthScore is called for the service with a valid cluster", func() {
+			It("should return 1", func() {
 				esName := testName1 + remoteClusterID1
 				endPoint1 := t.newEndpoint(cluster1HostNamePod1, cluster1EndPointIP1)
 				endpointSlice := t.newEndpointSliceFromEndpoint(testService1, remoteClusterID1, esName, testNS1, []v1beta1.Endpoint{endPoint1})
 				t.createEndpointSlice(testNS1, endpointSlice)
-				t.awaitIsHealthy(testService1, testNS1, remoteClusterID1)
+				t.awaitHealthScore(testService1, testNS1, remoteClusterID1, 1)
 			})
 		})
 	})
 
-	When("IsHealthy is called for a non-existent service", func() {
-		It("should return false", func() {
-			Expect(t.controller.IsHealthy(testService1, testNS1, remoteClusterID1)).To(BeFalse())
+	When("HealthScore is called for a non-existent service", func() {
+		It("should return 0", func() {
+			Expect(t.controller.HealthScore(testService1, testNS1, remoteClusterID1)).To(BeZero())
 		})
 	})
 
-	When("IsHealthy is called for a service with no endpoints", func() {
-		It("should return false", func() {
+	When("HealthScore is called for a service with some not-ready endpoints", func() {
+		It("should return the share of ready endpoints", func() {
+			ready := false
+			esName := testName1 + remoteClusterID1
+			endPoint1 := t.newEndpoint(cluster1HostNamePod1, cluster1EndPointIP1)
+			endPoint2 := t.newEndpoint(cluster2HostNamePod1, cluster2EndPointIP1)
+			endPoint2.Conditions.Ready = &ready
+			endpointSlice := t.newEndpointSliceFromEndpoint(testService1, remoteClusterID1, esName, testNS1,
+				[]v1beta1.Endpoint{endPoint1, endPoint2})
+			t.createEndpointSlice(testNS1, endpointSlice)
+			t.awaitHealthScore(testService1, testNS1, remoteClusterID1, 0.5)
+			Expect(t.controller.ReadyEndpoints(testService1, testNS1, remoteClusterID1)).To(Equal(1))
+		})
+	})
+
+	When("HealthScore is called for a service with no endpoints", func() {
+		It("should return 0", func() {
 			esName := testName1 + remoteClusterID1
 			endpointSlice := t.newEndpointSliceFromEndpoint(testService1, remoteClusterID1, esName, testNS1, []v1beta1.Endpoint{})
 			t.createEndpointSlice(testNS1, endpointSlice)
-			t.awaitNotIsHealthy(testService1, testNS1, remoteClusterID1)
+			t.awaitNoHealthScore(testService1, testNS1, remoteClusterID1)
 		})
 	})
 
 	When("a service exists in multiple clusters with valid endpoints", func() {
-		When("IsHealthy is called for each cluster", func() {
-			It("should return true", func() {
+		When("HealthScore is called for each cluster", func() {
+			It("should return 1", func() {
 				esName1 := testName1 + remoteClusterID1
 				endPoint1 := t.newEndpoint(cluster1HostNamePod1, cluster1EndPointIP1)
 				endpointSlice := t.newEndpointSliceFromEndpoint(testService1, remoteClusterID1, esName1, testNS1, []v1beta1.Endpoint{endPoint1})
@@ -88,20 +103,26 @@ var _ = Describe("EndpointSlice controller", func() {
 				endpointSlice2 := t.newEndpointSliceFromEndpoint(testService2, remoteClusterID2, esName2, testNS2, []v1beta1.Endpoint{endPoint2})
 				t.createEndpointSlice(testNS2, endpointSlice2)
 
-				t.awaitIsHealthy(testService1, testNS1, remoteClusterID1)
-				t.awaitIsHealthy(testService2, testNS2, remoteClusterID2)
+				t.awaitHealthScore(testService1, testNS1, remoteClusterID1, 1)
+				t.awaitHealthScore(testService2, testNS2, remoteClusterID2, 1)
 			})
 		})
 	})
 
-	When("IsHealthy is called for a non-existent cluster", func() {
-		It("should return false", func() {
+	When("the initial EndpointSlices have been listed", func() {
+		It("HasSynced should return true", func() {
+			Eventually(t.controller.HasSynced, 5).Should(BeTrue())
+		})
+	})
+
+	When("HealthScore is called for a non-existent cluster", func() {
+		It("should return 0", func() {
 			esName1 := testName1 + remoteClusterID1
 			endPoint1 := t.newEndpoint(cluster1HostNamePod1, cluster1EndPointIP1)
 			endpointSlice := t.newEndpointSliceFromEndpoint(testService1, remoteClusterID1, esName1, testNS1, []v1beta1.Endpoint{endPoint1})
 			t.createEndpointSlice(testNS1, endpointSlice)
 
-			t.awaitNotIsHealthy(testService1, testNS1, "randomcluster")
+			t.awaitNoHealthScore(testService1, testNS1, "randomcluster")
 		})
 	})
 })
@@ -163,14 +184,14 @@ func (t *endpointSliceTestDriver) newEndpoint(hostname, ipaddress string) v1beta
 	}
 }
 
-func (t *endpointSliceTestDriver) awaitIsHealthy(name, nameSpace, clusterID string) {
-	Eventually(func() bool {
-		return t.controller.IsHealthy(name, nameSpace, clusterID)
-	}, 5).Should(BeTrue())
+func (t *endpointSliceTestDriver) awaitHealthScore(name, nameSpace, clusterID string, score float64) {
+	Eventually(func() float64 {
+		return t.controller.HealthScore(name, nameSpace, clusterID)
+	}, 5).Should(Equal(score))
 }
 
-func (t *endpointSliceTestDriver) awaitNotIsHealthy(name, nameSpace, clusterID string) {
-	Consistently(func() bool {
-		return t.controller.IsHealthy(name, nameSpace, clusterID)
-	}, 500*time.Millisecond).Should(BeFalse())
+func (t *endpointSliceTestDriver) awaitNoHealthScore(name, nameSpace, clusterID string) {
+	Consistently(func() float64 {
+		return t.controller.HealthScore(name, nameSpace, clusterID)
+	}, 500*time.Millisecond).Should(BeZero())
 }
@@ -0,0 +1,198 @@
+/*
+SPDX-License-Identifier: Apache-2.0
+
+Copyright Contributors to the Submariner project.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package endpointslice
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	"github.com/submariner-io/admiral/pkg/log"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/watch"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/tools/cache"
+	"k8s.io/klog"
+)
+
+const (
+	headlessServicePod = "HeadlessServicePod"
+	// HeadlessSvcPodIP is the annotation Globalnet sets on a headless service pod's GlobalIngressIP with the pod's IP
+	HeadlessSvcPodIP = "submariner.io/headless-svc-pod-ip"
+)
+
+// GlobalIPResolver translates the endpoint IPs of headless services to the global IPs Globalnet allocates to their pods.
+type GlobalIPResolver interface {
+	// GetGlobalIP returns the global IP allocated to the pod with the given IP, exported from the given cluster and
+	// namespace, or false if there's no such mapping, in which case the pod's IP is used as-is.
+	GetGlobalIP(cluster, namespace, ip string) (string, bool)
+}
+
+// GlobalIngressIPController is a GlobalIPResolver which maps the IPs of the local cluster's headless service pods
+// using the GlobalIngressIP resources Globalnet creates for them.
+type GlobalIngressIPController struct {
+	// Indirection hook for unit tests to supply fake client sets
+	NewClientset func(kubeConfig *rest.Config) (dynamic.Interface, error)
+	// OnChange, if set, is called with the namespace of every pod whose global IP changes
+	OnChange       func(namespace string)
+	localClusterID func() string
+	informer       cache.Controller
+	stopCh         chan struct{}
+	mutex          sync.RWMutex
+	// globalIPs maps namespace/pod IP keys to global IPs
+	globalIPs map[string]string
+	// podIPs maps the GlobalIngressIPs' namespace/name keys to the namespace/pod IP keys they were last seen with
+	podIPs map[string]string
+}
+
+func NewGlobalIngressIPController(localClusterID func() string) *GlobalIngressIPController {
+	return &GlobalIngressIPController{
+		NewClientset:   dynamic.NewForConfig,
+		localClusterID: localClusterID,
+		stopCh:         make(chan struct{}),
+		globalIPs:      make(map[string]string),
+		podIPs:         make(map[string]string),
+	}
+}
+
+func (c *GlobalIngressIPController) Start(kubeConfig *rest.Config) error {
+	klog.Infof("Starting GlobalIngressIP Controller")
+
+	clientSet, err := c.NewClientset(kubeConfig)
+	if err != nil {
+		return fmt.Errorf("error creating client set: %v", err)
+	}
+
+	client := clientSet.Resource(schema.GroupVersionResource{Group: "submariner.io", Version: "v1", Resource: "globalingressips"})
+
+	_, c.informer = cache.NewInformer(
+		&cache.ListWatch{
+			ListFunc: func(options metav1.ListOptions) (runtime.Object, error) {
+				return client.Namespace(metav1.NamespaceAll).List(context.TODO(), options)
+			},
+			WatchFunc: func(options metav1.ListOptions) (watch.Interface, error) {
+				return client.Namespace(metav1.NamespaceAll).Watch(context.TODO(), options)
+			},
+		},
+		&unstructured.Unstructured{},
+		0,
+		cache.ResourceEventHandlerFuncs{
+			AddFunc: func(obj interface{}) {
+				c.put(obj.(*unstructured.Unstructured))
+			},
+			UpdateFunc: func(old interface{}, new interface{}) {
+				c.put(new.(*unstructured.Unstructured))
+			},
+			DeleteFunc: func(obj interface{}) {
+				key, err := cache.DeletionHandlingMetaNamespaceKeyFunc(obj)
+				if err != nil {
+					klog.Errorf("Failed to get the key of deleted GlobalIngressIP %v: %v", obj, err)
+					return
+				}
+
+				namespace, _, _ := cache.SplitMetaNamespaceKey(key)
+				c.update(key, namespace, "", "")
+			},
+		},
+	)
+
+	go c.informer.Run(c.stopCh)
+
+	return nil
+}
+
+func (c *GlobalIngressIPController) Stop() {
+	close(c.stopCh)
+
+	klog.Infof("GlobalIngressIP Controller stopped")
+}
+
+// HasSynced returns true once the initial list of GlobalIngressIPs has been received.
+func (c *GlobalIngressIPController) HasSynced() bool {
+	return c.informer != nil && c.informer.HasSynced()
+}
+
+func (c *GlobalIngressIPController) GetGlobalIP(cluster, namespace, ip string) (string, bool) {
+	if cluster != c.localClusterID() {
+		return "", false
+	}
+
+	c.mutex.RLock()
+	defer c.mutex.RUnlock()
+
+	globalIP, ok := c.globalIPs[namespace+"/"+ip]
+
+	return globalIP, ok
+}
+
+func (c *GlobalIngressIPController) put(obj *unstructured.Unstructured) {
+	key := obj.GetNamespace() + "/" + obj.GetName()
+
+	target, _, _ := unstructured.NestedString(obj.Object, "spec", "target")
+	if target != headlessServicePod {
+		return
+	}
+
+	podIP := obj.GetAnnotations()[HeadlessSvcPodIP]
+	globalIP, _, _ := unstructured.NestedString(obj.Object, "status", "allocatedIP")
+
+	if podIP == "" || globalIP == "" {
+		klog.V(log.DEBUG).Infof("GlobalIngressIP %q has no pod IP or global IP yet", key)
+
+		globalIP = ""
+	}
+
+	c.update(key, obj.GetNamespace(), podIP, globalIP)
+}
+
+// update records the global IP of the pod with the given IP for the given GlobalIngressIP, removing the mapping if
+// the global IP is empty, and reports the change if there's one.
+func (c *GlobalIngressIPController) update(key, namespace, podIP, globalIP string) {
+	changed := func() bool {
+		c.mutex.Lock()
+		defer c.mutex.Unlock()
+
+		changed := false
+
+		podKey := namespace + "/" + podIP
+		if previous, ok := c.podIPs[key]; ok && (previous != podKey || globalIP == "") {
+			delete(c.globalIPs, previous)
+			delete(c.podIPs, key)
+
+			changed = true
+		}
+
+		if globalIP != "" && c.globalIPs[podKey] != globalIP {
+			c.globalIPs[podKey] = globalIP
+			c.podIPs[key] = podKey
+
+			changed = true
+		}
+
+		return changed
+	}()
+
+	if changed && c.OnChange != nil {
+		klog.V(log.DEBUG).Infof("Global IP of pod %q in %q changed to %q", podIP, namespace, globalIP)
+		c.OnChange(namespace)
+	}
+}
@@ -0,0 +1,139 @@
+/*
+SPDX-License-Identifier: Apache-2.0
+
+Copyright Contributors to the Submariner project.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package endpointslice_test
+
+import (
+	"context"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/submariner-io/lighthouse/pkg/endpointslice"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/dynamic"
+	fakeClient "k8s.io/client-go/dynamic/fake"
+	"k8s.io/client-go/rest"
+)
+
+var _ = Describe("GlobalIngressIP controller", func() {
+	const (
+		localClusterID = "east"
+		namespace      = "ns1"
+		podIP          = "10.1.1.1"
+		globalIP       = "242.254.1.1"
+	)
+
+	var (
+		controller *endpointslice.GlobalIngressIPController
+		client     dynamic.ResourceInterface
+		changes    chan string
+	)
+
+	BeforeEach(func() {
+		changes = make(chan string, 10)
+		dynClient := fakeClient.NewSimpleDynamicClient(runtime.NewScheme())
+		client = dynClient.Resource(schema.GroupVersionResource{Group: "submariner.io", Version: "v1",
+			Resource: "globalingressips"}).Namespace(namespace)
+
+		controller = endpointslice.NewGlobalIngressIPController(func() string {
+			return localClusterID
+		})
+		controller.NewClientset = func(kubeConfig *rest.Config) (dynamic.Interface, error) {
+			return dynClient, nil
+		}
+		controller.OnChange = func(namespace string) {
+			changes <- namespace
+		}
+
+		Expect(controller.Start(&rest.Config{})).To(Succeed())
+		Eventually(controller.HasSynced, 5).Should(BeTrue())
+	})
+
+	AfterEach(func() {
+		controller.Stop()
+	})
+
+	newGlobalIngressIP := func(target, allocatedIP string) *unstructured.Unstructured {
+		obj := &unstructured.Unstructured{Object: map[string]interface{}{
+			"spec": map[string]interface{}{
+				"target": target,
+			},
+		}}
+		obj.SetKind("GlobalIngressIP")
+		obj.SetAPIVersion("submariner.io/v1")
+		obj.SetName("pod-pod1")
+		obj.SetNamespace(namespace)
+		obj.SetAnnotations(map[string]string{endpointslice.HeadlessSvcPodIP: podIP})
+
+		if allocatedIP != "" {
+			Expect(unstructured.SetNestedField(obj.Object, allocatedIP, "status", "allocatedIP")).To(Succeed())
+		}
+
+		return obj
+	}
+
+	getGlobalIP := func() string {
+		ip, _ := controller.GetGlobalIP(localClusterID, namespace, podIP)
+		return ip
+	}
+
+	When("a headless service pod's GlobalIngressIP has an allocated IP", func() {
+		BeforeEach(func() {
+			_, err := client.Create(context.TODO(), newGlobalIngressIP("HeadlessServicePod", globalIP), metav1.CreateOptions{})
+			Expect(err).To(Succeed())
+		})
+
+		It("should map the pod's IP to the global IP in the local cluster only", func() {
+			Eventually(getGlobalIP, 5).Should(Equal(globalIP))
+			Eventually(changes).Should(Receive(Equal(namespace)))
+
+			_, ok := controller.GetGlobalIP("west", namespace, podIP)
+			Expect(ok).To(BeFalse())
+
+			_, ok = controller.GetGlobalIP(localClusterID, "other", podIP)
+			Expect(ok).To(BeFalse())
+		})
+
+		Context("and is then deleted", func() {
+			It("should remove the mapping", func() {
+				Eventually(getGlobalIP, 5).Should(Equal(globalIP))
+				Expect(client.Delete(context.TODO(), "pod-pod1", metav1.DeleteOptions{})).To(Succeed())
+				Eventually(getGlobalIP, 5).Should(BeEmpty())
+			})
+		})
+	})
+
+	When("a headless service pod's GlobalIngressIP has no allocated IP", func() {
+		It("should not map the pod's IP", func() {
+			_, err := client.Create(context.TODO(), newGlobalIngressIP("HeadlessServicePod", ""), metav1.CreateOptions{})
+			Expect(err).To(Succeed())
+			Consistently(getGlobalIP).Should(BeEmpty())
+			Expect(changes).ToNot(Receive())
+		})
+	})
+
+	When("a service's GlobalIngressIP has an allocated IP", func() {
+		It("should not map any pod IP", func() {
+			_, err := client.Create(context.TODO(), newGlobalIngressIP("ClusterIPService", globalIP), metav1.CreateOptions{})
+			Expect(err).To(Succeed())
+			Consistently(getGlobalIP).Should(BeEmpty())
+		})
+	})
+})
@@ -18,50 +18,81 @@ limitations under the License.
 package endpointslice
 
 import (
+	"hash/fnv"
+	"reflect"
+	"sort"
+	"strings"
 	"sync"
+	"sync/atomic"
 
 	"github.com/submariner-io/admiral/pkg/log"
 	"github.com/submariner-io/lighthouse/pkg/constants"
 	"github.com/submariner-io/lighthouse/pkg/serviceimport"
+	corev1 "k8s.io/api/core/v1"
 	discovery "k8s.io/api/discovery/v1beta1"
+	"k8s.io/apimachinery/pkg/util/validation"
 	"k8s.io/klog"
 	mcsv1a1 "sigs.k8s.io/mcs-api/pkg/apis/v1alpha1"
 )
 
 type endpointInfo struct {
 	key         string
+	name        string
+	namespace   string
 	clusterInfo map[string]*clusterInfo
 }
 
+// clusterInfo holds the records of a service in a cluster, aggregated from all the EndpointSlices the cluster exports
+// for it. It's rebuilt rather than modified when the slices change, since it's read without any lock.
 type clusterInfo struct {
 	hostRecords map[string][]serviceimport.DNSRecord
 	recordList  []serviceimport.DNSRecord
+	// endpointSlices holds the cluster's EndpointSlices for the service, by name
+	endpointSlices map[string]*discovery.EndpointSlice
+	// sliceRecords holds the records built from each of the EndpointSlices, by name, so that only the records of the
+	// endpoints which changed have to be rebuilt when a slice is updated
+	sliceRecords map[string][]serviceimport.DNSRecord
+	// endpointCount is the number of addresses in the EndpointSlices, whether they're ready or not
+	endpointCount int
 }
 
-type Map struct {
-	epMap map[string]*endpointInfo
-	sync.RWMutex
-}
+// shardCount is the number of shards the services are spread over, so that writers to different services rarely wait
+// for each other.
+const shardCount = 32
 
-func (m *Map) GetDNSRecords(hostname, cluster, namespace, name string, checkCluster func(string) bool) ([]serviceimport.DNSRecord, bool) {
-	key := keyFunc(name, namespace)
+// shard holds the services whose keys hash to it. Its services map is only replaced, when a service is added, and each
+// service's endpointInfo is only replaced as a whole when its EndpointSlices change, so readers never take the mutex,
+// which only serializes writers.
+type shard struct {
+	sync.Mutex
+	services atomic.Value // map[string]*service
+}
 
-	clusterInfos := func() map[string]*clusterInfo {
-		m.RLock()
-		defer m.RUnlock()
+// service holds the current endpointInfo of a headless service. An endpointInfo is never modified once stored.
+type service struct {
+	info atomic.Value // *endpointInfo
+}
 
-		result, ok := m.epMap[key]
-		if !ok {
-			return nil
-		}
+// globalIPResolverHolder gives the values stored in Map.globalIPs a consistent type, as atomic.Value requires.
+type globalIPResolverHolder struct {
+	GlobalIPResolver
+}
 
-		return result.clusterInfo
-	}()
+type Map struct {
+	shards    [shardCount]*shard
+	globalIPs atomic.Value // globalIPResolverHolder
+	// rrCacheBudget, if set, bounds the memory used by the records' caches
+	rrCacheBudget *serviceimport.RRCacheBudget
+}
 
-	if clusterInfos == nil {
+func (m *Map) GetDNSRecords(hostname, cluster, namespace, name string, checkCluster func(string) bool) ([]serviceimport.DNSRecord, bool) {
+	epInfo := m.Get(keyFunc(name, namespace))
+	if epInfo == nil {
 		return nil, false
 	}
 
+	clusterInfos := epInfo.clusterInfo
+
 	switch {
 	case cluster == "":
 		records := make([]serviceimport.DNSRecord, 0)
@@ -86,9 +117,14 @@ func (m *Map) GetDNSRecords(hostname, cluster, namespace, name string, checkClus
 }
 
 func NewMap() *Map {
-	return &Map{
-		epMap: make(map[string]*endpointInfo),
+	m := &Map{}
+
+	for i := range m.shards {
+		m.shards[i] = &shard{}
+		m.shards[i].services.Store(map[string]*service{})
 	}
+
+	return m
 }
 
 func (m *Map) Put(es *discovery.EndpointSlice) {
@@ -105,22 +141,181 @@ func (m *Map) Put(es *discovery.EndpointSlice) {
 		return
 	}
 
-	m.Lock()
-	defer m.Unlock()
+	m.update(key, func(epInfo *endpointInfo) *endpointInfo {
+		if epInfo == nil {
+			epInfo = &endpointInfo{
+				key:         key,
+				name:        es.Labels[constants.LabelSourceName],
+				namespace:   ImportNamespace(es),
+				clusterInfo: make(map[string]*clusterInfo),
+			}
+		}
 
-	epInfo, ok := m.epMap[key]
-	if !ok {
-		epInfo = &endpointInfo{
-			key:         key,
-			clusterInfo: make(map[string]*clusterInfo),
+		endpointSlices := map[string]*discovery.EndpointSlice{}
+		sliceRecords := map[string][]serviceimport.DNSRecord{}
+
+		info := epInfo.clusterInfo[cluster]
+		if info != nil {
+			for name, endpointSlice := range info.endpointSlices {
+				endpointSlices[name] = endpointSlice
+				sliceRecords[name] = info.sliceRecords[name]
+			}
+		}
+
+		records, changed := m.buildSliceRecords(cluster, es, sliceRecords[es.Name])
+		if previous, found := endpointSlices[es.Name]; found && !changed && addressCount(previous) == addressCount(es) {
+			klog.V(log.TRACE).Infof("The records of EndpointSlice %q in %q are unchanged", es.Name, cluster)
+			return nil
+		}
+
+		releaseRRCaches(sliceRecords[es.Name], records)
+		endpointSlices[es.Name] = es
+		sliceRecords[es.Name] = records
+		info = newClusterInfo(endpointSlices, sliceRecords)
+
+		klog.V(log.DEBUG).Infof("Adding clusterInfo %#v for EndpointSlice %q in %q", info, es.Name, cluster)
+
+		return epInfo.withClusterInfo(cluster, info)
+	})
+}
+
+// update replaces the endpointInfo of the service with the given key by the one returned by the given function, which
+// is passed the current endpointInfo, or nil if the service isn't in the map, and mustn't modify it. Nothing is
+// replaced if the function returns nil. Updates to services in the same shard are serialized.
+func (m *Map) update(key string, updateFn func(*endpointInfo) *endpointInfo) {
+	s := m.shardFor(key)
+
+	s.Lock()
+	defer s.Unlock()
+
+	services := s.load()
+
+	svc := services[key]
+	if svc != nil {
+		if epInfo := updateFn(svc.load()); epInfo != nil {
+			svc.info.Store(epInfo)
+		}
+
+		return
+	}
+
+	epInfo := updateFn(nil)
+	if epInfo == nil {
+		return
+	}
+
+	svc = &service{}
+	svc.info.Store(epInfo)
+
+	newServices := make(map[string]*service, len(services)+1)
+	for k, v := range services {
+		newServices[k] = v
+	}
+
+	newServices[key] = svc
+	s.services.Store(newServices)
+}
+
+// withClusterInfo returns a copy of the endpointInfo with the given cluster's info replaced, or removed if it's nil.
+func (e *endpointInfo) withClusterInfo(cluster string, info *clusterInfo) *endpointInfo {
+	clusterInfos := make(map[string]*clusterInfo, len(e.clusterInfo)+1)
+	for id, existing := range e.clusterInfo {
+		clusterInfos[id] = existing
+	}
+
+	if info != nil {
+		clusterInfos[cluster] = info
+	} else {
+		delete(clusterInfos, cluster)
+	}
+
+	return &endpointInfo{key: e.key, name: e.name, namespace: e.namespace, clusterInfo: clusterInfos}
+}
+
+func (m *Map) shardFor(key string) *shard {
+	h := fnv.New32a()
+	_, _ = h.Write([]byte(key))
+
+	return m.shards[h.Sum32()%shardCount]
+}
+
+func (s *shard) load() map[string]*service {
+	services, _ := s.services.Load().(map[string]*service)
+	return services
+}
+
+func (s *service) load() *endpointInfo {
+	epInfo, _ := s.info.Load().(*endpointInfo)
+	return epInfo
+}
+
+// forEach calls the given function with the current endpointInfo of every service in the map.
+func (m *Map) forEach(f func(*endpointInfo)) {
+	for _, s := range m.shards {
+		for _, svc := range s.load() {
+			f(svc.load())
 		}
 	}
+}
 
-	epInfo.clusterInfo[cluster] = &clusterInfo{
-		recordList:  make([]serviceimport.DNSRecord, 0),
-		hostRecords: make(map[string][]serviceimport.DNSRecord),
+// newClusterInfo aggregates the records of all the given EndpointSlices of a service in a cluster. An address
+// present in several slices, e.g. while an endpoint moves from one slice to another, is only answered once, but every
+// endpoint sharing it, e.g. host-networked pods on the same node, is answered for by name.
+func newClusterInfo(endpointSlices map[string]*discovery.EndpointSlice,
+	sliceRecords map[string][]serviceimport.DNSRecord) *clusterInfo {
+	info := &clusterInfo{
+		recordList:     make([]serviceimport.DNSRecord, 0),
+		hostRecords:    make(map[string][]serviceimport.DNSRecord),
+		endpointSlices: endpointSlices,
+		sliceRecords:   sliceRecords,
 	}
 
+	names := make([]string, 0, len(sliceRecords))
+	for name := range sliceRecords {
+		names = append(names, name)
+	}
+
+	sort.Strings(names)
+
+	addresses := map[string]bool{}
+
+	for _, es := range endpointSlices {
+		for i := range es.Endpoints {
+			for _, address := range es.Endpoints[i].Addresses {
+				addresses[address] = true
+			}
+		}
+	}
+
+	info.endpointCount = len(addresses)
+
+	seen := map[string]bool{}
+	seenHosts := map[string]bool{}
+
+	for _, name := range names {
+		for i := range sliceRecords[name] {
+			record := &sliceRecords[name][i]
+
+			if record.HostName != "" && !seenHosts[recordKey(record)] {
+				seenHosts[recordKey(record)] = true
+				info.hostRecords[record.HostName] = append(info.hostRecords[record.HostName], *record)
+			}
+
+			if !seen[record.IP] {
+				seen[record.IP] = true
+				info.recordList = append(info.recordList, *record)
+			}
+		}
+	}
+
+	return info
+}
+
+// buildSliceRecords builds the records of the given EndpointSlice, reusing the previous records of the endpoints which
+// haven't changed so that their cached resource records are kept. It also returns whether the records differ from the
+// previous ones.
+func (m *Map) buildSliceRecords(cluster string, es *discovery.EndpointSlice,
+	previous []serviceimport.DNSRecord) ([]serviceimport.DNSRecord, bool) {
 	mcsPorts := make([]mcsv1a1.ServicePort, len(es.Ports))
 
 	for i, port := range es.Ports {
@@ -133,33 +328,117 @@ func (m *Map) Put(es *discovery.EndpointSlice) {
 		mcsPorts[i] = mcsPort
 	}
 
-	for _, endpoint := range es.Endpoints {
-		var records []serviceimport.DNSRecord
+	previousByKey := make(map[string]*serviceimport.DNSRecord, len(previous))
+	for i := range previous {
+		previousByKey[recordKey(&previous[i])] = &previous[i]
+	}
+
+	// The endpoints of host-networked pods are their nodes' IPs, which the pods on the same node share, so each pod is
+	// answered for by name even though its IP is already answered for
+	hostNetwork := es.Labels[constants.LabelHostNetwork] == "true"
+
+	records := make([]serviceimport.DNSRecord, 0, len(previous))
+	seen := map[string]bool{}
+	changed := false
+
+	for i := range es.Endpoints {
+		endpoint := &es.Endpoints[i]
+
+		// Not-ready endpoints are only exported as ready if the service publishes them
+		if endpoint.Conditions.Ready != nil && !*endpoint.Conditions.Ready {
+			continue
+		}
 
 		for _, address := range endpoint.Addresses {
 			record := serviceimport.DNSRecord{
-				IP:          address,
+				IP:          m.globalIP(cluster, es.Labels[constants.LabelSourceNamespace], address),
 				Ports:       mcsPorts,
 				ClusterName: cluster,
 			}
 
-			if endpoint.Hostname != nil {
-				record.HostName = *endpoint.Hostname
+			record.HostName = endpointHostname(endpoint, record.IP)
+
+			seenKey := address
+			if hostNetwork {
+				seenKey = recordKey(&record)
+			}
+
+			if seen[seenKey] {
+				continue
+			}
+
+			seen[seenKey] = true
+
+			record.Zone = endpoint.Topology[corev1.LabelZoneFailureDomainStable]
+			record.Region = endpoint.Topology[corev1.LabelZoneRegionStable]
+
+			if prev := previousByKey[recordKey(&record)]; prev != nil && sameRecord(prev, &record) {
+				record = *prev
+			} else {
+				record.RRs = serviceimport.NewRRCache(m.rrCacheBudget)
+				changed = true
+			}
+
+			if len(records) >= len(previous) || recordKey(&previous[len(records)]) != recordKey(&record) {
+				changed = true
 			}
 
 			records = append(records, record)
 		}
+	}
+
+	return records, changed || len(records) != len(previous)
+}
+
+// releaseRRCaches releases the caches of the given previous records which the current records don't reuse.
+func releaseRRCaches(previous, current []serviceimport.DNSRecord) {
+	reused := make(map[*serviceimport.RRCache]bool, len(current))
+	for i := range current {
+		reused[current[i].RRs] = true
+	}
 
-		if endpoint.Hostname != nil {
-			epInfo.clusterInfo[cluster].hostRecords[*endpoint.Hostname] = records
+	for i := range previous {
+		if !reused[previous[i].RRs] {
+			previous[i].RRs.Release()
 		}
+	}
+}
+
+// addressCount returns the number of addresses in the given EndpointSlice, whether their endpoints are ready or not.
+func addressCount(es *discovery.EndpointSlice) int {
+	count := 0
+	for i := range es.Endpoints {
+		count += len(es.Endpoints[i].Addresses)
+	}
+
+	return count
+}
 
-		epInfo.clusterInfo[cluster].recordList = append(epInfo.clusterInfo[cluster].recordList, records...)
+// endpointHostname returns the name of the given endpoint, answered for its per-pod queries and targeted by the SRV
+// records of its service: its hostname or, as many pods don't set one, the name of the pod it targets if that's a valid
+// DNS label, or else its IP with dashes, as CoreDNS names the endpoints of headless services.
+func endpointHostname(endpoint *discovery.Endpoint, ip string) string {
+	if endpoint.Hostname != nil && *endpoint.Hostname != "" {
+		return *endpoint.Hostname
 	}
 
-	klog.V(log.DEBUG).Infof("Adding clusterInfo %#v for EndpointSlice %q in %q", epInfo.clusterInfo[cluster], es.Name, cluster)
+	if ref := endpoint.TargetRef; ref != nil && (ref.Kind == "" || ref.Kind == "Pod") &&
+		len(validation.IsDNS1123Label(ref.Name)) == 0 {
+		return ref.Name
+	}
 
-	m.epMap[key] = epInfo
+	return strings.NewReplacer(".", "-", ":", "-").Replace(ip)
+}
+
+// recordKey identifies the given record among those of a service in a cluster.
+func recordKey(record *serviceimport.DNSRecord) string {
+	return record.IP + "/" + record.HostName
+}
+
+// sameRecord returns whether the given records would answer queries with the same resource records.
+func sameRecord(a, b *serviceimport.DNSRecord) bool {
+	return a.IP == b.IP && a.HostName == b.HostName && a.ClusterName == b.ClusterName && a.Zone == b.Zone &&
+		a.Region == b.Region && reflect.DeepEqual(a.Ports, b.Ports)
 }
 
 func (m *Map) Remove(es *discovery.EndpointSlice) {
@@ -171,25 +450,126 @@ func (m *Map) Remove(es *discovery.EndpointSlice) {
 			return
 		}
 
-		m.Lock()
-		defer m.Unlock()
+		m.update(key, func(epInfo *endpointInfo) *endpointInfo {
+			if epInfo == nil {
+				return nil
+			}
 
-		epInfo, ok := m.epMap[key]
-		if !ok {
-			return
-		}
+			info := epInfo.clusterInfo[cluster]
+			if info == nil || info.endpointSlices[es.Name] == nil {
+				return nil
+			}
+
+			klog.V(log.DEBUG).Infof("Removing EndpointSlice %q from clusterInfo %#v in %q", es.Name, info, cluster)
+
+			releaseRRCaches(info.sliceRecords[es.Name], nil)
+
+			if len(info.endpointSlices) == 1 {
+				return epInfo.withClusterInfo(cluster, nil)
+			}
+
+			endpointSlices := map[string]*discovery.EndpointSlice{}
+			sliceRecords := map[string][]serviceimport.DNSRecord{}
+
+			for name, endpointSlice := range info.endpointSlices {
+				if name != es.Name {
+					endpointSlices[name] = endpointSlice
+					sliceRecords[name] = info.sliceRecords[name]
+				}
+			}
+
+			return epInfo.withClusterInfo(cluster, newClusterInfo(endpointSlices, sliceRecords))
+		})
+	}
+}
+
+// SetGlobalIPResolver sets the resolver used to translate the endpoint IPs of the EndpointSlices put from then on.
+func (m *Map) SetGlobalIPResolver(resolver GlobalIPResolver) {
+	m.globalIPs.Store(globalIPResolverHolder{resolver})
+}
+
+// SetRRCacheBudget sets the budget bounding the memory used by the records' caches. It must be called before any
+// EndpointSlice is put.
+func (m *Map) SetRRCacheBudget(budget *serviceimport.RRCacheBudget) {
+	m.rrCacheBudget = budget
+}
+
+// globalIP returns the global IP of the given endpoint IP if it has one, otherwise the IP itself.
+func (m *Map) globalIP(cluster, namespace, ip string) string {
+	holder, _ := m.globalIPs.Load().(globalIPResolverHolder)
+	if holder.GlobalIPResolver == nil {
+		return ip
+	}
 
-		klog.V(log.DEBUG).Infof("Adding endpointInfo %#v for %s in %s", epInfo.clusterInfo[cluster], es.Name, cluster)
-		delete(epInfo.clusterInfo, cluster)
+	if globalIP, ok := holder.GetGlobalIP(cluster, namespace, ip); ok {
+		return globalIP
 	}
+
+	return ip
+}
+
+// EndpointSlices returns the EndpointSlices currently in the map for services exported from the given namespace, so
+// that they can be put again when the global IPs of their endpoints change.
+func (m *Map) EndpointSlices(namespace string) []*discovery.EndpointSlice {
+	var endpointSlices []*discovery.EndpointSlice
+
+	m.forEach(func(epInfo *endpointInfo) {
+		for _, info := range epInfo.clusterInfo {
+			for _, endpointSlice := range info.endpointSlices {
+				if endpointSlice.Labels[constants.LabelSourceNamespace] == namespace {
+					endpointSlices = append(endpointSlices, endpointSlice)
+				}
+			}
+		}
+	})
+
+	return endpointSlices
 }
 
+// Get returns the current endpointInfo of the service with the given key, or nil if there's none. It must not be
+// modified.
 func (m *Map) Get(key string) *endpointInfo {
-	m.RLock()
-	defer m.RUnlock()
-	endpointInfo := m.epMap[key]
+	svc := m.shardFor(key).load()[key]
+	if svc == nil {
+		return nil
+	}
 
-	return endpointInfo
+	return svc.load()
+}
+
+// Services returns the names of the headless services in the given namespace.
+func (m *Map) Services(namespace string) []string {
+	var names []string
+
+	m.forEach(func(epInfo *endpointInfo) {
+		if epInfo.namespace == namespace {
+			names = append(names, epInfo.name)
+		}
+	})
+
+	return names
+}
+
+// Dump returns the state of every headless service in the map, sorted by namespace and name.
+func (m *Map) Dump() []serviceimport.ServiceState {
+	services := make([]serviceimport.ServiceState, 0)
+
+	m.forEach(func(epInfo *endpointInfo) {
+		state := serviceimport.ServiceState{Name: epInfo.name, Namespace: epInfo.namespace, Headless: true}
+
+		for _, info := range epInfo.clusterInfo {
+			for _, record := range info.recordList {
+				record.RRs = nil
+				state.Records = append(state.Records, record)
+			}
+		}
+
+		services = append(services, state)
+	})
+
+	serviceimport.SortServiceStates(services)
+
+	return services
 }
 
 func getKey(es *discovery.EndpointSlice) (string, bool) {
@@ -199,15 +579,25 @@ func getKey(es *discovery.EndpointSlice) (string, bool) {
 		return "", false
 	}
 
-	namespace, ok := es.Labels[constants.LabelSourceNamespace]
+	namespace := ImportNamespace(es)
 
-	if !ok {
+	if namespace == "" {
 		return "", false
 	}
 
 	return keyFunc(name, namespace), true
 }
 
+// ImportNamespace returns the namespace in which the given EndpointSlice's service is served: the namespace it's
+// mapped to by the exporting cluster, if any, otherwise the namespace it's exported from.
+func ImportNamespace(es *discovery.EndpointSlice) string {
+	if namespace := es.Labels[constants.ImportNamespace]; namespace != "" {
+		return namespace
+	}
+
+	return es.Labels[constants.LabelSourceNamespace]
+}
+
 func keyFunc(name, namespace string) string {
 	return name + "-" + namespace
 }
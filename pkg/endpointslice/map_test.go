@@ -27,6 +27,7 @@ import (
 	"github.com/submariner-io/lighthouse/pkg/endpointslice"
 
 	lhconstants "github.com/submariner-io/lighthouse/pkg/constants"
+	corev1 "k8s.io/api/core/v1"
 	discovery "k8s.io/api/discovery/v1beta1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 )
@@ -126,6 +127,66 @@ var _ = Describe("EndpointSlice Map", func() {
 		})
 	})
 
+	When("a headless service is mapped to another namespace by the exporting cluster", func() {
+		It("should return its IPs in the mapped namespace only", func() {
+			es := newEndpointSlice(namespace1, service1, clusterID1, []string{endpointIP})
+			es.Labels[lhconstants.ImportNamespace] = "shared"
+			endpointSliceMap.Put(es)
+
+			records := getRecords("", "", "shared", service1)
+			Expect(records).To(HaveLen(1))
+			Expect(records[0].IP).To(Equal(endpointIP))
+
+			_, found := endpointSliceMap.GetDNSRecords("", "", namespace1, service1, checkCluster)
+			Expect(found).To(BeFalse())
+		})
+	})
+
+	When("the map is dumped", func() {
+		It("should return every headless service with its endpoints", func() {
+			endpointSliceMap.Put(newEndpointSlice(namespace1, service1, clusterID2, []string{endpointIP2}))
+			endpointSliceMap.Put(newEndpointSlice(namespace1, service1, clusterID1, []string{endpointIP}))
+
+			services := endpointSliceMap.Dump()
+			Expect(services).To(HaveLen(1))
+			Expect(services[0].Name).To(Equal(service1))
+			Expect(services[0].Namespace).To(Equal(namespace1))
+			Expect(services[0].Headless).To(BeTrue())
+			Expect(services[0].Records).To(HaveLen(2))
+			Expect(services[0].Records[0].IP).To(Equal(endpointIP))
+			Expect(services[0].Records[1].IP).To(Equal(endpointIP2))
+		})
+	})
+
+	When("a global IP resolver is set", func() {
+		const globalIP = "242.254.1.1"
+
+		BeforeEach(func() {
+			endpointSliceMap.SetGlobalIPResolver(globalIPResolverFunc(func(cluster, namespace, ip string) (string, bool) {
+				if cluster == clusterID1 && namespace == namespace1 && ip == endpointIP {
+					return globalIP, true
+				}
+
+				return "", false
+			}))
+		})
+
+		It("should return the global IPs of the endpoints which have one and the IPs of those which don't", func() {
+			endpointSliceMap.Put(newEndpointSlice(namespace1, service1, clusterID1, []string{endpointIP, endpointIP2}))
+			endpointSliceMap.Put(newEndpointSlice(namespace1, service1, clusterID2, []string{endpointIP}))
+
+			expectIPs("", "", namespace1, service1, []string{globalIP, endpointIP2, endpointIP})
+		})
+
+		It("should return the EndpointSlices exported from a namespace", func() {
+			es := newEndpointSlice(namespace1, service1, clusterID1, []string{endpointIP})
+			endpointSliceMap.Put(es)
+
+			Expect(endpointSliceMap.EndpointSlices(namespace1)).To(Equal([]*discovery.EndpointSlice{es}))
+			Expect(endpointSliceMap.EndpointSlices("other")).To(BeEmpty())
+		})
+	})
+
 	When("a headless service is present in multiple connected clusters and one is removed", func() {
 		It("should consistently return all the remaining IPs", func() {
 			es1 := newEndpointSlice(namespace1, service1, clusterID1, []string{endpointIP})
@@ -141,8 +202,218 @@ var _ = Describe("EndpointSlice Map", func() {
 		})
 	})
 
+	When("a headless service has several EndpointSlices in a cluster", func() {
+		var es1, es2 *discovery.EndpointSlice
+
+		BeforeEach(func() {
+			es1 = newEndpointSlice(namespace1, service1, clusterID1, []string{endpointIP})
+			es1.Name = service1 + "-1"
+			endpointSliceMap.Put(es1)
+
+			es2 = newEndpointSlice(namespace1, service1, clusterID1, []string{endpointIP2})
+			es2.Name = service1 + "-2"
+			endpointSliceMap.Put(es2)
+		})
+
+		It("should return the IPs of all the slices", func() {
+			expectIPs("", clusterID1, namespace1, service1, []string{endpointIP, endpointIP2})
+		})
+
+		It("should keep the IPs of the other slices when one is updated or removed", func() {
+			es2.Endpoints[0].Addresses = []string{endpointIP3}
+			endpointSliceMap.Put(es2)
+			expectIPs("", clusterID1, namespace1, service1, []string{endpointIP, endpointIP3})
+
+			endpointSliceMap.Remove(es1)
+			expectIPs("", clusterID1, namespace1, service1, []string{endpointIP3})
+		})
+
+		It("should return an IP present in several slices once", func() {
+			es2.Endpoints[0].Addresses = []string{endpointIP, endpointIP2}
+			endpointSliceMap.Put(es2)
+			expectIPs("", clusterID1, namespace1, service1, []string{endpointIP, endpointIP2})
+		})
+	})
+
+	When("a headless service has endpoints which aren't ready", func() {
+		var es *discovery.EndpointSlice
+
+		BeforeEach(func() {
+			ready, notReady := true, false
+			es = newEndpointSlice(namespace1, service1, clusterID1, []string{endpointIP})
+			es.Endpoints[0].Conditions.Ready = &ready
+			es.Endpoints = append(es.Endpoints, discovery.Endpoint{
+				Addresses:  []string{endpointIP2},
+				Conditions: discovery.EndpointConditions{Ready: &notReady},
+			}, discovery.Endpoint{
+				Addresses: []string{endpointIP3},
+			})
+			endpointSliceMap.Put(es)
+		})
+
+		It("should only return the IPs of the ready endpoints and of those with an unknown readiness", func() {
+			expectIPs("", "", namespace1, service1, []string{endpointIP, endpointIP3})
+		})
+
+		It("should return the IP of an endpoint once it's ready", func() {
+			ready := true
+			es.Endpoints[1].Conditions.Ready = &ready
+			endpointSliceMap.Put(es)
+			expectIPs("", "", namespace1, service1, []string{endpointIP, endpointIP2, endpointIP3})
+		})
+	})
+
+	When("a headless service's endpoints have no hostname", func() {
+		BeforeEach(func() {
+			es := newEndpointSlice(namespace1, service1, clusterID1, []string{endpointIP})
+			es.Endpoints[0].TargetRef = &corev1.ObjectReference{Kind: "Pod", Name: "pod-1"}
+			es.Endpoints = append(es.Endpoints, discovery.Endpoint{
+				Addresses: []string{endpointIP2},
+				TargetRef: &corev1.ObjectReference{Kind: "Pod", Name: "not.a.label"},
+			}, discovery.Endpoint{
+				Addresses: []string{endpointIP3},
+			})
+			endpointSliceMap.Put(es)
+		})
+
+		It("should name them after their pod or, failing that, their IP", func() {
+			hostnames := []string{}
+			for _, record := range getRecords("", "", namespace1, service1) {
+				hostnames = append(hostnames, record.HostName)
+			}
+
+			Expect(hostnames).To(ConsistOf("pod-1", "100-96-157-102", "100-96-157-103"))
+		})
+
+		It("should answer for them by these names", func() {
+			expectIPs("pod-1", clusterID1, namespace1, service1, []string{endpointIP})
+			expectIPs("100-96-157-103", clusterID1, namespace1, service1, []string{endpointIP3})
+		})
+	})
+
+	When("a headless service is backed by host-networked pods on the same node", func() {
+		var es *discovery.EndpointSlice
+
+		BeforeEach(func() {
+			es = newEndpointSlice(namespace1, service1, clusterID1, []string{endpointIP})
+			es.Endpoints[0].TargetRef = &corev1.ObjectReference{Kind: "Pod", Name: "pod-1"}
+			es.Endpoints = append(es.Endpoints, discovery.Endpoint{
+				Addresses: []string{endpointIP},
+				TargetRef: &corev1.ObjectReference{Kind: "Pod", Name: "pod-2"},
+			})
+		})
+
+		Context("and its EndpointSlice is flagged as host-networked", func() {
+			It("should answer for each pod by name, with the node's IP once for the service", func() {
+				es.Labels[lhconstants.LabelHostNetwork] = "true"
+				endpointSliceMap.Put(es)
+
+				expectIPs("pod-1", clusterID1, namespace1, service1, []string{endpointIP})
+				expectIPs("pod-2", clusterID1, namespace1, service1, []string{endpointIP})
+				expectIPs("", clusterID1, namespace1, service1, []string{endpointIP})
+			})
+		})
+
+		Context("and its EndpointSlice isn't flagged as host-networked", func() {
+			It("should only answer for the first pod with the IP", func() {
+				endpointSliceMap.Put(es)
+
+				expectIPs("pod-1", clusterID1, namespace1, service1, []string{endpointIP})
+				_, found := endpointSliceMap.GetDNSRecords("pod-2", clusterID1, namespace1, service1, checkCluster)
+				Expect(found).To(BeFalse())
+			})
+		})
+	})
+
+	When("a headless service's EndpointSlice is updated after its records were returned", func() {
+		It("should not change the returned records", func() {
+			es := newEndpointSlice(namespace1, service1, clusterID1, []string{endpointIP})
+			endpointSliceMap.Put(es)
+
+			records := getRecords("", "", namespace1, service1)
+
+			es = newEndpointSlice(namespace1, service1, clusterID1, []string{endpointIP2})
+			endpointSliceMap.Put(es)
+			endpointSliceMap.Put(newEndpointSlice(namespace1, service1, clusterID2, []string{endpointIP3}))
+
+			Expect(records).To(HaveLen(1))
+			Expect(records[0].IP).To(Equal(endpointIP))
+			expectIPs("", "", namespace1, service1, []string{endpointIP2, endpointIP3})
+		})
+	})
+
+	When("a headless service's EndpointSlice is updated with a new endpoint", func() {
+		It("should keep the records of the unchanged endpoints along with their cached resource records", func() {
+			es := newEndpointSlice(namespace1, service1, clusterID1, []string{endpointIP})
+			endpointSliceMap.Put(es)
+
+			records := getRecords("", clusterID1, namespace1, service1)
+			Expect(records).To(HaveLen(1))
+
+			es = newEndpointSlice(namespace1, service1, clusterID1, []string{endpointIP, endpointIP2})
+			endpointSliceMap.Put(es)
+
+			updated := getRecords("", clusterID1, namespace1, service1)
+			Expect(updated).To(HaveLen(2))
+			Expect(updated[0].IP).To(Equal(endpointIP))
+			Expect(updated[0].RRs).To(BeIdenticalTo(records[0].RRs))
+			Expect(updated[1].RRs).ToNot(BeIdenticalTo(records[0].RRs))
+		})
+
+		It("should not replace the records if the EndpointSlice is put unchanged", func() {
+			es := newEndpointSlice(namespace1, service1, clusterID1, []string{endpointIP, endpointIP2})
+			endpointSliceMap.Put(es)
+
+			records := getRecords("", clusterID1, namespace1, service1)
+
+			endpointSliceMap.Put(newEndpointSlice(namespace1, service1, clusterID1, []string{endpointIP, endpointIP2}))
+
+			Expect(getRecords("", clusterID1, namespace1, service1)).To(Equal(records))
+			Expect(&getRecords("", clusterID1, namespace1, service1)[0]).To(BeIdenticalTo(&records[0]))
+		})
+	})
+
+	When("EndpointSlices are put and removed while the map is read", func() {
+		It("should answer every read with a consistent set of records", func() {
+			es1 := newEndpointSlice(namespace1, service1, clusterID1, []string{endpointIP})
+			es2 := newEndpointSlice(namespace1, service1, clusterID2, []string{endpointIP2})
+			endpointSliceMap.Put(es1)
+
+			done := make(chan struct{})
+
+			go func() {
+				defer GinkgoRecover()
+				defer close(done)
+
+				for i := 0; i < 500; i++ {
+					endpointSliceMap.Put(es2)
+					endpointSliceMap.Remove(es2)
+				}
+			}()
+
+			for finished := false; !finished; {
+				select {
+				case <-done:
+					finished = true
+				default:
+					records, found := endpointSliceMap.GetDNSRecords("", "", namespace1, service1, checkCluster)
+					Expect(found).To(BeTrue())
+					Expect(len(records)).To(BeNumerically(">=", 1))
+					Expect(records[0].IP).To(Or(Equal(endpointIP), Equal(endpointIP2)))
+				}
+			}
+
+			expectIPs("", "", namespace1, service1, []string{endpointIP})
+		})
+	})
 })
 
+type globalIPResolverFunc func(cluster, namespace, ip string) (string, bool)
+
+func (f globalIPResolverFunc) GetGlobalIP(cluster, namespace, ip string) (string, bool) {
+	return f(cluster, namespace, ip)
+}
+
 func newEndpointSlice(namespace, name, clusterID string, endpointIPs []string) *discovery.EndpointSlice {
 	return &discovery.EndpointSlice{
 		ObjectMeta: metav1.ObjectMeta{
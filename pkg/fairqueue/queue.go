@@ -0,0 +1,208 @@
+/*
+SPDX-License-Identifier: Apache-2.0
+
+Copyright Contributors to the Submariner project.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package fairqueue
+
+import (
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"golang.org/x/time/rate"
+)
+
+var lagGauge = promauto.NewGaugeVec(prometheus.GaugeOpts{
+	Name: "lighthouse_import_processing_lag_seconds",
+	Help: "Age of the oldest unprocessed change, per queue and namespace",
+}, []string{"queue", "namespace"})
+
+// Queue processes changes in per-namespace FIFO order, taking turns between namespaces so that a flood of changes in
+// one namespace, e.g. when a large cluster joins, doesn't delay the changes in others. Each namespace can also be
+// limited to a rate of changes per second.
+type Queue struct {
+	name     string
+	mutex    sync.Mutex
+	wakeup   chan struct{}
+	pending  map[string][]item
+	turns    []string
+	limiters map[string]*rate.Limiter
+	limit    rate.Limit
+	burst    int
+	// active counts the changes taken from pending which are still being processed
+	active int
+}
+
+type item struct {
+	added   time.Time
+	process func()
+}
+
+// New creates a queue without rate limits; the name identifies its lag metrics.
+func New(name string) *Queue {
+	return &Queue{
+		name:     name,
+		wakeup:   make(chan struct{}, 1),
+		pending:  make(map[string][]item),
+		limiters: make(map[string]*rate.Limiter),
+		limit:    rate.Inf,
+	}
+}
+
+// SetRateLimit limits each namespace to limit changes per second, with bursts of up to burst changes.
+func (q *Queue) SetRateLimit(limit float64, burst int) {
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+
+	if burst < 1 {
+		burst = 1
+	}
+
+	q.limit = rate.Limit(limit)
+	q.burst = burst
+	q.limiters = make(map[string]*rate.Limiter)
+}
+
+// Add queues a change in the given namespace, processed by calling process.
+func (q *Queue) Add(namespace string, process func()) {
+	q.mutex.Lock()
+
+	if len(q.pending[namespace]) == 0 {
+		q.turns = append(q.turns, namespace)
+	}
+
+	q.pending[namespace] = append(q.pending[namespace], item{added: time.Now(), process: process})
+
+	q.mutex.Unlock()
+
+	select {
+	case q.wakeup <- struct{}{}:
+	default:
+	}
+}
+
+// Lag returns the age of the oldest unprocessed change in the given namespace.
+func (q *Queue) Lag(namespace string) time.Duration {
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+
+	if items := q.pending[namespace]; len(items) > 0 {
+		return time.Since(items[0].added)
+	}
+
+	return 0
+}
+
+// Idle returns true if all the changes added so far have been processed.
+func (q *Queue) Idle() bool {
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+
+	return len(q.pending) == 0 && q.active == 0
+}
+
+// Run processes changes until stopCh is closed.
+func (q *Queue) Run(stopCh <-chan struct{}) {
+	for {
+		process, wait := q.next()
+		if process != nil {
+			process()
+
+			q.mutex.Lock()
+			q.active--
+			q.mutex.Unlock()
+
+			continue
+		}
+
+		var timer <-chan time.Time
+		if wait > 0 {
+			timer = time.After(wait)
+		}
+
+		select {
+		case <-stopCh:
+			return
+		case <-q.wakeup:
+		case <-timer:
+		}
+	}
+}
+
+// next returns the next change to process, taking the namespaces in turn, or if none can be processed yet, how long
+// to wait before the earliest rate-limited one can; zero means there's nothing to wait for.
+func (q *Queue) next() (func(), time.Duration) {
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+
+	var wait time.Duration
+
+	for range q.turns {
+		namespace := q.turns[0]
+		q.turns = q.turns[1:]
+
+		if delay := q.reserve(namespace); delay > 0 {
+			q.turns = append(q.turns, namespace)
+			lagGauge.WithLabelValues(q.name, namespace).Set(time.Since(q.pending[namespace][0].added).Seconds())
+
+			if wait == 0 || delay < wait {
+				wait = delay
+			}
+
+			continue
+		}
+
+		items := q.pending[namespace]
+		next := items[0]
+
+		if len(items) > 1 {
+			q.pending[namespace] = items[1:]
+			q.turns = append(q.turns, namespace)
+			lagGauge.WithLabelValues(q.name, namespace).Set(time.Since(items[1].added).Seconds())
+		} else {
+			delete(q.pending, namespace)
+			lagGauge.DeleteLabelValues(q.name, namespace)
+		}
+
+		q.active++
+
+		return next.process, 0
+	}
+
+	return nil, wait
+}
+
+// reserve takes a token for the namespace, returning how long to wait if none is available.
+func (q *Queue) reserve(namespace string) time.Duration {
+	if q.limit == rate.Inf {
+		return 0
+	}
+
+	limiter, ok := q.limiters[namespace]
+	if !ok {
+		limiter = rate.NewLimiter(q.limit, q.burst)
+		q.limiters[namespace] = limiter
+	}
+
+	reservation := limiter.Reserve()
+	if delay := reservation.Delay(); delay > 0 {
+		reservation.Cancel()
+		return delay
+	}
+
+	return 0
+}
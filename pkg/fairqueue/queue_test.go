@@ -0,0 +1,128 @@
+/*
+SPDX-License-Identifier: Apache-2.0
+
+Copyright Contributors to the Submariner project.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package fairqueue_test
+
+import (
+	"sync"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/submariner-io/lighthouse/pkg/fairqueue"
+)
+
+var _ = Describe("Queue", func() {
+	const (
+		namespace1 = "namespace1"
+		namespace2 = "namespace2"
+	)
+
+	var (
+		queue  *fairqueue.Queue
+		stopCh chan struct{}
+		mutex  sync.Mutex
+		order  []string
+	)
+
+	BeforeEach(func() {
+		queue = fairqueue.New("test")
+		stopCh = make(chan struct{})
+		order = nil
+	})
+
+	AfterEach(func() {
+		close(stopCh)
+	})
+
+	processed := func() []string {
+		mutex.Lock()
+		defer mutex.Unlock()
+
+		return append([]string(nil), order...)
+	}
+
+	add := func(namespace string, count int) {
+		for i := 0; i < count; i++ {
+			queue.Add(namespace, func() {
+				mutex.Lock()
+				defer mutex.Unlock()
+
+				order = append(order, namespace)
+			})
+		}
+	}
+
+	When("one namespace has many pending changes", func() {
+		It("should take turns with the other namespaces", func() {
+			add(namespace1, 50)
+			add(namespace2, 1)
+
+			go queue.Run(stopCh)
+
+			Eventually(processed).Should(HaveLen(51))
+			Expect(processed()[:2]).To(ConsistOf(namespace1, namespace2))
+		})
+	})
+
+	When("a rate limit is set", func() {
+		BeforeEach(func() {
+			queue.SetRateLimit(10, 1)
+		})
+
+		It("should limit the changes processed per namespace", func() {
+			start := time.Now()
+
+			add(namespace1, 3)
+			go queue.Run(stopCh)
+
+			Eventually(processed).Should(HaveLen(3))
+			Expect(time.Since(start)).To(BeNumerically(">=", 150*time.Millisecond))
+		})
+
+		It("should not delay the other namespaces", func() {
+			add(namespace1, 5)
+			go queue.Run(stopCh)
+
+			Eventually(processed).Should(HaveLen(1))
+			add(namespace2, 1)
+
+			Eventually(processed, 150*time.Millisecond).Should(ContainElement(namespace2))
+			Expect(queue.Lag(namespace1)).To(BeNumerically(">", 0))
+		})
+	})
+
+	When("a namespace has no pending changes", func() {
+		It("should report no lag", func() {
+			Expect(queue.Lag(namespace1)).To(BeZero())
+		})
+	})
+
+	When("changes are added", func() {
+		It("should only report idle once they're processed", func() {
+			Expect(queue.Idle()).To(BeTrue())
+
+			add(namespace1, 2)
+			Expect(queue.Idle()).To(BeFalse())
+
+			go queue.Run(stopCh)
+
+			Eventually(queue.Idle).Should(BeTrue())
+			Expect(processed()).To(HaveLen(2))
+		})
+	})
+})
@@ -22,7 +22,10 @@ import (
 	"fmt"
 	"os"
 	"sync/atomic"
+	"time"
 
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
 	"github.com/submariner-io/admiral/pkg/log"
 	"github.com/submariner-io/admiral/pkg/workqueue"
 	v1 "k8s.io/api/core/v1"
@@ -43,26 +46,56 @@ type NewClientsetFunc func(c *rest.Config) (dynamic.Interface, error)
 // NewClientset is an indirection hook for unit tests to supply fake client sets
 var NewClientset NewClientsetFunc
 
+var transitionsCounter = promauto.NewCounterVec(prometheus.CounterOpts{
+	Name: "lighthouse_cluster_connectivity_transitions_total",
+	Help: "Number of times each cluster's gateway connection was reported connected or disconnected",
+}, []string{"cluster", "status"})
+
+var degradedGauge = promauto.NewGauge(prometheus.GaugeOpts{
+	Name: "lighthouse_cluster_connectivity_degraded",
+	Help: "Whether the Gateway resources can't be read, so that clusters' connectivity is determined by the degraded policy",
+})
+
+// The policies determining clusters' connectivity while the Gateway resources can't be read.
+const (
+	// AssumeConnected considers all the clusters connected
+	AssumeConnected = "assume-connected"
+	// AssumeDisconnected only considers the local cluster connected
+	AssumeDisconnected = "assume-disconnected"
+	// ServeStale keeps the last connectivity read, considering all the clusters connected if none was
+	ServeStale = "serve-stale"
+)
+
 type Controller struct {
-	NewClientset     NewClientsetFunc
+	NewClientset NewClientsetFunc
+	// StableFor, if set, is how long a cluster which reconnects must stay connected before IsConnected reports it
+	// as connected again, so that answers don't oscillate while its connection flaps
+	StableFor time.Duration
+	// DegradedPolicy determines clusters' connectivity while the Gateway resources can't be read, e.g. because
+	// their CRD is missing or access to them is forbidden; AssumeConnected if unset
+	DegradedPolicy   string
 	informer         cache.Controller
 	store            cache.Store
 	queue            workqueue.Interface
 	stopCh           chan struct{}
 	clusterStatusMap atomic.Value
+	clusterLatencies atomic.Value
 	localClusterID   atomic.Value
-	gatewayAvailable bool
+	degraded         int32
+	// knownClusters lists the clusters seen connected so far; it's only accessed when processing Gateways
+	knownClusters map[string]bool
 }
 
 func NewController() *Controller {
 	controller := &Controller{
-		NewClientset:     getNewClientsetFunc(),
-		queue:            workqueue.New("Gateway Controller"),
-		stopCh:           make(chan struct{}),
-		gatewayAvailable: true,
+		NewClientset:  getNewClientsetFunc(),
+		queue:         workqueue.New("Gateway Controller"),
+		stopCh:        make(chan struct{}),
+		knownClusters: make(map[string]bool),
 	}
 
-	controller.clusterStatusMap.Store(make(map[string]bool))
+	controller.clusterStatusMap.Store(make(map[string]time.Time))
+	controller.clusterLatencies.Store(make(map[string]time.Duration))
 
 	localClusterID := os.Getenv("SUBMARINER_CLUSTERID")
 
@@ -82,10 +115,11 @@ func getNewClientsetFunc() NewClientsetFunc {
 
 func (c *Controller) Start(kubeConfig *rest.Config) error {
 	gwClientset, err := c.getCheckedClientset(kubeConfig)
-	if errors.IsNotFound(err) {
-		klog.Infof("Gateway resource not found, disabling Gateway status controller")
+	if errors.IsNotFound(err) || errors.IsForbidden(err) {
+		klog.Warningf("Unable to read the Gateway resources, disabling Gateway status controller and determining "+
+			"connectivity with the %q policy: %v", c.getDegradedPolicy(), err)
 
-		c.gatewayAvailable = false
+		c.setDegraded(true)
 
 		return nil
 	}
@@ -98,7 +132,10 @@ func (c *Controller) Start(kubeConfig *rest.Config) error {
 
 	c.store, c.informer = cache.NewInformer(&cache.ListWatch{
 		ListFunc: func(options metav1.ListOptions) (runtime.Object, error) {
-			return gwClientset.List(context.TODO(), metav1.ListOptions{})
+			list, err := gwClientset.List(context.TODO(), metav1.ListOptions{})
+			c.setDegraded(err != nil)
+
+			return list, err
 		},
 		WatchFunc: func(options metav1.ListOptions) (watch.Interface, error) {
 			return gwClientset.Watch(context.TODO(), options)
@@ -155,12 +192,18 @@ func (c *Controller) gatewayCreatedOrUpdated(obj *unstructured.Unstructured) {
 	c.updateLocalClusterIDIfNeeded(localClusterID)
 
 	c.updateClusterStatusMap(connections)
+
+	c.updateClusterLatencies(connections)
 }
 
+// updateClusterStatusMap records the connected clusters along with the time from which they're considered stable: the
+// time they reconnected if they were seen connected before, otherwise the zero time since there's nothing to dampen.
 func (c *Controller) updateClusterStatusMap(connections []interface{}) {
-	var newMap map[string]bool
+	var newMap map[string]time.Time
 
 	currentMap := c.getClusterStatusMap()
+	statuses := make(map[string]bool)
+	clusterIDs := []string{}
 
 	for _, connection := range connections {
 		connectionMap := connection.(map[string]interface{})
@@ -176,23 +219,38 @@ func (c *Controller) updateClusterStatusMap(connections []interface{}) {
 			continue
 		}
 
-		if status == "connected" {
-			_, found := currentMap[clusterID]
-			if !found {
-				if newMap == nil {
-					newMap = copyMap(currentMap)
-				}
+		// The last status reported for a cluster wins
+		if _, found := statuses[clusterID]; !found {
+			clusterIDs = append(clusterIDs, clusterID)
+		}
+
+		statuses[clusterID] = status == "connected"
+	}
 
-				newMap[clusterID] = true
+	for _, clusterID := range clusterIDs {
+		_, wasConnected := currentMap[clusterID]
+		if statuses[clusterID] == wasConnected {
+			continue
+		}
+
+		if newMap == nil {
+			newMap = copyMap(currentMap)
+		}
+
+		if statuses[clusterID] {
+			since := time.Time{}
+			if c.knownClusters[clusterID] {
+				since = time.Now()
 			}
+
+			newMap[clusterID] = since
+			c.knownClusters[clusterID] = true
+
+			transitionsCounter.WithLabelValues(clusterID, "connected").Inc()
 		} else {
-			_, found = currentMap[clusterID]
-			if found {
-				if newMap == nil {
-					newMap = copyMap(currentMap)
-				}
-				delete(newMap, clusterID)
-			}
+			delete(newMap, clusterID)
+
+			transitionsCounter.WithLabelValues(clusterID, "disconnected").Inc()
 		}
 	}
 
@@ -202,6 +260,33 @@ func (c *Controller) updateClusterStatusMap(connections []interface{}) {
 	}
 }
 
+// updateClusterLatencies records the average round-trip time measured by the gateway for each connection that reports
+// one. Unlike the status map, it's rebuilt on every update since latencies change continuously.
+func (c *Controller) updateClusterLatencies(connections []interface{}) {
+	latencies := make(map[string]time.Duration)
+
+	for _, connection := range connections {
+		connectionMap := connection.(map[string]interface{})
+
+		clusterID, _, _ := unstructured.NestedString(connectionMap, "endpoint", "cluster_id")
+		average, found, err := unstructured.NestedString(connectionMap, "latencyRTT", "average")
+
+		if clusterID == "" || !found || err != nil {
+			continue
+		}
+
+		rtt, err := time.ParseDuration(average)
+		if err != nil {
+			klog.Errorf("Invalid average latency %q for cluster %q: %v", average, clusterID, err)
+			continue
+		}
+
+		latencies[clusterID] = rtt
+	}
+
+	c.clusterLatencies.Store(latencies)
+}
+
 func (c *Controller) updateLocalClusterIDIfNeeded(clusterID string) {
 	updateNeeded := clusterID != "" && clusterID != c.LocalClusterID()
 	if updateNeeded {
@@ -252,8 +337,8 @@ func getGatewayStatus(obj *unstructured.Unstructured) (connections []interface{}
 	return connections, localClusterID, true
 }
 
-func (c *Controller) getClusterStatusMap() map[string]bool {
-	return c.clusterStatusMap.Load().(map[string]bool)
+func (c *Controller) getClusterStatusMap() map[string]time.Time {
+	return c.clusterStatusMap.Load().(map[string]time.Time)
 }
 
 func (c *Controller) getCheckedClientset(kubeConfig *rest.Config) (dynamic.ResourceInterface, error) {
@@ -269,8 +354,8 @@ func (c *Controller) getCheckedClientset(kubeConfig *rest.Config) (dynamic.Resou
 	return gwClient, err
 }
 
-func copyMap(src map[string]bool) map[string]bool {
-	m := make(map[string]bool)
+func copyMap(src map[string]time.Time) map[string]time.Time {
+	m := make(map[string]time.Time)
 	for k, v := range src {
 		m[k] = v
 	}
@@ -278,11 +363,59 @@ func copyMap(src map[string]bool) map[string]bool {
 	return m
 }
 
+func (c *Controller) setDegraded(degraded bool) {
+	value := int32(0)
+	if degraded {
+		value = 1
+	}
+
+	if atomic.SwapInt32(&c.degraded, value) != value {
+		if degraded {
+			klog.Warningf("The Gateway resources can't be read, determining connectivity with the %q policy", c.getDegradedPolicy())
+		} else {
+			klog.Infof("The Gateway resources can be read again")
+		}
+	}
+
+	degradedGauge.Set(float64(value))
+}
+
+func (c *Controller) getDegradedPolicy() string {
+	if c.DegradedPolicy == "" {
+		return AssumeConnected
+	}
+
+	return c.DegradedPolicy
+}
+
 // Public API
 func (c *Controller) IsConnected(clusterID string) bool {
-	return !c.gatewayAvailable || c.getClusterStatusMap()[clusterID]
+	statusMap := c.getClusterStatusMap()
+
+	if atomic.LoadInt32(&c.degraded) != 0 {
+		switch c.getDegradedPolicy() {
+		case AssumeDisconnected:
+			return clusterID == c.LocalClusterID()
+		case ServeStale:
+			if len(statusMap) == 0 {
+				return true
+			}
+		default:
+			return true
+		}
+	}
+
+	since, connected := statusMap[clusterID]
+
+	return connected && (c.StableFor <= 0 || time.Since(since) >= c.StableFor)
 }
 
 func (c *Controller) LocalClusterID() string {
 	return c.localClusterID.Load().(string)
 }
+
+// Latency returns the average round-trip time to the given cluster's gateway, if known.
+func (c *Controller) Latency(clusterID string) (time.Duration, bool) {
+	rtt, found := c.clusterLatencies.Load().(map[string]time.Duration)[clusterID]
+	return rtt, found
+}
@@ -19,7 +19,9 @@ package gateway_test
 
 import (
 	"context"
+	"os"
 	"testing"
+	"time"
 
 	. "github.com/onsi/ginkgo"
 	. "github.com/onsi/gomega"
@@ -107,6 +109,51 @@ var _ = Describe("Gateway controller", func() {
 		})
 	})
 
+	When("a remote cluster reconnects and a stable period is required", func() {
+		BeforeEach(func() {
+			t.stableFor = time.Second
+			t.addGatewayStatusConnection(remoteClusterID1, "connected")
+		})
+
+		It("should only report it connected once it has been connected for that period", func() {
+			t.createGateway()
+			t.awaitIsConnected(remoteClusterID1)
+
+			t.addGatewayStatusConnection(remoteClusterID1, "error")
+			t.updateGateway()
+			t.awaitIsNotConnected(remoteClusterID1)
+
+			t.addGatewayStatusConnection(remoteClusterID1, "connected")
+			t.updateGateway()
+			Consistently(func() bool {
+				return t.controller.IsConnected(remoteClusterID1)
+			}, 500*time.Millisecond).Should(BeFalse())
+			t.awaitIsConnected(remoteClusterID1)
+		})
+	})
+
+	When("the connections for an active Gateway report latencies", func() {
+		BeforeEach(func() {
+			t.addGatewayStatusConnection(remoteClusterID1, "connected")
+			t.setGatewayConnectionLatency("2.5ms")
+			t.addGatewayStatusConnection(remoteClusterID2, "connected")
+		})
+
+		When("Latency is called for the remote clusters", func() {
+			It("should return the latency of the clusters that report one", func() {
+				t.createGateway()
+				t.awaitLatency(remoteClusterID1, 2500*time.Microsecond)
+
+				_, found := t.controller.Latency(remoteClusterID2)
+				Expect(found).To(BeFalse())
+
+				t.setGatewayConnectionLatency("1ms")
+				t.updateGateway()
+				t.awaitLatency(remoteClusterID2, time.Millisecond)
+			})
+		})
+	})
+
 	When("a passive Gateway is created", func() {
 		BeforeEach(func() {
 			Expect(unstructured.SetNestedField(t.gatewayObj.Object, "passive", "status", "haStatus")).To(Succeed())
@@ -150,6 +197,39 @@ var _ = Describe("Gateway controller", func() {
 			})
 		})
 	})
+
+	When("access to the Gateway resource is forbidden", func() {
+		BeforeEach(func() {
+			t.gatewayReactor.SetFailOnList(errors.NewForbidden(schema.GroupResource{}, "", nil))
+		})
+
+		When("the degraded policy is assume-disconnected", func() {
+			BeforeEach(func() {
+				t.degradedPolicy = gateway.AssumeDisconnected
+				os.Setenv("SUBMARINER_CLUSTERID", localClusterID)
+			})
+
+			AfterEach(func() {
+				os.Unsetenv("SUBMARINER_CLUSTERID")
+			})
+
+			It("should only report the local cluster connected", func() {
+				t.awaitIsConnected(localClusterID)
+				Expect(t.controller.IsConnected(remoteClusterID1)).To(BeFalse())
+			})
+		})
+
+		When("the degraded policy is assume-connected", func() {
+			BeforeEach(func() {
+				t.degradedPolicy = gateway.AssumeConnected
+			})
+
+			It("should report all clusters connected", func() {
+				t.awaitIsConnected(localClusterID)
+				t.awaitIsConnected(remoteClusterID1)
+			})
+		})
+	})
 })
 
 type testDriver struct {
@@ -158,6 +238,8 @@ type testDriver struct {
 	gatewayClient  dynamic.ResourceInterface
 	gatewayReactor *fake.FailingReactor
 	gatewayObj     *unstructured.Unstructured
+	stableFor      time.Duration
+	degradedPolicy string
 }
 
 func newTestDiver() *testDriver {
@@ -174,6 +256,8 @@ func newTestDiver() *testDriver {
 
 		t.gatewayReactor = fake.NewFailingReactorForResource(&t.dynClient.Fake, "gateways")
 		t.gatewayObj = newGateway()
+		t.stableFor = 0
+		t.degradedPolicy = ""
 	})
 
 	JustBeforeEach(func() {
@@ -181,6 +265,8 @@ func newTestDiver() *testDriver {
 		t.controller.NewClientset = func(c *rest.Config) (dynamic.Interface, error) {
 			return t.dynClient, nil
 		}
+		t.controller.StableFor = t.stableFor
+		t.controller.DegradedPolicy = t.degradedPolicy
 
 		Expect(t.controller.Start(&rest.Config{})).To(Succeed())
 	})
@@ -253,6 +339,24 @@ func (t *testDriver) addGatewayStatusConnection(clusterID, status string) *unstr
 	return t.gatewayObj
 }
 
+// setGatewayConnectionLatency sets the average RTT of the most recently added connection.
+func (t *testDriver) setGatewayConnectionLatency(average string) {
+	current, _, err := unstructured.NestedSlice(t.gatewayObj.Object, "status", "connections")
+	Expect(err).To(Succeed())
+
+	conn := current[len(current)-1].(map[string]interface{})
+	Expect(unstructured.SetNestedField(conn, average, "latencyRTT", "average")).To(Succeed())
+
+	Expect(unstructured.SetNestedSlice(t.gatewayObj.Object, current, "status", "connections")).To(Succeed())
+}
+
+func (t *testDriver) awaitLatency(clusterID string, expected time.Duration) {
+	Eventually(func() time.Duration {
+		rtt, _ := t.controller.Latency(clusterID)
+		return rtt
+	}, 5).Should(Equal(expected))
+}
+
 func newGateway() *unstructured.Unstructured {
 	gw := &unstructured.Unstructured{}
 	gw.SetName("test-gateway")
@@ -0,0 +1,427 @@
+/*
+SPDX-License-Identifier: Apache-2.0
+
+Copyright Contributors to the Submariner project.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package gather
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"path"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	lhconstants "github.com/submariner-io/lighthouse/pkg/constants"
+	"github.com/submariner-io/lighthouse/pkg/resolver"
+	corev1 "k8s.io/api/core/v1"
+	discovery "k8s.io/api/discovery/v1beta1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/klog"
+	"sigs.k8s.io/yaml"
+)
+
+const (
+	DefaultNamespace       = "submariner-operator"
+	DefaultBrokerNamespace = "submariner-k8s-broker"
+	DefaultAgentSelector   = "app=submariner-lighthouse-agent"
+	DefaultCoreDNSSelector = "app=submariner-lighthouse-coredns"
+	DefaultLogLines        = int64(1000)
+	dumpTimeout            = 30 * time.Second
+)
+
+var (
+	serviceExportGVR = schema.GroupVersionResource{Group: "multicluster.x-k8s.io", Version: "v1alpha1",
+		Resource: "serviceexports"}
+	serviceImportGVR = schema.GroupVersionResource{Group: "multicluster.x-k8s.io", Version: "v1alpha1",
+		Resource: "serviceimports"}
+	endpointSliceGVR = schema.GroupVersionResource{Group: "discovery.k8s.io", Version: "v1beta1", Resource: "endpointslices"}
+
+	ipv4Pattern = regexp.MustCompile(`\b(?:\d{1,3}\.){3}\d{1,3}\b`)
+	// ipv6Pattern matches candidate IPv6 addresses, which are only redacted if they parse as such, so that e.g. times
+	// and MAC addresses are left alone
+	ipv6Pattern = regexp.MustCompile(`[0-9A-Fa-f]{0,4}(?::[0-9A-Fa-f]{0,4}){2,7}`)
+)
+
+// Dumper returns the state a DNS server resolves from, e.g. a resolver.Client connected to its grpc-endpoint.
+type Dumper interface {
+	Dump(ctx context.Context) (*resolver.State, error)
+}
+
+type Options struct {
+	// Namespace in which the Lighthouse agent and CoreDNS run
+	Namespace string
+	// BrokerNamespace holds the resources synced to the broker
+	BrokerNamespace string
+	// AgentSelector and CoreDNSSelector are label selectors for the agent and CoreDNS pods
+	AgentSelector   string
+	CoreDNSSelector string
+	// LogLines is the number of recent log lines gathered from each pod
+	LogLines int64
+	// RedactIPs replaces IPv4 and IPv6 addresses with stable placeholders, so relationships between resources are
+	// preserved
+	RedactIPs bool
+	// RedactNames replaces the given strings, for example namespace or cluster names, with placeholders
+	RedactNames []string
+	// DNSServer, if set, is dumped for the services and clusters the DNS server resolves from
+	DNSServer Dumper
+}
+
+// Gatherer collects the Lighthouse state of a cluster, and optionally of the broker, into a single archive.
+type Gatherer struct {
+	options      Options
+	kubeClient   kubernetes.Interface
+	dynClient    dynamic.Interface
+	brokerClient dynamic.Interface
+	redactions   map[string]string
+}
+
+// New creates a Gatherer. brokerClient may be nil, in which case the broker view is omitted.
+func New(options Options, kubeClient kubernetes.Interface, dynClient, brokerClient dynamic.Interface) *Gatherer {
+	if options.Namespace == "" {
+		options.Namespace = DefaultNamespace
+	}
+
+	if options.BrokerNamespace == "" {
+		options.BrokerNamespace = DefaultBrokerNamespace
+	}
+
+	if options.AgentSelector == "" {
+		options.AgentSelector = DefaultAgentSelector
+	}
+
+	if options.CoreDNSSelector == "" {
+		options.CoreDNSSelector = DefaultCoreDNSSelector
+	}
+
+	if options.LogLines == 0 {
+		options.LogLines = DefaultLogLines
+	}
+
+	return &Gatherer{
+		options:      options,
+		kubeClient:   kubeClient,
+		dynClient:    dynClient,
+		brokerClient: brokerClient,
+		redactions:   make(map[string]string),
+	}
+}
+
+type archive struct {
+	gatherer *Gatherer
+	writer   *tar.Writer
+	now      time.Time
+}
+
+// Write writes a gzipped tar archive of the gathered state to w. Failures to gather individual items are recorded in
+// the archive's errors.txt rather than aborting the whole gathering.
+func (g *Gatherer) Write(ctx context.Context, w io.Writer) error {
+	gz := gzip.NewWriter(w)
+	a := &archive{gatherer: g, writer: tar.NewWriter(gz), now: time.Now()}
+
+	var errs []string
+
+	record := func(err error) {
+		if err != nil {
+			klog.Warningf("Error gathering: %v", err)
+			errs = append(errs, err.Error())
+		}
+	}
+
+	localImports, err := a.addResources(ctx, g.dynClient, serviceImportGVR, metav1.NamespaceAll, "",
+		"local/serviceimports.yaml")
+	record(err)
+
+	_, err = a.addResources(ctx, g.dynClient, serviceExportGVR, metav1.NamespaceAll, "", "local/serviceexports.yaml")
+	record(err)
+
+	managedBy := discovery.LabelManagedBy + "=" + lhconstants.LabelValueManagedBy
+
+	localSlices, err := a.addResources(ctx, g.dynClient, endpointSliceGVR, metav1.NamespaceAll, managedBy,
+		"local/endpointslices.yaml")
+	record(err)
+
+	record(a.addConfigMaps(ctx))
+	record(a.addPods(ctx, g.options.AgentSelector, "agent"))
+	record(a.addPods(ctx, g.options.CoreDNSSelector, "coredns"))
+
+	if g.options.DNSServer != nil {
+		record(a.addDNSServerState(ctx))
+	}
+
+	if g.brokerClient != nil {
+		brokerImports, err := a.addResources(ctx, g.brokerClient, serviceImportGVR, g.options.BrokerNamespace, "",
+			"broker/serviceimports.yaml")
+		record(err)
+
+		brokerSlices, err := a.addResources(ctx, g.brokerClient, endpointSliceGVR, g.options.BrokerNamespace, "",
+			"broker/endpointslices.yaml")
+		record(err)
+
+		record(a.add("diff.txt", []byte(diff("ServiceImports", localImports, brokerImports)+
+			diff("EndpointSlices", localSlices, brokerSlices))))
+	}
+
+	if len(errs) > 0 {
+		record(a.add("errors.txt", []byte(strings.Join(errs, "\n")+"\n")))
+	}
+
+	if err := a.writer.Close(); err != nil {
+		return fmt.Errorf("error closing the archive: %v", err)
+	}
+
+	if err := gz.Close(); err != nil {
+		return fmt.Errorf("error compressing the archive: %v", err)
+	}
+
+	return nil
+}
+
+func (a *archive) add(name string, content []byte) error {
+	content = a.gatherer.redact(content)
+
+	err := a.writer.WriteHeader(&tar.Header{
+		Name:    name,
+		Mode:    0o644,
+		Size:    int64(len(content)),
+		ModTime: a.now,
+	})
+	if err != nil {
+		return fmt.Errorf("error writing the archive header for %q: %v", name, err)
+	}
+
+	if _, err := a.writer.Write(content); err != nil {
+		return fmt.Errorf("error writing %q to the archive: %v", name, err)
+	}
+
+	return nil
+}
+
+// addResources adds the listed resources to the archive and returns the names they're synced under, i.e. their names
+// without the namespace, so the local and broker views can be compared.
+func (a *archive) addResources(ctx context.Context, client dynamic.Interface, gvr schema.GroupVersionResource, namespace,
+	selector, name string) ([]string, error) {
+	list, err := client.Resource(gvr).Namespace(namespace).List(ctx, metav1.ListOptions{LabelSelector: selector})
+	if err != nil {
+		return nil, fmt.Errorf("error listing %s: %v", gvr.Resource, err)
+	}
+
+	names := make([]string, 0, len(list.Items))
+
+	for i := range list.Items {
+		cleanup(&list.Items[i])
+		names = append(names, list.Items[i].GetName())
+	}
+
+	return names, a.addYAML(name, list)
+}
+
+func (a *archive) addConfigMaps(ctx context.Context) error {
+	configMaps := []struct{ namespace, name string }{
+		{"kube-system", "coredns"},
+		{a.gatherer.options.Namespace, "submariner-lighthouse-coredns"},
+	}
+
+	for _, cm := range configMaps {
+		obj, err := a.gatherer.kubeClient.CoreV1().ConfigMaps(cm.namespace).Get(ctx, cm.name, metav1.GetOptions{})
+		if apierrors.IsNotFound(err) {
+			continue
+		}
+
+		if err != nil {
+			return fmt.Errorf("error retrieving ConfigMap \"%s/%s\": %v", cm.namespace, cm.name, err)
+		}
+
+		obj.ManagedFields = nil
+
+		if err := a.addYAML(path.Join("config", cm.namespace+"-"+cm.name+".yaml"), obj); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (a *archive) addPods(ctx context.Context, selector, dir string) error {
+	pods, err := a.gatherer.kubeClient.CoreV1().Pods(a.gatherer.options.Namespace).List(ctx,
+		metav1.ListOptions{LabelSelector: selector})
+	if err != nil {
+		return fmt.Errorf("error listing %s pods: %v", dir, err)
+	}
+
+	for i := range pods.Items {
+		pods.Items[i].ManagedFields = nil
+	}
+
+	if err := a.addYAML(path.Join(dir, "pods.yaml"), pods); err != nil {
+		return err
+	}
+
+	for i := range pods.Items {
+		logs, err := a.gatherer.kubeClient.CoreV1().Pods(pods.Items[i].Namespace).GetLogs(pods.Items[i].Name,
+			&corev1.PodLogOptions{TailLines: &a.gatherer.options.LogLines}).DoRaw(ctx)
+		if err != nil {
+			return fmt.Errorf("error retrieving the logs of pod %q: %v", pods.Items[i].Name, err)
+		}
+
+		if err := a.add(path.Join(dir, "logs", pods.Items[i].Name+".log"), logs); err != nil {
+			return err
+		}
+
+		// The query logs are written among the other logs, they're extracted to be analyzed on their own
+		if queries := queryLogLines(logs); len(queries) > 0 {
+			if err := a.add(path.Join(dir, "querylogs", pods.Items[i].Name+".jsonl"), queries); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// addDNSServerState adds the state the DNS server resolves from, i.e. the services and clusters in its registry.
+func (a *archive) addDNSServerState(ctx context.Context) error {
+	ctx, cancel := context.WithTimeout(ctx, dumpTimeout)
+	defer cancel()
+
+	state, err := a.gatherer.options.DNSServer.Dump(ctx)
+	if err != nil {
+		return fmt.Errorf("error dumping the DNS server's state: %v", err)
+	}
+
+	return a.addYAML("coredns/state.yaml", state)
+}
+
+// queryLogLines returns the lines of the given logs written by the plugin's querylog option.
+func queryLogLines(logs []byte) []byte {
+	var queries []byte
+
+	for _, line := range bytes.Split(logs, []byte("\n")) {
+		line = bytes.TrimSpace(line)
+		if !bytes.HasPrefix(line, []byte("{")) {
+			continue
+		}
+
+		entry := map[string]interface{}{}
+		if json.Unmarshal(line, &entry) != nil {
+			continue
+		}
+
+		if _, ok := entry["qname"]; ok {
+			queries = append(append(queries, line...), '\n')
+		}
+	}
+
+	return queries
+}
+
+func (a *archive) addYAML(name string, obj interface{}) error {
+	content, err := yaml.Marshal(obj)
+	if err != nil {
+		return fmt.Errorf("error marshalling %q: %v", name, err)
+	}
+
+	return a.add(name, content)
+}
+
+// cleanup removes fields which are noisy and of no use for debugging.
+func cleanup(obj *unstructured.Unstructured) {
+	obj.SetManagedFields(nil)
+
+	annotations := obj.GetAnnotations()
+	if _, ok := annotations[corev1.LastAppliedConfigAnnotation]; ok {
+		delete(annotations, corev1.LastAppliedConfigAnnotation)
+		obj.SetAnnotations(annotations)
+	}
+}
+
+func (g *Gatherer) redact(content []byte) []byte {
+	if g.options.RedactIPs {
+		content = ipv6Pattern.ReplaceAllFunc(content, func(candidate []byte) []byte {
+			if ip := net.ParseIP(string(candidate)); ip == nil || ip.To4() != nil {
+				return candidate
+			}
+
+			return []byte(g.placeholder("ip", string(candidate)))
+		})
+
+		content = ipv4Pattern.ReplaceAllFunc(content, func(ip []byte) []byte {
+			return []byte(g.placeholder("ip", string(ip)))
+		})
+	}
+
+	for _, name := range g.options.RedactNames {
+		if name != "" {
+			content = []byte(strings.ReplaceAll(string(content), name, g.placeholder("name", name)))
+		}
+	}
+
+	return content
+}
+
+func (g *Gatherer) placeholder(kind, value string) string {
+	p, ok := g.redactions[value]
+	if !ok {
+		p = fmt.Sprintf("redacted-%s-%d", kind, len(g.redactions)+1)
+		g.redactions[value] = p
+	}
+
+	return p
+}
+
+func diff(kind string, local, broker []string) string {
+	localSet := make(map[string]bool, len(local))
+	for _, name := range local {
+		localSet[name] = true
+	}
+
+	brokerSet := make(map[string]bool, len(broker))
+	for _, name := range broker {
+		brokerSet[name] = true
+	}
+
+	var missingLocally, missingOnBroker []string
+
+	for name := range brokerSet {
+		if !localSet[name] {
+			missingLocally = append(missingLocally, name)
+		}
+	}
+
+	for name := range localSet {
+		if !brokerSet[name] {
+			missingOnBroker = append(missingOnBroker, name)
+		}
+	}
+
+	sort.Strings(missingLocally)
+	sort.Strings(missingOnBroker)
+
+	return fmt.Sprintf("%s on the broker but not in this cluster: %v\n%s in this cluster but not on the broker: %v\n",
+		kind, missingLocally, kind, missingOnBroker)
+}
@@ -0,0 +1,219 @@
+/*
+SPDX-License-Identifier: Apache-2.0
+
+Copyright Contributors to the Submariner project.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package gather_test
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"errors"
+	"io"
+	"io/ioutil"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/submariner-io/admiral/pkg/fake"
+	"github.com/submariner-io/lighthouse/pkg/gather"
+	"github.com/submariner-io/lighthouse/pkg/resolver"
+	"github.com/submariner-io/lighthouse/pkg/serviceimport"
+	corev1 "k8s.io/api/core/v1"
+	discovery "k8s.io/api/discovery/v1beta1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/dynamic"
+	fakeKubeClient "k8s.io/client-go/kubernetes/fake"
+	mcsv1a1 "sigs.k8s.io/mcs-api/pkg/apis/v1alpha1"
+)
+
+const (
+	serviceIP     = "10.253.9.1"
+	importedName  = "nginx-default-east"
+	missingName   = "nginx-default-west"
+	agentPodName  = "lighthouse-agent-1"
+	coreDNSConfig = "clusterset.local:53 {\n    forward . 10.96.0.10 fd00:10:96::a\n}\n"
+)
+
+var serviceImportGVR = schema.GroupVersionResource{Group: "multicluster.x-k8s.io", Version: "v1alpha1",
+	Resource: "serviceimports"}
+
+var _ = Describe("Gatherer", func() {
+	var (
+		options      gather.Options
+		kubeClient   *fakeKubeClient.Clientset
+		dynClient    dynamic.Interface
+		brokerClient dynamic.Interface
+		files        map[string]string
+	)
+
+	BeforeEach(func() {
+		options = gather.Options{}
+
+		kubeClient = fakeKubeClient.NewSimpleClientset(
+			&corev1.ConfigMap{
+				ObjectMeta: metav1.ObjectMeta{Name: "coredns", Namespace: "kube-system"},
+				Data:       map[string]string{"Corefile": coreDNSConfig},
+			},
+			&corev1.Pod{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      agentPodName,
+					Namespace: gather.DefaultNamespace,
+					Labels:    map[string]string{"app": "submariner-lighthouse-agent"},
+				},
+			})
+
+		scheme := runtime.NewScheme()
+		Expect(corev1.AddToScheme(scheme)).To(Succeed())
+		Expect(discovery.AddToScheme(scheme)).To(Succeed())
+		Expect(mcsv1a1.AddToScheme(scheme)).To(Succeed())
+
+		dynClient = fake.NewDynamicClient(scheme)
+		brokerClient = fake.NewDynamicClient(scheme)
+
+		createServiceImport(dynClient, gather.DefaultNamespace, importedName)
+		createServiceImport(brokerClient, gather.DefaultBrokerNamespace, importedName)
+		createServiceImport(brokerClient, gather.DefaultBrokerNamespace, missingName)
+	})
+
+	JustBeforeEach(func() {
+		buf := &bytes.Buffer{}
+		Expect(gather.New(options, kubeClient, dynClient, brokerClient).Write(context.TODO(), buf)).To(Succeed())
+		files = readArchive(buf)
+	})
+
+	It("should include the local and broker resources", func() {
+		Expect(files).To(HaveKey("local/serviceimports.yaml"))
+		Expect(files["local/serviceimports.yaml"]).To(ContainSubstring(importedName))
+		Expect(files).To(HaveKey("local/serviceexports.yaml"))
+		Expect(files).To(HaveKey("local/endpointslices.yaml"))
+		Expect(files["broker/serviceimports.yaml"]).To(ContainSubstring(missingName))
+	})
+
+	It("should include the CoreDNS configuration", func() {
+		Expect(files["config/kube-system-coredns.yaml"]).To(ContainSubstring("clusterset.local:53"))
+	})
+
+	It("should include the agent pods and their logs", func() {
+		Expect(files["agent/pods.yaml"]).To(ContainSubstring(agentPodName))
+		Expect(files).To(HaveKey("agent/logs/" + agentPodName + ".log"))
+	})
+
+	It("should report the differences between the local and broker views", func() {
+		Expect(files["diff.txt"]).To(ContainSubstring("ServiceImports on the broker but not in this cluster: [" + missingName + "]"))
+	})
+
+	When("IP redaction is requested", func() {
+		BeforeEach(func() {
+			options.RedactIPs = true
+		})
+
+		It("should replace the IPs with placeholders", func() {
+			Expect(files["local/serviceimports.yaml"]).ToNot(ContainSubstring(serviceIP))
+			Expect(files["local/serviceimports.yaml"]).To(ContainSubstring("redacted-ip-"))
+			Expect(files["config/kube-system-coredns.yaml"]).ToNot(ContainSubstring("10.96.0.10"))
+		})
+
+		It("should replace the IPv6 addresses too", func() {
+			Expect(files["config/kube-system-coredns.yaml"]).ToNot(ContainSubstring("fd00:10:96::a"))
+			Expect(files["config/kube-system-coredns.yaml"]).To(ContainSubstring("clusterset.local:53"))
+		})
+	})
+
+	When("a DNS server is given", func() {
+		BeforeEach(func() {
+			options.DNSServer = &fakeDumper{state: &resolver.State{
+				LocalClusterID: "east",
+				ServiceImports: []serviceimport.ServiceState{{Name: "nginx", Namespace: "default"}},
+			}}
+		})
+
+		It("should include the state it resolves from", func() {
+			Expect(files["coredns/state.yaml"]).To(ContainSubstring("east"))
+			Expect(files["coredns/state.yaml"]).To(ContainSubstring("nginx"))
+		})
+
+		Context("and it can't be dumped", func() {
+			BeforeEach(func() {
+				options.DNSServer = &fakeDumper{err: errors.New("connection refused")}
+			})
+
+			It("should record the error", func() {
+				Expect(files).ToNot(HaveKey("coredns/state.yaml"))
+				Expect(files["errors.txt"]).To(ContainSubstring("connection refused"))
+			})
+		})
+	})
+
+	When("name redaction is requested", func() {
+		BeforeEach(func() {
+			options.RedactNames = []string{"nginx"}
+		})
+
+		It("should replace the names with placeholders", func() {
+			Expect(files["local/serviceimports.yaml"]).ToNot(ContainSubstring("nginx"))
+			Expect(files["diff.txt"]).To(ContainSubstring("redacted-name-1-default-west"))
+		})
+	})
+})
+
+type fakeDumper struct {
+	state *resolver.State
+	err   error
+}
+
+func (d *fakeDumper) Dump(context.Context) (*resolver.State, error) {
+	return d.state, d.err
+}
+
+func createServiceImport(client dynamic.Interface, namespace, name string) {
+	si := &unstructured.Unstructured{}
+	si.SetAPIVersion("multicluster.x-k8s.io/v1alpha1")
+	si.SetKind("ServiceImport")
+	si.SetNamespace(namespace)
+	si.SetName(name)
+	Expect(unstructured.SetNestedStringSlice(si.Object, []string{serviceIP}, "spec", "ips")).To(Succeed())
+
+	_, err := client.Resource(serviceImportGVR).Namespace(namespace).Create(context.TODO(), si, metav1.CreateOptions{})
+	Expect(err).To(Succeed())
+}
+
+func readArchive(buf *bytes.Buffer) map[string]string {
+	gz, err := gzip.NewReader(buf)
+	Expect(err).To(Succeed())
+
+	files := map[string]string{}
+	reader := tar.NewReader(gz)
+
+	for {
+		header, err := reader.Next()
+		if err == io.EOF {
+			break
+		}
+
+		Expect(err).To(Succeed())
+
+		content, err := ioutil.ReadAll(reader)
+		Expect(err).To(Succeed())
+
+		files[header.Name] = string(content)
+	}
+
+	return files
+}
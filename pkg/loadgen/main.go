@@ -0,0 +1,316 @@
+/*
+SPDX-License-Identifier: Apache-2.0
+
+Copyright Contributors to the Submariner project.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"math/rand"
+	"net"
+	"os"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/miekg/dns"
+	lhconstants "github.com/submariner-io/lighthouse/pkg/constants"
+	"github.com/submariner-io/lighthouse/plugin/lighthouse"
+	corev1 "k8s.io/api/core/v1"
+	discovery "k8s.io/api/discovery/v1beta1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	mcsv1a1 "sigs.k8s.io/mcs-api/pkg/apis/v1alpha1"
+)
+
+const zone = "clusterset.local."
+
+type options struct {
+	services      int
+	namespaces    int
+	clusters      int
+	headlessRatio float64
+	endpoints     int
+	queries       int
+	concurrency   int
+	srvRatio      float64
+	nxDomainRatio float64
+	skew          float64
+	seed          int64
+}
+
+func main() {
+	opts := options{}
+
+	flag.IntVar(&opts.services, "services", 10000, "Number of services exported.")
+	flag.IntVar(&opts.namespaces, "namespaces", 100, "Number of namespaces the services are spread over.")
+	flag.IntVar(&opts.clusters, "clusters", 3, "Number of clusters exporting each service.")
+	flag.Float64Var(&opts.headlessRatio, "headless-ratio", 0.1, "Fraction of the services which are headless.")
+	flag.IntVar(&opts.endpoints, "endpoints", 20, "Number of endpoints of each headless service in each cluster.")
+	flag.IntVar(&opts.queries, "queries", 1000000, "Number of queries to make.")
+	flag.IntVar(&opts.concurrency, "concurrency", 8, "Number of concurrent clients.")
+	flag.Float64Var(&opts.srvRatio, "srv-ratio", 0.2, "Fraction of the queries which are for SRV records; the others are for A records.")
+	flag.Float64Var(&opts.nxDomainRatio, "nxdomain-ratio", 0.05, "Fraction of the queries for services which don't exist.")
+	flag.Float64Var(&opts.skew, "skew", 1.1,
+		"Skew of the Zipf distribution of queries over services, greater than 1; the higher it is, the more queries go to the "+
+			"most popular services.")
+	flag.Int64Var(&opts.seed, "seed", 1, "Seed of the pseudo-random generation of the services and queries.")
+	flag.Parse()
+
+	if err := opts.validate(); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(2)
+	}
+
+	start := time.Now()
+	handler := lighthouse.NewStatic([]string{zone}, opts.serviceImports(), opts.endpointSlices())
+	fmt.Printf("Loaded %d services exported by %d clusters in %v\n", opts.services, opts.clusters, time.Since(start))
+
+	run(handler, &opts)
+}
+
+func (o *options) validate() error {
+	switch {
+	case o.services < 1 || o.namespaces < 1 || o.clusters < 1 || o.queries < 1 || o.concurrency < 1:
+		return fmt.Errorf("the numbers of services, namespaces, clusters, queries and clients must be positive")
+	case o.headlessRatio < 0 || o.headlessRatio > 1 || o.srvRatio < 0 || o.srvRatio > 1 || o.nxDomainRatio < 0 ||
+		o.nxDomainRatio > 1:
+		return fmt.Errorf("ratios must be between 0 and 1")
+	case o.skew <= 1:
+		return fmt.Errorf("the skew must be greater than 1")
+	}
+
+	return nil
+}
+
+func (o *options) isHeadless(i int) bool {
+	return float64(i%100) < o.headlessRatio*100
+}
+
+func (o *options) serviceName(i int) (name, namespace string) {
+	return fmt.Sprintf("service-%d", i), fmt.Sprintf("namespace-%d", i%o.namespaces)
+}
+
+func clusterID(c int) string {
+	return fmt.Sprintf("cluster-%d", c)
+}
+
+func (o *options) serviceImports() []*mcsv1a1.ServiceImport {
+	serviceImports := make([]*mcsv1a1.ServiceImport, 0, o.services*o.clusters)
+
+	for i := 0; i < o.services; i++ {
+		name, namespace := o.serviceName(i)
+
+		siType, ips := mcsv1a1.ClusterSetIP, []string{}
+
+		if o.isHeadless(i) {
+			siType = mcsv1a1.Headless
+		}
+
+		for c := 0; c < o.clusters; c++ {
+			if siType == mcsv1a1.ClusterSetIP {
+				ips = []string{fmt.Sprintf("10.%d.%d.%d", 100+c, i/256%256, i%256)}
+			}
+
+			serviceImports = append(serviceImports, &mcsv1a1.ServiceImport{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      name + "-" + namespace + "-" + clusterID(c),
+					Namespace: "submariner-operator",
+					Annotations: map[string]string{
+						lhconstants.OriginName:      name,
+						lhconstants.OriginNamespace: namespace,
+					},
+					Labels: map[string]string{lhconstants.LabelSourceCluster: clusterID(c)},
+				},
+				Spec: mcsv1a1.ServiceImportSpec{
+					Type:  siType,
+					IPs:   ips,
+					Ports: []mcsv1a1.ServicePort{{Name: "http", Protocol: corev1.ProtocolTCP, Port: 80}},
+				},
+				Status: mcsv1a1.ServiceImportStatus{Clusters: []mcsv1a1.ClusterStatus{{Cluster: clusterID(c)}}},
+			})
+		}
+	}
+
+	return serviceImports
+}
+
+func (o *options) endpointSlices() []*discovery.EndpointSlice {
+	endpointSlices := []*discovery.EndpointSlice{}
+	portName, protocol, port := "http", corev1.ProtocolTCP, int32(80)
+	ready := true
+
+	for i := 0; i < o.services; i++ {
+		if !o.isHeadless(i) {
+			continue
+		}
+
+		name, namespace := o.serviceName(i)
+
+		for c := 0; c < o.clusters; c++ {
+			endpoints := make([]discovery.Endpoint, o.endpoints)
+
+			for e := range endpoints {
+				hostname := fmt.Sprintf("pod-%d", e)
+				endpoints[e] = discovery.Endpoint{
+					Addresses:  []string{fmt.Sprintf("100.%d.%d.%d", 96+c, e/256%256, e%256)},
+					Hostname:   &hostname,
+					Conditions: discovery.EndpointConditions{Ready: &ready},
+				}
+			}
+
+			endpointSlices = append(endpointSlices, &discovery.EndpointSlice{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      name + "-" + clusterID(c),
+					Namespace: namespace,
+					Labels: map[string]string{
+						discovery.LabelManagedBy:         lhconstants.LabelValueManagedBy,
+						lhconstants.LabelSourceNamespace: namespace,
+						lhconstants.LabelSourceCluster:   clusterID(c),
+						lhconstants.LabelSourceName:      name,
+					},
+				},
+				AddressType: discovery.AddressTypeIPv4,
+				Endpoints:   endpoints,
+				Ports:       []discovery.EndpointPort{{Name: &portName, Protocol: &protocol, Port: &port}},
+			})
+		}
+	}
+
+	return endpointSlices
+}
+
+// run makes the queries from concurrent clients, each drawing services from a Zipf distribution so that a few
+// services receive most of the queries, as in real clustersets, and reports the throughput and latencies.
+func run(handler *lighthouse.Lighthouse, o *options) {
+	latencies := make([][]time.Duration, o.concurrency)
+	rcodes := make([]map[int]int, o.concurrency)
+
+	var wg sync.WaitGroup
+
+	start := time.Now()
+
+	for client := 0; client < o.concurrency; client++ {
+		queries := o.queries / o.concurrency
+		if client < o.queries%o.concurrency {
+			queries++
+		}
+
+		wg.Add(1)
+
+		go func(client, queries int) {
+			defer wg.Done()
+
+			random := rand.New(rand.NewSource(o.seed + int64(client)))
+			zipf := rand.NewZipf(random, o.skew, 1, uint64(o.services-1))
+			w := &discardWriter{remote: &net.UDPAddr{IP: net.IPv4(10, 0, byte(client/256), byte(client%256)), Port: 53}}
+
+			latencies[client] = make([]time.Duration, 0, queries)
+			rcodes[client] = map[int]int{}
+
+			for q := 0; q < queries; q++ {
+				name, namespace := o.serviceName(int(zipf.Uint64()))
+				if random.Float64() < o.nxDomainRatio {
+					name = "missing-" + name
+				}
+
+				qtype := dns.TypeA
+				if random.Float64() < o.srvRatio {
+					qtype = dns.TypeSRV
+				}
+
+				m := new(dns.Msg)
+				m.SetQuestion(name+"."+namespace+".svc."+zone, qtype)
+
+				queryStart := time.Now()
+				rcode, _ := handler.ServeDNS(context.TODO(), w, m)
+				latencies[client] = append(latencies[client], time.Since(queryStart))
+				rcodes[client][rcode]++
+			}
+		}(client, queries)
+	}
+
+	wg.Wait()
+
+	report(time.Since(start), latencies, rcodes)
+}
+
+func report(elapsed time.Duration, latencies [][]time.Duration, rcodes []map[int]int) {
+	all := []time.Duration{}
+	for _, l := range latencies {
+		all = append(all, l...)
+	}
+
+	sort.Slice(all, func(i, j int) bool {
+		return all[i] < all[j]
+	})
+
+	percentile := func(p float64) time.Duration {
+		return all[int(p*float64(len(all)-1))]
+	}
+
+	fmt.Printf("Made %d queries in %v: %.0f queries/s\n", len(all), elapsed, float64(len(all))/elapsed.Seconds())
+	fmt.Printf("Latency: p50 %v, p90 %v, p99 %v, p99.9 %v, max %v\n", percentile(0.5), percentile(0.9), percentile(0.99),
+		percentile(0.999), all[len(all)-1])
+
+	totals := map[int]int{}
+
+	for _, r := range rcodes {
+		for rcode, count := range r {
+			totals[rcode] += count
+		}
+	}
+
+	for rcode, count := range totals {
+		fmt.Printf("%s: %d\n", dns.RcodeToString[rcode], count)
+	}
+}
+
+// discardWriter is a dns.ResponseWriter discarding the responses, so that only the handler is measured.
+type discardWriter struct {
+	remote net.Addr
+}
+
+func (w *discardWriter) LocalAddr() net.Addr {
+	return &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1), Port: 53}
+}
+
+func (w *discardWriter) RemoteAddr() net.Addr {
+	return w.remote
+}
+
+func (w *discardWriter) WriteMsg(m *dns.Msg) error {
+	return nil
+}
+
+func (w *discardWriter) Write(b []byte) (int, error) {
+	return len(b), nil
+}
+
+func (w *discardWriter) Close() error {
+	return nil
+}
+
+func (w *discardWriter) TsigStatus() error {
+	return nil
+}
+
+func (w *discardWriter) TsigTimersOnly(bool) {
+}
+
+func (w *discardWriter) Hijack() {
+}
@@ -0,0 +1,130 @@
+/*
+SPDX-License-Identifier: Apache-2.0
+
+Copyright Contributors to the Submariner project.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+// Package maintenance tracks the clusters of the clusterset which operators have put in maintenance, by annotating
+// their Submariner Cluster resources, so that the resolver can drain the traffic to them.
+package maintenance
+
+import (
+	"context"
+	"strconv"
+	"sync/atomic"
+
+	lhconstants "github.com/submariner-io/lighthouse/pkg/constants"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/watch"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/tools/cache"
+	"k8s.io/klog"
+)
+
+// DefaultNamespace is the namespace the Cluster resources are synced to from the broker in the member clusters
+const DefaultNamespace = "submariner-operator"
+
+// ClusterGVR identifies the Submariner Cluster resources, which list the members of the clusterset.
+var ClusterGVR = schema.GroupVersionResource{Group: "submariner.io", Version: "v1", Resource: "clusters"}
+
+// IsInMaintenance checks whether the given Cluster resource is annotated as in maintenance.
+func IsInMaintenance(cluster *unstructured.Unstructured) bool {
+	inMaintenance, err := strconv.ParseBool(cluster.GetAnnotations()[lhconstants.Maintenance])
+	return err == nil && inMaintenance
+}
+
+// ClusterID returns the ID of the cluster the given Cluster resource describes.
+func ClusterID(cluster *unstructured.Unstructured) string {
+	if clusterID, found, _ := unstructured.NestedString(cluster.Object, "spec", "cluster_id"); found && clusterID != "" {
+		return clusterID
+	}
+
+	return cluster.GetName()
+}
+
+// Watcher tracks the clusters in maintenance.
+type Watcher struct {
+	client   dynamic.ResourceInterface
+	store    cache.Store
+	informer cache.Controller
+	clusters atomic.Value // map[string]bool
+}
+
+func NewWatcher(client dynamic.Interface, namespace string) *Watcher {
+	w := &Watcher{client: client.Resource(ClusterGVR).Namespace(namespace)}
+	w.clusters.Store(map[string]bool{})
+
+	update := func(interface{}) {
+		w.update()
+	}
+
+	w.store, w.informer = cache.NewInformer(&cache.ListWatch{
+		ListFunc: func(options metav1.ListOptions) (runtime.Object, error) {
+			return w.client.List(context.TODO(), options)
+		},
+		WatchFunc: func(options metav1.ListOptions) (watch.Interface, error) {
+			return w.client.Watch(context.TODO(), options)
+		},
+	}, &unstructured.Unstructured{}, 0, cache.ResourceEventHandlerFuncs{
+		AddFunc: update,
+		UpdateFunc: func(_, _ interface{}) {
+			w.update()
+		},
+		DeleteFunc: update,
+	})
+
+	return w
+}
+
+// Start starts watching the Cluster resources. It doesn't wait for them to be listed, so that a missing CRD doesn't
+// hold back the caller; until they are, no cluster is in maintenance.
+func (w *Watcher) Start(stopCh <-chan struct{}) {
+	klog.Infof("Watching the clusters in maintenance")
+
+	go w.informer.Run(stopCh)
+}
+
+// InMaintenance checks whether the given cluster is in maintenance.
+func (w *Watcher) InMaintenance(clusterID string) bool {
+	return w.clusters.Load().(map[string]bool)[clusterID]
+}
+
+func (w *Watcher) update() {
+	clusters := map[string]bool{}
+
+	for _, obj := range w.store.List() {
+		cluster := obj.(*unstructured.Unstructured)
+		if IsInMaintenance(cluster) {
+			clusters[ClusterID(cluster)] = true
+		}
+	}
+
+	previous := w.clusters.Load().(map[string]bool)
+	for clusterID := range clusters {
+		if !previous[clusterID] {
+			klog.Infof("Cluster %q is in maintenance, excluding it from the answers", clusterID)
+		}
+	}
+
+	for clusterID := range previous {
+		if !clusters[clusterID] {
+			klog.Infof("Cluster %q is out of maintenance", clusterID)
+		}
+	}
+
+	w.clusters.Store(clusters)
+}
@@ -0,0 +1,112 @@
+/*
+SPDX-License-Identifier: Apache-2.0
+
+Copyright Contributors to the Submariner project.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package maintenance_test
+
+import (
+	"context"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	lhconstants "github.com/submariner-io/lighthouse/pkg/constants"
+	"github.com/submariner-io/lighthouse/pkg/maintenance"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/dynamic"
+	fakeClient "k8s.io/client-go/dynamic/fake"
+)
+
+const namespace = "submariner"
+
+var _ = Describe("Watcher", func() {
+	var (
+		client  dynamic.Interface
+		watcher *maintenance.Watcher
+		stopCh  chan struct{}
+	)
+
+	newCluster := func(name, inMaintenance string) *unstructured.Unstructured {
+		obj := &unstructured.Unstructured{}
+		obj.SetAPIVersion(maintenance.ClusterGVR.GroupVersion().String())
+		obj.SetKind("Cluster")
+		obj.SetName(name)
+		obj.SetNamespace(namespace)
+		Expect(unstructured.SetNestedField(obj.Object, name, "spec", "cluster_id")).To(Succeed())
+
+		if inMaintenance != "" {
+			obj.SetAnnotations(map[string]string{lhconstants.Maintenance: inMaintenance})
+		}
+
+		return obj
+	}
+
+	BeforeEach(func() {
+		stopCh = make(chan struct{})
+		client = fakeClient.NewSimpleDynamicClient(runtime.NewScheme(), newCluster("east", "true"),
+			newCluster("west", ""), newCluster("north", "false"))
+		watcher = maintenance.NewWatcher(client, namespace)
+		watcher.Start(stopCh)
+	})
+
+	AfterEach(func() {
+		close(stopCh)
+	})
+
+	When("a cluster is annotated as in maintenance", func() {
+		It("should report it in maintenance", func() {
+			Eventually(func() bool {
+				return watcher.InMaintenance("east")
+			}).Should(BeTrue())
+		})
+	})
+
+	When("a cluster isn't annotated as in maintenance", func() {
+		It("should not report it in maintenance", func() {
+			Eventually(func() bool {
+				return watcher.InMaintenance("east")
+			}).Should(BeTrue())
+
+			Expect(watcher.InMaintenance("west")).To(BeFalse())
+			Expect(watcher.InMaintenance("north")).To(BeFalse())
+		})
+	})
+
+	When("a cluster's maintenance annotation changes", func() {
+		It("should track it", func() {
+			Eventually(func() bool {
+				return watcher.InMaintenance("east")
+			}).Should(BeTrue())
+
+			_, err := client.Resource(maintenance.ClusterGVR).Namespace(namespace).Update(context.TODO(),
+				newCluster("east", ""), metav1.UpdateOptions{})
+			Expect(err).To(Succeed())
+
+			_, err = client.Resource(maintenance.ClusterGVR).Namespace(namespace).Update(context.TODO(),
+				newCluster("west", "true"), metav1.UpdateOptions{})
+			Expect(err).To(Succeed())
+
+			Eventually(func() bool {
+				return watcher.InMaintenance("west")
+			}).Should(BeTrue())
+
+			Eventually(func() bool {
+				return watcher.InMaintenance("east")
+			}).Should(BeFalse())
+		})
+	})
+})
@@ -0,0 +1,80 @@
+/*
+SPDX-License-Identifier: Apache-2.0
+
+Copyright Contributors to the Submariner project.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package resolver
+
+import "sync"
+
+// Changes notifies the streams watching a service when the data it resolves from changes, so that watches don't
+// need to poll. A nil Changes notifies nothing.
+type Changes struct {
+	mutex    sync.Mutex
+	watchers map[string]map[chan struct{}]bool
+}
+
+func NewChanges() *Changes {
+	return &Changes{watchers: map[string]map[chan struct{}]bool{}}
+}
+
+// ServiceChanged notifies the streams watching the given service.
+func (c *Changes) ServiceChanged(name, namespace string) {
+	if c == nil {
+		return
+	}
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	for ch := range c.watchers[namespace+"/"+name] {
+		// A pending notification already covers this change
+		select {
+		case ch <- struct{}{}:
+		default:
+		}
+	}
+}
+
+// subscribe returns a channel receiving a value whenever the given service changes, and the function to stop
+// notifying it.
+func (c *Changes) subscribe(name, namespace string) (<-chan struct{}, func()) {
+	ch := make(chan struct{}, 1)
+	if c == nil {
+		return ch, func() {}
+	}
+
+	key := namespace + "/" + name
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	if c.watchers[key] == nil {
+		c.watchers[key] = map[chan struct{}]bool{}
+	}
+
+	c.watchers[key][ch] = true
+
+	return ch, func() {
+		c.mutex.Lock()
+		defer c.mutex.Unlock()
+
+		delete(c.watchers[key], ch)
+
+		if len(c.watchers[key]) == 0 {
+			delete(c.watchers, key)
+		}
+	}
+}
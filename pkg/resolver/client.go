@@ -0,0 +1,168 @@
+/*
+SPDX-License-Identifier: Apache-2.0
+
+Copyright Contributors to the Submariner project.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package resolver
+
+import (
+	"context"
+	"crypto/tls"
+	"time"
+
+	"github.com/submariner-io/lighthouse/pkg/serviceimport"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/protobuf/types/known/emptypb"
+	"google.golang.org/protobuf/types/known/structpb"
+	"google.golang.org/protobuf/types/known/wrapperspb"
+	v1 "k8s.io/api/core/v1"
+	mcsv1a1 "sigs.k8s.io/mcs-api/pkg/apis/v1alpha1"
+)
+
+// Client calls a resolver server, e.g. from diagnostic tools.
+type Client struct {
+	conn *grpc.ClientConn
+}
+
+// Dial connects to a resolver server, over TLS if tlsConfig is set.
+func Dial(address string, tlsConfig *tls.Config) (*Client, error) {
+	option := grpc.WithInsecure()
+	if tlsConfig != nil {
+		option = grpc.WithTransportCredentials(credentials.NewTLS(tlsConfig))
+	}
+
+	conn, err := grpc.Dial(address, option)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Client{conn: conn}, nil
+}
+
+func (c *Client) Close() error {
+	return c.conn.Close()
+}
+
+// Resolve returns the endpoint set the server currently resolves the given service to.
+func (c *Client) Resolve(ctx context.Context, serviceName string) (found bool, records []serviceimport.DNSRecord, err error) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	stream, err := c.conn.NewStream(ctx, &serviceDesc.Streams[0], WatchMethod)
+	if err != nil {
+		return false, nil, err
+	}
+
+	if err := stream.SendMsg(wrapperspb.String(serviceName)); err != nil {
+		return false, nil, err
+	}
+
+	if err := stream.CloseSend(); err != nil {
+		return false, nil, err
+	}
+
+	msg := &structpb.Struct{}
+	if err := stream.RecvMsg(msg); err != nil {
+		return false, nil, err
+	}
+
+	return msg.Fields["found"].GetBoolValue(), recordsFromValue(msg.Fields["endpoints"]), nil
+}
+
+// Dump returns the data the server resolves from.
+func (c *Client) Dump(ctx context.Context) (*State, error) {
+	msg := &structpb.Struct{}
+	if err := c.conn.Invoke(ctx, DumpMethod, &emptypb.Empty{}, msg); err != nil {
+		return nil, err
+	}
+
+	state := &State{
+		LocalClusterID: msg.Fields["localCluster"].GetStringValue(),
+		ServiceImports: servicesFromValue(msg.Fields["serviceImports"]),
+		EndpointSlices: servicesFromValue(msg.Fields["endpointSlices"]),
+	}
+
+	for _, value := range msg.Fields["clusters"].GetListValue().GetValues() {
+		fields := value.GetStructValue().GetFields()
+		latency, _ := time.ParseDuration(fields["latency"].GetStringValue())
+
+		state.Clusters = append(state.Clusters, ClusterState{
+			ID:        fields["id"].GetStringValue(),
+			Connected: fields["connected"].GetBoolValue(),
+			Latency:   latency,
+		})
+	}
+
+	for _, value := range msg.Fields["hotNames"].GetListValue().GetValues() {
+		fields := value.GetStructValue().GetFields()
+
+		state.HotNames = append(state.HotNames, HotName{
+			Name:    fields["name"].GetStringValue(),
+			Type:    fields["type"].GetStringValue(),
+			Queries: uint64(fields["queries"].GetNumberValue()),
+		})
+	}
+
+	return state, nil
+}
+
+func servicesFromValue(value *structpb.Value) []serviceimport.ServiceState {
+	var services []serviceimport.ServiceState
+
+	for _, service := range value.GetListValue().GetValues() {
+		fields := service.GetStructValue().GetFields()
+		services = append(services, serviceimport.ServiceState{
+			Name:                          fields["name"].GetStringValue(),
+			Namespace:                     fields["namespace"].GetStringValue(),
+			Headless:                      fields["headless"].GetBoolValue(),
+			SessionAffinity:               v1.ServiceAffinity(fields["sessionAffinity"].GetStringValue()),
+			SessionAffinityTimeoutSeconds: int32(fields["sessionAffinityTimeout"].GetNumberValue()),
+			Records:                       recordsFromValue(fields["endpoints"]),
+		})
+	}
+
+	return services
+}
+
+func recordsFromValue(value *structpb.Value) []serviceimport.DNSRecord {
+	var records []serviceimport.DNSRecord
+
+	for _, endpoint := range value.GetListValue().GetValues() {
+		fields := endpoint.GetStructValue().GetFields()
+		record := serviceimport.DNSRecord{
+			IP:          fields["ip"].GetStringValue(),
+			HostName:    fields["hostname"].GetStringValue(),
+			ClusterName: fields["cluster"].GetStringValue(),
+		}
+
+		for _, ip := range fields["ips"].GetListValue().GetValues() {
+			record.IPs = append(record.IPs, ip.GetStringValue())
+		}
+
+		for _, port := range fields["ports"].GetListValue().GetValues() {
+			portFields := port.GetStructValue().GetFields()
+			record.Ports = append(record.Ports, mcsv1a1.ServicePort{
+				Name:     portFields["name"].GetStringValue(),
+				Protocol: v1.Protocol(portFields["protocol"].GetStringValue()),
+				Port:     int32(portFields["port"].GetNumberValue()),
+			})
+		}
+
+		records = append(records, record)
+	}
+
+	return records
+}
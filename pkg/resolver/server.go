@@ -0,0 +1,442 @@
+/*
+SPDX-License-Identifier: Apache-2.0
+
+Copyright Contributors to the Submariner project.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package resolver
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"net"
+	"reflect"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/submariner-io/lighthouse/pkg/serviceimport"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/emptypb"
+	"google.golang.org/protobuf/types/known/structpb"
+	"google.golang.org/protobuf/types/known/wrapperspb"
+	"k8s.io/klog"
+)
+
+const (
+	ServiceName  = "lighthouse.resolver.v1.Resolver"
+	WatchMethod  = "/" + ServiceName + "/Watch"
+	ReportMethod = "/" + ServiceName + "/Report"
+	DumpMethod   = "/" + ServiceName + "/Dump"
+
+	defaultInterval = 30 * time.Second
+)
+
+// Source supplies the endpoint sets served to watchers. It is implemented by the DNS plugin so that gRPC clients
+// see the same records the DNS handler would answer with.
+type Source interface {
+	Resolve(name, namespace string) ([]serviceimport.DNSRecord, bool)
+}
+
+// Reporter receives connectivity signals for services in clusters, for example from health probers. Sources that
+// implement it are registered for the Report method.
+type Reporter interface {
+	ReportConnectivity(name, namespace, clusterID string, success bool)
+}
+
+// Introspector exposes the data a Source resolves from, so that diagnostic tools can compare it across clusters.
+// Sources that implement it are registered for the Dump method.
+type Introspector interface {
+	State() State
+}
+
+// State is a snapshot of the data a Source resolves from.
+type State struct {
+	LocalClusterID string
+	Clusters       []ClusterState
+	ServiceImports []serviceimport.ServiceState
+	EndpointSlices []serviceimport.ServiceState
+	// HotNames lists the most queried names, most queried first, if the Source tracks them
+	HotNames []HotName
+}
+
+// HotName is a name queried often enough for its answer to be prefetched, with its recent number of queries.
+type HotName struct {
+	Name    string
+	Type    string
+	Queries uint64
+}
+
+// ClusterState describes a cluster as seen by a Source; a zero Latency means it isn't known.
+type ClusterState struct {
+	ID        string
+	Connected bool
+	Latency   time.Duration
+}
+
+// Server implements a minimal server-streaming resolver API. A client sends the name of a service, either as
+// "service.namespace" or as a fully-qualified clusterset name, and receives the current endpoint set followed by a
+// new message each time the set changes. Clients may also report the outcome of connections to a service in a
+// cluster, as a struct with "service", "cluster" and "success" fields, and dump the data the server resolves from.
+type Server struct {
+	// Interval at which watched services are re-evaluated even if no change is notified, e.g. to pick up changes to the
+	// connectivity of clusters
+	Interval time.Duration
+	// TLSConfig, if set, is used to serve over TLS; it should require client certificates since the server exposes the
+	// state of the Source
+	TLSConfig *tls.Config
+	source    Source
+	changes   *Changes
+	server    *grpc.Server
+}
+
+// The request and response types are protobuf well-known types so that no generated code is required.
+var serviceDesc = grpc.ServiceDesc{
+	ServiceName: ServiceName,
+	HandlerType: (*resolverServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "Report",
+			Handler:    reportHandler,
+		},
+		{
+			MethodName: "Dump",
+			Handler:    dumpHandler,
+		},
+	},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "Watch",
+			Handler:       watchHandler,
+			ServerStreams: true,
+		},
+	},
+	Metadata: "lighthouse/resolver.proto",
+}
+
+type resolverServer interface {
+	watch(serviceName string, stream watchStream) error
+	report(ctx context.Context, request *structpb.Struct) error
+	dump() (*structpb.Struct, error)
+}
+
+type watchStream interface {
+	Context() context.Context
+	SendMsg(m interface{}) error
+}
+
+// NewServer creates a server for the given Source, whose watches are notified of service changes by changes.
+func NewServer(source Source, changes *Changes) *Server {
+	return &Server{
+		Interval: defaultInterval,
+		source:   source,
+		changes:  changes,
+	}
+}
+
+func (s *Server) Start(address string) error {
+	listener, err := net.Listen("tcp", address)
+	if err != nil {
+		return fmt.Errorf("error listening on %q: %v", address, err)
+	}
+
+	var options []grpc.ServerOption
+	if s.TLSConfig != nil {
+		options = append(options, grpc.Creds(credentials.NewTLS(s.TLSConfig)))
+	}
+
+	s.server = grpc.NewServer(options...)
+	s.server.RegisterService(&serviceDesc, s)
+
+	klog.Infof("Starting resolver gRPC server on %q", listener.Addr())
+
+	go func() {
+		if err := s.server.Serve(listener); err != nil {
+			klog.Errorf("Error serving resolver gRPC requests: %v", err)
+		}
+	}()
+
+	return nil
+}
+
+func (s *Server) Stop() {
+	if s.server != nil {
+		s.server.GracefulStop()
+		klog.Infof("Resolver gRPC server stopped")
+	}
+}
+
+func watchHandler(srv interface{}, stream grpc.ServerStream) error {
+	request := &wrapperspb.StringValue{}
+	if err := stream.RecvMsg(request); err != nil {
+		return err
+	}
+
+	return srv.(resolverServer).watch(request.GetValue(), stream)
+}
+
+func reportHandler(srv interface{}, ctx context.Context, dec func(interface{}) error,
+	interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	request := &structpb.Struct{}
+	if err := dec(request); err != nil {
+		return nil, err
+	}
+
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return &emptypb.Empty{}, srv.(resolverServer).report(ctx, req.(*structpb.Struct))
+	}
+
+	if interceptor == nil {
+		return handler(ctx, request)
+	}
+
+	return interceptor(ctx, request, &grpc.UnaryServerInfo{Server: srv, FullMethod: ReportMethod}, handler)
+}
+
+func dumpHandler(srv interface{}, ctx context.Context, dec func(interface{}) error,
+	interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	request := &emptypb.Empty{}
+	if err := dec(request); err != nil {
+		return nil, err
+	}
+
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(resolverServer).dump()
+	}
+
+	if interceptor == nil {
+		return handler(ctx, request)
+	}
+
+	return interceptor(ctx, request, &grpc.UnaryServerInfo{Server: srv, FullMethod: DumpMethod}, handler)
+}
+
+func (s *Server) dump() (*structpb.Struct, error) {
+	introspector, ok := s.source.(Introspector)
+	if !ok {
+		return nil, status.Error(codes.Unimplemented, "dumping the state is not supported")
+	}
+
+	state := introspector.State()
+
+	clusters := make([]*structpb.Value, 0, len(state.Clusters))
+	for _, cluster := range state.Clusters {
+		clusters = append(clusters, structpb.NewStructValue(&structpb.Struct{Fields: map[string]*structpb.Value{
+			"id":        structpb.NewStringValue(cluster.ID),
+			"connected": structpb.NewBoolValue(cluster.Connected),
+			"latency":   structpb.NewStringValue(cluster.Latency.String()),
+		}}))
+	}
+
+	hotNames := make([]*structpb.Value, 0, len(state.HotNames))
+	for _, name := range state.HotNames {
+		hotNames = append(hotNames, structpb.NewStructValue(&structpb.Struct{Fields: map[string]*structpb.Value{
+			"name":    structpb.NewStringValue(name.Name),
+			"type":    structpb.NewStringValue(name.Type),
+			"queries": structpb.NewNumberValue(float64(name.Queries)),
+		}}))
+	}
+
+	return &structpb.Struct{Fields: map[string]*structpb.Value{
+		"localCluster":   structpb.NewStringValue(state.LocalClusterID),
+		"clusters":       structpb.NewListValue(&structpb.ListValue{Values: clusters}),
+		"serviceImports": servicesToValue(state.ServiceImports),
+		"endpointSlices": servicesToValue(state.EndpointSlices),
+		"hotNames":       structpb.NewListValue(&structpb.ListValue{Values: hotNames}),
+	}}, nil
+}
+
+func servicesToValue(services []serviceimport.ServiceState) *structpb.Value {
+	values := make([]*structpb.Value, 0, len(services))
+
+	for i := range services {
+		values = append(values, structpb.NewStructValue(&structpb.Struct{Fields: map[string]*structpb.Value{
+			"name":                   structpb.NewStringValue(services[i].Name),
+			"namespace":              structpb.NewStringValue(services[i].Namespace),
+			"headless":               structpb.NewBoolValue(services[i].Headless),
+			"sessionAffinity":        structpb.NewStringValue(string(services[i].SessionAffinity)),
+			"sessionAffinityTimeout": structpb.NewNumberValue(float64(services[i].SessionAffinityTimeoutSeconds)),
+			"endpoints":              recordsToValue(services[i].Records),
+		}}))
+	}
+
+	return structpb.NewListValue(&structpb.ListValue{Values: values})
+}
+
+func (s *Server) report(ctx context.Context, request *structpb.Struct) error {
+	reporter, ok := s.source.(Reporter)
+	if !ok {
+		return status.Error(codes.Unimplemented, "connectivity reports are not supported")
+	}
+
+	if err := s.authorizeReport(ctx); err != nil {
+		return err
+	}
+
+	fields := request.GetFields()
+
+	name, namespace, ok := splitServiceName(fields["service"].GetStringValue())
+	if !ok {
+		return status.Errorf(codes.InvalidArgument, "invalid service name %q", fields["service"].GetStringValue())
+	}
+
+	clusterID := fields["cluster"].GetStringValue()
+	if clusterID == "" {
+		return status.Error(codes.InvalidArgument, "a cluster is required")
+	}
+
+	reporter.ReportConnectivity(name, namespace, clusterID, fields["success"].GetBoolValue())
+
+	return nil
+}
+
+// authorizeReport checks that a report comes from a client trusted to feed the circuit breaker, since a forged failure
+// stops answering with a cluster: one presenting a certificate if the server is served over TLS, a local one otherwise.
+func (s *Server) authorizeReport(ctx context.Context) error {
+	p, ok := peer.FromContext(ctx)
+	if !ok {
+		return status.Error(codes.PermissionDenied, "the client of the connectivity report is unknown")
+	}
+
+	if s.TLSConfig != nil {
+		if info, ok := p.AuthInfo.(credentials.TLSInfo); ok && len(info.State.PeerCertificates) > 0 {
+			return nil
+		}
+
+		return status.Error(codes.Unauthenticated, "connectivity reports require a client certificate")
+	}
+
+	if addr, ok := p.Addr.(*net.TCPAddr); ok && addr.IP.IsLoopback() {
+		return nil
+	}
+
+	return status.Errorf(codes.PermissionDenied, "connectivity reports are only accepted from local clients, not %v", p.Addr)
+}
+
+func (s *Server) watch(serviceName string, stream watchStream) error {
+	name, namespace, ok := splitServiceName(serviceName)
+	if !ok {
+		return status.Errorf(codes.InvalidArgument, "invalid service name %q", serviceName)
+	}
+
+	// Subscribe before the first evaluation so that no change is missed
+	changed, unsubscribe := s.changes.subscribe(name, namespace)
+	defer unsubscribe()
+
+	ticker := time.NewTicker(s.Interval)
+	defer ticker.Stop()
+
+	var (
+		lastRecords []serviceimport.DNSRecord
+		lastFound   bool
+		sent        bool
+	)
+
+	for {
+		records, found := s.source.Resolve(name, namespace)
+		records = sortedCopy(records)
+
+		if !sent || found != lastFound || !reflect.DeepEqual(records, lastRecords) {
+			if err := stream.SendMsg(toStruct(found, records)); err != nil {
+				return err
+			}
+
+			lastRecords, lastFound, sent = records, found, true
+		}
+
+		select {
+		case <-stream.Context().Done():
+			return nil
+		case <-changed:
+		case <-ticker.C:
+		}
+	}
+}
+
+func splitServiceName(serviceName string) (name, namespace string, ok bool) {
+	labels := strings.Split(strings.TrimSuffix(serviceName, "."), ".")
+	if len(labels) < 2 || labels[0] == "" || labels[1] == "" {
+		return "", "", false
+	}
+
+	return labels[0], labels[1], true
+}
+
+func sortedCopy(from []serviceimport.DNSRecord) []serviceimport.DNSRecord {
+	records := make([]serviceimport.DNSRecord, len(from))
+	copy(records, from)
+
+	// The cached resource records aren't part of the endpoint set and are recreated on every informer update
+	for i := range records {
+		records[i].RRs = nil
+	}
+
+	sort.Slice(records, func(i, j int) bool {
+		if records[i].ClusterName != records[j].ClusterName {
+			return records[i].ClusterName < records[j].ClusterName
+		}
+
+		if records[i].IP != records[j].IP {
+			return records[i].IP < records[j].IP
+		}
+
+		return records[i].HostName < records[j].HostName
+	})
+
+	return records
+}
+
+func toStruct(found bool, records []serviceimport.DNSRecord) *structpb.Struct {
+	return &structpb.Struct{Fields: map[string]*structpb.Value{
+		"found":     structpb.NewBoolValue(found),
+		"endpoints": recordsToValue(records),
+	}}
+}
+
+func recordsToValue(records []serviceimport.DNSRecord) *structpb.Value {
+	endpoints := make([]*structpb.Value, 0, len(records))
+
+	for i := range records {
+		ports := make([]*structpb.Value, 0, len(records[i].Ports))
+		for _, port := range records[i].Ports {
+			ports = append(ports, structpb.NewStructValue(&structpb.Struct{Fields: map[string]*structpb.Value{
+				"name":     structpb.NewStringValue(port.Name),
+				"protocol": structpb.NewStringValue(string(port.Protocol)),
+				"port":     structpb.NewNumberValue(float64(port.Port)),
+			}}))
+		}
+
+		addresses := records[i].Addresses()
+		ips := make([]*structpb.Value, len(addresses))
+
+		for j, ip := range addresses {
+			ips[j] = structpb.NewStringValue(ip)
+		}
+
+		endpoints = append(endpoints, structpb.NewStructValue(&structpb.Struct{Fields: map[string]*structpb.Value{
+			"ip":       structpb.NewStringValue(records[i].IP),
+			"ips":      structpb.NewListValue(&structpb.ListValue{Values: ips}),
+			"hostname": structpb.NewStringValue(records[i].HostName),
+			"cluster":  structpb.NewStringValue(records[i].ClusterName),
+			"ports":    structpb.NewListValue(&structpb.ListValue{Values: ports}),
+		}}))
+	}
+
+	return structpb.NewListValue(&structpb.ListValue{Values: endpoints})
+}
@@ -0,0 +1,374 @@
+/*
+SPDX-License-Identifier: Apache-2.0
+
+Copyright Contributors to the Submariner project.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package resolver
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"net"
+	"sync"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/submariner-io/lighthouse/pkg/serviceimport"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/structpb"
+	corev1 "k8s.io/api/core/v1"
+)
+
+const (
+	service1   = "service1"
+	namespace1 = "namespace1"
+	clusterID1 = "cluster1"
+	clusterID2 = "cluster2"
+	serviceIP1 = "100.96.156.101"
+	serviceIP2 = "100.96.156.102"
+)
+
+var _ = Describe("Resolver server", func() {
+	var (
+		source  *fakeSource
+		changes *Changes
+		stream  *fakeStream
+		server  *Server
+		cancel  context.CancelFunc
+		done    chan error
+	)
+
+	BeforeEach(func() {
+		source = &fakeSource{}
+		changes = NewChanges()
+		server = NewServer(source, changes)
+		server.Interval = time.Hour
+
+		var ctx context.Context
+		ctx, cancel = context.WithCancel(context.Background())
+		stream = &fakeStream{ctx: ctx, sent: make(chan *structpb.Struct, 10)}
+		done = make(chan error, 1)
+	})
+
+	AfterEach(func() {
+		cancel()
+	})
+
+	watch := func(name string) {
+		go func() {
+			done <- server.watch(name, stream)
+		}()
+	}
+
+	When("a service is watched", func() {
+		BeforeEach(func() {
+			source.set(true, serviceimport.DNSRecord{IP: serviceIP1, ClusterName: clusterID1})
+			watch(service1 + "." + namespace1)
+		})
+
+		It("should send the initial endpoint set", func() {
+			msg := stream.awaitMessage()
+			Expect(msg.Fields["found"].GetBoolValue()).To(BeTrue())
+			Expect(ipsOf(msg)).To(Equal([]string{serviceIP1}))
+		})
+
+		It("should not resend an unchanged endpoint set", func() {
+			stream.awaitMessage()
+			Consistently(stream.sent, 100*time.Millisecond).ShouldNot(Receive())
+		})
+
+		It("should send the new endpoint set when its change is notified", func() {
+			stream.awaitMessage()
+
+			source.set(true, serviceimport.DNSRecord{IP: serviceIP2, ClusterName: clusterID2},
+				serviceimport.DNSRecord{IP: serviceIP1, ClusterName: clusterID1})
+			changes.ServiceChanged(service1, namespace1)
+			Expect(ipsOf(stream.awaitMessage())).To(Equal([]string{serviceIP1, serviceIP2}))
+
+			source.set(false)
+			changes.ServiceChanged(service1, namespace1)
+			Expect(stream.awaitMessage().Fields["found"].GetBoolValue()).To(BeFalse())
+		})
+
+		It("should not re-evaluate it when another service changes", func() {
+			stream.awaitMessage()
+
+			source.set(false)
+			changes.ServiceChanged("service2", namespace1)
+			Consistently(stream.sent, 100*time.Millisecond).ShouldNot(Receive())
+		})
+
+		It("should stop notifying it when the stream is closed", func() {
+			stream.awaitMessage()
+			cancel()
+			Eventually(done).Should(Receive(BeNil()))
+			Eventually(func() int {
+				changes.mutex.Lock()
+				defer changes.mutex.Unlock()
+
+				return len(changes.watchers)
+			}).Should(BeZero())
+		})
+
+		It("should return when the stream is closed", func() {
+			stream.awaitMessage()
+			cancel()
+			Eventually(done).Should(Receive(BeNil()))
+		})
+	})
+
+	When("a watched service changes without being notified", func() {
+		BeforeEach(func() {
+			server.Interval = 10 * time.Millisecond
+			source.set(true, serviceimport.DNSRecord{IP: serviceIP1, ClusterName: clusterID1})
+			watch(service1 + "." + namespace1)
+		})
+
+		It("should send the new endpoint set once it's re-evaluated", func() {
+			stream.awaitMessage()
+
+			source.set(true, serviceimport.DNSRecord{IP: serviceIP2, ClusterName: clusterID2})
+			Expect(ipsOf(stream.awaitMessage())).To(Equal([]string{serviceIP2}))
+		})
+	})
+
+	When("a fully-qualified service name is watched", func() {
+		It("should resolve the service and namespace labels", func() {
+			watch(service1 + "." + namespace1 + ".svc.clusterset.local.")
+			stream.awaitMessage()
+			Expect(source.requested()).To(Equal(service1 + "/" + namespace1))
+		})
+	})
+
+	When("an invalid service name is watched", func() {
+		It("should return an InvalidArgument error", func() {
+			watch(service1)
+
+			var err error
+			Eventually(done).Should(Receive(&err))
+			Expect(status.Code(err)).To(Equal(codes.InvalidArgument))
+		})
+	})
+
+	When("a connection failure is reported", func() {
+		It("should pass it to the source", func() {
+			Expect(server.report(fromPeer(localAddr, nil), newReport(service1+"."+namespace1, clusterID1, false))).To(Succeed())
+			Expect(source.reported()).To(Equal(service1 + "/" + namespace1 + "/" + clusterID1 + ": false"))
+		})
+	})
+
+	When("a connection failure is reported by a remote client", func() {
+		It("should return a PermissionDenied error", func() {
+			err := server.report(fromPeer(remoteAddr, nil), newReport(service1+"."+namespace1, clusterID1, false))
+			Expect(status.Code(err)).To(Equal(codes.PermissionDenied))
+			Expect(source.reported()).To(BeEmpty())
+		})
+	})
+
+	When("the server is served over TLS", func() {
+		BeforeEach(func() {
+			server.TLSConfig = &tls.Config{MinVersion: tls.VersionTLS12}
+		})
+
+		When("a connection failure is reported by a client presenting a certificate", func() {
+			It("should pass it to the source", func() {
+				authInfo := credentials.TLSInfo{State: tls.ConnectionState{PeerCertificates: []*x509.Certificate{{}}}}
+				Expect(server.report(fromPeer(remoteAddr, authInfo), newReport(service1+"."+namespace1, clusterID1,
+					false))).To(Succeed())
+				Expect(source.reported()).To(Equal(service1 + "/" + namespace1 + "/" + clusterID1 + ": false"))
+			})
+		})
+
+		When("a connection failure is reported by a client without a certificate", func() {
+			It("should return an Unauthenticated error", func() {
+				err := server.report(fromPeer(localAddr, nil), newReport(service1+"."+namespace1, clusterID1, false))
+				Expect(status.Code(err)).To(Equal(codes.Unauthenticated))
+				Expect(source.reported()).To(BeEmpty())
+			})
+		})
+	})
+
+	When("a report without a cluster is received", func() {
+		It("should return an InvalidArgument error", func() {
+			err := server.report(fromPeer(localAddr, nil), newReport(service1+"."+namespace1, "", true))
+			Expect(status.Code(err)).To(Equal(codes.InvalidArgument))
+		})
+	})
+
+	When("the state is dumped", func() {
+		It("should return the source's clusters and services", func() {
+			source.state = State{
+				LocalClusterID: clusterID1,
+				Clusters: []ClusterState{
+					{ID: clusterID1, Connected: true},
+					{ID: clusterID2, Latency: time.Millisecond},
+				},
+				ServiceImports: []serviceimport.ServiceState{{
+					Name:                          service1,
+					Namespace:                     namespace1,
+					SessionAffinity:               corev1.ServiceAffinityClientIP,
+					SessionAffinityTimeoutSeconds: 600,
+					Records:                       []serviceimport.DNSRecord{{IP: serviceIP1, ClusterName: clusterID1}},
+				}},
+				HotNames: []HotName{{Name: service1 + "." + namespace1 + ".svc.clusterset.local.", Type: "A", Queries: 42}},
+			}
+
+			msg, err := server.dump()
+			Expect(err).To(Succeed())
+			Expect(msg.Fields["localCluster"].GetStringValue()).To(Equal(clusterID1))
+
+			clusters := msg.Fields["clusters"].GetListValue().GetValues()
+			Expect(clusters).To(HaveLen(2))
+			Expect(clusters[0].GetStructValue().Fields["connected"].GetBoolValue()).To(BeTrue())
+			Expect(clusters[1].GetStructValue().Fields["id"].GetStringValue()).To(Equal(clusterID2))
+			Expect(clusters[1].GetStructValue().Fields["latency"].GetStringValue()).To(Equal("1ms"))
+
+			services := msg.Fields["serviceImports"].GetListValue().GetValues()
+			Expect(services).To(HaveLen(1))
+			Expect(services[0].GetStructValue().Fields["name"].GetStringValue()).To(Equal(service1))
+			Expect(ipsOf(services[0].GetStructValue())).To(Equal([]string{serviceIP1}))
+			Expect(services[0].GetStructValue().Fields["sessionAffinity"].GetStringValue()).To(Equal("ClientIP"))
+			Expect(services[0].GetStructValue().Fields["sessionAffinityTimeout"].GetNumberValue()).To(Equal(600.0))
+			Expect(msg.Fields["endpointSlices"].GetListValue().GetValues()).To(BeEmpty())
+
+			hotNames := msg.Fields["hotNames"].GetListValue().GetValues()
+			Expect(hotNames).To(HaveLen(1))
+			Expect(hotNames[0].GetStructValue().Fields["type"].GetStringValue()).To(Equal("A"))
+			Expect(hotNames[0].GetStructValue().Fields["queries"].GetNumberValue()).To(Equal(42.0))
+		})
+	})
+
+	When("the state is dumped from a source which doesn't expose it", func() {
+		It("should return an Unimplemented error", func() {
+			_, err := NewServer(&resolveOnlySource{}, nil).dump()
+			Expect(status.Code(err)).To(Equal(codes.Unimplemented))
+		})
+	})
+})
+
+type fakeSource struct {
+	sync.Mutex
+	records []serviceimport.DNSRecord
+	found   bool
+	request string
+	report  string
+	state   State
+}
+
+func (f *fakeSource) Resolve(name, namespace string) ([]serviceimport.DNSRecord, bool) {
+	f.Lock()
+	defer f.Unlock()
+
+	f.request = name + "/" + namespace
+
+	return f.records, f.found
+}
+
+func (f *fakeSource) ReportConnectivity(name, namespace, clusterID string, success bool) {
+	f.Lock()
+	defer f.Unlock()
+
+	f.report = fmt.Sprintf("%s/%s/%s: %t", name, namespace, clusterID, success)
+}
+
+func (f *fakeSource) State() State {
+	f.Lock()
+	defer f.Unlock()
+
+	return f.state
+}
+
+func (f *fakeSource) reported() string {
+	f.Lock()
+	defer f.Unlock()
+
+	return f.report
+}
+
+func (f *fakeSource) set(found bool, records ...serviceimport.DNSRecord) {
+	f.Lock()
+	defer f.Unlock()
+
+	f.found = found
+	f.records = records
+}
+
+func (f *fakeSource) requested() string {
+	f.Lock()
+	defer f.Unlock()
+
+	return f.request
+}
+
+type resolveOnlySource struct{}
+
+func (r *resolveOnlySource) Resolve(name, namespace string) ([]serviceimport.DNSRecord, bool) {
+	return nil, false
+}
+
+type fakeStream struct {
+	ctx  context.Context
+	sent chan *structpb.Struct
+}
+
+func (f *fakeStream) Context() context.Context {
+	return f.ctx
+}
+
+func (f *fakeStream) SendMsg(m interface{}) error {
+	f.sent <- m.(*structpb.Struct)
+	return nil
+}
+
+func (f *fakeStream) awaitMessage() *structpb.Struct {
+	var msg *structpb.Struct
+
+	Eventually(f.sent).Should(Receive(&msg))
+
+	return msg
+}
+
+var (
+	localAddr  = &net.TCPAddr{IP: net.IPv4(127, 0, 0, 1), Port: 40000}
+	remoteAddr = &net.TCPAddr{IP: net.IPv4(10, 0, 0, 1), Port: 40000}
+)
+
+func fromPeer(addr net.Addr, authInfo credentials.AuthInfo) context.Context {
+	return peer.NewContext(context.Background(), &peer.Peer{Addr: addr, AuthInfo: authInfo})
+}
+
+func newReport(service, cluster string, success bool) *structpb.Struct {
+	return &structpb.Struct{Fields: map[string]*structpb.Value{
+		"service": structpb.NewStringValue(service),
+		"cluster": structpb.NewStringValue(cluster),
+		"success": structpb.NewBoolValue(success),
+	}}
+}
+
+func ipsOf(msg *structpb.Struct) []string {
+	ips := []string{}
+
+	for _, endpoint := range msg.Fields["endpoints"].GetListValue().GetValues() {
+		ips = append(ips, endpoint.GetStructValue().Fields["ip"].GetStringValue())
+	}
+
+	return ips
+}
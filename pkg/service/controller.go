@@ -20,6 +20,7 @@ package service
 import (
 	"context"
 	"fmt"
+	"sync"
 
 	"github.com/submariner-io/lighthouse/pkg/serviceimport"
 
@@ -41,6 +42,10 @@ type Controller struct {
 	svcInformer  cache.Controller
 	svcStore     cache.Store
 	stopCh       chan struct{}
+	records      map[string]*serviceimport.DNSRecord
+	mutex        sync.RWMutex
+	// RRCacheBudget, if set, bounds the memory used by the records' caches
+	RRCacheBudget *serviceimport.RRCacheBudget
 }
 
 func NewController() *Controller {
@@ -48,7 +53,8 @@ func NewController() *Controller {
 		NewClientset: func(c *rest.Config) (kubernetes.Interface, error) {
 			return kubernetes.NewForConfig(c)
 		},
-		stopCh: make(chan struct{}),
+		stopCh:  make(chan struct{}),
+		records: make(map[string]*serviceimport.DNSRecord),
 	}
 }
 
@@ -72,9 +78,11 @@ func (c *Controller) Start(kubeConfig *rest.Config) error {
 		&v1.Service{},
 		0,
 		cache.ResourceEventHandlerFuncs{
-			AddFunc:    func(obj interface{}) {},
-			UpdateFunc: func(old interface{}, new interface{}) {},
-			DeleteFunc: func(obj interface{}) {},
+			AddFunc: c.serviceCreatedOrUpdated,
+			UpdateFunc: func(old interface{}, new interface{}) {
+				c.serviceCreatedOrUpdated(new)
+			},
+			DeleteFunc: c.serviceDeleted,
 		},
 	)
 
@@ -90,22 +98,29 @@ func (c *Controller) Stop() {
 }
 
 func (c *Controller) GetIP(name, namespace string) (*serviceimport.DNSRecord, bool) {
-	key := namespace + "/" + name
-	obj, exists, err := c.svcStore.GetByKey(key)
+	c.mutex.RLock()
+	defer c.mutex.RUnlock()
 
-	if err != nil {
-		klog.V(log.DEBUG).Infof("Error trying to get service for key %q", key)
-		return nil, false
-	}
+	record, found := c.records[namespace+"/"+name]
 
-	if !exists {
-		return nil, false
-	}
+	return record, found
+}
 
+// The DNS records are built once per informer update so that queries can reuse them and their cached resource records.
+func (c *Controller) serviceCreatedOrUpdated(obj interface{}) {
 	svc := obj.(*v1.Service)
+	key := svc.Namespace + "/" + svc.Name
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	if record := c.records[key]; record != nil {
+		record.RRs.Release()
+	}
 
 	if svc.Spec.Type != v1.ServiceTypeClusterIP || svc.Spec.ClusterIP == "" {
-		return nil, false
+		delete(c.records, key)
+		return
 	}
 
 	var mcsServicePorts []mcsv1a1.ServicePort
@@ -115,16 +130,33 @@ func (c *Controller) GetIP(name, namespace string) (*serviceimport.DNSRecord, bo
 
 	for index, ports := range svc.Spec.Ports {
 		mcsServicePorts[index] = mcsv1a1.ServicePort{
-			Name:     ports.Name,
-			Protocol: ports.Protocol,
-			Port:     ports.Port,
+			Name:        ports.Name,
+			Protocol:    ports.Protocol,
+			AppProtocol: ports.AppProtocol,
+			Port:        ports.Port,
 		}
 	}
 
-	record := &serviceimport.DNSRecord{
+	c.records[key] = &serviceimport.DNSRecord{
 		IP:    svc.Spec.ClusterIP,
 		Ports: mcsServicePorts,
+		RRs:   serviceimport.NewRRCache(c.RRCacheBudget),
+	}
+}
+
+func (c *Controller) serviceDeleted(obj interface{}) {
+	key, err := cache.DeletionHandlingMetaNamespaceKeyFunc(obj)
+	if err != nil {
+		klog.V(log.DEBUG).Infof("Error getting key for deleted service %#v: %v", obj, err)
+		return
+	}
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	if record := c.records[key]; record != nil {
+		record.RRs.Release()
 	}
 
-	return record, true
+	delete(c.records, key)
 }
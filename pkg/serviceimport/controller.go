@@ -18,16 +18,22 @@ limitations under the License.
 package serviceimport
 
 import (
+	"context"
 	"fmt"
+	"sync/atomic"
+	"time"
 
 	"github.com/submariner-io/admiral/pkg/log"
+	lhconstants "github.com/submariner-io/lighthouse/pkg/constants"
+	"github.com/submariner-io/lighthouse/pkg/fairqueue"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/watch"
 	"k8s.io/client-go/rest"
 	"k8s.io/client-go/tools/cache"
 	"k8s.io/klog"
 	mcsv1a1 "sigs.k8s.io/mcs-api/pkg/apis/v1alpha1"
 	mcsClientset "sigs.k8s.io/mcs-api/pkg/client/clientset/versioned"
-	mcsInformers "sigs.k8s.io/mcs-api/pkg/client/informers/externalversions"
 )
 
 type NewClientsetFunc func(kubeConfig *rest.Config) (mcsClientset.Interface, error)
@@ -41,6 +47,14 @@ type Controller struct {
 	serviceInformer cache.SharedIndexInformer
 	stopCh          chan struct{}
 	store           Store
+	// Queue, if set, processes the changes fairly across namespaces instead of as they are received
+	Queue *fairqueue.Queue
+	// Standalone, if set, also reads the ServiceImports created by other MCS implementations than Lighthouse, converting
+	// them with FromMCS
+	Standalone bool
+	synced     int32
+	// disconnectedSince holds the time.Time since which ServiceImports can't be listed or watched, zero while they can
+	disconnectedSince atomic.Value
 }
 
 func NewController(serviceImportStore Store) *Controller {
@@ -69,14 +83,32 @@ func (c *Controller) Start(kubeConfig *rest.Config) error {
 		return fmt.Errorf("error creating client set: %v", err)
 	}
 
-	informerFactory := mcsInformers.NewSharedInformerFactoryWithOptions(clientSet, 0,
-		mcsInformers.WithNamespace(metav1.NamespaceAll))
+	serviceImports := clientSet.MulticlusterV1alpha1().ServiceImports(metav1.NamespaceAll)
 
-	c.serviceInformer = informerFactory.Multicluster().V1alpha1().ServiceImports().Informer()
+	// The ListWatch reports whether the API server can be reached, so that answers can be flagged as stale while it
+	// can't
+	c.serviceInformer = cache.NewSharedIndexInformer(&cache.ListWatch{
+		ListFunc: func(options metav1.ListOptions) (runtime.Object, error) {
+			list, err := serviceImports.List(context.TODO(), options)
+			c.recordConnectivity(err)
+
+			return list, err
+		},
+		WatchFunc: func(options metav1.ListOptions) (watch.Interface, error) {
+			w, err := serviceImports.Watch(context.TODO(), options)
+			c.recordConnectivity(err)
+
+			return w, err
+		},
+	}, &mcsv1a1.ServiceImport{}, 0, cache.Indexers{cache.NamespaceIndex: cache.MetaNamespaceIndexFunc})
 	c.serviceInformer.AddEventHandler(cache.ResourceEventHandlerFuncs{
 		AddFunc: c.serviceImportCreatedOrUpdated,
 		UpdateFunc: func(oldObj, newObj interface{}) {
 			c.serviceImportCreatedOrUpdated(newObj)
+
+			if c.Standalone {
+				c.removeDroppedClusters(oldObj.(*mcsv1a1.ServiceImport), newObj.(*mcsv1a1.ServiceImport))
+			}
 		},
 		DeleteFunc: c.serviceImportDeleted,
 	})
@@ -90,6 +122,40 @@ func (c *Controller) Start(kubeConfig *rest.Config) error {
 	return nil
 }
 
+// HasSynced returns true once the initial list of ServiceImports has been received and applied to the store.
+func (c *Controller) HasSynced() bool {
+	if atomic.LoadInt32(&c.synced) == 1 {
+		return true
+	}
+
+	if c.serviceInformer == nil || !c.serviceInformer.HasSynced() || (c.Queue != nil && !c.Queue.Idle()) {
+		return false
+	}
+
+	atomic.StoreInt32(&c.synced, 1)
+
+	return true
+}
+
+// DisconnectedSince returns the time since which ServiceImports can't be listed or watched, and whether they can't.
+func (c *Controller) DisconnectedSince() (time.Time, bool) {
+	since, _ := c.disconnectedSince.Load().(time.Time)
+	return since, !since.IsZero()
+}
+
+func (c *Controller) recordConnectivity(err error) {
+	since, _ := c.disconnectedSince.Load().(time.Time)
+
+	switch {
+	case err != nil && since.IsZero():
+		klog.Warningf("Lost access to the ServiceImports: %v", err)
+		c.disconnectedSince.Store(time.Now())
+	case err == nil && !since.IsZero():
+		klog.Infof("Regained access to the ServiceImports after %v", time.Since(since))
+		c.disconnectedSince.Store(time.Time{})
+	}
+}
+
 func (c *Controller) Stop() {
 	close(c.stopCh)
 
@@ -99,7 +165,12 @@ func (c *Controller) Stop() {
 func (c *Controller) serviceImportCreatedOrUpdated(obj interface{}) {
 	klog.V(log.DEBUG).Infof("In serviceImportCreatedOrUpdated for: %#v, ", obj)
 
-	c.store.Put(obj.(*mcsv1a1.ServiceImport))
+	for _, si := range c.convert(obj.(*mcsv1a1.ServiceImport)) {
+		si := si
+		c.process(si, func() {
+			c.store.Put(si)
+		})
+	}
 }
 
 func (c *Controller) serviceImportDeleted(obj interface{}) {
@@ -121,5 +192,48 @@ func (c *Controller) serviceImportDeleted(obj interface{}) {
 		}
 	}
 
-	c.store.Remove(si)
+	for _, si := range c.convert(si) {
+		si := si
+		c.process(si, func() {
+			c.store.Remove(si)
+		})
+	}
+}
+
+// removeDroppedClusters removes the clusters which a ServiceImport created by another MCS implementation no longer
+// lists from the store.
+func (c *Controller) removeDroppedClusters(oldSI, newSI *mcsv1a1.ServiceImport) {
+	listed := map[string]bool{}
+	for _, si := range FromMCS(newSI) {
+		listed[si.Labels[lhconstants.LabelSourceCluster]] = true
+	}
+
+	for _, si := range FromMCS(oldSI) {
+		if listed[si.Labels[lhconstants.LabelSourceCluster]] {
+			continue
+		}
+
+		si := si
+		c.process(si, func() {
+			c.store.Remove(si)
+		})
+	}
+}
+
+// convert returns the ServiceImports to store for the given one, converted with FromMCS in standalone mode.
+func (c *Controller) convert(si *mcsv1a1.ServiceImport) []*mcsv1a1.ServiceImport {
+	if c.Standalone {
+		return FromMCS(si)
+	}
+
+	return []*mcsv1a1.ServiceImport{si}
+}
+
+func (c *Controller) process(si *mcsv1a1.ServiceImport, change func()) {
+	if c.Queue == nil {
+		change()
+		return
+	}
+
+	c.Queue.Add(ImportNamespace(si), change)
 }
@@ -33,6 +33,7 @@ import (
 
 var _ = Describe("ServiceImport controller", func() {
 	Describe("ServiceImport lifecycle notifications", testLifecycleNotifications)
+	Describe("Standalone ServiceImport notifications", testStandaloneNotifications)
 })
 
 func testLifecycleNotifications() {
@@ -116,6 +117,12 @@ func testLifecycleNotifications() {
 		store.verifyPut(second)
 	}
 
+	When("the controller has started", func() {
+		It("HasSynced should return true", func() {
+			Expect(controller.HasSynced()).To(BeTrue())
+		})
+	})
+
 	When("a ServiceImport is added", func() {
 		It("it should be added to the ServiceImport store", func() {
 			testOnAdd(serviceImport)
@@ -142,6 +149,92 @@ func testLifecycleNotifications() {
 	})
 }
 
+func testStandaloneNotifications() {
+	const (
+		service1   = "service1"
+		namespace1 = "namespace1"
+		serviceIP  = "243.1.0.1"
+		clusterID  = "clusterID"
+		clusterID2 = "clusterID2"
+	)
+
+	var (
+		mcsServiceImport *mcsv1a1.ServiceImport
+		controller       *serviceimport.Controller
+		fakeClientSet    mcsClientset.Interface
+		store            *fakeStore
+	)
+
+	BeforeEach(func() {
+		store = &fakeStore{
+			put:    make(chan *mcsv1a1.ServiceImport, 10),
+			remove: make(chan *mcsv1a1.ServiceImport, 10),
+		}
+
+		mcsServiceImport = &mcsv1a1.ServiceImport{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      service1,
+				Namespace: namespace1,
+			},
+			Spec: mcsv1a1.ServiceImportSpec{
+				Type: mcsv1a1.ClusterSetIP,
+				IPs:  []string{serviceIP},
+			},
+			Status: mcsv1a1.ServiceImportStatus{
+				Clusters: []mcsv1a1.ClusterStatus{{Cluster: clusterID}, {Cluster: clusterID2}},
+			},
+		}
+
+		controller = serviceimport.NewController(store)
+		controller.Standalone = true
+		fakeClientSet = fakeMCSClientSet.NewSimpleClientset()
+
+		controller.NewClientset = func(c *rest.Config) (mcsClientset.Interface, error) {
+			return fakeClientSet, nil
+		}
+
+		Expect(controller.Start(&rest.Config{})).To(Succeed())
+
+		_, err := fakeClientSet.MulticlusterV1alpha1().ServiceImports(namespace1).Create(context.TODO(), mcsServiceImport,
+			metav1.CreateOptions{})
+		Expect(err).To(Succeed())
+	})
+
+	AfterEach(func() {
+		controller.Stop()
+	})
+
+	verifyCluster := func(si *mcsv1a1.ServiceImport, clusterID string) {
+		Expect(si.Annotations).To(HaveKeyWithValue(lhconstants.OriginName, service1))
+		Expect(si.Annotations).To(HaveKeyWithValue(lhconstants.OriginNamespace, namespace1))
+		Expect(si.Labels).To(HaveKeyWithValue(lhconstants.LabelSourceCluster, clusterID))
+		Expect(si.Status.Clusters).To(Equal([]mcsv1a1.ClusterStatus{{Cluster: clusterID}}))
+	}
+
+	When("a ServiceImport created by another MCS implementation is added", func() {
+		It("should be added to the ServiceImport store for each of its clusters", func() {
+			for _, clusterID := range []string{clusterID, clusterID2} {
+				var si *mcsv1a1.ServiceImport
+				Eventually(store.put, 5).Should(Receive(&si))
+				verifyCluster(si, clusterID)
+			}
+		})
+	})
+
+	When("a cluster is dropped from a ServiceImport created by another MCS implementation", func() {
+		It("should be removed from the ServiceImport store", func() {
+			mcsServiceImport.Status.Clusters = mcsServiceImport.Status.Clusters[:1]
+			_, err := fakeClientSet.MulticlusterV1alpha1().ServiceImports(namespace1).Update(context.TODO(), mcsServiceImport,
+				metav1.UpdateOptions{})
+			Expect(err).To(Succeed())
+
+			var si *mcsv1a1.ServiceImport
+			Eventually(store.remove, 5).Should(Receive(&si))
+			verifyCluster(si, clusterID2)
+		})
+	})
+}
+
 func newServiceImport(namespace, name, serviceIP, clusterID string) *mcsv1a1.ServiceImport {
 	return &mcsv1a1.ServiceImport{
 		ObjectMeta: metav1.ObjectMeta{
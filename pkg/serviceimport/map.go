@@ -18,18 +18,47 @@ limitations under the License.
 package serviceimport
 
 import (
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
 	"sync"
 	"sync/atomic"
 
 	lhconstants "github.com/submariner-io/lighthouse/pkg/constants"
+	"github.com/submariner-io/lighthouse/pkg/dnsname"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/util/validation"
+	"k8s.io/klog"
 	mcsv1a1 "sigs.k8s.io/mcs-api/pkg/apis/v1alpha1"
 )
 
 type DNSRecord struct {
-	IP          string
+	IP string
+	// IPs, if set, holds all the record's IPs, starting with IP, e.g. for dual-stack services or services with several
+	// global IPs
+	IPs         []string
 	Ports       []mcsv1a1.ServicePort
 	HostName    string
 	ClusterName string
+	// Zone and Region, if known, locate the endpoint of a headless service
+	Zone   string
+	Region string
+	// RRs caches the resource records built from this record
+	RRs *RRCache
+}
+
+// Addresses returns all the record's IPs.
+func (r *DNSRecord) Addresses() []string {
+	if len(r.IPs) > 0 {
+		return r.IPs
+	}
+
+	if r.IP == "" {
+		return nil
+	}
+
+	return []string{r.IP}
 }
 
 type clusterInfo struct {
@@ -44,6 +73,17 @@ type serviceInfo struct {
 	clustersQueue []clusterInfo
 	rrCount       uint64
 	isHeadless    bool
+	// originNamespace is the namespace the service is exported from, if it's imported in another one
+	originNamespace string
+	// clusterSelection is the cluster selection policy requested for the service, if any
+	clusterSelection string
+	// minReadyEndpoints is the number of ready endpoints the service requires in a cluster for it to be eligible
+	minReadyEndpoints int
+	// aliases are the other names the service answers for in its namespace
+	aliases []string
+	// sessionAffinity and sessionAffinityTimeout are the session affinity settings of the exported service
+	sessionAffinity        corev1.ServiceAffinity
+	sessionAffinityTimeout int32
 }
 
 func (si *serviceInfo) buildClusterInfoQueue() {
@@ -56,6 +96,10 @@ func (si *serviceInfo) buildClusterInfoQueue() {
 
 type Map struct {
 	svcMap map[string]*serviceInfo
+	// aliases maps the keys of the services' aliases to the names of the services
+	aliases map[string]string
+	// rrCacheBudget, if set, bounds the memory used by the records' caches
+	rrCacheBudget *RRCacheBudget
 	sync.RWMutex
 }
 
@@ -121,15 +165,55 @@ func (m *Map) GetIP(namespace, name, cluster, localCluster string, checkCluster
 	return nil, true, false
 }
 
+// GetAllRecords returns the records of every cluster exporting the given ClusterSetIP service that is connected
+// and has healthy endpoints, rather than a single round-robin selection.
+func (m *Map) GetAllRecords(namespace, name string, checkCluster func(string) bool,
+	checkEndpoint func(string, string, string) bool) ([]DNSRecord, bool) {
+	queue, isHeadless, found := func() ([]clusterInfo, bool, bool) {
+		m.RLock()
+		defer m.RUnlock()
+
+		si, ok := m.svcMap[keyFunc(namespace, name)]
+		if !ok {
+			return nil, false, false
+		}
+
+		return si.clustersQueue, si.isHeadless, true
+	}()
+
+	if !found || isHeadless {
+		return nil, false
+	}
+
+	records := make([]DNSRecord, 0, len(queue))
+
+	for _, info := range queue {
+		if checkCluster(info.name) && checkEndpoint(name, namespace, info.name) {
+			records = append(records, *info.record)
+		}
+	}
+
+	return records, true
+}
+
 func NewMap() *Map {
 	return &Map{
-		svcMap: make(map[string]*serviceInfo),
+		svcMap:  make(map[string]*serviceInfo),
+		aliases: make(map[string]string),
 	}
 }
 
+// SetRRCacheBudget sets the budget bounding the memory used by the caches of the records put from then on.
+func (m *Map) SetRRCacheBudget(budget *RRCacheBudget) {
+	m.Lock()
+	defer m.Unlock()
+
+	m.rrCacheBudget = budget
+}
+
 func (m *Map) Put(serviceImport *mcsv1a1.ServiceImport) {
 	if name, ok := serviceImport.Annotations["origin-name"]; ok {
-		namespace := serviceImport.Annotations["origin-namespace"]
+		namespace := ImportNamespace(serviceImport)
 		key := keyFunc(namespace, name)
 
 		m.Lock()
@@ -139,32 +223,115 @@ func (m *Map) Put(serviceImport *mcsv1a1.ServiceImport) {
 
 		if !ok {
 			remoteService = &serviceInfo{
-				key:        key,
-				records:    make(map[string]*DNSRecord),
-				rrCount:    0,
-				isHeadless: serviceImport.Spec.Type == mcsv1a1.Headless,
+				key:             key,
+				records:         make(map[string]*DNSRecord),
+				rrCount:         0,
+				isHeadless:      serviceImport.Spec.Type == mcsv1a1.Headless,
+				originNamespace: serviceImport.Annotations["origin-namespace"],
 			}
 		}
 
+		changed := false
+
 		if serviceImport.Spec.Type == mcsv1a1.ClusterSetIP {
+			clusterName := serviceImport.GetLabels()[lhconstants.LabelSourceCluster]
 			record := &DNSRecord{
-				IP:    serviceImport.Spec.IPs[0],
-				Ports: serviceImport.Spec.Ports,
+				IP:          serviceImport.Spec.IPs[0],
+				IPs:         serviceImport.Spec.IPs,
+				Ports:       serviceImport.Spec.Ports,
+				ClusterName: clusterName,
+			}
+
+			// An unchanged record is kept along with the resource records cached from it
+			if existing := remoteService.records[clusterName]; existing == nil || !sameRecord(existing, record) {
+				if existing != nil {
+					existing.RRs.Release()
+				}
+
+				record.RRs = NewRRCache(m.rrCacheBudget)
+				remoteService.records[clusterName] = record
+				changed = true
 			}
-			remoteService.records[serviceImport.GetLabels()[lhconstants.LabelSourceCluster]] = record
 		}
 
-		if !remoteService.isHeadless {
+		if !remoteService.isHeadless && (changed || remoteService.clustersQueue == nil) {
 			remoteService.buildClusterInfoQueue()
 		}
 
+		remoteService.clusterSelection = serviceImport.Annotations[lhconstants.ClusterSelection]
+		remoteService.minReadyEndpoints = parseMinReadyEndpoints(serviceImport.Annotations[lhconstants.MinReadyEndpoints])
+		m.setAliases(remoteService, namespace, name, parseAliases(serviceImport.Annotations[lhconstants.Aliases]))
+		// The session affinity of the last ServiceImport received wins if clusters disagree
+		remoteService.sessionAffinity, remoteService.sessionAffinityTimeout = sessionAffinityOf(serviceImport)
 		m.svcMap[key] = remoteService
 	}
 }
 
+// sessionAffinityOf returns the session affinity of the given ServiceImport, and its timeout in seconds if any.
+func sessionAffinityOf(serviceImport *mcsv1a1.ServiceImport) (corev1.ServiceAffinity, int32) {
+	affinity := serviceImport.Spec.SessionAffinity
+	if affinity == "" {
+		affinity = corev1.ServiceAffinityNone
+	}
+
+	config := serviceImport.Spec.SessionAffinityConfig
+	if affinity != corev1.ServiceAffinityClientIP || config == nil || config.ClientIP == nil || config.ClientIP.TimeoutSeconds == nil {
+		return affinity, 0
+	}
+
+	return affinity, *config.ClientIP.TimeoutSeconds
+}
+
+// sameRecord returns whether the given records would answer queries with the same resource records.
+func sameRecord(a, b *DNSRecord) bool {
+	return a.ClusterName == b.ClusterName && reflect.DeepEqual(a.Addresses(), b.Addresses()) && reflect.DeepEqual(a.Ports, b.Ports)
+}
+
+// setAliases replaces the aliases of the given service. It must be called with the lock held.
+func (m *Map) setAliases(si *serviceInfo, namespace, name string, aliases []string) {
+	for _, alias := range si.aliases {
+		if m.aliases[keyFunc(namespace, alias)] == name {
+			delete(m.aliases, keyFunc(namespace, alias))
+		}
+	}
+
+	si.aliases = aliases
+
+	for _, alias := range aliases {
+		m.aliases[keyFunc(namespace, alias)] = name
+	}
+}
+
+func parseAliases(annotation string) []string {
+	var aliases []string
+
+	for _, alias := range strings.Split(annotation, ",") {
+		alias = strings.TrimSpace(alias)
+		if alias == "" {
+			continue
+		}
+
+		// Aliases exported by other MCS implementations or older agents may not be encoded yet
+		encoded, err := dnsname.ToASCII(alias)
+		if err != nil {
+			klog.Warningf("Ignoring invalid service alias: %v", err)
+			continue
+		}
+
+		if errs := validation.IsDNS1123Label(encoded); len(errs) > 0 {
+			klog.Warningf("Ignoring invalid service alias %q: %v", alias, errs)
+			continue
+		}
+
+		aliases = append(aliases, encoded)
+	}
+
+	return aliases
+}
+
 func (m *Map) Remove(serviceImport *mcsv1a1.ServiceImport) {
 	if name, ok := serviceImport.Annotations["origin-name"]; ok {
-		namespace := serviceImport.Annotations["origin-namespace"]
+		namespace := ImportNamespace(serviceImport)
 		key := keyFunc(namespace, name)
 
 		m.Lock()
@@ -176,10 +343,14 @@ func (m *Map) Remove(serviceImport *mcsv1a1.ServiceImport) {
 		}
 
 		for _, info := range serviceImport.Status.Clusters {
-			delete(remoteService.records, info.Cluster)
+			if record := remoteService.records[info.Cluster]; record != nil {
+				record.RRs.Release()
+				delete(remoteService.records, info.Cluster)
+			}
 		}
 
 		if len(remoteService.records) == 0 {
+			m.setAliases(remoteService, namespace, name, nil)
 			delete(m.svcMap, key)
 		} else if !remoteService.isHeadless {
 			remoteService.buildClusterInfoQueue()
@@ -187,6 +358,193 @@ func (m *Map) Remove(serviceImport *mcsv1a1.ServiceImport) {
 	}
 }
 
+// Services returns the names of the services in the given namespace.
+func (m *Map) Services(namespace string) []string {
+	m.RLock()
+	defer m.RUnlock()
+
+	var names []string
+
+	for key := range m.svcMap {
+		if ns, name := splitKey(key); ns == namespace {
+			names = append(names, name)
+		}
+	}
+
+	return names
+}
+
+// OriginNamespace returns the namespace the given imported service is exported from, which differs from the namespace
+// it's imported in if the exporting cluster maps it to another one.
+func (m *Map) OriginNamespace(namespace, name string) string {
+	m.RLock()
+	defer m.RUnlock()
+
+	if si, ok := m.svcMap[keyFunc(namespace, name)]; ok && si.originNamespace != "" {
+		return si.originNamespace
+	}
+
+	return namespace
+}
+
+// parseMinReadyEndpoints returns the minimum number of ready endpoints requested by the given annotation, ignoring
+// invalid ones.
+func parseMinReadyEndpoints(annotation string) int {
+	if annotation == "" {
+		return 0
+	}
+
+	minimum, err := strconv.Atoi(annotation)
+	if err != nil || minimum < 0 {
+		klog.Warningf("Ignoring invalid minimum number of ready endpoints %q", annotation)
+		return 0
+	}
+
+	return minimum
+}
+
+// MinReadyEndpoints returns the number of ready endpoints the given service requires in a cluster for the cluster to
+// be eligible, as last imported, or 0 if it doesn't require any.
+func (m *Map) MinReadyEndpoints(namespace, name string) int {
+	m.RLock()
+	defer m.RUnlock()
+
+	if si, ok := m.svcMap[keyFunc(namespace, name)]; ok {
+		return si.minReadyEndpoints
+	}
+
+	return 0
+}
+
+// ClusterSelection returns the cluster selection policy requested for the given service, as last imported, if any.
+func (m *Map) ClusterSelection(namespace, name string) string {
+	m.RLock()
+	defer m.RUnlock()
+
+	if si, ok := m.svcMap[keyFunc(namespace, name)]; ok {
+		return si.clusterSelection
+	}
+
+	return ""
+}
+
+// Canonical returns the name of the service the given name is an alias of in the given namespace, if it's an alias
+// and not itself the name of a service.
+func (m *Map) Canonical(namespace, name string) (string, bool) {
+	m.RLock()
+	defer m.RUnlock()
+
+	if _, ok := m.svcMap[keyFunc(namespace, name)]; ok {
+		return "", false
+	}
+
+	canonical, ok := m.aliases[keyFunc(namespace, name)]
+
+	return canonical, ok
+}
+
+// HasCluster checks whether the given cluster exports the given ClusterSetIP service.
+func (m *Map) HasCluster(namespace, name, cluster string) bool {
+	m.RLock()
+	defer m.RUnlock()
+
+	if si, ok := m.svcMap[keyFunc(namespace, name)]; ok {
+		_, found := si.records[cluster]
+		return found
+	}
+
+	return false
+}
+
+// ImportNamespace returns the namespace in which the given ServiceImport's service is served: the namespace it's mapped
+// to by the exporting cluster, if any, otherwise the namespace it's exported from.
+func ImportNamespace(serviceImport *mcsv1a1.ServiceImport) string {
+	if namespace := serviceImport.Annotations[lhconstants.ImportNamespace]; namespace != "" {
+		return namespace
+	}
+
+	return serviceImport.Annotations[lhconstants.OriginNamespace]
+}
+
+// ServiceState describes a service as held in a map, for diagnostics.
+type ServiceState struct {
+	Name      string
+	Namespace string
+	Headless  bool
+	// SessionAffinity is the session affinity of the service, and SessionAffinityTimeoutSeconds its ClientIP
+	// timeout, zero if unset
+	SessionAffinity               corev1.ServiceAffinity
+	SessionAffinityTimeoutSeconds int32
+	// Records holds copies of the service's records, without their cached resource records
+	Records []DNSRecord
+}
+
+// Dump returns the state of every service in the map, sorted by namespace and name.
+func (m *Map) Dump() []ServiceState {
+	m.RLock()
+	defer m.RUnlock()
+
+	services := make([]ServiceState, 0, len(m.svcMap))
+
+	for key, si := range m.svcMap {
+		namespace, name := splitKey(key)
+		state := ServiceState{
+			Name:                          name,
+			Namespace:                     namespace,
+			Headless:                      si.isHeadless,
+			SessionAffinity:               si.sessionAffinity,
+			SessionAffinityTimeoutSeconds: si.sessionAffinityTimeout,
+		}
+
+		for _, record := range si.records {
+			copied := *record
+			copied.RRs = nil
+			state.Records = append(state.Records, copied)
+		}
+
+		services = append(services, state)
+	}
+
+	SortServiceStates(services)
+
+	return services
+}
+
+// SortServiceStates sorts services by namespace and name, and their records by cluster, IP and hostname.
+func SortServiceStates(services []ServiceState) {
+	sort.Slice(services, func(i, j int) bool {
+		if services[i].Namespace != services[j].Namespace {
+			return services[i].Namespace < services[j].Namespace
+		}
+
+		return services[i].Name < services[j].Name
+	})
+
+	for _, service := range services {
+		records := service.Records
+		sort.Slice(records, func(i, j int) bool {
+			if records[i].ClusterName != records[j].ClusterName {
+				return records[i].ClusterName < records[j].ClusterName
+			}
+
+			if records[i].IP != records[j].IP {
+				return records[i].IP < records[j].IP
+			}
+
+			return records[i].HostName < records[j].HostName
+		})
+	}
+}
+
+func splitKey(key string) (namespace, name string) {
+	parts := strings.SplitN(key, "/", 2)
+	if len(parts) < 2 {
+		return "", key
+	}
+
+	return parts[0], parts[1]
+}
+
 func keyFunc(namespace, name string) string {
 	return namespace + "/" + name
 }
@@ -18,9 +18,13 @@ limitations under the License.
 package serviceimport_test
 
 import (
+	"github.com/miekg/dns"
 	. "github.com/onsi/ginkgo"
 	. "github.com/onsi/gomega"
+	lhconstants "github.com/submariner-io/lighthouse/pkg/constants"
 	"github.com/submariner-io/lighthouse/pkg/serviceimport"
+	corev1 "k8s.io/api/core/v1"
+	mcsv1a1 "sigs.k8s.io/mcs-api/pkg/apis/v1alpha1"
 )
 
 var _ = Describe("ServiceImport Map", func() {
@@ -180,6 +184,51 @@ var _ = Describe("ServiceImport Map", func() {
 		})
 	})
 
+	When("a service's ServiceImport is put again", func() {
+		var first *serviceimport.DNSRecord
+
+		BeforeEach(func() {
+			serviceImportMap.Put(newServiceImport(namespace1, service1, serviceIP1, clusterID1))
+			first, _, _ = serviceImportMap.GetIP(namespace1, service1, clusterID1, "", checkCluster, checkEndpoint)
+			Expect(first).ToNot(BeNil())
+		})
+
+		It("should keep the record and its cached resource records if it's unchanged", func() {
+			serviceImportMap.Put(newServiceImport(namespace1, service1, serviceIP1, clusterID1))
+
+			record, _, _ := serviceImportMap.GetIP(namespace1, service1, clusterID1, "", checkCluster, checkEndpoint)
+			Expect(record).To(BeIdenticalTo(first))
+		})
+
+		It("should replace the record if it changed", func() {
+			serviceImportMap.Put(newServiceImport(namespace1, service1, serviceIP2, clusterID1))
+
+			record, _, _ := serviceImportMap.GetIP(namespace1, service1, clusterID1, "", checkCluster, checkEndpoint)
+			Expect(record.IP).To(Equal(serviceIP2))
+			Expect(record.RRs).ToNot(BeIdenticalTo(first.RRs))
+		})
+
+		It("should release the cached resource records of a replaced record from the budget", func() {
+			budget := serviceimport.NewRRCacheBudget()
+			budget.SetLimit(1 << 20)
+			serviceImportMap.SetRRCacheBudget(budget)
+			serviceImportMap.Put(newServiceImport(namespace1, service1, serviceIP2, clusterID1))
+
+			record, _, _ := serviceImportMap.GetIP(namespace1, service1, clusterID1, "", checkCluster, checkEndpoint)
+			record.RRs.Get(serviceimport.RRKey{Name: "service1.namespace1.svc.clusterset.local.", Qtype: dns.TypeA}, func() []dns.RR {
+				return []dns.RR{&dns.A{Hdr: dns.RR_Header{Name: "service1.namespace1.svc.clusterset.local.", Rrtype: dns.TypeA}}}
+			})
+
+			size, _ := budget.Usage()
+			Expect(size).To(BeNumerically(">", 0))
+
+			serviceImportMap.Put(newServiceImport(namespace1, service1, serviceIP1, clusterID1))
+
+			size, _ = budget.Usage()
+			Expect(size).To(BeZero())
+		})
+	})
+
 	When("a service is present in three connected clusters", func() {
 		JustBeforeEach(func() {
 			serviceImportMap.Put(newServiceImport(namespace1, service1, serviceIP1, clusterID1))
@@ -209,6 +258,37 @@ var _ = Describe("ServiceImport Map", func() {
 		})
 	})
 
+	When("the map is dumped", func() {
+		It("should return every service with its records sorted by cluster", func() {
+			serviceImportMap.Put(newServiceImport(namespace1, service1, serviceIP2, clusterID2))
+			serviceImportMap.Put(newServiceImport(namespace1, service1, serviceIP1, clusterID1))
+
+			services := serviceImportMap.Dump()
+			Expect(services).To(HaveLen(1))
+			Expect(services[0].Name).To(Equal(service1))
+			Expect(services[0].Namespace).To(Equal(namespace1))
+			Expect(services[0].Headless).To(BeFalse())
+			Expect(services[0].Records).To(HaveLen(2))
+			Expect(services[0].Records[0].IP).To(Equal(serviceIP1))
+			Expect(services[0].Records[0].ClusterName).To(Equal(clusterID1))
+			Expect(services[0].Records[1].IP).To(Equal(serviceIP2))
+			Expect(services[0].SessionAffinity).To(Equal(corev1.ServiceAffinityNone))
+		})
+
+		It("should return the session affinity of the service", func() {
+			timeout := int32(600)
+			si := newServiceImport(namespace1, service1, serviceIP1, clusterID1)
+			si.Spec.SessionAffinity = corev1.ServiceAffinityClientIP
+			si.Spec.SessionAffinityConfig = &corev1.SessionAffinityConfig{ClientIP: &corev1.ClientIPConfig{TimeoutSeconds: &timeout}}
+			serviceImportMap.Put(si)
+
+			services := serviceImportMap.Dump()
+			Expect(services).To(HaveLen(1))
+			Expect(services[0].SessionAffinity).To(Equal(corev1.ServiceAffinityClientIP))
+			Expect(services[0].SessionAffinityTimeoutSeconds).To(Equal(timeout))
+		})
+	})
+
 	When("a service is present in one disconnected cluster", func() {
 		It("should consistently return found with empty IP", func() {
 			clusterStatusMap[clusterID1] = false
@@ -255,6 +335,51 @@ var _ = Describe("ServiceImport Map", func() {
 		})
 	})
 
+	When("a service is mapped to another namespace by the exporting cluster", func() {
+		BeforeEach(func() {
+			si := newServiceImport(namespace1, service1, serviceIP1, clusterID1)
+			si.Annotations[lhconstants.ImportNamespace] = namespace2
+			serviceImportMap.Put(si)
+		})
+
+		It("should return its IP in the mapped namespace only", func() {
+			Expect(getIP(namespace2, service1)).To(Equal(serviceIP1))
+			expectIPsNotFound(namespace1, service1, "", "")
+		})
+
+		It("should return the namespace it's exported from", func() {
+			Expect(serviceImportMap.OriginNamespace(namespace2, service1)).To(Equal(namespace1))
+		})
+	})
+
+	When("all records of a service present in three clusters are requested", func() {
+		getAllIPs := func() []string {
+			records, found := serviceImportMap.GetAllRecords(namespace1, service1, checkCluster, checkEndpoint)
+			Expect(found).To(BeTrue())
+
+			ips := []string{}
+			for _, record := range records {
+				ips = append(ips, record.IP)
+			}
+
+			return ips
+		}
+
+		BeforeEach(func() {
+			serviceImportMap.Put(newServiceImport(namespace1, service1, serviceIP1, clusterID1))
+			serviceImportMap.Put(newServiceImport(namespace1, service1, serviceIP2, clusterID2))
+			serviceImportMap.Put(newServiceImport(namespace1, service1, serviceIP3, clusterID3))
+		})
+
+		It("should return the IPs of all the eligible clusters", func() {
+			Expect(getAllIPs()).To(ConsistOf(serviceIP1, serviceIP2, serviceIP3))
+
+			clusterStatusMap[clusterID1] = false
+			endpointStatusMap[clusterID3] = false
+			Expect(getAllIPs()).To(ConsistOf(serviceIP2))
+		})
+	})
+
 	When("a service does not exist", func() {
 		It("should return not found", func() {
 			expectIPsNotFound(namespace1, service1, "", "")
@@ -299,4 +424,63 @@ var _ = Describe("ServiceImport Map", func() {
 			}
 		})
 	})
+
+	When("a service has aliases", func() {
+		var si *mcsv1a1.ServiceImport
+
+		BeforeEach(func() {
+			si = newServiceImport(namespace1, service1, serviceIP1, clusterID1)
+			si.Annotations[lhconstants.Aliases] = "alias1, Alias2,invalid_alias"
+			serviceImportMap.Put(si)
+		})
+
+		It("should return the service for its valid aliases in its namespace", func() {
+			canonical, found := serviceImportMap.Canonical(namespace1, "alias1")
+			Expect(found).To(BeTrue())
+			Expect(canonical).To(Equal(service1))
+
+			canonical, found = serviceImportMap.Canonical(namespace1, "alias2")
+			Expect(found).To(BeTrue())
+			Expect(canonical).To(Equal(service1))
+
+			_, found = serviceImportMap.Canonical(namespace1, "invalid_alias")
+			Expect(found).To(BeFalse())
+
+			_, found = serviceImportMap.Canonical(namespace2, "alias1")
+			Expect(found).To(BeFalse())
+		})
+
+		It("should drop the aliases which are no longer set", func() {
+			si.Annotations[lhconstants.Aliases] = "alias2"
+			serviceImportMap.Put(si)
+
+			_, found := serviceImportMap.Canonical(namespace1, "alias1")
+			Expect(found).To(BeFalse())
+		})
+
+		It("should drop the aliases when the service is removed", func() {
+			serviceImportMap.Remove(si)
+
+			_, found := serviceImportMap.Canonical(namespace1, "alias1")
+			Expect(found).To(BeFalse())
+		})
+	})
+
+	When("a service requires a minimum number of ready endpoints", func() {
+		It("should return the minimum", func() {
+			si := newServiceImport(namespace1, service1, serviceIP1, clusterID1)
+			si.Annotations[lhconstants.MinReadyEndpoints] = "3"
+			serviceImportMap.Put(si)
+
+			Expect(serviceImportMap.MinReadyEndpoints(namespace1, service1)).To(Equal(3))
+		})
+
+		It("should ignore an invalid minimum", func() {
+			si := newServiceImport(namespace1, service1, serviceIP1, clusterID1)
+			si.Annotations[lhconstants.MinReadyEndpoints] = "-1"
+			serviceImportMap.Put(si)
+
+			Expect(serviceImportMap.MinReadyEndpoints(namespace1, service1)).To(BeZero())
+		})
+	})
 })
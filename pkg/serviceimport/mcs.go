@@ -0,0 +1,65 @@
+/*
+SPDX-License-Identifier: Apache-2.0
+
+Copyright Contributors to the Submariner project.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package serviceimport
+
+import (
+	lhconstants "github.com/submariner-io/lighthouse/pkg/constants"
+	mcsv1a1 "sigs.k8s.io/mcs-api/pkg/apis/v1alpha1"
+)
+
+// FromMCS converts a ServiceImport created by any MCS implementation, named after its service in the service's
+// namespace and listing the clusters exporting it in its status, to the per-cluster ServiceImports created by the
+// Lighthouse agent, so that it can be put in a map. Lighthouse ServiceImports are returned as is. A ServiceImport
+// which doesn't list its clusters is converted as exported by a single unnamed cluster, and a ClusterSetIP one isn't
+// converted until its IPs are allocated.
+func FromMCS(serviceImport *mcsv1a1.ServiceImport) []*mcsv1a1.ServiceImport {
+	if _, ok := serviceImport.Annotations[lhconstants.OriginName]; ok {
+		return []*mcsv1a1.ServiceImport{serviceImport}
+	}
+
+	if serviceImport.Spec.Type == mcsv1a1.ClusterSetIP && len(serviceImport.Spec.IPs) == 0 {
+		return nil
+	}
+
+	clusters := serviceImport.Status.Clusters
+	if len(clusters) == 0 {
+		clusters = []mcsv1a1.ClusterStatus{{}}
+	}
+
+	converted := make([]*mcsv1a1.ServiceImport, 0, len(clusters))
+
+	for _, cluster := range clusters {
+		si := serviceImport.DeepCopy()
+		if si.Annotations == nil {
+			si.Annotations = map[string]string{}
+		}
+
+		if si.Labels == nil {
+			si.Labels = map[string]string{}
+		}
+
+		si.Annotations[lhconstants.OriginName] = serviceImport.Name
+		si.Annotations[lhconstants.OriginNamespace] = serviceImport.Namespace
+		si.Labels[lhconstants.LabelSourceCluster] = cluster.Cluster
+		si.Status.Clusters = []mcsv1a1.ClusterStatus{cluster}
+
+		converted = append(converted, si)
+	}
+
+	return converted
+}
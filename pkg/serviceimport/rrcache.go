@@ -0,0 +1,263 @@
+/*
+SPDX-License-Identifier: Apache-2.0
+
+Copyright Contributors to the Submariner project.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package serviceimport
+
+import (
+	"container/list"
+	"sync"
+	"sync/atomic"
+
+	"github.com/miekg/dns"
+)
+
+// maxRRCacheEntries bounds the number of query variants (e.g. types, names or zones) cached per record.
+const maxRRCacheEntries = 32
+
+// Estimates of the memory used by a cache entry besides its records' wire data: the map and list elements, the key and
+// the slice, and each record's struct and header.
+const (
+	rrCacheEntryOverhead = 256
+	rrOverhead           = 64
+)
+
+// RRKey identifies the query a set of resource records was built for.
+type RRKey struct {
+	Name   string
+	Zone   string
+	Qtype  uint16
+	Qclass uint16
+	TTL    uint32
+	// Priority is the priority of SRV records, which depends on whether the record's cluster is the local one
+	Priority uint16
+}
+
+// RRCache holds the resource records built from a DNSRecord so they don't have to be rebuilt on every query. A new
+// cache is created whenever a record is changed by an informer update, so cached entries never go stale; records an
+// update leaves unchanged keep their cache. The returned records are shared between responses and must not be modified.
+type RRCache struct {
+	mutex   sync.RWMutex
+	entries map[RRKey]*rrCacheEntry
+	budget  *RRCacheBudget
+	// released is set once the cache is replaced, after which it caches nothing more
+	released bool
+}
+
+type rrCacheEntry struct {
+	cache *RRCache
+	key   RRKey
+	rrs   []dns.RR
+	size  int64
+	// elem is the entry's element in the budget's LRU list, nil if it isn't accounted for; it's only accessed with the
+	// budget's mutex held
+	elem *list.Element
+}
+
+// RRCacheBudget bounds the memory used by the RR caches sharing it, i.e. those of a plugin instance, evicting the least
+// recently used entries once it's exceeded. While its limit is 0, the caches are only bounded by maxRRCacheEntries and
+// nothing is accounted for.
+type RRCacheBudget struct {
+	// bounded is set while the limit isn't 0, so that cache hits don't contend on the mutex otherwise
+	bounded   int32
+	mutex     sync.Mutex
+	limit     int64
+	size      int64
+	evictions uint64
+	lru       list.List
+}
+
+func NewRRCacheBudget() *RRCacheBudget {
+	return &RRCacheBudget{}
+}
+
+// SetLimit bounds the estimated memory used by the caches sharing the budget to the given number of bytes, or removes
+// the bound if 0. The bound only applies to the entries cached afterwards.
+func (b *RRCacheBudget) SetLimit(bytes int64) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	b.limit = bytes
+
+	bounded := int32(0)
+	if bytes > 0 {
+		bounded = 1
+	}
+
+	atomic.StoreInt32(&b.bounded, bounded)
+}
+
+// Usage returns the estimated memory used by the caches sharing the budget in bytes, and the number of entries evicted
+// to stay within its limit.
+func (b *RRCacheBudget) Usage() (size int64, evictions uint64) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	return b.size, b.evictions
+}
+
+// NewRRCache returns a cache accounted for in the given budget; a nil budget leaves it bounded by maxRRCacheEntries only.
+func NewRRCache(budget *RRCacheBudget) *RRCache {
+	return &RRCache{
+		entries: make(map[RRKey]*rrCacheEntry),
+		budget:  budget,
+	}
+}
+
+// Get returns the records cached for the given key, calling build to create and cache them if absent. A nil cache
+// simply returns the result of build.
+func (c *RRCache) Get(key RRKey, build func() []dns.RR) []dns.RR {
+	if c == nil {
+		return build()
+	}
+
+	c.mutex.RLock()
+	entry, ok := c.entries[key]
+	c.mutex.RUnlock()
+
+	if ok {
+		c.budget.touch(entry)
+		return entry.rrs
+	}
+
+	entry = &rrCacheEntry{cache: c, key: key, rrs: build()}
+
+	c.mutex.Lock()
+
+	if _, ok := c.entries[key]; ok || c.released || len(c.entries) >= maxRRCacheEntries {
+		c.mutex.Unlock()
+		return entry.rrs
+	}
+
+	c.entries[key] = entry
+	c.mutex.Unlock()
+
+	// The budget is only accounted for once the cache's lock is released, since evictions lock the caches they evict from
+	c.budget.add(entry)
+
+	return entry.rrs
+}
+
+// Release removes the cache's entries from its budget once the cache is replaced by an update or its record removed.
+// Queries still holding the cache can use it, but it caches nothing more.
+func (c *RRCache) Release() {
+	if c == nil {
+		return
+	}
+
+	c.mutex.Lock()
+
+	c.released = true
+	entries := make([]*rrCacheEntry, 0, len(c.entries))
+
+	for _, entry := range c.entries {
+		entries = append(entries, entry)
+	}
+
+	c.mutex.Unlock()
+
+	c.budget.release(entries)
+}
+
+func (c *RRCache) remove(entry *rrCacheEntry) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	if c.entries[entry.key] == entry {
+		delete(c.entries, entry.key)
+	}
+}
+
+// touch marks the given entry as the most recently used.
+func (b *RRCacheBudget) touch(entry *rrCacheEntry) {
+	if b == nil || atomic.LoadInt32(&b.bounded) == 0 {
+		return
+	}
+
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	if entry.elem != nil {
+		b.lru.MoveToFront(entry.elem)
+	}
+}
+
+// add accounts for the given entry, evicting the least recently used entries if the limit is exceeded.
+func (b *RRCacheBudget) add(entry *rrCacheEntry) {
+	if b == nil {
+		return
+	}
+
+	b.mutex.Lock()
+
+	// An entry cached while its cache is being released isn't among the entries released, so it mustn't be accounted for
+	entry.cache.mutex.RLock()
+	released := entry.cache.released
+	entry.cache.mutex.RUnlock()
+
+	if b.limit <= 0 || released {
+		b.mutex.Unlock()
+		return
+	}
+
+	entry.size = rrCacheEntrySize(entry)
+	entry.elem = b.lru.PushFront(entry)
+	b.size += entry.size
+
+	var evicted []*rrCacheEntry
+
+	for b.size > b.limit {
+		victim := b.lru.Remove(b.lru.Back()).(*rrCacheEntry)
+		victim.elem = nil
+		b.size -= victim.size
+		b.evictions++
+		evicted = append(evicted, victim)
+	}
+
+	b.mutex.Unlock()
+
+	for _, victim := range evicted {
+		victim.cache.remove(victim)
+	}
+}
+
+// release stops accounting for the given entries.
+func (b *RRCacheBudget) release(entries []*rrCacheEntry) {
+	if b == nil || len(entries) == 0 {
+		return
+	}
+
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	for _, entry := range entries {
+		if entry.elem != nil {
+			b.lru.Remove(entry.elem)
+			entry.elem = nil
+			b.size -= entry.size
+		}
+	}
+}
+
+func rrCacheEntrySize(entry *rrCacheEntry) int64 {
+	size := rrCacheEntryOverhead + len(entry.key.Name) + len(entry.key.Zone)
+
+	for _, rr := range entry.rrs {
+		size += rrOverhead + dns.Len(rr)
+	}
+
+	return int64(size)
+}
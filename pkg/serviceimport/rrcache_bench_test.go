@@ -0,0 +1,73 @@
+/*
+SPDX-License-Identifier: Apache-2.0
+
+Copyright Contributors to the Submariner project.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package serviceimport_test
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/miekg/dns"
+	"github.com/submariner-io/lighthouse/pkg/serviceimport"
+)
+
+const benchmarkKeys = 1000
+
+func benchmarkRRCacheGet(b *testing.B, limit int64) {
+	budget := serviceimport.NewRRCacheBudget()
+	budget.SetLimit(limit)
+
+	caches := make([]*serviceimport.RRCache, benchmarkKeys)
+	keys := make([]serviceimport.RRKey, benchmarkKeys)
+
+	for i := range keys {
+		caches[i] = serviceimport.NewRRCache(budget)
+		keys[i] = serviceimport.RRKey{Name: fmt.Sprintf("service%d.namespace1.svc.clusterset.local.", i), Qtype: dns.TypeA,
+			Qclass: dns.ClassINET, TTL: 5}
+	}
+
+	build := func(i int) func() []dns.RR {
+		return func() []dns.RR {
+			return []dns.RR{&dns.A{Hdr: dns.RR_Header{Name: keys[i].Name, Rrtype: dns.TypeA, Class: dns.ClassINET, Ttl: 5}}}
+		}
+	}
+
+	b.ReportAllocs()
+	b.ResetTimer()
+
+	b.RunParallel(func(pb *testing.PB) {
+		i := 0
+		for pb.Next() {
+			caches[i%benchmarkKeys].Get(keys[i%benchmarkKeys], build(i%benchmarkKeys))
+			i++
+		}
+	})
+}
+
+func BenchmarkRRCacheGet(b *testing.B) {
+	benchmarkRRCacheGet(b, 0)
+}
+
+// BenchmarkRRCacheGetBounded measures the cost of the LRU accounting while every entry fits in the bound.
+func BenchmarkRRCacheGetBounded(b *testing.B) {
+	benchmarkRRCacheGet(b, 1<<30)
+}
+
+// BenchmarkRRCacheGetEvicting measures the cost of evicting and rebuilding entries when only some of them fit.
+func BenchmarkRRCacheGetEvicting(b *testing.B) {
+	benchmarkRRCacheGet(b, benchmarkKeys*50)
+}
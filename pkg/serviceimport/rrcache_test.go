@@ -0,0 +1,164 @@
+/*
+SPDX-License-Identifier: Apache-2.0
+
+Copyright Contributors to the Submariner project.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package serviceimport_test
+
+import (
+	"fmt"
+
+	"github.com/miekg/dns"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/submariner-io/lighthouse/pkg/serviceimport"
+)
+
+var _ = Describe("RRCache", func() {
+	var (
+		cache  *serviceimport.RRCache
+		key    serviceimport.RRKey
+		builds int
+	)
+
+	build := func() []dns.RR {
+		builds++
+		return []dns.RR{&dns.A{Hdr: dns.RR_Header{Name: key.Name, Rrtype: dns.TypeA, Class: key.Qclass, Ttl: key.TTL}}}
+	}
+
+	BeforeEach(func() {
+		cache = serviceimport.NewRRCache(nil)
+		key = serviceimport.RRKey{Name: "service1.namespace1.svc.clusterset.local.", Qtype: dns.TypeA, Qclass: dns.ClassINET, TTL: 5}
+		builds = 0
+	})
+
+	When("records are requested repeatedly for the same key", func() {
+		It("should build them once and return the cached records", func() {
+			first := cache.Get(key, build)
+			Expect(cache.Get(key, build)[0]).To(BeIdenticalTo(first[0]))
+			Expect(builds).To(Equal(1))
+		})
+	})
+
+	When("records are requested for different keys", func() {
+		It("should build them for each key", func() {
+			cache.Get(key, build)
+
+			key.TTL = 10
+			Expect(cache.Get(key, build)[0].Header().Ttl).To(Equal(uint32(10)))
+			Expect(builds).To(Equal(2))
+		})
+	})
+
+	When("the number of keys exceeds the limit", func() {
+		It("should keep building records for the additional keys", func() {
+			for i := 0; i < 100; i++ {
+				key.Name = fmt.Sprintf("service%d.namespace1.svc.clusterset.local.", i)
+				cache.Get(key, build)
+				cache.Get(key, build)
+			}
+
+			Expect(builds).To(BeNumerically(">", 100))
+		})
+	})
+
+	When("the cache is nil", func() {
+		It("should build the records on every request", func() {
+			var nilCache *serviceimport.RRCache
+			nilCache.Get(key, build)
+			nilCache.Get(key, build)
+			Expect(builds).To(Equal(2))
+		})
+	})
+
+	When("the memory of the caches is bounded", func() {
+		var (
+			budget     *serviceimport.RRCacheBudget
+			otherCache *serviceimport.RRCache
+		)
+
+		keyFor := func(i int) serviceimport.RRKey {
+			k := key
+			k.Name = fmt.Sprintf("service%d.namespace1.svc.clusterset.local.", i)
+
+			return k
+		}
+
+		BeforeEach(func() {
+			budget = serviceimport.NewRRCacheBudget()
+			cache = serviceimport.NewRRCache(budget)
+			otherCache = serviceimport.NewRRCache(budget)
+
+			// Enough for two entries
+			budget.SetLimit(1000)
+		})
+
+		It("should evict the least recently used entries across caches", func() {
+			cache.Get(keyFor(0), build)
+			otherCache.Get(keyFor(1), build)
+			cache.Get(keyFor(0), build)
+			Expect(builds).To(Equal(2))
+
+			otherCache.Get(keyFor(2), build)
+
+			size, evictions := budget.Usage()
+			Expect(evictions).To(Equal(uint64(1)))
+			Expect(size).To(BeNumerically("<=", 1000))
+
+			cache.Get(keyFor(0), build)
+			Expect(builds).To(Equal(3))
+
+			otherCache.Get(keyFor(1), build)
+			Expect(builds).To(Equal(4))
+		})
+
+		It("should not account for the entries of other budgets", func() {
+			serviceimport.NewRRCache(serviceimport.NewRRCacheBudget()).Get(keyFor(0), build)
+
+			size, _ := budget.Usage()
+			Expect(size).To(BeZero())
+		})
+
+		Context("and a cache is released", func() {
+			It("should stop accounting for its entries", func() {
+				cache.Get(keyFor(0), build)
+				otherCache.Get(keyFor(1), build)
+				before, _ := budget.Usage()
+
+				cache.Release()
+
+				after, _ := budget.Usage()
+				Expect(after).To(BeNumerically(">", 0))
+				Expect(after).To(BeNumerically("<", before))
+
+				// The released entry leaves room for another one
+				otherCache.Get(keyFor(2), build)
+
+				_, evictions := budget.Usage()
+				Expect(evictions).To(BeZero())
+			})
+
+			It("should not cache any more entries", func() {
+				cache.Release()
+				cache.Get(keyFor(0), build)
+				cache.Get(keyFor(0), build)
+				Expect(builds).To(Equal(2))
+
+				size, _ := budget.Usage()
+				Expect(size).To(BeZero())
+			})
+		})
+	})
+})
@@ -0,0 +1,188 @@
+/*
+SPDX-License-Identifier: Apache-2.0
+
+Copyright Contributors to the Submariner project.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package simulate
+
+import (
+	"reflect"
+	"sort"
+
+	"github.com/submariner-io/lighthouse/pkg/endpointslice"
+	"github.com/submariner-io/lighthouse/pkg/serviceimport"
+	discovery "k8s.io/api/discovery/v1beta1"
+	mcsv1a1 "sigs.k8s.io/mcs-api/pkg/apis/v1alpha1"
+)
+
+// Snapshot is the registry state answers are computed from, as seen by the plugin in the local cluster.
+type Snapshot struct {
+	LocalClusterID string
+	ServiceImports []*mcsv1a1.ServiceImport
+	EndpointSlices []*discovery.EndpointSlice
+}
+
+// Conditions describes a hypothetical state of the clusters. Clusters which aren't listed are connected, and services
+// are healthy in them.
+type Conditions struct {
+	// Disconnected lists the clusters whose gateway isn't connected to the local cluster
+	Disconnected []string
+	// Unhealthy maps a service, as "name.namespace", to the clusters in which it has no ready endpoints
+	Unhealthy map[string][]string
+}
+
+// Answer describes how queries for a service's clusterset name would be answered.
+type Answer struct {
+	// Found is false if the name doesn't exist, and queries get NXDOMAIN
+	Found    bool
+	Headless bool
+	// Records lists the records queries may be answered with. Headless services are answered with all of them;
+	// ClusterSetIP services with one, preferring the local cluster and otherwise rotating through the others. If it's
+	// empty, queries get NODATA.
+	Records []serviceimport.DNSRecord
+}
+
+// Result maps each service in a snapshot, as "name.namespace", to its answer.
+type Result map[string]Answer
+
+// Simulator computes the answers the plugin would serve from a snapshot under various conditions, using the same
+// record selection as the plugin, so that failovers can be rehearsed and policy changes validated beforehand.
+type Simulator struct {
+	localClusterID string
+	serviceImports *serviceimport.Map
+	endpointSlices *endpointslice.Map
+}
+
+func New(snapshot *Snapshot) *Simulator {
+	s := &Simulator{
+		localClusterID: snapshot.LocalClusterID,
+		serviceImports: serviceimport.NewMap(),
+		endpointSlices: endpointslice.NewMap(),
+	}
+
+	for _, si := range snapshot.ServiceImports {
+		s.serviceImports.Put(si)
+	}
+
+	for _, es := range snapshot.EndpointSlices {
+		s.endpointSlices.Put(es)
+	}
+
+	return s
+}
+
+// Answer returns the answer for the given service under the given conditions.
+func (s *Simulator) Answer(name, namespace string, conditions *Conditions) Answer {
+	isConnected := conditions.isConnected(s.localClusterID)
+	isHealthy := conditions.isHealthy
+
+	records, found := s.serviceImports.GetAllRecords(namespace, name, isConnected, isHealthy)
+	if !found {
+		records, found = s.endpointSlices.GetDNSRecords("", "", namespace, name, isConnected)
+		return newAnswer(found, true, records)
+	}
+
+	// The local cluster is always answered with when the service is healthy there, whatever the load balancing
+	for i := range records {
+		if records[i].ClusterName == s.localClusterID {
+			return newAnswer(true, false, records[i:i+1])
+		}
+	}
+
+	return newAnswer(true, false, records)
+}
+
+// Run returns the answers for every service in the snapshot under the given conditions.
+func (s *Simulator) Run(conditions *Conditions) Result {
+	result := Result{}
+
+	for _, services := range [][]serviceimport.ServiceState{s.serviceImports.Dump(), s.endpointSlices.Dump()} {
+		for i := range services {
+			key := services[i].Name + "." + services[i].Namespace
+			if _, ok := result[key]; !ok {
+				result[key] = s.Answer(services[i].Name, services[i].Namespace, conditions)
+			}
+		}
+	}
+
+	return result
+}
+
+// Changes returns the services whose answers differ between two results, sorted.
+func Changes(before, after Result) []string {
+	changes := []string{}
+
+	for key, answer := range before {
+		if !reflect.DeepEqual(answer, after[key]) {
+			changes = append(changes, key)
+		}
+	}
+
+	for key := range after {
+		if _, ok := before[key]; !ok {
+			changes = append(changes, key)
+		}
+	}
+
+	sort.Strings(changes)
+
+	return changes
+}
+
+func (c *Conditions) isConnected(localClusterID string) func(string) bool {
+	return func(clusterID string) bool {
+		if clusterID == localClusterID {
+			return true
+		}
+
+		for _, disconnected := range c.Disconnected {
+			if disconnected == clusterID {
+				return false
+			}
+		}
+
+		return true
+	}
+}
+
+func (c *Conditions) isHealthy(name, namespace, clusterID string) bool {
+	for _, unhealthy := range c.Unhealthy[name+"."+namespace] {
+		if unhealthy == clusterID {
+			return false
+		}
+	}
+
+	return true
+}
+
+func newAnswer(found, headless bool, records []serviceimport.DNSRecord) Answer {
+	answer := Answer{Found: found, Headless: headless}
+
+	for i := range records {
+		record := records[i]
+		record.RRs = nil
+		answer.Records = append(answer.Records, record)
+	}
+
+	sort.Slice(answer.Records, func(i, j int) bool {
+		if answer.Records[i].ClusterName != answer.Records[j].ClusterName {
+			return answer.Records[i].ClusterName < answer.Records[j].ClusterName
+		}
+
+		return answer.Records[i].IP < answer.Records[j].IP
+	})
+
+	return answer
+}
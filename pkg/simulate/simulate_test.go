@@ -0,0 +1,173 @@
+/*
+SPDX-License-Identifier: Apache-2.0
+
+Copyright Contributors to the Submariner project.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package simulate_test
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	lhconstants "github.com/submariner-io/lighthouse/pkg/constants"
+	"github.com/submariner-io/lighthouse/pkg/simulate"
+	discovery "k8s.io/api/discovery/v1beta1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	mcsv1a1 "sigs.k8s.io/mcs-api/pkg/apis/v1alpha1"
+)
+
+const (
+	service1   = "service1"
+	service2   = "service2"
+	namespace1 = "namespace1"
+	clusterID1 = "cluster1"
+	clusterID2 = "cluster2"
+	clusterID3 = "cluster3"
+	serviceIP1 = "100.96.156.101"
+	serviceIP2 = "100.96.156.102"
+	serviceIP3 = "100.96.156.103"
+	endpointIP = "100.96.157.101"
+)
+
+var _ = Describe("Simulator", func() {
+	var (
+		snapshot   *simulate.Snapshot
+		conditions *simulate.Conditions
+	)
+
+	BeforeEach(func() {
+		snapshot = &simulate.Snapshot{
+			LocalClusterID: clusterID1,
+			ServiceImports: []*mcsv1a1.ServiceImport{
+				newServiceImport(namespace1, service1, serviceIP2, clusterID2),
+				newServiceImport(namespace1, service1, serviceIP3, clusterID3),
+			},
+			EndpointSlices: []*discovery.EndpointSlice{newEndpointSlice(namespace1, service2, clusterID2, endpointIP)},
+		}
+
+		conditions = &simulate.Conditions{}
+	})
+
+	answerIPs := func(answer simulate.Answer) []string {
+		ips := []string{}
+		for i := range answer.Records {
+			ips = append(ips, answer.Records[i].IP)
+		}
+
+		return ips
+	}
+
+	When("all clusters are connected and healthy", func() {
+		It("should answer with every exporting cluster", func() {
+			answer := simulate.New(snapshot).Answer(service1, namespace1, conditions)
+			Expect(answer.Found).To(BeTrue())
+			Expect(answer.Headless).To(BeFalse())
+			Expect(answerIPs(answer)).To(Equal([]string{serviceIP2, serviceIP3}))
+		})
+	})
+
+	When("the local cluster exports the service", func() {
+		It("should only answer with the local cluster", func() {
+			snapshot.ServiceImports = append(snapshot.ServiceImports, newServiceImport(namespace1, service1, serviceIP1, clusterID1))
+			answer := simulate.New(snapshot).Answer(service1, namespace1, conditions)
+			Expect(answerIPs(answer)).To(Equal([]string{serviceIP1}))
+		})
+	})
+
+	When("a cluster is disconnected", func() {
+		It("should fail over to the other clusters", func() {
+			conditions.Disconnected = []string{clusterID2}
+			Expect(answerIPs(simulate.New(snapshot).Answer(service1, namespace1, conditions))).To(Equal([]string{serviceIP3}))
+			Expect(simulate.New(snapshot).Answer(service2, namespace1, conditions).Records).To(BeEmpty())
+		})
+	})
+
+	When("a service is unhealthy in every cluster", func() {
+		It("should answer with no records", func() {
+			conditions.Unhealthy = map[string][]string{service1 + "." + namespace1: {clusterID2, clusterID3}}
+			answer := simulate.New(snapshot).Answer(service1, namespace1, conditions)
+			Expect(answer.Found).To(BeTrue())
+			Expect(answer.Records).To(BeEmpty())
+		})
+	})
+
+	When("a service doesn't exist", func() {
+		It("should not be found", func() {
+			Expect(simulate.New(snapshot).Answer("unknown", namespace1, conditions).Found).To(BeFalse())
+		})
+	})
+
+	When("answers are compared across conditions", func() {
+		It("should return the services whose answers change", func() {
+			simulator := simulate.New(snapshot)
+			before := simulator.Run(conditions)
+			Expect(before).To(HaveLen(2))
+			Expect(before[service2+"."+namespace1].Headless).To(BeTrue())
+			Expect(answerIPs(before[service2+"."+namespace1])).To(Equal([]string{endpointIP}))
+
+			after := simulator.Run(&simulate.Conditions{Disconnected: []string{clusterID3}})
+			Expect(simulate.Changes(before, after)).To(Equal([]string{service1 + "." + namespace1}))
+			Expect(simulate.Changes(before, simulator.Run(conditions))).To(BeEmpty())
+		})
+	})
+})
+
+func newServiceImport(namespace, name, serviceIP, clusterID string) *mcsv1a1.ServiceImport {
+	return &mcsv1a1.ServiceImport{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      name + "-" + namespace + "-" + clusterID,
+			Namespace: namespace,
+			Annotations: map[string]string{
+				"origin-name":      name,
+				"origin-namespace": namespace,
+			},
+			Labels: map[string]string{
+				lhconstants.LabelSourceCluster: clusterID,
+			},
+		},
+		Spec: mcsv1a1.ServiceImportSpec{
+			Type: mcsv1a1.ClusterSetIP,
+			IPs:  []string{serviceIP},
+		},
+		Status: mcsv1a1.ServiceImportStatus{
+			Clusters: []mcsv1a1.ClusterStatus{
+				{
+					Cluster: clusterID,
+				},
+			},
+		},
+	}
+}
+
+func newEndpointSlice(namespace, name, clusterID string, endpointIPs ...string) *discovery.EndpointSlice {
+	return &discovery.EndpointSlice{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      name + "-" + clusterID,
+			Namespace: namespace,
+			Labels: map[string]string{
+				lhconstants.LabelServiceImportName: name,
+				discovery.LabelManagedBy:           lhconstants.LabelValueManagedBy,
+				lhconstants.LabelSourceNamespace:   namespace,
+				lhconstants.LabelSourceCluster:     clusterID,
+				lhconstants.LabelSourceName:        name,
+			},
+		},
+		AddressType: discovery.AddressTypeIPv4,
+		Endpoints: []discovery.Endpoint{
+			{
+				Addresses: endpointIPs,
+			},
+		},
+	}
+}
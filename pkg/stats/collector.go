@@ -0,0 +1,161 @@
+/*
+SPDX-License-Identifier: Apache-2.0
+
+Copyright Contributors to the Submariner project.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package stats
+
+import (
+	"sync"
+	"time"
+
+	"github.com/miekg/dns"
+)
+
+// maxServicesPerNamespace bounds the names tracked per namespace, since queries for non-existent services are counted
+// too.
+const maxServicesPerNamespace = 256
+
+// Failover describes the most recent change of the cluster answered with for a service because the previous cluster
+// became unavailable.
+type Failover struct {
+	Time time.Time
+	From string
+	To   string
+}
+
+// ServiceStats summarizes the resolution of a service since the collector started.
+type ServiceStats struct {
+	Queries      uint64
+	NXDomain     uint64
+	LastFailover *Failover
+}
+
+// Collector accumulates per-service resolution statistics, grouped by namespace. Only the namespaces with imported
+// services are tracked, so that the number of namespaces stays bounded whatever namespaces are queried. A nil Collector
+// ignores all records.
+type Collector struct {
+	mutex sync.Mutex
+	// services returns the names of the services imported in a namespace; if nil, all namespaces are tracked
+	services    func(namespace string) []string
+	namespaces  map[string]map[string]*ServiceStats
+	lastCluster map[serviceKey]string
+}
+
+type serviceKey struct {
+	namespace string
+	name      string
+}
+
+func NewCollector(services func(namespace string) []string) *Collector {
+	return &Collector{
+		services:    services,
+		namespaces:  make(map[string]map[string]*ServiceStats),
+		lastCluster: make(map[serviceKey]string),
+	}
+}
+
+// RecordQuery records a query for the given service and the response code it was answered with.
+func (c *Collector) RecordQuery(namespace, name string, rcode int) {
+	if c == nil {
+		return
+	}
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	s := c.get(namespace, name)
+	if s == nil {
+		return
+	}
+
+	s.Queries++
+
+	if rcode == dns.RcodeNameError {
+		s.NXDomain++
+	}
+}
+
+// RecordAnswer records the cluster a service was answered with. If it differs from the previous answer and the previous
+// cluster is no longer available, according to isAvailable, a failover is recorded.
+func (c *Collector) RecordAnswer(namespace, name, clusterID string, isAvailable func(clusterID string) bool) {
+	if c == nil {
+		return
+	}
+
+	key := serviceKey{namespace: namespace, name: name}
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	previous, ok := c.lastCluster[key]
+	c.lastCluster[key] = clusterID
+
+	if !ok || previous == clusterID || isAvailable(previous) {
+		return
+	}
+
+	if s := c.get(namespace, name); s != nil {
+		s.LastFailover = &Failover{Time: time.Now(), From: previous, To: clusterID}
+	}
+}
+
+// Snapshot returns a copy of the statistics collected so far, keyed by namespace then service name.
+func (c *Collector) Snapshot() map[string]map[string]ServiceStats {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	snapshot := make(map[string]map[string]ServiceStats, len(c.namespaces))
+
+	for namespace, services := range c.namespaces {
+		snapshot[namespace] = make(map[string]ServiceStats, len(services))
+
+		for name, s := range services {
+			copied := *s
+			if s.LastFailover != nil {
+				failover := *s.LastFailover
+				copied.LastFailover = &failover
+			}
+
+			snapshot[namespace][name] = copied
+		}
+	}
+
+	return snapshot
+}
+
+func (c *Collector) get(namespace, name string) *ServiceStats {
+	services, ok := c.namespaces[namespace]
+	if !ok {
+		if c.services != nil && len(c.services(namespace)) == 0 {
+			return nil
+		}
+
+		services = make(map[string]*ServiceStats)
+		c.namespaces[namespace] = services
+	}
+
+	s, ok := services[name]
+	if !ok {
+		if len(services) >= maxServicesPerNamespace {
+			return nil
+		}
+
+		s = &ServiceStats{}
+		services[name] = s
+	}
+
+	return s
+}
@@ -0,0 +1,172 @@
+/*
+SPDX-License-Identifier: Apache-2.0
+
+Copyright Contributors to the Submariner project.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package stats
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"sort"
+	"time"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/klog"
+)
+
+const DefaultInterval = time.Minute
+
+// ResolutionStatsGVR identifies the namespaced ResolutionStats resources the statistics are published to.
+var ResolutionStatsGVR = schema.GroupVersionResource{
+	Group:    "lighthouse.submariner.io",
+	Version:  "v1alpha1",
+	Resource: "resolutionstats",
+}
+
+// Publisher periodically writes the statistics of each namespace to a ResolutionStats resource in that namespace, so
+// that application owners can see how their services resolve without access to the metrics stack. Each DNS server
+// replica publishes its own resource, named after the replica.
+type Publisher struct {
+	collector *Collector
+	client    dynamic.NamespaceableResourceInterface
+	name      string
+	interval  time.Duration
+	stopCh    chan struct{}
+	published map[string]interface{}
+}
+
+func NewPublisher(collector *Collector, client dynamic.Interface, name string, interval time.Duration) *Publisher {
+	return &Publisher{
+		collector: collector,
+		client:    client.Resource(ResolutionStatsGVR),
+		name:      name,
+		interval:  interval,
+		stopCh:    make(chan struct{}),
+		published: make(map[string]interface{}),
+	}
+}
+
+func (p *Publisher) Start() {
+	klog.Infof("Publishing resolution statistics as %q every %v", p.name, p.interval)
+
+	go wait.Until(p.Publish, p.interval, p.stopCh)
+}
+
+func (p *Publisher) Stop() {
+	close(p.stopCh)
+}
+
+// Publish writes the current statistics, skipping namespaces whose statistics haven't changed since the last call.
+func (p *Publisher) Publish() {
+	for namespace, services := range p.collector.Snapshot() {
+		status := toStatus(services)
+		if reflect.DeepEqual(p.published[namespace], status) {
+			continue
+		}
+
+		if err := p.publish(namespace, status); err != nil {
+			klog.Errorf("Error publishing the resolution statistics for namespace %q: %v", namespace, err)
+			continue
+		}
+
+		p.published[namespace] = status
+	}
+}
+
+func (p *Publisher) publish(namespace string, status []interface{}) error {
+	client := p.client.Namespace(namespace)
+
+	obj, err := client.Get(context.TODO(), p.name, metav1.GetOptions{})
+	if apierrors.IsNotFound(err) {
+		obj = &unstructured.Unstructured{}
+		obj.SetAPIVersion(ResolutionStatsGVR.GroupVersion().String())
+		obj.SetKind("ResolutionStats")
+		obj.SetName(p.name)
+		obj.SetNamespace(namespace)
+
+		if err := setStatus(obj, status); err != nil {
+			return err
+		}
+
+		_, err = client.Create(context.TODO(), obj, metav1.CreateOptions{})
+
+		return err
+	}
+
+	if err != nil {
+		return err
+	}
+
+	if err := setStatus(obj, status); err != nil {
+		return err
+	}
+
+	_, err = client.Update(context.TODO(), obj, metav1.UpdateOptions{})
+
+	return err
+}
+
+func setStatus(obj *unstructured.Unstructured, services []interface{}) error {
+	if err := unstructured.SetNestedSlice(obj.Object, services, "status", "services"); err != nil {
+		return err
+	}
+
+	return unstructured.SetNestedField(obj.Object, time.Now().UTC().Format(time.RFC3339), "status", "lastUpdated")
+}
+
+func toStatus(services map[string]ServiceStats) []interface{} {
+	names := make([]string, 0, len(services))
+	for name := range services {
+		names = append(names, name)
+	}
+
+	sort.Strings(names)
+
+	status := make([]interface{}, 0, len(names))
+
+	for _, name := range names {
+		s := services[name]
+
+		entry := map[string]interface{}{
+			"name":         name,
+			"queries":      int64(s.Queries),
+			"nxdomain":     int64(s.NXDomain),
+			"nxdomainRate": fmt.Sprintf("%.3f", float64(s.NXDomain)/float64(s.Queries)),
+		}
+
+		if s.Queries == 0 {
+			entry["nxdomainRate"] = "0.000"
+		}
+
+		if s.LastFailover != nil {
+			entry["lastFailover"] = map[string]interface{}{
+				"time": s.LastFailover.Time.UTC().Format(time.RFC3339),
+				"from": s.LastFailover.From,
+				"to":   s.LastFailover.To,
+			}
+		}
+
+		status = append(status, entry)
+	}
+
+	return status
+}
@@ -0,0 +1,187 @@
+/*
+SPDX-License-Identifier: Apache-2.0
+
+Copyright Contributors to the Submariner project.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package stats
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/miekg/dns"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	fakeClient "k8s.io/client-go/dynamic/fake"
+)
+
+const (
+	namespace1 = "namespace1"
+	namespace2 = "namespace2"
+	service1   = "service1"
+	service2   = "service2"
+	clusterID1 = "cluster1"
+	clusterID2 = "cluster2"
+	replica    = "coredns-1"
+)
+
+var _ = Describe("Collector", func() {
+	var collector *Collector
+
+	BeforeEach(func() {
+		collector = NewCollector(func(namespace string) []string {
+			if namespace == namespace1 || namespace == namespace2 {
+				return []string{service1}
+			}
+
+			return nil
+		})
+	})
+
+	When("queries are recorded", func() {
+		It("should count the queries and NXDOMAIN responses per service and namespace", func() {
+			collector.RecordQuery(namespace1, service1, dns.RcodeSuccess)
+			collector.RecordQuery(namespace1, service1, dns.RcodeNameError)
+			collector.RecordQuery(namespace1, service2, dns.RcodeSuccess)
+			collector.RecordQuery(namespace2, service1, dns.RcodeNameError)
+
+			snapshot := collector.Snapshot()
+			Expect(snapshot[namespace1][service1]).To(Equal(ServiceStats{Queries: 2, NXDomain: 1}))
+			Expect(snapshot[namespace1][service2]).To(Equal(ServiceStats{Queries: 1}))
+			Expect(snapshot[namespace2][service1]).To(Equal(ServiceStats{Queries: 1, NXDomain: 1}))
+		})
+	})
+
+	When("the number of services in a namespace exceeds the limit", func() {
+		It("should not track the additional services", func() {
+			for i := 0; i <= maxServicesPerNamespace; i++ {
+				collector.RecordQuery(namespace1, fmt.Sprintf("service%d", i), dns.RcodeNameError)
+			}
+
+			Expect(collector.Snapshot()[namespace1]).To(HaveLen(maxServicesPerNamespace))
+		})
+	})
+
+	When("namespaces without imported services are queried", func() {
+		It("should not track them", func() {
+			for i := 0; i < 10; i++ {
+				collector.RecordQuery(fmt.Sprintf("unknown%d", i), service1, dns.RcodeNameError)
+			}
+
+			collector.RecordQuery(namespace1, service1, dns.RcodeSuccess)
+
+			snapshot := collector.Snapshot()
+			Expect(snapshot).To(HaveLen(1))
+			Expect(snapshot).To(HaveKey(namespace1))
+		})
+	})
+
+	When("the answered cluster changes", func() {
+		BeforeEach(func() {
+			collector.RecordAnswer(namespace1, service1, clusterID1, nil)
+		})
+
+		It("should record a failover if the previous cluster is unavailable", func() {
+			collector.RecordAnswer(namespace1, service1, clusterID2, func(string) bool {
+				return false
+			})
+
+			failover := collector.Snapshot()[namespace1][service1].LastFailover
+			Expect(failover).ToNot(BeNil())
+			Expect(failover.From).To(Equal(clusterID1))
+			Expect(failover.To).To(Equal(clusterID2))
+		})
+
+		It("should not record a failover if the previous cluster is still available", func() {
+			collector.RecordAnswer(namespace1, service1, clusterID2, func(string) bool {
+				return true
+			})
+
+			Expect(collector.Snapshot()[namespace1][service1].LastFailover).To(BeNil())
+		})
+	})
+
+	When("the collector is nil", func() {
+		It("should ignore records", func() {
+			var nilCollector *Collector
+			nilCollector.RecordQuery(namespace1, service1, dns.RcodeSuccess)
+			nilCollector.RecordAnswer(namespace1, service1, clusterID1, nil)
+		})
+	})
+})
+
+var _ = Describe("Publisher", func() {
+	var (
+		collector *Collector
+		publisher *Publisher
+		client    *fakeClient.FakeDynamicClient
+	)
+
+	BeforeEach(func() {
+		collector = NewCollector(nil)
+		client = fakeClient.NewSimpleDynamicClient(runtime.NewScheme())
+		publisher = NewPublisher(collector, client, replica, DefaultInterval)
+
+		collector.RecordQuery(namespace1, service1, dns.RcodeSuccess)
+		collector.RecordQuery(namespace1, service1, dns.RcodeSuccess)
+		collector.RecordQuery(namespace1, service1, dns.RcodeSuccess)
+		collector.RecordQuery(namespace1, service1, dns.RcodeNameError)
+		collector.RecordAnswer(namespace1, service1, clusterID1, nil)
+		collector.RecordAnswer(namespace1, service1, clusterID2, func(string) bool {
+			return false
+		})
+	})
+
+	getServices := func(namespace string) []interface{} {
+		obj, err := client.Resource(ResolutionStatsGVR).Namespace(namespace).Get(context.TODO(), replica, metav1.GetOptions{})
+		Expect(err).To(Succeed())
+
+		services, _, err := unstructured.NestedSlice(obj.Object, "status", "services")
+		Expect(err).To(Succeed())
+
+		return services
+	}
+
+	It("should create a ResolutionStats resource per namespace", func() {
+		publisher.Publish()
+
+		services := getServices(namespace1)
+		Expect(services).To(HaveLen(1))
+
+		entry := services[0].(map[string]interface{})
+		Expect(entry["name"]).To(Equal(service1))
+		Expect(entry["queries"]).To(Equal(int64(4)))
+		Expect(entry["nxdomain"]).To(Equal(int64(1)))
+		Expect(entry["nxdomainRate"]).To(Equal("0.250"))
+
+		failover := entry["lastFailover"].(map[string]interface{})
+		Expect(failover["from"]).To(Equal(clusterID1))
+		Expect(failover["to"]).To(Equal(clusterID2))
+	})
+
+	It("should update the ResolutionStats resource when the statistics change", func() {
+		publisher.Publish()
+
+		collector.RecordQuery(namespace1, service2, dns.RcodeNameError)
+		publisher.Publish()
+
+		services := getServices(namespace1)
+		Expect(services).To(HaveLen(2))
+		Expect(services[1].(map[string]interface{})["nxdomainRate"]).To(Equal("1.000"))
+	})
+})
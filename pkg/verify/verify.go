@@ -0,0 +1,241 @@
+/*
+SPDX-License-Identifier: Apache-2.0
+
+Copyright Contributors to the Submariner project.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package verify
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"strings"
+	"time"
+
+	"github.com/miekg/dns"
+	lhconstants "github.com/submariner-io/lighthouse/pkg/constants"
+)
+
+// Check identifies a resolution check.
+type Check string
+
+const (
+	// ClusterSetIP checks that a ClusterSetIP service's clusterset name resolves to an address
+	ClusterSetIP Check = "clusterset-ip"
+	// SRV checks that a ClusterSetIP service's clusterset name has SRV records, whose targets are in the clusterset domain
+	SRV Check = "srv"
+	// Headless checks that a headless service's clusterset name resolves to the addresses of its endpoints
+	Headless Check = "headless"
+	// ClusterPrefixed checks that a ClusterSetIP service's name prefixed with the ID of a cluster exporting it resolves to
+	// an address
+	ClusterPrefixed Check = "cluster-prefixed"
+	// NXDomain checks that the clusterset name of a service which doesn't exist gets NXDOMAIN
+	NXDomain Check = "nxdomain"
+)
+
+const (
+	// DefaultTimeout is the default timeout of each query
+	DefaultTimeout = 5 * time.Second
+	// DefaultResolvConf is the resolver configuration the DNS server is read from if none is given
+	DefaultResolvConf = "/etc/resolv.conf"
+)
+
+// Options configures the checks. The checks of the services which aren't given are skipped.
+type Options struct {
+	// Server is the address, as host:port, of the DNS server to query; the first nameserver of DefaultResolvConf if empty
+	Server string
+	// Domain is the clusterset domain; lhconstants.DefaultClustersetDomain if empty
+	Domain string
+	// Namespace holds the services checked
+	Namespace string
+	// Service is an exported ClusterSetIP service, checked by ClusterSetIP, SRV and ClusterPrefixed
+	Service string
+	// HeadlessService is an exported headless service, checked by Headless
+	HeadlessService string
+	// ClusterID is the ID of a cluster exporting Service, checked by ClusterPrefixed
+	ClusterID string
+	// Timeout is the timeout of each query; DefaultTimeout if zero
+	Timeout time.Duration
+}
+
+// Result is the outcome of a check.
+type Result struct {
+	Check Check
+	// Name and Type are the name and type queried
+	Name string
+	Type string
+	// Passed is true if the answer was the expected one
+	Passed bool
+	// Skipped is true if the check wasn't run because its service or cluster wasn't given
+	Skipped bool
+	// Rcode is the response code answered, empty if the query failed
+	Rcode string
+	// Answers lists the records answered
+	Answers []string
+	// Message explains why the check failed or was skipped
+	Message string
+	// Duration is how long the query took
+	Duration time.Duration
+}
+
+// Verifier runs resolution checks against a DNS server, typically from a pod inside a cluster of the clusterset.
+type Verifier struct {
+	options Options
+	client  *dns.Client
+}
+
+// New creates a Verifier, reading the DNS server from DefaultResolvConf if none is given.
+func New(options Options) (*Verifier, error) {
+	if options.Namespace == "" {
+		return nil, fmt.Errorf("the namespace of the services to check is required")
+	}
+
+	if options.Domain == "" {
+		options.Domain = lhconstants.DefaultClustersetDomain
+	}
+
+	if options.Timeout == 0 {
+		options.Timeout = DefaultTimeout
+	}
+
+	if options.Server == "" {
+		config, err := dns.ClientConfigFromFile(DefaultResolvConf)
+		if err != nil {
+			return nil, fmt.Errorf("error reading the DNS server from %q: %v", DefaultResolvConf, err)
+		}
+
+		if len(config.Servers) == 0 {
+			return nil, fmt.Errorf("no nameserver is configured in %q", DefaultResolvConf)
+		}
+
+		options.Server = net.JoinHostPort(config.Servers[0], config.Port)
+	}
+
+	return &Verifier{
+		options: options,
+		client:  &dns.Client{Timeout: options.Timeout},
+	}, nil
+}
+
+// Run runs all the checks and returns their results, in the order of the Check constants.
+func (v *Verifier) Run(ctx context.Context) []Result {
+	return []Result{
+		v.run(ctx, ClusterSetIP, v.options.Service, dns.TypeA, v.expectAddresses),
+		v.run(ctx, SRV, v.options.Service, dns.TypeSRV, v.expectSRV),
+		v.run(ctx, Headless, v.options.HeadlessService, dns.TypeA, v.expectAddresses),
+		v.runClusterPrefixed(ctx),
+		v.run(ctx, NXDomain, fmt.Sprintf("lighthouse-verify-%d", time.Now().UnixNano()), dns.TypeA, v.expectNXDomain),
+	}
+}
+
+func (v *Verifier) runClusterPrefixed(ctx context.Context) Result {
+	if v.options.Service != "" && v.options.ClusterID == "" {
+		return Result{Check: ClusterPrefixed, Type: dns.TypeToString[dns.TypeA], Skipped: true, Message: "no cluster ID given"}
+	}
+
+	service := v.options.Service
+	if service != "" {
+		service = v.options.ClusterID + "." + service
+	}
+
+	return v.run(ctx, ClusterPrefixed, service, dns.TypeA, v.expectAddresses)
+}
+
+// run queries the given service's clusterset name for the given type, and checks the response with the given function,
+// which returns why the response isn't the expected one.
+func (v *Verifier) run(ctx context.Context, check Check, service string, qtype uint16, expect func(*dns.Msg) string) Result {
+	result := Result{Check: check, Type: dns.TypeToString[qtype]}
+
+	if service == "" {
+		result.Skipped = true
+		result.Message = "no service given"
+
+		return result
+	}
+
+	result.Name = dns.Fqdn(service + "." + v.options.Namespace + ".svc." + v.options.Domain)
+
+	m := new(dns.Msg)
+	m.SetQuestion(result.Name, qtype)
+
+	r, rtt, err := v.client.ExchangeContext(ctx, m, v.options.Server)
+	result.Duration = rtt
+
+	if err != nil {
+		result.Message = fmt.Sprintf("error querying %s: %v", v.options.Server, err)
+		return result
+	}
+
+	result.Rcode = dns.RcodeToString[r.Rcode]
+
+	for _, rr := range r.Answer {
+		result.Answers = append(result.Answers, rr.String())
+	}
+
+	result.Message = expect(r)
+	result.Passed = result.Message == ""
+
+	return result
+}
+
+func (v *Verifier) expectAddresses(r *dns.Msg) string {
+	if r.Rcode != dns.RcodeSuccess {
+		return fmt.Sprintf("expected NOERROR, got %s", dns.RcodeToString[r.Rcode])
+	}
+
+	for _, rr := range r.Answer {
+		if rr.Header().Rrtype == dns.TypeA || rr.Header().Rrtype == dns.TypeAAAA {
+			return ""
+		}
+	}
+
+	return "no address was answered"
+}
+
+func (v *Verifier) expectSRV(r *dns.Msg) string {
+	if r.Rcode != dns.RcodeSuccess {
+		return fmt.Sprintf("expected NOERROR, got %s", dns.RcodeToString[r.Rcode])
+	}
+
+	found := false
+	suffix := "." + dns.Fqdn(v.options.Domain)
+
+	for _, rr := range r.Answer {
+		srv, ok := rr.(*dns.SRV)
+		if !ok {
+			continue
+		}
+
+		if !strings.HasSuffix(strings.ToLower(srv.Target), strings.ToLower(suffix)) {
+			return fmt.Sprintf("the target %q isn't in the clusterset domain", srv.Target)
+		}
+
+		found = true
+	}
+
+	if !found {
+		return "no SRV record was answered"
+	}
+
+	return ""
+}
+
+func (v *Verifier) expectNXDomain(r *dns.Msg) string {
+	if r.Rcode != dns.RcodeNameError {
+		return fmt.Sprintf("expected NXDOMAIN, got %s", dns.RcodeToString[r.Rcode])
+	}
+
+	return ""
+}
@@ -0,0 +1,222 @@
+/*
+SPDX-License-Identifier: Apache-2.0
+
+Copyright Contributors to the Submariner project.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package verify_test
+
+import (
+	"context"
+	"net"
+	"time"
+
+	"github.com/coredns/coredns/plugin"
+	"github.com/miekg/dns"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	lhconstants "github.com/submariner-io/lighthouse/pkg/constants"
+	"github.com/submariner-io/lighthouse/pkg/verify"
+	"github.com/submariner-io/lighthouse/plugin/lighthouse"
+	corev1 "k8s.io/api/core/v1"
+	discovery "k8s.io/api/discovery/v1beta1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	mcsv1a1 "sigs.k8s.io/mcs-api/pkg/apis/v1alpha1"
+)
+
+const (
+	namespace = "test-ns"
+	clusterID = "cluster1"
+)
+
+var _ = Describe("Verifier", func() {
+	var (
+		server  *dns.Server
+		options verify.Options
+		results []verify.Result
+	)
+
+	BeforeEach(func() {
+		handler := lighthouse.NewStatic([]string{"clusterset.local."}, []*mcsv1a1.ServiceImport{
+			newServiceImport("nginx", mcsv1a1.ClusterSetIP, "10.253.1.1"),
+			newServiceImport("db", mcsv1a1.Headless, ""),
+		}, []*discovery.EndpointSlice{newEndpointSlice("db", "100.96.1.1")})
+
+		conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+		Expect(err).To(Succeed())
+
+		server = &dns.Server{PacketConn: conn, Handler: dns.HandlerFunc(func(w dns.ResponseWriter, r *dns.Msg) {
+			rcode, _ := handler.ServeDNS(context.TODO(), w, r)
+			if !plugin.ClientWrite(rcode) {
+				m := new(dns.Msg)
+				m.SetRcode(r, rcode)
+				_ = w.WriteMsg(m)
+			}
+		})}
+
+		started := make(chan struct{})
+		server.NotifyStartedFunc = func() {
+			close(started)
+		}
+
+		go func() {
+			_ = server.ActivateAndServe()
+		}()
+
+		Eventually(started).Should(BeClosed())
+
+		options = verify.Options{
+			Server:          conn.LocalAddr().String(),
+			Namespace:       namespace,
+			Service:         "nginx",
+			HeadlessService: "db",
+			ClusterID:       clusterID,
+			Timeout:         time.Second,
+		}
+	})
+
+	JustBeforeEach(func() {
+		verifier, err := verify.New(options)
+		Expect(err).To(Succeed())
+
+		results = verifier.Run(context.TODO())
+	})
+
+	AfterEach(func() {
+		Expect(server.Shutdown()).To(Succeed())
+	})
+
+	When("the services resolve", func() {
+		It("should pass every check", func() {
+			Expect(results).To(HaveLen(5))
+
+			for i := range results {
+				Expect(results[i].Passed).To(BeTrue(), "%s: %s", results[i].Check, results[i].Message)
+				Expect(results[i].Skipped).To(BeFalse())
+			}
+
+			Expect(results[0].Check).To(Equal(verify.ClusterSetIP))
+			Expect(results[0].Name).To(Equal("nginx." + namespace + ".svc.clusterset.local."))
+			Expect(results[0].Answers).To(HaveLen(1))
+			Expect(results[3].Name).To(Equal(clusterID + ".nginx." + namespace + ".svc.clusterset.local."))
+			Expect(results[4].Rcode).To(Equal("NXDOMAIN"))
+		})
+	})
+
+	When("the services don't exist", func() {
+		BeforeEach(func() {
+			options.Service = "missing"
+			options.HeadlessService = "missing-headless"
+		})
+
+		It("should fail their checks with the response code", func() {
+			for _, result := range results[:4] {
+				Expect(result.Passed).To(BeFalse(), string(result.Check))
+				Expect(result.Rcode).To(Equal("NXDOMAIN"))
+				Expect(result.Message).To(ContainSubstring("expected NOERROR"))
+			}
+
+			Expect(results[4].Passed).To(BeTrue())
+		})
+	})
+
+	When("the headless service and cluster ID aren't given", func() {
+		BeforeEach(func() {
+			options.HeadlessService = ""
+			options.ClusterID = ""
+		})
+
+		It("should skip their checks", func() {
+			Expect(results[2].Skipped).To(BeTrue())
+			Expect(results[3].Skipped).To(BeTrue())
+			Expect(results[0].Passed).To(BeTrue())
+		})
+	})
+
+	When("the DNS server doesn't answer", func() {
+		BeforeEach(func() {
+			options.Server = "127.0.0.1:1"
+			options.Timeout = 100 * time.Millisecond
+		})
+
+		It("should fail every check with the error", func() {
+			for _, result := range results {
+				if result.Skipped {
+					continue
+				}
+
+				Expect(result.Passed).To(BeFalse())
+				Expect(result.Message).To(ContainSubstring("error querying"))
+			}
+		})
+	})
+
+	When("no namespace is given", func() {
+		It("should return an error", func() {
+			_, err := verify.New(verify.Options{Server: "127.0.0.1:53"})
+			Expect(err).To(HaveOccurred())
+		})
+	})
+})
+
+func newServiceImport(name string, siType mcsv1a1.ServiceImportType, ip string) *mcsv1a1.ServiceImport {
+	si := &mcsv1a1.ServiceImport{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      name + "-" + namespace + "-" + clusterID,
+			Namespace: "submariner-operator",
+			Annotations: map[string]string{
+				lhconstants.OriginName:      name,
+				lhconstants.OriginNamespace: namespace,
+			},
+			Labels: map[string]string{lhconstants.LabelSourceCluster: clusterID},
+		},
+		Spec: mcsv1a1.ServiceImportSpec{
+			Type:  siType,
+			Ports: []mcsv1a1.ServicePort{{Name: "http", Protocol: corev1.ProtocolTCP, Port: 80}},
+		},
+		Status: mcsv1a1.ServiceImportStatus{Clusters: []mcsv1a1.ClusterStatus{{Cluster: clusterID}}},
+	}
+
+	if ip != "" {
+		si.Spec.IPs = []string{ip}
+	}
+
+	return si
+}
+
+func newEndpointSlice(name, ip string) *discovery.EndpointSlice {
+	portName, protocol, port := "http", corev1.ProtocolTCP, int32(80)
+	hostname := "pod-0"
+	ready := true
+
+	return &discovery.EndpointSlice{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      name + "-" + clusterID,
+			Namespace: namespace,
+			Labels: map[string]string{
+				discovery.LabelManagedBy:         lhconstants.LabelValueManagedBy,
+				lhconstants.LabelSourceNamespace: namespace,
+				lhconstants.LabelSourceCluster:   clusterID,
+				lhconstants.LabelSourceName:      name,
+			},
+		},
+		AddressType: discovery.AddressTypeIPv4,
+		Endpoints: []discovery.Endpoint{{
+			Addresses:  []string{ip},
+			Hostname:   &hostname,
+			Conditions: discovery.EndpointConditions{Ready: &ready},
+		}},
+		Ports: []discovery.EndpointPort{{Name: &portName, Protocol: &protocol, Port: &port}},
+	}
+}
@@ -0,0 +1,75 @@
+/*
+SPDX-License-Identifier: Apache-2.0
+
+Copyright Contributors to the Submariner project.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package lighthouse
+
+import (
+	"strings"
+
+	"github.com/coredns/coredns/request"
+	"github.com/miekg/dns"
+)
+
+// clusterID returns the ID of the cluster named in a query: the cluster the name is an alias of, if it's one, otherwise
+// the name itself. Aliases loaded from the configuration directory take precedence over those in the Corefile.
+func (lh *Lighthouse) clusterID(name string) string {
+	if config := lh.currentConfig(); config != nil {
+		if id, ok := config.clusterAliases[name]; ok {
+			return id
+		}
+	}
+
+	if id, ok := lh.clusterAliases[name]; ok {
+		return id
+	}
+
+	return name
+}
+
+const (
+	recordsAliasMode = "records"
+	cnameAliasMode   = "cname"
+)
+
+// resolveAlias rewrites a query for an alias of a service, set with the lighthouse.submariner.io/aliases annotation, into
+// a query for the service. In cname mode, the CNAME record from the queried name to the service's name is also set, to
+// precede the service's records in the answer; otherwise the service's records are answered with the queried name.
+func (lh *Lighthouse) resolveAlias(state request.Request, pReq recordRequest) recordRequest {
+	canonical, ok := lh.serviceImports.Canonical(pReq.namespace, pReq.service)
+	if !ok {
+		return pReq
+	}
+
+	log.Debugf("%q is an alias of service %q in namespace %q", state.QName(), canonical, pReq.namespace)
+
+	pReq.service = canonical
+
+	if lh.aliasMode != cnameAliasMode {
+		return pReq
+	}
+
+	// The service's label is followed by the namespace and "svc" labels, then the zone
+	labels := dns.SplitDomainName(state.QName())
+	labels[len(labels)-dns.CountLabel(state.Zone)-3] = canonical
+
+	pReq.cname = &dns.CNAME{
+		Hdr:    dns.RR_Header{Name: state.QName(), Rrtype: dns.TypeCNAME, Class: state.QClass(), Ttl: lh.getTTL()},
+		Target: dns.Fqdn(strings.Join(labels, ".")),
+	}
+
+	return pReq
+}
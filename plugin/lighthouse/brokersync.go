@@ -0,0 +1,61 @@
+/*
+SPDX-License-Identifier: Apache-2.0
+
+Copyright Contributors to the Submariner project.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package lighthouse
+
+import (
+	"time"
+)
+
+const defaultOutOfSyncTTL = uint32(30)
+
+// brokerOutOfSync widens the TTL of the answers while the agent reports it's out of sync with the broker: the records
+// can't be refreshed from the clusterset meanwhile, so clients needn't query for them as often.
+type brokerOutOfSync struct {
+	ttl uint32
+	// outOfSyncSince reports whether the agent is out of sync with the broker, and since when
+	outOfSyncSince func() (time.Time, bool)
+}
+
+// outOfSyncTTL returns the TTL to answer with, and true, if the agent is out of sync with the broker.
+func (lh *Lighthouse) outOfSyncTTL() (uint32, bool) {
+	if lh.brokerOutOfSync == nil {
+		return 0, false
+	}
+
+	_, outOfSync := lh.brokerOutOfSync.outOfSyncSince()
+
+	return lh.brokerOutOfSync.ttl, outOfSync
+}
+
+// widenedTTL returns the minimum TTL to answer with, and true, if the maps are stale or the agent is out of sync with
+// the broker.
+func (lh *Lighthouse) widenedTTL() (uint32, bool) {
+	var ttl uint32
+
+	widened := false
+
+	if staleTTL, stale := lh.staleTTL(); stale {
+		ttl, widened = staleTTL, true
+	}
+
+	if syncTTL, outOfSync := lh.outOfSyncTTL(); outOfSync && syncTTL > ttl {
+		ttl, widened = syncTTL, true
+	}
+
+	return ttl, widened
+}
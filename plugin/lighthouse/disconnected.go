@@ -0,0 +1,49 @@
+/*
+SPDX-License-Identifier: Apache-2.0
+
+Copyright Contributors to the Submariner project.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package lighthouse
+
+import (
+	"github.com/miekg/dns"
+)
+
+// The policies for queries which only disconnected clusters could answer.
+const (
+	// dropDisconnected leaves the disconnected clusters out, so that such queries are answered with NODATA
+	dropDisconnected = "drop"
+	// serveDisconnected answers with the records of the disconnected clusters, with a low TTL
+	serveDisconnected = "serve-anyway"
+	// failDisconnected answers with SERVFAIL
+	failDisconnected = "servfail"
+)
+
+const defaultDisconnectedTTL = uint32(1)
+
+// withTTL returns copies of the given records with their TTL lowered to at most ttl. The records themselves are shared
+// with other responses and cached, so they mustn't be changed.
+func withTTL(records []dns.RR, ttl uint32) []dns.RR {
+	result := make([]dns.RR, len(records))
+
+	for i, rr := range records {
+		result[i] = dns.Copy(rr)
+		if result[i].Header().Ttl > ttl {
+			result[i].Header().Ttl = ttl
+		}
+	}
+
+	return result
+}
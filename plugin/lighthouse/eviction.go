@@ -0,0 +1,133 @@
+/*
+SPDX-License-Identifier: Apache-2.0
+
+Copyright Contributors to the Submariner project.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package lighthouse
+
+import (
+	"sync/atomic"
+
+	"github.com/coredns/coredns/plugin/pkg/dnsutil"
+	lhconstants "github.com/submariner-io/lighthouse/pkg/constants"
+	"github.com/submariner-io/lighthouse/pkg/endpointslice"
+	"github.com/submariner-io/lighthouse/pkg/resolver"
+	"github.com/submariner-io/lighthouse/pkg/serviceimport"
+	discovery "k8s.io/api/discovery/v1beta1"
+	mcsv1a1 "sigs.k8s.io/mcs-api/pkg/apis/v1alpha1"
+)
+
+// CacheEvictor is implemented by caching plugins which can drop cached answers on request. Lighthouse uses it so that
+// answers it no longer gives, e.g. the IP of a cluster a service failed over from, aren't served from the cache for
+// the remainder of their TTL.
+type CacheEvictor interface {
+	// Evict drops the cached answers for the given name and all the names below it.
+	Evict(name string)
+}
+
+// evictionHints emits eviction hints for a service's names when the records it's answered with may have changed.
+// It does nothing until both the zones and an evictor are set.
+type evictionHints struct {
+	zones   atomic.Value // []string
+	evictor atomic.Value // evictorHolder
+}
+
+// evictorHolder gives the values stored in evictionHints.evictor a consistent type, as atomic.Value requires.
+type evictorHolder struct {
+	CacheEvictor
+}
+
+func (e *evictionHints) setZones(zones []string) {
+	e.zones.Store(zones)
+}
+
+func (e *evictionHints) setEvictor(evictor CacheEvictor) {
+	e.evictor.Store(evictorHolder{evictor})
+}
+
+func (e *evictionHints) serviceChanged(name, namespace string) {
+	holder, _ := e.evictor.Load().(evictorHolder)
+	zones, _ := e.zones.Load().([]string)
+
+	if holder.CacheEvictor == nil || name == "" || namespace == "" {
+		return
+	}
+
+	for _, zone := range zones {
+		holder.Evict(dnsutil.Join(name, namespace, Svc, zone))
+	}
+}
+
+// serviceImportStore updates a ServiceImport store, then emits eviction hints for the service, drops the cached misses
+// in its namespace, updates the per-namespace metrics, prefetches the service's hot names and notifies its watchers.
+type serviceImportStore struct {
+	serviceimport.Store
+	hints    *evictionHints
+	negative *negativeCache
+	metrics  *namespaceMetrics
+	serial   *zoneSerial
+	prefetch *prefetcher
+	changes  *resolver.Changes
+}
+
+func (s *serviceImportStore) Put(serviceImport *mcsv1a1.ServiceImport) {
+	s.Store.Put(serviceImport)
+	s.changed(serviceImport)
+}
+
+func (s *serviceImportStore) Remove(serviceImport *mcsv1a1.ServiceImport) {
+	s.Store.Remove(serviceImport)
+	s.changed(serviceImport)
+}
+
+func (s *serviceImportStore) changed(serviceImport *mcsv1a1.ServiceImport) {
+	namespace := serviceimport.ImportNamespace(serviceImport)
+	s.hints.serviceChanged(serviceImport.Annotations[lhconstants.OriginName], namespace)
+	s.negative.invalidate(namespace)
+	s.metrics.serviceChanged(namespace)
+	s.serial.bump()
+	s.prefetch.serviceChanged(serviceImport.Annotations[lhconstants.OriginName], namespace)
+	s.changes.ServiceChanged(serviceImport.Annotations[lhconstants.OriginName], namespace)
+}
+
+// endpointSliceStore updates an EndpointSlice store, then emits eviction hints for the service, drops the cached
+// misses in its namespace, prefetches the service's hot names and notifies its watchers.
+type endpointSliceStore struct {
+	endpointslice.Store
+	hints    *evictionHints
+	negative *negativeCache
+	serial   *zoneSerial
+	prefetch *prefetcher
+	changes  *resolver.Changes
+}
+
+func (s *endpointSliceStore) Put(endpointSlice *discovery.EndpointSlice) {
+	s.Store.Put(endpointSlice)
+	s.changed(endpointSlice)
+}
+
+func (s *endpointSliceStore) Remove(endpointSlice *discovery.EndpointSlice) {
+	s.Store.Remove(endpointSlice)
+	s.changed(endpointSlice)
+}
+
+func (s *endpointSliceStore) changed(endpointSlice *discovery.EndpointSlice) {
+	namespace := endpointslice.ImportNamespace(endpointSlice)
+	s.hints.serviceChanged(endpointSlice.Labels[lhconstants.LabelSourceName], namespace)
+	s.negative.invalidate(namespace)
+	s.serial.bump()
+	s.prefetch.serviceChanged(endpointSlice.Labels[lhconstants.LabelSourceName], namespace)
+	s.changes.ServiceChanged(endpointSlice.Labels[lhconstants.LabelSourceName], namespace)
+}
@@ -0,0 +1,105 @@
+/*
+SPDX-License-Identifier: Apache-2.0
+
+Copyright Contributors to the Submariner project.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package lighthouse
+
+import (
+	"sync"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/submariner-io/lighthouse/pkg/endpointslice"
+	"github.com/submariner-io/lighthouse/pkg/serviceimport"
+	mcsv1a1 "sigs.k8s.io/mcs-api/pkg/apis/v1alpha1"
+)
+
+var _ = Describe("Cache eviction hints", func() {
+	var (
+		hints   *evictionHints
+		evictor *fakeEvictor
+		siStore *serviceImportStore
+		esStore *endpointSliceStore
+	)
+
+	const serviceName = service1 + "." + namespace1 + ".svc.clusterset.local."
+
+	BeforeEach(func() {
+		hints = &evictionHints{}
+		hints.setZones([]string{"clusterset.local."})
+		evictor = &fakeEvictor{}
+		siStore = &serviceImportStore{Store: serviceimport.NewMap(), hints: hints}
+		esStore = &endpointSliceStore{Store: endpointslice.NewMap(), hints: hints}
+	})
+
+	When("an evictor is set", func() {
+		BeforeEach(func() {
+			hints.setEvictor(evictor)
+		})
+
+		It("should evict the service's name when its ServiceImports change", func() {
+			si := newServiceImport(namespace1, service1, clusterID, serviceIP, portName1, portNumber1, protocol1, mcsv1a1.ClusterSetIP)
+
+			siStore.Put(si)
+			Expect(evictor.evicted()).To(Equal([]string{serviceName}))
+
+			siStore.Remove(si)
+			Expect(evictor.evicted()).To(Equal([]string{serviceName, serviceName}))
+		})
+
+		It("should evict the service's name when its EndpointSlices change", func() {
+			es := newEndpointSlice(namespace1, service1, clusterID, portName1, []string{hostName1}, []string{endpointIP}, portNumber1,
+				protocol1)
+
+			esStore.Put(es)
+			esStore.Remove(es)
+			Expect(evictor.evicted()).To(Equal([]string{serviceName, serviceName}))
+		})
+	})
+
+	When("no evictor is set", func() {
+		It("should still update the store", func() {
+			siMap := serviceimport.NewMap()
+			siStore.Store = siMap
+			siStore.Put(newServiceImport(namespace1, service1, clusterID, serviceIP, portName1, portNumber1, protocol1,
+				mcsv1a1.ClusterSetIP))
+
+			_, found := siMap.GetAllRecords(namespace1, service1, func(string) bool { return true },
+				func(string, string, string) bool { return true })
+			Expect(found).To(BeTrue())
+			Expect(evictor.evicted()).To(BeEmpty())
+		})
+	})
+})
+
+type fakeEvictor struct {
+	sync.Mutex
+	names []string
+}
+
+func (f *fakeEvictor) Evict(name string) {
+	f.Lock()
+	defer f.Unlock()
+
+	f.names = append(f.names, name)
+}
+
+func (f *fakeEvictor) evicted() []string {
+	f.Lock()
+	defer f.Unlock()
+
+	return f.names
+}
@@ -0,0 +1,90 @@
+/*
+SPDX-License-Identifier: Apache-2.0
+
+Copyright Contributors to the Submariner project.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package lighthouse
+
+import (
+	"strconv"
+	"sync"
+
+	"github.com/coredns/coredns/request"
+	"github.com/miekg/dns"
+	"github.com/submariner-io/lighthouse/pkg/serviceimport"
+)
+
+// answerSet is the outcome of resolving a query: the records of the service it names and the resource records
+// assembled from them.
+type answerSet struct {
+	dnsRecords []serviceimport.DNSRecord
+	isHeadless bool
+	found      bool
+	records    []dns.RR
+	extras     []dns.RR
+	// disconnected is set if the records are those of disconnected clusters
+	disconnected bool
+}
+
+// flightGroup lets concurrent identical queries share the computation of their answer, so that bursts of the same
+// query don't each assemble it.
+type flightGroup struct {
+	mutex   sync.Mutex
+	flights map[string]*flight
+}
+
+type flight struct {
+	done    sync.WaitGroup
+	answers *answerSet
+}
+
+// do returns the answers computed by fn for the given key, or those of the computation in progress for the same key
+// if there is one, in which case it also returns true.
+func (g *flightGroup) do(key string, fn func() *answerSet) (*answerSet, bool) {
+	g.mutex.Lock()
+
+	if f, ok := g.flights[key]; ok {
+		g.mutex.Unlock()
+		f.done.Wait()
+
+		return f.answers, true
+	}
+
+	if g.flights == nil {
+		g.flights = map[string]*flight{}
+	}
+
+	f := &flight{}
+	f.done.Add(1)
+	g.flights[key] = f
+	g.mutex.Unlock()
+
+	defer func() {
+		g.mutex.Lock()
+		delete(g.flights, key)
+		g.mutex.Unlock()
+		f.done.Done()
+	}()
+
+	f.answers = fn()
+
+	return f.answers, false
+}
+
+// flightKey identifies the queries whose answers are identical: the name, with its case as the answers echo it, the
+// type and, when clusters are selected by hashing the client, the client.
+func flightKey(state request.Request, pReq recordRequest) string {
+	return state.QName() + "/" + strconv.Itoa(int(state.QType())) + "/" + pReq.client
+}
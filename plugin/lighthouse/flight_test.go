@@ -0,0 +1,93 @@
+/*
+SPDX-License-Identifier: Apache-2.0
+
+Copyright Contributors to the Submariner project.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package lighthouse
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Query deduplication", func() {
+	var group *flightGroup
+
+	BeforeEach(func() {
+		group = &flightGroup{}
+	})
+
+	When("identical queries are concurrent", func() {
+		It("should compute their answers once and share them", func() {
+			computing := make(chan struct{})
+			release := make(chan struct{})
+			computed := &answerSet{found: true}
+			calls := 0
+
+			type result struct {
+				answers *answerSet
+				shared  bool
+			}
+
+			first := make(chan result)
+
+			go func() {
+				answers, shared := group.do("key", func() *answerSet {
+					calls++
+					close(computing)
+					<-release
+
+					return computed
+				})
+				first <- result{answers, shared}
+			}()
+
+			<-computing
+
+			second := make(chan result)
+
+			go func() {
+				answers, shared := group.do("key", func() *answerSet {
+					calls++
+					return &answerSet{}
+				})
+				second <- result{answers, shared}
+			}()
+
+			Consistently(second).ShouldNot(Receive())
+			close(release)
+
+			Expect(<-first).To(Equal(result{computed, false}))
+			Expect(<-second).To(Equal(result{computed, true}))
+			Expect(calls).To(Equal(1))
+		})
+	})
+
+	When("identical queries are consecutive", func() {
+		It("should compute their answers each time", func() {
+			answers, shared := group.do("key", func() *answerSet {
+				return &answerSet{found: true}
+			})
+			Expect(shared).To(BeFalse())
+			Expect(answers.found).To(BeTrue())
+
+			answers, shared = group.do("key", func() *answerSet {
+				return &answerSet{}
+			})
+			Expect(shared).To(BeFalse())
+			Expect(answers.found).To(BeFalse())
+		})
+	})
+})
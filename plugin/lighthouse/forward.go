@@ -0,0 +1,89 @@
+/*
+SPDX-License-Identifier: Apache-2.0
+
+Copyright Contributors to the Submariner project.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package lighthouse
+
+import (
+	"context"
+
+	"github.com/coredns/coredns/request"
+	"github.com/miekg/dns"
+	"github.com/submariner-io/lighthouse/pkg/dnstls"
+)
+
+// forwarderFor returns the forwarder for the most specific zone holding the given name, if any.
+func (lh *Lighthouse) forwarderFor(qname string) *dnstls.Forwarder {
+	var found *dnstls.Forwarder
+
+	for _, f := range lh.forwarders {
+		if dns.IsSubDomain(f.Zone, qname) && (found == nil || len(f.Zone) > len(found.Zone)) {
+			found = f
+		}
+	}
+
+	return found
+}
+
+func (lh *Lighthouse) forward(ctx context.Context, f *dnstls.Forwarder, state request.Request) (int, error) {
+	resp, err := f.Forward(ctx, state.Req)
+	if err != nil {
+		log.Errorf("Failed to forward the query for %q: %v", state.QName(), err)
+		return dns.RcodeServerFailure, lh.error("failed to forward query")
+	}
+
+	wErr := state.W.WriteMsg(resp)
+	if wErr != nil {
+		log.Errorf("Failed to write message %#v: %v", resp, wErr)
+		return dns.RcodeServerFailure, lh.error("failed to write response")
+	}
+
+	return dns.RcodeSuccess, nil
+}
+
+// dnsHandler serves the queries received by the plugin's own DNS over TLS listener, writing a response with the
+// returned code if the plugin didn't write one, as CoreDNS does for the queries it receives.
+func (lh *Lighthouse) dnsHandler() dns.Handler {
+	return dns.HandlerFunc(func(w dns.ResponseWriter, r *dns.Msg) {
+		tracker := &writeTracker{ResponseWriter: w}
+
+		rcode, err := lh.ServeDNS(context.TODO(), tracker, r)
+		if err != nil {
+			log.Debugf("Error serving the query received over TLS: %v", err)
+		}
+
+		if tracker.written {
+			return
+		}
+
+		m := new(dns.Msg)
+		m.SetRcode(r, rcode)
+
+		if wErr := w.WriteMsg(m); wErr != nil {
+			log.Errorf("Failed to write message %#v: %v", m, wErr)
+		}
+	})
+}
+
+type writeTracker struct {
+	dns.ResponseWriter
+	written bool
+}
+
+func (w *writeTracker) WriteMsg(m *dns.Msg) error {
+	w.written = true
+	return w.ResponseWriter.WriteMsg(m)
+}
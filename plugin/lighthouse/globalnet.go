@@ -0,0 +1,68 @@
+/*
+SPDX-License-Identifier: Apache-2.0
+
+Copyright Contributors to the Submariner project.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package lighthouse
+
+import (
+	"sync"
+
+	"github.com/submariner-io/lighthouse/pkg/endpointslice"
+	"github.com/submariner-io/lighthouse/pkg/fairqueue"
+)
+
+// globalIPRefresher puts a namespace's EndpointSlices again when the global IPs of its pods change, so that their
+// endpoints are answered with the new IPs. Refreshes go through the import queue, after the changes already queued
+// for the namespace, and a namespace is only queued once until it's refreshed, since Globalnet typically allocates
+// the global IPs of many pods at once.
+type globalIPRefresher struct {
+	endpointSlices *endpointslice.Map
+	store          endpointslice.Store
+	queue          *fairqueue.Queue
+	mutex          sync.Mutex
+	pending        map[string]bool
+}
+
+func newGlobalIPRefresher(endpointSlices *endpointslice.Map, store endpointslice.Store,
+	queue *fairqueue.Queue) *globalIPRefresher {
+	return &globalIPRefresher{
+		endpointSlices: endpointSlices,
+		store:          store,
+		queue:          queue,
+		pending:        make(map[string]bool),
+	}
+}
+
+func (r *globalIPRefresher) refresh(namespace string) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	if r.pending[namespace] {
+		return
+	}
+
+	r.pending[namespace] = true
+
+	r.queue.Add(namespace, func() {
+		r.mutex.Lock()
+		delete(r.pending, namespace)
+		r.mutex.Unlock()
+
+		for _, endpointSlice := range r.endpointSlices.EndpointSlices(namespace) {
+			r.store.Put(endpointSlice)
+		}
+	})
+}
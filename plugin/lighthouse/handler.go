@@ -20,22 +20,39 @@ package lighthouse
 import (
 	"context"
 	"errors"
-	"fmt"
+	"math/rand"
+	"net"
+	"strings"
+	"time"
 
 	"github.com/coredns/coredns/plugin"
+	"github.com/coredns/coredns/plugin/pkg/edns"
 	"github.com/coredns/coredns/request"
 	"github.com/miekg/dns"
-	"github.com/submariner-io/lighthouse/pkg/serviceimport"
 )
 
 const PluginName = "lighthouse"
 
 // ServeDNS implements the plugin.Handler interface.
 func (lh *Lighthouse) ServeDNS(ctx context.Context, w dns.ResponseWriter, r *dns.Msg) (int, error) {
+	entry := lh.queryLog.sample()
+	if entry == nil {
+		return lh.serveDNS(ctx, w, r, nil)
+	}
+
+	start := time.Now()
+	rcode, err := lh.serveDNS(ctx, w, r, entry)
+	lh.queryLog.write(entry, request.Request{W: w, Req: r}, rcode, start)
+
+	return rcode, err
+}
+
+func (lh *Lighthouse) serveDNS(ctx context.Context, w dns.ResponseWriter, r *dns.Msg, entry *queryLogEntry) (int, error) {
 	state := request.Request{W: w, Req: r}
 	qname := state.QName()
 
 	log.Debugf("Request received for %q", qname)
+	traceQuery(ctx, qname, state.QType())
 
 	// qname: mysvc.default.svc.example.org.
 	// zone:  example.org.
@@ -46,80 +63,248 @@ func (lh *Lighthouse) ServeDNS(ctx context.Context, w dns.ResponseWriter, r *dns
 		return lh.nextOrFailure(state.Name(), ctx, w, r, dns.RcodeNotZone, "No matching zone found")
 	}
 
-	if state.QType() != dns.TypeA && state.QType() != dns.TypeAAAA && state.QType() != dns.TypeSRV {
-		msg := fmt.Sprintf("Query of type %d is not supported", state.QType())
-		log.Debugf(msg)
+	// Updates aren't queries, whatever their zone section holds
+	if r.Opcode == dns.OpcodeUpdate {
+		return lh.refuseUpdate(state)
+	}
+
+	if allowed, limit := lh.limiter.allow(state.IP()); !allowed {
+		log.Debugf("Refusing the query for %q from %s, which exceeds the %s rate limit", qname, state.IP(), limit)
+		rateLimitedCount.WithLabelValues(limit).Inc()
+
+		return dns.RcodeRefused, lh.error("rate limit exceeded")
+	}
+
+	// The server normally rejects unsupported EDNS versions before any plugin runs; check anyway so that every path
+	// answers them with BADVERS and an OPT record, as RFC 6891 requires
+	if badVers, err := edns.Version(r); err != nil {
+		log.Debugf("Unsupported EDNS version in request for %q", qname)
+
+		if wErr := w.WriteMsg(badVers); wErr != nil {
+			log.Errorf("Failed to write message %#v: %v", badVers, wErr)
+			return dns.RcodeServerFailure, lh.error("failed to write response")
+		}
+
+		return dns.RcodeBadVers, nil
+	}
+
+	if f := lh.forwarderFor(qname); f != nil {
+		log.Debugf("Forwarding the query for %q to the resolver for %q", qname, f.Zone)
+		return lh.forward(ctx, f, state)
+	}
 
-		return lh.nextOrFailure(state.Name(), ctx, w, r, dns.RcodeNotImplemented, msg)
+	if len(lh.clustersetDomains) > 0 {
+		zone = plugin.Zones(lh.clustersetDomains).Matches(qname)
+		if zone == "" {
+			log.Debugf("Request is not within the clusterset domains %v", lh.clustersetDomains)
+			return lh.nextOrFailure(state.Name(), ctx, w, r, dns.RcodeNameError, "Not in a clusterset domain")
+		}
 	}
 
 	zone = qname[len(qname)-len(zone):] // maintain case of original query
 	state.Zone = zone
 
-	pReq, pErr := parseRequest(state)
+	if lh.dnssec != nil && state.QType() == dns.TypeDNSKEY && qname == zone {
+		return lh.dnskeyResponse(state)
+	}
+
+	if lh.transfer != nil && (state.QType() == dns.TypeAXFR || state.QType() == dns.TypeIXFR) && qname == zone {
+		return lh.transferResponse(state)
+	}
+
+	if qname == zone {
+		log.Debugf("Request is for the apex of %q", zone)
+		return lh.apexResponse(ctx, state)
+	}
+
+	pReq, pErr := parseRequest(state, lh.srvPrefixes)
+	if pErr == nil && pReq.podOrSvc == "" && strings.EqualFold(qname, Svc+"."+zone) {
+		// The svc label exists, even though it owns no records; pod queries aren't supported so nothing is below pod
+		log.Debugf("Request for %q is for an empty non-terminal", qname)
+		return lh.emptyNonTerminalResponse(ctx, state, true)
+	}
+
 	if pErr != nil || pReq.podOrSvc != Svc {
 		// We only support svc type queries i.e. *.svc.*
 		log.Debugf("Request type %q is not a 'svc' type query - err was %v", pReq.podOrSvc, pErr)
 		return lh.nextOrFailure(state.Name(), ctx, w, r, dns.RcodeNameError, "Only services supported")
 	}
 
-	return lh.getDNSRecord(zone, state, ctx, w, r, pReq)
+	if pReq.cluster != "" {
+		pReq.cluster = lh.clusterID(pReq.cluster)
+	}
+
+	if lh.isExcludedNamespace(pReq.namespace) {
+		log.Debugf("Namespace %q is excluded", pReq.namespace)
+		return lh.nextOrFailure(state.Name(), ctx, w, r, dns.RcodeNameError, "namespace excluded")
+	}
+
+	if state.QType() == dns.TypeSRV && plugin.Zones(lh.srvDisabledZones).Matches(qname) != "" {
+		log.Debugf("SRV records are disabled for %q", qname)
+
+		if lh.Fall.Through(qname) {
+			return plugin.NextOrFailure(lh.Name(), lh.Next, ctx, w, r)
+		}
+
+		return lh.noData(ctx, state)
+	}
+
+	if lh.getLoadBalance() == hashLoadBalance {
+		pReq.client = clientKey(state)
+	}
+
+	pReq.logEntry = entry
+
+	if lh.staleExpired() {
+		log.Debugf("Not answering %q from ServiceImports and EndpointSlices stale for longer than %v", qname, lh.stale.window)
+		return dns.RcodeServerFailure, lh.error("stale data expired")
+	}
+
+	if _, stale := lh.staleTTL(); stale {
+		staleAnswersCount.Inc()
+	}
+
+	if nonTerminal, exists := lh.emptyNonTerminal(state, pReq); nonTerminal {
+		log.Debugf("Request for %q is for an empty non-terminal, which exists: %t", qname, exists)
+		return lh.emptyNonTerminalResponse(ctx, state, exists)
+	}
+
+	if lh.wildcardLimit > 0 && isWildcard(pReq) {
+		rcode, err := lh.wildcardResponse(ctx, zone, state, pReq)
+		lh.stats.RecordQuery(pReq.namespace, pReq.service, rcode)
+		lh.namespaceMetrics.recordQuery(pReq.namespace, rcode)
+
+		return rcode, err
+	}
+
+	pReq = lh.resolveAlias(state, pReq)
+
+	rcode, err := lh.getDNSRecord(zone, state, ctx, w, r, pReq)
+	lh.stats.RecordQuery(pReq.namespace, pReq.service, rcode)
+	lh.namespaceMetrics.recordQuery(pReq.namespace, rcode)
+
+	return rcode, err
 }
 
 func (lh *Lighthouse) getDNSRecord(zone string, state request.Request, ctx context.Context, w dns.ResponseWriter,
 	r *dns.Msg, pReq recordRequest) (int, error) {
-	var isHeadless bool
-	var (
-		dnsRecords []serviceimport.DNSRecord
-		found      bool
-		record     *serviceimport.DNSRecord
-	)
-
-	record, found = lh.getClusterIPForSvc(pReq)
-	if !found {
-		dnsRecords, found = lh.endpointSlices.GetDNSRecords(pReq.hostname, pReq.cluster, pReq.namespace,
-			pReq.service, lh.clusterStatus.IsConnected)
-		if !found {
-			log.Debugf("No record found for %q", state.QName())
+	if nxdomain, cached := lh.negative.get(pReq.namespace, state.Name(), state.QType()); cached {
+		log.Debugf("Answering %q from the negative cache", state.QName())
+		negativeCacheHitsCount.Inc()
+
+		if nxdomain {
 			return lh.nextOrFailure(state.Name(), ctx, w, r, dns.RcodeNameError, "record not found")
 		}
 
-		isHeadless = true
-	} else if record != nil && record.IP != "" {
-		dnsRecords = append(dnsRecords, *record)
+		return lh.noData(ctx, state)
 	}
 
-	if len(dnsRecords) == 0 {
-		log.Debugf("Couldn't find a connected cluster or valid IPs for %q", state.QName())
-		return lh.emptyResponse(state)
+	// The records answering a query for an alias answered with a CNAME are those of the name it points to
+	answerState := state
+	if pReq.cname != nil {
+		req := r.Copy()
+		req.Question[0].Name = pReq.cname.Target
+		answerState = request.Request{W: w, Req: req, Zone: state.Zone}
 	}
 
-	if state.QType() == dns.TypeAAAA {
-		log.Debugf("Returning empty response for TypeAAAA query")
-		return lh.emptyResponse(state)
+	answers, shared := lh.flights.do(flightKey(answerState, pReq), func() *answerSet {
+		return lh.assembleAnswers(zone, answerState, pReq)
+	})
+	if shared {
+		dedupHitsCount.Inc()
+	}
+
+	dnsRecords, records, extras := answers.dnsRecords, answers.records, answers.extras
+
+	if !answers.found {
+		log.Debugf("No record found for %q", state.QName())
+		lh.negative.add(pReq.namespace, state.Name(), state.QType(), true)
+
+		return lh.nextOrFailure(state.Name(), ctx, w, r, dns.RcodeNameError, "record not found")
 	}
 
-	records := make([]dns.RR, 0)
+	// A port-prefixed name only exists if one of the service's ports matches; if no cluster is available, we can't tell
+	if pReq.port != "" && len(dnsRecords) > 0 && !hasRequestedPort(dnsRecords, pReq) {
+		log.Debugf("No port matching %q found for %q", pReq.port, state.QName())
+		lh.negative.add(pReq.namespace, state.Name(), state.QType(), true)
 
-	if state.QType() == dns.TypeA {
-		records = lh.createARecords(dnsRecords, state)
-	} else if state.QType() == dns.TypeSRV {
-		records = lh.createSRVRecords(dnsRecords, state, pReq, zone, isHeadless)
+		return lh.nextOrFailure(state.Name(), ctx, w, r, dns.RcodeNameError, "port not found")
 	}
 
+	if answers.disconnected {
+		disconnectedAnswersCount.WithLabelValues(lh.disconnectedPolicy).Inc()
+
+		if lh.disconnectedPolicy == failDisconnected {
+			log.Debugf("Only disconnected clusters can answer %q", state.QName())
+			return dns.RcodeServerFailure, lh.error("clusters disconnected")
+		}
+	}
+
+	// The name exists, so any query type we can't answer gets a NODATA response
 	if len(records) == 0 {
-		log.Debugf("Couldn't find a connected cluster or valid record for %q", state.QName())
-		return lh.emptyResponse(state)
+		log.Debugf("Couldn't find a connected cluster or valid record of type %d for %q", state.QType(), state.QName())
+		lh.negative.add(pReq.namespace, state.Name(), state.QType(), false)
+
+		return lh.noData(ctx, state)
+	}
+
+	lh.prefetch.record(flightKey(answerState, pReq), zone, answerState, pReq)
+
+	if answers.isHeadless {
+		var truncated bool
+		if records, extras, truncated = lh.capEndpoints(records, extras); truncated {
+			log.Debugf("Capping the answer for %q to %d records", state.QName(), lh.maxEndpoints)
+			truncatedAnswersCount.Inc()
+		}
+	}
+
+	if lh.upstream != nil && state.QType() == dns.TypeSRV {
+		records, extras = lh.resolveSRVTargets(ctx, state, records, extras)
+		if len(records) == 0 {
+			log.Debugf("None of the SRV targets of %q could be resolved", state.QName())
+			return lh.noData(ctx, state)
+		}
 	}
 
 	log.Debugf("rr is %v", records)
 
-	a := new(dns.Msg)
-	a.SetReply(r)
-	a.Authoritative = true
+	a := newResponse(r)
 	a.Answer = append(a.Answer, records...)
+	a.Extra = append(a.Extra, extras...)
+
+	if answers.disconnected {
+		a.Answer = withTTL(a.Answer, lh.disconnectedTTL)
+		a.Extra = withTTL(a.Extra, lh.disconnectedTTL)
+	}
+
+	// Clients commonly use the first address answered, so the endpoints of headless services are answered in random
+	// order to spread the clients over the pods, as kube-dns does. The records are shared with concurrent queries and
+	// cached, so only the response's copy is shuffled.
+	if answers.isHeadless && state.QType() == lh.primaryAddressType() && !lh.noShuffle && !lh.stableOrder {
+		rand.Shuffle(len(a.Answer), func(i, j int) {
+			a.Answer[i], a.Answer[j] = a.Answer[j], a.Answer[i]
+		})
+	}
+
+	if pReq.cname != nil {
+		a.Answer = append([]dns.RR{pReq.cname}, a.Answer...)
+	}
+
+	lh.sign(state, a, zone)
+
+	// Compress the response so that its size is accounted for as it will be sent. If it still doesn't fit the
+	// client's UDP buffer size, additional records are dropped first, then answers, and the TC bit is set so that the
+	// client retries over TCP to get the full set.
+	a.Compress = true
+	state.SizeAndDo(a)
+	a = state.Scrub(a)
+
 	log.Debugf("Responding to query with '%s'", a.Answer)
 
+	clusters := clustersOf(dnsRecords)
+	traceAnswer(ctx, clusters, len(a.Answer))
+	pReq.logEntry.answered(clusters)
+
 	wErr := w.WriteMsg(a)
 	if wErr != nil {
 		// Error writing reply msg
@@ -130,10 +315,88 @@ func (lh *Lighthouse) getDNSRecord(zone string, state request.Request, ctx conte
 	return dns.RcodeSuccess, nil
 }
 
+// assembleAnswers resolves the records of the service named by the query and assembles the resource records answering
+// it.
+func (lh *Lighthouse) assembleAnswers(zone string, state request.Request, pReq recordRequest) *answerSet {
+	answers := &answerSet{}
+
+	answers.dnsRecords, answers.isHeadless, answers.found = lh.getDNSRecords(pReq)
+	if !answers.found {
+		return answers
+	}
+
+	// If no connected cluster can answer, disconnected ones may be, depending on the policy
+	if len(answers.dnsRecords) == 0 && lh.disconnectedPolicy != "" && lh.disconnectedPolicy != dropDisconnected {
+		pReq.ignoreConnectivity = true
+
+		if dnsRecords, isHeadless, found := lh.getDNSRecords(pReq); found && len(dnsRecords) > 0 {
+			answers.dnsRecords, answers.isHeadless, answers.disconnected = dnsRecords, isHeadless, true
+		}
+	}
+
+	if lh.stableOrder {
+		answers.dnsRecords = sortRecords(answers.dnsRecords)
+	}
+
+	if state.QType() != dns.TypeANY {
+		answers.records, answers.extras = lh.createRecords(state.QType(), answers, state, pReq, zone)
+		return answers
+	}
+
+	// ANY queries get a minimal response as per RFC 8482, unless the types to answer them with are configured
+	if len(lh.anyTypes) == 0 {
+		answers.records = []dns.RR{lh.createHINFORecord(state)}
+		return answers
+	}
+
+	for _, qtype := range lh.anyTypes {
+		records, extras := lh.createRecords(qtype, answers, state, pReq, zone)
+		answers.records = append(answers.records, records...)
+		answers.extras = append(answers.extras, extras...)
+	}
+
+	return answers
+}
+
+// createRecords returns the records of the given type, and their additional records, for the resolved service.
+func (lh *Lighthouse) createRecords(qtype uint16, answers *answerSet, state request.Request, pReq recordRequest,
+	zone string) (records, extras []dns.RR) {
+	switch qtype {
+	case dns.TypeA:
+		// Port-prefixed names only own SRV records, and IPv6-only clustersets have no IPv4 addresses to answer
+		if pReq.port == "" && !lh.ipv6Only {
+			records = lh.createARecords(answers.dnsRecords, state, answers.isHeadless)
+		}
+	case dns.TypeAAAA:
+		if pReq.port == "" {
+			records = lh.createAAAARecords(answers.dnsRecords, state, answers.isHeadless)
+		}
+	case dns.TypeSRV:
+		records, extras = lh.createSRVRecords(answers.dnsRecords, state, pReq, zone, answers.isHeadless)
+	}
+
+	return records, extras
+}
+
+// noData passes the query to the next plugin if NODATA responses fall through for its name, otherwise it writes a
+// NODATA response.
+func (lh *Lighthouse) noData(ctx context.Context, state request.Request) (int, error) {
+	if lh.fallNoData.Through(state.Name()) {
+		return plugin.NextOrFailure(lh.Name(), lh.Next, ctx, state.W, state.Req)
+	}
+
+	return lh.emptyResponse(state)
+}
+
+// emptyResponse writes a NODATA response, with the zone's SOA record so that resolvers can cache it.
 func (lh *Lighthouse) emptyResponse(state request.Request) (int, error) {
-	a := new(dns.Msg)
-	a.SetReply(state.Req)
-	a.Authoritative = true
+	a := newResponse(state.Req)
+	a.Ns = []dns.RR{lh.soa(state.Zone)}
+
+	lh.sign(state, a, state.Zone)
+
+	// Echo the OPT record, without the options we don't support
+	state.SizeAndDo(a)
 
 	wErr := state.W.WriteMsg(a)
 	if wErr != nil {
@@ -145,6 +408,124 @@ func (lh *Lighthouse) emptyResponse(state request.Request) (int, error) {
 	return dns.RcodeSuccess, nil
 }
 
+// dnskeyResponse answers a query for the zone's DNSKEY records, signed with the keys themselves if the client is
+// DNSSEC-aware, so that resolvers can validate the signed answers against the zone's DS records.
+func (lh *Lighthouse) dnskeyResponse(state request.Request) (int, error) {
+	records := lh.dnssec.DNSKEYs(state.QName(), state.Zone, lh.getTTL())
+	if len(records) == 0 {
+		return lh.emptyResponse(state)
+	}
+
+	return lh.answerResponse(state, records)
+}
+
+// apexResponse answers a query for the zone's apex: with the zone's SOA record, or the name server it names, for those
+// types, otherwise with NODATA since the apex exists.
+func (lh *Lighthouse) apexResponse(ctx context.Context, state request.Request) (int, error) {
+	switch state.QType() {
+	case dns.TypeSOA:
+		return lh.answerResponse(state, []dns.RR{lh.soa(state.Zone)})
+	case dns.TypeNS:
+		return lh.answerResponse(state, []dns.RR{&dns.NS{
+			Hdr: dns.RR_Header{Name: state.QName(), Rrtype: dns.TypeNS, Class: state.QClass(), Ttl: lh.getTTL()},
+			Ns:  nameServer(state.Zone),
+		}})
+	}
+
+	return lh.emptyNonTerminalResponse(ctx, state, true)
+}
+
+// answerResponse writes a response answering the query with the given records, signed for DNSSEC-aware clients.
+func (lh *Lighthouse) answerResponse(state request.Request, records []dns.RR) (int, error) {
+	a := newResponse(state.Req)
+	a.Answer = records
+
+	lh.sign(state, a, state.Zone)
+
+	state.SizeAndDo(a)
+	a = state.Scrub(a)
+
+	wErr := state.W.WriteMsg(a)
+	if wErr != nil {
+		// Error writing reply msg
+		log.Errorf("Failed to write message %#v: %v", a, wErr)
+		return dns.RcodeServerFailure, lh.error("failed to write response")
+	}
+
+	return dns.RcodeSuccess, nil
+}
+
+// newResponse returns an empty reply to the given request, flagged as answered by the zone's authoritative server: it
+// isn't recursive, since only the forwarded zones' responses come from elsewhere and they're relayed as they are, and
+// its data is only flagged as authenticated once signed.
+func newResponse(req *dns.Msg) *dns.Msg {
+	a := new(dns.Msg)
+	a.SetReply(req)
+	a.Authoritative = true
+	a.RecursionAvailable = false
+	a.AuthenticatedData = false
+
+	return a
+}
+
+// sign signs the records of the response for a DNSSEC-aware client if there are keys for the zone, and only then flags
+// them as authenticated data (RFC 4035): validating resolvers downstream would otherwise trust unsigned records.
+func (lh *Lighthouse) sign(state request.Request, a *dns.Msg, zone string) {
+	if !state.Do() || !lh.dnssec.Signs(zone) {
+		return
+	}
+
+	a.Answer = lh.dnssec.Sign(a.Answer, zone)
+	a.Ns = lh.dnssec.Sign(a.Ns, zone)
+	a.Extra = lh.dnssec.Sign(a.Extra, zone)
+	a.AuthenticatedData = true
+}
+
+// soa returns the zone's SOA record, given with NODATA responses, whose TTL and minimum TTL are the negative TTL as
+// resolvers cache such responses for the lower of the two (RFC 2308).
+func (lh *Lighthouse) soa(zone string) dns.RR {
+	return &dns.SOA{
+		Hdr:     dns.RR_Header{Name: zone, Rrtype: dns.TypeSOA, Class: dns.ClassINET, Ttl: lh.negativeTTL()},
+		Ns:      nameServer(zone),
+		Mbox:    "hostmaster." + zone,
+		Serial:  lh.serial.get(),
+		Refresh: 7200,
+		Retry:   1800,
+		Expire:  86400,
+		Minttl:  lh.negativeTTL(),
+	}
+}
+
+// nameServer returns the name of the zone's name server, as given in its SOA and NS records.
+func nameServer(zone string) string {
+	return "ns.dns." + zone
+}
+
+// clientKey identifies the client a query is made for: the EDNS Client Subnet if the query carries one, so that
+// clients behind a shared recursive resolver are told apart, otherwise the source address.
+func clientKey(state request.Request) string {
+	if opt := state.Req.IsEdns0(); opt != nil {
+		for _, o := range opt.Option {
+			subnet, ok := o.(*dns.EDNS0_SUBNET)
+			if !ok || subnet.Address == nil {
+				continue
+			}
+
+			ip, bits := subnet.Address, net.IPv6len*8
+			if ip4 := ip.To4(); ip4 != nil && subnet.Family == 1 {
+				ip, bits = ip4, net.IPv4len*8
+			}
+
+			if int(subnet.SourceNetmask) <= bits {
+				mask := net.CIDRMask(int(subnet.SourceNetmask), bits)
+				return (&net.IPNet{IP: ip.Mask(mask), Mask: mask}).String()
+			}
+		}
+	}
+
+	return state.IP()
+}
+
 // Name implements the Handler interface.
 func (lh *Lighthouse) Name() string {
 	return PluginName
@@ -157,7 +538,43 @@ func (lh *Lighthouse) error(str string) error {
 func (lh *Lighthouse) nextOrFailure(name string, ctx context.Context, w dns.ResponseWriter, r *dns.Msg, code int, err string) (int, error) {
 	if lh.Fall.Through(name) {
 		return plugin.NextOrFailure(lh.Name(), lh.Next, ctx, w, r)
-	} else {
-		return code, lh.error(err)
 	}
+
+	// The server only writes the replies of the rcodes which aren't answers, an NXDOMAIN has to be written here
+	if code == dns.RcodeNameError {
+		log.Debugf("Answering %q with NXDOMAIN: %s", name, err)
+		return lh.nameErrorResponse(request.Request{W: w, Req: r})
+	}
+
+	return code, lh.error(err)
+}
+
+// nameErrorResponse writes an NXDOMAIN response, with the SOA record of the query's zone so that resolvers can cache
+// it (RFC 2308).
+func (lh *Lighthouse) nameErrorResponse(state request.Request) (int, error) {
+	qname := state.QName()
+
+	zone := plugin.Zones(lh.clustersetDomains).Matches(qname)
+	if zone == "" {
+		zone = plugin.Zones(lh.Zones).Matches(qname)
+	}
+
+	zone = qname[len(qname)-len(zone):] // maintain case of original query
+
+	a := newResponse(state.Req)
+	a.Rcode = dns.RcodeNameError
+	a.Ns = []dns.RR{lh.soa(zone)}
+
+	lh.sign(state, a, zone)
+
+	state.SizeAndDo(a)
+
+	wErr := state.W.WriteMsg(a)
+	if wErr != nil {
+		// Error writing reply msg
+		log.Errorf("Failed to write message %#v: %v", a, wErr)
+		return dns.RcodeServerFailure, lh.error("failed to write response")
+	}
+
+	return dns.RcodeNameError, nil
 }
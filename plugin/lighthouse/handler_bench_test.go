@@ -0,0 +1,150 @@
+/*
+SPDX-License-Identifier: Apache-2.0
+
+Copyright Contributors to the Submariner project.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package lighthouse
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/coredns/coredns/plugin/test"
+	"github.com/coredns/coredns/request"
+	"github.com/miekg/dns"
+	"github.com/submariner-io/lighthouse/pkg/endpointslice"
+	"github.com/submariner-io/lighthouse/pkg/serviceimport"
+	mcsv1a1 "sigs.k8s.io/mcs-api/pkg/apis/v1alpha1"
+)
+
+const benchmarkEndpoints = 10
+
+func newBenchmarkLighthouse(endpoints int) *Lighthouse {
+	mockCs := NewMockClusterStatus()
+	mockCs.clusterStatusMap[clusterID] = true
+	mockCs.clusterStatusMap[clusterID2] = true
+	mockCs.localClusterID = clusterID
+
+	mockEs := NewMockEndpointStatus()
+	mockEs.endpointStatusMap[clusterID] = true
+	mockEs.endpointStatusMap[clusterID2] = true
+
+	siMap := serviceimport.NewMap()
+	siMap.Put(newServiceImport(namespace1, service1, clusterID2, serviceIP, portName1, portNumber1, protocol1, mcsv1a1.ClusterSetIP))
+	siMap.Put(newServiceImport(namespace2, service1, clusterID, "", portName1, portNumber1, protocol1, mcsv1a1.Headless))
+
+	hostNames := make([]string, endpoints)
+	endpointIPs := make([]string, endpoints)
+
+	for i := range endpointIPs {
+		hostNames[i] = fmt.Sprintf("host%d", i)
+		endpointIPs[i] = fmt.Sprintf("100.96.%d.%d", 157+i/250, i%250+1)
+	}
+
+	esMap := endpointslice.NewMap()
+	esMap.Put(newEndpointSlice(namespace2, service1, clusterID, portName1, hostNames, endpointIPs, portNumber1, protocol1))
+
+	return &Lighthouse{
+		Zones:           []string{"clusterset.local."},
+		serviceImports:  siMap,
+		endpointSlices:  esMap,
+		clusterStatus:   mockCs,
+		endpointsStatus: mockEs,
+		localServices:   NewMockLocalServices(),
+		ttl:             defaultTTL,
+	}
+}
+
+func benchmarkServeDNS(b *testing.B, namespace string, qtype uint16, endpoints int) {
+	lh := newBenchmarkLighthouse(endpoints)
+	w := &test.ResponseWriter{}
+
+	m := new(dns.Msg)
+	m.SetQuestion(fmt.Sprintf("%s.%s.svc.clusterset.local.", service1, namespace), qtype)
+
+	b.ReportAllocs()
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		if _, err := lh.ServeDNS(context.TODO(), w, m); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkServeDNS_A(b *testing.B) {
+	benchmarkServeDNS(b, namespace1, dns.TypeA, benchmarkEndpoints)
+}
+
+func BenchmarkServeDNS_SRV(b *testing.B) {
+	benchmarkServeDNS(b, namespace1, dns.TypeSRV, benchmarkEndpoints)
+}
+
+func BenchmarkServeDNSHeadlessA(b *testing.B) {
+	benchmarkServeDNS(b, namespace2, dns.TypeA, benchmarkEndpoints)
+}
+
+func BenchmarkServeDNSHeadlessSRV(b *testing.B) {
+	benchmarkServeDNS(b, namespace2, dns.TypeSRV, benchmarkEndpoints)
+}
+
+func BenchmarkServeDNS_Headless1000Endpoints(b *testing.B) {
+	benchmarkServeDNS(b, namespace2, dns.TypeA, 1000)
+}
+
+// The uncached variants build every record on each query, which is what the handler did before records were cached.
+func benchmarkCreateRecords(b *testing.B, qtype uint16, cached bool) {
+	lh := newBenchmarkLighthouse(benchmarkEndpoints)
+
+	dnsRecords, _ := lh.endpointSlices.GetDNSRecords("", "", namespace2, service1, nil)
+	if !cached {
+		for i := range dnsRecords {
+			dnsRecords[i].RRs = nil
+		}
+	}
+
+	m := new(dns.Msg)
+	m.SetQuestion(fmt.Sprintf("%s.%s.svc.clusterset.local.", service1, namespace2), qtype)
+	state := request.Request{W: &test.ResponseWriter{}, Req: m}
+	pReq := recordRequest{service: service1, namespace: namespace2}
+
+	b.ReportAllocs()
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		if qtype == dns.TypeA {
+			lh.createARecords(dnsRecords, state, true)
+		} else {
+			lh.createSRVRecords(dnsRecords, state, pReq, "clusterset.local.", true)
+		}
+	}
+}
+
+func BenchmarkCreateARecordsCached(b *testing.B) {
+	benchmarkCreateRecords(b, dns.TypeA, true)
+}
+
+func BenchmarkCreateARecordsUncached(b *testing.B) {
+	benchmarkCreateRecords(b, dns.TypeA, false)
+}
+
+func BenchmarkCreateSRVRecordsCached(b *testing.B) {
+	benchmarkCreateRecords(b, dns.TypeSRV, true)
+}
+
+func BenchmarkCreateSRVRecordsUncached(b *testing.B) {
+	benchmarkCreateRecords(b, dns.TypeSRV, false)
+}
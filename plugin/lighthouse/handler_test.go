@@ -18,8 +18,13 @@ limitations under the License.
 package lighthouse
 
 import (
+	"bytes"
 	"context"
+	"encoding/json"
 	"fmt"
+	"net"
+	"sort"
+	"time"
 
 	v1 "k8s.io/api/core/v1"
 
@@ -29,10 +34,16 @@ import (
 	"github.com/miekg/dns"
 	. "github.com/onsi/ginkgo"
 	. "github.com/onsi/gomega"
+	ot "github.com/opentracing/opentracing-go"
+	"github.com/opentracing/opentracing-go/mocktracer"
 	"github.com/pkg/errors"
+	"github.com/submariner-io/lighthouse/pkg/breaker"
 	lhconstants "github.com/submariner-io/lighthouse/pkg/constants"
+	"github.com/submariner-io/lighthouse/pkg/dnssec"
+	"github.com/submariner-io/lighthouse/pkg/dnstls"
 	"github.com/submariner-io/lighthouse/pkg/endpointslice"
 	"github.com/submariner-io/lighthouse/pkg/serviceimport"
+	"github.com/submariner-io/lighthouse/pkg/stats"
 	discovery "k8s.io/api/discovery/v1beta1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	mcsv1a1 "sigs.k8s.io/mcs-api/pkg/apis/v1alpha1"
@@ -61,10 +72,50 @@ const (
 var _ = Describe("Lighthouse DNS plugin Handler", func() {
 	Context("Fallthrough not configured", testWithoutFallback)
 	Context("Fallthrough configured", testWithFallback)
+	Context("NODATA fallthrough configured", testWithNoDataFallback)
 	Context("Cluster connectivity status", testClusterStatus)
 	Context("Headless services", testHeadlessService)
 	Context("Local services", testLocalService)
 	Context("SRV  records", testSRVMultiplePorts)
+	Context("Clusterset domain configured", testClustersetDomain)
+	Context("Circuit breaker configured", testCircuitBreaker)
+	Context("Multiple zones configured", testMultipleZones)
+	Context("Negative answers", testNegativeAnswers)
+	Context("EDNS handling", testEDNS)
+	Context("Large answer sets", testLargeAnswers)
+	Context("Latency load balancing configured", testLatencyLoadBalance)
+	Context("Hash load balancing configured", testHashLoadBalance)
+	Context("SRV records disabled", testDisableSRV)
+	Context("Namespaces restricted", testNamespaceRestrictions)
+	Context("Resolution statistics configured", testResolutionStats)
+	Context("Tracing enabled", testTracing)
+	Context("Query log configured", testQueryLog)
+	Context("DNSSEC configured", testDNSSEC)
+	Context("Forwarding over TLS configured", testTLSForwarding)
+	Context("Rate limit configured", testRateLimit)
+	Context("ServiceImports with several IPs", testMultipleIPs)
+	Context("Ports with an application protocol", testAppProtocol)
+	Context("Wildcard queries", testWildcard)
+	Context("ANY queries", testAnyQueries)
+	Context("Cluster selection policies", testClusterSelection)
+	Context("Serving stale data configured", testServeStale)
+	Context("SRV priorities", testSRVPriorities)
+	Context("Topology preference configured", testTopology)
+	Context("Service aliases", testAliases)
+	Context("Cluster aliases", testClusterAliases)
+	Context("Negative caching configured", testNegativeCache)
+	Context("Disconnected cluster policies", testDisconnectedClusters)
+	Context("Maximum endpoints configured", testMaxEndpoints)
+	Context("Dynamic updates", testDynamicUpdates)
+	Context("Health threshold configured", testHealthThreshold)
+	Context("Minimum ready endpoints requested", testMinReadyEndpoints)
+	Context("Record class TTLs configured", testRecordTTLs)
+	Context("IPv6-only mode", testIPv6Only)
+	Context("Broker out of sync TTL configured", testBrokerOutOfSync)
+	Context("Non-canonical query names", testNonCanonicalQueries)
+	Context("Empty non-terminals", testEmptyNonTerminals)
+	Context("Response flags", testResponseFlags)
+	Context("Clusters in maintenance", testMaintenance)
 })
 
 type FailingResponseWriter struct {
@@ -85,16 +136,54 @@ func (m *MockClusterStatus) IsConnected(clusterID string) bool {
 	return m.clusterStatusMap[clusterID]
 }
 
+type MockClusterLatency struct {
+	*MockClusterStatus
+	latencyMap map[string]time.Duration
+}
+
+func (m *MockClusterLatency) Latency(clusterID string) (time.Duration, bool) {
+	rtt, found := m.latencyMap[clusterID]
+	return rtt, found
+}
+
 type MockEndpointStatus struct {
 	endpointStatusMap map[string]bool
+	// healthScoreMap overrides the score of the healthy clusters in endpointStatusMap
+	healthScoreMap map[string]float64
+	// readyCountMap overrides the number of ready endpoints, 1, of the healthy clusters in endpointStatusMap
+	readyCountMap map[string]int
 }
 
 func NewMockEndpointStatus() *MockEndpointStatus {
-	return &MockEndpointStatus{endpointStatusMap: make(map[string]bool)}
+	return &MockEndpointStatus{
+		endpointStatusMap: make(map[string]bool),
+		healthScoreMap:    make(map[string]float64),
+		readyCountMap:     make(map[string]int),
+	}
+}
+
+func (m *MockEndpointStatus) ReadyEndpoints(name, namespace, clusterID string) int {
+	if !m.endpointStatusMap[clusterID] {
+		return 0
+	}
+
+	if count, found := m.readyCountMap[clusterID]; found {
+		return count
+	}
+
+	return 1
 }
 
-func (m *MockEndpointStatus) IsHealthy(name, namespace, clusterID string) bool {
-	return m.endpointStatusMap[clusterID]
+func (m *MockEndpointStatus) HealthScore(name, namespace, clusterID string) float64 {
+	if !m.endpointStatusMap[clusterID] {
+		return 0
+	}
+
+	if score, found := m.healthScoreMap[clusterID]; found {
+		return score
+	}
+
+	return 1
 }
 
 func (m *MockClusterStatus) LocalClusterID() string {
@@ -166,6 +255,9 @@ func testWithoutFallback() {
 				Answer: []dns.RR{
 					test.SRV(fmt.Sprintf("%s    5    IN    SRV 0 50 %d %s", qname, portNumber1, qname)),
 				},
+				Extra: []dns.RR{
+					test.A(fmt.Sprintf("%s    5    IN    A    %s", qname, serviceIP)),
+				},
 			})
 		})
 	})
@@ -191,6 +283,9 @@ func testWithoutFallback() {
 				Answer: []dns.RR{
 					test.SRV(fmt.Sprintf("%s    5    IN    SRV 0 50 %d %s", qname, portNumber1, qname)),
 				},
+				Extra: []dns.RR{
+					test.A(fmt.Sprintf("%s    5    IN    A    %s", qname, serviceIP)),
+				},
 			})
 		})
 	})
@@ -219,6 +314,9 @@ func testWithoutFallback() {
 				Answer: []dns.RR{
 					test.SRV(fmt.Sprintf("%s    5    IN    SRV 0 50 %d %s", qname, portNumber1, qname)),
 				},
+				Extra: []dns.RR{
+					test.A(fmt.Sprintf("%s    5    IN    A    %s", qname, serviceIP)),
+				},
 			})
 		})
 	})
@@ -303,6 +401,7 @@ func testWithoutFallback() {
 				Qtype:  dns.TypeAAAA,
 				Rcode:  dns.RcodeSuccess,
 				Answer: []dns.RR{},
+				Ns:     []dns.RR{soaRecord("clusterset.local.")},
 			})
 		})
 	})
@@ -383,6 +482,7 @@ func testWithFallback() {
 				Qtype:  dns.TypeAAAA,
 				Rcode:  dns.RcodeSuccess,
 				Answer: []dns.RR{},
+				Ns:     []dns.RR{soaRecord("clusterset.local.")},
 			})
 		})
 	})
@@ -450,6 +550,67 @@ func testWithFallback() {
 	})
 }
 
+func testWithNoDataFallback() {
+	var (
+		rec *dnstest.Recorder
+		lh  *Lighthouse
+	)
+
+	BeforeEach(func() {
+		mockCs := NewMockClusterStatus()
+		mockCs.clusterStatusMap[clusterID] = true
+		mockEs := NewMockEndpointStatus()
+		mockEs.endpointStatusMap[clusterID] = true
+
+		lh = &Lighthouse{
+			Zones:           []string{"clusterset.local."},
+			fallNoData:      fall.F{Zones: []string{"clusterset.local."}},
+			Next:            test.NextHandler(dns.RcodeBadCookie, errors.New("dummy plugin")),
+			serviceImports:  setupServiceImportMap(),
+			endpointSlices:  setupEndpointSliceMap(),
+			clusterStatus:   mockCs,
+			endpointsStatus: mockEs,
+			localServices:   NewMockLocalServices(),
+			ttl:             defaultTTL,
+		}
+
+		rec = dnstest.NewRecorder(&test.ResponseWriter{})
+	})
+
+	When("a query results in NODATA", func() {
+		It("should invoke the next plugin", func() {
+			executeTestCase(lh, rec, test.Case{
+				Qname: fmt.Sprintf("%s.%s.svc.clusterset.local.", service1, namespace1),
+				Qtype: dns.TypeAAAA,
+				Rcode: dns.RcodeBadCookie,
+			})
+		})
+	})
+
+	When("a query results in NXDOMAIN", func() {
+		It("should not invoke the next plugin", func() {
+			executeTestCase(lh, rec, test.Case{
+				Qname: fmt.Sprintf("%s.%s.svc.clusterset.local.", service1, namespace2),
+				Qtype: dns.TypeA,
+				Rcode: dns.RcodeNameError,
+			})
+		})
+	})
+
+	When("a query for a name outside the fallthrough zones results in NODATA", func() {
+		It("should return an empty response", func() {
+			lh.Zones = []string{"clusterset.local.", "supercluster.local."}
+			executeTestCase(lh, rec, test.Case{
+				Qname:  fmt.Sprintf("%s.%s.svc.supercluster.local.", service1, namespace1),
+				Qtype:  dns.TypeAAAA,
+				Rcode:  dns.RcodeSuccess,
+				Answer: []dns.RR{},
+				Ns:     []dns.RR{soaRecord("supercluster.local.")},
+			})
+		})
+	})
+}
+
 func testClusterStatus() {
 	var (
 		rec    *dnstest.Recorder
@@ -501,6 +662,9 @@ func testClusterStatus() {
 				Answer: []dns.RR{
 					test.SRV(fmt.Sprintf("%s    5    IN    SRV 0 50 %d %s", qname, portNumber2, qname)),
 				},
+				Extra: []dns.RR{
+					test.A(fmt.Sprintf("%s    5    IN    A    %s", qname, serviceIP2)),
+				},
 			})
 		})
 	})
@@ -530,6 +694,9 @@ func testClusterStatus() {
 				Answer: []dns.RR{
 					test.SRV(fmt.Sprintf("%s    5    IN    SRV 0 50 %d %s", qname, portNumber1, qname)),
 				},
+				Extra: []dns.RR{
+					test.A(fmt.Sprintf("%s    5    IN    A    %s", qname, serviceIP)),
+				},
 			})
 		})
 	})
@@ -557,6 +724,9 @@ func testClusterStatus() {
 				Answer: []dns.RR{
 					test.SRV(fmt.Sprintf("%s    5    IN    SRV 0 50 %d %s", qname, portNumber2, qname)),
 				},
+				Extra: []dns.RR{
+					test.A(fmt.Sprintf("%s    5    IN    A    %s", qname, serviceIP2)),
+				},
 			})
 		})
 	})
@@ -573,6 +743,7 @@ func testClusterStatus() {
 				Qtype:  dns.TypeA,
 				Rcode:  dns.RcodeSuccess,
 				Answer: []dns.RR{},
+				Ns:     []dns.RR{soaRecord("clusterset.local.")},
 			})
 		})
 		It("should return empty response (NODATA) for SRV record query", func() {
@@ -581,6 +752,7 @@ func testClusterStatus() {
 				Qtype:  dns.TypeSRV,
 				Rcode:  dns.RcodeSuccess,
 				Answer: []dns.RR{},
+				Ns:     []dns.RR{soaRecord("clusterset.local.")},
 			})
 		})
 	})
@@ -598,6 +770,7 @@ func testClusterStatus() {
 				Qtype:  dns.TypeA,
 				Rcode:  dns.RcodeSuccess,
 				Answer: []dns.RR{},
+				Ns:     []dns.RR{soaRecord("clusterset.local.")},
 			})
 		})
 		It("should return empty response (NODATA) for SRV record query", func() {
@@ -606,6 +779,7 @@ func testClusterStatus() {
 				Qtype:  dns.TypeSRV,
 				Rcode:  dns.RcodeSuccess,
 				Answer: []dns.RR{},
+				Ns:     []dns.RR{soaRecord("clusterset.local.")},
 			})
 		})
 	})
@@ -653,6 +827,7 @@ func testHeadlessService() {
 				Qtype:  dns.TypeA,
 				Rcode:  dns.RcodeSuccess,
 				Answer: []dns.RR{},
+				Ns:     []dns.RR{soaRecord("clusterset.local.")},
 			})
 		})
 		It("should succeed and return empty response (NODATA)", func() {
@@ -661,6 +836,7 @@ func testHeadlessService() {
 				Qtype:  dns.TypeSRV,
 				Rcode:  dns.RcodeSuccess,
 				Answer: []dns.RR{},
+				Ns:     []dns.RR{soaRecord("clusterset.local.")},
 			})
 		})
 	})
@@ -690,6 +866,9 @@ func testHeadlessService() {
 				Answer: []dns.RR{
 					test.SRV(fmt.Sprintf("%s    5    IN    SRV 0 50 %d %s.%s.%s", qname, portNumber1, hostName1, clusterID, qname)),
 				},
+				Extra: []dns.RR{
+					test.A(fmt.Sprintf("%s.%s.%s    5    IN    A    %s", hostName1, clusterID, qname, endpointIP)),
+				},
 			})
 		})
 		It("should succeed and write an SRV record response for query with cluster name", func() {
@@ -701,6 +880,9 @@ func testHeadlessService() {
 				Answer: []dns.RR{
 					test.SRV(fmt.Sprintf("%s    5    IN    SRV  0 50 %d %s.%s", qname, portNumber1, hostName1, qname)),
 				},
+				Extra: []dns.RR{
+					test.A(fmt.Sprintf("%s.%s    5    IN    A    %s", hostName1, qname, endpointIP)),
+				},
 			})
 		})
 	})
@@ -734,6 +916,10 @@ func testHeadlessService() {
 					test.SRV(fmt.Sprintf("%s    5    IN    SRV  0 50 %d %s.%s.%s", qname, portNumber1, hostName1, clusterID, qname)),
 					test.SRV(fmt.Sprintf("%s    5    IN    SRV  0 50 %d %s.%s.%s", qname, portNumber1, hostName2, clusterID, qname)),
 				},
+				Extra: []dns.RR{
+					test.A(fmt.Sprintf("%s.%s.%s    5    IN    A    %s", hostName1, clusterID, qname, endpointIP)),
+					test.A(fmt.Sprintf("%s.%s.%s    5    IN    A    %s", hostName2, clusterID, qname, endpointIP2)),
+				},
 			})
 		})
 		It("should succeed and write an SRV record response when port and protocol is queried", func() {
@@ -748,6 +934,12 @@ func testHeadlessService() {
 					test.SRV(fmt.Sprintf("%s    5    IN    SRV  0 50 %d %s.%s.%s.%s.svc.clusterset.local.",
 						qname, portNumber1, hostName2, clusterID, service1, namespace1)),
 				},
+				Extra: []dns.RR{
+					test.A(fmt.Sprintf("%s.%s.%s.%s.svc.clusterset.local.    5    IN    A    %s",
+						hostName1, clusterID, service1, namespace1, endpointIP)),
+					test.A(fmt.Sprintf("%s.%s.%s.%s.svc.clusterset.local.    5    IN    A    %s",
+						hostName2, clusterID, service1, namespace1, endpointIP2)),
+				},
 			})
 		})
 		It("should succeed and write an SRV record response when port and protocol is queried with underscore prefix", func() {
@@ -762,6 +954,12 @@ func testHeadlessService() {
 					test.SRV(fmt.Sprintf("%s    5    IN    SRV  0 50 %d %s.%s.%s.%s.svc.clusterset.local.",
 						qname, portNumber1, hostName2, clusterID, service1, namespace1)),
 				},
+				Extra: []dns.RR{
+					test.A(fmt.Sprintf("%s.%s.%s.%s.svc.clusterset.local.    5    IN    A    %s",
+						hostName1, clusterID, service1, namespace1, endpointIP)),
+					test.A(fmt.Sprintf("%s.%s.%s.%s.svc.clusterset.local.    5    IN    A    %s",
+						hostName2, clusterID, service1, namespace1, endpointIP2)),
+				},
 			})
 		})
 	})
@@ -880,6 +1078,9 @@ func testLocalService() {
 				Answer: []dns.RR{
 					test.SRV(fmt.Sprintf("%s    5    IN    SRV 0 50 %d %s", qname, portNumber1, qname)),
 				},
+				Extra: []dns.RR{
+					test.A(fmt.Sprintf("%s    5    IN    A    %s", qname, serviceIP)),
+				},
 			})
 		})
 	})
@@ -905,6 +1106,9 @@ func testLocalService() {
 				Answer: []dns.RR{
 					test.SRV(fmt.Sprintf("%s    5    IN    SRV 0 50 %d %s", qname, portNumber2, qname)),
 				},
+				Extra: []dns.RR{
+					test.A(fmt.Sprintf("%s    5    IN    A    %s", qname, serviceIP2)),
+				},
 			})
 		})
 	})
@@ -935,6 +1139,9 @@ func testLocalService() {
 				Answer: []dns.RR{
 					test.SRV(fmt.Sprintf("%s    5    IN    SRV 0 50 %d %s", qname, portNumber2, qname)),
 				},
+				Extra: []dns.RR{
+					test.A(fmt.Sprintf("%s    5    IN    A    %s", qname, serviceIP2)),
+				},
 			})
 		})
 	})
@@ -996,6 +1203,9 @@ func testSRVMultiplePorts() {
 					test.SRV(fmt.Sprintf("%s    5    IN    SRV 0 50 %d %s", qname, portNumber2, qname)),
 					test.SRV(fmt.Sprintf("%s    5    IN    SRV 0 50 %d %s", qname, portNumber1, qname)),
 				},
+				Extra: []dns.RR{
+					test.A(fmt.Sprintf("%s    5    IN    A    %s", qname, serviceIP)),
+				},
 			})
 		})
 		It("with  HTTP portname  should return TCP port", func() {
@@ -1007,6 +1217,9 @@ func testSRVMultiplePorts() {
 				Answer: []dns.RR{
 					test.SRV(fmt.Sprintf("%s    5    IN    SRV 0 50 %d %s.%s.svc.clusterset.local.", qname, portNumber1, service1, namespace1)),
 				},
+				Extra: []dns.RR{
+					test.A(fmt.Sprintf("%s.%s.svc.clusterset.local.    5    IN    A    %s", service1, namespace1, serviceIP)),
+				},
 			})
 		})
 		It("with  DNS portname  should return UDP port", func() {
@@ -1018,6 +1231,9 @@ func testSRVMultiplePorts() {
 				Answer: []dns.RR{
 					test.SRV(fmt.Sprintf("%s    5    IN    SRV 0 50 %d %s.%s.svc.clusterset.local.", qname, portNumber2, service1, namespace1)),
 				},
+				Extra: []dns.RR{
+					test.A(fmt.Sprintf("%s.%s.svc.clusterset.local.    5    IN    A    %s", service1, namespace1, serviceIP)),
+				},
 			})
 		})
 		It("with  cluster name should return all the ports from the cluster", func() {
@@ -1030,6 +1246,9 @@ func testSRVMultiplePorts() {
 					test.SRV(fmt.Sprintf("%s    5    IN    SRV 0 50 %d %s", qname, portNumber2, qname)),
 					test.SRV(fmt.Sprintf("%s    5    IN    SRV 0 50 %d %s", qname, portNumber1, qname)),
 				},
+				Extra: []dns.RR{
+					test.A(fmt.Sprintf("%s    5    IN    A    %s", qname, serviceIP)),
+				},
 			})
 		})
 		It("with  HTTP portname  should return TCP port with underscore prefix", func() {
@@ -1041,86 +1260,2135 @@ func testSRVMultiplePorts() {
 				Answer: []dns.RR{
 					test.SRV(fmt.Sprintf("%s    5    IN    SRV 0 50 %d %s.%s.svc.clusterset.local.", qname, portNumber1, service1, namespace1)),
 				},
+				Extra: []dns.RR{
+					test.A(fmt.Sprintf("%s.%s.svc.clusterset.local.    5    IN    A    %s", service1, namespace1, serviceIP)),
+				},
 			})
 		})
 	})
 }
 
-func executeTestCase(lh *Lighthouse, rec *dnstest.Recorder, tc test.Case) {
-	code, err := lh.ServeDNS(context.TODO(), rec, tc.Msg())
+func testClustersetDomain() {
+	var (
+		rec *dnstest.Recorder
+		lh  *Lighthouse
+	)
 
-	Expect(code).Should(Equal(tc.Rcode))
+	BeforeEach(func() {
+		mockCs := NewMockClusterStatus()
+		mockCs.clusterStatusMap[clusterID] = true
+		mockEs := NewMockEndpointStatus()
+		mockEs.endpointStatusMap[clusterID] = true
+		lh = &Lighthouse{
+			Zones:             []string{"."},
+			clustersetDomains: []string{"mesh.example.org."},
+			serviceImports:    setupServiceImportMap(),
+			endpointSlices:    setupEndpointSliceMap(),
+			clusterStatus:     mockCs,
+			endpointsStatus:   mockEs,
+			localServices:     NewMockLocalServices(),
+			ttl:               defaultTTL,
+		}
 
-	if tc.Rcode == dns.RcodeSuccess {
-		Expect(err).To(Succeed())
-		Expect(test.SortAndCheck(rec.Msg, tc)).To(Succeed())
-	} else {
-		Expect(err).To(HaveOccurred())
-	}
-}
+		rec = dnstest.NewRecorder(&test.ResponseWriter{})
+	})
 
-func setupServiceImportMap() *serviceimport.Map {
-	siMap := serviceimport.NewMap()
-	siMap.Put(newServiceImport(namespace1, service1, clusterID, serviceIP, portName1, portNumber1, protocol1, mcsv1a1.ClusterSetIP))
+	When("type A DNS query for a name in the clusterset domain", func() {
+		It("should succeed and write an A record response", func() {
+			qname := fmt.Sprintf("%s.%s.svc.mesh.example.org.", service1, namespace1)
+			executeTestCase(lh, rec, test.Case{
+				Qname: qname,
+				Qtype: dns.TypeA,
+				Rcode: dns.RcodeSuccess,
+				Answer: []dns.RR{
+					test.A(fmt.Sprintf("%s    5    IN    A    %s", qname, serviceIP)),
+				},
+			})
+		})
+	})
 
-	return siMap
+	When("type SRV DNS query for a name in the clusterset domain", func() {
+		It("should succeed and write an SRV record response with a target in the clusterset domain", func() {
+			qname := fmt.Sprintf("%s.%s.%s.%s.svc.mesh.example.org.", portName1, protocol1, service1, namespace1)
+			executeTestCase(lh, rec, test.Case{
+				Qname: qname,
+				Qtype: dns.TypeSRV,
+				Rcode: dns.RcodeSuccess,
+				Answer: []dns.RR{
+					test.SRV(fmt.Sprintf("%s    5    IN    SRV 0 50 %d %s.%s.svc.mesh.example.org.", qname, portNumber1,
+						service1, namespace1)),
+				},
+				Extra: []dns.RR{
+					test.A(fmt.Sprintf("%s.%s.svc.mesh.example.org.    5    IN    A    %s", service1, namespace1, serviceIP)),
+				},
+			})
+		})
+	})
+
+	When("type A DNS query for a name outside the clusterset domain", func() {
+		It("should return RcodeNameError", func() {
+			executeTestCase(lh, rec, test.Case{
+				Qname: fmt.Sprintf("%s.%s.svc.clusterset.local.", service1, namespace1),
+				Qtype: dns.TypeA,
+				Rcode: dns.RcodeNameError,
+			})
+		})
+	})
 }
 
-func setupEndpointSliceMap() *endpointslice.Map {
-	esMap := endpointslice.NewMap()
-	esMap.Put(newEndpointSlice(namespace1, service1, clusterID, portName1, []string{hostName1}, []string{endpointIP}, portNumber1, protocol1))
+func testCircuitBreaker() {
+	var (
+		rec    *dnstest.Recorder
+		lh     *Lighthouse
+		mockEs *MockEndpointStatus
+	)
 
-	return esMap
-}
+	BeforeEach(func() {
+		mockCs := NewMockClusterStatus()
+		mockCs.clusterStatusMap[clusterID] = true
+		mockCs.clusterStatusMap[clusterID2] = true
+		mockEs = NewMockEndpointStatus()
+		mockEs.endpointStatusMap[clusterID] = true
+		mockEs.endpointStatusMap[clusterID2] = true
+		lh = &Lighthouse{
+			Zones:           []string{"clusterset.local."},
+			serviceImports:  setupServiceImportMap(),
+			endpointSlices:  setupEndpointSliceMap(),
+			clusterStatus:   mockCs,
+			endpointsStatus: mockEs,
+			localServices:   NewMockLocalServices(),
+			ttl:             defaultTTL,
+			breaker:         breaker.New(1, time.Minute, time.Minute),
+		}
 
-func newServiceImport(namespace, name, clusterID, serviceIP, portName string,
-	portNumber int32, protocol v1.Protocol, siType mcsv1a1.ServiceImportType) *mcsv1a1.ServiceImport {
-	return &mcsv1a1.ServiceImport{
-		ObjectMeta: metav1.ObjectMeta{
-			Name:      name,
-			Namespace: namespace,
-			Annotations: map[string]string{
-				"origin-name":      name,
-				"origin-namespace": namespace,
-			},
-			Labels: map[string]string{
-				lhconstants.LabelSourceCluster: clusterID,
-			},
-		},
-		Spec: mcsv1a1.ServiceImportSpec{
-			Type: siType,
-			IPs:  []string{serviceIP},
-			Ports: []mcsv1a1.ServicePort{
-				{
-					Name:     portName,
-					Protocol: protocol,
-					Port:     portNumber,
-				},
-			},
-		},
-		Status: mcsv1a1.ServiceImportStatus{
-			Clusters: []mcsv1a1.ClusterStatus{
-				{
-					Cluster: clusterID,
+		lh.serviceImports.Put(newServiceImport(namespace1, service1, clusterID2, serviceIP2, portName1, portNumber1,
+			protocol1, mcsv1a1.ClusterSetIP))
+
+		rec = dnstest.NewRecorder(&test.ResponseWriter{})
+	})
+
+	When("connection failures open the circuit for a cluster", func() {
+		JustBeforeEach(func() {
+			lh.ReportConnectivity(service1, namespace1, clusterID, false)
+		})
+
+		It("should only answer with the other cluster", func() {
+			qname := fmt.Sprintf("%s.%s.svc.clusterset.local.", service1, namespace1)
+			for i := 0; i < 2; i++ {
+				executeTestCase(lh, rec, test.Case{
+					Qname: qname,
+					Qtype: dns.TypeA,
+					Rcode: dns.RcodeSuccess,
+					Answer: []dns.RR{
+						test.A(fmt.Sprintf("%s    5    IN    A    %s", qname, serviceIP2)),
+					},
+				})
+			}
+		})
+
+		It("should answer with the cluster again once a success is reported", func() {
+			lh.ReportConnectivity(service1, namespace1, clusterID, true)
+			mockEs.endpointStatusMap[clusterID2] = false
+
+			qname := fmt.Sprintf("%s.%s.svc.clusterset.local.", service1, namespace1)
+			executeTestCase(lh, rec, test.Case{
+				Qname: qname,
+				Qtype: dns.TypeA,
+				Rcode: dns.RcodeSuccess,
+				Answer: []dns.RR{
+					test.A(fmt.Sprintf("%s    5    IN    A    %s", qname, serviceIP)),
 				},
-			},
-		},
-	}
+			})
+		})
+	})
 }
 
-func newEndpointSlice(namespace, name, clusterID, portName string, hostName, endpointIPs []string, portNumber int32,
-	protocol v1.Protocol) *discovery.EndpointSlice {
-	endpoints := make([]discovery.Endpoint, len(endpointIPs))
+func testMultipleZones() {
+	var (
+		rec *dnstest.Recorder
+		lh  *Lighthouse
+	)
 
-	for i := range endpointIPs {
-		endpoint := discovery.Endpoint{
-			Addresses: []string{endpointIPs[i]},
-			Hostname:  &hostName[i],
+	BeforeEach(func() {
+		mockCs := NewMockClusterStatus()
+		mockCs.clusterStatusMap[clusterID] = true
+		mockEs := NewMockEndpointStatus()
+		mockEs.endpointStatusMap[clusterID] = true
+		lh = &Lighthouse{
+			Zones:           []string{"clusterset.local.", "supercluster.local."},
+			serviceImports:  setupServiceImportMap(),
+			endpointSlices:  setupEndpointSliceMap(),
+			clusterStatus:   mockCs,
+			endpointsStatus: mockEs,
+			localServices:   NewMockLocalServices(),
+			ttl:             defaultTTL,
 		}
-		endpoints[i] = endpoint
-	}
 
-	return &discovery.EndpointSlice{
+		rec = dnstest.NewRecorder(&test.ResponseWriter{})
+	})
+
+	for _, zone := range []string{"clusterset.local.", "supercluster.local."} {
+		zone := zone
+
+		When(fmt.Sprintf("DNS queries are in the %q zone", zone), func() {
+			It("should succeed and write an A record response", func() {
+				qname := fmt.Sprintf("%s.%s.svc.%s", service1, namespace1, zone)
+				executeTestCase(lh, rec, test.Case{
+					Qname: qname,
+					Qtype: dns.TypeA,
+					Rcode: dns.RcodeSuccess,
+					Answer: []dns.RR{
+						test.A(fmt.Sprintf("%s    5    IN    A    %s", qname, serviceIP)),
+					},
+				})
+			})
+
+			It("should succeed and write an SRV record response with a target in the same zone", func() {
+				qname := fmt.Sprintf("%s.%s.%s.%s.svc.%s", portName1, protocol1, service1, namespace1, zone)
+				executeTestCase(lh, rec, test.Case{
+					Qname: qname,
+					Qtype: dns.TypeSRV,
+					Rcode: dns.RcodeSuccess,
+					Answer: []dns.RR{
+						test.SRV(fmt.Sprintf("%s    5    IN    SRV 0 50 %d %s.%s.svc.%s", qname, portNumber1, service1,
+							namespace1, zone)),
+					},
+					Extra: []dns.RR{
+						test.A(fmt.Sprintf("%s.%s.svc.%s    5    IN    A    %s", service1, namespace1, zone, serviceIP)),
+					},
+				})
+			})
+		})
+	}
+}
+
+func testNegativeAnswers() {
+	var (
+		rec *dnstest.Recorder
+		lh  *Lighthouse
+	)
+
+	BeforeEach(func() {
+		mockCs := NewMockClusterStatus()
+		mockCs.clusterStatusMap[clusterID] = true
+		mockEs := NewMockEndpointStatus()
+		mockEs.endpointStatusMap[clusterID] = true
+		lh = &Lighthouse{
+			Zones:           []string{"clusterset.local."},
+			serviceImports:  setupServiceImportMap(),
+			endpointSlices:  setupEndpointSliceMap(),
+			clusterStatus:   mockCs,
+			endpointsStatus: mockEs,
+			localServices:   NewMockLocalServices(),
+			ttl:             defaultTTL,
+		}
+
+		rec = dnstest.NewRecorder(&test.ResponseWriter{})
+	})
+
+	When("a query of an unsupported type is made for an existing service", func() {
+		It("should return NODATA with the SOA record", func() {
+			executeTestCase(lh, rec, test.Case{
+				Qname:  fmt.Sprintf("%s.%s.svc.clusterset.local.", service1, namespace1),
+				Qtype:  dns.TypeTXT,
+				Rcode:  dns.RcodeSuccess,
+				Answer: []dns.RR{},
+				Ns:     []dns.RR{soaRecord("clusterset.local.")},
+			})
+		})
+	})
+
+	When("a query of an unsupported type is made for a non-existent service", func() {
+		It("should return RcodeNameError", func() {
+			executeTestCase(lh, rec, test.Case{
+				Qname: fmt.Sprintf("unknown.%s.svc.clusterset.local.", namespace1),
+				Qtype: dns.TypeTXT,
+				Rcode: dns.RcodeNameError,
+			})
+		})
+	})
+
+	When("a type A query is made for an existing port-prefixed name", func() {
+		It("should return NODATA with the SOA record", func() {
+			executeTestCase(lh, rec, test.Case{
+				Qname:  fmt.Sprintf("%s.%s.%s.%s.svc.clusterset.local.", portName1, protocol1, service1, namespace1),
+				Qtype:  dns.TypeA,
+				Rcode:  dns.RcodeSuccess,
+				Answer: []dns.RR{},
+				Ns:     []dns.RR{soaRecord("clusterset.local.")},
+			})
+		})
+	})
+
+	When("a query is made for a port-prefixed name with a non-existent port", func() {
+		It("should return RcodeNameError for any type", func() {
+			qname := fmt.Sprintf("%s.%s.%s.%s.svc.clusterset.local.", portName2, protocol2, service1, namespace1)
+
+			for _, qtype := range []uint16{dns.TypeSRV, dns.TypeA} {
+				executeTestCase(lh, rec, test.Case{
+					Qname: qname,
+					Qtype: qtype,
+					Rcode: dns.RcodeNameError,
+				})
+			}
+		})
+	})
+
+	When("a query is made for a cluster-prefixed name of a cluster not exporting the service", func() {
+		It("should return RcodeNameError", func() {
+			executeTestCase(lh, rec, test.Case{
+				Qname: fmt.Sprintf("%s.%s.%s.svc.clusterset.local.", clusterID2, service1, namespace1),
+				Qtype: dns.TypeAAAA,
+				Rcode: dns.RcodeNameError,
+			})
+		})
+	})
+}
+
+func testDNSSEC() {
+	var (
+		lh     *Lighthouse
+		dnskey *dns.DNSKEY
+	)
+
+	BeforeEach(func() {
+		mockCs := NewMockClusterStatus()
+		mockCs.clusterStatusMap[clusterID] = true
+		mockEs := NewMockEndpointStatus()
+		mockEs.endpointStatusMap[clusterID] = true
+
+		lh = &Lighthouse{
+			Zones:           []string{"clusterset.local."},
+			serviceImports:  setupServiceImportMap(),
+			endpointSlices:  setupEndpointSliceMap(),
+			clusterStatus:   mockCs,
+			endpointsStatus: mockEs,
+			localServices:   NewMockLocalServices(),
+			ttl:             defaultTTL,
+			dnssec:          dnssec.NewSigner(),
+		}
+
+		dnskey = &dns.DNSKEY{
+			Hdr:       dns.RR_Header{Name: "clusterset.local.", Rrtype: dns.TypeDNSKEY, Class: dns.ClassINET, Ttl: 3600},
+			Flags:     dns.ZONE,
+			Protocol:  3,
+			Algorithm: dns.ECDSAP256SHA256,
+		}
+
+		privateKey, err := dnskey.Generate(256)
+		Expect(err).To(Succeed())
+
+		keys, err := dnssec.ParseKeys(map[string][]byte{
+			"zsk.key":     []byte(dnskey.String()),
+			"zsk.private": []byte(dnskey.PrivateKeyString(privateKey)),
+		})
+		Expect(err).To(Succeed())
+
+		lh.dnssec.SetKeys(keys)
+	})
+
+	serve := func(qname string, qtype uint16, do bool) *dns.Msg {
+		m := new(dns.Msg)
+		m.SetQuestion(qname, qtype)
+		m.SetEdns0(4096, do)
+
+		rec := dnstest.NewRecorder(&test.ResponseWriter{})
+		code, err := lh.ServeDNS(context.TODO(), rec, m)
+		Expect(err).To(Succeed())
+		Expect(code).To(Equal(dns.RcodeSuccess))
+
+		return rec.Msg
+	}
+
+	verify := func(records []dns.RR, qtype uint16) {
+		Expect(records).To(HaveLen(2))
+		Expect(records[0].Header().Rrtype).To(Equal(qtype))
+
+		sig, ok := records[1].(*dns.RRSIG)
+		Expect(ok).To(BeTrue())
+		Expect(sig.TypeCovered).To(Equal(qtype))
+		Expect(sig.Verify(dnskey, records[:1])).To(Succeed())
+	}
+
+	qname := fmt.Sprintf("%s.%s.svc.clusterset.local.", service1, namespace1)
+
+	When("a DNSSEC-aware client queries for a service", func() {
+		It("should sign the answer and flag it as authenticated data", func() {
+			msg := serve(qname, dns.TypeA, true)
+			verify(msg.Answer, dns.TypeA)
+			Expect(msg.AuthenticatedData).To(BeTrue())
+			Expect(msg.Authoritative).To(BeTrue())
+		})
+
+		It("should sign the SRV records and the addresses of their targets", func() {
+			msg := serve(qname, dns.TypeSRV, true)
+			verify(msg.Answer, dns.TypeSRV)
+			verify(msg.Extra[:2], dns.TypeA)
+		})
+	})
+
+	When("a DNSSEC-aware client queries for a type the service doesn't have", func() {
+		It("should sign the SOA record", func() {
+			msg := serve(qname, dns.TypeAAAA, true)
+			verify(msg.Ns, dns.TypeSOA)
+			Expect(msg.AuthenticatedData).To(BeTrue())
+		})
+	})
+
+	When("a client which isn't DNSSEC-aware queries for a service", func() {
+		It("should not sign the answer nor flag it as authenticated data", func() {
+			msg := serve(qname, dns.TypeA, false)
+			Expect(msg.Answer).To(HaveLen(1))
+			Expect(msg.AuthenticatedData).To(BeFalse())
+		})
+	})
+
+	When("a DNSSEC-aware client queries for a service in a zone without keys", func() {
+		BeforeEach(func() {
+			lh.dnssec.SetKeys([]dnssec.Key{})
+		})
+
+		It("should not flag the answer as authenticated data", func() {
+			msg := serve(qname, dns.TypeA, true)
+			Expect(msg.Answer).To(HaveLen(1))
+			Expect(msg.AuthenticatedData).To(BeFalse())
+		})
+	})
+
+	When("a DNSSEC-aware client queries for the zone's DNSKEY", func() {
+		It("should return the signed key", func() {
+			msg := serve("clusterset.local.", dns.TypeDNSKEY, true)
+			verify(msg.Answer, dns.TypeDNSKEY)
+			Expect(msg.Answer[0].(*dns.DNSKEY).PublicKey).To(Equal(dnskey.PublicKey))
+		})
+	})
+}
+
+func testTLSForwarding() {
+	var (
+		rec *dnstest.Recorder
+		lh  *Lighthouse
+	)
+
+	BeforeEach(func() {
+		mockCs := NewMockClusterStatus()
+		mockCs.clusterStatusMap[clusterID] = true
+		mockEs := NewMockEndpointStatus()
+		mockEs.endpointStatusMap[clusterID] = true
+
+		// Nothing listens on the upstream, so forwarded queries fail
+		forwarder, err := dnstls.NewForwarder("remote.clusterset.local.", "tls://127.0.0.1:1", "", &dnstls.Certificates{})
+		Expect(err).To(Succeed())
+
+		lh = &Lighthouse{
+			Zones:           []string{"clusterset.local."},
+			serviceImports:  setupServiceImportMap(),
+			endpointSlices:  setupEndpointSliceMap(),
+			clusterStatus:   mockCs,
+			endpointsStatus: mockEs,
+			localServices:   NewMockLocalServices(),
+			ttl:             defaultTTL,
+			forwarders:      []*dnstls.Forwarder{forwarder},
+		}
+
+		rec = dnstest.NewRecorder(&test.ResponseWriter{})
+	})
+
+	When("a query is made for a name in a forwarded zone", func() {
+		It("should forward it", func() {
+			executeTestCase(lh, rec, test.Case{
+				Qname: fmt.Sprintf("%s.%s.svc.remote.clusterset.local.", service1, namespace1),
+				Qtype: dns.TypeA,
+				Rcode: dns.RcodeServerFailure,
+			})
+		})
+	})
+
+	When("a query is made for a name outside the forwarded zones", func() {
+		It("should answer it", func() {
+			qname := fmt.Sprintf("%s.%s.svc.clusterset.local.", service1, namespace1)
+			executeTestCase(lh, rec, test.Case{
+				Qname: qname,
+				Qtype: dns.TypeA,
+				Rcode: dns.RcodeSuccess,
+				Answer: []dns.RR{
+					test.A(fmt.Sprintf("%s    5    IN    A    %s", qname, serviceIP)),
+				},
+			})
+		})
+	})
+}
+
+func testRateLimit() {
+	var (
+		rec *dnstest.Recorder
+		lh  *Lighthouse
+	)
+
+	BeforeEach(func() {
+		mockCs := NewMockClusterStatus()
+		mockCs.clusterStatusMap[clusterID] = true
+		mockEs := NewMockEndpointStatus()
+		mockEs.endpointStatusMap[clusterID] = true
+
+		lh = &Lighthouse{
+			Zones:           []string{"clusterset.local."},
+			serviceImports:  setupServiceImportMap(),
+			endpointSlices:  setupEndpointSliceMap(),
+			clusterStatus:   mockCs,
+			endpointsStatus: mockEs,
+			localServices:   NewMockLocalServices(),
+			ttl:             defaultTTL,
+			limiter:         newQueryLimiter(),
+		}
+
+		lh.limiter.setClientLimit(0.001, 1)
+
+		rec = dnstest.NewRecorder(&test.ResponseWriter{})
+	})
+
+	When("a client exceeds the rate limit", func() {
+		It("should refuse its queries", func() {
+			qname := fmt.Sprintf("%s.%s.svc.clusterset.local.", service1, namespace1)
+			executeTestCase(lh, rec, test.Case{
+				Qname: qname,
+				Qtype: dns.TypeA,
+				Rcode: dns.RcodeSuccess,
+				Answer: []dns.RR{
+					test.A(fmt.Sprintf("%s    5    IN    A    %s", qname, serviceIP)),
+				},
+			})
+
+			executeTestCase(lh, rec, test.Case{
+				Qname: qname,
+				Qtype: dns.TypeA,
+				Rcode: dns.RcodeRefused,
+			})
+		})
+	})
+}
+
+func testMultipleIPs() {
+	const (
+		serviceIPv6 = "fd00:100:96::101"
+		qname       = "service1.namespace1.svc.clusterset.local."
+	)
+
+	var (
+		rec *dnstest.Recorder
+		lh  *Lighthouse
+	)
+
+	BeforeEach(func() {
+		mockCs := NewMockClusterStatus()
+		mockCs.clusterStatusMap[clusterID] = true
+		mockEs := NewMockEndpointStatus()
+		mockEs.endpointStatusMap[clusterID] = true
+
+		si := newServiceImport(namespace1, service1, clusterID, serviceIP, portName1, portNumber1, protocol1, mcsv1a1.ClusterSetIP)
+		si.Spec.IPs = []string{serviceIP, serviceIPv6, serviceIP2}
+
+		lh = &Lighthouse{
+			Zones:           []string{"clusterset.local."},
+			serviceImports:  serviceimport.NewMap(),
+			endpointSlices:  setupEndpointSliceMap(),
+			clusterStatus:   mockCs,
+			endpointsStatus: mockEs,
+			localServices:   NewMockLocalServices(),
+			ttl:             defaultTTL,
+		}
+
+		lh.serviceImports.Put(si)
+
+		rec = dnstest.NewRecorder(&test.ResponseWriter{})
+	})
+
+	When("a type A DNS query is made", func() {
+		It("should return all the IPv4 addresses", func() {
+			executeTestCase(lh, rec, test.Case{
+				Qname: qname,
+				Qtype: dns.TypeA,
+				Rcode: dns.RcodeSuccess,
+				Answer: []dns.RR{
+					test.A(fmt.Sprintf("%s    5    IN    A    %s", qname, serviceIP)),
+					test.A(fmt.Sprintf("%s    5    IN    A    %s", qname, serviceIP2)),
+				},
+			})
+		})
+	})
+
+	When("a type AAAA DNS query is made", func() {
+		It("should return the IPv6 address", func() {
+			executeTestCase(lh, rec, test.Case{
+				Qname: qname,
+				Qtype: dns.TypeAAAA,
+				Rcode: dns.RcodeSuccess,
+				Answer: []dns.RR{
+					test.AAAA(fmt.Sprintf("%s    5    IN    AAAA    %s", qname, serviceIPv6)),
+				},
+			})
+		})
+	})
+
+	When("a type SRV DNS query is made", func() {
+		It("should return all the addresses of the target", func() {
+			executeTestCase(lh, rec, test.Case{
+				Qname: qname,
+				Qtype: dns.TypeSRV,
+				Rcode: dns.RcodeSuccess,
+				Answer: []dns.RR{
+					test.SRV(fmt.Sprintf("%s    5    IN    SRV 0 50 %d %s", qname, portNumber1, qname)),
+				},
+				Extra: []dns.RR{
+					test.A(fmt.Sprintf("%s    5    IN    A    %s", qname, serviceIP)),
+					test.AAAA(fmt.Sprintf("%s    5    IN    AAAA    %s", qname, serviceIPv6)),
+					test.A(fmt.Sprintf("%s    5    IN    A    %s", qname, serviceIP2)),
+				},
+			})
+		})
+	})
+}
+
+func testAppProtocol() {
+	var (
+		rec *dnstest.Recorder
+		lh  *Lighthouse
+	)
+
+	BeforeEach(func() {
+		mockCs := NewMockClusterStatus()
+		mockCs.clusterStatusMap[clusterID] = true
+		mockEs := NewMockEndpointStatus()
+		mockEs.endpointStatusMap[clusterID] = true
+
+		si := newServiceImport(namespace1, service1, clusterID, serviceIP, portName1, portNumber1, protocol1, mcsv1a1.ClusterSetIP)
+		appProtocol := "kubernetes.io/h2c"
+		si.Spec.Ports[0].AppProtocol = &appProtocol
+
+		lh = &Lighthouse{
+			Zones:           []string{"clusterset.local."},
+			serviceImports:  serviceimport.NewMap(),
+			endpointSlices:  setupEndpointSliceMap(),
+			clusterStatus:   mockCs,
+			endpointsStatus: mockEs,
+			localServices:   NewMockLocalServices(),
+			ttl:             defaultTTL,
+		}
+
+		lh.serviceImports.Put(si)
+
+		rec = dnstest.NewRecorder(&test.ResponseWriter{})
+	})
+
+	expectSRV := func(protocol string) {
+		qname := fmt.Sprintf("_%s._%s.%s.%s.svc.clusterset.local.", portName1, protocol, service1, namespace1)
+		executeTestCase(lh, rec, test.Case{
+			Qname: qname,
+			Qtype: dns.TypeSRV,
+			Rcode: dns.RcodeSuccess,
+			Answer: []dns.RR{
+				test.SRV(fmt.Sprintf("%s    5    IN    SRV 0 50 %d %s.%s.svc.clusterset.local.", qname, portNumber1, service1,
+					namespace1)),
+			},
+			Extra: []dns.RR{
+				test.A(fmt.Sprintf("%s.%s.svc.clusterset.local.    5    IN    A    %s", service1, namespace1, serviceIP)),
+			},
+		})
+	}
+
+	When("an SRV query names the port's application protocol", func() {
+		It("should match the port", func() {
+			expectSRV("h2c")
+		})
+	})
+
+	When("an SRV query names the port's L4 protocol", func() {
+		It("should match the port", func() {
+			expectSRV("tcp")
+		})
+	})
+
+	When("an SRV query names another protocol", func() {
+		It("should return NXDOMAIN", func() {
+			executeTestCase(lh, rec, test.Case{
+				Qname: fmt.Sprintf("_%s._grpc.%s.%s.svc.clusterset.local.", portName1, service1, namespace1),
+				Qtype: dns.TypeSRV,
+				Rcode: dns.RcodeNameError,
+			})
+		})
+	})
+}
+
+func testWildcard() {
+	const (
+		service2 = "service2"
+		qname    = "*.namespace1.svc.clusterset.local."
+	)
+
+	var (
+		rec *dnstest.Recorder
+		lh  *Lighthouse
+	)
+
+	BeforeEach(func() {
+		mockCs := NewMockClusterStatus()
+		mockCs.clusterStatusMap[clusterID] = true
+		mockEs := NewMockEndpointStatus()
+		mockEs.endpointStatusMap[clusterID] = true
+
+		lh = &Lighthouse{
+			Zones:           []string{"clusterset.local."},
+			serviceImports:  setupServiceImportMap(),
+			endpointSlices:  setupEndpointSliceMap(),
+			clusterStatus:   mockCs,
+			endpointsStatus: mockEs,
+			localServices:   NewMockLocalServices(),
+			ttl:             defaultTTL,
+			wildcardLimit:   defaultWildcardLimit,
+		}
+
+		lh.serviceImports.Put(newServiceImport(namespace1, service2, clusterID, serviceIP2, portName1, portNumber1, protocol1,
+			mcsv1a1.ClusterSetIP))
+
+		rec = dnstest.NewRecorder(&test.ResponseWriter{})
+	})
+
+	When("a type A DNS query is made", func() {
+		It("should return the records of all the services in the namespace", func() {
+			executeTestCase(lh, rec, test.Case{
+				Qname: qname,
+				Qtype: dns.TypeA,
+				Rcode: dns.RcodeSuccess,
+				Answer: []dns.RR{
+					test.A(fmt.Sprintf("%s.%s.svc.clusterset.local.    5    IN    A    %s", service1, namespace1, serviceIP)),
+					test.A(fmt.Sprintf("%s.%s.svc.clusterset.local.    5    IN    A    %s", service2, namespace1, serviceIP2)),
+				},
+			})
+		})
+	})
+
+	When("a type SRV DNS query is made", func() {
+		It("should return the SRV records of all the services in the namespace", func() {
+			executeTestCase(lh, rec, test.Case{
+				Qname: qname,
+				Qtype: dns.TypeSRV,
+				Rcode: dns.RcodeSuccess,
+				Answer: []dns.RR{
+					test.SRV(fmt.Sprintf("%s.%s.svc.clusterset.local.    5    IN    SRV 0 50 %d %s.%s.svc.clusterset.local.",
+						service1, namespace1, portNumber1, service1, namespace1)),
+					test.SRV(fmt.Sprintf("%s.%s.svc.clusterset.local.    5    IN    SRV 0 50 %d %s.%s.svc.clusterset.local.",
+						service2, namespace1, portNumber1, service2, namespace1)),
+				},
+				Extra: []dns.RR{
+					test.A(fmt.Sprintf("%s.%s.svc.clusterset.local.    5    IN    A    %s", service1, namespace1, serviceIP)),
+					test.A(fmt.Sprintf("%s.%s.svc.clusterset.local.    5    IN    A    %s", service2, namespace1, serviceIP2)),
+				},
+			})
+		})
+	})
+
+	When("the number of services exceeds the limit", func() {
+		BeforeEach(func() {
+			lh.wildcardLimit = 1
+		})
+
+		It("should only return the records of the first services", func() {
+			executeTestCase(lh, rec, test.Case{
+				Qname: qname,
+				Qtype: dns.TypeA,
+				Rcode: dns.RcodeSuccess,
+				Answer: []dns.RR{
+					test.A(fmt.Sprintf("%s.%s.svc.clusterset.local.    5    IN    A    %s", service1, namespace1, serviceIP)),
+				},
+			})
+		})
+	})
+
+	When("the namespace has no services", func() {
+		It("should return NXDOMAIN", func() {
+			executeTestCase(lh, rec, test.Case{
+				Qname: "*.namespace2.svc.clusterset.local.",
+				Qtype: dns.TypeA,
+				Rcode: dns.RcodeNameError,
+			})
+		})
+	})
+
+	When("wildcard queries are disabled", func() {
+		BeforeEach(func() {
+			lh.wildcardLimit = 0
+		})
+
+		It("should return NXDOMAIN", func() {
+			executeTestCase(lh, rec, test.Case{
+				Qname: qname,
+				Qtype: dns.TypeA,
+				Rcode: dns.RcodeNameError,
+			})
+		})
+	})
+}
+
+func testAnyQueries() {
+	qname := fmt.Sprintf("%s.%s.svc.clusterset.local.", service1, namespace1)
+
+	var (
+		rec *dnstest.Recorder
+		lh  *Lighthouse
+	)
+
+	BeforeEach(func() {
+		mockCs := NewMockClusterStatus()
+		mockCs.clusterStatusMap[clusterID] = true
+		mockEs := NewMockEndpointStatus()
+		mockEs.endpointStatusMap[clusterID] = true
+
+		lh = &Lighthouse{
+			Zones:           []string{"clusterset.local."},
+			serviceImports:  setupServiceImportMap(),
+			endpointSlices:  setupEndpointSliceMap(),
+			clusterStatus:   mockCs,
+			endpointsStatus: mockEs,
+			localServices:   NewMockLocalServices(),
+			ttl:             defaultTTL,
+		}
+
+		rec = dnstest.NewRecorder(&test.ResponseWriter{})
+	})
+
+	When("a type ANY DNS query is made", func() {
+		It("should return the RFC 8482 HINFO record", func() {
+			code, err := lh.ServeDNS(context.TODO(), rec, test.Case{Qname: qname, Qtype: dns.TypeANY}.Msg())
+			Expect(err).To(Succeed())
+			Expect(code).To(Equal(dns.RcodeSuccess))
+			Expect(rec.Msg.Answer).To(HaveLen(1))
+			Expect(rec.Msg.Answer[0].String()).To(Equal(fmt.Sprintf("%s\t5\tIN\tHINFO\t\"RFC8482\" \"\"", qname)))
+		})
+	})
+
+	When("a type ANY DNS query is made with the types to answer configured", func() {
+		BeforeEach(func() {
+			lh.anyTypes = []uint16{dns.TypeA, dns.TypeSRV}
+		})
+
+		It("should return the records of the configured types", func() {
+			executeTestCase(lh, rec, test.Case{
+				Qname: qname,
+				Qtype: dns.TypeANY,
+				Rcode: dns.RcodeSuccess,
+				Answer: []dns.RR{
+					test.A(fmt.Sprintf("%s    5    IN    A    %s", qname, serviceIP)),
+					test.SRV(fmt.Sprintf("%s    5    IN    SRV 0 50 %d %s", qname, portNumber1, qname)),
+				},
+				Extra: []dns.RR{
+					test.A(fmt.Sprintf("%s    5    IN    A    %s", qname, serviceIP)),
+				},
+			})
+		})
+	})
+
+	When("a type ANY DNS query is made for a non-existent service", func() {
+		It("should return NXDOMAIN", func() {
+			executeTestCase(lh, rec, test.Case{
+				Qname: "unknown.namespace1.svc.clusterset.local.",
+				Qtype: dns.TypeANY,
+				Rcode: dns.RcodeNameError,
+			})
+		})
+	})
+}
+
+func testClusterSelection() {
+	qname := fmt.Sprintf("%s.%s.svc.clusterset.local.", service1, namespace1)
+
+	var (
+		rec    *dnstest.Recorder
+		lh     *Lighthouse
+		mockEs *MockEndpointStatus
+	)
+
+	BeforeEach(func() {
+		mockCs := NewMockClusterStatus()
+		mockCs.clusterStatusMap[clusterID] = true
+		mockCs.clusterStatusMap[clusterID2] = true
+		mockCs.localClusterID = clusterID
+		mockEs = NewMockEndpointStatus()
+		mockEs.endpointStatusMap[clusterID] = true
+		mockEs.endpointStatusMap[clusterID2] = true
+		mockLs := NewMockLocalServices()
+		mockLs.LocalServicesMap[getKey(service1, namespace1)] = &serviceimport.DNSRecord{
+			IP:          serviceIP,
+			ClusterName: clusterID,
+		}
+
+		lh = &Lighthouse{
+			Zones:           []string{"clusterset.local."},
+			serviceImports:  setupServiceImportMap(),
+			endpointSlices:  setupEndpointSliceMap(),
+			clusterStatus:   mockCs,
+			endpointsStatus: mockEs,
+			localServices:   mockLs,
+			ttl:             defaultTTL,
+		}
+		lh.serviceImports.Put(newServiceImport(namespace1, service1, clusterID2, serviceIP2, portName1, portNumber1,
+			protocol1, mcsv1a1.ClusterSetIP))
+
+		rec = dnstest.NewRecorder(&test.ResponseWriter{})
+	})
+
+	answeredIPs := func() []string {
+		ips := []string{}
+
+		for i := 0; i < 2; i++ {
+			code, err := lh.ServeDNS(context.TODO(), rec, test.Case{Qname: qname, Qtype: dns.TypeA}.Msg())
+			Expect(err).To(Succeed())
+			Expect(code).To(Equal(dns.RcodeSuccess))
+
+			for _, rr := range rec.Msg.Answer {
+				ips = append(ips, rr.(*dns.A).A.String())
+			}
+		}
+
+		return ips
+	}
+
+	When("the local-first policy is configured", func() {
+		BeforeEach(func() {
+			lh.clusterSelection = localFirstSelection
+		})
+
+		It("should only answer with the local cluster", func() {
+			Expect(answeredIPs()).To(Equal([]string{serviceIP, serviceIP}))
+		})
+	})
+
+	When("the round-robin policy is configured", func() {
+		BeforeEach(func() {
+			lh.clusterSelection = roundRobinSelection
+		})
+
+		It("should answer with each cluster in turn", func() {
+			Expect(answeredIPs()).To(ConsistOf(serviceIP, serviceIP2))
+		})
+	})
+
+	When("the all policy is configured", func() {
+		BeforeEach(func() {
+			lh.clusterSelection = allSelection
+		})
+
+		It("should answer with all the clusters", func() {
+			executeTestCase(lh, rec, test.Case{
+				Qname: qname,
+				Qtype: dns.TypeA,
+				Rcode: dns.RcodeSuccess,
+				Answer: []dns.RR{
+					test.A(fmt.Sprintf("%s    5    IN    A    %s", qname, serviceIP)),
+					test.A(fmt.Sprintf("%s    5    IN    A    %s", qname, serviceIP2)),
+				},
+			})
+		})
+	})
+
+	When("the remote-only-on-unhealthy policy is configured", func() {
+		BeforeEach(func() {
+			lh.clusterSelection = remoteOnUnhealthySelection
+		})
+
+		It("should answer with the local cluster while it's healthy", func() {
+			Expect(answeredIPs()).To(Equal([]string{serviceIP, serviceIP}))
+		})
+
+		Context("and the local service is unhealthy", func() {
+			BeforeEach(func() {
+				mockEs.endpointStatusMap[clusterID] = false
+			})
+
+			It("should answer with the remote cluster", func() {
+				Expect(answeredIPs()).To(Equal([]string{serviceIP2, serviceIP2}))
+			})
+		})
+
+		Context("and the local cluster doesn't export the service", func() {
+			BeforeEach(func() {
+				lh.serviceImports = serviceimport.NewMap()
+				lh.serviceImports.Put(newServiceImport(namespace1, service1, clusterID2, serviceIP2, portName1, portNumber1,
+					protocol1, mcsv1a1.ClusterSetIP))
+			})
+
+			It("should return NODATA", func() {
+				executeTestCase(lh, rec, test.Case{
+					Qname: qname,
+					Qtype: dns.TypeA,
+					Rcode: dns.RcodeSuccess,
+					Ns:    []dns.RR{soaRecord("clusterset.local.")},
+				})
+			})
+		})
+	})
+
+	When("all the clusters are requested", func() {
+		It("should answer with all the clusters", func() {
+			allQname := fmt.Sprintf("all.%s.%s.svc.clusterset.local.", service1, namespace1)
+			executeTestCase(lh, rec, test.Case{
+				Qname: allQname,
+				Qtype: dns.TypeA,
+				Rcode: dns.RcodeSuccess,
+				Answer: []dns.RR{
+					test.A(fmt.Sprintf("%s    5    IN    A    %s", allQname, serviceIP)),
+					test.A(fmt.Sprintf("%s    5    IN    A    %s", allQname, serviceIP2)),
+				},
+			})
+		})
+	})
+
+	When("the service requests a policy", func() {
+		BeforeEach(func() {
+			si := newServiceImport(namespace1, service1, clusterID2, serviceIP2, portName1, portNumber1, protocol1,
+				mcsv1a1.ClusterSetIP)
+			si.Annotations[lhconstants.ClusterSelection] = allSelection
+			lh.serviceImports.Put(si)
+		})
+
+		It("should override the configured policy", func() {
+			executeTestCase(lh, rec, test.Case{
+				Qname: qname,
+				Qtype: dns.TypeA,
+				Rcode: dns.RcodeSuccess,
+				Answer: []dns.RR{
+					test.A(fmt.Sprintf("%s    5    IN    A    %s", qname, serviceIP)),
+					test.A(fmt.Sprintf("%s    5    IN    A    %s", qname, serviceIP2)),
+				},
+			})
+		})
+	})
+}
+
+func testServeStale() {
+	qname := fmt.Sprintf("%s.%s.svc.clusterset.local.", service1, namespace1)
+
+	var (
+		rec               *dnstest.Recorder
+		lh                *Lighthouse
+		disconnectedSince time.Time
+	)
+
+	BeforeEach(func() {
+		mockCs := NewMockClusterStatus()
+		mockCs.clusterStatusMap[clusterID] = true
+		mockEs := NewMockEndpointStatus()
+		mockEs.endpointStatusMap[clusterID] = true
+		disconnectedSince = time.Time{}
+
+		lh = &Lighthouse{
+			Zones:           []string{"clusterset.local."},
+			serviceImports:  setupServiceImportMap(),
+			endpointSlices:  setupEndpointSliceMap(),
+			clusterStatus:   mockCs,
+			endpointsStatus: mockEs,
+			localServices:   NewMockLocalServices(),
+			ttl:             defaultTTL,
+			stale: &serveStale{window: time.Hour, ttl: defaultStaleTTL, sources: []func() (time.Time, bool){
+				func() (time.Time, bool) {
+					return disconnectedSince, !disconnectedSince.IsZero()
+				},
+			}},
+		}
+
+		rec = dnstest.NewRecorder(&test.ResponseWriter{})
+	})
+
+	When("the ServiceImports and EndpointSlices are up to date", func() {
+		It("should answer with the configured TTL", func() {
+			executeTestCase(lh, rec, test.Case{
+				Qname: qname,
+				Qtype: dns.TypeA,
+				Rcode: dns.RcodeSuccess,
+				Answer: []dns.RR{
+					test.A(fmt.Sprintf("%s    5    IN    A    %s", qname, serviceIP)),
+				},
+			})
+		})
+	})
+
+	When("the ServiceImports and EndpointSlices are stale within the window", func() {
+		BeforeEach(func() {
+			disconnectedSince = time.Now().Add(-time.Minute)
+		})
+
+		It("should answer with the stale TTL", func() {
+			executeTestCase(lh, rec, test.Case{
+				Qname: qname,
+				Qtype: dns.TypeA,
+				Rcode: dns.RcodeSuccess,
+				Answer: []dns.RR{
+					test.A(fmt.Sprintf("%s    30    IN    A    %s", qname, serviceIP)),
+				},
+			})
+		})
+	})
+
+	When("the ServiceImports and EndpointSlices have been stale for longer than the window", func() {
+		BeforeEach(func() {
+			disconnectedSince = time.Now().Add(-2 * time.Hour)
+		})
+
+		It("should return SERVFAIL", func() {
+			executeTestCase(lh, rec, test.Case{
+				Qname: qname,
+				Qtype: dns.TypeA,
+				Rcode: dns.RcodeServerFailure,
+			})
+		})
+	})
+}
+
+func testSRVPriorities() {
+	var (
+		rec *dnstest.Recorder
+		lh  *Lighthouse
+	)
+
+	BeforeEach(func() {
+		mockCs := NewMockClusterStatus()
+		mockCs.clusterStatusMap[clusterID] = true
+		mockCs.clusterStatusMap[clusterID2] = true
+		mockCs.localClusterID = clusterID
+		mockEs := NewMockEndpointStatus()
+		mockEs.endpointStatusMap[clusterID] = true
+		mockEs.endpointStatusMap[clusterID2] = true
+
+		lh = &Lighthouse{
+			Zones:             []string{"clusterset.local."},
+			serviceImports:    setupServiceImportMap(),
+			endpointSlices:    setupEndpointSliceMap(),
+			clusterStatus:     mockCs,
+			endpointsStatus:   mockEs,
+			localServices:     NewMockLocalServices(),
+			ttl:               defaultTTL,
+			srvLocalPriority:  defaultSRVLocalPriority,
+			srvRemotePriority: defaultSRVRemotePriority,
+		}
+		lh.serviceImports.Put(newServiceImport(namespace1, service1, clusterID2, serviceIP2, portName1, portNumber1, protocol1,
+			mcsv1a1.ClusterSetIP))
+
+		rec = dnstest.NewRecorder(&test.ResponseWriter{})
+	})
+
+	When("an SRV query is answered with the local cluster", func() {
+		qname := fmt.Sprintf("%s.%s.%s.svc.clusterset.local.", clusterID, service1, namespace1)
+
+		It("should return the local priority", func() {
+			executeTestCase(lh, rec, test.Case{
+				Qname: qname,
+				Qtype: dns.TypeSRV,
+				Rcode: dns.RcodeSuccess,
+				Answer: []dns.RR{
+					test.SRV(fmt.Sprintf("%s    5    IN    SRV 0 50 %d %s", qname, portNumber1, qname)),
+				},
+				Extra: []dns.RR{
+					test.A(fmt.Sprintf("%s    5    IN    A    %s", qname, serviceIP)),
+				},
+			})
+		})
+	})
+
+	When("an SRV query is answered with a remote cluster", func() {
+		qname := fmt.Sprintf("%s.%s.%s.svc.clusterset.local.", clusterID2, service1, namespace1)
+
+		It("should return the remote priority", func() {
+			executeTestCase(lh, rec, test.Case{
+				Qname: qname,
+				Qtype: dns.TypeSRV,
+				Rcode: dns.RcodeSuccess,
+				Answer: []dns.RR{
+					test.SRV(fmt.Sprintf("%s    5    IN    SRV 10 50 %d %s", qname, portNumber1, qname)),
+				},
+				Extra: []dns.RR{
+					test.A(fmt.Sprintf("%s    5    IN    A    %s", qname, serviceIP2)),
+				},
+			})
+		})
+	})
+}
+
+func testTopology() {
+	qname := fmt.Sprintf("%s.%s.svc.clusterset.local.", service1, namespace1)
+
+	var (
+		rec *dnstest.Recorder
+		lh  *Lighthouse
+	)
+
+	BeforeEach(func() {
+		mockCs := NewMockClusterStatus()
+		mockCs.clusterStatusMap[clusterID] = true
+		mockEs := NewMockEndpointStatus()
+		mockEs.endpointStatusMap[clusterID] = true
+
+		lh = &Lighthouse{
+			Zones:           []string{"clusterset.local."},
+			serviceImports:  serviceimport.NewMap(),
+			endpointSlices:  endpointslice.NewMap(),
+			clusterStatus:   mockCs,
+			endpointsStatus: mockEs,
+			localServices:   NewMockLocalServices(),
+			ttl:             defaultTTL,
+			topology:        &topologyPreference{zone: "zone1", region: "region1"},
+		}
+
+		lh.serviceImports.Put(newServiceImport(namespace1, service1, clusterID, "", portName1, portNumber1, protocol1,
+			mcsv1a1.Headless))
+
+		rec = dnstest.NewRecorder(&test.ResponseWriter{})
+	})
+
+	putEndpoints := func(topologies ...map[string]string) {
+		es := newEndpointSlice(namespace1, service1, clusterID, portName1, []string{hostName1, hostName2},
+			[]string{endpointIP, endpointIP2}, portNumber1, protocol1)
+		for i := range topologies {
+			es.Endpoints[i].Topology = topologies[i]
+		}
+
+		lh.endpointSlices.Put(es)
+	}
+
+	When("an endpoint is in the same zone", func() {
+		It("should only return the endpoints in the zone", func() {
+			putEndpoints(map[string]string{v1.LabelZoneFailureDomainStable: "zone1", v1.LabelZoneRegionStable: "region1"},
+				map[string]string{v1.LabelZoneFailureDomainStable: "zone2", v1.LabelZoneRegionStable: "region1"})
+
+			executeTestCase(lh, rec, test.Case{
+				Qname: qname,
+				Qtype: dns.TypeA,
+				Rcode: dns.RcodeSuccess,
+				Answer: []dns.RR{
+					test.A(fmt.Sprintf("%s    5    IN    A    %s", qname, endpointIP)),
+				},
+			})
+		})
+	})
+
+	When("no endpoint is in the same zone but one is in the same region", func() {
+		It("should only return the endpoints in the region", func() {
+			putEndpoints(map[string]string{v1.LabelZoneFailureDomainStable: "zone3", v1.LabelZoneRegionStable: "region2"},
+				map[string]string{v1.LabelZoneFailureDomainStable: "zone2", v1.LabelZoneRegionStable: "region1"})
+
+			executeTestCase(lh, rec, test.Case{
+				Qname: qname,
+				Qtype: dns.TypeA,
+				Rcode: dns.RcodeSuccess,
+				Answer: []dns.RR{
+					test.A(fmt.Sprintf("%s    5    IN    A    %s", qname, endpointIP2)),
+				},
+			})
+		})
+	})
+
+	When("the endpoints have no topology", func() {
+		It("should return all the endpoints", func() {
+			putEndpoints()
+
+			executeTestCase(lh, rec, test.Case{
+				Qname: qname,
+				Qtype: dns.TypeA,
+				Rcode: dns.RcodeSuccess,
+				Answer: []dns.RR{
+					test.A(fmt.Sprintf("%s    5    IN    A    %s", qname, endpointIP)),
+					test.A(fmt.Sprintf("%s    5    IN    A    %s", qname, endpointIP2)),
+				},
+			})
+		})
+	})
+}
+
+func testAliases() {
+	const alias = "alias1"
+
+	qname := fmt.Sprintf("%s.%s.svc.clusterset.local.", alias, namespace1)
+
+	var (
+		rec *dnstest.Recorder
+		lh  *Lighthouse
+	)
+
+	BeforeEach(func() {
+		mockCs := NewMockClusterStatus()
+		mockCs.clusterStatusMap[clusterID] = true
+		mockEs := NewMockEndpointStatus()
+		mockEs.endpointStatusMap[clusterID] = true
+
+		si := newServiceImport(namespace1, service1, clusterID, serviceIP, portName1, portNumber1, protocol1, mcsv1a1.ClusterSetIP)
+		si.Annotations[lhconstants.Aliases] = alias + ", other"
+
+		lh = &Lighthouse{
+			Zones:           []string{"clusterset.local."},
+			serviceImports:  serviceimport.NewMap(),
+			endpointSlices:  setupEndpointSliceMap(),
+			clusterStatus:   mockCs,
+			endpointsStatus: mockEs,
+			localServices:   NewMockLocalServices(),
+			ttl:             defaultTTL,
+		}
+		lh.serviceImports.Put(si)
+
+		rec = dnstest.NewRecorder(&test.ResponseWriter{})
+	})
+
+	When("a query is made for an alias of a service", func() {
+		It("should answer with the service's records", func() {
+			executeTestCase(lh, rec, test.Case{
+				Qname: qname,
+				Qtype: dns.TypeA,
+				Rcode: dns.RcodeSuccess,
+				Answer: []dns.RR{
+					test.A(fmt.Sprintf("%s    5    IN    A    %s", qname, serviceIP)),
+				},
+			})
+		})
+	})
+
+	When("a query is made for an alias of a service and aliases are answered with CNAMEs", func() {
+		BeforeEach(func() {
+			lh.aliasMode = cnameAliasMode
+		})
+
+		It("should answer with a CNAME to the service followed by its records", func() {
+			canonical := fmt.Sprintf("%s.%s.svc.clusterset.local.", service1, namespace1)
+
+			executeTestCase(lh, rec, test.Case{
+				Qname: qname,
+				Qtype: dns.TypeA,
+				Rcode: dns.RcodeSuccess,
+				Answer: []dns.RR{
+					test.CNAME(fmt.Sprintf("%s    5    IN    CNAME    %s", qname, canonical)),
+					test.A(fmt.Sprintf("%s    5    IN    A    %s", canonical, serviceIP)),
+				},
+			})
+		})
+	})
+
+	When("a query is made for a name which isn't an alias", func() {
+		It("should return NXDOMAIN", func() {
+			executeTestCase(lh, rec, test.Case{
+				Qname: fmt.Sprintf("unknown.%s.svc.clusterset.local.", namespace1),
+				Qtype: dns.TypeA,
+				Rcode: dns.RcodeNameError,
+			})
+		})
+	})
+}
+
+func testClusterAliases() {
+	const alias = "east"
+
+	var (
+		rec *dnstest.Recorder
+		lh  *Lighthouse
+	)
+
+	BeforeEach(func() {
+		mockCs := NewMockClusterStatus()
+		mockCs.clusterStatusMap[clusterID] = true
+		mockEs := NewMockEndpointStatus()
+		mockEs.endpointStatusMap[clusterID] = true
+
+		lh = &Lighthouse{
+			Zones:           []string{"clusterset.local."},
+			serviceImports:  setupServiceImportMap(),
+			endpointSlices:  setupEndpointSliceMap(),
+			clusterStatus:   mockCs,
+			endpointsStatus: mockEs,
+			localServices:   NewMockLocalServices(),
+			ttl:             defaultTTL,
+			clusterAliases:  map[string]string{alias: clusterID},
+		}
+
+		rec = dnstest.NewRecorder(&test.ResponseWriter{})
+	})
+
+	When("a query is made for a service in a cluster given by its alias", func() {
+		It("should answer with the service's records in that cluster", func() {
+			qname := fmt.Sprintf("%s.%s.%s.svc.clusterset.local.", alias, service1, namespace1)
+
+			executeTestCase(lh, rec, test.Case{
+				Qname: qname,
+				Qtype: dns.TypeA,
+				Rcode: dns.RcodeSuccess,
+				Answer: []dns.RR{
+					test.A(fmt.Sprintf("%s    5    IN    A    %s", qname, serviceIP)),
+				},
+			})
+		})
+	})
+
+	When("a query is made for a service in a cluster given by its ID", func() {
+		It("should still answer with the service's records in that cluster", func() {
+			qname := fmt.Sprintf("%s.%s.%s.svc.clusterset.local.", clusterID, service1, namespace1)
+
+			executeTestCase(lh, rec, test.Case{
+				Qname: qname,
+				Qtype: dns.TypeA,
+				Rcode: dns.RcodeSuccess,
+				Answer: []dns.RR{
+					test.A(fmt.Sprintf("%s    5    IN    A    %s", qname, serviceIP)),
+				},
+			})
+		})
+	})
+}
+
+func testNegativeCache() {
+	qname := fmt.Sprintf("%s.%s.svc.clusterset.local.", service1, namespace2)
+
+	var (
+		rec *dnstest.Recorder
+		lh  *Lighthouse
+	)
+
+	BeforeEach(func() {
+		mockCs := NewMockClusterStatus()
+		mockCs.clusterStatusMap[clusterID] = true
+		mockEs := NewMockEndpointStatus()
+		mockEs.endpointStatusMap[clusterID] = true
+
+		lh = &Lighthouse{
+			Zones:           []string{"clusterset.local."},
+			serviceImports:  setupServiceImportMap(),
+			endpointSlices:  setupEndpointSliceMap(),
+			clusterStatus:   mockCs,
+			endpointsStatus: mockEs,
+			localServices:   NewMockLocalServices(),
+			ttl:             defaultTTL,
+			negative:        newNegativeCache(),
+		}
+
+		rec = dnstest.NewRecorder(&test.ResponseWriter{})
+
+		executeTestCase(lh, rec, test.Case{
+			Qname: qname,
+			Qtype: dns.TypeA,
+			Rcode: dns.RcodeNameError,
+		})
+	})
+
+	When("a service which isn't imported is queried again", func() {
+		It("should answer NXDOMAIN from the negative cache", func() {
+			_, found := lh.negative.get(namespace2, qname, dns.TypeA)
+			Expect(found).To(BeTrue())
+
+			executeTestCase(lh, rec, test.Case{
+				Qname: qname,
+				Qtype: dns.TypeA,
+				Rcode: dns.RcodeNameError,
+			})
+		})
+	})
+
+	When("the service is imported after it was queried", func() {
+		BeforeEach(func() {
+			store := &serviceImportStore{Store: lh.serviceImports, hints: &evictionHints{}, negative: lh.negative}
+			store.Put(newServiceImport(namespace2, service1, clusterID, serviceIP, portName1, portNumber1, protocol1,
+				mcsv1a1.ClusterSetIP))
+		})
+
+		It("should answer with its records", func() {
+			executeTestCase(lh, rec, test.Case{
+				Qname: qname,
+				Qtype: dns.TypeA,
+				Rcode: dns.RcodeSuccess,
+				Answer: []dns.RR{
+					test.A(fmt.Sprintf("%s    5    IN    A    %s", qname, serviceIP)),
+				},
+			})
+		})
+	})
+
+	When("a record type which the service doesn't have is queried", func() {
+		It("should answer NODATA with the negative TTL", func() {
+			qname := fmt.Sprintf("%s.%s.svc.clusterset.local.", service1, namespace1)
+
+			for i := 0; i < 2; i++ {
+				executeTestCase(lh, rec, test.Case{
+					Qname: qname,
+					Qtype: dns.TypeAAAA,
+					Rcode: dns.RcodeSuccess,
+					Ns: []dns.RR{
+						test.SOA("clusterset.local.    30    IN    SOA    ns.dns.clusterset.local. hostmaster.clusterset.local. " +
+							"0 7200 1800 86400 30"),
+					},
+				})
+			}
+
+			_, found := lh.negative.get(namespace1, qname, dns.TypeAAAA)
+			Expect(found).To(BeTrue())
+		})
+	})
+}
+
+func testDisconnectedClusters() {
+	qname := fmt.Sprintf("%s.%s.svc.clusterset.local.", service1, namespace1)
+
+	var (
+		rec *dnstest.Recorder
+		lh  *Lighthouse
+	)
+
+	BeforeEach(func() {
+		mockCs := NewMockClusterStatus()
+		mockCs.clusterStatusMap[clusterID] = false
+		mockEs := NewMockEndpointStatus()
+		mockEs.endpointStatusMap[clusterID] = true
+
+		lh = &Lighthouse{
+			Zones:           []string{"clusterset.local."},
+			serviceImports:  setupServiceImportMap(),
+			endpointSlices:  setupEndpointSliceMap(),
+			clusterStatus:   mockCs,
+			endpointsStatus: mockEs,
+			localServices:   NewMockLocalServices(),
+			ttl:             defaultTTL,
+		}
+
+		rec = dnstest.NewRecorder(&test.ResponseWriter{})
+	})
+
+	When("the service's only cluster is disconnected and the policy is to drop disconnected clusters", func() {
+		BeforeEach(func() {
+			lh.disconnectedPolicy = dropDisconnected
+		})
+
+		It("should return NODATA", func() {
+			executeTestCase(lh, rec, test.Case{
+				Qname:  qname,
+				Qtype:  dns.TypeA,
+				Rcode:  dns.RcodeSuccess,
+				Answer: []dns.RR{},
+				Ns:     []dns.RR{soaRecord("clusterset.local.")},
+			})
+		})
+	})
+
+	When("the service's only cluster is disconnected and the policy is to serve disconnected clusters anyway", func() {
+		BeforeEach(func() {
+			lh.disconnectedPolicy, lh.disconnectedTTL = serveDisconnected, defaultDisconnectedTTL
+		})
+
+		It("should answer with the cluster's IP and a low TTL", func() {
+			executeTestCase(lh, rec, test.Case{
+				Qname: qname,
+				Qtype: dns.TypeA,
+				Rcode: dns.RcodeSuccess,
+				Answer: []dns.RR{
+					test.A(fmt.Sprintf("%s    1    IN    A    %s", qname, serviceIP)),
+				},
+			})
+		})
+
+		It("should keep the TTL of the cached records", func() {
+			executeTestCase(lh, rec, test.Case{Qname: qname, Qtype: dns.TypeA, Rcode: dns.RcodeSuccess,
+				Answer: []dns.RR{test.A(fmt.Sprintf("%s    1    IN    A    %s", qname, serviceIP))}})
+
+			lh.clusterStatus.(*MockClusterStatus).clusterStatusMap[clusterID] = true
+
+			executeTestCase(lh, rec, test.Case{Qname: qname, Qtype: dns.TypeA, Rcode: dns.RcodeSuccess,
+				Answer: []dns.RR{test.A(fmt.Sprintf("%s    5    IN    A    %s", qname, serviceIP))}})
+		})
+	})
+
+	When("the service's only cluster is disconnected and the policy is to fail", func() {
+		BeforeEach(func() {
+			lh.disconnectedPolicy = failDisconnected
+		})
+
+		It("should return SERVFAIL", func() {
+			executeTestCase(lh, rec, test.Case{
+				Qname: qname,
+				Qtype: dns.TypeA,
+				Rcode: dns.RcodeServerFailure,
+			})
+		})
+	})
+
+	When("a headless service's only cluster is disconnected and the policy is to serve disconnected clusters anyway", func() {
+		BeforeEach(func() {
+			lh.serviceImports = serviceimport.NewMap()
+			lh.serviceImports.Put(newServiceImport(namespace1, service1, clusterID, "", portName1, portNumber1, protocol1,
+				mcsv1a1.Headless))
+			lh.disconnectedPolicy, lh.disconnectedTTL = serveDisconnected, defaultDisconnectedTTL
+		})
+
+		It("should answer with the endpoints' IPs and a low TTL", func() {
+			executeTestCase(lh, rec, test.Case{
+				Qname: qname,
+				Qtype: dns.TypeA,
+				Rcode: dns.RcodeSuccess,
+				Answer: []dns.RR{
+					test.A(fmt.Sprintf("%s    1    IN    A    %s", qname, endpointIP)),
+				},
+			})
+		})
+	})
+}
+
+func testMaxEndpoints() {
+	qname := fmt.Sprintf("%s.%s.svc.clusterset.local.", service1, namespace1)
+	hostNames := []string{"pod-0", "pod-1", "pod-2", "pod-3", "pod-4"}
+	endpointIPs := []string{"100.96.1.10", "100.96.1.11", "100.96.1.12", "100.96.1.13", "100.96.1.14"}
+
+	var lh *Lighthouse
+
+	BeforeEach(func() {
+		mockCs := NewMockClusterStatus()
+		mockCs.clusterStatusMap[clusterID] = true
+		mockEs := NewMockEndpointStatus()
+		mockEs.endpointStatusMap[clusterID] = true
+
+		lh = &Lighthouse{
+			Zones:           []string{"clusterset.local."},
+			serviceImports:  serviceimport.NewMap(),
+			endpointSlices:  endpointslice.NewMap(),
+			clusterStatus:   mockCs,
+			endpointsStatus: mockEs,
+			localServices:   NewMockLocalServices(),
+			ttl:             defaultTTL,
+			maxEndpoints:    2,
+		}
+
+		lh.serviceImports.Put(newServiceImport(namespace1, service1, clusterID, "", portName1, portNumber1, protocol1,
+			mcsv1a1.Headless))
+		lh.endpointSlices.Put(newEndpointSlice(namespace1, service1, clusterID, portName1, hostNames, endpointIPs,
+			portNumber1, protocol1))
+	})
+
+	query := func(qtype uint16) *dns.Msg {
+		rec := dnstest.NewRecorder(&test.ResponseWriter{})
+		code, err := lh.ServeDNS(context.TODO(), rec, test.Case{Qname: qname, Qtype: qtype}.Msg())
+		Expect(err).To(Succeed())
+		Expect(code).To(Equal(dns.RcodeSuccess))
+
+		return rec.Msg
+	}
+
+	answeredIPs := func(m *dns.Msg) []string {
+		ips := []string{}
+		for _, rr := range m.Answer {
+			ips = append(ips, rr.(*dns.A).A.String())
+		}
+
+		sort.Strings(ips)
+
+		return ips
+	}
+
+	When("a headless service has more endpoints than the maximum", func() {
+		It("should answer a random sample of its A records", func() {
+			seen := map[string]bool{}
+
+			for i := 0; i < 50; i++ {
+				ips := answeredIPs(query(dns.TypeA))
+				Expect(ips).To(HaveLen(2))
+				Expect(endpointIPs).To(ContainElements(ips))
+
+				for _, ip := range ips {
+					seen[ip] = true
+				}
+			}
+
+			Expect(len(seen)).To(BeNumerically(">", 2))
+		})
+
+		It("should answer as many SRV records, with the addresses of their targets only", func() {
+			m := query(dns.TypeSRV)
+			Expect(m.Answer).To(HaveLen(2))
+			Expect(m.Extra).To(HaveLen(2))
+
+			for _, rr := range m.Extra {
+				Expect([]string{m.Answer[0].(*dns.SRV).Target, m.Answer[1].(*dns.SRV).Target}).To(
+					ContainElement(rr.Header().Name))
+			}
+		})
+	})
+
+	When("a headless service has more endpoints than the maximum and a stable subset is configured", func() {
+		BeforeEach(func() {
+			lh.maxEndpointsMode = stableEndpoints
+		})
+
+		It("should answer the same A records to every query", func() {
+			ips := answeredIPs(query(dns.TypeA))
+			Expect(ips).To(HaveLen(2))
+
+			for i := 0; i < 10; i++ {
+				Expect(answeredIPs(query(dns.TypeA))).To(Equal(ips))
+			}
+		})
+	})
+
+	When("a headless service has no more endpoints than the maximum", func() {
+		BeforeEach(func() {
+			lh.maxEndpoints = len(endpointIPs)
+		})
+
+		It("should answer all its A records", func() {
+			Expect(answeredIPs(query(dns.TypeA))).To(Equal(endpointIPs))
+		})
+	})
+}
+
+func testDynamicUpdates() {
+	var (
+		rec *dnstest.Recorder
+		lh  *Lighthouse
+	)
+
+	BeforeEach(func() {
+		lh = &Lighthouse{
+			Zones:           []string{"clusterset.local."},
+			serviceImports:  setupServiceImportMap(),
+			endpointSlices:  setupEndpointSliceMap(),
+			clusterStatus:   NewMockClusterStatus(),
+			endpointsStatus: NewMockEndpointStatus(),
+			localServices:   NewMockLocalServices(),
+			ttl:             defaultTTL,
+			Next:            test.NextHandler(dns.RcodeBadCookie, errors.New("dummy plugin")),
+		}
+
+		rec = dnstest.NewRecorder(&test.ResponseWriter{})
+	})
+
+	update := func() *dns.Msg {
+		m := new(dns.Msg)
+		m.SetUpdate("clusterset.local.")
+		m.Insert([]dns.RR{test.A(fmt.Sprintf("%s.%s.svc.clusterset.local.    5    IN    A    %s", service1, namespace1,
+			serviceIP2))})
+
+		return m
+	}
+
+	When("an unsigned dynamic update is received for the zone", func() {
+		It("should refuse it without invoking the next plugin", func() {
+			code, err := lh.ServeDNS(context.TODO(), rec, update())
+			Expect(err).To(HaveOccurred())
+			Expect(code).To(Equal(dns.RcodeRefused))
+		})
+	})
+
+	When("a dynamic update signed with TSIG is received for the zone", func() {
+		It("should refuse it", func() {
+			m := update()
+			m.SetTsig("update-key.", dns.HmacSHA256, 300, time.Now().Unix())
+
+			code, err := lh.ServeDNS(context.TODO(), rec, m)
+			Expect(err).To(HaveOccurred())
+			Expect(code).To(Equal(dns.RcodeRefused))
+		})
+	})
+
+	When("a dynamic update is received for another zone", func() {
+		It("should invoke the next plugin", func() {
+			m := new(dns.Msg)
+			m.SetUpdate("example.org.")
+
+			code, err := lh.ServeDNS(context.TODO(), rec, m)
+			Expect(err).To(HaveOccurred())
+			Expect(code).To(Equal(dns.RcodeBadCookie))
+		})
+	})
+}
+
+func testHealthThreshold() {
+	qname := fmt.Sprintf("%s.%s.svc.clusterset.local.", service1, namespace1)
+
+	var (
+		lh     *Lighthouse
+		mockEs *MockEndpointStatus
+	)
+
+	BeforeEach(func() {
+		mockCs := NewMockClusterStatus()
+		mockCs.clusterStatusMap[clusterID] = true
+		mockCs.clusterStatusMap[clusterID2] = true
+		mockEs = NewMockEndpointStatus()
+		mockEs.endpointStatusMap[clusterID] = true
+		mockEs.endpointStatusMap[clusterID2] = true
+		mockEs.healthScoreMap[clusterID] = 0.02
+
+		lh = &Lighthouse{
+			Zones:           []string{"clusterset.local."},
+			serviceImports:  setupServiceImportMap(),
+			endpointSlices:  setupEndpointSliceMap(),
+			clusterStatus:   mockCs,
+			endpointsStatus: mockEs,
+			localServices:   NewMockLocalServices(),
+			ttl:             defaultTTL,
+			healthThreshold: 0.5,
+		}
+
+		lh.serviceImports.Put(newServiceImport(namespace1, service1, clusterID2, serviceIP2, portName2,
+			portNumber2, protocol2, mcsv1a1.ClusterSetIP))
+	})
+
+	answeredIPs := func() map[string]bool {
+		ips := map[string]bool{}
+
+		for i := 0; i < 10; i++ {
+			rec := dnstest.NewRecorder(&test.ResponseWriter{})
+			code, err := lh.ServeDNS(context.TODO(), rec, test.Case{Qname: qname, Qtype: dns.TypeA}.Msg())
+			Expect(err).To(Succeed())
+			Expect(code).To(Equal(dns.RcodeSuccess))
+			Expect(rec.Msg.Answer).To(HaveLen(1))
+
+			ips[rec.Msg.Answer[0].(*dns.A).A.String()] = true
+		}
+
+		return ips
+	}
+
+	When("a cluster's health score is below the threshold", func() {
+		It("should only answer with the clusters reaching it", func() {
+			Expect(answeredIPs()).To(Equal(map[string]bool{serviceIP2: true}))
+		})
+	})
+
+	When("no cluster's health score reaches the threshold", func() {
+		BeforeEach(func() {
+			mockEs.healthScoreMap[clusterID2] = 0.25
+		})
+
+		It("should answer with the clusters which have healthy endpoints", func() {
+			Expect(answeredIPs()).To(Equal(map[string]bool{serviceIP: true, serviceIP2: true}))
+		})
+	})
+
+	When("no threshold is configured", func() {
+		BeforeEach(func() {
+			lh.healthThreshold = 0
+		})
+
+		It("should answer with every cluster which has healthy endpoints", func() {
+			Expect(answeredIPs()).To(Equal(map[string]bool{serviceIP: true, serviceIP2: true}))
+		})
+	})
+}
+
+func testMinReadyEndpoints() {
+	qname := fmt.Sprintf("%s.%s.svc.clusterset.local.", service1, namespace1)
+
+	var (
+		lh     *Lighthouse
+		mockEs *MockEndpointStatus
+	)
+
+	BeforeEach(func() {
+		mockCs := NewMockClusterStatus()
+		mockCs.clusterStatusMap[clusterID] = true
+		mockCs.clusterStatusMap[clusterID2] = true
+		mockEs = NewMockEndpointStatus()
+		mockEs.endpointStatusMap[clusterID] = true
+		mockEs.endpointStatusMap[clusterID2] = true
+		mockEs.readyCountMap[clusterID] = 2
+		mockEs.readyCountMap[clusterID2] = 5
+
+		lh = &Lighthouse{
+			Zones:           []string{"clusterset.local."},
+			serviceImports:  setupServiceImportMap(),
+			endpointSlices:  setupEndpointSliceMap(),
+			clusterStatus:   mockCs,
+			endpointsStatus: mockEs,
+			localServices:   NewMockLocalServices(),
+			ttl:             defaultTTL,
+		}
+
+		si := newServiceImport(namespace1, service1, clusterID2, serviceIP2, portName2, portNumber2, protocol2,
+			mcsv1a1.ClusterSetIP)
+		si.Annotations[lhconstants.MinReadyEndpoints] = "3"
+		lh.serviceImports.Put(si)
+	})
+
+	answeredIPs := func() map[string]bool {
+		ips := map[string]bool{}
+
+		for i := 0; i < 10; i++ {
+			rec := dnstest.NewRecorder(&test.ResponseWriter{})
+			code, err := lh.ServeDNS(context.TODO(), rec, test.Case{Qname: qname, Qtype: dns.TypeA}.Msg())
+			Expect(err).To(Succeed())
+			Expect(code).To(Equal(dns.RcodeSuccess))
+			Expect(rec.Msg.Answer).To(HaveLen(1))
+
+			ips[rec.Msg.Answer[0].(*dns.A).A.String()] = true
+		}
+
+		return ips
+	}
+
+	When("a cluster has fewer ready endpoints than the service requires", func() {
+		It("should only answer with the clusters which have enough", func() {
+			Expect(answeredIPs()).To(Equal(map[string]bool{serviceIP2: true}))
+		})
+	})
+
+	When("no cluster has as many ready endpoints as the service requires", func() {
+		BeforeEach(func() {
+			mockEs.readyCountMap[clusterID2] = 1
+		})
+
+		It("should answer with the clusters which have ready endpoints", func() {
+			Expect(answeredIPs()).To(Equal(map[string]bool{serviceIP: true, serviceIP2: true}))
+		})
+	})
+}
+
+func testRecordTTLs() {
+	service2 := "service2"
+
+	var lh *Lighthouse
+
+	BeforeEach(func() {
+		mockCs := NewMockClusterStatus()
+		mockCs.clusterStatusMap[clusterID] = true
+		mockEs := NewMockEndpointStatus()
+		mockEs.endpointStatusMap[clusterID] = true
+
+		lh = &Lighthouse{
+			Zones:           []string{"clusterset.local."},
+			serviceImports:  setupServiceImportMap(),
+			endpointSlices:  setupEndpointSliceMap(),
+			clusterStatus:   mockCs,
+			endpointsStatus: mockEs,
+			localServices:   NewMockLocalServices(),
+			ttl:             defaultTTL,
+			recordTTLs:      map[string]uint32{headlessTTL: 2, srvTTL: 7, negativeTTL: 30},
+		}
+
+		lh.serviceImports.Put(newServiceImport(namespace1, service2, clusterID, "", portName1, portNumber1, protocol1,
+			mcsv1a1.Headless))
+		lh.endpointSlices.Put(newEndpointSlice(namespace1, service2, clusterID, portName1, []string{hostName1},
+			[]string{endpointIP}, portNumber1, protocol1))
+	})
+
+	query := func(service string, qtype uint16) *dns.Msg {
+		rec := dnstest.NewRecorder(&test.ResponseWriter{})
+		code, err := lh.ServeDNS(context.TODO(), rec,
+			test.Case{Qname: fmt.Sprintf("%s.%s.svc.clusterset.local.", service, namespace1), Qtype: qtype}.Msg())
+		Expect(err).To(Succeed())
+		Expect(code).To(Equal(dns.RcodeSuccess))
+
+		return rec.Msg
+	}
+
+	expectTTL := func(records []dns.RR, ttl uint32) {
+		Expect(records).ToNot(BeEmpty())
+
+		for _, rr := range records {
+			Expect(rr.Header().Ttl).To(Equal(ttl), "TTL of %s", rr)
+		}
+	}
+
+	When("a ClusterSetIP service is queried", func() {
+		It("should answer with the TTL", func() {
+			expectTTL(query(service1, dns.TypeA).Answer, defaultTTL)
+		})
+	})
+
+	When("a headless service is queried", func() {
+		It("should answer with the headless TTL", func() {
+			expectTTL(query(service2, dns.TypeA).Answer, 2)
+		})
+	})
+
+	When("SRV records are queried", func() {
+		It("should answer with the SRV TTL, and the address records of the targets with their service's TTL", func() {
+			m := query(service1, dns.TypeSRV)
+			expectTTL(m.Answer, 7)
+			expectTTL(m.Extra, defaultTTL)
+
+			m = query(service2, dns.TypeSRV)
+			expectTTL(m.Answer, 7)
+			expectTTL(m.Extra, 2)
+		})
+	})
+
+	When("a record type the service doesn't have is queried", func() {
+		It("should answer NODATA with the negative TTL", func() {
+			m := query(service1, dns.TypeAAAA)
+			Expect(m.Answer).To(BeEmpty())
+			Expect(m.Ns).To(HaveLen(1))
+			Expect(m.Ns[0].Header().Ttl).To(Equal(uint32(30)))
+			Expect(m.Ns[0].(*dns.SOA).Minttl).To(Equal(uint32(30)))
+		})
+	})
+}
+
+func testIPv6Only() {
+	const (
+		service2   = "service2"
+		serviceIP6 = "fd00::10"
+		endpointA  = "fd00::20"
+		endpointB  = "fd00::21"
+	)
+
+	var lh *Lighthouse
+
+	BeforeEach(func() {
+		mockCs := NewMockClusterStatus()
+		mockCs.clusterStatusMap[clusterID] = true
+		mockEs := NewMockEndpointStatus()
+		mockEs.endpointStatusMap[clusterID] = true
+
+		lh = &Lighthouse{
+			Zones:           []string{"clusterset.local."},
+			serviceImports:  serviceimport.NewMap(),
+			endpointSlices:  endpointslice.NewMap(),
+			clusterStatus:   mockCs,
+			endpointsStatus: mockEs,
+			localServices:   NewMockLocalServices(),
+			ttl:             defaultTTL,
+			ipv6Only:        true,
+		}
+
+		lh.serviceImports.Put(newServiceImport(namespace1, service1, clusterID, serviceIP6, portName1, portNumber1, protocol1,
+			mcsv1a1.ClusterSetIP))
+		lh.serviceImports.Put(newServiceImport(namespace1, service2, clusterID, "", portName1, portNumber1, protocol1,
+			mcsv1a1.Headless))
+
+		endpointSlice := newEndpointSlice(namespace1, service2, clusterID, portName1, []string{hostName1, hostName2},
+			[]string{endpointA, endpointB}, portNumber1, protocol1)
+		endpointSlice.AddressType = discovery.AddressTypeIPv6
+		lh.endpointSlices.Put(endpointSlice)
+	})
+
+	query := func(service string, qtype uint16) *dns.Msg {
+		rec := dnstest.NewRecorder(&test.ResponseWriter{})
+		code, err := lh.ServeDNS(context.TODO(), rec,
+			test.Case{Qname: fmt.Sprintf("%s.%s.svc.clusterset.local.", service, namespace1), Qtype: qtype}.Msg())
+		Expect(err).To(Succeed())
+		Expect(code).To(Equal(dns.RcodeSuccess))
+
+		return rec.Msg
+	}
+
+	When("AAAA records are queried", func() {
+		It("should answer the IPv6 addresses", func() {
+			m := query(service1, dns.TypeAAAA)
+			Expect(m.Answer).To(HaveLen(1))
+			Expect(m.Answer[0].(*dns.AAAA).AAAA.String()).To(Equal(serviceIP6))
+
+			m = query(service2, dns.TypeAAAA)
+			Expect(m.Answer).To(HaveLen(2))
+		})
+	})
+
+	When("A records are queried", func() {
+		It("should answer NODATA", func() {
+			for _, service := range []string{service1, service2} {
+				m := query(service, dns.TypeA)
+				Expect(m.Answer).To(BeEmpty())
+				Expect(m.Ns).To(HaveLen(1))
+				Expect(m.Ns[0]).To(BeAssignableToTypeOf(&dns.SOA{}))
+			}
+		})
+	})
+
+	When("SRV records are queried", func() {
+		It("should only add the AAAA records of the targets", func() {
+			m := query(service2, dns.TypeSRV)
+			Expect(m.Answer).To(HaveLen(2))
+			Expect(m.Extra).To(HaveLen(2))
+
+			for _, rr := range m.Extra {
+				Expect(rr.Header().Rrtype).To(Equal(dns.TypeAAAA))
+			}
+		})
+	})
+}
+
+func soaRecord(zone string) dns.RR {
+	return test.SOA(fmt.Sprintf("%s    5    IN    SOA    ns.dns.%s hostmaster.%s 0 7200 1800 86400 5", zone, zone, zone))
+}
+
+func executeTestCase(lh *Lighthouse, rec *dnstest.Recorder, tc test.Case) {
+	code, err := lh.ServeDNS(context.TODO(), rec, tc.Msg())
+
+	Expect(code).Should(Equal(tc.Rcode))
+
+	switch tc.Rcode {
+	case dns.RcodeSuccess:
+		Expect(err).To(Succeed())
+		Expect(test.SortAndCheck(rec.Msg, tc)).To(Succeed())
+	case dns.RcodeNameError:
+		// NXDOMAIN responses are written with the zone's SOA record so that resolvers can cache them
+		Expect(err).To(Succeed())
+		Expect(rec.Msg.Rcode).To(Equal(dns.RcodeNameError))
+		Expect(rec.Msg.Ns).To(HaveLen(1))
+		Expect(rec.Msg.Ns[0].Header().Rrtype).To(Equal(dns.TypeSOA))
+	default:
+		Expect(err).To(HaveOccurred())
+	}
+}
+
+func setupServiceImportMap() *serviceimport.Map {
+	siMap := serviceimport.NewMap()
+	siMap.Put(newServiceImport(namespace1, service1, clusterID, serviceIP, portName1, portNumber1, protocol1, mcsv1a1.ClusterSetIP))
+
+	return siMap
+}
+
+func setupEndpointSliceMap() *endpointslice.Map {
+	esMap := endpointslice.NewMap()
+	esMap.Put(newEndpointSlice(namespace1, service1, clusterID, portName1, []string{hostName1}, []string{endpointIP}, portNumber1, protocol1))
+
+	return esMap
+}
+
+func newServiceImport(namespace, name, clusterID, serviceIP, portName string,
+	portNumber int32, protocol v1.Protocol, siType mcsv1a1.ServiceImportType) *mcsv1a1.ServiceImport {
+	return &mcsv1a1.ServiceImport{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      name,
+			Namespace: namespace,
+			Annotations: map[string]string{
+				"origin-name":      name,
+				"origin-namespace": namespace,
+			},
+			Labels: map[string]string{
+				lhconstants.LabelSourceCluster: clusterID,
+			},
+		},
+		Spec: mcsv1a1.ServiceImportSpec{
+			Type: siType,
+			IPs:  []string{serviceIP},
+			Ports: []mcsv1a1.ServicePort{
+				{
+					Name:     portName,
+					Protocol: protocol,
+					Port:     portNumber,
+				},
+			},
+		},
+		Status: mcsv1a1.ServiceImportStatus{
+			Clusters: []mcsv1a1.ClusterStatus{
+				{
+					Cluster: clusterID,
+				},
+			},
+		},
+	}
+}
+
+func newEndpointSlice(namespace, name, clusterID, portName string, hostName, endpointIPs []string, portNumber int32,
+	protocol v1.Protocol) *discovery.EndpointSlice {
+	endpoints := make([]discovery.Endpoint, len(endpointIPs))
+
+	for i := range endpointIPs {
+		endpoint := discovery.Endpoint{
+			Addresses: []string{endpointIPs[i]},
+			Hostname:  &hostName[i],
+		}
+		endpoints[i] = endpoint
+	}
+
+	return &discovery.EndpointSlice{
 		ObjectMeta: metav1.ObjectMeta{
 			Name:      name,
 			Namespace: namespace,
@@ -1143,3 +3411,1291 @@ func newEndpointSlice(namespace, name, clusterID, portName string, hostName, end
 		},
 	}
 }
+
+func testLargeAnswers() {
+	const numEndpoints = 300
+
+	var lh *Lighthouse
+
+	BeforeEach(func() {
+		mockCs := NewMockClusterStatus()
+		mockCs.clusterStatusMap[clusterID] = true
+		mockCs.localClusterID = clusterID
+		mockEs := NewMockEndpointStatus()
+		mockEs.endpointStatusMap[clusterID] = true
+
+		lh = &Lighthouse{
+			Zones:           []string{"clusterset.local."},
+			serviceImports:  serviceimport.NewMap(),
+			endpointSlices:  endpointslice.NewMap(),
+			clusterStatus:   mockCs,
+			endpointsStatus: mockEs,
+			localServices:   NewMockLocalServices(),
+			ttl:             defaultTTL,
+		}
+
+		hostNames := make([]string, numEndpoints)
+		endpointIPs := make([]string, numEndpoints)
+
+		for i := range hostNames {
+			hostNames[i] = fmt.Sprintf("%s-%d", hostName1, i)
+			endpointIPs[i] = fmt.Sprintf("100.96.%d.%d", 158+i/250, i%250+1)
+		}
+
+		lh.serviceImports.Put(newServiceImport(namespace1, service1, clusterID, "", portName1,
+			portNumber1, protocol1, mcsv1a1.Headless))
+		lh.endpointSlices.Put(newEndpointSlice(namespace1, service1, clusterID, portName1, hostNames, endpointIPs,
+			portNumber1, protocol1))
+	})
+
+	qname := fmt.Sprintf("%s.%s.svc.clusterset.local.", service1, namespace1)
+
+	serve := func(w dns.ResponseWriter, qtype uint16, bufSize uint16) *dns.Msg {
+		m := new(dns.Msg)
+		m.SetQuestion(qname, qtype)
+
+		if bufSize > 0 {
+			m.SetEdns0(bufSize, false)
+		}
+
+		rec := dnstest.NewRecorder(w)
+		code, err := lh.ServeDNS(context.TODO(), rec, m)
+		Expect(err).To(Succeed())
+		Expect(code).To(Equal(dns.RcodeSuccess))
+
+		return rec.Msg
+	}
+
+	When("a type A query is made over UDP", func() {
+		It("should fit the response in the UDP message size and set the truncated bit", func() {
+			msg := serve(&test.ResponseWriter{}, dns.TypeA, 0)
+			Expect(msg.Truncated).To(BeTrue())
+			Expect(msg.Answer).ToNot(BeEmpty())
+			Expect(len(msg.Answer)).To(BeNumerically("<", numEndpoints))
+			Expect(msg.Len()).To(BeNumerically("<=", dns.MinMsgSize))
+		})
+	})
+
+	When("a type A query is made over UDP with a larger EDNS buffer size", func() {
+		It("should fit more records in the response", func() {
+			small := serve(&test.ResponseWriter{}, dns.TypeA, 0)
+			large := serve(&test.ResponseWriter{}, dns.TypeA, 2048)
+			Expect(len(large.Answer)).To(BeNumerically(">", len(small.Answer)))
+			Expect(large.Len()).To(BeNumerically("<=", 2048))
+		})
+	})
+
+	When("a type SRV query is made over UDP", func() {
+		It("should drop the additional records first and set the truncated bit", func() {
+			msg := serve(&test.ResponseWriter{}, dns.TypeSRV, 0)
+			Expect(msg.Truncated).To(BeTrue())
+			Expect(msg.Extra).To(BeEmpty())
+			Expect(msg.Len()).To(BeNumerically("<=", dns.MinMsgSize))
+		})
+	})
+
+	When("a type A query is made over TCP", func() {
+		It("should return the full set of records", func() {
+			msg := serve(&test.ResponseWriter{TCP: true}, dns.TypeA, 0)
+			Expect(msg.Truncated).To(BeFalse())
+			Expect(msg.Answer).To(HaveLen(numEndpoints))
+		})
+	})
+
+	When("a type SRV query is made over TCP", func() {
+		It("should return the full set of records with their addresses", func() {
+			msg := serve(&test.ResponseWriter{TCP: true}, dns.TypeSRV, 0)
+			Expect(msg.Truncated).To(BeFalse())
+			Expect(msg.Answer).To(HaveLen(numEndpoints))
+			Expect(msg.Extra).To(HaveLen(numEndpoints))
+		})
+	})
+
+	When("type A queries are made repeatedly", func() {
+		It("should answer the records in different orders", func() {
+			first := serve(&test.ResponseWriter{TCP: true}, dns.TypeA, 0)
+			second := serve(&test.ResponseWriter{TCP: true}, dns.TypeA, 0)
+			Expect(second.Answer).To(ConsistOf(first.Answer))
+			Expect(second.Answer).ToNot(Equal(first.Answer))
+		})
+
+		Context("and shuffling is disabled", func() {
+			BeforeEach(func() {
+				lh.noShuffle = true
+			})
+
+			It("should answer the records in the same order", func() {
+				first := serve(&test.ResponseWriter{TCP: true}, dns.TypeA, 0)
+				Expect(serve(&test.ResponseWriter{TCP: true}, dns.TypeA, 0).Answer).To(Equal(first.Answer))
+			})
+		})
+
+		Context("and the order is stable", func() {
+			BeforeEach(func() {
+				lh.stableOrder = true
+
+				lh.clusterStatus.(*MockClusterStatus).clusterStatusMap[clusterID2] = true
+				lh.endpointsStatus.(*MockEndpointStatus).endpointStatusMap[clusterID2] = true
+				lh.serviceImports.Put(newServiceImport(namespace1, service1, clusterID2, "", portName1,
+					portNumber1, protocol1, mcsv1a1.Headless))
+				lh.endpointSlices.Put(newEndpointSlice(namespace1, service1, clusterID2, portName1, []string{hostName2},
+					[]string{"100.96.1.1"}, portNumber1, protocol1))
+			})
+
+			It("should answer the records sorted by cluster ID then IP", func() {
+				first := serve(&test.ResponseWriter{TCP: true}, dns.TypeA, 0)
+				Expect(first.Answer).To(HaveLen(numEndpoints + 1))
+				Expect(serve(&test.ResponseWriter{TCP: true}, dns.TypeA, 0).Answer).To(Equal(first.Answer))
+
+				for i := 1; i < numEndpoints; i++ {
+					Expect(bytes.Compare(first.Answer[i-1].(*dns.A).A.To16(), first.Answer[i].(*dns.A).A.To16())).To(Equal(-1))
+				}
+
+				Expect(first.Answer[numEndpoints].(*dns.A).A.String()).To(Equal("100.96.1.1"))
+			})
+		})
+	})
+}
+
+func testLatencyLoadBalance() {
+	var (
+		rec    *dnstest.Recorder
+		lh     *Lighthouse
+		mockCl *MockClusterLatency
+	)
+
+	BeforeEach(func() {
+		mockCs := NewMockClusterStatus()
+		mockCs.clusterStatusMap[clusterID] = true
+		mockCs.clusterStatusMap[clusterID2] = true
+		mockCl = &MockClusterLatency{MockClusterStatus: mockCs, latencyMap: map[string]time.Duration{}}
+		mockEs := NewMockEndpointStatus()
+		mockEs.endpointStatusMap[clusterID] = true
+		mockEs.endpointStatusMap[clusterID2] = true
+		lh = &Lighthouse{
+			Zones:           []string{"clusterset.local."},
+			serviceImports:  setupServiceImportMap(),
+			endpointSlices:  setupEndpointSliceMap(),
+			clusterStatus:   mockCl,
+			endpointsStatus: mockEs,
+			localServices:   NewMockLocalServices(),
+			ttl:             defaultTTL,
+			loadBalance:     latencyLoadBalance,
+		}
+
+		lh.serviceImports.Put(newServiceImport(namespace1, service1, clusterID2, serviceIP2, portName1, portNumber1,
+			protocol1, mcsv1a1.ClusterSetIP))
+
+		rec = dnstest.NewRecorder(&test.ResponseWriter{})
+	})
+
+	qname := fmt.Sprintf("%s.%s.svc.clusterset.local.", service1, namespace1)
+
+	expectAnswers := func(ip string) {
+		for i := 0; i < 3; i++ {
+			executeTestCase(lh, rec, test.Case{
+				Qname: qname,
+				Qtype: dns.TypeA,
+				Rcode: dns.RcodeSuccess,
+				Answer: []dns.RR{
+					test.A(fmt.Sprintf("%s    5    IN    A    %s", qname, ip)),
+				},
+			})
+		}
+	}
+
+	When("the service is in two remote clusters with known latencies", func() {
+		BeforeEach(func() {
+			mockCl.latencyMap[clusterID] = 10 * time.Millisecond
+			mockCl.latencyMap[clusterID2] = 2 * time.Millisecond
+		})
+
+		It("should always answer with the cluster with the lowest latency", func() {
+			expectAnswers(serviceIP2)
+		})
+
+		It("should answer with the next lowest latency cluster if it becomes unavailable", func() {
+			mockCl.clusterStatusMap[clusterID2] = false
+			expectAnswers(serviceIP)
+		})
+	})
+
+	When("the latency of only one cluster is known", func() {
+		BeforeEach(func() {
+			mockCl.latencyMap[clusterID] = 10 * time.Millisecond
+		})
+
+		It("should prefer the cluster with a known latency", func() {
+			expectAnswers(serviceIP)
+		})
+	})
+
+	When("the service is in the local cluster", func() {
+		BeforeEach(func() {
+			mockCl.localClusterID = clusterID
+			mockCl.latencyMap[clusterID2] = time.Millisecond
+			lh.localServices.(*MockLocalServices).LocalServicesMap[getKey(service1, namespace1)] = &serviceimport.DNSRecord{
+				IP:          serviceIP,
+				ClusterName: clusterID,
+			}
+		})
+
+		It("should answer with the local cluster", func() {
+			expectAnswers(serviceIP)
+		})
+	})
+}
+
+func testResolutionStats() {
+	var (
+		rec    *dnstest.Recorder
+		lh     *Lighthouse
+		mockCs *MockClusterStatus
+	)
+
+	BeforeEach(func() {
+		mockCs = NewMockClusterStatus()
+		mockCs.clusterStatusMap[clusterID] = true
+		mockEs := NewMockEndpointStatus()
+		mockEs.endpointStatusMap[clusterID] = true
+		mockEs.endpointStatusMap[clusterID2] = true
+		siMap := setupServiceImportMap()
+		lh = &Lighthouse{
+			Zones:           []string{"clusterset.local."},
+			serviceImports:  siMap,
+			endpointSlices:  setupEndpointSliceMap(),
+			clusterStatus:   mockCs,
+			endpointsStatus: mockEs,
+			localServices:   NewMockLocalServices(),
+			ttl:             defaultTTL,
+			stats:           stats.NewCollector(siMap.Services),
+		}
+
+		lh.serviceImports.Put(newServiceImport(namespace1, service1, clusterID2, serviceIP2, portName1, portNumber1,
+			protocol1, mcsv1a1.ClusterSetIP))
+
+		rec = dnstest.NewRecorder(&test.ResponseWriter{})
+	})
+
+	qname := fmt.Sprintf("%s.%s.svc.clusterset.local.", service1, namespace1)
+
+	When("a service is queried", func() {
+		It("should count the queries and NXDOMAIN responses", func() {
+			executeTestCase(lh, rec, test.Case{
+				Qname: qname,
+				Qtype: dns.TypeA,
+				Rcode: dns.RcodeSuccess,
+				Answer: []dns.RR{
+					test.A(fmt.Sprintf("%s    5    IN    A    %s", qname, serviceIP)),
+				},
+			})
+
+			executeTestCase(lh, rec, test.Case{
+				Qname: "unknown." + namespace1 + ".svc.clusterset.local.",
+				Qtype: dns.TypeA,
+				Rcode: dns.RcodeNameError,
+			})
+
+			snapshot := lh.stats.Snapshot()
+			Expect(snapshot[namespace1][service1]).To(Equal(stats.ServiceStats{Queries: 1}))
+			Expect(snapshot[namespace1]["unknown"]).To(Equal(stats.ServiceStats{Queries: 1, NXDomain: 1}))
+		})
+	})
+
+	When("a namespace without imported services is queried", func() {
+		It("should not track it", func() {
+			executeTestCase(lh, rec, test.Case{
+				Qname: service1 + "." + namespace2 + ".svc.clusterset.local.",
+				Qtype: dns.TypeA,
+				Rcode: dns.RcodeNameError,
+			})
+
+			Expect(lh.stats.Snapshot()).ToNot(HaveKey(namespace2))
+		})
+	})
+
+	When("the cluster answered with becomes unavailable", func() {
+		It("should record a failover to the next cluster", func() {
+			executeTestCase(lh, rec, test.Case{
+				Qname: qname,
+				Qtype: dns.TypeA,
+				Rcode: dns.RcodeSuccess,
+				Answer: []dns.RR{
+					test.A(fmt.Sprintf("%s    5    IN    A    %s", qname, serviceIP)),
+				},
+			})
+
+			mockCs.clusterStatusMap[clusterID] = false
+			mockCs.clusterStatusMap[clusterID2] = true
+
+			executeTestCase(lh, rec, test.Case{
+				Qname: qname,
+				Qtype: dns.TypeA,
+				Rcode: dns.RcodeSuccess,
+				Answer: []dns.RR{
+					test.A(fmt.Sprintf("%s    5    IN    A    %s", qname, serviceIP2)),
+				},
+			})
+
+			failover := lh.stats.Snapshot()[namespace1][service1].LastFailover
+			Expect(failover).ToNot(BeNil())
+			Expect(failover.From).To(Equal(clusterID))
+			Expect(failover.To).To(Equal(clusterID2))
+		})
+	})
+}
+
+func testHashLoadBalance() {
+	var (
+		lh     *Lighthouse
+		mockCs *MockClusterStatus
+	)
+
+	BeforeEach(func() {
+		mockCs = NewMockClusterStatus()
+		mockCs.clusterStatusMap[clusterID] = true
+		mockCs.clusterStatusMap[clusterID2] = true
+		mockEs := NewMockEndpointStatus()
+		mockEs.endpointStatusMap[clusterID] = true
+		mockEs.endpointStatusMap[clusterID2] = true
+		lh = &Lighthouse{
+			Zones:           []string{"clusterset.local."},
+			serviceImports:  setupServiceImportMap(),
+			endpointSlices:  setupEndpointSliceMap(),
+			clusterStatus:   mockCs,
+			endpointsStatus: mockEs,
+			localServices:   NewMockLocalServices(),
+			ttl:             defaultTTL,
+			loadBalance:     hashLoadBalance,
+		}
+
+		lh.serviceImports.Put(newServiceImport(namespace1, service1, clusterID2, serviceIP2, portName1, portNumber1,
+			protocol1, mcsv1a1.ClusterSetIP))
+	})
+
+	qname := fmt.Sprintf("%s.%s.svc.clusterset.local.", service1, namespace1)
+
+	resolve := func(subnet string) string {
+		msg := new(dns.Msg)
+		msg.SetQuestion(qname, dns.TypeA)
+
+		if subnet != "" {
+			_, ipNet, err := net.ParseCIDR(subnet)
+			Expect(err).To(Succeed())
+
+			ones, _ := ipNet.Mask.Size()
+			msg.SetEdns0(4096, false)
+			msg.IsEdns0().Option = append(msg.IsEdns0().Option, &dns.EDNS0_SUBNET{
+				Code:          dns.EDNS0SUBNET,
+				Family:        1,
+				SourceNetmask: uint8(ones),
+				Address:       ipNet.IP,
+			})
+		}
+
+		rec := dnstest.NewRecorder(&test.ResponseWriter{})
+		code, err := lh.ServeDNS(context.TODO(), rec, msg)
+		Expect(err).To(Succeed())
+		Expect(code).To(Equal(dns.RcodeSuccess))
+		Expect(rec.Msg.Answer).To(HaveLen(1))
+
+		return rec.Msg.Answer[0].(*dns.A).A.String()
+	}
+
+	When("the same client queries repeatedly", func() {
+		It("should always answer with the same cluster", func() {
+			ip := resolve("")
+			for i := 0; i < 5; i++ {
+				Expect(resolve("")).To(Equal(ip))
+			}
+		})
+	})
+
+	When("queries carry a client subnet", func() {
+		It("should answer clients in the same subnet with the same cluster", func() {
+			Expect(resolve("10.1.2.3/24")).To(Equal(resolve("10.1.2.200/24")))
+		})
+
+		It("should spread different subnets over the clusters", func() {
+			ips := map[string]bool{}
+			for i := 0; i < 32; i++ {
+				ips[resolve(fmt.Sprintf("10.1.%d.0/24", i))] = true
+			}
+
+			Expect(ips).To(HaveLen(2))
+		})
+	})
+
+	When("the cluster selected for a client becomes unavailable", func() {
+		It("should answer with another cluster", func() {
+			ip := resolve("")
+
+			if ip == serviceIP {
+				mockCs.clusterStatusMap[clusterID] = false
+				Expect(resolve("")).To(Equal(serviceIP2))
+			} else {
+				mockCs.clusterStatusMap[clusterID2] = false
+				Expect(resolve("")).To(Equal(serviceIP))
+			}
+		})
+	})
+}
+
+func testDisableSRV() {
+	var (
+		rec *dnstest.Recorder
+		lh  *Lighthouse
+	)
+
+	BeforeEach(func() {
+		mockCs := NewMockClusterStatus()
+		mockCs.clusterStatusMap[clusterID] = true
+		mockEs := NewMockEndpointStatus()
+		mockEs.endpointStatusMap[clusterID] = true
+		lh = &Lighthouse{
+			Zones:            []string{"clusterset.local.", "supercluster.local."},
+			Next:             test.NextHandler(dns.RcodeBadCookie, errors.New("dummy plugin")),
+			serviceImports:   setupServiceImportMap(),
+			endpointSlices:   setupEndpointSliceMap(),
+			clusterStatus:    mockCs,
+			endpointsStatus:  mockEs,
+			localServices:    NewMockLocalServices(),
+			ttl:              defaultTTL,
+			srvDisabledZones: []string{"supercluster.local."},
+		}
+
+		rec = dnstest.NewRecorder(&test.ResponseWriter{})
+	})
+
+	qname := fmt.Sprintf("%s.%s.svc.supercluster.local.", service1, namespace1)
+
+	When("an SRV query is made in a zone with SRV records disabled", func() {
+		It("should return an empty response (NODATA)", func() {
+			executeTestCase(lh, rec, test.Case{
+				Qname:  qname,
+				Qtype:  dns.TypeSRV,
+				Rcode:  dns.RcodeSuccess,
+				Answer: []dns.RR{},
+				Ns:     []dns.RR{soaRecord("supercluster.local.")},
+			})
+		})
+
+		It("should invoke the next plugin if fallthrough is configured", func() {
+			lh.Fall = fall.F{Zones: []string{"supercluster.local."}}
+			executeTestCase(lh, rec, test.Case{
+				Qname: qname,
+				Qtype: dns.TypeSRV,
+				Rcode: dns.RcodeBadCookie,
+			})
+		})
+	})
+
+	When("an A query is made in a zone with SRV records disabled", func() {
+		It("should succeed and write an A record response", func() {
+			executeTestCase(lh, rec, test.Case{
+				Qname: qname,
+				Qtype: dns.TypeA,
+				Rcode: dns.RcodeSuccess,
+				Answer: []dns.RR{
+					test.A(fmt.Sprintf("%s    5    IN    A    %s", qname, serviceIP)),
+				},
+			})
+		})
+	})
+
+	When("an SRV query is made in another zone", func() {
+		It("should succeed and write an SRV record response", func() {
+			qname := fmt.Sprintf("%s.%s.svc.clusterset.local.", service1, namespace1)
+			executeTestCase(lh, rec, test.Case{
+				Qname: qname,
+				Qtype: dns.TypeSRV,
+				Rcode: dns.RcodeSuccess,
+				Answer: []dns.RR{
+					test.SRV(fmt.Sprintf("%s    5    IN    SRV 0 50 %d %s", qname, portNumber1, qname)),
+				},
+				Extra: []dns.RR{
+					test.A(fmt.Sprintf("%s    5    IN    A    %s", qname, serviceIP)),
+				},
+			})
+		})
+	})
+}
+
+func testEDNS() {
+	var (
+		rec *dnstest.Recorder
+		lh  *Lighthouse
+	)
+
+	BeforeEach(func() {
+		mockCs := NewMockClusterStatus()
+		mockCs.clusterStatusMap[clusterID] = true
+		mockEs := NewMockEndpointStatus()
+		mockEs.endpointStatusMap[clusterID] = true
+		lh = &Lighthouse{
+			Zones:           []string{"clusterset.local."},
+			serviceImports:  setupServiceImportMap(),
+			endpointSlices:  setupEndpointSliceMap(),
+			clusterStatus:   mockCs,
+			endpointsStatus: mockEs,
+			localServices:   NewMockLocalServices(),
+			ttl:             defaultTTL,
+		}
+
+		rec = dnstest.NewRecorder(&test.ResponseWriter{})
+	})
+
+	qname := fmt.Sprintf("%s.%s.svc.clusterset.local.", service1, namespace1)
+
+	newQuery := func(qtype uint16, version uint8) *dns.Msg {
+		m := new(dns.Msg)
+		m.SetQuestion(qname, qtype)
+		m.SetEdns0(4096, false)
+		m.IsEdns0().SetVersion(version)
+		m.IsEdns0().Option = append(m.IsEdns0().Option, &dns.EDNS0_LOCAL{Code: 65001, Data: []byte{1, 2, 3}})
+
+		return m
+	}
+
+	expectOPT := func() {
+		opt := rec.Msg.IsEdns0()
+		Expect(opt).ToNot(BeNil())
+		Expect(opt.Version()).To(BeZero())
+		Expect(opt.Option).To(BeEmpty())
+	}
+
+	When("a query with an unsupported EDNS version is made", func() {
+		It("should return BADVERS with an OPT record", func() {
+			code, err := lh.ServeDNS(context.TODO(), rec, newQuery(dns.TypeA, 1))
+			Expect(err).To(Succeed())
+			Expect(code).To(Equal(dns.RcodeBadVers))
+			Expect(rec.Msg.Rcode).To(Equal(dns.RcodeBadVers))
+			Expect(rec.Msg.Answer).To(BeEmpty())
+			expectOPT()
+		})
+	})
+
+	When("a query with unknown EDNS options results in an answer", func() {
+		It("should echo the OPT record without the unknown options", func() {
+			code, err := lh.ServeDNS(context.TODO(), rec, newQuery(dns.TypeA, 0))
+			Expect(err).To(Succeed())
+			Expect(code).To(Equal(dns.RcodeSuccess))
+			Expect(rec.Msg.Answer).To(HaveLen(1))
+			expectOPT()
+		})
+	})
+
+	When("a query with unknown EDNS options results in NODATA", func() {
+		It("should echo the OPT record without the unknown options", func() {
+			code, err := lh.ServeDNS(context.TODO(), rec, newQuery(dns.TypeAAAA, 0))
+			Expect(err).To(Succeed())
+			Expect(code).To(Equal(dns.RcodeSuccess))
+			Expect(rec.Msg.Answer).To(BeEmpty())
+			Expect(rec.Msg.Ns).To(HaveLen(1))
+			expectOPT()
+		})
+	})
+}
+
+func testNamespaceRestrictions() {
+	var (
+		rec *dnstest.Recorder
+		lh  *Lighthouse
+	)
+
+	BeforeEach(func() {
+		mockCs := NewMockClusterStatus()
+		mockCs.clusterStatusMap[clusterID] = true
+		mockEs := NewMockEndpointStatus()
+		mockEs.endpointStatusMap[clusterID] = true
+		lh = &Lighthouse{
+			Zones:           []string{"clusterset.local."},
+			Next:            test.NextHandler(dns.RcodeBadCookie, errors.New("dummy plugin")),
+			serviceImports:  setupServiceImportMap(),
+			endpointSlices:  setupEndpointSliceMap(),
+			clusterStatus:   mockCs,
+			endpointsStatus: mockEs,
+			localServices:   NewMockLocalServices(),
+			ttl:             defaultTTL,
+		}
+
+		rec = dnstest.NewRecorder(&test.ResponseWriter{})
+	})
+
+	qname := fmt.Sprintf("%s.%s.svc.clusterset.local.", service1, namespace1)
+
+	When("the service's namespace is in the allowed namespaces", func() {
+		It("should succeed and write an A record response", func() {
+			lh.namespaces = map[string]bool{namespace1: true}
+			executeTestCase(lh, rec, test.Case{
+				Qname: qname,
+				Qtype: dns.TypeA,
+				Rcode: dns.RcodeSuccess,
+				Answer: []dns.RR{
+					test.A(fmt.Sprintf("%s    5    IN    A    %s", qname, serviceIP)),
+				},
+			})
+		})
+	})
+
+	When("the service's namespace isn't in the allowed namespaces", func() {
+		It("should return RcodeNameError", func() {
+			lh.namespaces = map[string]bool{namespace2: true}
+			executeTestCase(lh, rec, test.Case{
+				Qname: qname,
+				Qtype: dns.TypeA,
+				Rcode: dns.RcodeNameError,
+			})
+		})
+	})
+
+	When("the service's namespace is excluded", func() {
+		BeforeEach(func() {
+			lh.excludedNamespaces = map[string]bool{namespace1: true}
+		})
+
+		It("should return RcodeNameError", func() {
+			executeTestCase(lh, rec, test.Case{
+				Qname: qname,
+				Qtype: dns.TypeSRV,
+				Rcode: dns.RcodeNameError,
+			})
+		})
+
+		It("should invoke the next plugin if fallthrough is configured", func() {
+			lh.Fall = fall.F{Zones: []string{"clusterset.local."}}
+			executeTestCase(lh, rec, test.Case{
+				Qname: qname,
+				Qtype: dns.TypeA,
+				Rcode: dns.RcodeBadCookie,
+			})
+		})
+	})
+}
+
+func testTracing() {
+	var (
+		rec    *dnstest.Recorder
+		lh     *Lighthouse
+		tracer *mocktracer.MockTracer
+		span   *mocktracer.MockSpan
+	)
+
+	BeforeEach(func() {
+		mockCs := NewMockClusterStatus()
+		mockCs.clusterStatusMap[clusterID] = true
+		mockEs := NewMockEndpointStatus()
+		mockEs.endpointStatusMap[clusterID] = true
+		lh = &Lighthouse{
+			Zones:           []string{"clusterset.local."},
+			serviceImports:  setupServiceImportMap(),
+			endpointSlices:  setupEndpointSliceMap(),
+			clusterStatus:   mockCs,
+			endpointsStatus: mockEs,
+			localServices:   NewMockLocalServices(),
+			ttl:             defaultTTL,
+		}
+
+		rec = dnstest.NewRecorder(&test.ResponseWriter{})
+		tracer = mocktracer.New()
+		span = tracer.StartSpan(PluginName).(*mocktracer.MockSpan)
+	})
+
+	serveDNS := func(qname string, qtype uint16) {
+		m := new(dns.Msg)
+		m.SetQuestion(qname, qtype)
+		_, _ = lh.ServeDNS(ot.ContextWithSpan(context.TODO(), span), rec, m)
+	}
+
+	When("a query is answered", func() {
+		It("should tag the span with the query, the chosen cluster and the answer count", func() {
+			qname := fmt.Sprintf("%s.%s.svc.clusterset.local.", service1, namespace1)
+			serveDNS(qname, dns.TypeA)
+
+			Expect(span.Tag(tagName)).To(Equal(qname))
+			Expect(span.Tag(tagType)).To(Equal("A"))
+			Expect(span.Tag(tagClusters)).To(Equal(clusterID))
+			Expect(span.Tag(tagAnswers)).To(Equal(1))
+		})
+	})
+
+	When("a query isn't answered", func() {
+		It("should only tag the span with the query", func() {
+			qname := fmt.Sprintf("unknown.%s.svc.clusterset.local.", namespace1)
+			serveDNS(qname, dns.TypeA)
+
+			Expect(span.Tag(tagName)).To(Equal(qname))
+			Expect(span.Tag(tagClusters)).To(BeNil())
+			Expect(span.Tag(tagAnswers)).To(BeNil())
+		})
+	})
+}
+
+func testQueryLog() {
+	var (
+		rec    *dnstest.Recorder
+		lh     *Lighthouse
+		output *bytes.Buffer
+	)
+
+	BeforeEach(func() {
+		mockCs := NewMockClusterStatus()
+		mockCs.clusterStatusMap[clusterID] = true
+		mockEs := NewMockEndpointStatus()
+		mockEs.endpointStatusMap[clusterID] = true
+		output = &bytes.Buffer{}
+		lh = &Lighthouse{
+			Zones:           []string{"clusterset.local."},
+			serviceImports:  setupServiceImportMap(),
+			endpointSlices:  setupEndpointSliceMap(),
+			clusterStatus:   mockCs,
+			endpointsStatus: mockEs,
+			localServices:   NewMockLocalServices(),
+			ttl:             defaultTTL,
+			queryLog:        &queryLog{rate: 1, writer: output},
+		}
+
+		rec = dnstest.NewRecorder(&test.ResponseWriter{})
+	})
+
+	readEntries := func() []queryLogEntry {
+		entries := []queryLogEntry{}
+		decoder := json.NewDecoder(output)
+
+		for decoder.More() {
+			entry := queryLogEntry{}
+			Expect(decoder.Decode(&entry)).To(Succeed())
+			entries = append(entries, entry)
+		}
+
+		return entries
+	}
+
+	When("a query is answered", func() {
+		It("should log it with the selected cluster", func() {
+			qname := fmt.Sprintf("%s.%s.svc.clusterset.local.", service1, namespace1)
+			executeTestCase(lh, rec, test.Case{
+				Qname: qname,
+				Qtype: dns.TypeA,
+				Rcode: dns.RcodeSuccess,
+				Answer: []dns.RR{
+					test.A(fmt.Sprintf("%s    5    IN    A    %s", qname, serviceIP)),
+				},
+			})
+
+			entries := readEntries()
+			Expect(entries).To(HaveLen(1))
+			Expect(entries[0].Name).To(Equal(qname))
+			Expect(entries[0].Type).To(Equal("A"))
+			Expect(entries[0].Rcode).To(Equal("NOERROR"))
+			Expect(entries[0].Cluster).To(Equal(clusterID))
+			Expect(entries[0].Client).To(Equal("10.240.0.1"))
+			Expect(entries[0].Time).ToNot(BeEmpty())
+		})
+	})
+
+	When("a query gets NXDOMAIN", func() {
+		It("should log it without a cluster", func() {
+			executeTestCase(lh, rec, test.Case{
+				Qname: fmt.Sprintf("unknown.%s.svc.clusterset.local.", namespace1),
+				Qtype: dns.TypeA,
+				Rcode: dns.RcodeNameError,
+			})
+
+			entries := readEntries()
+			Expect(entries).To(HaveLen(1))
+			Expect(entries[0].Rcode).To(Equal("NXDOMAIN"))
+			Expect(entries[0].Cluster).To(BeEmpty())
+		})
+	})
+
+	When("queries aren't sampled", func() {
+		It("should not log them", func() {
+			lh.queryLog.rate = 0
+			executeTestCase(lh, rec, test.Case{
+				Qname: fmt.Sprintf("unknown.%s.svc.clusterset.local.", namespace1),
+				Qtype: dns.TypeA,
+				Rcode: dns.RcodeNameError,
+			})
+
+			Expect(output.Len()).To(BeZero())
+		})
+	})
+}
+
+func testBrokerOutOfSync() {
+	qname := fmt.Sprintf("%s.%s.svc.clusterset.local.", service1, namespace1)
+
+	var (
+		rec            *dnstest.Recorder
+		lh             *Lighthouse
+		outOfSyncSince time.Time
+	)
+
+	BeforeEach(func() {
+		mockCs := NewMockClusterStatus()
+		mockCs.clusterStatusMap[clusterID] = true
+		mockEs := NewMockEndpointStatus()
+		mockEs.endpointStatusMap[clusterID] = true
+		outOfSyncSince = time.Time{}
+
+		lh = &Lighthouse{
+			Zones:           []string{"clusterset.local."},
+			serviceImports:  setupServiceImportMap(),
+			endpointSlices:  setupEndpointSliceMap(),
+			clusterStatus:   mockCs,
+			endpointsStatus: mockEs,
+			localServices:   NewMockLocalServices(),
+			ttl:             defaultTTL,
+			brokerOutOfSync: &brokerOutOfSync{ttl: defaultOutOfSyncTTL, outOfSyncSince: func() (time.Time, bool) {
+				return outOfSyncSince, !outOfSyncSince.IsZero()
+			}},
+		}
+
+		rec = dnstest.NewRecorder(&test.ResponseWriter{})
+	})
+
+	When("the agent is in sync with the broker", func() {
+		It("should answer with the configured TTL", func() {
+			executeTestCase(lh, rec, test.Case{
+				Qname: qname,
+				Qtype: dns.TypeA,
+				Rcode: dns.RcodeSuccess,
+				Answer: []dns.RR{
+					test.A(fmt.Sprintf("%s    5    IN    A    %s", qname, serviceIP)),
+				},
+			})
+		})
+	})
+
+	When("the agent is out of sync with the broker", func() {
+		BeforeEach(func() {
+			outOfSyncSince = time.Now().Add(-time.Minute)
+		})
+
+		It("should answer with the out of sync TTL", func() {
+			executeTestCase(lh, rec, test.Case{
+				Qname: qname,
+				Qtype: dns.TypeA,
+				Rcode: dns.RcodeSuccess,
+				Answer: []dns.RR{
+					test.A(fmt.Sprintf("%s    30    IN    A    %s", qname, serviceIP)),
+				},
+			})
+		})
+	})
+}
+
+func testNonCanonicalQueries() {
+	var (
+		rec *dnstest.Recorder
+		lh  *Lighthouse
+	)
+
+	BeforeEach(func() {
+		mockCs := NewMockClusterStatus()
+		mockCs.clusterStatusMap[clusterID] = true
+		mockEs := NewMockEndpointStatus()
+		mockEs.endpointStatusMap[clusterID] = true
+
+		lh = &Lighthouse{
+			Zones:           []string{"clusterset.local."},
+			serviceImports:  setupServiceImportMap(),
+			endpointSlices:  setupEndpointSliceMap(),
+			clusterStatus:   mockCs,
+			endpointsStatus: mockEs,
+			localServices:   NewMockLocalServices(),
+			ttl:             defaultTTL,
+		}
+
+		rec = dnstest.NewRecorder(&test.ResponseWriter{})
+	})
+
+	When("a query has mixed-case labels", func() {
+		It("should answer with the name as queried", func() {
+			qname := "SERVICE1.Namespace1.SVC.ClusterSet.Local."
+
+			executeTestCase(lh, rec, test.Case{
+				Qname: qname,
+				Qtype: dns.TypeA,
+				Rcode: dns.RcodeSuccess,
+				Answer: []dns.RR{
+					test.A(fmt.Sprintf("%s    5    IN    A    %s", qname, serviceIP)),
+				},
+			})
+		})
+	})
+
+	When("a name is queried with different cases", func() {
+		It("should answer each query with the name as queried", func() {
+			for _, qname := range []string{
+				"SERVICE1.Namespace1.SVC.ClusterSet.Local.",
+				fmt.Sprintf("%s.%s.svc.clusterset.local.", service1, namespace1),
+				"service1.NAMESPACE1.svc.clusterset.local.",
+			} {
+				executeTestCase(lh, rec, test.Case{
+					Qname: qname,
+					Qtype: dns.TypeA,
+					Rcode: dns.RcodeSuccess,
+					Answer: []dns.RR{
+						test.A(fmt.Sprintf("%s    5    IN    A    %s", qname, serviceIP)),
+					},
+				})
+
+				executeTestCase(lh, rec, test.Case{
+					Qname: qname,
+					Qtype: dns.TypeSRV,
+					Rcode: dns.RcodeSuccess,
+					Answer: []dns.RR{
+						test.SRV(fmt.Sprintf("%s    5    IN    SRV 0 50 %d %s.%s.svc.clusterset.local.", qname, portNumber1,
+							service1, namespace1)),
+					},
+					Extra: []dns.RR{
+						test.A(fmt.Sprintf("%s.%s.svc.clusterset.local.    5    IN    A    %s", service1, namespace1, serviceIP)),
+					},
+				})
+
+				Expect(rec.Msg.Answer[0].(*dns.SRV).Target).To(Equal(fmt.Sprintf("%s.%s.svc.clusterset.local.", service1,
+					namespace1)))
+				Expect(rec.Msg.Extra[0].Header().Name).To(Equal(fmt.Sprintf("%s.%s.svc.clusterset.local.", service1,
+					namespace1)))
+			}
+		})
+	})
+
+	When("a query has escaped labels", func() {
+		It("should answer with the name as queried", func() {
+			qname := fmt.Sprintf("\\%03d%s.%s.svc.clusterset.local.", service1[0], service1[1:], namespace1)
+
+			executeTestCase(lh, rec, test.Case{
+				Qname: qname,
+				Qtype: dns.TypeA,
+				Rcode: dns.RcodeSuccess,
+				Answer: []dns.RR{
+					test.A(fmt.Sprintf("%s    5    IN    A    %s", qname, serviceIP)),
+				},
+			})
+		})
+	})
+}
+
+func testEmptyNonTerminals() {
+	var (
+		rec *dnstest.Recorder
+		lh  *Lighthouse
+	)
+
+	BeforeEach(func() {
+		mockCs := NewMockClusterStatus()
+		mockCs.clusterStatusMap[clusterID] = true
+		mockEs := NewMockEndpointStatus()
+		mockEs.endpointStatusMap[clusterID] = true
+
+		lh = &Lighthouse{
+			Zones:           []string{"clusterset.local."},
+			serviceImports:  setupServiceImportMap(),
+			endpointSlices:  setupEndpointSliceMap(),
+			clusterStatus:   mockCs,
+			endpointsStatus: mockEs,
+			localServices:   NewMockLocalServices(),
+			ttl:             defaultTTL,
+		}
+
+		rec = dnstest.NewRecorder(&test.ResponseWriter{})
+	})
+
+	noData := func(qname string, qtype uint16) {
+		executeTestCase(lh, rec, test.Case{
+			Qname:  qname,
+			Qtype:  qtype,
+			Rcode:  dns.RcodeSuccess,
+			Answer: []dns.RR{},
+			Ns:     []dns.RR{soaRecord("clusterset.local.")},
+		})
+	}
+
+	nxDomain := func(qname string, qtype uint16) {
+		executeTestCase(lh, rec, test.Case{
+			Qname: qname,
+			Qtype: qtype,
+			Rcode: dns.RcodeNameError,
+		})
+	}
+
+	When("the zone's apex is queried for its SOA record", func() {
+		It("should answer with the SOA record", func() {
+			executeTestCase(lh, rec, test.Case{
+				Qname:  "clusterset.local.",
+				Qtype:  dns.TypeSOA,
+				Rcode:  dns.RcodeSuccess,
+				Answer: []dns.RR{soaRecord("clusterset.local.")},
+			})
+		})
+	})
+
+	When("the zone's apex is queried for its NS records", func() {
+		It("should answer with the name server named by the SOA record", func() {
+			executeTestCase(lh, rec, test.Case{
+				Qname:  "clusterset.local.",
+				Qtype:  dns.TypeNS,
+				Rcode:  dns.RcodeSuccess,
+				Answer: []dns.RR{test.NS("clusterset.local.    5    IN    NS    ns.dns.clusterset.local.")},
+			})
+		})
+	})
+
+	When("the zone's apex is queried for other types", func() {
+		It("should return an empty response (NODATA)", func() {
+			noData("clusterset.local.", dns.TypeA)
+			noData("clusterset.local.", dns.TypeTXT)
+		})
+	})
+
+	When("the zone's svc label is queried", func() {
+		It("should return an empty response (NODATA)", func() {
+			noData("svc.clusterset.local.", dns.TypeNS)
+			noData("svc.clusterset.local.", dns.TypeA)
+			noData("SVC.clusterset.local.", dns.TypeSOA)
+		})
+	})
+
+	When("the zone's pod label is queried", func() {
+		It("should return NXDOMAIN since pod queries aren't supported", func() {
+			nxDomain("pod.clusterset.local.", dns.TypeA)
+		})
+	})
+
+	When("a namespace with services is queried", func() {
+		It("should return an empty response (NODATA)", func() {
+			noData(fmt.Sprintf("%s.svc.clusterset.local.", namespace1), dns.TypeNS)
+			noData(fmt.Sprintf("%s.svc.clusterset.local.", namespace1), dns.TypeA)
+		})
+	})
+
+	When("a namespace without services is queried", func() {
+		It("should return RcodeNameError", func() {
+			nxDomain(fmt.Sprintf("%s.svc.clusterset.local.", namespace2), dns.TypeA)
+		})
+	})
+
+	When("the protocol label of a service's SRV records is queried for type A", func() {
+		It("should return an empty response (NODATA)", func() {
+			noData(fmt.Sprintf("_tcp.%s.%s.svc.clusterset.local.", service1, namespace1), dns.TypeA)
+		})
+	})
+
+	When("the owner name of a service's SRV records is queried for type A", func() {
+		It("should return an empty response (NODATA)", func() {
+			noData(fmt.Sprintf("_%s._tcp.%s.%s.svc.clusterset.local.", portName1, service1, namespace1), dns.TypeA)
+		})
+	})
+
+	When("a protocol the service has no port for is queried for type A", func() {
+		It("should return RcodeNameError", func() {
+			nxDomain(fmt.Sprintf("_udp.%s.%s.svc.clusterset.local.", service1, namespace1), dns.TypeA)
+			nxDomain(fmt.Sprintf("_%s._udp.%s.%s.svc.clusterset.local.", portName1, service1, namespace1), dns.TypeA)
+		})
+	})
+
+	When("the protocol label of a service which doesn't exist is queried for type A", func() {
+		It("should return RcodeNameError", func() {
+			nxDomain(fmt.Sprintf("_tcp.unknown.%s.svc.clusterset.local.", namespace1), dns.TypeA)
+		})
+	})
+}
+
+func testResponseFlags() {
+	var lh *Lighthouse
+
+	BeforeEach(func() {
+		mockCs := NewMockClusterStatus()
+		mockCs.clusterStatusMap[clusterID] = true
+		mockEs := NewMockEndpointStatus()
+		mockEs.endpointStatusMap[clusterID] = true
+
+		lh = &Lighthouse{
+			Zones:           []string{"clusterset.local."},
+			serviceImports:  setupServiceImportMap(),
+			endpointSlices:  setupEndpointSliceMap(),
+			clusterStatus:   mockCs,
+			endpointsStatus: mockEs,
+			localServices:   NewMockLocalServices(),
+			ttl:             defaultTTL,
+		}
+	})
+
+	serve := func(qname string, qtype uint16) *dns.Msg {
+		m := new(dns.Msg)
+		m.SetQuestion(qname, qtype)
+		m.RecursionDesired = true
+		m.AuthenticatedData = true
+		m.SetEdns0(4096, true)
+
+		rec := dnstest.NewRecorder(&test.ResponseWriter{})
+		code, err := lh.ServeDNS(context.TODO(), rec, m)
+		Expect(err).To(Succeed())
+		Expect(code).To(Equal(dns.RcodeSuccess))
+
+		return rec.Msg
+	}
+
+	verifyFlags := func(msg *dns.Msg) {
+		Expect(msg.Authoritative).To(BeTrue())
+		Expect(msg.RecursionDesired).To(BeTrue())
+		Expect(msg.RecursionAvailable).To(BeFalse())
+		Expect(msg.AuthenticatedData).To(BeFalse())
+	}
+
+	When("a query for a service is answered", func() {
+		It("should be authoritative, without recursion available nor authenticated data", func() {
+			msg := serve(fmt.Sprintf("%s.%s.svc.clusterset.local.", service1, namespace1), dns.TypeA)
+			Expect(msg.Answer).To(HaveLen(1))
+			verifyFlags(msg)
+		})
+	})
+
+	When("a query is answered with NODATA", func() {
+		It("should be authoritative, without recursion available nor authenticated data", func() {
+			msg := serve(fmt.Sprintf("%s.%s.svc.clusterset.local.", service1, namespace1), dns.TypeAAAA)
+			Expect(msg.Answer).To(BeEmpty())
+			verifyFlags(msg)
+		})
+	})
+
+	When("a wildcard query is answered", func() {
+		BeforeEach(func() {
+			lh.wildcardLimit = defaultWildcardLimit
+		})
+
+		It("should be authoritative, without recursion available nor authenticated data", func() {
+			msg := serve(fmt.Sprintf("*.%s.svc.clusterset.local.", namespace1), dns.TypeA)
+			Expect(msg.Answer).To(HaveLen(1))
+			verifyFlags(msg)
+		})
+	})
+}
+
+func testMaintenance() {
+	var (
+		rec         *dnstest.Recorder
+		lh          *Lighthouse
+		maintenance map[string]bool
+	)
+
+	qname := fmt.Sprintf("%s.%s.svc.clusterset.local.", service1, namespace1)
+
+	BeforeEach(func() {
+		mockCs := NewMockClusterStatus()
+		mockCs.clusterStatusMap[clusterID] = true
+		mockCs.clusterStatusMap[clusterID2] = true
+		mockEs := NewMockEndpointStatus()
+		mockEs.endpointStatusMap[clusterID] = true
+		mockEs.endpointStatusMap[clusterID2] = true
+		maintenance = map[string]bool{}
+
+		lh = &Lighthouse{
+			Zones:           []string{"clusterset.local."},
+			serviceImports:  setupServiceImportMap(),
+			endpointSlices:  setupEndpointSliceMap(),
+			clusterStatus:   mockCs,
+			endpointsStatus: mockEs,
+			localServices:   NewMockLocalServices(),
+			ttl:             defaultTTL,
+			inMaintenance: func(clusterID string) bool {
+				return maintenance[clusterID]
+			},
+		}
+
+		lh.serviceImports.Put(newServiceImport(namespace1, service1, clusterID2, serviceIP2, portName1, portNumber1,
+			protocol1, mcsv1a1.ClusterSetIP))
+
+		rec = dnstest.NewRecorder(&test.ResponseWriter{})
+	})
+
+	When("a cluster is in maintenance", func() {
+		BeforeEach(func() {
+			maintenance[clusterID] = true
+		})
+
+		It("should answer with the other clusters only", func() {
+			for i := 0; i < 5; i++ {
+				executeTestCase(lh, rec, test.Case{
+					Qname: qname,
+					Qtype: dns.TypeA,
+					Rcode: dns.RcodeSuccess,
+					Answer: []dns.RR{
+						test.A(fmt.Sprintf("%s    5    IN    A    %s", qname, serviceIP2)),
+					},
+				})
+			}
+		})
+
+		Context("and it's the local cluster", func() {
+			BeforeEach(func() {
+				lh.clusterStatus.(*MockClusterStatus).localClusterID = clusterID
+				lh.localServices.(*MockLocalServices).LocalServicesMap[getKey(service1, namespace1)] = &serviceimport.DNSRecord{
+					IP:          "10.96.0.10",
+					ClusterName: clusterID,
+				}
+			})
+
+			It("should answer with the other clusters only", func() {
+				executeTestCase(lh, rec, test.Case{
+					Qname: qname,
+					Qtype: dns.TypeA,
+					Rcode: dns.RcodeSuccess,
+					Answer: []dns.RR{
+						test.A(fmt.Sprintf("%s    5    IN    A    %s", qname, serviceIP2)),
+					},
+				})
+			})
+
+			It("should answer a query naming it with its exported service rather than the local Service", func() {
+				clusterQname := fmt.Sprintf("%s.%s", clusterID, qname)
+
+				executeTestCase(lh, rec, test.Case{
+					Qname: clusterQname,
+					Qtype: dns.TypeA,
+					Rcode: dns.RcodeSuccess,
+					Answer: []dns.RR{
+						test.A(fmt.Sprintf("%s    5    IN    A    %s", clusterQname, serviceIP)),
+					},
+				})
+			})
+		})
+	})
+
+	When("all the clusters are in maintenance", func() {
+		BeforeEach(func() {
+			maintenance[clusterID] = true
+			maintenance[clusterID2] = true
+		})
+
+		It("should return an empty response (NODATA)", func() {
+			executeTestCase(lh, rec, test.Case{
+				Qname:  qname,
+				Qtype:  dns.TypeA,
+				Rcode:  dns.RcodeSuccess,
+				Answer: []dns.RR{},
+				Ns:     []dns.RR{soaRecord("clusterset.local.")},
+			})
+		})
+
+		Context("and disconnected clusters are served anyway", func() {
+			BeforeEach(func() {
+				lh.disconnectedPolicy = serveDisconnected
+				lh.disconnectedTTL = 1
+			})
+
+			It("should still return an empty response (NODATA)", func() {
+				executeTestCase(lh, rec, test.Case{
+					Qname:  qname,
+					Qtype:  dns.TypeA,
+					Rcode:  dns.RcodeSuccess,
+					Answer: []dns.RR{},
+					Ns:     []dns.RR{soaRecord("clusterset.local.")},
+				})
+			})
+		})
+	})
+}
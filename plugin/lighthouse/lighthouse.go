@@ -19,12 +19,19 @@ package lighthouse
 
 import (
 	"errors"
+	"sync/atomic"
+	"time"
 
 	"github.com/coredns/coredns/plugin"
 	"github.com/coredns/coredns/plugin/pkg/fall"
 	clog "github.com/coredns/coredns/plugin/pkg/log"
+	"github.com/submariner-io/lighthouse/pkg/breaker"
+	"github.com/submariner-io/lighthouse/pkg/dnssec"
+	"github.com/submariner-io/lighthouse/pkg/dnstls"
 	"github.com/submariner-io/lighthouse/pkg/endpointslice"
+	"github.com/submariner-io/lighthouse/pkg/resolver"
 	"github.com/submariner-io/lighthouse/pkg/serviceimport"
+	"github.com/submariner-io/lighthouse/pkg/stats"
 )
 
 const (
@@ -33,6 +40,36 @@ const (
 	defaultTTL = uint32(5)
 )
 
+const (
+	defaultSRVLocalPriority  = uint16(0)
+	defaultSRVRemotePriority = uint16(10)
+)
+
+// The classes of records whose TTL can be set apart from the others.
+const (
+	clusterSetIPTTL = "clusterset"
+	headlessTTL     = "headless"
+	srvTTL          = "srv"
+	negativeTTL     = "negative"
+)
+
+const (
+	roundRobinLoadBalance = "round_robin"
+	latencyLoadBalance    = "latency"
+	hashLoadBalance       = "hash"
+)
+
+const (
+	localFirstSelection        = "local-first"
+	roundRobinSelection        = "round-robin"
+	allSelection               = "all"
+	remoteOnUnhealthySelection = "remote-only-on-unhealthy"
+)
+
+// allClusters, used as the cluster in a query such as all.service.namespace.svc.clusterset.local, requests the records
+// of all the eligible clusters regardless of the cluster selection policy.
+const allClusters = "all"
+
 var (
 	errInvalidRequest = errors.New("invalid query name")
 )
@@ -51,20 +88,130 @@ type Lighthouse struct {
 	clusterStatus   ClusterStatus
 	endpointsStatus EndpointsStatus
 	localServices   LocalServices
+	// clustersetDomains, if set, restricts answers to names under these domains; the matching domain is used in
+	// synthesized records instead of the matched zone
+	clustersetDomains []string
+	// breaker, if set, stops answering with clusters for which connection failures to a service were reported
+	breaker *breaker.Breaker
+	// loadBalance is the policy used to pick a remote cluster when the local cluster doesn't host the service
+	loadBalance string
+	// clusterSelection is the policy selecting the clusters answered for services which don't request their own,
+	// local-first if unset
+	clusterSelection string
+	// fallNoData lists the zones in which NODATA responses are passed to the next plugin, like Fall for NXDOMAIN
+	fallNoData fall.F
+	// srvDisabledZones, if set, lists the zones for which SRV queries are answered with NODATA or passed on
+	srvDisabledZones []string
+	// namespaces, if set, restricts answers to services in these namespaces
+	namespaces map[string]bool
+	// excludedNamespaces lists namespaces whose services are never answered for
+	excludedNamespaces map[string]bool
+	// config, if loaded, holds a *reloadableConfig overriding the settings above which can be reloaded at runtime
+	config atomic.Value
+	// stats, if set, collects per-service resolution statistics for publishing to application namespaces
+	stats *stats.Collector
+	// queryLog, if set, logs a sample of the queries served
+	queryLog *queryLog
+	// synced, if set, reports whether the initial ServiceImports and EndpointSlices have been loaded
+	synced func() bool
+	// flights shares the answers computed for a query with concurrent identical queries
+	flights flightGroup
+	// noShuffle stops the A records of headless services from being answered in random order
+	noShuffle bool
+	// stableOrder answers the records of services in several clusters or with several endpoints sorted by cluster ID,
+	// then IP, rather than in random or map order
+	stableOrder bool
+	// ipv6Only answers only AAAA records for the clusterset's services, with NODATA for A queries
+	ipv6Only bool
+	// dnssec, if set, signs the answers to queries from DNSSEC-aware clients and answers for the zones' DNSKEYs
+	dnssec *dnssec.Signer
+	// limiter, if set, refuses the queries exceeding the configured rates
+	limiter *queryLimiter
+	// forwarders, if set, forward the queries for names in their zones to resolvers in other clusters over TLS
+	forwarders []*dnstls.Forwarder
+	// wildcardLimit, if set, enables *.namespace.svc queries, answered for up to this many services
+	wildcardLimit int
+	// anyTypes, if set, are the types of the records answered to ANY queries instead of the RFC 8482 HINFO record
+	anyTypes []uint16
+	// srvLocalPriority and srvRemotePriority are the priorities of the SRV records targeting the local cluster and
+	// the remote clusters respectively
+	srvLocalPriority  uint16
+	srvRemotePriority uint16
+	// topology, if set, restricts the answers for headless services to the endpoints closest to this instance
+	topology *topologyPreference
+	// aliasMode is how queries for the aliases of services are answered, with the services' records by default or
+	// with CNAMEs to the services' names
+	aliasMode string
+	// srvPrefixes is which requests the _port._protocol prefixes of SRV owner names are parsed in, all by default
+	srvPrefixes string
+	// clusterAliases maps friendly names which can be used instead of cluster IDs in queries to the cluster IDs
+	clusterAliases map[string]string
+	// negative, if set, caches the queries answered with NXDOMAIN or NODATA
+	negative *negativeCache
+	// stale, if set, keeps answering from the maps with a longer TTL for a while when they can't be refreshed
+	stale *serveStale
+	// brokerOutOfSync, if set, answers with a longer TTL while the agent reports it's out of sync with the broker
+	brokerOutOfSync *brokerOutOfSync
+	// inMaintenance, if set, checks whether a cluster is in maintenance, excluding it from the answers
+	inMaintenance func(clusterID string) bool
+	// disconnectedPolicy is how queries which only disconnected clusters could answer are answered, by dropping the
+	// disconnected clusters by default
+	disconnectedPolicy string
+	// disconnectedTTL is the TTL of the records of disconnected clusters, when they're answered anyway
+	disconnectedTTL uint32
+	// namespaceMetrics, if set, reports the imported services and the queries per namespace
+	namespaceMetrics *namespaceMetrics
+	// upstream, if set, resolves the SRV targets the plugin has no addresses for
+	upstream upstreamResolver
+	// maxEndpoints, if set, is the maximum number of records answered for a headless service, picked as per
+	// maxEndpointsMode
+	maxEndpoints     int
+	maxEndpointsMode string
+	// serial, if set, is the serial of the zones' SOA record, bumped whenever a ServiceImport or EndpointSlice changes
+	serial *zoneSerial
+	// transfer, if set, answers AXFR and IXFR queries for the zones
+	transfer *zoneTransfer
+	// recordTTLs overrides the TTL for some classes of records, by class
+	recordTTLs map[string]uint32
+	// prefetch, if set, rebuilds the answers of the most queried names when their service changes
+	prefetch *prefetcher
+	// healthThreshold is the health score a service must reach in a cluster for the cluster to be answered with, as
+	// long as some cluster reaches it
+	healthThreshold float64
 }
 
+// ClusterStatus reports the connectivity with the clusters of the clusterset. Its backend is selected by the
+// cluster-status option: the Submariner Gateway resources by default, or one of the clusterstatus package's.
 type ClusterStatus interface {
 	IsConnected(clusterID string) bool
 
 	LocalClusterID() string
 }
 
+// ClusterLatency is an optional extension of ClusterStatus exposing the measured round-trip time to each cluster.
+type ClusterLatency interface {
+	Latency(clusterID string) (time.Duration, bool)
+}
+
 type LocalServices interface {
 	GetIP(name, namespace string) (*serviceimport.DNSRecord, bool)
 }
 
+// EndpointsStatus reports the health of a service's endpoints in each cluster.
 type EndpointsStatus interface {
-	IsHealthy(name, namespace, clusterID string) bool
+	// HealthScore returns the share of the service's endpoints in the given cluster which are healthy, from 0, if it
+	// has none or the cluster doesn't export the service, to 1.
+	HealthScore(name, namespace, clusterID string) float64
+}
+
+// EndpointsCount is an optional extension of EndpointsStatus exposing the number of ready endpoints of a service in
+// each cluster.
+type EndpointsCount interface {
+	ReadyEndpoints(name, namespace, clusterID string) int
 }
 
 var _ plugin.Handler = &Lighthouse{}
+
+var _ resolver.Source = &Lighthouse{}
+
+var _ resolver.Reporter = &Lighthouse{}
@@ -0,0 +1,100 @@
+/*
+SPDX-License-Identifier: Apache-2.0
+
+Copyright Contributors to the Submariner project.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package lighthouse
+
+import (
+	"hash/fnv"
+	"math/rand"
+	"sort"
+	"strings"
+
+	"github.com/miekg/dns"
+)
+
+// The ways of picking the records answered for a headless service with more endpoints than max-endpoints.
+const (
+	// randomEndpoints answers a random sample of the records, different for each query
+	randomEndpoints = "random"
+	// stableEndpoints answers the same subset of the records for each query, on every instance
+	stableEndpoints = "stable"
+)
+
+// capEndpoints returns at most maxEndpoints of the given records of a headless service, with the additional records of
+// the SRV targets left out dropped. It also returns whether any record was left out. The given slices, which can be
+// shared with concurrent queries, aren't modified.
+func (lh *Lighthouse) capEndpoints(records, extras []dns.RR) (cappedRecords, cappedExtras []dns.RR, truncated bool) {
+	if lh.maxEndpoints == 0 || len(records) <= lh.maxEndpoints {
+		return records, extras, false
+	}
+
+	cappedRecords = append(cappedRecords, records...)
+
+	if lh.maxEndpointsMode == stableEndpoints {
+		// The records are ordered by hash rather than by name or address, so that the subset isn't biased towards
+		// e.g. the first cluster
+		hashes := make(map[dns.RR]uint32, len(cappedRecords))
+
+		for _, rr := range cappedRecords {
+			h := fnv.New32a()
+			_, _ = h.Write([]byte(rr.String()))
+			hashes[rr] = h.Sum32()
+		}
+
+		sort.SliceStable(cappedRecords, func(i, j int) bool {
+			return hashes[cappedRecords[i]] < hashes[cappedRecords[j]]
+		})
+	} else {
+		rand.Shuffle(len(cappedRecords), func(i, j int) {
+			cappedRecords[i], cappedRecords[j] = cappedRecords[j], cappedRecords[i]
+		})
+	}
+
+	cappedRecords = cappedRecords[:lh.maxEndpoints]
+
+	if lh.stableOrder {
+		// The records left are answered in the order they were given
+		positions := make(map[dns.RR]int, len(records))
+
+		for i, rr := range records {
+			positions[rr] = i
+		}
+
+		sort.Slice(cappedRecords, func(i, j int) bool {
+			return positions[cappedRecords[i]] < positions[cappedRecords[j]]
+		})
+	}
+
+	targets := map[string]bool{}
+
+	for _, rr := range cappedRecords {
+		if srv, ok := rr.(*dns.SRV); ok {
+			targets[strings.ToLower(srv.Target)] = true
+		}
+	}
+
+	for _, rr := range extras {
+		if rrtype := rr.Header().Rrtype; (rrtype == dns.TypeA || rrtype == dns.TypeAAAA) &&
+			!targets[strings.ToLower(rr.Header().Name)] {
+			continue
+		}
+
+		cappedExtras = append(cappedExtras, rr)
+	}
+
+	return cappedRecords, cappedExtras, true
+}
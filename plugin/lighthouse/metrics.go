@@ -0,0 +1,188 @@
+/*
+SPDX-License-Identifier: Apache-2.0
+
+Copyright Contributors to the Submariner project.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package lighthouse
+
+import (
+	"sync"
+
+	"github.com/coredns/coredns/plugin"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/submariner-io/lighthouse/pkg/serviceimport"
+)
+
+// dedupHitsCount counts the queries answered with the answers computed for a concurrent identical query.
+var dedupHitsCount = promauto.NewCounter(prometheus.CounterOpts{
+	Namespace: plugin.Namespace,
+	Subsystem: PluginName,
+	Name:      "dedup_hits_total",
+	Help:      "Counter of queries answered with the answers computed for a concurrent identical query.",
+})
+
+// rateLimitedCount counts the queries refused for exceeding a rate limit, per limit.
+var rateLimitedCount = promauto.NewCounterVec(prometheus.CounterOpts{
+	Namespace: plugin.Namespace,
+	Subsystem: PluginName,
+	Name:      "rate_limited_total",
+	Help:      "Counter of queries refused for exceeding the per-client or total rate limit.",
+}, []string{"limit"})
+
+// importedServicesGauge reports the number of services imported, per namespace.
+var importedServicesGauge = promauto.NewGaugeVec(prometheus.GaugeOpts{
+	Namespace: plugin.Namespace,
+	Subsystem: PluginName,
+	Name:      "imported_services",
+	Help:      "Gauge of the number of services imported, per namespace.",
+}, []string{"namespace"})
+
+// namespaceQueriesCount counts the queries for services, per namespace and response code.
+var namespaceQueriesCount = promauto.NewCounterVec(prometheus.CounterOpts{
+	Namespace: plugin.Namespace,
+	Subsystem: PluginName,
+	Name:      "namespace_queries_total",
+	Help:      "Counter of queries for services, per namespace and response code.",
+}, []string{"namespace", "rcode"})
+
+// negativeCacheHitsCount counts the queries answered from the negative cache.
+var negativeCacheHitsCount = promauto.NewCounter(prometheus.CounterOpts{
+	Namespace: plugin.Namespace,
+	Subsystem: PluginName,
+	Name:      "negative_cache_hits_total",
+	Help:      "Counter of queries answered with NXDOMAIN or NODATA from the negative cache.",
+})
+
+// disconnectedAnswersCount counts the queries which only disconnected clusters could answer, per policy applied.
+var disconnectedAnswersCount = promauto.NewCounterVec(prometheus.CounterOpts{
+	Namespace: plugin.Namespace,
+	Subsystem: PluginName,
+	Name:      "disconnected_answers_total",
+	Help:      "Counter of queries which only disconnected clusters could answer, per policy applied.",
+}, []string{"policy"})
+
+// staleAnswersCount counts the queries answered from ServiceImports and EndpointSlices which can't be refreshed.
+var staleAnswersCount = promauto.NewCounter(prometheus.CounterOpts{
+	Namespace: plugin.Namespace,
+	Subsystem: PluginName,
+	Name:      "stale_answers_total",
+	Help:      "Counter of queries answered while the ServiceImports and EndpointSlices can't be refreshed.",
+})
+
+// srvTargetLookupsCount counts the SRV targets looked up through the upstream, per result.
+var srvTargetLookupsCount = promauto.NewCounterVec(prometheus.CounterOpts{
+	Namespace: plugin.Namespace,
+	Subsystem: PluginName,
+	Name:      "srv_target_lookups_total",
+	Help:      "Counter of SRV targets looked up through the upstream, per result.",
+}, []string{"result"})
+
+// truncatedAnswersCount counts the answers for headless services capped to max-endpoints records.
+var truncatedAnswersCount = promauto.NewCounter(prometheus.CounterOpts{
+	Namespace: plugin.Namespace,
+	Subsystem: PluginName,
+	Name:      "truncated_answers_total",
+	Help:      "Counter of answers for headless services capped to the maximum number of endpoints.",
+})
+
+// transfersCount counts the zone transfers, per type.
+var transfersCount = promauto.NewCounterVec(prometheus.CounterOpts{
+	Namespace: plugin.Namespace,
+	Subsystem: PluginName,
+	Name:      "transfers_total",
+	Help:      "Counter of zone transfers, per type.",
+}, []string{"type"})
+
+// refusedUpdatesCount counts the refused dynamic updates, by whether they're signed with TSIG.
+var refusedUpdatesCount = promauto.NewCounterVec(prometheus.CounterOpts{
+	Namespace: plugin.Namespace,
+	Subsystem: PluginName,
+	Name:      "refused_updates_total",
+	Help:      "Counter of refused dynamic updates, by whether they're signed with TSIG.",
+}, []string{"signed"})
+
+// prefetchesCount counts the answers of hot names rebuilt after their service changed.
+var prefetchesCount = promauto.NewCounter(prometheus.CounterOpts{
+	Namespace: plugin.Namespace,
+	Subsystem: PluginName,
+	Name:      "prefetches_total",
+	Help:      "Counter of answers of hot names rebuilt after their service changed.",
+})
+
+// rrCacheBudgets holds the resource record cache budgets of the running plugin instances, whose usage is reported
+// together, and the evictions of the budgets of the instances shut down since, so that the evictions never decrease.
+var rrCacheBudgets = struct {
+	sync.Mutex
+	live    map[*serviceimport.RRCacheBudget]bool
+	retired uint64
+}{live: map[*serviceimport.RRCacheBudget]bool{}}
+
+func registerRRCacheBudget(budget *serviceimport.RRCacheBudget) {
+	rrCacheBudgets.Lock()
+	defer rrCacheBudgets.Unlock()
+
+	rrCacheBudgets.live[budget] = true
+}
+
+func unregisterRRCacheBudget(budget *serviceimport.RRCacheBudget) {
+	rrCacheBudgets.Lock()
+	defer rrCacheBudgets.Unlock()
+
+	if rrCacheBudgets.live[budget] {
+		_, evictions := budget.Usage()
+		rrCacheBudgets.retired += evictions
+		delete(rrCacheBudgets.live, budget)
+	}
+}
+
+// rrCacheUsage returns the estimated memory used by the resource record caches of all the running plugin instances in
+// bytes, and the number of entries evicted to stay within their bounds.
+func rrCacheUsage() (size int64, evictions uint64) {
+	rrCacheBudgets.Lock()
+	defer rrCacheBudgets.Unlock()
+
+	evictions = rrCacheBudgets.retired
+
+	for budget := range rrCacheBudgets.live {
+		budgetSize, budgetEvictions := budget.Usage()
+		size += budgetSize
+		evictions += budgetEvictions
+	}
+
+	return size, evictions
+}
+
+// The estimated memory used by the resource record caches is reported while bounded by cache-memory.
+var _ = promauto.NewGaugeFunc(prometheus.GaugeOpts{
+	Namespace: plugin.Namespace,
+	Subsystem: PluginName,
+	Name:      "rr_cache_bytes",
+	Help:      "Gauge of the estimated memory used by the resource record caches in bytes, while bounded.",
+}, func() float64 {
+	size, _ := rrCacheUsage()
+	return float64(size)
+})
+
+// The resource record cache entries evicted to stay within the cache-memory bound are counted.
+var _ = promauto.NewCounterFunc(prometheus.CounterOpts{
+	Namespace: plugin.Namespace,
+	Subsystem: PluginName,
+	Name:      "rr_cache_evictions_total",
+	Help:      "Counter of resource record cache entries evicted to stay within the memory bound.",
+}, func() float64 {
+	_, evictions := rrCacheUsage()
+	return float64(evictions)
+})
@@ -0,0 +1,123 @@
+/*
+SPDX-License-Identifier: Apache-2.0
+
+Copyright Contributors to the Submariner project.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package lighthouse
+
+import (
+	"sync"
+
+	"github.com/miekg/dns"
+)
+
+// otherNamespaces is the namespace label under which the namespaces not reported individually are aggregated.
+const otherNamespaces = "other"
+
+// namespaceMetrics reports the imported services and the queries per namespace. Only the namespaces in the allow-list,
+// if set, or else those with imported services, are reported individually, so that the number of label values stays
+// bounded whatever namespaces are queried.
+type namespaceMetrics struct {
+	sync.RWMutex
+	// services returns the names of the services imported in a namespace
+	services func(namespace string) []string
+	imported map[string]int
+	allowed  map[string]bool
+}
+
+func newNamespaceMetrics(services func(namespace string) []string) *namespaceMetrics {
+	return &namespaceMetrics{
+		services: services,
+		imported: map[string]int{},
+	}
+}
+
+// setAllowed restricts the namespaces reported individually to the given ones, if any.
+func (n *namespaceMetrics) setAllowed(allowed map[string]bool) {
+	n.Lock()
+	defer n.Unlock()
+
+	n.allowed = allowed
+
+	importedServicesGauge.Reset()
+
+	for namespace := range n.imported {
+		n.updateGauge(namespace)
+	}
+}
+
+// label must be called with the lock held.
+func (n *namespaceMetrics) label(namespace string) string {
+	if n.allowed != nil {
+		if n.allowed[namespace] {
+			return namespace
+		}
+	} else if n.imported[namespace] > 0 {
+		return namespace
+	}
+
+	return otherNamespaces
+}
+
+// serviceChanged updates the number of services imported in the given namespace.
+func (n *namespaceMetrics) serviceChanged(namespace string) {
+	if n == nil {
+		return
+	}
+
+	count := len(n.services(namespace))
+
+	n.Lock()
+	defer n.Unlock()
+
+	if count == 0 {
+		delete(n.imported, namespace)
+
+		if n.allowed == nil {
+			importedServicesGauge.DeleteLabelValues(namespace)
+			return
+		}
+	} else {
+		n.imported[namespace] = count
+	}
+
+	n.updateGauge(namespace)
+}
+
+// updateGauge must be called with the lock held.
+func (n *namespaceMetrics) updateGauge(namespace string) {
+	label := n.label(namespace)
+	count := 0
+
+	for ns, imported := range n.imported {
+		if n.label(ns) == label {
+			count += imported
+		}
+	}
+
+	importedServicesGauge.WithLabelValues(label).Set(float64(count))
+}
+
+func (n *namespaceMetrics) recordQuery(namespace string, rcode int) {
+	if n == nil {
+		return
+	}
+
+	n.RLock()
+	label := n.label(namespace)
+	n.RUnlock()
+
+	namespaceQueriesCount.WithLabelValues(label, dns.RcodeToString[rcode]).Inc()
+}
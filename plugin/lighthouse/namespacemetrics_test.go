@@ -0,0 +1,84 @@
+/*
+SPDX-License-Identifier: Apache-2.0
+
+Copyright Contributors to the Submariner project.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package lighthouse
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/submariner-io/lighthouse/pkg/serviceimport"
+	mcsv1a1 "sigs.k8s.io/mcs-api/pkg/apis/v1alpha1"
+)
+
+var _ = Describe("Per-namespace metrics", func() {
+	var (
+		metrics *namespaceMetrics
+		siStore *serviceImportStore
+	)
+
+	BeforeEach(func() {
+		importedServicesGauge.Reset()
+		namespaceQueriesCount.Reset()
+
+		siMap := serviceimport.NewMap()
+		metrics = newNamespaceMetrics(siMap.Services)
+		siStore = &serviceImportStore{Store: siMap, hints: &evictionHints{}, metrics: metrics}
+
+		siStore.Put(newServiceImport(namespace1, service1, clusterID, serviceIP, portName1, portNumber1, protocol1,
+			mcsv1a1.ClusterSetIP))
+		siStore.Put(newServiceImport(namespace2, service1, clusterID, serviceIP2, portName1, portNumber1, protocol1,
+			mcsv1a1.ClusterSetIP))
+	})
+
+	When("no allow-list is set", func() {
+		It("should report each namespace with imported services individually", func() {
+			Expect(testutil.ToFloat64(importedServicesGauge.WithLabelValues(namespace1))).To(Equal(1.0))
+			Expect(testutil.ToFloat64(importedServicesGauge.WithLabelValues(namespace2))).To(Equal(1.0))
+		})
+
+		It("should aggregate the queries for namespaces without imported services", func() {
+			metrics.recordQuery(namespace1, 0)
+			metrics.recordQuery("unknown", 3)
+
+			Expect(testutil.ToFloat64(namespaceQueriesCount.WithLabelValues(namespace1, "NOERROR"))).To(Equal(1.0))
+			Expect(testutil.ToFloat64(namespaceQueriesCount.WithLabelValues(otherNamespaces, "NXDOMAIN"))).To(Equal(1.0))
+		})
+	})
+
+	When("an allow-list is set", func() {
+		BeforeEach(func() {
+			metrics.setAllowed(map[string]bool{namespace1: true})
+		})
+
+		It("should aggregate the namespaces which aren't in it", func() {
+			Expect(testutil.ToFloat64(importedServicesGauge.WithLabelValues(namespace1))).To(Equal(1.0))
+			Expect(testutil.ToFloat64(importedServicesGauge.WithLabelValues(otherNamespaces))).To(Equal(1.0))
+
+			metrics.recordQuery(namespace2, 0)
+			Expect(testutil.ToFloat64(namespaceQueriesCount.WithLabelValues(otherNamespaces, "NOERROR"))).To(Equal(1.0))
+		})
+	})
+
+	When("the last service in a namespace is removed", func() {
+		It("should stop reporting the namespace", func() {
+			siStore.Remove(newServiceImport(namespace2, service1, clusterID, serviceIP2, portName1, portNumber1, protocol1,
+				mcsv1a1.ClusterSetIP))
+			Expect(testutil.CollectAndCount(importedServicesGauge)).To(Equal(1))
+		})
+	})
+})
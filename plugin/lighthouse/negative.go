@@ -0,0 +1,158 @@
+/*
+SPDX-License-Identifier: Apache-2.0
+
+Copyright Contributors to the Submariner project.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package lighthouse
+
+import (
+	"sync"
+	"time"
+)
+
+const (
+	defaultNegativeTTL       = uint32(30)
+	defaultNegativeCacheSize = 10000
+)
+
+// negativeCache remembers the queries which were answered with NXDOMAIN or NODATA, so that repeated misses, e.g. from
+// misconfigured clients probing services which aren't exported, are answered without resolving them again. Entries
+// expire after the negative TTL, which is also the one given to resolvers in the SOA record of NODATA responses, and
+// all the entries in a namespace are dropped whenever a service in it changes, so that a service is answered as soon
+// as it's imported.
+type negativeCache struct {
+	ttl  uint32
+	size int
+	now  func() time.Time
+
+	mutex sync.Mutex
+	// entries holds the cached misses, by namespace
+	entries map[string]map[negativeKey]negativeEntry
+	count   int
+}
+
+type negativeKey struct {
+	qname string
+	qtype uint16
+}
+
+type negativeEntry struct {
+	nxdomain bool
+	expires  time.Time
+}
+
+func newNegativeCache() *negativeCache {
+	return &negativeCache{
+		ttl:     defaultNegativeTTL,
+		size:    defaultNegativeCacheSize,
+		now:     time.Now,
+		entries: map[string]map[negativeKey]negativeEntry{},
+	}
+}
+
+// get returns whether the given query was last answered with NXDOMAIN rather than NODATA, and true, if it's a cached
+// miss which hasn't expired.
+func (c *negativeCache) get(namespace, qname string, qtype uint16) (nxdomain, found bool) {
+	if c == nil {
+		return false, false
+	}
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	entry, ok := c.entries[namespace][negativeKey{qname: qname, qtype: qtype}]
+	if !ok || !c.now().Before(entry.expires) {
+		return false, false
+	}
+
+	return entry.nxdomain, true
+}
+
+// add caches a miss, NXDOMAIN or NODATA, for the given query. If the cache is full, the expired entries are dropped first, and the miss
+// isn't cached if that isn't enough.
+func (c *negativeCache) add(namespace, qname string, qtype uint16, nxdomain bool) {
+	if c == nil {
+		return
+	}
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	now := c.now()
+
+	if c.count >= c.size {
+		c.purge(now)
+
+		if c.count >= c.size {
+			return
+		}
+	}
+
+	namespaceEntries := c.entries[namespace]
+	if namespaceEntries == nil {
+		namespaceEntries = map[negativeKey]negativeEntry{}
+		c.entries[namespace] = namespaceEntries
+	}
+
+	key := negativeKey{qname: qname, qtype: qtype}
+	if _, exists := namespaceEntries[key]; !exists {
+		c.count++
+	}
+
+	namespaceEntries[key] = negativeEntry{nxdomain: nxdomain, expires: now.Add(time.Duration(c.ttl) * time.Second)}
+}
+
+// invalidate drops the cached misses in the given namespace.
+func (c *negativeCache) invalidate(namespace string) {
+	if c == nil {
+		return
+	}
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	c.count -= len(c.entries[namespace])
+	delete(c.entries, namespace)
+}
+
+// purge drops the expired entries. It must be called with the mutex held.
+func (c *negativeCache) purge(now time.Time) {
+	for namespace, namespaceEntries := range c.entries {
+		for key, entry := range namespaceEntries {
+			if !now.Before(entry.expires) {
+				delete(namespaceEntries, key)
+				c.count--
+			}
+		}
+
+		if len(namespaceEntries) == 0 {
+			delete(c.entries, namespace)
+		}
+	}
+}
+
+// negativeTTL returns the TTL of NODATA responses: the one configured for negative answers, if any, otherwise the
+// negative TTL if misses are cached, otherwise the TTL.
+func (lh *Lighthouse) negativeTTL() uint32 {
+	if ttl, found := lh.recordTTLs[negativeTTL]; found {
+		return ttl
+	}
+
+	if lh.negative != nil {
+		return lh.negative.ttl
+	}
+
+	return lh.getTTL()
+}
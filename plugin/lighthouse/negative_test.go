@@ -0,0 +1,100 @@
+/*
+SPDX-License-Identifier: Apache-2.0
+
+Copyright Contributors to the Submariner project.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package lighthouse
+
+import (
+	"time"
+
+	"github.com/miekg/dns"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Negative cache", func() {
+	const (
+		qname1 = "service1.namespace1.svc.clusterset.local."
+		qname2 = "service2.namespace1.svc.clusterset.local."
+		qname3 = "service1.namespace2.svc.clusterset.local."
+	)
+
+	var (
+		cache *negativeCache
+		now   time.Time
+	)
+
+	BeforeEach(func() {
+		now = time.Now()
+		cache = newNegativeCache()
+		cache.now = func() time.Time {
+			return now
+		}
+	})
+
+	expectCached := func(namespace, qname string, qtype uint16, expected bool) {
+		_, found := cache.get(namespace, qname, qtype)
+		Expect(found).To(Equal(expected))
+	}
+
+	When("a miss is cached", func() {
+		BeforeEach(func() {
+			cache.add("namespace1", qname1, dns.TypeA, true)
+		})
+
+		It("should return it for the same query only", func() {
+			nxdomain, found := cache.get("namespace1", qname1, dns.TypeA)
+			Expect(found).To(BeTrue())
+			Expect(nxdomain).To(BeTrue())
+
+			expectCached("namespace1", qname1, dns.TypeAAAA, false)
+			expectCached("namespace1", qname2, dns.TypeA, false)
+		})
+
+		It("should expire it after the negative TTL", func() {
+			now = now.Add(time.Duration(defaultNegativeTTL-1) * time.Second)
+			expectCached("namespace1", qname1, dns.TypeA, true)
+
+			now = now.Add(time.Second)
+			expectCached("namespace1", qname1, dns.TypeA, false)
+		})
+
+		It("should drop it when its namespace is invalidated", func() {
+			cache.add("namespace2", qname3, dns.TypeA, false)
+
+			cache.invalidate("namespace1")
+			expectCached("namespace1", qname1, dns.TypeA, false)
+			expectCached("namespace2", qname3, dns.TypeA, true)
+		})
+	})
+
+	When("the cache is full", func() {
+		BeforeEach(func() {
+			cache.size = 2
+			cache.add("namespace1", qname1, dns.TypeA, true)
+			cache.add("namespace1", qname2, dns.TypeA, true)
+		})
+
+		It("should not cache more misses until entries expire", func() {
+			cache.add("namespace2", qname3, dns.TypeA, true)
+			expectCached("namespace2", qname3, dns.TypeA, false)
+
+			now = now.Add(time.Duration(defaultNegativeTTL) * time.Second)
+			cache.add("namespace2", qname3, dns.TypeA, true)
+			expectCached("namespace2", qname3, dns.TypeA, true)
+		})
+	})
+})
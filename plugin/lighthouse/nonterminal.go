@@ -0,0 +1,88 @@
+/*
+SPDX-License-Identifier: Apache-2.0
+
+Copyright Contributors to the Submariner project.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package lighthouse
+
+import (
+	"context"
+	"strings"
+
+	"github.com/coredns/coredns/plugin"
+	"github.com/coredns/coredns/request"
+	"github.com/miekg/dns"
+)
+
+// emptyNonTerminal checks whether the request is for a name which owns no records of the queried type but has names
+// below it which do: the namespaces of the services, and the labels of their SRV records queried for other types.
+// Resolvers minimizing the names they query (RFC 9156) query these on their way to the services' names, and give up
+// on an NXDOMAIN response since nothing can then exist below (RFC 8020). The second value is whether the name exists.
+func (lh *Lighthouse) emptyNonTerminal(state request.Request, pReq recordRequest) (bool, bool) {
+	switch {
+	case pReq.service == "":
+		return true, len(lh.namespaceServices(pReq.namespace)) > 0
+	case pReq.protocol != "" && state.QType() != dns.TypeSRV:
+		return true, lh.hasSRVName(pReq)
+	}
+
+	return false, false
+}
+
+// hasSRVName checks whether the service has a port matching the requested protocol, and port name if any, that is
+// whether it has SRV records owned by or below the requested name. If no cluster is available, we can't tell.
+func (lh *Lighthouse) hasSRVName(pReq recordRequest) bool {
+	dnsRecords, _, found := lh.getDNSRecords(recordRequest{
+		service:            pReq.service,
+		namespace:          pReq.namespace,
+		ignoreConnectivity: true,
+	})
+	if !found {
+		return false
+	}
+
+	if len(dnsRecords) == 0 {
+		return true
+	}
+
+	for i := range dnsRecords {
+		for _, port := range dnsRecords[i].Ports {
+			portReq := pReq
+			if portReq.port == "" {
+				portReq.port = strings.ToLower(port.Name)
+			}
+
+			if portMatches(port, portReq) {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+// emptyNonTerminalResponse answers a query for a name owning no records: with NODATA if it exists, so that resolvers
+// carry on with the names below it, otherwise with NXDOMAIN.
+func (lh *Lighthouse) emptyNonTerminalResponse(ctx context.Context, state request.Request, exists bool) (int, error) {
+	if !exists {
+		return lh.nextOrFailure(state.Name(), ctx, state.W, state.Req, dns.RcodeNameError, "record not found")
+	}
+
+	if lh.Fall.Through(state.Name()) {
+		return plugin.NextOrFailure(lh.Name(), lh.Next, ctx, state.W, state.Req)
+	}
+
+	return lh.noData(ctx, state)
+}
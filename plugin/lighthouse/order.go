@@ -0,0 +1,58 @@
+/*
+SPDX-License-Identifier: Apache-2.0
+
+Copyright Contributors to the Submariner project.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package lighthouse
+
+import (
+	"bytes"
+	"net"
+	"sort"
+	"strings"
+
+	"github.com/submariner-io/lighthouse/pkg/serviceimport"
+)
+
+// sortRecords returns the given records sorted by cluster ID, then by IP and hostname, so that the responses with
+// several records list them in the same order for every query, on every instance. The given slice, which can be shared
+// with concurrent queries, isn't modified.
+func sortRecords(dnsRecords []serviceimport.DNSRecord) []serviceimport.DNSRecord {
+	sorted := append([]serviceimport.DNSRecord{}, dnsRecords...)
+
+	sort.SliceStable(sorted, func(i, j int) bool {
+		if sorted[i].ClusterName != sorted[j].ClusterName {
+			return sorted[i].ClusterName < sorted[j].ClusterName
+		}
+
+		if c := compareIPs(sorted[i].IP, sorted[j].IP); c != 0 {
+			return c < 0
+		}
+
+		return sorted[i].HostName < sorted[j].HostName
+	})
+
+	return sorted
+}
+
+// compareIPs compares the given IPs numerically, IPv4 addresses first, or as strings if they aren't both IPs.
+func compareIPs(a, b string) int {
+	ipA, ipB := net.ParseIP(a), net.ParseIP(b)
+	if ipA == nil || ipB == nil {
+		return strings.Compare(a, b)
+	}
+
+	return bytes.Compare(ipA.To16(), ipB.To16())
+}
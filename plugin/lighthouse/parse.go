@@ -18,9 +18,12 @@ limitations under the License.
 package lighthouse
 
 import (
+	"strings"
+
 	"github.com/coredns/coredns/plugin/pkg/dnsutil"
 	"github.com/coredns/coredns/request"
 	"github.com/miekg/dns"
+	"github.com/submariner-io/lighthouse/pkg/dnsname"
 )
 
 // NOTE: This is taken from github.com/coredns/plugin/kubernetes/parse.go with changes to support use cases in
@@ -42,8 +45,28 @@ type recordRequest struct {
 	namespace string
 	// A each name can be for a pod or a service, here we track what we've seen, either "pod" or "service".
 	podOrSvc string
+	// The client the query was made by, set when selecting clusters by hashing it.
+	client string
+	// The query log entry to fill in, if the query is logged.
+	logEntry *queryLogEntry
+	// The CNAME record answering for an alias of the service, if the query is for one and aliases are answered with
+	// CNAMEs.
+	cname *dns.CNAME
+	// Whether clusters are eligible regardless of their connectivity, to answer from disconnected clusters.
+	ignoreConnectivity bool
+	// Whether the answer is prefetched rather than queried, so that it isn't accounted for as an answer.
+	prefetched bool
 }
 
+const (
+	// allSRVPrefixes, the default, parses the _port._protocol prefixes of SRV owner names whatever the queried type
+	allSRVPrefixes = "all"
+	// srvSRVPrefixes only parses them in SRV requests, so that other requests are for clusters and hostnames
+	srvSRVPrefixes = "srv"
+	// noSRVPrefixes never parses them, so that SRV requests are for the same names as A requests
+	noSRVPrefixes = "none"
+)
+
 // parseRequest parses the qname to find all the elements we need for querying lighthouse.
 // 3 Possible cases:
 // 1. (host): host.cluster.service.namespace.pod|svc.zone
@@ -51,7 +74,7 @@ type recordRequest struct {
 // 3. (service): service.namespace.pod|svc.zone
 //
 // Federations are handled in the federation plugin. And aren't parsed here.
-func parseRequest(state request.Request) (r recordRequest, err error) {
+func parseRequest(state request.Request, srvPrefixes string) (r recordRequest, err error) {
 	base, _ := dnsutil.TrimZone(state.Name(), state.Zone)
 	// return NODATA for apex queries
 	if base == "" || base == Svc || base == Pod {
@@ -59,6 +82,15 @@ func parseRequest(state request.Request) (r recordRequest, err error) {
 	}
 
 	segs := dns.SplitDomainName(base)
+	for i := range segs {
+		label, err := canonicalLabel(segs[i])
+		if err != nil {
+			return r, errInvalidRequest
+		}
+
+		segs[i] = label
+	}
+
 	// for r.name, r.namespace and r.cluster, we need to know if they have been set or not...
 	// For cluster: if empty we should skip the cluster check in k.get(). Hence we cannot set if to "*".
 	// For name: myns.svc.cluster.local != *.myns.svc.cluster.local
@@ -95,7 +127,7 @@ func parseRequest(state request.Request) (r recordRequest, err error) {
 		return r, nil
 	}
 
-	return parseSegments(segs, last, r, state)
+	return parseSegments(segs, last, r, state, srvPrefixes)
 }
 
 // String return a string representation of r, it just returns all fields concatenated with dots.
@@ -110,10 +142,34 @@ func (r recordRequest) String() string {
 	return s
 }
 
-func parseSegments(segs []string, count int, r recordRequest, state request.Request) (recordRequest, error) {
+func parseSegments(segs []string, count int, r recordRequest, state request.Request, srvPrefixes string) (recordRequest, error) {
+	qtype := state.QType()
+	anyTypePrefixes := srvPrefixes != srvSRVPrefixes && srvPrefixes != noSRVPrefixes
+
+	// Without SRV prefixes, the labels of SRV requests are those of A requests
+	if srvPrefixes == noSRVPrefixes && qtype == dns.TypeSRV {
+		qtype = dns.TypeA
+	}
+
 	// Because of ambiguity we check the labels left: 1: a cluster. 2: hostname and cluster.
 	// Anything else is a query that is too long to answer and can safely be delegated to return an nxdomain.
-	if state.QType() == dns.TypeA {
+	// The owner names of SRV records and the protocol labels above them are also queried for other types, e.g. by
+	// resolvers minimizing the names they query: they exist, but own no records of these types.
+	if anyTypePrefixes && qtype != dns.TypeSRV && strings.HasPrefix(segs[count], "_") {
+		switch {
+		case count == 0: // protocol only
+			r.protocol = stripUnderscore(segs[count])
+		case count == 1 && strings.HasPrefix(segs[count-1], "_"): // port and protocol
+			r.protocol = stripUnderscore(segs[count])
+			r.port = stripUnderscore(segs[count-1])
+		default:
+			return r, errInvalidRequest
+		}
+
+		return r, nil
+	}
+
+	if qtype == dns.TypeA {
 		switch count {
 		case 0: // cluster only
 			r.cluster = segs[count]
@@ -123,7 +179,7 @@ func parseSegments(segs []string, count int, r recordRequest, state request.Requ
 		default: // too long
 			return r, errInvalidRequest
 		}
-	} else if state.QType() == dns.TypeSRV {
+	} else if qtype == dns.TypeSRV {
 		switch count {
 		case 0: // cluster only
 			r.cluster = segs[count]
@@ -143,6 +199,44 @@ func parseSegments(segs []string, count int, r recordRequest, state request.Requ
 	return r, nil
 }
 
+// canonicalLabel returns the given label of a query name unescaped and lower-cased, as the names of the services,
+// namespaces and clusters it's matched against are, so that queries escaping characters (e.g. \065 for A) or varying
+// their case (e.g. for DNS 0x20 randomization) are answered. The answers keep the name as queried. Internationalized
+// labels, sent as raw UTF-8, are encoded as the A-labels the names are exported as; invalid ones return an error.
+func canonicalLabel(label string) (string, error) {
+	if !strings.Contains(label, "\\") {
+		return dnsname.ToASCII(label)
+	}
+
+	var b strings.Builder
+
+	for i := 0; i < len(label); i++ {
+		if label[i] != '\\' || i+1 == len(label) {
+			b.WriteByte(label[i])
+			continue
+		}
+
+		// \DDD is the character with the decimal value DDD, \X is X itself
+		if i+3 < len(label) && isDigit(label[i+1]) && isDigit(label[i+2]) && isDigit(label[i+3]) {
+			if value := int(label[i+1]-'0')*100 + int(label[i+2]-'0')*10 + int(label[i+3]-'0'); value <= 0xff {
+				b.WriteByte(byte(value))
+				i += 3
+
+				continue
+			}
+		}
+
+		b.WriteByte(label[i+1])
+		i++
+	}
+
+	return dnsname.ToASCII(b.String())
+}
+
+func isDigit(c byte) bool {
+	return c >= '0' && c <= '9'
+}
+
 // stripUnderscore removes a prefixed underscore from s.
 func stripUnderscore(s string) string {
 	if s[0] != '_' {
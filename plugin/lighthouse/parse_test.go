@@ -29,6 +29,7 @@ const bareResult = "...."
 var _ = Describe("[parse] Test parse DNS request", func() {
 	Context("When request is valid", testParseValid)
 	Context("When request is invalid", testParseInvalid)
+	Context("When request isn't canonical", testParseCanonical)
 })
 
 func testParseValid() {
@@ -43,7 +44,7 @@ func testParseValid() {
 			m := new(dns.Msg)
 			m.SetQuestion(tc.query, dns.TypeA)
 			state := request.Request{Zone: zone, Req: m}
-			r, e := parseRequest(state)
+			r, e := parseRequest(state, allSRVPrefixes)
 			Expect(e).NotTo(HaveOccurred())
 			Expect(r.String()).Should(Equal(tc.expected))
 		})
@@ -54,7 +55,7 @@ func testParseValid() {
 			m := new(dns.Msg)
 			m.SetQuestion(tc.query, dns.TypeA)
 			state := request.Request{Zone: zone, Req: m}
-			r, e := parseRequest(state)
+			r, e := parseRequest(state, allSRVPrefixes)
 			Expect(e).NotTo(HaveOccurred())
 			Expect(r.String()).Should(Equal(tc.expected))
 		})
@@ -65,7 +66,7 @@ func testParseValid() {
 			m := new(dns.Msg)
 			m.SetQuestion(tc.query, dns.TypeA)
 			state := request.Request{Zone: zone, Req: m}
-			r, e := parseRequest(state)
+			r, e := parseRequest(state, allSRVPrefixes)
 			Expect(e).NotTo(HaveOccurred())
 			Expect(r.String()).Should(Equal(tc.expected))
 		})
@@ -76,7 +77,7 @@ func testParseValid() {
 			m := new(dns.Msg)
 			m.SetQuestion(tc.query, dns.TypeA)
 			state := request.Request{Zone: zone, Req: m}
-			r, e := parseRequest(state)
+			r, e := parseRequest(state, allSRVPrefixes)
 			Expect(e).NotTo(HaveOccurred())
 			Expect(r.String()).Should(Equal(tc.expected))
 		})
@@ -87,7 +88,7 @@ func testParseValid() {
 			m := new(dns.Msg)
 			m.SetQuestion(tc.query, dns.TypeA)
 			state := request.Request{Zone: zone, Req: m}
-			r, e := parseRequest(state)
+			r, e := parseRequest(state, allSRVPrefixes)
 			Expect(e).NotTo(HaveOccurred())
 			Expect(r.String()).Should(Equal(tc.expected))
 		})
@@ -98,7 +99,7 @@ func testParseValid() {
 			m := new(dns.Msg)
 			m.SetQuestion(tc.query, dns.TypeA)
 			state := request.Request{Zone: zone, Req: m}
-			r, e := parseRequest(state)
+			r, e := parseRequest(state, allSRVPrefixes)
 			Expect(e).NotTo(HaveOccurred())
 			Expect(r.String()).Should(Equal(tc.expected))
 		})
@@ -109,11 +110,90 @@ func testParseValid() {
 			m := new(dns.Msg)
 			m.SetQuestion(tc.query, dns.TypeA)
 			state := request.Request{Zone: zone, Req: m}
-			r, e := parseRequest(state)
+			r, e := parseRequest(state, allSRVPrefixes)
 			Expect(e).NotTo(HaveOccurred())
 			Expect(r.String()).Should(Equal(tc.expected))
 		})
 	})
+	When("An A request of an SRV owner name", func() {
+		It("Should give no error", func() {
+			m := new(dns.Msg)
+			m.SetQuestion("_http._tcp.webs.mynamespace.svc.inter.webs.tests.", dns.TypeA)
+			state := request.Request{Zone: zone, Req: m}
+			r, e := parseRequest(state, allSRVPrefixes)
+			Expect(e).NotTo(HaveOccurred())
+			Expect(r.String()).Should(Equal("..webs.mynamespace.svc"))
+			Expect(r.port).Should(Equal("http"))
+			Expect(r.protocol).Should(Equal("tcp"))
+		})
+	})
+	When("An A request of an SRV owner name with SRV prefixes only parsed for SRV requests", func() {
+		It("Should parse the prefixes as hostname and cluster", func() {
+			m := new(dns.Msg)
+			m.SetQuestion("_http._tcp.webs.mynamespace.svc.inter.webs.tests.", dns.TypeA)
+			state := request.Request{Zone: zone, Req: m}
+			r, e := parseRequest(state, srvSRVPrefixes)
+			Expect(e).NotTo(HaveOccurred())
+			Expect(r.String()).Should(Equal("_http._tcp.webs.mynamespace.svc"))
+			Expect(r.protocol).Should(BeEmpty())
+		})
+	})
+	When("An SRV request with SRV prefixes disabled", func() {
+		It("Should parse the prefixes as hostname and cluster", func() {
+			m := new(dns.Msg)
+			m.SetQuestion("_http._tcp.webs.mynamespace.svc.inter.webs.tests.", dns.TypeSRV)
+			state := request.Request{Zone: zone, Req: m}
+			r, e := parseRequest(state, noSRVPrefixes)
+			Expect(e).NotTo(HaveOccurred())
+			Expect(r.String()).Should(Equal("_http._tcp.webs.mynamespace.svc"))
+			Expect(r.port).Should(BeEmpty())
+		})
+	})
+}
+
+func testParseCanonical() {
+	When("a request has mixed-case labels", func() {
+		It("should parse them lower-cased", func() {
+			m := new(dns.Msg)
+			m.SetQuestion("Cluster1.WEBS.MyNamespace.SVC.inter.webs.tests.", dns.TypeA)
+			state := request.Request{Zone: zone, Req: m}
+			r, e := parseRequest(state, allSRVPrefixes)
+			Expect(e).NotTo(HaveOccurred())
+			Expect(r.String()).Should(Equal(".cluster1.webs.mynamespace.svc"))
+		})
+	})
+
+	When("a request has escaped labels", func() {
+		It("should parse them unescaped", func() {
+			m := new(dns.Msg)
+			m.SetQuestion("\\087eb\\s.my\\110amespace.svc.inter.webs.tests.", dns.TypeA)
+			state := request.Request{Zone: zone, Req: m}
+			r, e := parseRequest(state, allSRVPrefixes)
+			Expect(e).NotTo(HaveOccurred())
+			Expect(r.String()).Should(Equal("..webs.mynamespace.svc"))
+		})
+	})
+
+	When("a request has internationalized labels", func() {
+		It("should parse them encoded as A-labels", func() {
+			m := new(dns.Msg)
+			m.SetQuestion("Caf\\195\\137.XN--NXASMQ6B.svc.inter.webs.tests.", dns.TypeA)
+			state := request.Request{Zone: zone, Req: m}
+			r, e := parseRequest(state, allSRVPrefixes)
+			Expect(e).NotTo(HaveOccurred())
+			Expect(r.String()).Should(Equal("..xn--caf-dma.xn--nxasmq6b.svc"))
+		})
+	})
+
+	When("a request has an invalid A-label", func() {
+		It("should give an error", func() {
+			m := new(dns.Msg)
+			m.SetQuestion("xn--b.mynamespace.svc.inter.webs.tests.", dns.TypeA)
+			state := request.Request{Zone: zone, Req: m}
+			_, e := parseRequest(state, allSRVPrefixes)
+			Expect(e).To(HaveOccurred())
+		})
+	})
 }
 
 func testParseInvalid() {
@@ -122,7 +202,7 @@ func testParseInvalid() {
 			m := new(dns.Msg)
 			m.SetQuestion("webs.mynamespace.pood.inter.webs.test.", dns.TypeA)
 			state := request.Request{Zone: zone, Req: m}
-			_, e := parseRequest(state)
+			_, e := parseRequest(state, allSRVPrefixes)
 			Expect(e).To(HaveOccurred())
 		})
 	})
@@ -131,7 +211,7 @@ func testParseInvalid() {
 			m := new(dns.Msg)
 			m.SetQuestion("too.long.for.what.I.am.trying.to.pod.inter.webs.tests.", dns.TypeA)
 			state := request.Request{Zone: zone, Req: m}
-			_, e := parseRequest(state)
+			_, e := parseRequest(state, allSRVPrefixes)
 			Expect(e).To(HaveOccurred())
 		})
 	})
@@ -0,0 +1,220 @@
+/*
+SPDX-License-Identifier: Apache-2.0
+
+Copyright Contributors to the Submariner project.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package lighthouse
+
+import (
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/coredns/coredns/request"
+	"github.com/miekg/dns"
+	"github.com/submariner-io/lighthouse/pkg/resolver"
+)
+
+const (
+	defaultPrefetchCount = 100
+	// prefetchDelay is how long refreshes wait after a change, so that the changes of a rollout, which come in bursts,
+	// are refreshed together
+	prefetchDelay = 100 * time.Millisecond
+	// prefetchDecayInterval is the interval at which the query counts are halved, so that names which are no longer
+	// queried stop being hot
+	prefetchDecayInterval = time.Minute
+	// prefetchTrackedFactor bounds the number of names tracked, as a multiple of the number of hot names
+	prefetchTrackedFactor = 10
+)
+
+// prefetcher counts the queries for each name and, when the records of the service a hot name, i.e. one of the most
+// queried ones, is answered from change, rebuilds its answer in the background, so that the next query doesn't pay
+// for it. The resource records are cached along with the records they're built from, which are only replaced when
+// they change, so changes rather than TTLs drive the refreshes. It does nothing until its refresh function is set.
+type prefetcher struct {
+	count   int
+	delay   time.Duration
+	now     func() time.Time
+	refresh func(*hotName)
+
+	mutex sync.Mutex
+	// names holds the tracked names, by flight key
+	names     map[string]*hotName
+	decayed   time.Time
+	pending   map[string]bool
+	scheduled bool
+}
+
+// hotName is a tracked name, with the query it was last answered for.
+type hotName struct {
+	key     string
+	zone    string
+	qname   string
+	qtype   uint16
+	qclass  uint16
+	pReq    recordRequest
+	queries uint64
+}
+
+func newPrefetcher() *prefetcher {
+	return &prefetcher{
+		count:   defaultPrefetchCount,
+		delay:   prefetchDelay,
+		now:     time.Now,
+		names:   map[string]*hotName{},
+		pending: map[string]bool{},
+	}
+}
+
+// record counts a query answered from the records of the service named by the given request.
+func (p *prefetcher) record(key, zone string, state request.Request, pReq recordRequest) {
+	if p == nil || p.refresh == nil {
+		return
+	}
+
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	if now := p.now(); now.Sub(p.decayed) >= prefetchDecayInterval {
+		p.decay()
+		p.decayed = now
+	}
+
+	name, ok := p.names[key]
+	if !ok {
+		if len(p.names) >= p.count*prefetchTrackedFactor {
+			p.decay()
+
+			if len(p.names) >= p.count*prefetchTrackedFactor {
+				return
+			}
+		}
+
+		pReq.logEntry = nil
+		pReq.prefetched = true
+		name = &hotName{key: key, zone: zone, qname: state.QName(), qtype: state.QType(), qclass: state.QClass(), pReq: pReq}
+		p.names[key] = name
+	}
+
+	name.queries++
+}
+
+// decay halves the query counts and stops tracking the names which haven't been queried since the last decay. It
+// must be called with the mutex held.
+func (p *prefetcher) decay() {
+	for key, name := range p.names {
+		name.queries /= 2
+		if name.queries == 0 {
+			delete(p.names, key)
+		}
+	}
+}
+
+// hot returns the hot names, most queried first. It must be called with the mutex held.
+func (p *prefetcher) hot() []*hotName {
+	names := make([]*hotName, 0, len(p.names))
+	for _, name := range p.names {
+		names = append(names, name)
+	}
+
+	sort.Slice(names, func(i, j int) bool {
+		if names[i].queries != names[j].queries {
+			return names[i].queries > names[j].queries
+		}
+
+		return names[i].key < names[j].key
+	})
+
+	if len(names) > p.count {
+		names = names[:p.count]
+	}
+
+	return names
+}
+
+// serviceChanged schedules the refresh of the hot names answered from the given service.
+func (p *prefetcher) serviceChanged(name, namespace string) {
+	if p == nil || p.refresh == nil || name == "" || namespace == "" {
+		return
+	}
+
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	p.pending[namespace+"/"+name] = true
+
+	if !p.scheduled {
+		p.scheduled = true
+
+		time.AfterFunc(p.delay, p.run)
+	}
+}
+
+// run refreshes the hot names answered from the services which changed since the last run.
+func (p *prefetcher) run() {
+	p.mutex.Lock()
+
+	pending := p.pending
+	p.pending = map[string]bool{}
+	p.scheduled = false
+
+	names := []*hotName{}
+
+	for _, name := range p.hot() {
+		if pending[name.pReq.namespace+"/"+name.pReq.service] {
+			names = append(names, name)
+		}
+	}
+
+	p.mutex.Unlock()
+
+	for _, name := range names {
+		p.refresh(name)
+		prefetchesCount.Inc()
+	}
+}
+
+// hotNames returns the hot names with their query counts, for diagnostics.
+func (p *prefetcher) hotNames() []resolver.HotName {
+	if p == nil || p.refresh == nil {
+		return nil
+	}
+
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	hot := p.hot()
+	names := make([]resolver.HotName, 0, len(hot))
+
+	for _, name := range hot {
+		names = append(names, resolver.HotName{Name: name.qname, Type: dns.TypeToString[name.qtype], Queries: name.queries})
+	}
+
+	return names
+}
+
+// prefetchAnswer rebuilds the answer of the given hot name, so that its resource records are cached again. Identical
+// queries made meanwhile share it.
+func (lh *Lighthouse) prefetchAnswer(name *hotName) {
+	req := new(dns.Msg)
+	req.SetQuestion(name.qname, name.qtype)
+	req.Question[0].Qclass = name.qclass
+
+	state := request.Request{Req: req, Zone: name.zone}
+
+	lh.flights.do(name.key, func() *answerSet {
+		return lh.assembleAnswers(name.zone, state, name.pReq)
+	})
+}
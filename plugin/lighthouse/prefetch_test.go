@@ -0,0 +1,185 @@
+/*
+SPDX-License-Identifier: Apache-2.0
+
+Copyright Contributors to the Submariner project.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package lighthouse
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/coredns/coredns/plugin/pkg/dnstest"
+	"github.com/coredns/coredns/plugin/test"
+	"github.com/coredns/coredns/request"
+	"github.com/miekg/dns"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/submariner-io/lighthouse/pkg/resolver"
+	mcsv1a1 "sigs.k8s.io/mcs-api/pkg/apis/v1alpha1"
+)
+
+var _ = Describe("Prefetcher", func() {
+	const (
+		zone     = "clusterset.local."
+		service2 = "service2"
+	)
+
+	var (
+		prefetch  *prefetcher
+		now       time.Time
+		mutex     sync.Mutex
+		refreshed []string
+	)
+
+	BeforeEach(func() {
+		now = time.Now()
+		refreshed = nil
+		prefetch = newPrefetcher()
+		prefetch.count = 2
+		prefetch.delay = time.Millisecond
+		prefetch.now = func() time.Time {
+			return now
+		}
+		prefetch.refresh = func(name *hotName) {
+			mutex.Lock()
+			defer mutex.Unlock()
+
+			refreshed = append(refreshed, name.qname)
+		}
+	})
+
+	getRefreshed := func() []string {
+		mutex.Lock()
+		defer mutex.Unlock()
+
+		return refreshed
+	}
+
+	query := func(service, namespace string, times int) string {
+		qname := fmt.Sprintf("%s.%s.svc.%s", service, namespace, zone)
+		state := request.Request{Req: test.Case{Qname: qname, Qtype: dns.TypeA}.Msg(), Zone: zone}
+		pReq := recordRequest{service: service, namespace: namespace}
+
+		for i := 0; i < times; i++ {
+			prefetch.record(flightKey(state, pReq), zone, state, pReq)
+		}
+
+		return qname
+	}
+
+	When("names are queried", func() {
+		It("should list the most queried ones as hot", func() {
+			qname1 := query(service1, namespace1, 3)
+			qname2 := query(service2, namespace1, 5)
+			query(service1, namespace2, 1)
+
+			Expect(prefetch.hotNames()).To(Equal([]resolver.HotName{
+				{Name: qname2, Type: "A", Queries: 5},
+				{Name: qname1, Type: "A", Queries: 3},
+			}))
+		})
+
+		It("should halve their query counts over time", func() {
+			qname := query(service1, namespace1, 4)
+
+			now = now.Add(prefetchDecayInterval)
+			query(service1, namespace1, 1)
+
+			Expect(prefetch.hotNames()).To(Equal([]resolver.HotName{{Name: qname, Type: "A", Queries: 3}}))
+		})
+	})
+
+	When("the service of a hot name changes", func() {
+		It("should refresh its hot names only", func() {
+			qname := query(service1, namespace1, 3)
+			query(service2, namespace1, 2)
+			query(service1, namespace2, 1)
+
+			prefetch.serviceChanged(service1, namespace1)
+			prefetch.serviceChanged(service1, namespace2)
+
+			Eventually(getRefreshed).Should(Equal([]string{qname}))
+			Consistently(getRefreshed).Should(HaveLen(1))
+		})
+	})
+
+	When("prefetching isn't enabled", func() {
+		BeforeEach(func() {
+			prefetch.refresh = nil
+		})
+
+		It("should neither track names nor refresh them", func() {
+			query(service1, namespace1, 3)
+			prefetch.serviceChanged(service1, namespace1)
+
+			Expect(prefetch.hotNames()).To(BeEmpty())
+			Consistently(getRefreshed).Should(BeEmpty())
+		})
+	})
+})
+
+var _ = Describe("Prefetching", func() {
+	qname := fmt.Sprintf("%s.%s.svc.clusterset.local.", service1, namespace1)
+
+	var (
+		lh       *Lighthouse
+		prefetch chan string
+	)
+
+	BeforeEach(func() {
+		mockCs := NewMockClusterStatus()
+		mockCs.clusterStatusMap[clusterID] = true
+		mockEs := NewMockEndpointStatus()
+		mockEs.endpointStatusMap[clusterID] = true
+
+		lh = &Lighthouse{
+			Zones:           []string{"clusterset.local."},
+			serviceImports:  setupServiceImportMap(),
+			endpointSlices:  setupEndpointSliceMap(),
+			clusterStatus:   mockCs,
+			endpointsStatus: mockEs,
+			localServices:   NewMockLocalServices(),
+			ttl:             defaultTTL,
+			prefetch:        newPrefetcher(),
+		}
+
+		prefetch = make(chan string, 10)
+		lh.prefetch.delay = time.Millisecond
+		lh.prefetch.refresh = func(name *hotName) {
+			lh.prefetchAnswer(name)
+			prefetch <- name.qname
+		}
+	})
+
+	When("a queried service changes", func() {
+		It("should rebuild the answer of its name", func() {
+			rec := dnstest.NewRecorder(&test.ResponseWriter{})
+			code, err := lh.ServeDNS(context.TODO(), rec, test.Case{Qname: qname, Qtype: dns.TypeA}.Msg())
+			Expect(err).To(Succeed())
+			Expect(code).To(Equal(dns.RcodeSuccess))
+
+			Expect(lh.State().HotNames).To(Equal([]resolver.HotName{{Name: qname, Type: "A", Queries: 1}}))
+
+			lh.serviceImports.Put(newServiceImport(namespace1, service1, clusterID, serviceIP2, portName1, portNumber1,
+				protocol1, mcsv1a1.ClusterSetIP))
+			lh.prefetch.serviceChanged(service1, namespace1)
+
+			Eventually(prefetch).Should(Receive(Equal(qname)))
+		})
+	})
+})
@@ -0,0 +1,86 @@
+/*
+SPDX-License-Identifier: Apache-2.0
+
+Copyright Contributors to the Submariner project.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package lighthouse
+
+import (
+	"encoding/json"
+	"io"
+	"math/rand"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/coredns/coredns/request"
+	"github.com/miekg/dns"
+)
+
+// queryLog writes a JSON line for a sample of the queries the plugin serves.
+type queryLog struct {
+	// rate is the fraction of queries logged, between 0 (excluded) and 1
+	rate   float64
+	mutex  sync.Mutex
+	writer io.Writer
+}
+
+// queryLogEntry is filled in while a sampled query is served.
+type queryLogEntry struct {
+	Time     string  `json:"time"`
+	Client   string  `json:"client"`
+	Name     string  `json:"qname"`
+	Type     string  `json:"qtype"`
+	Rcode    string  `json:"rcode"`
+	Cluster  string  `json:"cluster,omitempty"`
+	Duration float64 `json:"duration"`
+}
+
+func newQueryLog(rate float64) *queryLog {
+	return &queryLog{rate: rate, writer: os.Stdout}
+}
+
+// sample returns a new entry if the query should be logged, nil otherwise.
+func (q *queryLog) sample() *queryLogEntry {
+	if q == nil || (q.rate < 1 && rand.Float64() >= q.rate) {
+		return nil
+	}
+
+	return &queryLogEntry{}
+}
+
+func (q *queryLog) write(entry *queryLogEntry, state request.Request, rcode int, start time.Time) {
+	entry.Time = start.UTC().Format(time.RFC3339Nano)
+	entry.Client = state.IP()
+	entry.Name = state.Name()
+	entry.Type = state.Type()
+	entry.Rcode = dns.RcodeToString[rcode]
+	entry.Duration = time.Since(start).Seconds()
+
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+
+	if err := json.NewEncoder(q.writer).Encode(entry); err != nil {
+		log.Errorf("Failed to write the query log: %v", err)
+	}
+}
+
+// answered records the clusters a logged query was answered from.
+func (e *queryLogEntry) answered(clusters []string) {
+	if e != nil {
+		e.Cluster = strings.Join(clusters, ",")
+	}
+}
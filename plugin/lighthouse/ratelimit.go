@@ -0,0 +1,114 @@
+/*
+SPDX-License-Identifier: Apache-2.0
+
+Copyright Contributors to the Submariner project.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package lighthouse
+
+import (
+	"sync"
+	"time"
+
+	"golang.org/x/time/rate"
+)
+
+const (
+	clientRateLimit    = "client"
+	aggregateRateLimit = "total"
+
+	// Clients which haven't queried for this long are forgotten, so that the limiters of short-lived pods don't pile up
+	idleClientTimeout = 5 * time.Minute
+)
+
+// queryLimiter limits the queries answered to a number per second per client and overall, with token buckets allowing
+// bursts. A nil limiter allows every query.
+type queryLimiter struct {
+	mutex     sync.Mutex
+	limit     rate.Limit
+	burst     int
+	aggregate *rate.Limiter
+	clients   map[string]*clientLimiter
+	lastSweep time.Time
+	now       func() time.Time
+}
+
+type clientLimiter struct {
+	limiter  *rate.Limiter
+	lastSeen time.Time
+}
+
+func newQueryLimiter() *queryLimiter {
+	return &queryLimiter{
+		limit:   rate.Inf,
+		clients: map[string]*clientLimiter{},
+		now:     time.Now,
+	}
+}
+
+// setClientLimit limits each client to limit queries per second, with bursts of up to burst queries.
+func (l *queryLimiter) setClientLimit(limit float64, burst int) {
+	l.limit = rate.Limit(limit)
+	l.burst = burst
+}
+
+// setAggregateLimit limits all the clients together to limit queries per second, with bursts of up to burst queries.
+func (l *queryLimiter) setAggregateLimit(limit float64, burst int) {
+	l.aggregate = rate.NewLimiter(rate.Limit(limit), burst)
+}
+
+// allow returns whether a query from the given client may be answered, and if not, the limit it exceeds.
+func (l *queryLimiter) allow(client string) (bool, string) {
+	if l == nil {
+		return true, ""
+	}
+
+	now := l.now()
+
+	if l.limit != rate.Inf && !l.clientLimiter(client, now).AllowN(now, 1) {
+		return false, clientRateLimit
+	}
+
+	// Queries refused to a client don't count towards the aggregate limit, so that one client can't starve the others
+	if l.aggregate != nil && !l.aggregate.AllowN(now, 1) {
+		return false, aggregateRateLimit
+	}
+
+	return true, ""
+}
+
+func (l *queryLimiter) clientLimiter(client string, now time.Time) *rate.Limiter {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	if now.Sub(l.lastSweep) > idleClientTimeout {
+		for key, c := range l.clients {
+			if now.Sub(c.lastSeen) > idleClientTimeout {
+				delete(l.clients, key)
+			}
+		}
+
+		l.lastSweep = now
+	}
+
+	c, ok := l.clients[client]
+	if !ok {
+		c = &clientLimiter{limiter: rate.NewLimiter(l.limit, l.burst)}
+		l.clients[client] = c
+	}
+
+	c.lastSeen = now
+
+	return c.limiter
+}
@@ -0,0 +1,119 @@
+/*
+SPDX-License-Identifier: Apache-2.0
+
+Copyright Contributors to the Submariner project.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package lighthouse
+
+import (
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Query rate limiting", func() {
+	var (
+		limiter *queryLimiter
+		now     time.Time
+	)
+
+	BeforeEach(func() {
+		now = time.Now()
+		limiter = newQueryLimiter()
+		limiter.now = func() time.Time {
+			return now
+		}
+	})
+
+	When("a client exceeds its limit", func() {
+		BeforeEach(func() {
+			limiter.setClientLimit(10, 2)
+		})
+
+		It("should refuse its queries beyond the burst until tokens are replenished", func() {
+			Expect(limiter.allow("10.0.0.1")).To(BeTrue())
+			Expect(limiter.allow("10.0.0.1")).To(BeTrue())
+
+			allowed, limit := limiter.allow("10.0.0.1")
+			Expect(allowed).To(BeFalse())
+			Expect(limit).To(Equal(clientRateLimit))
+
+			now = now.Add(100 * time.Millisecond)
+			Expect(limiter.allow("10.0.0.1")).To(BeTrue())
+		})
+
+		It("should still allow other clients", func() {
+			for i := 0; i < 3; i++ {
+				limiter.allow("10.0.0.1")
+			}
+
+			Expect(limiter.allow("10.0.0.2")).To(BeTrue())
+		})
+	})
+
+	When("the clients together exceed the total limit", func() {
+		BeforeEach(func() {
+			limiter.setAggregateLimit(10, 2)
+		})
+
+		It("should refuse the queries of every client", func() {
+			Expect(limiter.allow("10.0.0.1")).To(BeTrue())
+			Expect(limiter.allow("10.0.0.2")).To(BeTrue())
+
+			allowed, limit := limiter.allow("10.0.0.3")
+			Expect(allowed).To(BeFalse())
+			Expect(limit).To(Equal(aggregateRateLimit))
+		})
+	})
+
+	When("a client is refused by its own limit", func() {
+		BeforeEach(func() {
+			limiter.setClientLimit(10, 1)
+			limiter.setAggregateLimit(10, 2)
+		})
+
+		It("should not count its queries towards the total", func() {
+			for i := 0; i < 5; i++ {
+				limiter.allow("10.0.0.1")
+			}
+
+			Expect(limiter.allow("10.0.0.2")).To(BeTrue())
+		})
+	})
+
+	When("a client has been idle", func() {
+		BeforeEach(func() {
+			limiter.setClientLimit(10, 1)
+		})
+
+		It("should forget it", func() {
+			limiter.allow("10.0.0.1")
+			Expect(limiter.clients).To(HaveLen(1))
+
+			now = now.Add(2 * idleClientTimeout)
+			limiter.allow("10.0.0.2")
+			Expect(limiter.clients).To(HaveLen(1))
+			Expect(limiter.clients).To(HaveKey("10.0.0.2"))
+		})
+	})
+
+	When("no limiter is configured", func() {
+		It("should allow every query", func() {
+			var noLimiter *queryLimiter
+			Expect(noLimiter.allow("10.0.0.1")).To(BeTrue())
+		})
+	})
+})
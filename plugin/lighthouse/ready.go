@@ -0,0 +1,31 @@
+/*
+SPDX-License-Identifier: Apache-2.0
+
+Copyright Contributors to the Submariner project.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package lighthouse
+
+// Ready implements the ready plugin's Readiness interface. Until the initial ServiceImports and EndpointSlices have
+// been loaded, CoreDNS is reported as not ready, so that a restarted replica doesn't receive queries, and answer
+// NXDOMAIN for services that exist, before it knows about them.
+func (lh *Lighthouse) Ready() bool {
+	if lh.synced == nil || lh.synced() {
+		return true
+	}
+
+	log.Infof("Waiting for the initial ServiceImports and EndpointSlices to be loaded")
+
+	return false
+}
@@ -18,89 +18,607 @@ limitations under the License.
 package lighthouse
 
 import (
+	"hash/fnv"
 	"net"
+	"sort"
 	"strings"
 
 	"github.com/coredns/coredns/request"
 	"github.com/miekg/dns"
+	"github.com/submariner-io/lighthouse/pkg/resolver"
 	"github.com/submariner-io/lighthouse/pkg/serviceimport"
 	"sigs.k8s.io/mcs-api/pkg/apis/v1alpha1"
 )
 
-func (lh *Lighthouse) createARecords(dnsrecords []serviceimport.DNSRecord, state request.Request) []dns.RR {
-	records := make([]dns.RR, 0)
+func (lh *Lighthouse) createARecords(dnsrecords []serviceimport.DNSRecord, state request.Request, isHeadless bool) []dns.RR {
+	return lh.createQueriedAddressRecords(dnsrecords, state, dns.TypeA, isHeadless)
+}
+
+func (lh *Lighthouse) createAAAARecords(dnsrecords []serviceimport.DNSRecord, state request.Request, isHeadless bool) []dns.RR {
+	return lh.createQueriedAddressRecords(dnsrecords, state, dns.TypeAAAA, isHeadless)
+}
+
+// primaryAddressType returns the type of the address records clients primarily query for the clusterset's services:
+// AAAA in IPv6-only mode, A otherwise.
+func (lh *Lighthouse) primaryAddressType() uint16 {
+	if lh.ipv6Only {
+		return dns.TypeAAAA
+	}
+
+	return dns.TypeA
+}
 
-	for _, record := range dnsrecords {
-		dnsRecord := &dns.A{Hdr: dns.RR_Header{Name: state.QName(), Rrtype: dns.TypeA, Class: state.QClass(),
-			Ttl: lh.ttl}, A: net.ParseIP(record.IP).To4()}
-		records = append(records, dnsRecord)
+// additionalAddressType returns the type of the address records answered for SRV targets and in zone transfers: AAAA
+// in IPv6-only mode, zero otherwise for both A and AAAA.
+func (lh *Lighthouse) additionalAddressType() uint16 {
+	if lh.ipv6Only {
+		return dns.TypeAAAA
+	}
+
+	return 0
+}
+
+// createQueriedAddressRecords returns the address records of the given type, A or AAAA, for all the IPs of the given
+// DNS records in the matching family, e.g. the IPv4 and IPv6 cluster IPs of dual-stack services respectively.
+func (lh *Lighthouse) createQueriedAddressRecords(dnsrecords []serviceimport.DNSRecord, state request.Request, qtype uint16,
+	isHeadless bool) []dns.RR {
+	records := make([]dns.RR, 0, len(dnsrecords))
+	key := serviceimport.RRKey{Name: state.Name(), Qtype: qtype, Qclass: state.QClass(), TTL: lh.getAddressTTL(isHeadless)}
+
+	for i := range dnsrecords {
+		record := &dnsrecords[i]
+		records = append(records, withOwner(record.RRs.Get(key, func() []dns.RR {
+			return buildAddressRecords(record.Addresses(), key, qtype)
+		}), state.QName())...)
 	}
 
 	return records
 }
 
+// withOwner returns the given records, built for the lower-cased query name so that the cache isn't defeated by
+// resolvers randomizing the case of the names they query, owned by the name as queried. The cached records are
+// shared, so they're copied if the names differ.
+func withOwner(rrs []dns.RR, qname string) []dns.RR {
+	if len(rrs) == 0 || rrs[0].Header().Name == qname {
+		return rrs
+	}
+
+	owned := make([]dns.RR, len(rrs))
+
+	for i, rr := range rrs {
+		owned[i] = dns.Copy(rr)
+		owned[i].Header().Name = qname
+	}
+
+	return owned
+}
+
+// buildAddressRecords returns the address records of the given type for the IPs in the matching family; a zero type
+// returns records of both types.
+func buildAddressRecords(ips []string, key serviceimport.RRKey, qtype uint16) []dns.RR {
+	records := make([]dns.RR, 0, len(ips))
+
+	for _, address := range ips {
+		ip := net.ParseIP(address)
+		if ip == nil {
+			continue
+		}
+
+		if ip4 := ip.To4(); ip4 != nil {
+			if qtype != dns.TypeAAAA {
+				records = append(records, &dns.A{Hdr: dns.RR_Header{Name: key.Name, Rrtype: dns.TypeA, Class: key.Qclass,
+					Ttl: key.TTL}, A: ip4})
+			}
+		} else if qtype != dns.TypeA {
+			records = append(records, &dns.AAAA{Hdr: dns.RR_Header{Name: key.Name, Rrtype: dns.TypeAAAA, Class: key.Qclass,
+				Ttl: key.TTL}, AAAA: ip})
+		}
+	}
+
+	return records
+}
+
+// createSRVRecords returns the SRV records for the given DNS records along with the address records of their targets,
+// to be added to the additional section of the response.
 func (lh *Lighthouse) createSRVRecords(dnsrecords []serviceimport.DNSRecord, state request.Request, pReq recordRequest, zone string,
+	isHeadless bool) (records, extras []dns.RR) {
+	// The zone keeps the query's case, the records are keyed and their targets named after the canonical zone
+	key := serviceimport.RRKey{Name: state.Name(), Zone: strings.ToLower(zone), Qtype: dns.TypeSRV, Qclass: state.QClass(),
+		TTL: lh.getRecordTTL(srvTTL)}
+
+	for i := range dnsrecords {
+		record := &dnsrecords[i]
+		recordKey := key
+		recordKey.Priority = lh.srvPriority(record.ClusterName)
+
+		srvRecords := withOwner(record.RRs.Get(recordKey, func() []dns.RR {
+			return lh.buildSRVRecords(record, recordKey, pReq, isHeadless)
+		}), state.QName())
+
+		if len(srvRecords) == 0 {
+			return nil, nil
+		}
+
+		records = append(records, srvRecords...)
+		extras = append(extras, lh.createAddressRecords(record, srvRecords[0].(*dns.SRV).Target, state, isHeadless)...)
+	}
+
+	return records, extras
+}
+
+// srvPriority returns the priority of the SRV records targeting the given cluster, so that SRV-aware clients prefer
+// the local cluster but can fail over to the remote ones. All the clusters get the same priority while the local
+// cluster isn't known.
+func (lh *Lighthouse) srvPriority(cluster string) uint16 {
+	local := lh.clusterStatus.LocalClusterID()
+	if cluster == "" || local == "" || cluster == local {
+		return lh.srvLocalPriority
+	}
+
+	return lh.srvRemotePriority
+}
+
+// createHINFORecord returns the HINFO record answering ANY queries as per RFC 8482, so that they can't be used to
+// amplify traffic.
+func (lh *Lighthouse) createHINFORecord(state request.Request) dns.RR {
+	return &dns.HINFO{
+		Hdr: dns.RR_Header{Name: state.QName(), Rrtype: dns.TypeHINFO, Class: state.QClass(), Ttl: lh.getTTL()},
+		Cpu: "RFC8482",
+	}
+}
+
+// createAddressRecords returns the A and AAAA records of the given SRV target for all the record's IPs, only the AAAA
+// records in IPv6-only mode.
+func (lh *Lighthouse) createAddressRecords(record *serviceimport.DNSRecord, target string, state request.Request,
 	isHeadless bool) []dns.RR {
-	var records []dns.RR
-
-	for _, dnsRecord := range dnsrecords {
-		var reqPorts []v1alpha1.ServicePort
-
-		if pReq.port == "" {
-			reqPorts = dnsRecord.Ports
-		} else {
-			log.Debugf("Requested port %q, protocol %q for SRV", pReq.port, pReq.protocol)
-			for _, port := range dnsRecord.Ports {
-				name := strings.ToLower(port.Name)
-				protocol := strings.ToLower(string(port.Protocol))
-
-				log.Debugf("Checking port %q, protocol %q", name, protocol)
-				if name == pReq.port && protocol == pReq.protocol {
-					reqPorts = append(reqPorts, port)
-				}
+	key := serviceimport.RRKey{Name: target, Qtype: dns.TypeANY, Qclass: state.QClass(), TTL: lh.getAddressTTL(isHeadless)}
+
+	return record.RRs.Get(key, func() []dns.RR {
+		return buildAddressRecords(record.Addresses(), key, lh.additionalAddressType())
+	})
+}
+
+func (lh *Lighthouse) buildSRVRecords(dnsRecord *serviceimport.DNSRecord, key serviceimport.RRKey, pReq recordRequest,
+	isHeadless bool) []dns.RR {
+	var reqPorts []v1alpha1.ServicePort
+
+	if pReq.port == "" {
+		reqPorts = dnsRecord.Ports
+	} else {
+		log.Debugf("Requested port %q, protocol %q for SRV", pReq.port, pReq.protocol)
+		for _, port := range dnsRecord.Ports {
+			if portMatches(port, pReq) {
+				reqPorts = append(reqPorts, port)
 			}
 		}
+	}
+
+	records := make([]dns.RR, 0, len(reqPorts))
 
-		if len(reqPorts) == 0 {
-			return nil
+	if len(reqPorts) == 0 {
+		return records
+	}
+
+	target := pReq.service + "." + pReq.namespace + ".svc." + key.Zone
+
+	if isHeadless {
+		target = dnsRecord.ClusterName + "." + target
+	} else if pReq.cluster != "" {
+		target = pReq.cluster + "." + target
+	}
+
+	if isHeadless {
+		target = dnsRecord.HostName + "." + target
+	}
+
+	for _, port := range reqPorts {
+		record := &dns.SRV{
+			Hdr:      dns.RR_Header{Name: key.Name, Rrtype: dns.TypeSRV, Class: key.Qclass, Ttl: key.TTL},
+			Priority: key.Priority,
+			Weight:   50,
+			Port:     uint16(port.Port),
+			Target:   target,
+		}
+		records = append(records, record)
+	}
+
+	return records
+}
+
+// portMatches checks whether the port has the requested name and either the requested L4 protocol or application
+// protocol, so that e.g. _grpc._h2c.service... matches a port named grpc with the kubernetes.io/h2c appProtocol.
+func portMatches(port v1alpha1.ServicePort, pReq recordRequest) bool {
+	name := strings.ToLower(port.Name)
+	protocol := strings.ToLower(string(port.Protocol))
+
+	log.Debugf("Checking port %q, protocol %q", name, protocol)
+
+	if name != pReq.port {
+		return false
+	}
+
+	return protocol == pReq.protocol || appProtocolMatches(port.AppProtocol, pReq.protocol)
+}
+
+// appProtocolMatches checks whether the application protocol is the requested one, ignoring the domain prefix of
+// prefixed protocols such as kubernetes.io/h2c since it can't appear in a DNS label.
+func appProtocolMatches(appProtocol *string, protocol string) bool {
+	if appProtocol == nil || *appProtocol == "" {
+		return false
+	}
+
+	name := strings.ToLower(*appProtocol)
+
+	return name == protocol || name[strings.LastIndex(name, "/")+1:] == protocol
+}
+
+func hasRequestedPort(dnsRecords []serviceimport.DNSRecord, pReq recordRequest) bool {
+	for i := range dnsRecords {
+		for _, port := range dnsRecords[i].Ports {
+			if portMatches(port, pReq) {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+func (lh *Lighthouse) getDNSRecords(pReq recordRequest) (dnsRecords []serviceimport.DNSRecord, isHeadless, found bool) {
+	if pReq.cluster == allClusters || (pReq.cluster == "" && lh.getClusterSelection(pReq.namespace, pReq.service) == allSelection) {
+		if dnsRecords, found = lh.getAllClusterSetIPRecords(pReq.service, pReq.namespace, lh.isConnected(pReq)); found {
+			return dnsRecords, false, true
 		}
 
-		target := pReq.service + "." + pReq.namespace + ".svc." + zone
+		// Headless services are answered with the endpoints of all the clusters anyway
+		if pReq.cluster == allClusters {
+			pReq.cluster = ""
+		}
+	}
+
+	record, found := lh.getClusterIPForSvc(pReq)
+	if !found {
+		dnsRecords, found = lh.endpointSlices.GetDNSRecords(pReq.hostname, pReq.cluster, pReq.namespace,
+			pReq.service, lh.clusterCheck(pReq.service, pReq.namespace, lh.isConnected(pReq)))
 
-		if isHeadless {
-			target = dnsRecord.ClusterName + "." + target
-		} else if pReq.cluster != "" {
-			target = pReq.cluster + "." + target
+		if pReq.hostname == "" {
+			dnsRecords = lh.topology.filter(dnsRecords)
 		}
 
-		if isHeadless {
-			target = dnsRecord.HostName + "." + target
+		return dnsRecords, true, found
+	}
+
+	if record != nil && record.IP != "" {
+		dnsRecords = append(dnsRecords, *record)
+	}
+
+	return dnsRecords, false, true
+}
+
+// Resolve returns every record the handler could answer with for the given service, across all eligible clusters.
+func (lh *Lighthouse) Resolve(name, namespace string) ([]serviceimport.DNSRecord, bool) {
+	connected := lh.isConnected(recordRequest{})
+
+	records, found := lh.getAllClusterSetIPRecords(name, namespace, connected)
+	if !found {
+		return lh.endpointSlices.GetDNSRecords("", "", namespace, name, lh.clusterCheck(name, namespace, connected))
+	}
+
+	return records, true
+}
+
+// getAllClusterSetIPRecords returns the records of all the eligible clusters for the given ClusterSetIP service, with
+// the local cluster's service IP for the local cluster.
+func (lh *Lighthouse) getAllClusterSetIPRecords(name, namespace string, connected func(string) bool) ([]serviceimport.DNSRecord, bool) {
+	records, found := lh.serviceImports.GetAllRecords(namespace, name, connected, lh.isHealthy)
+	if !found {
+		return nil, false
+	}
+
+	if len(records) == 0 && lh.isGated(name, namespace) {
+		records, _ = lh.serviceImports.GetAllRecords(namespace, name, connected, lh.hasHealthyEndpoints)
+	}
+
+	localClusterID := lh.clusterStatus.LocalClusterID()
+
+	for i := range records {
+		if records[i].ClusterName != localClusterID {
+			continue
 		}
 
-		for _, port := range reqPorts {
-			record := &dns.SRV{
-				Hdr:      dns.RR_Header{Name: state.QName(), Rrtype: dns.TypeSRV, Class: state.QClass(), Ttl: lh.ttl},
-				Priority: 0,
-				Weight:   50,
-				Port:     uint16(port.Port),
-				Target:   target,
+		if local, ok := lh.localServices.GetIP(name, lh.serviceImports.OriginNamespace(namespace, name)); ok {
+			records[i].IP = local.IP
+			records[i].IPs = local.IPs
+			records[i].RRs = local.RRs
+		}
+	}
+
+	return records, true
+}
+
+// State returns the services and clusters the handler resolves from, for diagnostics.
+func (lh *Lighthouse) State() resolver.State {
+	state := resolver.State{
+		LocalClusterID: lh.clusterStatus.LocalClusterID(),
+		ServiceImports: lh.serviceImports.Dump(),
+		EndpointSlices: lh.endpointSlices.Dump(),
+		HotNames:       lh.prefetch.hotNames(),
+	}
+
+	clusterIDs := map[string]bool{}
+
+	for _, services := range [][]serviceimport.ServiceState{state.ServiceImports, state.EndpointSlices} {
+		for i := range services {
+			for j := range services[i].Records {
+				clusterIDs[services[i].Records[j].ClusterName] = true
 			}
-			records = append(records, record)
 		}
 	}
 
-	return records
+	latency, _ := lh.clusterStatus.(ClusterLatency)
+
+	for clusterID := range clusterIDs {
+		cluster := resolver.ClusterState{ID: clusterID, Connected: lh.clusterStatus.IsConnected(clusterID)}
+		if latency != nil {
+			cluster.Latency, _ = latency.Latency(clusterID)
+		}
+
+		state.Clusters = append(state.Clusters, cluster)
+	}
+
+	sort.Slice(state.Clusters, func(i, j int) bool {
+		return state.Clusters[i].ID < state.Clusters[j].ID
+	})
+
+	return state
 }
 
 func (lh *Lighthouse) getClusterIPForSvc(pReq recordRequest) (*serviceimport.DNSRecord, bool) {
 	localClusterID := lh.clusterStatus.LocalClusterID()
+	selection := lh.getClusterSelection(pReq.namespace, pReq.service)
 
-	record, found, isLocal := lh.serviceImports.GetIP(pReq.namespace, pReq.service, pReq.cluster, localClusterID, lh.clusterStatus.IsConnected,
-		lh.endpointsStatus.IsHealthy)
-	getLocal := isLocal || (pReq.cluster != "" && pReq.cluster == localClusterID)
+	localInMaintenance := lh.inMaintenance != nil && lh.inMaintenance(localClusterID)
+
+	// The local cluster is only preferred over the others if the policy says so, and never while in maintenance
+	preferredClusterID := localClusterID
+	if selection == roundRobinSelection || localInMaintenance {
+		preferredClusterID = ""
+	}
+
+	record, found, isLocal := lh.serviceImports.GetIP(pReq.namespace, pReq.service, pReq.cluster, preferredClusterID,
+		lh.isConnected(pReq), lh.isHealthy)
+
+	if found && record == nil && pReq.cluster == "" && lh.isGated(pReq.service, pReq.namespace) {
+		// No cluster passes the health gates, the least healthy clusters are better than none
+		record, found, isLocal = lh.serviceImports.GetIP(pReq.namespace, pReq.service, pReq.cluster, preferredClusterID,
+			lh.isConnected(pReq), lh.hasHealthyEndpoints)
+	}
+
+	if found && record != nil && !isLocal && pReq.cluster == "" {
+		switch {
+		case selection == remoteOnUnhealthySelection && !lh.serviceImports.HasCluster(pReq.namespace, pReq.service, localClusterID):
+			// Remote clusters are only answered when the local cluster's service is unhealthy
+			record = nil
+		case lh.getLoadBalance() == latencyLoadBalance:
+			record = lh.lowestLatencyRecord(pReq, record)
+		case lh.getLoadBalance() == hashLoadBalance:
+			record = lh.hashedRecord(pReq, record)
+		}
+	}
+
+	// While the local cluster is in maintenance, queries naming it are answered with its exported service like those
+	// naming any other cluster, rather than bypassing the clusterset with the local Service
+	getLocal := !localInMaintenance && (isLocal || (pReq.cluster != "" && pReq.cluster == localClusterID) ||
+		(record != nil && record.ClusterName == localClusterID))
 	if found && getLocal {
-		record, found = lh.localServices.GetIP(pReq.service, pReq.namespace)
+		record, found = lh.localServices.GetIP(pReq.service, lh.serviceImports.OriginNamespace(pReq.namespace, pReq.service))
+	}
+
+	if lh.stats != nil && found && record != nil && pReq.cluster == "" && !pReq.prefetched {
+		clusterID := record.ClusterName
+		if getLocal {
+			clusterID = localClusterID
+		}
+
+		lh.stats.RecordAnswer(pReq.namespace, pReq.service, clusterID, func(previous string) bool {
+			return lh.isConnected(recordRequest{})(previous) && lh.isHealthy(pReq.service, pReq.namespace, previous)
+		})
 	}
 
 	return record, found
 }
+
+// lowestLatencyRecord returns the record of the eligible cluster with the lowest round-trip time, preferring clusters
+// with a known latency. If none is known, the given round-robin selection is kept.
+func (lh *Lighthouse) lowestLatencyRecord(pReq recordRequest, selected *serviceimport.DNSRecord) *serviceimport.DNSRecord {
+	latency, ok := lh.clusterStatus.(ClusterLatency)
+	if !ok {
+		return selected
+	}
+
+	best := selected
+	bestRTT, found := latency.Latency(selected.ClusterName)
+
+	records, _ := lh.serviceImports.GetAllRecords(pReq.namespace, pReq.service, lh.isConnected(pReq), lh.isHealthy)
+
+	for i := range records {
+		rtt, ok := latency.Latency(records[i].ClusterName)
+		if ok && (!found || rtt < bestRTT) {
+			best, bestRTT, found = &records[i], rtt, true
+		}
+	}
+
+	return best
+}
+
+// hashedRecord returns the record of the eligible cluster selected by rendezvous hashing of the client, so that the
+// same client keeps getting the same cluster while it remains eligible, and only the clients of a cluster that
+// becomes ineligible move elsewhere.
+func (lh *Lighthouse) hashedRecord(pReq recordRequest, selected *serviceimport.DNSRecord) *serviceimport.DNSRecord {
+	if pReq.client == "" {
+		return selected
+	}
+
+	records, _ := lh.serviceImports.GetAllRecords(pReq.namespace, pReq.service, lh.isConnected(pReq), lh.isHealthy)
+
+	var best *serviceimport.DNSRecord
+
+	bestWeight := uint64(0)
+
+	for i := range records {
+		h := fnv.New64a()
+		_, _ = h.Write([]byte(pReq.client))
+		_, _ = h.Write([]byte{0})
+		_, _ = h.Write([]byte(records[i].ClusterName))
+
+		if weight := h.Sum64(); best == nil || weight > bestWeight {
+			best, bestWeight = &records[i], weight
+		}
+	}
+
+	if best == nil {
+		return selected
+	}
+
+	return best
+}
+
+// clustersOf returns the sorted, distinct clusters of the given records.
+func clustersOf(records []serviceimport.DNSRecord) []string {
+	clusters := make([]string, 0, len(records))
+	seen := map[string]bool{}
+
+	for i := range records {
+		if !seen[records[i].ClusterName] {
+			seen[records[i].ClusterName] = true
+			clusters = append(clusters, records[i].ClusterName)
+		}
+	}
+
+	sort.Strings(clusters)
+
+	return clusters
+}
+
+// isExcludedNamespace checks whether services in the given namespace are hidden, either because namespaces are
+// restricted to a list which doesn't include it, or because it's excluded in the Corefile or reloaded configuration.
+func (lh *Lighthouse) isExcludedNamespace(namespace string) bool {
+	if len(lh.namespaces) > 0 && !lh.namespaces[namespace] {
+		return true
+	}
+
+	if lh.excludedNamespaces[namespace] {
+		return true
+	}
+
+	config := lh.currentConfig()
+
+	return config != nil && config.excludedNamespaces[namespace]
+}
+
+// getClusterSelection returns the policy selecting the clusters answered for the given service: the one requested for
+// the service, if valid, otherwise the configured one.
+func (lh *Lighthouse) getClusterSelection(namespace, name string) string {
+	if selection := lh.serviceImports.ClusterSelection(namespace, name); selection != "" {
+		if isClusterSelection(selection) {
+			return selection
+		}
+
+		log.Debugf("Ignoring the invalid cluster selection policy %q of service %s/%s", selection, namespace, name)
+	}
+
+	if lh.clusterSelection != "" {
+		return lh.clusterSelection
+	}
+
+	return localFirstSelection
+}
+
+func isClusterSelection(selection string) bool {
+	switch selection {
+	case localFirstSelection, roundRobinSelection, allSelection, remoteOnUnhealthySelection:
+		return true
+	}
+
+	return false
+}
+
+// isHealthy checks that the service has healthy endpoints in the given cluster, enough of them to reach the health
+// threshold, and that its circuit isn't open.
+func (lh *Lighthouse) isHealthy(name, namespace, clusterID string) bool {
+	score := lh.endpointsStatus.HealthScore(name, namespace, clusterID)
+
+	return score > 0 && score >= lh.healthThreshold && lh.hasMinReadyEndpoints(name, namespace, clusterID) &&
+		lh.breaker.Allow(name, namespace, clusterID)
+}
+
+// hasMinReadyEndpoints checks that the service has as many ready endpoints in the given cluster as it requires, if the
+// endpoints status can count them.
+func (lh *Lighthouse) hasMinReadyEndpoints(name, namespace, clusterID string) bool {
+	minimum := lh.serviceImports.MinReadyEndpoints(namespace, name)
+	if minimum == 0 {
+		return true
+	}
+
+	count, ok := lh.endpointsStatus.(EndpointsCount)
+
+	return !ok || count.ReadyEndpoints(name, namespace, clusterID) >= minimum
+}
+
+// isGated checks whether clusters with healthy endpoints may be avoided for the given service, because of the health
+// threshold or of the number of ready endpoints the service requires.
+func (lh *Lighthouse) isGated(name, namespace string) bool {
+	return lh.healthThreshold > 0 || lh.serviceImports.MinReadyEndpoints(namespace, name) > 0
+}
+
+// hasHealthyEndpoints checks that the service has healthy endpoints in the given cluster, however few, and that its
+// circuit isn't open. Clusters below the health threshold, or with fewer ready endpoints than the service requires,
+// are only answered with when no cluster is eligible otherwise.
+func (lh *Lighthouse) hasHealthyEndpoints(name, namespace, clusterID string) bool {
+	return lh.endpointsStatus.HealthScore(name, namespace, clusterID) > 0 && lh.breaker.Allow(name, namespace, clusterID)
+}
+
+// clusterCheck returns a function checking the given service's clusters with the given connectivity check and for open
+// circuits.
+func (lh *Lighthouse) clusterCheck(name, namespace string, connected func(string) bool) func(string) bool {
+	if lh.breaker == nil {
+		return connected
+	}
+
+	return func(clusterID string) bool {
+		return connected(clusterID) && lh.breaker.Allow(name, namespace, clusterID)
+	}
+}
+
+// isConnected returns the function checking the connectivity of clusters for the given request: any cluster is
+// considered connected when answering from disconnected clusters. Clusters in maintenance never are, so that they're
+// drained whatever their connectivity.
+func (lh *Lighthouse) isConnected(pReq recordRequest) func(string) bool {
+	connected := lh.clusterStatus.IsConnected
+	if pReq.ignoreConnectivity {
+		connected = func(string) bool {
+			return true
+		}
+	}
+
+	if lh.inMaintenance == nil {
+		return connected
+	}
+
+	return func(clusterID string) bool {
+		return connected(clusterID) && !lh.inMaintenance(clusterID)
+	}
+}
+
+// ReportConnectivity records the outcome of a connection attempt to the given service in the given cluster.
+func (lh *Lighthouse) ReportConnectivity(name, namespace, clusterID string, success bool) {
+	if lh.breaker == nil {
+		return
+	}
+
+	if success {
+		lh.breaker.RecordSuccess(name, namespace, clusterID)
+	} else {
+		lh.breaker.RecordFailure(name, namespace, clusterID)
+	}
+}
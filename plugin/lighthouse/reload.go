@@ -0,0 +1,250 @@
+/*
+SPDX-License-Identifier: Apache-2.0
+
+Copyright Contributors to the Submariner project.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package lighthouse
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"github.com/fsnotify/fsnotify"
+	"github.com/pkg/errors"
+	"k8s.io/apimachinery/pkg/util/validation"
+)
+
+// Files read from the reloadable configuration directory, typically the keys of a mounted ConfigMap.
+const (
+	ttlConfigFile                = "ttl"
+	loadBalanceConfigFile        = "loadbalance"
+	excludedNamespacesConfigFile = "exclude-namespaces"
+	clusterAliasesConfigFile     = "cluster-aliases"
+)
+
+// reloadableConfig holds the settings which can be changed without restarting CoreDNS. It is replaced as a whole
+// whenever the configuration directory changes, so that queries never see a partially updated configuration.
+type reloadableConfig struct {
+	ttl                uint32
+	loadBalance        string
+	excludedNamespaces map[string]bool
+	clusterAliases     map[string]string
+}
+
+// currentConfig returns the reloaded configuration, or nil if none was loaded.
+func (lh *Lighthouse) currentConfig() *reloadableConfig {
+	config, _ := lh.config.Load().(*reloadableConfig)
+	return config
+}
+
+func (lh *Lighthouse) getTTL() uint32 {
+	ttl := lh.ttl
+	if config := lh.currentConfig(); config != nil {
+		ttl = config.ttl
+	}
+
+	if widenedTTL, widened := lh.widenedTTL(); widened && widenedTTL > ttl {
+		return widenedTTL
+	}
+
+	return ttl
+}
+
+// getRecordTTL returns the TTL of the records of the given class: the one configured for the class, if any,
+// otherwise the TTL.
+func (lh *Lighthouse) getRecordTTL(class string) uint32 {
+	ttl, found := lh.recordTTLs[class]
+	if !found {
+		return lh.getTTL()
+	}
+
+	if widenedTTL, widened := lh.widenedTTL(); widened && widenedTTL > ttl {
+		return widenedTTL
+	}
+
+	return ttl
+}
+
+// getAddressTTL returns the TTL of the address records of a ClusterSetIP or headless service.
+func (lh *Lighthouse) getAddressTTL(isHeadless bool) uint32 {
+	if isHeadless {
+		return lh.getRecordTTL(headlessTTL)
+	}
+
+	return lh.getRecordTTL(clusterSetIPTTL)
+}
+
+func (lh *Lighthouse) getLoadBalance() string {
+	if config := lh.currentConfig(); config != nil {
+		return config.loadBalance
+	}
+
+	return lh.loadBalance
+}
+
+// loadConfig reads the configuration directory, using the Corefile settings for missing files. Nothing is changed if
+// any setting is invalid.
+func (lh *Lighthouse) loadConfig(dir string) error {
+	config := &reloadableConfig{ttl: lh.ttl, loadBalance: lh.loadBalance, excludedNamespaces: map[string]bool{},
+		clusterAliases: map[string]string{}}
+
+	value, found, err := readConfigFile(dir, ttlConfigFile)
+	if err != nil {
+		return err
+	}
+
+	if found {
+		t, err := strconv.Atoi(value)
+		if err != nil || t < 0 || t > 3600 {
+			return errors.Errorf("ttl must be in range [0, 3600]: %s", value)
+		}
+
+		config.ttl = uint32(t)
+	}
+
+	value, found, err = readConfigFile(dir, loadBalanceConfigFile)
+	if err != nil {
+		return err
+	}
+
+	if found {
+		switch value {
+		case roundRobinLoadBalance, latencyLoadBalance, hashLoadBalance:
+			config.loadBalance = value
+		default:
+			return errors.Errorf("unknown loadbalance policy %q", value)
+		}
+	}
+
+	value, _, err = readConfigFile(dir, excludedNamespacesConfigFile)
+	if err != nil {
+		return err
+	}
+
+	namespaces := strings.FieldsFunc(value, func(r rune) bool {
+		return r == ',' || unicode.IsSpace(r)
+	})
+
+	for _, namespace := range namespaces {
+		config.excludedNamespaces[namespace] = true
+	}
+
+	value, _, err = readConfigFile(dir, clusterAliasesConfigFile)
+	if err != nil {
+		return err
+	}
+
+	// Each alias is given as ALIAS=CLUSTER
+	for _, entry := range strings.FieldsFunc(value, func(r rune) bool {
+		return r == ',' || unicode.IsSpace(r)
+	}) {
+		alias, cluster := splitClusterAlias(entry)
+		if err := validateClusterAlias(alias, cluster); err != nil {
+			return errors.Wrapf(err, "invalid cluster alias %q", entry)
+		}
+
+		config.clusterAliases[alias] = cluster
+	}
+
+	lh.config.Store(config)
+
+	return nil
+}
+
+func splitClusterAlias(entry string) (alias, cluster string) {
+	i := strings.Index(entry, "=")
+	if i < 0 {
+		return entry, ""
+	}
+
+	return entry[:i], entry[i+1:]
+}
+
+// validateClusterAlias checks that the alias can be used as a label in queries and doesn't hide the all.service
+// queries.
+func validateClusterAlias(alias, cluster string) error {
+	if cluster == "" {
+		return errors.New("no cluster ID given")
+	}
+
+	if alias == allClusters {
+		return errors.Errorf("%q is reserved", allClusters)
+	}
+
+	if errs := validation.IsDNS1123Label(alias); len(errs) > 0 {
+		return errors.Errorf("%s", strings.Join(errs, ", "))
+	}
+
+	return nil
+}
+
+func readConfigFile(dir, name string) (value string, found bool, err error) {
+	data, err := ioutil.ReadFile(filepath.Join(dir, name))
+	if os.IsNotExist(err) {
+		return "", false, nil
+	}
+
+	if err != nil {
+		return "", false, errors.Wrapf(err, "error reading %q", name)
+	}
+
+	return strings.TrimSpace(string(data)), true, nil
+}
+
+// watchConfig reloads the configuration whenever the directory changes, until the returned function is called.
+// Mounted ConfigMaps are updated by atomically swapping a symlink in the directory, which is seen as a change to it.
+func (lh *Lighthouse) watchConfig(dir string) (func(), error) {
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return nil, errors.Wrap(err, "error creating the configuration watcher")
+	}
+
+	if err := watcher.Add(dir); err != nil {
+		_ = watcher.Close()
+		return nil, errors.Wrapf(err, "error watching %q", dir)
+	}
+
+	go func() {
+		for {
+			select {
+			case _, ok := <-watcher.Events:
+				if !ok {
+					return
+				}
+
+				if err := lh.loadConfig(dir); err != nil {
+					log.Errorf("Error reloading the configuration from %q, keeping the previous one: %v", dir, err)
+					continue
+				}
+
+				log.Infof("Reloaded the configuration from %q", dir)
+			case err, ok := <-watcher.Errors:
+				if !ok {
+					return
+				}
+
+				log.Errorf("Error watching the configuration in %q: %v", dir, err)
+			}
+		}
+	}()
+
+	return func() {
+		_ = watcher.Close()
+	}, nil
+}
@@ -0,0 +1,137 @@
+/*
+SPDX-License-Identifier: Apache-2.0
+
+Copyright Contributors to the Submariner project.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package lighthouse
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"github.com/coredns/coredns/plugin/pkg/dnstest"
+	"github.com/coredns/coredns/plugin/test"
+	"github.com/miekg/dns"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Reloadable configuration", func() {
+	var (
+		lh  *Lighthouse
+		dir string
+	)
+
+	BeforeEach(func() {
+		var err error
+		dir, err = ioutil.TempDir("", "lighthouse-config")
+		Expect(err).To(Succeed())
+
+		mockCs := NewMockClusterStatus()
+		mockCs.clusterStatusMap[clusterID] = true
+		mockEs := NewMockEndpointStatus()
+		mockEs.endpointStatusMap[clusterID] = true
+		lh = &Lighthouse{
+			Zones:           []string{"clusterset.local."},
+			serviceImports:  setupServiceImportMap(),
+			endpointSlices:  setupEndpointSliceMap(),
+			clusterStatus:   mockCs,
+			endpointsStatus: mockEs,
+			localServices:   NewMockLocalServices(),
+			ttl:             defaultTTL,
+			loadBalance:     roundRobinLoadBalance,
+		}
+	})
+
+	AfterEach(func() {
+		Expect(os.RemoveAll(dir)).To(Succeed())
+	})
+
+	writeFile := func(name, value string) {
+		Expect(ioutil.WriteFile(filepath.Join(dir, name), []byte(value), 0o600)).To(Succeed())
+	}
+
+	When("the configuration directory is empty", func() {
+		It("should use the Corefile settings", func() {
+			Expect(lh.loadConfig(dir)).To(Succeed())
+			Expect(lh.getTTL()).To(Equal(defaultTTL))
+			Expect(lh.getLoadBalance()).To(Equal(roundRobinLoadBalance))
+			Expect(lh.isExcludedNamespace(namespace1)).To(BeFalse())
+			Expect(lh.clusterID("east")).To(Equal("east"))
+		})
+	})
+
+	When("the configuration directory contains settings", func() {
+		BeforeEach(func() {
+			writeFile(ttlConfigFile, "30\n")
+			writeFile(loadBalanceConfigFile, latencyLoadBalance)
+			writeFile(excludedNamespacesConfigFile, namespace1+", other\n")
+			writeFile(clusterAliasesConfigFile, "east="+clusterID+"\nwest="+clusterID2+"\n")
+			Expect(lh.loadConfig(dir)).To(Succeed())
+		})
+
+		It("should override the Corefile settings", func() {
+			Expect(lh.getTTL()).To(Equal(uint32(30)))
+			Expect(lh.getLoadBalance()).To(Equal(latencyLoadBalance))
+			Expect(lh.isExcludedNamespace("other")).To(BeTrue())
+			Expect(lh.clusterID("east")).To(Equal(clusterID))
+			Expect(lh.clusterID("west")).To(Equal(clusterID2))
+		})
+
+		It("should not resolve services in the excluded namespaces", func() {
+			executeTestCase(lh, dnstest.NewRecorder(&test.ResponseWriter{}), test.Case{
+				Qname: fmt.Sprintf("%s.%s.svc.clusterset.local.", service1, namespace1),
+				Qtype: dns.TypeA,
+				Rcode: dns.RcodeNameError,
+			})
+		})
+
+		It("should keep the previous settings if a setting is invalid", func() {
+			writeFile(ttlConfigFile, "5000")
+			Expect(lh.loadConfig(dir)).ToNot(Succeed())
+			Expect(lh.getTTL()).To(Equal(uint32(30)))
+		})
+
+		It("should keep the previous settings if a cluster alias is invalid", func() {
+			writeFile(clusterAliasesConfigFile, "all="+clusterID)
+			Expect(lh.loadConfig(dir)).ToNot(Succeed())
+			Expect(lh.clusterID("east")).To(Equal(clusterID))
+		})
+	})
+
+	When("the configuration directory is watched", func() {
+		It("should reload the settings when they change", func() {
+			Expect(lh.loadConfig(dir)).To(Succeed())
+
+			stop, err := lh.watchConfig(dir)
+			Expect(err).To(Succeed())
+
+			defer stop()
+
+			writeFile(ttlConfigFile, "60")
+			Eventually(lh.getTTL).Should(Equal(uint32(60)))
+
+			rec := dnstest.NewRecorder(&test.ResponseWriter{})
+			_, err = lh.ServeDNS(context.TODO(), rec, new(dns.Msg).SetQuestion(
+				fmt.Sprintf("%s.%s.svc.clusterset.local.", service1, namespace1), dns.TypeA))
+			Expect(err).To(Succeed())
+			Expect(rec.Msg.Answer).To(HaveLen(1))
+			Expect(rec.Msg.Answer[0].Header().Ttl).To(Equal(uint32(60)))
+		})
+	})
+})
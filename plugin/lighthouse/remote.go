@@ -0,0 +1,303 @@
+/*
+SPDX-License-Identifier: Apache-2.0
+
+Copyright Contributors to the Submariner project.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package lighthouse
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/coredns/caddy"
+	"github.com/submariner-io/lighthouse/pkg/endpointslice"
+	"github.com/submariner-io/lighthouse/pkg/fairqueue"
+	"github.com/submariner-io/lighthouse/pkg/serviceimport"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/validation"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/apimachinery/pkg/watch"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/tools/cache"
+	"k8s.io/client-go/tools/clientcmd"
+	"k8s.io/klog"
+)
+
+// remoteKubeConfigKey is the key of the kubeconfig in the Secrets giving access to the remote clusters.
+const remoteKubeConfigKey = "kubeconfig"
+
+// remoteSyncTimeout bounds the time the controllers of a remote cluster take to load its resources.
+const remoteSyncTimeout = time.Minute
+
+// Hook for unit tests
+var newKubeClientset = func(c *rest.Config) (kubernetes.Interface, error) {
+	return kubernetes.NewForConfig(c)
+}
+
+// remoteClusters describes the member clusters whose ServiceImports and EndpointSlices are watched directly, through
+// the kubeconfigs in the given Secrets, and merged with the local ones.
+type remoteClusters struct {
+	namespace string
+	secrets   []string
+
+	clusters map[string]*remoteCluster
+}
+
+// remoteCluster watches the ServiceImports and EndpointSlices of a remote cluster. Each kubeconfig read from its Secret
+// starts a new generation of controllers, a distinct source of the shared entries, and the previous generation is only
+// dropped once the new one has loaded the cluster's resources, so that those still present are kept throughout.
+type remoteCluster struct {
+	secret     string
+	siSources  *serviceImportSources
+	epSources  *endpointSliceSources
+	queue      *fairqueue.Queue
+	standalone bool
+	// kubeConfig and generation are only accessed by load, which isn't called concurrently
+	kubeConfig []byte
+	generation int
+
+	mutex   sync.Mutex
+	current *remoteControllers
+	stopped bool
+}
+
+// remoteControllers are the controllers of a generation of a remote cluster.
+type remoteControllers struct {
+	source       string
+	siController *serviceimport.Controller
+	epController *endpointslice.Controller
+	stopOnce     sync.Once
+}
+
+func parseRemoteClusters(c *caddy.Controller) (*remoteClusters, error) {
+	args := c.RemainingArgs()
+	if len(args) < 2 {
+		return nil, c.ArgErr()
+	}
+
+	if errs := validation.IsDNS1123Label(args[0]); len(errs) > 0 {
+		return nil, c.Errf("invalid namespace %q: %v", args[0], errs)
+	}
+
+	for _, name := range args[1:] {
+		if errs := validation.IsDNS1123Subdomain(name); len(errs) > 0 {
+			return nil, c.Errf("invalid Secret name %q: %v", name, errs)
+		}
+	}
+
+	return &remoteClusters{namespace: args[0], secrets: args[1:]}, nil
+}
+
+// start starts watching the ServiceImports and EndpointSlices of the remote clusters, putting them in the given shared
+// stores through the import queue shared with the local controllers, and watching their Secrets for new kubeconfigs.
+// It returns the function reporting whether the initial resources of all the remote clusters were loaded.
+func (r *remoteClusters) start(c *caddy.Controller, cfg *rest.Config, siSources *serviceImportSources,
+	epSources *endpointSliceSources, queue *fairqueue.Queue, standalone bool) (func() bool, error) {
+	client, err := newKubeClientset(cfg)
+	if err != nil {
+		return nil, fmt.Errorf("error creating the Secrets client: %v", err)
+	}
+
+	r.clusters = make(map[string]*remoteCluster, len(r.secrets))
+
+	for _, name := range r.secrets {
+		secret, err := client.CoreV1().Secrets(r.namespace).Get(context.TODO(), name, metav1.GetOptions{})
+		if err != nil {
+			return nil, fmt.Errorf("error retrieving the kubeconfig Secret %s/%s: %v", r.namespace, name, err)
+		}
+
+		cluster := &remoteCluster{secret: r.namespace + "/" + name, siSources: siSources, epSources: epSources, queue: queue,
+			standalone: standalone}
+
+		c.OnShutdown(func() error {
+			cluster.stop()
+			return nil
+		})
+
+		if err := cluster.load(secret); err != nil {
+			return nil, err
+		}
+
+		r.clusters[name] = cluster
+	}
+
+	stopCh := make(chan struct{})
+
+	_, informer := cache.NewInformer(&cache.ListWatch{
+		ListFunc: func(options metav1.ListOptions) (runtime.Object, error) {
+			return client.CoreV1().Secrets(r.namespace).List(context.TODO(), options)
+		},
+		WatchFunc: func(options metav1.ListOptions) (watch.Interface, error) {
+			return client.CoreV1().Secrets(r.namespace).Watch(context.TODO(), options)
+		},
+	}, &corev1.Secret{}, 0, cache.ResourceEventHandlerFuncs{
+		AddFunc: r.secretCreatedOrUpdated,
+		UpdateFunc: func(oldObj, newObj interface{}) {
+			r.secretCreatedOrUpdated(newObj)
+		},
+	})
+
+	go informer.Run(stopCh)
+
+	c.OnShutdown(func() error {
+		close(stopCh)
+		return nil
+	})
+
+	return func() bool {
+		for _, cluster := range r.clusters {
+			if !cluster.hasSynced() {
+				return false
+			}
+		}
+
+		return true
+	}, nil
+}
+
+// Deleted Secrets and invalid kubeconfigs are ignored, keeping the controllers started with the previous kubeconfig, so
+// that the remote clusters keep being watched while their Secrets are being replaced.
+func (r *remoteClusters) secretCreatedOrUpdated(obj interface{}) {
+	secret := obj.(*corev1.Secret)
+
+	cluster, ok := r.clusters[secret.Name]
+	if !ok {
+		return
+	}
+
+	if err := cluster.load(secret); err != nil {
+		klog.Errorf("Error reloading the remote cluster from its kubeconfig, keeping the previous one: %v", err)
+	}
+}
+
+// load starts a new generation of controllers if the Secret holds a new kubeconfig, stopping and dropping the entries of
+// the previous generation once it has synced.
+func (r *remoteCluster) load(secret *corev1.Secret) error {
+	data, ok := secret.Data[remoteKubeConfigKey]
+	if !ok {
+		return fmt.Errorf("the kubeconfig Secret %s has no %q key", r.secret, remoteKubeConfigKey)
+	}
+
+	if bytes.Equal(data, r.kubeConfig) {
+		return nil
+	}
+
+	remoteCfg, err := clientcmd.RESTConfigFromKubeConfig(data)
+	if err != nil {
+		return fmt.Errorf("error parsing the kubeconfig in Secret %s: %v", r.secret, err)
+	}
+
+	r.generation++
+
+	next, err := r.startControllers(fmt.Sprintf("%s#%d", r.secret, r.generation), remoteCfg)
+	if err != nil {
+		return err
+	}
+
+	r.mutex.Lock()
+	previous := r.current
+
+	if r.stopped {
+		r.mutex.Unlock()
+		r.drop(next)
+
+		return nil
+	}
+
+	r.current, r.kubeConfig = next, data
+	r.mutex.Unlock()
+
+	if previous != nil {
+		klog.Infof("Reloaded the remote cluster from the new kubeconfig in Secret %s", r.secret)
+		r.drop(previous)
+	}
+
+	return nil
+}
+
+// startControllers starts the controllers of a new generation, returning once they have loaded the cluster's resources.
+func (r *remoteCluster) startControllers(source string, remoteCfg *rest.Config) (*remoteControllers, error) {
+	controllers := &remoteControllers{
+		source:       source,
+		siController: serviceimport.NewController(r.siSources.from(source)),
+		epController: endpointslice.NewController(r.epSources.from(source)),
+	}
+
+	controllers.siController.Queue = r.queue
+	controllers.siController.Standalone = r.standalone
+	controllers.epController.Queue = r.queue
+
+	// Stopping the controllers interrupts their start
+	timer := time.AfterFunc(remoteSyncTimeout, controllers.stop)
+	defer timer.Stop()
+
+	err := controllers.siController.Start(remoteCfg)
+	if err != nil {
+		err = fmt.Errorf("error starting the ServiceImport controller of %s: %v", r.secret, err)
+	} else if err = controllers.epController.Start(remoteCfg); err != nil {
+		err = fmt.Errorf("error starting the EndpointSlice controller of %s: %v", r.secret, err)
+	} else if err = wait.PollImmediate(100*time.Millisecond, remoteSyncTimeout, controllers.hasSynced); err != nil {
+		err = fmt.Errorf("error loading the resources of %s: %v", r.secret, err)
+	}
+
+	if err != nil {
+		r.drop(controllers)
+		return nil, err
+	}
+
+	return controllers, nil
+}
+
+// drop stops the controllers and forgets the entries they put.
+func (r *remoteCluster) drop(controllers *remoteControllers) {
+	controllers.stop()
+	r.siSources.drop(controllers.source)
+	r.epSources.drop(controllers.source)
+}
+
+func (r *remoteCluster) hasSynced() bool {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	return r.current != nil && r.current.siController.HasSynced() && r.current.epController.HasSynced()
+}
+
+func (r *remoteCluster) stop() {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	r.stopped = true
+
+	if r.current != nil {
+		r.current.stop()
+	}
+}
+
+func (c *remoteControllers) hasSynced() (bool, error) {
+	return c.siController.HasSynced() && c.epController.HasSynced(), nil
+}
+
+func (c *remoteControllers) stop() {
+	c.stopOnce.Do(func() {
+		c.siController.Stop()
+		c.epController.Stop()
+	})
+}
@@ -18,17 +18,39 @@ limitations under the License.
 package lighthouse
 
 import (
+	"encoding/base64"
 	"flag"
 	"fmt"
+	"math"
+	"net"
+	"os"
 	"strconv"
+	"strings"
+	"time"
 
 	"github.com/coredns/caddy"
 	"github.com/coredns/coredns/core/dnsserver"
 	"github.com/coredns/coredns/plugin"
+	"github.com/coredns/coredns/plugin/pkg/upstream"
+	"github.com/miekg/dns"
+	"github.com/submariner-io/lighthouse/pkg/breaker"
+	"github.com/submariner-io/lighthouse/pkg/brokerstatus"
+	"github.com/submariner-io/lighthouse/pkg/clusterstatus"
+	"github.com/submariner-io/lighthouse/pkg/dnssec"
+	"github.com/submariner-io/lighthouse/pkg/dnstls"
 	"github.com/submariner-io/lighthouse/pkg/endpointslice"
+	"github.com/submariner-io/lighthouse/pkg/fairqueue"
 	"github.com/submariner-io/lighthouse/pkg/gateway"
+	"github.com/submariner-io/lighthouse/pkg/maintenance"
+	"github.com/submariner-io/lighthouse/pkg/resolver"
 	"github.com/submariner-io/lighthouse/pkg/service"
 	"github.com/submariner-io/lighthouse/pkg/serviceimport"
+	"github.com/submariner-io/lighthouse/pkg/stats"
+	"k8s.io/apimachinery/pkg/api/resource"
+	"k8s.io/apimachinery/pkg/util/validation"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/tools/cache"
 	"k8s.io/client-go/tools/clientcmd"
 )
 
@@ -40,6 +62,82 @@ var (
 // Hook for unit tests
 var buildKubeConfigFunc = clientcmd.BuildConfigFromFlags
 
+// Hook for unit tests
+var newStatsClientset = dynamic.NewForConfig
+
+// Hook for unit tests
+var newBrokerStatusClientset = dynamic.NewForConfig
+
+// Hook for unit tests
+var newMaintenanceClientset = dynamic.NewForConfig
+
+// Hook for unit tests
+var newDNSSECController = dnssec.NewController
+
+// Hook for unit tests
+var newTLSController = dnstls.NewController
+
+// Hook for unit tests
+var newGlobalIngressIPController = endpointslice.NewGlobalIngressIPController
+
+// getNodeTopology is an indirection hook for unit tests to supply the zone and region of the node CoreDNS runs on
+var getNodeTopology = nodeTopology
+
+func parseClustersetDomains(c *caddy.Controller, zones []string) ([]string, error) {
+	args := c.RemainingArgs()
+	if len(args) == 0 {
+		return nil, c.ArgErr()
+	}
+
+	domains := make([]string, len(args))
+
+	for i, arg := range args {
+		domain := dns.Fqdn(strings.ToLower(arg))
+		if _, ok := dns.IsDomainName(domain); !ok || domain == "." {
+			return nil, c.Errf("invalid clusterset-domain %q", arg)
+		}
+
+		if len(zones) > 0 && plugin.Zones(zones).Matches(domain) == "" {
+			return nil, c.Errf("clusterset-domain %q is not within the plugin zones %v", domain, zones)
+		}
+
+		domains[i] = domain
+	}
+
+	return domains, nil
+}
+
+func parseCircuitBreaker(c *caddy.Controller) (*breaker.Breaker, error) {
+	args := c.RemainingArgs()
+	if len(args) > 3 {
+		return nil, c.ArgErr()
+	}
+
+	b := breaker.New(breaker.DefaultThreshold, breaker.DefaultWindow, breaker.DefaultCooldown)
+
+	if len(args) > 0 {
+		t, err := strconv.Atoi(args[0])
+		if err != nil || t <= 0 {
+			return nil, c.Errf("circuit-breaker threshold must be a positive integer: %s", args[0])
+		}
+
+		b.Threshold = t
+	}
+
+	durations := []*time.Duration{&b.Window, &b.Cooldown}
+
+	for i, arg := range args[1:] {
+		d, err := time.ParseDuration(arg)
+		if err != nil || d <= 0 {
+			return nil, c.Errf("circuit-breaker durations must be positive: %s", arg)
+		}
+
+		*durations[i] = d
+	}
+
+	return b, nil
+}
+
 // init registers this plugin within the Caddy plugin framework. It uses "example" as the
 // name, and couples it to the Action "setup".
 func init() {
@@ -73,28 +171,47 @@ func lighthouseParse(c *caddy.Controller) (*Lighthouse, error) {
 		return nil, fmt.Errorf("error building kubeconfig: %v", err)
 	}
 
-	siMap := serviceimport.NewMap()
-	siController := serviceimport.NewController(siMap)
+	hints := &evictionHints{}
+	negative := newNegativeCache()
+	serial := newZoneSerial()
+	prefetch := newPrefetcher()
+	changes := resolver.NewChanges()
+	rrCacheBudget := serviceimport.NewRRCacheBudget()
 
-	err = siController.Start(cfg)
-	if err != nil {
-		return nil, fmt.Errorf("error starting the ServiceImport controller: %v", err)
-	}
+	importQueue := fairqueue.New("imports")
+	queueStopCh := make(chan struct{})
+
+	go importQueue.Run(queueStopCh)
+
+	siMap := serviceimport.NewMap()
+	siMap.SetRRCacheBudget(rrCacheBudget)
+	nsMetrics := newNamespaceMetrics(siMap.Services)
+	siStore := &serviceImportStore{Store: siMap, hints: hints, negative: negative, metrics: nsMetrics, serial: serial,
+		prefetch: prefetch, changes: changes}
+	// The stores are shared with the controllers of the remote clusters, if any
+	siSources := newServiceImportSources(siStore)
+	siController := serviceimport.NewController(siSources.from(localSource))
+	siController.Queue = importQueue
 
 	epMap := endpointslice.NewMap()
-	epController := endpointslice.NewController(epMap)
+	epMap.SetRRCacheBudget(rrCacheBudget)
+	epStore := &endpointSliceStore{Store: epMap, hints: hints, negative: negative, serial: serial, prefetch: prefetch,
+		changes: changes}
+	epSources := newEndpointSliceSources(epStore)
+	epController := endpointslice.NewController(epSources.from(localSource))
+	epController.Queue = importQueue
 	err = epController.Start(cfg)
 	if err != nil {
 		return nil, fmt.Errorf("error starting the EndpointSlice controller: %v", err)
 	}
 
 	gwController := gateway.NewController()
-	err = gwController.Start(cfg)
-	if err != nil {
-		return nil, fmt.Errorf("error starting the Gateway controller: %v", err)
-	}
+	clusterStatus := &clusterStatusBackend{ClusterStatus: gwController, start: func() error {
+		return gwController.Start(cfg)
+	}, stop: gwController.Stop}
 
 	svcController := service.NewController()
+	svcController.RRCacheBudget = rrCacheBudget
 	err = svcController.Start(cfg)
 	if err != nil {
 		return nil, fmt.Errorf("error starting the Service controller: %v", err)
@@ -103,13 +220,33 @@ func lighthouseParse(c *caddy.Controller) (*Lighthouse, error) {
 	c.OnShutdown(func() error {
 		siController.Stop()
 		epController.Stop()
-		gwController.Stop()
 		svcController.Stop()
+		close(queueStopCh)
+		unregisterRRCacheBudget(rrCacheBudget)
 		return nil
 	})
 
+	registerRRCacheBudget(rrCacheBudget)
+
 	lh := &Lighthouse{ttl: defaultTTL, serviceImports: siMap, clusterStatus: gwController, endpointSlices: epMap,
-		endpointsStatus: epController, localServices: svcController}
+		endpointsStatus: epController, localServices: svcController, loadBalance: roundRobinLoadBalance,
+		namespaceMetrics: nsMetrics, serial: serial, srvLocalPriority: defaultSRVLocalPriority, srvRemotePriority: defaultSRVRemotePriority,
+		synced: func() bool {
+			return siController.HasSynced() && epController.HasSynced()
+		}}
+
+	configDir := ""
+	clusterStatusSet := false
+	standalone := false
+
+	var remote *remoteClusters
+
+	var (
+		tlsController *dnstls.Controller
+		tlsListeners  []tlsListener
+		tlsForwards   []tlsForward
+		grpcEndpoints []grpcEndpoint
+	)
 
 	// Changed `for` to `if` to satisfy golint:
 	//	 SA4004: the surrounding loop is unconditionally terminated (staticcheck)
@@ -128,14 +265,456 @@ func lighthouseParse(c *caddy.Controller) (*Lighthouse, error) {
 			switch c.Val() {
 			case "fallthrough":
 				lh.Fall.SetZonesFromArgs(c.RemainingArgs())
+			case "fallthrough-nodata":
+				lh.fallNoData.SetZonesFromArgs(c.RemainingArgs())
 			case "ttl":
-				t, err := parseTTL(c)
+				t, recordTTLs, err := parseTTL(c)
+
+				if err != nil {
+					return nil, err
+				}
+
+				lh.ttl, lh.recordTTLs = t, recordTTLs
+			case "clusterset-domain":
+				domains, err := parseClustersetDomains(c, lh.Zones)
+				if err != nil {
+					return nil, err
+				}
+
+				lh.clustersetDomains = domains
+			case "circuit-breaker":
+				b, err := parseCircuitBreaker(c)
+				if err != nil {
+					return nil, err
+				}
+
+				lh.breaker = b
+			case "loadbalance":
+				policy, err := parseLoadBalance(c)
+				if err != nil {
+					return nil, err
+				}
+
+				lh.loadBalance = policy
+			case "cluster-selection":
+				args := c.RemainingArgs()
+				if len(args) != 1 {
+					return nil, c.ArgErr()
+				}
+
+				if !isClusterSelection(args[0]) {
+					return nil, c.Errf("unknown cluster-selection policy %q", args[0])
+				}
+
+				lh.clusterSelection = args[0]
+			case "disable":
+				zones, err := parseDisableSRV(c)
+				if err != nil {
+					return nil, err
+				}
+
+				lh.srvDisabledZones = zones
+			case "namespaces":
+				namespaces, err := parseNamespaces(c)
+				if err != nil {
+					return nil, err
+				}
+
+				lh.namespaces = namespaces
+			case "exclude-namespaces":
+				namespaces, err := parseNamespaces(c)
+				if err != nil {
+					return nil, err
+				}
+
+				lh.excludedNamespaces = namespaces
+			case "metrics-namespaces":
+				namespaces, err := parseNamespaces(c)
+				if err != nil {
+					return nil, err
+				}
+
+				nsMetrics.setAllowed(namespaces)
+			case "dnssec":
+				controller, err := parseDNSSEC(c)
+				if err != nil {
+					return nil, err
+				}
+
+				if err := controller.Start(cfg); err != nil {
+					return nil, fmt.Errorf("error starting the DNSSEC key controller: %v", err)
+				}
+
+				c.OnShutdown(func() error {
+					controller.Stop()
+					return nil
+				})
+
+				lh.dnssec = controller.Signer
+				synced := lh.synced
+				lh.synced = func() bool {
+					return synced() && controller.HasSynced()
+				}
+			case "tls-secret":
+				namespace, name, err := parseSecret(c, "tls-secret")
+				if err != nil {
+					return nil, err
+				}
+
+				tlsController = newTLSController(namespace, name)
+			case "tls-listen":
+				listener, err := parseTLSListen(c)
+				if err != nil {
+					return nil, err
+				}
+
+				tlsListeners = append(tlsListeners, listener)
+			case "tls-forward":
+				forward, err := parseTLSForward(c)
+				if err != nil {
+					return nil, err
+				}
+
+				tlsForwards = append(tlsForwards, forward)
+			case "ratelimit":
+				scope, limit, burst, err := parseRateLimit(c)
+				if err != nil {
+					return nil, err
+				}
+
+				if lh.limiter == nil {
+					lh.limiter = newQueryLimiter()
+				}
+
+				if scope == clientRateLimit {
+					lh.limiter.setClientLimit(limit, burst)
+				} else {
+					lh.limiter.setAggregateLimit(limit, burst)
+				}
+			case "no-shuffle":
+				if c.NextArg() {
+					return nil, c.ArgErr()
+				}
+
+				lh.noShuffle = true
+			case "stable-order":
+				if c.NextArg() {
+					return nil, c.ArgErr()
+				}
+
+				lh.stableOrder = true
+			case "ipv6-only":
+				if c.NextArg() {
+					return nil, c.ArgErr()
+				}
+
+				lh.ipv6Only = true
+			case "resolve-srv-targets":
+				if c.NextArg() {
+					return nil, c.ArgErr()
+				}
+
+				lh.upstream = upstream.New()
+			case "globalnet":
+				if c.NextArg() {
+					return nil, c.ArgErr()
+				}
+
+				controller := newGlobalIngressIPController(func() string {
+					return lh.clusterStatus.LocalClusterID()
+				})
+				controller.OnChange = newGlobalIPRefresher(epMap, epStore, importQueue).refresh
 
+				if err := controller.Start(cfg); err != nil {
+					return nil, fmt.Errorf("error starting the GlobalIngressIP controller: %v", err)
+				}
+
+				c.OnShutdown(func() error {
+					controller.Stop()
+					return nil
+				})
+
+				epMap.SetGlobalIPResolver(controller)
+				synced := lh.synced
+				lh.synced = func() bool {
+					return synced() && controller.HasSynced()
+				}
+			case "cache-eviction":
+				if c.NextArg() {
+					return nil, c.ArgErr()
+				}
+
+				c.OnStartup(func() error {
+					return findCacheEvictor(c, hints)
+				})
+			case "import-rate":
+				limit, burst, err := parseImportRate(c)
+				if err != nil {
+					return nil, err
+				}
+
+				importQueue.SetRateLimit(limit, burst)
+			case "reload-config":
+				args := c.RemainingArgs()
+				if len(args) != 1 {
+					return nil, c.ArgErr()
+				}
+
+				if info, err := os.Stat(args[0]); err != nil || !info.IsDir() {
+					return nil, c.Errf("reload-config must be an existing directory: %s", args[0])
+				}
+
+				configDir = args[0]
+			case "stats":
+				interval, err := parseStats(c)
+				if err != nil {
+					return nil, err
+				}
+
+				lh.stats = stats.NewCollector(lh.serviceImports.Services)
+
+				publisher, err := newStatsPublisher(cfg, lh.stats, interval)
+				if err != nil {
+					return nil, err
+				}
+
+				c.OnStartup(func() error {
+					publisher.Start()
+					return nil
+				})
+
+				c.OnShutdown(func() error {
+					publisher.Stop()
+					return nil
+				})
+			case "reconnect-delay":
+				delay, err := parseReconnectDelay(c)
+				if err != nil {
+					return nil, err
+				}
+
+				gwController.StableFor = delay
+			case "cluster-status":
+				backend, err := parseClusterStatus(c, gwController, cfg)
+				if err != nil {
+					return nil, err
+				}
+
+				clusterStatus = backend
+				lh.clusterStatus = backend.ClusterStatus
+				clusterStatusSet = true
+			case "standalone":
+				if c.NextArg() {
+					return nil, c.ArgErr()
+				}
+
+				standalone = true
+			case "kubeconfig-secrets":
+				remote, err = parseRemoteClusters(c)
+				if err != nil {
+					return nil, err
+				}
+			case "degraded-connectivity":
+				args := c.RemainingArgs()
+				if len(args) != 1 {
+					return nil, c.ArgErr()
+				}
+
+				switch args[0] {
+				case gateway.AssumeConnected, gateway.AssumeDisconnected, gateway.ServeStale:
+					gwController.DegradedPolicy = args[0]
+				default:
+					return nil, c.Errf("unknown degraded-connectivity policy %q", args[0])
+				}
+			case "querylog":
+				rate, err := parseQueryLog(c)
+				if err != nil {
+					return nil, err
+				}
+
+				lh.queryLog = newQueryLog(rate)
+			case "wildcard":
+				limit, err := parseWildcard(c)
+				if err != nil {
+					return nil, err
+				}
+
+				lh.wildcardLimit = limit
+			case "any-types":
+				types, err := parseAnyTypes(c)
+				if err != nil {
+					return nil, err
+				}
+
+				lh.anyTypes = types
+			case "cluster-alias":
+				args := c.RemainingArgs()
+				if len(args) != 2 {
+					return nil, c.ArgErr()
+				}
+
+				if err := validateClusterAlias(args[0], args[1]); err != nil {
+					return nil, c.Errf("invalid cluster alias %q: %v", args[0], err)
+				}
+
+				if lh.clusterAliases == nil {
+					lh.clusterAliases = map[string]string{}
+				}
+
+				lh.clusterAliases[args[0]] = args[1]
+			case "alias-mode":
+				args := c.RemainingArgs()
+				if len(args) != 1 {
+					return nil, c.ArgErr()
+				}
+
+				switch args[0] {
+				case recordsAliasMode, cnameAliasMode:
+					lh.aliasMode = args[0]
+				default:
+					return nil, c.Errf("unknown alias-mode %q", args[0])
+				}
+			case "srv-prefixes":
+				args := c.RemainingArgs()
+				if len(args) != 1 {
+					return nil, c.ArgErr()
+				}
+
+				switch args[0] {
+				case allSRVPrefixes, srvSRVPrefixes, noSRVPrefixes:
+					lh.srvPrefixes = args[0]
+				default:
+					return nil, c.Errf("unknown srv-prefixes %q", args[0])
+				}
+			case "topology":
+				topology, err := parseTopology(c, cfg)
 				if err != nil {
 					return nil, err
 				}
 
-				lh.ttl = t
+				lh.topology = topology
+			case "srv-priority":
+				local, remote, err := parseSRVPriority(c)
+				if err != nil {
+					return nil, err
+				}
+
+				lh.srvLocalPriority, lh.srvRemotePriority = local, remote
+			case "serve-stale":
+				window, ttl, err := parseServeStale(c)
+				if err != nil {
+					return nil, err
+				}
+
+				lh.stale = &serveStale{window: window, ttl: ttl,
+					sources: []func() (time.Time, bool){siController.DisconnectedSince, epController.DisconnectedSince}}
+			case "broker-out-of-sync":
+				ttl, namespace, err := parseBrokerOutOfSync(c)
+				if err != nil {
+					return nil, err
+				}
+
+				watcher, err := newBrokerStatusWatcher(cfg, namespace)
+				if err != nil {
+					return nil, err
+				}
+
+				stopCh := make(chan struct{})
+
+				c.OnStartup(func() error {
+					watcher.Start(stopCh)
+					return nil
+				})
+
+				c.OnShutdown(func() error {
+					close(stopCh)
+					return nil
+				})
+
+				lh.brokerOutOfSync = &brokerOutOfSync{ttl: ttl, outOfSyncSince: watcher.OutOfSyncSince}
+			case "maintenance":
+				namespace, err := parseMaintenance(c)
+				if err != nil {
+					return nil, err
+				}
+
+				watcher, err := newMaintenanceWatcher(cfg, namespace)
+				if err != nil {
+					return nil, err
+				}
+
+				stopCh := make(chan struct{})
+
+				c.OnStartup(func() error {
+					watcher.Start(stopCh)
+					return nil
+				})
+
+				c.OnShutdown(func() error {
+					close(stopCh)
+					return nil
+				})
+
+				lh.inMaintenance = watcher.InMaintenance
+			case "disconnected-clusters":
+				policy, ttl, err := parseDisconnectedClusters(c)
+				if err != nil {
+					return nil, err
+				}
+
+				lh.disconnectedPolicy, lh.disconnectedTTL = policy, ttl
+			case "negative-cache":
+				ttl, size, err := parseNegativeCache(c)
+				if err != nil {
+					return nil, err
+				}
+
+				negative.ttl, negative.size = ttl, size
+				lh.negative = negative
+			case "cache-memory":
+				limit, err := parseCacheMemory(c)
+				if err != nil {
+					return nil, err
+				}
+
+				rrCacheBudget.SetLimit(limit)
+			case "max-endpoints":
+				limit, mode, err := parseMaxEndpoints(c)
+				if err != nil {
+					return nil, err
+				}
+
+				lh.maxEndpoints, lh.maxEndpointsMode = limit, mode
+			case "prefetch":
+				count, err := parsePrefetch(c)
+				if err != nil {
+					return nil, err
+				}
+
+				prefetch.count = count
+				prefetch.refresh = lh.prefetchAnswer
+				lh.prefetch = prefetch
+			case "health-threshold":
+				threshold, err := parseHealthThreshold(c)
+				if err != nil {
+					return nil, err
+				}
+
+				lh.healthThreshold = threshold
+			case "transfer":
+				transfer, err := parseTransfer(c)
+				if err != nil {
+					return nil, err
+				}
+
+				lh.transfer = transfer
+			case "grpc-endpoint":
+				endpoint, err := parseGRPCEndpoint(c)
+				if err != nil {
+					return nil, err
+				}
+
+				grpcEndpoints = append(grpcEndpoints, endpoint)
 			default:
 				if c.Val() != "}" {
 					return nil, c.Errf("unknown property '%s'", c.Val())
@@ -144,26 +723,908 @@ func lighthouseParse(c *caddy.Controller) (*Lighthouse, error) {
 		}
 	}
 
-	return lh, nil
-}
+	if standalone {
+		// Serve the ServiceImports of any MCS implementation, without relying on Submariner for the connectivity and
+		// on the Lighthouse EndpointSlices for the health of the clusters
+		siController.Standalone = true
+		lh.endpointsStatus = staticStatus{}
 
-func parseTTL(c *caddy.Controller) (uint32, error) {
+		if !clusterStatusSet {
+			clusterStatus = alwaysConnectedBackend()
+			lh.clusterStatus = clusterStatus.ClusterStatus
+		}
+	}
+
+	// The ServiceImport controller is started once the options are known since they determine how ServiceImports are read
+	if err := siController.Start(cfg); err != nil {
+		return nil, fmt.Errorf("error starting the ServiceImport controller: %v", err)
+	}
+
+	if remote != nil {
+		remoteSynced, err := remote.start(c, cfg, siSources, epSources, importQueue, standalone)
+		if err != nil {
+			return nil, err
+		}
+
+		synced := lh.synced
+		lh.synced = func() bool {
+			return synced() && remoteSynced()
+		}
+	}
+
+	if err := clusterStatus.start(); err != nil {
+		return nil, fmt.Errorf("error starting the cluster status backend: %v", err)
+	}
+
+	c.OnShutdown(func() error {
+		clusterStatus.stop()
+		return nil
+	})
+
+	if err := lh.setupTLS(c, cfg, tlsController, tlsListeners, tlsForwards); err != nil {
+		return nil, err
+	}
+
+	if err := lh.setupGRPC(c, tlsController, grpcEndpoints, changes); err != nil {
+		return nil, err
+	}
+
+	if len(lh.clustersetDomains) > 0 {
+		hints.setZones(lh.clustersetDomains)
+	} else {
+		hints.setZones(lh.Zones)
+	}
+
+	if configDir != "" {
+		// The Corefile settings are the defaults for the reloadable configuration, so it's loaded once they're all known
+		if err := lh.loadConfig(configDir); err != nil {
+			return nil, c.Errf("error loading the configuration from %q: %v", configDir, err)
+		}
+
+		var stopWatching func()
+
+		c.OnStartup(func() error {
+			var err error
+			stopWatching, err = lh.watchConfig(configDir)
+
+			return err
+		})
+
+		c.OnShutdown(func() error {
+			if stopWatching != nil {
+				stopWatching()
+			}
+
+			return nil
+		})
+	}
+
+	return lh, nil
+}
+
+func parseTTL(c *caddy.Controller) (uint32, map[string]uint32, error) {
 	// Refer: https://github.com/coredns/coredns/blob/master/plugin/kubernetes/setup.go
 	args := c.RemainingArgs()
-	if len(args) == 0 {
-		return 0, c.ArgErr()
+	if len(args) == 0 || len(args)%2 == 0 {
+		return 0, nil, c.ArgErr()
 	}
 
 	t, err := strconv.Atoi(args[0])
 	if err != nil {
-		return 0, err
+		return 0, nil, err
 	}
 
 	if t < 0 || t > 3600 {
-		return 0, c.Errf("ttl must be in range [0, 3600]: %d", t)
+		return 0, nil, c.Errf("ttl must be in range [0, 3600]: %d", t)
+	}
+
+	// The TTL can be followed by the TTLs of classes of records, e.g. ttl 5 headless 2 negative 30
+	var recordTTLs map[string]uint32
+
+	for i := 1; i < len(args); i += 2 {
+		class := args[i]
+		if class != clusterSetIPTTL && class != headlessTTL && class != srvTTL && class != negativeTTL {
+			return 0, nil, c.Errf("unknown ttl record class %q", class)
+		}
+
+		classTTL, err := strconv.Atoi(args[i+1])
+		if err != nil || classTTL < 0 || classTTL > 3600 {
+			return 0, nil, c.Errf("%s ttl must be in range [0, 3600]: %s", class, args[i+1])
+		}
+
+		if recordTTLs == nil {
+			recordTTLs = map[string]uint32{}
+		}
+
+		recordTTLs[class] = uint32(classTTL)
+	}
+
+	return uint32(t), recordTTLs, nil
+}
+
+func parseImportRate(c *caddy.Controller) (float64, int, error) {
+	args := c.RemainingArgs()
+	if len(args) == 0 || len(args) > 2 {
+		return 0, 0, c.ArgErr()
+	}
+
+	limit, err := strconv.ParseFloat(args[0], 64)
+	if err != nil || limit <= 0 {
+		return 0, 0, c.Errf("import-rate limit must be a positive number: %s", args[0])
+	}
+
+	burst := int(math.Ceil(limit))
+
+	if len(args) > 1 {
+		burst, err = strconv.Atoi(args[1])
+		if err != nil || burst <= 0 {
+			return 0, 0, c.Errf("import-rate burst must be a positive integer: %s", args[1])
+		}
+	}
+
+	return limit, burst, nil
+}
+
+func parseRateLimit(c *caddy.Controller) (string, float64, int, error) {
+	args := c.RemainingArgs()
+	if len(args) < 2 || len(args) > 3 {
+		return "", 0, 0, c.ArgErr()
+	}
+
+	scope := args[0]
+	if scope != clientRateLimit && scope != aggregateRateLimit {
+		return "", 0, 0, c.Errf("ratelimit must be %q or %q: %s", clientRateLimit, aggregateRateLimit, scope)
+	}
+
+	limit, err := strconv.ParseFloat(args[1], 64)
+	if err != nil || limit <= 0 {
+		return "", 0, 0, c.Errf("ratelimit QPS must be a positive number: %s", args[1])
+	}
+
+	burst := int(math.Ceil(limit))
+
+	if len(args) > 2 {
+		burst, err = strconv.Atoi(args[2])
+		if err != nil || burst <= 0 {
+			return "", 0, 0, c.Errf("ratelimit burst must be a positive integer: %s", args[2])
+		}
+	}
+
+	return scope, limit, burst, nil
+}
+
+// findCacheEvictor looks for a plugin in the server block which can evict cached answers, to send eviction hints to.
+func findCacheEvictor(c *caddy.Controller, hints *evictionHints) error {
+	for _, handler := range dnsserver.GetConfig(c).Handlers() {
+		if evictor, ok := handler.(CacheEvictor); ok {
+			log.Infof("Sending cache eviction hints to the %q plugin", handler.Name())
+			hints.setEvictor(evictor)
+
+			return nil
+		}
+	}
+
+	log.Warning("cache-eviction is enabled but no plugin in the server block supports evicting cached answers")
+
+	return nil
+}
+
+func parseNamespaces(c *caddy.Controller) (map[string]bool, error) {
+	args := c.RemainingArgs()
+	if len(args) == 0 {
+		return nil, c.ArgErr()
+	}
+
+	namespaces := make(map[string]bool, len(args))
+
+	for _, namespace := range args {
+		if errs := validation.IsDNS1123Label(namespace); len(errs) > 0 {
+			return nil, c.Errf("invalid namespace %q: %v", namespace, errs)
+		}
+
+		namespaces[namespace] = true
+	}
+
+	return namespaces, nil
+}
+
+// parseDisableSRV parses "disable srv [ZONES...]", returning the zones SRV records are disabled for, all by default.
+func parseDisableSRV(c *caddy.Controller) ([]string, error) {
+	args := c.RemainingArgs()
+	if len(args) == 0 {
+		return nil, c.ArgErr()
+	}
+
+	if !strings.EqualFold(args[0], "srv") {
+		return nil, c.Errf("unknown record type to disable %q", args[0])
+	}
+
+	if len(args) == 1 {
+		return []string{"."}, nil
+	}
+
+	zones := make([]string, len(args)-1)
+	for i, zone := range args[1:] {
+		zones[i] = plugin.Host(zone).Normalize()
+	}
+
+	return zones, nil
+}
+
+func parseSecret(c *caddy.Controller, option string) (namespace, name string, err error) {
+	args := c.RemainingArgs()
+	if len(args) != 1 {
+		return "", "", c.ArgErr()
+	}
+
+	namespace, name, err = cache.SplitMetaNamespaceKey(args[0])
+	if err != nil || namespace == "" || name == "" {
+		return "", "", c.Errf("%s Secret must be given as NAMESPACE/NAME: %s", option, args[0])
+	}
+
+	return namespace, name, nil
+}
+
+func parseDNSSEC(c *caddy.Controller) (*dnssec.Controller, error) {
+	namespace, name, err := parseSecret(c, "dnssec")
+	if err != nil {
+		return nil, err
+	}
+
+	return newDNSSECController(namespace, name), nil
+}
+
+type tlsListener struct {
+	address string
+	// zones maps the server names clients connect to to the zones they may query
+	zones map[string]string
+}
+
+type tlsForward struct {
+	zone       string
+	upstream   string
+	serverName string
+}
+
+func parseTLSListen(c *caddy.Controller) (tlsListener, error) {
+	args := c.RemainingArgs()
+	if len(args) == 0 {
+		return tlsListener{}, c.ArgErr()
+	}
+
+	listener := tlsListener{address: args[0], zones: map[string]string{}}
+
+	for _, arg := range args[1:] {
+		zone := plugin.Host(arg).Normalize()
+		listener.zones[strings.TrimSuffix(zone, ".")] = zone
+	}
+
+	return listener, nil
+}
+
+func parseTLSForward(c *caddy.Controller) (tlsForward, error) {
+	args := c.RemainingArgs()
+	if len(args) < 2 || len(args) > 3 {
+		return tlsForward{}, c.ArgErr()
+	}
+
+	forward := tlsForward{zone: plugin.Host(args[0]).Normalize(), upstream: args[1]}
+	if len(args) == 3 {
+		forward.serverName = args[2]
+	}
+
+	return forward, nil
+}
+
+// setupTLS starts the DNS over TLS listeners and creates the forwarders, with the certificates from the TLS Secret.
+func (lh *Lighthouse) setupTLS(c *caddy.Controller, cfg *rest.Config, controller *dnstls.Controller, listeners []tlsListener,
+	forwards []tlsForward) error {
+	if controller == nil {
+		if len(listeners) > 0 || len(forwards) > 0 {
+			return c.Err("tls-listen and tls-forward require tls-secret")
+		}
+
+		return nil
+	}
+
+	for _, forward := range forwards {
+		f, err := dnstls.NewForwarder(forward.zone, forward.upstream, forward.serverName, controller.Certificates)
+		if err != nil {
+			return c.Errf("invalid tls-forward: %v", err)
+		}
+
+		lh.forwarders = append(lh.forwarders, f)
+	}
+
+	if err := controller.Start(cfg); err != nil {
+		return fmt.Errorf("error starting the TLS certificate controller: %v", err)
+	}
+
+	c.OnShutdown(func() error {
+		controller.Stop()
+		return nil
+	})
+
+	synced := lh.synced
+	lh.synced = func() bool {
+		return synced() && controller.HasSynced()
+	}
+
+	for _, listener := range listeners {
+		address := listener.address
+		server := dnstls.NewServer(lh.dnsHandler(), controller.Certificates, listener.zones)
+
+		c.OnStartup(func() error {
+			return server.Start(address)
+		})
+
+		c.OnShutdown(func() error {
+			server.Stop()
+			return nil
+		})
+	}
+
+	return nil
+}
+
+type grpcEndpoint struct {
+	address string
+	tls     bool
+}
+
+func parseGRPCEndpoint(c *caddy.Controller) (grpcEndpoint, error) {
+	args := c.RemainingArgs()
+	if len(args) == 0 || len(args) > 2 {
+		return grpcEndpoint{}, c.ArgErr()
+	}
+
+	endpoint := grpcEndpoint{address: args[0]}
+
+	if len(args) == 2 {
+		if args[1] != "tls" {
+			return grpcEndpoint{}, c.Errf("unknown grpc-endpoint option %q", args[1])
+		}
+
+		endpoint.tls = true
+
+		return endpoint, nil
+	}
+
+	host, port, err := net.SplitHostPort(args[0])
+	if err != nil {
+		return grpcEndpoint{}, c.Errf("invalid grpc-endpoint address %q: %v", args[0], err)
+	}
+
+	// Without TLS, anyone reaching the endpoint could dump the plugin's state and report failures, so it's only served
+	// on the loopback interface
+	if host == "" {
+		endpoint.address = net.JoinHostPort("127.0.0.1", port)
+	} else if ip := net.ParseIP(host); host != "localhost" && (ip == nil || !ip.IsLoopback()) {
+		return grpcEndpoint{}, c.Errf("grpc-endpoint %q must be a loopback address unless served with tls", args[0])
+	}
+
+	return endpoint, nil
+}
+
+func (lh *Lighthouse) setupGRPC(c *caddy.Controller, tlsController *dnstls.Controller, endpoints []grpcEndpoint,
+	changes *resolver.Changes) error {
+	for _, endpoint := range endpoints {
+		address := endpoint.address
+		server := resolver.NewServer(lh, changes)
+
+		if endpoint.tls {
+			if tlsController == nil {
+				return c.Err("grpc-endpoint with tls requires tls-secret")
+			}
+
+			server.TLSConfig = tlsController.Certificates.ServerConfig()
+		}
+
+		c.OnStartup(func() error {
+			return server.Start(address)
+		})
+
+		c.OnShutdown(func() error {
+			server.Stop()
+			return nil
+		})
+	}
+
+	return nil
+}
+
+func parseStats(c *caddy.Controller) (time.Duration, error) {
+	args := c.RemainingArgs()
+	if len(args) > 1 {
+		return 0, c.ArgErr()
+	}
+
+	if len(args) == 0 {
+		return stats.DefaultInterval, nil
+	}
+
+	d, err := time.ParseDuration(args[0])
+	if err != nil || d <= 0 {
+		return 0, c.Errf("stats interval must be a positive duration: %s", args[0])
+	}
+
+	return d, nil
+}
+
+// newStatsPublisher creates a publisher for the given collector, naming the published resources after this replica.
+func newStatsPublisher(cfg *rest.Config, collector *stats.Collector, interval time.Duration) (*stats.Publisher, error) {
+	client, err := newStatsClientset(cfg)
+	if err != nil {
+		return nil, fmt.Errorf("error creating the resolution statistics client: %v", err)
+	}
+
+	hostname, err := os.Hostname()
+	if err != nil {
+		return nil, fmt.Errorf("error retrieving the hostname: %v", err)
+	}
+
+	return stats.NewPublisher(collector, client, "coredns-"+hostname, interval), nil
+}
+
+// The backends reporting the connectivity with the clusters of the clusterset.
+const (
+	// gatewayClusterStatus reads the Submariner Gateway resources
+	gatewayClusterStatus = "gateway"
+	// alwaysConnectedClusterStatus considers every cluster connected
+	alwaysConnectedClusterStatus = "always-connected"
+	// fileClusterStatus considers the clusters listed in a file connected
+	fileClusterStatus = "file"
+	// httpClusterStatus probes a health endpoint for each cluster
+	httpClusterStatus = "http"
+)
+
+// clusterStatusBackend is the ClusterStatus implementation selected by the cluster-status option, started and stopped
+// along with the plugin.
+type clusterStatusBackend struct {
+	ClusterStatus
+	start func() error
+	stop  func()
+}
+
+func parseClusterStatus(c *caddy.Controller, gwController *gateway.Controller, cfg *rest.Config) (*clusterStatusBackend, error) {
+	args := c.RemainingArgs()
+	if len(args) == 0 {
+		return nil, c.ArgErr()
+	}
+
+	switch args[0] {
+	case gatewayClusterStatus:
+		if len(args) != 1 {
+			return nil, c.ArgErr()
+		}
+
+		return &clusterStatusBackend{ClusterStatus: gwController, start: func() error {
+			return gwController.Start(cfg)
+		}, stop: gwController.Stop}, nil
+	case alwaysConnectedClusterStatus:
+		if len(args) != 1 {
+			return nil, c.ArgErr()
+		}
+
+		return alwaysConnectedBackend(), nil
+	case fileClusterStatus:
+		if len(args) != 2 {
+			return nil, c.ArgErr()
+		}
+
+		backend := clusterstatus.NewFile(args[1])
+
+		return &clusterStatusBackend{ClusterStatus: backend, start: backend.Start, stop: backend.Stop}, nil
+	case httpClusterStatus:
+		if len(args) < 2 || len(args) > 3 {
+			return nil, c.ArgErr()
+		}
+
+		if !strings.HasPrefix(args[1], "http://") && !strings.HasPrefix(args[1], "https://") {
+			return nil, c.Errf("cluster-status http URL must be an http or https URL: %s", args[1])
+		}
+
+		interval := clusterstatus.DefaultProbeInterval
+
+		if len(args) > 2 {
+			d, err := time.ParseDuration(args[2])
+			if err != nil || d <= 0 {
+				return nil, c.Errf("cluster-status http interval must be a positive duration: %s", args[2])
+			}
+
+			interval = d
+		}
+
+		backend := clusterstatus.NewHTTP(args[1], interval)
+
+		return &clusterStatusBackend{ClusterStatus: backend, start: backend.Start, stop: backend.Stop}, nil
+	}
+
+	return nil, c.Errf("unknown cluster-status backend %q", args[0])
+}
+
+func alwaysConnectedBackend() *clusterStatusBackend {
+	return &clusterStatusBackend{ClusterStatus: clusterstatus.NewAlwaysConnected(), start: func() error {
+		return nil
+	}, stop: func() {}}
+}
+
+func parseCacheMemory(c *caddy.Controller) (int64, error) {
+	args := c.RemainingArgs()
+	if len(args) != 1 {
+		return 0, c.ArgErr()
+	}
+
+	limit, err := resource.ParseQuantity(args[0])
+	if err != nil || limit.Sign() <= 0 {
+		return 0, c.Errf("cache-memory must be a positive quantity of bytes: %s", args[0])
+	}
+
+	return limit.Value(), nil
+}
+
+func parseReconnectDelay(c *caddy.Controller) (time.Duration, error) {
+	args := c.RemainingArgs()
+	if len(args) != 1 {
+		return 0, c.ArgErr()
+	}
+
+	d, err := time.ParseDuration(args[0])
+	if err != nil || d < 0 {
+		return 0, c.Errf("reconnect-delay must be a non-negative duration: %s", args[0])
+	}
+
+	return d, nil
+}
+
+func parseQueryLog(c *caddy.Controller) (float64, error) {
+	args := c.RemainingArgs()
+	if len(args) > 1 {
+		return 0, c.ArgErr()
+	}
+
+	if len(args) == 0 {
+		return 1, nil
+	}
+
+	rate, err := strconv.ParseFloat(args[0], 64)
+	if err != nil || rate <= 0 || rate > 1 {
+		return 0, c.Errf("querylog sampling rate must be greater than 0 and at most 1: %s", args[0])
+	}
+
+	return rate, nil
+}
+
+func parseWildcard(c *caddy.Controller) (int, error) {
+	args := c.RemainingArgs()
+	if len(args) > 1 {
+		return 0, c.ArgErr()
+	}
+
+	if len(args) == 0 {
+		return defaultWildcardLimit, nil
+	}
+
+	limit, err := strconv.Atoi(args[0])
+	if err != nil || limit <= 0 {
+		return 0, c.Errf("wildcard limit must be a positive integer: %s", args[0])
+	}
+
+	return limit, nil
+}
+
+func parseAnyTypes(c *caddy.Controller) ([]uint16, error) {
+	args := c.RemainingArgs()
+	if len(args) == 0 {
+		return nil, c.ArgErr()
+	}
+
+	types := make([]uint16, 0, len(args))
+
+	for _, arg := range args {
+		qtype := dns.StringToType[strings.ToUpper(arg)]
+		if qtype != dns.TypeA && qtype != dns.TypeAAAA && qtype != dns.TypeSRV {
+			return nil, c.Errf("any-types must be A, AAAA or SRV: %s", arg)
+		}
+
+		types = append(types, qtype)
+	}
+
+	return types, nil
+}
+
+// parseTopology parses "topology prefer-zone [ZONE [REGION]]". Without a zone, the zone and region of the node named by
+// the NODE_NAME environment variable, typically set from the pod's spec.nodeName through the downward API, are used.
+func parseTopology(c *caddy.Controller, cfg *rest.Config) (*topologyPreference, error) {
+	args := c.RemainingArgs()
+	if len(args) == 0 || len(args) > 3 || args[0] != "prefer-zone" {
+		return nil, c.ArgErr()
+	}
+
+	if len(args) > 1 {
+		topology := &topologyPreference{zone: args[1]}
+		if len(args) > 2 {
+			topology.region = args[2]
+		}
+
+		return topology, nil
+	}
+
+	nodeName := os.Getenv("NODE_NAME")
+	if nodeName == "" {
+		return nil, c.Errf("topology prefer-zone needs a zone or the NODE_NAME environment variable")
+	}
+
+	zone, region, err := getNodeTopology(cfg, nodeName)
+	if err != nil {
+		return nil, err
+	}
+
+	if zone == "" && region == "" {
+		log.Warningf("Node %q has no zone or region labels, endpoints won't be filtered by topology", nodeName)
+		return nil, nil
+	}
+
+	return &topologyPreference{zone: zone, region: region}, nil
+}
+
+func parseSRVPriority(c *caddy.Controller) (uint16, uint16, error) {
+	args := c.RemainingArgs()
+	if len(args) != 2 {
+		return 0, 0, c.ArgErr()
+	}
+
+	priorities := make([]uint16, len(args))
+
+	for i, arg := range args {
+		p, err := strconv.ParseUint(arg, 10, 16)
+		if err != nil {
+			return 0, 0, c.Errf("srv-priority must be in range [0, 65535]: %s", arg)
+		}
+
+		priorities[i] = uint16(p)
+	}
+
+	return priorities[0], priorities[1], nil
+}
+
+func parseBrokerOutOfSync(c *caddy.Controller) (uint32, string, error) {
+	args := c.RemainingArgs()
+	if len(args) > 2 {
+		return 0, "", c.ArgErr()
+	}
+
+	ttl, namespace := defaultOutOfSyncTTL, brokerstatus.DefaultNamespace
+
+	if len(args) > 0 {
+		t, err := strconv.Atoi(args[0])
+		if err != nil || t <= 0 || t > 3600 {
+			return 0, "", c.Errf("broker-out-of-sync ttl must be in range [1, 3600]: %s", args[0])
+		}
+
+		ttl = uint32(t)
+	}
+
+	if len(args) > 1 {
+		if errs := validation.IsDNS1123Label(args[1]); len(errs) > 0 {
+			return 0, "", c.Errf("invalid namespace %q: %v", args[1], errs)
+		}
+
+		namespace = args[1]
 	}
 
-	return uint32(t), nil
+	return ttl, namespace, nil
+}
+
+func newBrokerStatusWatcher(cfg *rest.Config, namespace string) (*brokerstatus.Watcher, error) {
+	client, err := newBrokerStatusClientset(cfg)
+	if err != nil {
+		return nil, fmt.Errorf("error creating the broker sync status client: %v", err)
+	}
+
+	return brokerstatus.NewWatcher(client, namespace), nil
+}
+
+func parseMaintenance(c *caddy.Controller) (string, error) {
+	args := c.RemainingArgs()
+	if len(args) > 1 {
+		return "", c.ArgErr()
+	}
+
+	if len(args) == 0 {
+		return maintenance.DefaultNamespace, nil
+	}
+
+	if errs := validation.IsDNS1123Label(args[0]); len(errs) > 0 {
+		return "", c.Errf("invalid namespace %q: %v", args[0], errs)
+	}
+
+	return args[0], nil
+}
+
+func newMaintenanceWatcher(cfg *rest.Config, namespace string) (*maintenance.Watcher, error) {
+	client, err := newMaintenanceClientset(cfg)
+	if err != nil {
+		return nil, fmt.Errorf("error creating the cluster maintenance client: %v", err)
+	}
+
+	return maintenance.NewWatcher(client, namespace), nil
+}
+
+func parseServeStale(c *caddy.Controller) (time.Duration, uint32, error) {
+	args := c.RemainingArgs()
+	if len(args) > 2 {
+		return 0, 0, c.ArgErr()
+	}
+
+	window, ttl := defaultStaleWindow, defaultStaleTTL
+
+	if len(args) > 0 {
+		d, err := time.ParseDuration(args[0])
+		if err != nil || d <= 0 {
+			return 0, 0, c.Errf("serve-stale window must be a positive duration: %s", args[0])
+		}
+
+		window = d
+	}
+
+	if len(args) > 1 {
+		t, err := strconv.Atoi(args[1])
+		if err != nil || t <= 0 || t > 3600 {
+			return 0, 0, c.Errf("serve-stale ttl must be in range [1, 3600]: %s", args[1])
+		}
+
+		ttl = uint32(t)
+	}
+
+	return window, ttl, nil
+}
+
+func parseDisconnectedClusters(c *caddy.Controller) (string, uint32, error) {
+	args := c.RemainingArgs()
+	if len(args) == 0 || len(args) > 2 {
+		return "", 0, c.ArgErr()
+	}
+
+	switch args[0] {
+	case dropDisconnected, failDisconnected:
+		if len(args) > 1 {
+			return "", 0, c.ArgErr()
+		}
+	case serveDisconnected:
+	default:
+		return "", 0, c.Errf("unknown disconnected-clusters policy %q", args[0])
+	}
+
+	ttl := defaultDisconnectedTTL
+
+	if len(args) > 1 {
+		t, err := strconv.Atoi(args[1])
+		if err != nil || t < 0 || t > 3600 {
+			return "", 0, c.Errf("disconnected-clusters ttl must be in range [0, 3600]: %s", args[1])
+		}
+
+		ttl = uint32(t)
+	}
+
+	return args[0], ttl, nil
+}
+
+func parseNegativeCache(c *caddy.Controller) (uint32, int, error) {
+	args := c.RemainingArgs()
+	if len(args) > 2 {
+		return 0, 0, c.ArgErr()
+	}
+
+	ttl, size := defaultNegativeTTL, defaultNegativeCacheSize
+
+	if len(args) > 0 {
+		t, err := strconv.Atoi(args[0])
+		if err != nil || t <= 0 || t > 3600 {
+			return 0, 0, c.Errf("negative-cache ttl must be in range [1, 3600]: %s", args[0])
+		}
+
+		ttl = uint32(t)
+	}
+
+	if len(args) > 1 {
+		s, err := strconv.Atoi(args[1])
+		if err != nil || s <= 0 {
+			return 0, 0, c.Errf("negative-cache size must be a positive integer: %s", args[1])
+		}
+
+		size = s
+	}
+
+	return ttl, size, nil
+}
+
+func parseTransfer(c *caddy.Controller) (*zoneTransfer, error) {
+	args := c.RemainingArgs()
+	if len(args) < 2 {
+		return nil, c.ArgErr()
+	}
+
+	if _, err := base64.StdEncoding.DecodeString(args[1]); err != nil {
+		return nil, c.Errf("transfer secret must be base64-encoded: %v", err)
+	}
+
+	networks, err := parseNetworks(args[2:])
+	if err != nil {
+		return nil, c.Errf("invalid transfer network: %v", err)
+	}
+
+	return &zoneTransfer{keyName: strings.ToLower(dns.Fqdn(args[0])), secret: args[1], networks: networks}, nil
+}
+
+func parseMaxEndpoints(c *caddy.Controller) (int, string, error) {
+	args := c.RemainingArgs()
+	if len(args) == 0 || len(args) > 2 {
+		return 0, "", c.ArgErr()
+	}
+
+	limit, err := strconv.Atoi(args[0])
+	if err != nil || limit <= 0 {
+		return 0, "", c.Errf("max-endpoints count must be a positive integer: %s", args[0])
+	}
+
+	mode := randomEndpoints
+
+	if len(args) > 1 {
+		if args[1] != randomEndpoints && args[1] != stableEndpoints {
+			return 0, "", c.Errf("unknown max-endpoints mode %q", args[1])
+		}
+
+		mode = args[1]
+	}
+
+	return limit, mode, nil
+}
+
+func parsePrefetch(c *caddy.Controller) (int, error) {
+	args := c.RemainingArgs()
+	if len(args) > 1 {
+		return 0, c.ArgErr()
+	}
+
+	if len(args) == 0 {
+		return defaultPrefetchCount, nil
+	}
+
+	count, err := strconv.Atoi(args[0])
+	if err != nil || count <= 0 {
+		return 0, c.Errf("prefetch count must be a positive integer: %s", args[0])
+	}
+
+	return count, nil
+}
+
+func parseHealthThreshold(c *caddy.Controller) (float64, error) {
+	args := c.RemainingArgs()
+	if len(args) != 1 {
+		return 0, c.ArgErr()
+	}
+
+	threshold, err := strconv.ParseFloat(args[0], 64)
+	if err != nil || threshold < 0 || threshold > 1 {
+		return 0, c.Errf("health-threshold must be a number between 0 and 1: %s", args[0])
+	}
+
+	return threshold, nil
+}
+
+func parseLoadBalance(c *caddy.Controller) (string, error) {
+	args := c.RemainingArgs()
+	if len(args) != 1 {
+		return "", c.ArgErr()
+	}
+
+	switch args[0] {
+	case roundRobinLoadBalance, latencyLoadBalance, hashLoadBalance:
+		return args[0], nil
+	default:
+		return "", c.Errf("unknown loadbalance policy %q", args[0])
+	}
 }
 
 func init() {
@@ -20,6 +20,9 @@ package lighthouse
 import (
 	"context"
 	"errors"
+	"io/ioutil"
+	"os"
+	"time"
 
 	"k8s.io/client-go/kubernetes"
 
@@ -29,14 +32,21 @@ import (
 	"github.com/miekg/dns"
 	. "github.com/onsi/ginkgo"
 	. "github.com/onsi/gomega"
+	"github.com/submariner-io/lighthouse/pkg/breaker"
+	"github.com/submariner-io/lighthouse/pkg/clusterstatus"
+	"github.com/submariner-io/lighthouse/pkg/dnssec"
+	"github.com/submariner-io/lighthouse/pkg/dnstls"
 	"github.com/submariner-io/lighthouse/pkg/endpointslice"
 	"github.com/submariner-io/lighthouse/pkg/gateway"
 	"github.com/submariner-io/lighthouse/pkg/serviceimport"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/client-go/dynamic"
 	fakeClient "k8s.io/client-go/dynamic/fake"
 	fakeKubeClient "k8s.io/client-go/kubernetes/fake"
 	"k8s.io/client-go/rest"
+	mcsv1a1 "sigs.k8s.io/mcs-api/pkg/apis/v1alpha1"
 	mcsClientset "sigs.k8s.io/mcs-api/pkg/client/clientset/versioned"
 	fakeMCSClientset "sigs.k8s.io/mcs-api/pkg/client/clientset/versioned/fake"
 )
@@ -65,109 +75,1595 @@ var _ = Describe("Plugin setup", func() {
 		endpointslice.NewClientset = func(kubeConfig *rest.Config) (kubernetes.Interface, error) {
 			return fakeKubeClient.NewSimpleClientset(), nil
 		}
+
+		newStatsClientset = func(c *rest.Config) (dynamic.Interface, error) {
+			return fakeClient.NewSimpleDynamicClient(runtime.NewScheme()), nil
+		}
+
+		newBrokerStatusClientset = func(c *rest.Config) (dynamic.Interface, error) {
+			return fakeClient.NewSimpleDynamicClient(runtime.NewScheme()), nil
+		}
+
+		newMaintenanceClientset = func(c *rest.Config) (dynamic.Interface, error) {
+			return fakeClient.NewSimpleDynamicClient(runtime.NewScheme()), nil
+		}
+
+		newKubeClientset = func(c *rest.Config) (kubernetes.Interface, error) {
+			return fakeKubeClient.NewSimpleClientset(), nil
+		}
+
+		newDNSSECController = func(namespace, name string) *dnssec.Controller {
+			controller := dnssec.NewController(namespace, name)
+			controller.NewClientset = func(kubeConfig *rest.Config) (kubernetes.Interface, error) {
+				return fakeKubeClient.NewSimpleClientset(), nil
+			}
+
+			return controller
+		}
+
+		newGlobalIngressIPController = func(localClusterID func() string) *endpointslice.GlobalIngressIPController {
+			controller := endpointslice.NewGlobalIngressIPController(localClusterID)
+			controller.NewClientset = func(c *rest.Config) (dynamic.Interface, error) {
+				return fakeClient.NewSimpleDynamicClient(runtime.NewScheme()), nil
+			}
+
+			return controller
+		}
+
+		newTLSController = func(namespace, name string) *dnstls.Controller {
+			controller := dnstls.NewController(namespace, name)
+			controller.NewClientset = func(kubeConfig *rest.Config) (kubernetes.Interface, error) {
+				return fakeKubeClient.NewSimpleClientset(), nil
+			}
+
+			return controller
+		}
+	})
+
+	AfterEach(func() {
+		gateway.NewClientset = nil
+	})
+
+	Context("Parsing correct configurations", testCorrectConfig)
+	Context("Parsing incorrect configurations", testIncorrectConfig)
+	Context("Plugin registration", testPluginRegistration)
+})
+
+func testCorrectConfig() {
+	var (
+		lh     *Lighthouse
+		config string
+	)
+
+	BeforeEach(func() {
+		buildKubeConfigFunc = func(masterUrl, kubeconfigPath string) (*rest.Config, error) {
+			return &rest.Config{}, nil
+		}
+
+		config = PluginName
+	})
+
+	JustBeforeEach(func() {
+		var err error
+		lh, err = lighthouseParse(caddy.NewTestController("dns", config))
+		Expect(err).To(Succeed())
+	})
+
+	When("no optional arguments are specified", func() {
+		It("should succeed with empty zones and fallthrough fields", func() {
+			Expect(lh.Fall).To(Equal(fall.F{}))
+			Expect(lh.Zones).To(BeEmpty())
+		})
+
+		It("should report ready once the initial ServiceImports and EndpointSlices are loaded", func() {
+			Eventually(lh.Ready).Should(BeTrue())
+
+			lh.synced = func() bool {
+				return false
+			}
+
+			Expect(lh.Ready()).To(BeFalse())
+		})
+	})
+
+	When("lighthouse zone and fallthrough zone arguments are specified", func() {
+		BeforeEach(func() {
+			config = `lighthouse cluster2.local cluster3.local {
+			    fallthrough cluster2.local
+            }`
+		})
+
+		It("should succeed with the zones and fallthrough fields populated correctly", func() {
+			Expect(lh.Fall).To(Equal(fall.F{Zones: []string{"cluster2.local."}}))
+			Expect(lh.Zones).To(Equal([]string{"cluster2.local.", "cluster3.local."}))
+		})
+	})
+
+	When("fallthrough argument with no zones is specified", func() {
+		BeforeEach(func() {
+			config = `lighthouse {
+			    fallthrough
+            }`
+		})
+
+		It("should succeed with the root fallthrough zones", func() {
+			Expect(lh.Fall).Should(Equal(fall.Root))
+			Expect(lh.Zones).Should(BeEmpty())
+		})
+	})
+
+	When("fallthrough-nodata argument is specified", func() {
+		BeforeEach(func() {
+			config = `lighthouse cluster2.local cluster3.local {
+			    fallthrough-nodata cluster3.local
+            }`
+		})
+
+		It("should succeed with the NODATA fallthrough zones populated correctly", func() {
+			Expect(lh.fallNoData).To(Equal(fall.F{Zones: []string{"cluster3.local."}}))
+			Expect(lh.Fall).To(Equal(fall.F{}))
+		})
+	})
+
+	When("ttl arguments is specified", func() {
+		BeforeEach(func() {
+			config = `lighthouse {
+			    ttl 30
+            }`
+		})
+
+		It("should succeed with the ttl field populated correctly", func() {
+			Expect(lh.ttl).Should(Equal(uint32(30)))
+		})
+	})
+
+	When("ttl arguments are specified for record classes", func() {
+		BeforeEach(func() {
+			config = `lighthouse {
+			    ttl 5 headless 2 negative 30
+            }`
+		})
+
+		It("should succeed with the record classes' TTLs set", func() {
+			Expect(lh.ttl).Should(Equal(uint32(5)))
+			Expect(lh.recordTTLs).To(Equal(map[string]uint32{headlessTTL: 2, negativeTTL: 30}))
+		})
+	})
+
+	When("clusterset-domain argument is specified", func() {
+		BeforeEach(func() {
+			config = `lighthouse {
+			    clusterset-domain Mesh.Example.org
+            }`
+		})
+
+		It("should succeed with the normalized clusterset domain", func() {
+			Expect(lh.clustersetDomains).Should(Equal([]string{"mesh.example.org."}))
+		})
+	})
+
+	When("multiple clusterset-domain arguments are specified", func() {
+		BeforeEach(func() {
+			config = `lighthouse clusterset.local supercluster.local {
+			    clusterset-domain clusterset.local supercluster.local
+            }`
+		})
+
+		It("should succeed with all the clusterset domains", func() {
+			Expect(lh.Zones).Should(Equal([]string{"clusterset.local.", "supercluster.local."}))
+			Expect(lh.clustersetDomains).Should(Equal([]string{"clusterset.local.", "supercluster.local."}))
+		})
+	})
+
+	When("circuit-breaker argument is specified without values", func() {
+		BeforeEach(func() {
+			config = `lighthouse {
+			    circuit-breaker
+            }`
+		})
+
+		It("should succeed with the default circuit breaker settings", func() {
+			Expect(lh.breaker).ToNot(BeNil())
+			Expect(lh.breaker.Threshold).To(Equal(breaker.DefaultThreshold))
+			Expect(lh.breaker.Window).To(Equal(breaker.DefaultWindow))
+			Expect(lh.breaker.Cooldown).To(Equal(breaker.DefaultCooldown))
+		})
+	})
+
+	When("circuit-breaker argument is specified with values", func() {
+		BeforeEach(func() {
+			config = `lighthouse {
+			    circuit-breaker 3 10s 1m
+            }`
+		})
+
+		It("should succeed with the circuit breaker fields populated correctly", func() {
+			Expect(lh.breaker.Threshold).To(Equal(3))
+			Expect(lh.breaker.Window).To(Equal(10 * time.Second))
+			Expect(lh.breaker.Cooldown).To(Equal(time.Minute))
+		})
+	})
+
+	When("loadbalance argument is specified", func() {
+		BeforeEach(func() {
+			config = `lighthouse {
+			    loadbalance latency
+            }`
+		})
+
+		It("should succeed with the load balancing policy populated correctly", func() {
+			Expect(lh.loadBalance).Should(Equal(latencyLoadBalance))
+		})
+	})
+
+	When("namespaces and exclude-namespaces arguments are specified", func() {
+		BeforeEach(func() {
+			config = `lighthouse {
+			    namespaces ns1 ns2
+			    exclude-namespaces secret
+            }`
+		})
+
+		It("should succeed with the namespaces populated correctly", func() {
+			Expect(lh.namespaces).To(Equal(map[string]bool{"ns1": true, "ns2": true}))
+			Expect(lh.excludedNamespaces).To(Equal(map[string]bool{"secret": true}))
+		})
+	})
+
+	When("disable srv argument is specified without zones", func() {
+		BeforeEach(func() {
+			config = `lighthouse {
+			    disable srv
+            }`
+		})
+
+		It("should succeed with SRV records disabled for all zones", func() {
+			Expect(lh.srvDisabledZones).To(Equal([]string{"."}))
+		})
+	})
+
+	When("disable srv argument is specified with zones", func() {
+		BeforeEach(func() {
+			config = `lighthouse {
+			    disable srv supercluster.local
+            }`
+		})
+
+		It("should succeed with SRV records disabled for the zones", func() {
+			Expect(lh.srvDisabledZones).To(Equal([]string{"supercluster.local."}))
+		})
+	})
+
+	When("reload-config argument is specified", func() {
+		var dir string
+
+		BeforeEach(func() {
+			var err error
+			dir, err = ioutil.TempDir("", "lighthouse-config")
+			Expect(err).To(Succeed())
+
+			config = `lighthouse {
+			    ttl 30
+			    reload-config ` + dir + `
+            }`
+		})
+
+		AfterEach(func() {
+			Expect(os.RemoveAll(dir)).To(Succeed())
+		})
+
+		It("should succeed with the configuration loaded from the directory", func() {
+			Expect(lh.currentConfig()).ToNot(BeNil())
+			Expect(lh.getTTL()).To(Equal(uint32(30)))
+		})
+	})
+
+	When("cache-eviction argument is specified", func() {
+		BeforeEach(func() {
+			config = `lighthouse {
+			    cache-eviction
+            }`
+		})
+
+		It("should succeed", func() {
+			Expect(lh).ToNot(BeNil())
+		})
+	})
+
+	When("dnssec argument is specified", func() {
+		BeforeEach(func() {
+			config = `lighthouse {
+			    dnssec kube-system/lighthouse-dnssec
+            }`
+		})
+
+		It("should succeed with the signer populated", func() {
+			Expect(lh.dnssec).ToNot(BeNil())
+		})
+	})
+
+	When("tls-secret and tls-forward arguments are specified", func() {
+		BeforeEach(func() {
+			config = `lighthouse {
+			    tls-secret kube-system/lighthouse-tls
+			    tls-forward West.clusterset.local tls://10.0.0.1
+			    tls-forward south.clusterset.local https://10.0.0.2/dns-query resolver.south
+            }`
+		})
+
+		It("should succeed with the forwarders populated correctly", func() {
+			Expect(lh.forwarders).To(HaveLen(2))
+			Expect(lh.forwarders[0].Zone).To(Equal("west.clusterset.local."))
+			Expect(lh.forwarders[1].Zone).To(Equal("south.clusterset.local."))
+		})
+	})
+
+	When("ratelimit arguments are specified", func() {
+		BeforeEach(func() {
+			config = `lighthouse {
+			    ratelimit client 50
+			    ratelimit total 1000 2000
+            }`
+		})
+
+		It("should succeed with the limits populated correctly", func() {
+			Expect(lh.limiter).ToNot(BeNil())
+			Expect(float64(lh.limiter.limit)).To(Equal(50.0))
+			Expect(lh.limiter.burst).To(Equal(50))
+			Expect(lh.limiter.aggregate.Burst()).To(Equal(2000))
+		})
+	})
+
+	When("no-shuffle argument is specified", func() {
+		BeforeEach(func() {
+			config = `lighthouse {
+			    no-shuffle
+            }`
+		})
+
+		It("should succeed with shuffling disabled", func() {
+			Expect(lh.noShuffle).To(BeTrue())
+		})
+	})
+
+	When("stable-order argument is specified", func() {
+		BeforeEach(func() {
+			config = `lighthouse {
+			    stable-order
+            }`
+		})
+
+		It("should succeed with the order made stable", func() {
+			Expect(lh.stableOrder).To(BeTrue())
+		})
+	})
+
+	When("ipv6-only argument is specified", func() {
+		BeforeEach(func() {
+			config = `lighthouse {
+			    ipv6-only
+            }`
+		})
+
+		It("should succeed with IPv6-only mode enabled", func() {
+			Expect(lh.ipv6Only).To(BeTrue())
+		})
+	})
+
+	When("max-endpoints argument is specified", func() {
+		BeforeEach(func() {
+			config = `lighthouse {
+			    max-endpoints 100 stable
+            }`
+		})
+
+		It("should succeed with the maximum and mode set", func() {
+			Expect(lh.maxEndpoints).To(Equal(100))
+			Expect(lh.maxEndpointsMode).To(Equal(stableEndpoints))
+		})
+	})
+
+	When("max-endpoints argument is specified without a mode", func() {
+		BeforeEach(func() {
+			config = `lighthouse {
+			    max-endpoints 100
+            }`
+		})
+
+		It("should succeed with random sampling", func() {
+			Expect(lh.maxEndpointsMode).To(Equal(randomEndpoints))
+		})
+	})
+
+	When("prefetch argument is specified", func() {
+		BeforeEach(func() {
+			config = `lighthouse {
+			    prefetch
+            }`
+		})
+
+		It("should succeed with the default number of hot names", func() {
+			Expect(lh.prefetch).ToNot(BeNil())
+			Expect(lh.prefetch.count).To(Equal(defaultPrefetchCount))
+			Expect(lh.prefetch.refresh).ToNot(BeNil())
+		})
+	})
+
+	When("prefetch argument is specified with a count", func() {
+		BeforeEach(func() {
+			config = `lighthouse {
+			    prefetch 10
+            }`
+		})
+
+		It("should succeed with the number of hot names set", func() {
+			Expect(lh.prefetch.count).To(Equal(10))
+		})
+	})
+
+	When("health-threshold argument is specified", func() {
+		BeforeEach(func() {
+			config = `lighthouse {
+			    health-threshold 0.5
+            }`
+		})
+
+		It("should succeed with the threshold set", func() {
+			Expect(lh.healthThreshold).To(Equal(0.5))
+		})
+	})
+
+	When("transfer argument is specified", func() {
+		BeforeEach(func() {
+			config = `lighthouse {
+			    transfer Transfer-Key c2VjcmV0 10.0.0.0/8 192.168.1.1
+            }`
+		})
+
+		It("should succeed with zone transfers enabled", func() {
+			Expect(lh.transfer).ToNot(BeNil())
+			Expect(lh.transfer.keyName).To(Equal("transfer-key."))
+			Expect(lh.transfer.secret).To(Equal("c2VjcmV0"))
+			Expect(lh.transfer.allowed("10.1.2.3")).To(BeTrue())
+			Expect(lh.transfer.allowed("192.168.1.1")).To(BeTrue())
+			Expect(lh.transfer.allowed("192.168.1.2")).To(BeFalse())
+		})
+	})
+
+	When("resolve-srv-targets argument is specified", func() {
+		BeforeEach(func() {
+			config = `lighthouse {
+			    resolve-srv-targets
+            }`
+		})
+
+		It("should succeed with an upstream set", func() {
+			Expect(lh.upstream).ToNot(BeNil())
+		})
+	})
+
+	When("globalnet argument is specified", func() {
+		BeforeEach(func() {
+			config = `lighthouse {
+			    globalnet
+            }`
+		})
+
+		It("should succeed and wait for the GlobalIngressIPs to be synced", func() {
+			Eventually(lh.Ready, 5).Should(BeTrue())
+		})
+	})
+
+	When("stats argument is specified", func() {
+		BeforeEach(func() {
+			config = `lighthouse {
+			    stats 30s
+            }`
+		})
+
+		It("should succeed with the statistics collector populated", func() {
+			Expect(lh.stats).ToNot(BeNil())
+		})
+	})
+
+	When("reconnect-delay argument is specified", func() {
+		BeforeEach(func() {
+			config = `lighthouse {
+			    reconnect-delay 30s
+            }`
+		})
+
+		It("should succeed with the gateway's stable period set", func() {
+			Expect(lh.clusterStatus.(*gateway.Controller).StableFor).To(Equal(30 * time.Second))
+		})
+	})
+
+	When("degraded-connectivity argument is specified", func() {
+		BeforeEach(func() {
+			config = `lighthouse {
+			    degraded-connectivity serve-stale
+            }`
+		})
+
+		It("should succeed with the gateway's degraded policy set", func() {
+			Expect(lh.clusterStatus.(*gateway.Controller).DegradedPolicy).To(Equal(gateway.ServeStale))
+		})
+	})
+
+	When("cluster-status argument is specified", func() {
+		BeforeEach(func() {
+			config = `lighthouse {
+			    cluster-status always-connected
+            }`
+		})
+
+		It("should succeed with the cluster status backend set", func() {
+			Expect(lh.clusterStatus).To(BeAssignableToTypeOf(&clusterstatus.AlwaysConnected{}))
+		})
+	})
+
+	When("cluster-status argument is specified with an HTTP health endpoint", func() {
+		BeforeEach(func() {
+			config = `lighthouse {
+			    cluster-status http http://health.example.com/{cluster} 30s
+            }`
+		})
+
+		It("should succeed with the cluster status backend set", func() {
+			Expect(lh.clusterStatus).To(BeAssignableToTypeOf(&clusterstatus.HTTP{}))
+		})
+	})
+
+	When("standalone argument is specified", func() {
+		BeforeEach(func() {
+			config = `lighthouse {
+			    standalone
+            }`
+
+			serviceimport.NewClientset = func(kubeConfig *rest.Config) (mcsClientset.Interface, error) {
+				return fakeMCSClientset.NewSimpleClientset(&mcsv1a1.ServiceImport{
+					ObjectMeta: metav1.ObjectMeta{Name: "nginx", Namespace: "default"},
+					Spec:       mcsv1a1.ServiceImportSpec{Type: mcsv1a1.ClusterSetIP, IPs: []string{"243.1.0.1"}},
+					Status:     mcsv1a1.ServiceImportStatus{Clusters: []mcsv1a1.ClusterStatus{{Cluster: "west"}}},
+				}), nil
+			}
+		})
+
+		It("should succeed with every cluster connected and healthy", func() {
+			Expect(lh.clusterStatus).To(BeAssignableToTypeOf(&clusterstatus.AlwaysConnected{}))
+			Expect(lh.endpointsStatus).To(Equal(staticStatus{}))
+		})
+
+		It("should serve the ServiceImports created by other MCS implementations", func() {
+			Eventually(func() []string {
+				return lh.serviceImports.Services("default")
+			}).Should(ConsistOf("nginx"))
+		})
+	})
+
+	When("standalone argument is specified with a cluster status backend", func() {
+		BeforeEach(func() {
+			config = `lighthouse {
+			    standalone
+			    cluster-status http http://health.example.com/{cluster}
+            }`
+		})
+
+		It("should succeed with the cluster status backend kept", func() {
+			Expect(lh.clusterStatus).To(BeAssignableToTypeOf(&clusterstatus.HTTP{}))
+		})
+	})
+
+	When("kubeconfig-secrets argument is specified", func() {
+		var kubeClient *fakeKubeClient.Clientset
+
+		BeforeEach(func() {
+			config = `lighthouse {
+			    kubeconfig-secrets dns-tier cluster1
+            }`
+
+			kubeClient = fakeKubeClient.NewSimpleClientset(newKubeConfigSecret("https://cluster1.example.com:6443"))
+			newKubeClientset = func(c *rest.Config) (kubernetes.Interface, error) {
+				return kubeClient, nil
+			}
+
+			// The remote cluster serves nginx, or httpd once reached through its new server
+			serviceImports := map[string]*mcsv1a1.ServiceImport{
+				"https://cluster1.example.com:6443": newServiceImport("default", "nginx", "cluster1", serviceIP, portName1,
+					portNumber1, protocol1, mcsv1a1.ClusterSetIP),
+				"https://cluster1-new.example.com:6443": newServiceImport("default", "httpd", "cluster1", serviceIP, portName1,
+					portNumber1, protocol1, mcsv1a1.ClusterSetIP),
+			}
+
+			serviceimport.NewClientset = func(kubeConfig *rest.Config) (mcsClientset.Interface, error) {
+				if si, ok := serviceImports[kubeConfig.Host]; ok {
+					return fakeMCSClientset.NewSimpleClientset(si), nil
+				}
+
+				return fakeMCSClientset.NewSimpleClientset(), nil
+			}
+		})
+
+		It("should report ready once the remote clusters' ServiceImports and EndpointSlices are loaded", func() {
+			Eventually(lh.Ready).Should(BeTrue())
+			Expect(lh.serviceImports.Services("default")).To(ConsistOf("nginx"))
+		})
+
+		It("should reconnect to a remote cluster when its Secret is given a new kubeconfig", func() {
+			Eventually(lh.Ready).Should(BeTrue())
+
+			_, err := kubeClient.CoreV1().Secrets("dns-tier").Update(context.TODO(),
+				newKubeConfigSecret("https://cluster1-new.example.com:6443"), metav1.UpdateOptions{})
+			Expect(err).To(Succeed())
+
+			Eventually(func() []string {
+				return lh.serviceImports.Services("default")
+			}).Should(ConsistOf("httpd"))
+		})
+	})
+
+	When("cache-memory argument is specified", func() {
+		BeforeEach(func() {
+			config = `lighthouse {
+			    cache-memory 64Mi
+            }`
+		})
+
+		It("should succeed", func() {
+			Expect(lh).ToNot(BeNil())
+		})
+	})
+
+	When("querylog argument is specified", func() {
+		BeforeEach(func() {
+			config = `lighthouse {
+			    querylog 0.1
+            }`
+		})
+
+		It("should succeed with the query log sampling rate set", func() {
+			Expect(lh.queryLog).ToNot(BeNil())
+			Expect(lh.queryLog.rate).To(Equal(0.1))
+		})
+	})
+
+	When("serve-stale argument is specified", func() {
+		BeforeEach(func() {
+			config = `lighthouse {
+			    serve-stale
+            }`
+		})
+
+		It("should succeed with the default window and TTL", func() {
+			Expect(lh.stale).ToNot(BeNil())
+			Expect(lh.stale.window).To(Equal(defaultStaleWindow))
+			Expect(lh.stale.ttl).To(Equal(defaultStaleTTL))
+			Expect(lh.stale.sources).To(HaveLen(2))
+		})
+	})
+
+	When("serve-stale argument is specified with a window and TTL", func() {
+		BeforeEach(func() {
+			config = `lighthouse {
+			    serve-stale 1h 60
+            }`
+		})
+
+		It("should succeed with the window and TTL set", func() {
+			Expect(lh.stale.window).To(Equal(time.Hour))
+			Expect(lh.stale.ttl).To(Equal(uint32(60)))
+		})
+	})
+
+	When("broker-out-of-sync argument is specified", func() {
+		BeforeEach(func() {
+			config = `lighthouse {
+			    broker-out-of-sync
+            }`
+		})
+
+		It("should succeed with the default TTL", func() {
+			Expect(lh.brokerOutOfSync).ToNot(BeNil())
+			Expect(lh.brokerOutOfSync.ttl).To(Equal(defaultOutOfSyncTTL))
+			Expect(lh.brokerOutOfSync.outOfSyncSince).ToNot(BeNil())
+		})
+	})
+
+	When("broker-out-of-sync argument is specified with a TTL and namespace", func() {
+		BeforeEach(func() {
+			config = `lighthouse {
+			    broker-out-of-sync 120 submariner
+            }`
+		})
+
+		It("should succeed with the TTL set", func() {
+			Expect(lh.brokerOutOfSync.ttl).To(Equal(uint32(120)))
+		})
+	})
+
+	When("maintenance argument is specified", func() {
+		BeforeEach(func() {
+			config = `lighthouse {
+			    maintenance submariner
+            }`
+		})
+
+		It("should succeed with the clusters in maintenance checked", func() {
+			Expect(lh.inMaintenance).ToNot(BeNil())
+			Expect(lh.inMaintenance(clusterID)).To(BeFalse())
+		})
+	})
+
+	When("disconnected-clusters argument is specified", func() {
+		BeforeEach(func() {
+			config = `lighthouse {
+			    disconnected-clusters servfail
+            }`
+		})
+
+		It("should succeed with the policy set", func() {
+			Expect(lh.disconnectedPolicy).To(Equal(failDisconnected))
+		})
+	})
+
+	When("disconnected-clusters argument is specified to serve anyway with a TTL", func() {
+		BeforeEach(func() {
+			config = `lighthouse {
+			    disconnected-clusters serve-anyway 3
+            }`
+		})
+
+		It("should succeed with the policy and TTL set", func() {
+			Expect(lh.disconnectedPolicy).To(Equal(serveDisconnected))
+			Expect(lh.disconnectedTTL).To(Equal(uint32(3)))
+		})
+	})
+
+	When("negative-cache argument is specified", func() {
+		BeforeEach(func() {
+			config = `lighthouse {
+			    negative-cache
+            }`
+		})
+
+		It("should succeed with the default TTL and size", func() {
+			Expect(lh.negative).ToNot(BeNil())
+			Expect(lh.negative.ttl).To(Equal(defaultNegativeTTL))
+			Expect(lh.negative.size).To(Equal(defaultNegativeCacheSize))
+		})
+	})
+
+	When("negative-cache argument is specified with a TTL and size", func() {
+		BeforeEach(func() {
+			config = `lighthouse {
+			    negative-cache 60 100
+            }`
+		})
+
+		It("should succeed with the TTL and size set", func() {
+			Expect(lh.negative.ttl).To(Equal(uint32(60)))
+			Expect(lh.negative.size).To(Equal(100))
+		})
+	})
+
+	When("metrics-namespaces argument is specified", func() {
+		BeforeEach(func() {
+			config = `lighthouse {
+			    metrics-namespaces ns1 ns2
+            }`
+		})
+
+		It("should succeed with the metrics allow-list set", func() {
+			Expect(lh.namespaceMetrics.allowed).To(Equal(map[string]bool{"ns1": true, "ns2": true}))
+		})
+	})
+
+	When("wildcard argument is specified", func() {
+		BeforeEach(func() {
+			config = `lighthouse {
+			    wildcard
+            }`
+		})
+
+		It("should succeed with the default wildcard limit", func() {
+			Expect(lh.wildcardLimit).To(Equal(defaultWildcardLimit))
+		})
+	})
+
+	When("wildcard argument is specified with a limit", func() {
+		BeforeEach(func() {
+			config = `lighthouse {
+			    wildcard 10
+            }`
+		})
+
+		It("should succeed with the wildcard limit set", func() {
+			Expect(lh.wildcardLimit).To(Equal(10))
+		})
+	})
+
+	When("any-types argument is specified", func() {
+		BeforeEach(func() {
+			config = `lighthouse {
+			    any-types a srv
+            }`
+		})
+
+		It("should succeed with the ANY query types set", func() {
+			Expect(lh.anyTypes).To(Equal([]uint16{dns.TypeA, dns.TypeSRV}))
+		})
+	})
+
+	When("cluster-selection argument is specified", func() {
+		BeforeEach(func() {
+			config = `lighthouse {
+			    cluster-selection round-robin
+            }`
+		})
+
+		It("should succeed with the cluster selection policy set", func() {
+			Expect(lh.clusterSelection).To(Equal(roundRobinSelection))
+		})
+	})
+
+	When("topology argument is specified with a zone and region", func() {
+		BeforeEach(func() {
+			config = `lighthouse {
+			    topology prefer-zone zone1 region1
+            }`
+		})
+
+		It("should succeed with the topology preference set", func() {
+			Expect(lh.topology).To(Equal(&topologyPreference{zone: "zone1", region: "region1"}))
+		})
+	})
+
+	When("topology argument is specified without a zone", func() {
+		BeforeEach(func() {
+			config = `lighthouse {
+			    topology prefer-zone
+            }`
+
+			os.Setenv("NODE_NAME", "node1")
+			getNodeTopology = func(cfg *rest.Config, nodeName string) (string, string, error) {
+				return nodeName + "-zone", nodeName + "-region", nil
+			}
+		})
+
+		AfterEach(func() {
+			os.Unsetenv("NODE_NAME")
+			getNodeTopology = nodeTopology
+		})
+
+		It("should succeed with the topology of the node", func() {
+			Expect(lh.topology).To(Equal(&topologyPreference{zone: "node1-zone", region: "node1-region"}))
+		})
+	})
+
+	When("alias-mode argument is specified", func() {
+		BeforeEach(func() {
+			config = `lighthouse {
+			    alias-mode cname
+            }`
+		})
+
+		It("should succeed with the alias mode set", func() {
+			Expect(lh.aliasMode).To(Equal(cnameAliasMode))
+		})
+	})
+
+	When("srv-prefixes argument is specified", func() {
+		BeforeEach(func() {
+			config = `lighthouse {
+			    srv-prefixes srv
+            }`
+		})
+
+		It("should succeed with the SRV prefixes set", func() {
+			Expect(lh.srvPrefixes).To(Equal(srvSRVPrefixes))
+		})
+	})
+
+	When("cluster-alias arguments are specified", func() {
+		BeforeEach(func() {
+			config = `lighthouse {
+			    cluster-alias east cluster1
+			    cluster-alias west cluster2
+            }`
+		})
+
+		It("should succeed with the cluster aliases set", func() {
+			Expect(lh.clusterAliases).To(Equal(map[string]string{"east": "cluster1", "west": "cluster2"}))
+		})
+	})
+
+	When("srv-priority argument is specified", func() {
+		BeforeEach(func() {
+			config = `lighthouse {
+			    srv-priority 5 20
+            }`
+		})
+
+		It("should succeed with the SRV priorities set", func() {
+			Expect(lh.srvLocalPriority).To(Equal(uint16(5)))
+			Expect(lh.srvRemotePriority).To(Equal(uint16(20)))
+		})
+	})
+
+	When("loadbalance hash argument is specified", func() {
+		BeforeEach(func() {
+			config = `lighthouse {
+			    loadbalance hash
+            }`
+		})
+
+		It("should succeed with the load balancing policy populated correctly", func() {
+			Expect(lh.loadBalance).Should(Equal(hashLoadBalance))
+		})
+	})
+
+	When("grpc-endpoint argument is specified", func() {
+		BeforeEach(func() {
+			config = `lighthouse {
+			    grpc-endpoint :9053
+            }`
+		})
+
+		It("should succeed", func() {
+			Expect(lh).ToNot(BeNil())
+		})
+	})
+
+	When("grpc-endpoint argument is specified with tls", func() {
+		BeforeEach(func() {
+			config = `lighthouse {
+			    tls-secret kube-system/lighthouse-tls
+			    grpc-endpoint 0.0.0.0:9053 tls
+            }`
+		})
+
+		It("should succeed", func() {
+			Expect(lh).ToNot(BeNil())
+		})
+	})
+
+	It("Should handle missing optional fields", func() {
+		config := `lighthouse`
+		c := caddy.NewTestController("dns", config)
+		lh, err := lighthouseParse(c)
+		Expect(err).NotTo(HaveOccurred())
+		setupErr := setupLighthouse(c) // For coverage
+		Expect(setupErr).NotTo(HaveOccurred())
+		Expect(lh.Fall).Should(Equal(fall.F{}))
+		Expect(lh.Zones).Should(BeEmpty())
+		Expect(lh.ttl).Should(Equal(defaultTTL))
+		Expect(lh.loadBalance).Should(Equal(roundRobinLoadBalance))
+	})
+}
+
+func testIncorrectConfig() {
+	var (
+		setupErr error
+		config   string
+	)
+
+	JustBeforeEach(func() {
+		setupErr = setupLighthouse(caddy.NewTestController("dns", config))
+	})
+
+	When("an unexpected argument is specified", func() {
+		BeforeEach(func() {
+			config = `lighthouse {
+                dummy
+		    } noplugin`
+
+			buildKubeConfigFunc = func(masterUrl, kubeconfigPath string) (*rest.Config, error) {
+				return &rest.Config{}, nil
+			}
+		})
+
+		It("should return an appropriate plugin error", func() {
+			verifyPluginError(setupErr, "dummy")
+		})
+	})
+
+	When("an empty ttl is specified", func() {
+		BeforeEach(func() {
+			config = `lighthouse {
+                ttl
+		    } noplugin`
+
+			buildKubeConfigFunc = func(masterUrl, kubeconfigPath string) (*rest.Config, error) {
+				return &rest.Config{}, nil
+			}
+		})
+
+		It("should return an appropriate plugin error", func() {
+			verifyPluginError(setupErr, "Wrong argument count")
+		})
+	})
+
+	When("an invalid ttl is specified", func() {
+		BeforeEach(func() {
+			config = `lighthouse {
+                ttl -10
+		    } noplugin`
+
+			buildKubeConfigFunc = func(masterUrl, kubeconfigPath string) (*rest.Config, error) {
+				return &rest.Config{}, nil
+			}
+		})
+
+		It("should return an appropriate plugin error", func() {
+			verifyPluginError(setupErr, "ttl must be in range [0, 3600]: -10")
+		})
+	})
+
+	When("a ttl is specified for an unknown record class", func() {
+		BeforeEach(func() {
+			config = `lighthouse {
+                ttl 5 pods 2
+		    } noplugin`
+
+			buildKubeConfigFunc = func(masterUrl, kubeconfigPath string) (*rest.Config, error) {
+				return &rest.Config{}, nil
+			}
+		})
+
+		It("should return an appropriate plugin error", func() {
+			verifyPluginError(setupErr, "unknown ttl record class \"pods\"")
+		})
+	})
+
+	When("an invalid ttl is specified for a record class", func() {
+		BeforeEach(func() {
+			config = `lighthouse {
+                ttl 5 srv 4000
+		    } noplugin`
+
+			buildKubeConfigFunc = func(masterUrl, kubeconfigPath string) (*rest.Config, error) {
+				return &rest.Config{}, nil
+			}
+		})
+
+		It("should return an appropriate plugin error", func() {
+			verifyPluginError(setupErr, "srv ttl must be in range [0, 3600]: 4000")
+		})
+	})
+
+	When("grpc-endpoint is specified with a non-loopback address without tls", func() {
+		BeforeEach(func() {
+			config = `lighthouse {
+                grpc-endpoint 10.0.0.1:9053
+		    } noplugin`
+
+			buildKubeConfigFunc = func(masterUrl, kubeconfigPath string) (*rest.Config, error) {
+				return &rest.Config{}, nil
+			}
+		})
+
+		It("should return an appropriate plugin error", func() {
+			verifyPluginError(setupErr, `grpc-endpoint "10.0.0.1:9053" must be a loopback address unless served with tls`)
+		})
+	})
+
+	When("grpc-endpoint is specified with tls without tls-secret", func() {
+		BeforeEach(func() {
+			config = `lighthouse {
+                grpc-endpoint :9053 tls
+		    } noplugin`
+
+			buildKubeConfigFunc = func(masterUrl, kubeconfigPath string) (*rest.Config, error) {
+				return &rest.Config{}, nil
+			}
+		})
+
+		It("should return an appropriate plugin error", func() {
+			verifyPluginError(setupErr, "grpc-endpoint with tls requires tls-secret")
+		})
+	})
+
+	When("grpc-endpoint is specified without an address", func() {
+		BeforeEach(func() {
+			config = `lighthouse {
+                grpc-endpoint
+		    } noplugin`
+
+			buildKubeConfigFunc = func(masterUrl, kubeconfigPath string) (*rest.Config, error) {
+				return &rest.Config{}, nil
+			}
+		})
+
+		It("should return an appropriate plugin error", func() {
+			verifyPluginError(setupErr, "Wrong argument count")
+		})
+	})
+
+	When("clusterset-domain is specified without a domain", func() {
+		BeforeEach(func() {
+			config = `lighthouse {
+                clusterset-domain
+		    } noplugin`
+
+			buildKubeConfigFunc = func(masterUrl, kubeconfigPath string) (*rest.Config, error) {
+				return &rest.Config{}, nil
+			}
+		})
+
+		It("should return an appropriate plugin error", func() {
+			verifyPluginError(setupErr, "Wrong argument count")
+		})
+	})
+
+	When("clusterset-domain is outside the plugin zones", func() {
+		BeforeEach(func() {
+			config = `lighthouse clusterset.local {
+                clusterset-domain mesh.example.org
+		    } noplugin`
+
+			buildKubeConfigFunc = func(masterUrl, kubeconfigPath string) (*rest.Config, error) {
+				return &rest.Config{}, nil
+			}
+		})
+
+		It("should return an appropriate plugin error", func() {
+			verifyPluginError(setupErr, "is not within the plugin zones")
+		})
+	})
+
+	When("an invalid circuit-breaker threshold is specified", func() {
+		BeforeEach(func() {
+			config = `lighthouse {
+                circuit-breaker 0
+		    } noplugin`
+
+			buildKubeConfigFunc = func(masterUrl, kubeconfigPath string) (*rest.Config, error) {
+				return &rest.Config{}, nil
+			}
+		})
+
+		It("should return an appropriate plugin error", func() {
+			verifyPluginError(setupErr, "circuit-breaker threshold must be a positive integer: 0")
+		})
+	})
+
+	When("an unknown loadbalance policy is specified", func() {
+		BeforeEach(func() {
+			config = `lighthouse {
+                loadbalance random
+		    } noplugin`
+
+			buildKubeConfigFunc = func(masterUrl, kubeconfigPath string) (*rest.Config, error) {
+				return &rest.Config{}, nil
+			}
+		})
+
+		It("should return an appropriate plugin error", func() {
+			verifyPluginError(setupErr, "unknown loadbalance policy")
+		})
+	})
+
+	When("dnssec is specified without a namespace", func() {
+		BeforeEach(func() {
+			config = `lighthouse {
+                dnssec lighthouse-dnssec
+		    } noplugin`
+
+			buildKubeConfigFunc = func(masterUrl, kubeconfigPath string) (*rest.Config, error) {
+				return &rest.Config{}, nil
+			}
+		})
+
+		It("should return an appropriate plugin error", func() {
+			verifyPluginError(setupErr, "dnssec Secret must be given as NAMESPACE/NAME: lighthouse-dnssec")
+		})
+	})
+
+	When("tls-forward is specified without tls-secret", func() {
+		BeforeEach(func() {
+			config = `lighthouse {
+                tls-forward west.clusterset.local tls://10.0.0.1
+		    } noplugin`
+
+			buildKubeConfigFunc = func(masterUrl, kubeconfigPath string) (*rest.Config, error) {
+				return &rest.Config{}, nil
+			}
+		})
+
+		It("should return an appropriate plugin error", func() {
+			verifyPluginError(setupErr, "tls-listen and tls-forward require tls-secret")
+		})
+	})
+
+	When("tls-forward is specified with an unsupported upstream", func() {
+		BeforeEach(func() {
+			config = `lighthouse {
+                tls-secret kube-system/lighthouse-tls
+                tls-forward west.clusterset.local udp://10.0.0.1
+		    } noplugin`
+
+			buildKubeConfigFunc = func(masterUrl, kubeconfigPath string) (*rest.Config, error) {
+				return &rest.Config{}, nil
+			}
+		})
+
+		It("should return an appropriate plugin error", func() {
+			verifyPluginError(setupErr, "invalid tls-forward")
+		})
+	})
+
+	When("an invalid ratelimit scope is specified", func() {
+		BeforeEach(func() {
+			config = `lighthouse {
+                ratelimit namespace 50
+		    } noplugin`
+
+			buildKubeConfigFunc = func(masterUrl, kubeconfigPath string) (*rest.Config, error) {
+				return &rest.Config{}, nil
+			}
+		})
+
+		It("should return an appropriate plugin error", func() {
+			verifyPluginError(setupErr, "ratelimit must be \"client\" or \"total\": namespace")
+		})
+	})
+
+	When("cache-eviction is specified with arguments", func() {
+		BeforeEach(func() {
+			config = `lighthouse {
+                cache-eviction cache
+		    } noplugin`
+
+			buildKubeConfigFunc = func(masterUrl, kubeconfigPath string) (*rest.Config, error) {
+				return &rest.Config{}, nil
+			}
+		})
+
+		It("should return an appropriate plugin error", func() {
+			verifyPluginError(setupErr, "Wrong argument count")
+		})
+	})
+
+	When("globalnet has an unexpected argument", func() {
+		BeforeEach(func() {
+			config = `lighthouse {
+                globalnet yes
+		    } noplugin`
+
+			buildKubeConfigFunc = func(masterUrl, kubeconfigPath string) (*rest.Config, error) {
+				return &rest.Config{}, nil
+			}
+		})
+
+		It("should return an appropriate plugin error", func() {
+			verifyPluginError(setupErr, "Wrong argument count")
+		})
+	})
+
+	When("an invalid namespace is specified", func() {
+		BeforeEach(func() {
+			config = `lighthouse {
+                exclude-namespaces Not_A_Namespace
+		    } noplugin`
+
+			buildKubeConfigFunc = func(masterUrl, kubeconfigPath string) (*rest.Config, error) {
+				return &rest.Config{}, nil
+			}
+		})
+
+		It("should return an appropriate plugin error", func() {
+			verifyPluginError(setupErr, "invalid namespace")
+		})
+	})
+
+	When("disable is specified with an unknown record type", func() {
+		BeforeEach(func() {
+			config = `lighthouse {
+                disable mx
+		    } noplugin`
+
+			buildKubeConfigFunc = func(masterUrl, kubeconfigPath string) (*rest.Config, error) {
+				return &rest.Config{}, nil
+			}
+		})
+
+		It("should return an appropriate plugin error", func() {
+			verifyPluginError(setupErr, "unknown record type to disable")
+		})
+	})
+
+	When("a missing reload-config directory is specified", func() {
+		BeforeEach(func() {
+			config = `lighthouse {
+                reload-config /nonexistent/lighthouse
+		    } noplugin`
+
+			buildKubeConfigFunc = func(masterUrl, kubeconfigPath string) (*rest.Config, error) {
+				return &rest.Config{}, nil
+			}
+		})
+
+		It("should return an appropriate plugin error", func() {
+			verifyPluginError(setupErr, "reload-config must be an existing directory")
+		})
+	})
+
+	When("an invalid stats interval is specified", func() {
+		BeforeEach(func() {
+			config = `lighthouse {
+                stats 0s
+		    } noplugin`
+
+			buildKubeConfigFunc = func(masterUrl, kubeconfigPath string) (*rest.Config, error) {
+				return &rest.Config{}, nil
+			}
+		})
+
+		It("should return an appropriate plugin error", func() {
+			verifyPluginError(setupErr, "stats interval must be a positive duration")
+		})
+	})
+
+	When("an unknown degraded-connectivity policy is specified", func() {
+		BeforeEach(func() {
+			config = `lighthouse {
+                degraded-connectivity ignore
+		    } noplugin`
+
+			buildKubeConfigFunc = func(masterUrl, kubeconfigPath string) (*rest.Config, error) {
+				return &rest.Config{}, nil
+			}
+		})
+
+		It("should return an appropriate plugin error", func() {
+			verifyPluginError(setupErr, "unknown degraded-connectivity policy")
+		})
+	})
+
+	When("an invalid reconnect-delay is specified", func() {
+		BeforeEach(func() {
+			config = `lighthouse {
+                reconnect-delay soon
+		    } noplugin`
+
+			buildKubeConfigFunc = func(masterUrl, kubeconfigPath string) (*rest.Config, error) {
+				return &rest.Config{}, nil
+			}
+		})
+
+		It("should return an appropriate plugin error", func() {
+			verifyPluginError(setupErr, "reconnect-delay must be a non-negative duration")
+		})
+	})
+
+	When("an invalid querylog sampling rate is specified", func() {
+		BeforeEach(func() {
+			config = `lighthouse {
+                querylog 2
+		    } noplugin`
+
+			buildKubeConfigFunc = func(masterUrl, kubeconfigPath string) (*rest.Config, error) {
+				return &rest.Config{}, nil
+			}
+		})
+
+		It("should return an appropriate plugin error", func() {
+			verifyPluginError(setupErr, "querylog sampling rate must be greater than 0 and at most 1")
+		})
+	})
+
+	When("an invalid serve-stale window is specified", func() {
+		BeforeEach(func() {
+			config = `lighthouse {
+                serve-stale never
+		    } noplugin`
+
+			buildKubeConfigFunc = func(masterUrl, kubeconfigPath string) (*rest.Config, error) {
+				return &rest.Config{}, nil
+			}
+		})
+
+		It("should return an appropriate plugin error", func() {
+			verifyPluginError(setupErr, "serve-stale window must be a positive duration")
+		})
+	})
+
+	When("an invalid broker-out-of-sync TTL is specified", func() {
+		BeforeEach(func() {
+			config = `lighthouse {
+                broker-out-of-sync 0
+		    } noplugin`
+
+			buildKubeConfigFunc = func(masterUrl, kubeconfigPath string) (*rest.Config, error) {
+				return &rest.Config{}, nil
+			}
+		})
+
+		It("should return an appropriate plugin error", func() {
+			verifyPluginError(setupErr, "broker-out-of-sync ttl must be in range [1, 3600]")
+		})
+	})
+
+	When("an invalid maintenance namespace is specified", func() {
+		BeforeEach(func() {
+			config = `lighthouse {
+                maintenance Submariner_Operator
+		    } noplugin`
+
+			buildKubeConfigFunc = func(masterUrl, kubeconfigPath string) (*rest.Config, error) {
+				return &rest.Config{}, nil
+			}
+		})
+
+		It("should return an appropriate plugin error", func() {
+			verifyPluginError(setupErr, "invalid namespace")
+		})
 	})
 
-	AfterEach(func() {
-		gateway.NewClientset = nil
+	When("a kubeconfig Secret which doesn't exist is specified", func() {
+		BeforeEach(func() {
+			config = `lighthouse {
+                kubeconfig-secrets dns-tier cluster1
+		    } noplugin`
+
+			buildKubeConfigFunc = func(masterUrl, kubeconfigPath string) (*rest.Config, error) {
+				return &rest.Config{}, nil
+			}
+		})
+
+		It("should return an appropriate plugin error", func() {
+			verifyPluginError(setupErr, "error retrieving the kubeconfig Secret dns-tier/cluster1")
+		})
 	})
 
-	Context("Parsing correct configurations", testCorrectConfig)
-	Context("Parsing incorrect configurations", testIncorrectConfig)
-	Context("Plugin registration", testPluginRegistration)
-})
+	When("an invalid cache-memory bound is specified", func() {
+		BeforeEach(func() {
+			config = `lighthouse {
+                cache-memory -1Mi
+		    } noplugin`
 
-func testCorrectConfig() {
-	var (
-		lh     *Lighthouse
-		config string
-	)
+			buildKubeConfigFunc = func(masterUrl, kubeconfigPath string) (*rest.Config, error) {
+				return &rest.Config{}, nil
+			}
+		})
 
-	BeforeEach(func() {
-		buildKubeConfigFunc = func(masterUrl, kubeconfigPath string) (*rest.Config, error) {
-			return &rest.Config{}, nil
-		}
+		It("should return an appropriate plugin error", func() {
+			verifyPluginError(setupErr, "cache-memory must be a positive quantity of bytes")
+		})
+	})
 
-		config = PluginName
+	When("an unknown cluster-status backend is specified", func() {
+		BeforeEach(func() {
+			config = `lighthouse {
+                cluster-status consul
+		    } noplugin`
+
+			buildKubeConfigFunc = func(masterUrl, kubeconfigPath string) (*rest.Config, error) {
+				return &rest.Config{}, nil
+			}
+		})
+
+		It("should return an appropriate plugin error", func() {
+			verifyPluginError(setupErr, "unknown cluster-status backend")
+		})
 	})
 
-	JustBeforeEach(func() {
-		var err error
-		lh, err = lighthouseParse(caddy.NewTestController("dns", config))
-		Expect(err).To(Succeed())
+	When("a cluster-status file which doesn't exist is specified", func() {
+		BeforeEach(func() {
+			config = `lighthouse {
+                cluster-status file /nonexistent/connected-clusters
+		    } noplugin`
+
+			buildKubeConfigFunc = func(masterUrl, kubeconfigPath string) (*rest.Config, error) {
+				return &rest.Config{}, nil
+			}
+		})
+
+		It("should return an appropriate plugin error", func() {
+			verifyPluginError(setupErr, "error starting the cluster status backend")
+		})
 	})
 
-	When("no optional arguments are specified", func() {
-		It("should succeed with empty zones and fallthrough fields", func() {
-			Expect(lh.Fall).To(Equal(fall.F{}))
-			Expect(lh.Zones).To(BeEmpty())
+	When("an unknown disconnected-clusters policy is specified", func() {
+		BeforeEach(func() {
+			config = `lighthouse {
+                disconnected-clusters ignore
+		    } noplugin`
+
+			buildKubeConfigFunc = func(masterUrl, kubeconfigPath string) (*rest.Config, error) {
+				return &rest.Config{}, nil
+			}
+		})
+
+		It("should return an appropriate plugin error", func() {
+			verifyPluginError(setupErr, "unknown disconnected-clusters policy")
 		})
 	})
 
-	When("lighthouse zone and fallthrough zone arguments are specified", func() {
+	When("an invalid negative-cache TTL is specified", func() {
 		BeforeEach(func() {
-			config = `lighthouse cluster2.local cluster3.local {
-			    fallthrough cluster2.local
-            }`
+			config = `lighthouse {
+                negative-cache 0
+		    } noplugin`
+
+			buildKubeConfigFunc = func(masterUrl, kubeconfigPath string) (*rest.Config, error) {
+				return &rest.Config{}, nil
+			}
 		})
 
-		It("should succeed with the zones and fallthrough fields populated correctly", func() {
-			Expect(lh.Fall).To(Equal(fall.F{Zones: []string{"cluster2.local."}}))
-			Expect(lh.Zones).To(Equal([]string{"cluster2.local.", "cluster3.local."}))
+		It("should return an appropriate plugin error", func() {
+			verifyPluginError(setupErr, "negative-cache ttl must be in range [1, 3600]: 0")
 		})
 	})
 
-	When("fallthrough argument with no zones is specified", func() {
+	When("a transfer secret which isn't base64-encoded is specified", func() {
 		BeforeEach(func() {
 			config = `lighthouse {
-			    fallthrough
-            }`
+                transfer transfer-key not-base64!
+		    } noplugin`
+
+			buildKubeConfigFunc = func(masterUrl, kubeconfigPath string) (*rest.Config, error) {
+				return &rest.Config{}, nil
+			}
 		})
 
-		It("should succeed with the root fallthrough zones", func() {
-			Expect(lh.Fall).Should(Equal(fall.Root))
-			Expect(lh.Zones).Should(BeEmpty())
+		It("should return an appropriate plugin error", func() {
+			verifyPluginError(setupErr, "transfer secret must be base64-encoded")
 		})
 	})
 
-	When("ttl arguments is specified", func() {
+	When("an invalid transfer network is specified", func() {
 		BeforeEach(func() {
 			config = `lighthouse {
-			    ttl 30
-            }`
+                transfer transfer-key c2VjcmV0 10.0.0.0/33
+		    } noplugin`
+
+			buildKubeConfigFunc = func(masterUrl, kubeconfigPath string) (*rest.Config, error) {
+				return &rest.Config{}, nil
+			}
 		})
 
-		It("should succeed with the ttl field populated correctly", func() {
-			Expect(lh.ttl).Should(Equal(uint32(30)))
+		It("should return an appropriate plugin error", func() {
+			verifyPluginError(setupErr, "invalid transfer network")
 		})
 	})
 
-	It("Should handle missing optional fields", func() {
-		config := `lighthouse`
-		c := caddy.NewTestController("dns", config)
-		lh, err := lighthouseParse(c)
-		Expect(err).NotTo(HaveOccurred())
-		setupErr := setupLighthouse(c) // For coverage
-		Expect(setupErr).NotTo(HaveOccurred())
-		Expect(lh.Fall).Should(Equal(fall.F{}))
-		Expect(lh.Zones).Should(BeEmpty())
-		Expect(lh.ttl).Should(Equal(defaultTTL))
+	When("an invalid max-endpoints count is specified", func() {
+		BeforeEach(func() {
+			config = `lighthouse {
+                max-endpoints 0
+		    } noplugin`
+
+			buildKubeConfigFunc = func(masterUrl, kubeconfigPath string) (*rest.Config, error) {
+				return &rest.Config{}, nil
+			}
+		})
+
+		It("should return an appropriate plugin error", func() {
+			verifyPluginError(setupErr, "max-endpoints count must be a positive integer: 0")
+		})
 	})
-}
 
-func testIncorrectConfig() {
-	var (
-		setupErr error
-		config   string
-	)
+	When("an unknown max-endpoints mode is specified", func() {
+		BeforeEach(func() {
+			config = `lighthouse {
+                max-endpoints 10 first
+		    } noplugin`
 
-	JustBeforeEach(func() {
-		setupErr = setupLighthouse(caddy.NewTestController("dns", config))
+			buildKubeConfigFunc = func(masterUrl, kubeconfigPath string) (*rest.Config, error) {
+				return &rest.Config{}, nil
+			}
+		})
+
+		It("should return an appropriate plugin error", func() {
+			verifyPluginError(setupErr, "unknown max-endpoints mode")
+		})
 	})
 
-	When("an unexpected argument is specified", func() {
+	When("an invalid prefetch count is specified", func() {
 		BeforeEach(func() {
 			config = `lighthouse {
-                dummy
+                prefetch none
 		    } noplugin`
 
 			buildKubeConfigFunc = func(masterUrl, kubeconfigPath string) (*rest.Config, error) {
@@ -176,14 +1672,14 @@ func testIncorrectConfig() {
 		})
 
 		It("should return an appropriate plugin error", func() {
-			verifyPluginError(setupErr, "dummy")
+			verifyPluginError(setupErr, "prefetch count must be a positive integer: none")
 		})
 	})
 
-	When("an empty ttl is specified", func() {
+	When("an invalid health-threshold is specified", func() {
 		BeforeEach(func() {
 			config = `lighthouse {
-                ttl
+                health-threshold 1.5
 		    } noplugin`
 
 			buildKubeConfigFunc = func(masterUrl, kubeconfigPath string) (*rest.Config, error) {
@@ -192,14 +1688,14 @@ func testIncorrectConfig() {
 		})
 
 		It("should return an appropriate plugin error", func() {
-			verifyPluginError(setupErr, "Wrong argument count")
+			verifyPluginError(setupErr, "health-threshold must be a number between 0 and 1: 1.5")
 		})
 	})
 
-	When("an invalid ttl is specified", func() {
+	When("topology argument is specified without a zone or node name", func() {
 		BeforeEach(func() {
 			config = `lighthouse {
-                ttl -10
+                topology prefer-zone
 		    } noplugin`
 
 			buildKubeConfigFunc = func(masterUrl, kubeconfigPath string) (*rest.Config, error) {
@@ -208,7 +1704,119 @@ func testIncorrectConfig() {
 		})
 
 		It("should return an appropriate plugin error", func() {
-			verifyPluginError(setupErr, "ttl must be in range [0, 3600]: -10")
+			verifyPluginError(setupErr, "topology prefer-zone needs a zone or the NODE_NAME environment variable")
+		})
+	})
+
+	When("an unknown srv-prefixes is specified", func() {
+		BeforeEach(func() {
+			config = `lighthouse {
+                srv-prefixes some
+		    } noplugin`
+
+			buildKubeConfigFunc = func(masterUrl, kubeconfigPath string) (*rest.Config, error) {
+				return &rest.Config{}, nil
+			}
+		})
+
+		It("should return an appropriate plugin error", func() {
+			verifyPluginError(setupErr, "unknown srv-prefixes")
+		})
+	})
+
+	When("an unknown alias-mode is specified", func() {
+		BeforeEach(func() {
+			config = `lighthouse {
+                alias-mode dname
+		    } noplugin`
+
+			buildKubeConfigFunc = func(masterUrl, kubeconfigPath string) (*rest.Config, error) {
+				return &rest.Config{}, nil
+			}
+		})
+
+		It("should return an appropriate plugin error", func() {
+			verifyPluginError(setupErr, "unknown alias-mode")
+		})
+	})
+
+	When("an invalid cluster-alias is specified", func() {
+		BeforeEach(func() {
+			config = `lighthouse {
+                cluster-alias all cluster1
+		    } noplugin`
+
+			buildKubeConfigFunc = func(masterUrl, kubeconfigPath string) (*rest.Config, error) {
+				return &rest.Config{}, nil
+			}
+		})
+
+		It("should return an appropriate plugin error", func() {
+			verifyPluginError(setupErr, "invalid cluster alias")
+		})
+	})
+
+	When("an invalid srv-priority is specified", func() {
+		BeforeEach(func() {
+			config = `lighthouse {
+                srv-priority 0 70000
+		    } noplugin`
+
+			buildKubeConfigFunc = func(masterUrl, kubeconfigPath string) (*rest.Config, error) {
+				return &rest.Config{}, nil
+			}
+		})
+
+		It("should return an appropriate plugin error", func() {
+			verifyPluginError(setupErr, "srv-priority must be in range [0, 65535]")
+		})
+	})
+
+	When("an invalid wildcard limit is specified", func() {
+		BeforeEach(func() {
+			config = `lighthouse {
+                wildcard 0
+		    } noplugin`
+
+			buildKubeConfigFunc = func(masterUrl, kubeconfigPath string) (*rest.Config, error) {
+				return &rest.Config{}, nil
+			}
+		})
+
+		It("should return an appropriate plugin error", func() {
+			verifyPluginError(setupErr, "wildcard limit must be a positive integer")
+		})
+	})
+
+	When("an invalid ANY query type is specified", func() {
+		BeforeEach(func() {
+			config = `lighthouse {
+                any-types MX
+		    } noplugin`
+
+			buildKubeConfigFunc = func(masterUrl, kubeconfigPath string) (*rest.Config, error) {
+				return &rest.Config{}, nil
+			}
+		})
+
+		It("should return an appropriate plugin error", func() {
+			verifyPluginError(setupErr, "any-types must be A, AAAA or SRV")
+		})
+	})
+
+	When("an unknown cluster-selection policy is specified", func() {
+		BeforeEach(func() {
+			config = `lighthouse {
+                cluster-selection nearest
+		    } noplugin`
+
+			buildKubeConfigFunc = func(masterUrl, kubeconfigPath string) (*rest.Config, error) {
+				return &rest.Config{}, nil
+			}
+		})
+
+		It("should return an appropriate plugin error", func() {
+			verifyPluginError(setupErr, "unknown cluster-selection policy")
 		})
 	})
 
@@ -255,3 +1863,21 @@ func verifyPluginError(err error, str string) {
 	Expect(err.Error()).To(HavePrefix("plugin/lighthouse"))
 	Expect(err.Error()).To(ContainSubstring(str))
 }
+
+func newKubeConfigSecret(server string) *corev1.Secret {
+	return &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Name: "cluster1", Namespace: "dns-tier"},
+		Data: map[string][]byte{remoteKubeConfigKey: []byte(`apiVersion: v1
+kind: Config
+clusters:
+- name: cluster1
+  cluster:
+    server: ` + server + `
+contexts:
+- name: cluster1
+  context:
+    cluster: cluster1
+current-context: cluster1
+`)},
+	}
+}
@@ -0,0 +1,220 @@
+/*
+SPDX-License-Identifier: Apache-2.0
+
+Copyright Contributors to the Submariner project.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package lighthouse
+
+import (
+	"sort"
+	"sync"
+
+	lhconstants "github.com/submariner-io/lighthouse/pkg/constants"
+	"github.com/submariner-io/lighthouse/pkg/endpointslice"
+	"github.com/submariner-io/lighthouse/pkg/serviceimport"
+	discovery "k8s.io/api/discovery/v1beta1"
+	mcsv1a1 "sigs.k8s.io/mcs-api/pkg/apis/v1alpha1"
+)
+
+// localSource is the source of the entries put by the controllers watching the local cluster.
+const localSource = ""
+
+// entryStore is the store shared by the sources of the entries.
+type entryStore interface {
+	put(obj interface{})
+	remove(obj interface{})
+}
+
+// sourcedEntries records which of the controllers sharing a store hold each of its entries, with their latest version
+// of it, so that an entry removed by one of them is kept while another still holds it.
+type sourcedEntries struct {
+	mutex   sync.Mutex
+	store   entryStore
+	entries map[string]map[string]interface{}
+	// dropped holds the sources whose entries were dropped, ignoring the changes they still had queued
+	dropped map[string]bool
+}
+
+func newSourcedEntries(store entryStore) *sourcedEntries {
+	return &sourcedEntries{
+		store:   store,
+		entries: map[string]map[string]interface{}{},
+		dropped: map[string]bool{},
+	}
+}
+
+// put records the source's version of the entry and puts it in the store.
+func (s *sourcedEntries) put(key, source string, obj interface{}) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if s.dropped[source] {
+		return
+	}
+
+	versions, ok := s.entries[key]
+	if !ok {
+		versions = map[string]interface{}{}
+		s.entries[key] = versions
+	}
+
+	versions[source] = obj
+	s.store.put(obj)
+}
+
+// remove forgets the source's version of the entry and removes it from the store, putting back the version of another
+// source still holding it.
+func (s *sourcedEntries) remove(key, source string, obj interface{}) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if s.dropped[source] {
+		return
+	}
+
+	s.forget(key, source, obj)
+}
+
+// drop forgets all the entries of the source, removing from the store those no other source holds.
+func (s *sourcedEntries) drop(source string) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	s.dropped[source] = true
+
+	for key, versions := range s.entries {
+		if obj, ok := versions[source]; ok {
+			s.forget(key, source, obj)
+		}
+	}
+}
+
+func (s *sourcedEntries) forget(key, source string, obj interface{}) {
+	versions := s.entries[key]
+	delete(versions, source)
+
+	s.store.remove(obj)
+
+	if len(versions) == 0 {
+		delete(s.entries, key)
+		return
+	}
+
+	// The version of the first remaining source is put back, so that the same one is kept whatever the order of removal
+	sources := make([]string, 0, len(versions))
+	for source := range versions {
+		sources = append(sources, source)
+	}
+
+	sort.Strings(sources)
+	s.store.put(versions[sources[0]])
+}
+
+// serviceImportSources shares a ServiceImport store between sources.
+type serviceImportSources struct {
+	entries *sourcedEntries
+}
+
+func newServiceImportSources(store serviceimport.Store) *serviceImportSources {
+	return &serviceImportSources{entries: newSourcedEntries(serviceImportEntries{store: store})}
+}
+
+// from returns the store of the given source.
+func (s *serviceImportSources) from(source string) serviceimport.Store {
+	return &serviceImportSource{entries: s.entries, source: source}
+}
+
+// drop forgets the ServiceImports of the given source.
+func (s *serviceImportSources) drop(source string) {
+	s.entries.drop(source)
+}
+
+type serviceImportEntries struct {
+	store serviceimport.Store
+}
+
+func (s serviceImportEntries) put(obj interface{}) {
+	s.store.Put(obj.(*mcsv1a1.ServiceImport))
+}
+
+func (s serviceImportEntries) remove(obj interface{}) {
+	s.store.Remove(obj.(*mcsv1a1.ServiceImport))
+}
+
+type serviceImportSource struct {
+	entries *sourcedEntries
+	source  string
+}
+
+func (s *serviceImportSource) Put(serviceImport *mcsv1a1.ServiceImport) {
+	s.entries.put(serviceImportKey(serviceImport), s.source, serviceImport)
+}
+
+func (s *serviceImportSource) Remove(serviceImport *mcsv1a1.ServiceImport) {
+	s.entries.remove(serviceImportKey(serviceImport), s.source, serviceImport)
+}
+
+// serviceImportKey includes the source cluster, since the ServiceImports of other MCS implementations are converted
+// into one per cluster.
+func serviceImportKey(serviceImport *mcsv1a1.ServiceImport) string {
+	return serviceImport.Namespace + "/" + serviceImport.Name + "/" + serviceImport.Labels[lhconstants.LabelSourceCluster]
+}
+
+// endpointSliceSources shares an EndpointSlice store between sources.
+type endpointSliceSources struct {
+	store   endpointslice.Store
+	entries *sourcedEntries
+}
+
+func newEndpointSliceSources(store endpointslice.Store) *endpointSliceSources {
+	return &endpointSliceSources{store: store, entries: newSourcedEntries(endpointSliceEntries{store: store})}
+}
+
+// from returns the store of the given source.
+func (s *endpointSliceSources) from(source string) endpointslice.Store {
+	return &endpointSliceSource{Store: s.store, entries: s.entries, source: source}
+}
+
+// drop forgets the EndpointSlices of the given source.
+func (s *endpointSliceSources) drop(source string) {
+	s.entries.drop(source)
+}
+
+type endpointSliceEntries struct {
+	store endpointslice.Store
+}
+
+func (s endpointSliceEntries) put(obj interface{}) {
+	s.store.Put(obj.(*discovery.EndpointSlice))
+}
+
+func (s endpointSliceEntries) remove(obj interface{}) {
+	s.store.Remove(obj.(*discovery.EndpointSlice))
+}
+
+// endpointSliceSource reads the endpoints from the shared store.
+type endpointSliceSource struct {
+	endpointslice.Store
+	entries *sourcedEntries
+	source  string
+}
+
+func (s *endpointSliceSource) Put(endpointSlice *discovery.EndpointSlice) {
+	s.entries.put(endpointSlice.Namespace+"/"+endpointSlice.Name, s.source, endpointSlice)
+}
+
+func (s *endpointSliceSource) Remove(endpointSlice *discovery.EndpointSlice) {
+	s.entries.remove(endpointSlice.Namespace+"/"+endpointSlice.Name, s.source, endpointSlice)
+}
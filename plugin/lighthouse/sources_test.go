@@ -0,0 +1,112 @@
+/*
+SPDX-License-Identifier: Apache-2.0
+
+Copyright Contributors to the Submariner project.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package lighthouse
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/submariner-io/lighthouse/pkg/serviceimport"
+	mcsv1a1 "sigs.k8s.io/mcs-api/pkg/apis/v1alpha1"
+)
+
+var _ = Describe("Sourced entries", func() {
+	var (
+		store   *fakeEntryStore
+		entries *sourcedEntries
+	)
+
+	BeforeEach(func() {
+		store = &fakeEntryStore{}
+		entries = newSourcedEntries(store)
+	})
+
+	When("an entry is removed by one of the sources holding it", func() {
+		It("should put back the version of another source", func() {
+			entries.put("nginx", "cluster1", "v1")
+			entries.put("nginx", "cluster2", "v2")
+			entries.remove("nginx", "cluster2", "v2")
+
+			Expect(store.ops).To(Equal([]string{"put v1", "put v2", "remove v2", "put v1"}))
+		})
+	})
+
+	When("an entry is removed by every source holding it", func() {
+		It("should remove it from the store", func() {
+			entries.put("nginx", "cluster1", "v1")
+			entries.put("nginx", "cluster2", "v2")
+			entries.remove("nginx", "cluster1", "v1")
+			entries.remove("nginx", "cluster2", "v2")
+
+			Expect(store.ops).To(Equal([]string{"put v1", "put v2", "remove v1", "put v2", "remove v2"}))
+			Expect(entries.entries).To(BeEmpty())
+		})
+	})
+
+	When("a source is dropped", func() {
+		BeforeEach(func() {
+			entries.put("nginx", "cluster1", "nginx v1")
+			entries.put("nginx", "cluster2", "nginx v2")
+			entries.put("httpd", "cluster1", "httpd v1")
+			store.ops = nil
+
+			entries.drop("cluster1")
+		})
+
+		It("should only remove the entries no other source holds", func() {
+			Expect(store.ops).To(ConsistOf("remove nginx v1", "put nginx v2", "remove httpd v1"))
+		})
+
+		It("should ignore the changes it still had queued", func() {
+			store.ops = nil
+
+			entries.put("httpd", "cluster1", "httpd v1")
+			entries.remove("nginx", "cluster1", "nginx v1")
+
+			Expect(store.ops).To(BeEmpty())
+		})
+	})
+
+	When("ServiceImports are shared between sources", func() {
+		It("should keep a service until no source has it", func() {
+			siMap := serviceimport.NewMap()
+			sources := newServiceImportSources(siMap)
+			si := newServiceImport(namespace1, service1, clusterID, serviceIP, portName1, portNumber1, protocol1, mcsv1a1.ClusterSetIP)
+
+			sources.from(localSource).Put(si)
+			sources.from("dns-tier/cluster1#1").Put(si)
+
+			sources.from(localSource).Remove(si)
+			Expect(siMap.Services(namespace1)).To(ConsistOf(service1))
+
+			sources.drop("dns-tier/cluster1#1")
+			Expect(siMap.Services(namespace1)).To(BeEmpty())
+		})
+	})
+})
+
+type fakeEntryStore struct {
+	ops []string
+}
+
+func (s *fakeEntryStore) put(obj interface{}) {
+	s.ops = append(s.ops, "put "+obj.(string))
+}
+
+func (s *fakeEntryStore) remove(obj interface{}) {
+	s.ops = append(s.ops, "remove "+obj.(string))
+}
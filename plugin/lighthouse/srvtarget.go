@@ -0,0 +1,129 @@
+/*
+SPDX-License-Identifier: Apache-2.0
+
+Copyright Contributors to the Submariner project.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package lighthouse
+
+import (
+	"context"
+	"strings"
+
+	"github.com/coredns/coredns/request"
+	"github.com/miekg/dns"
+)
+
+// upstreamResolver looks up names through the server's plugin chain, as CoreDNS's upstream package does.
+type upstreamResolver interface {
+	Lookup(ctx context.Context, state request.Request, name string, qtype uint16) (*dns.Msg, error)
+}
+
+// resolveSRVTargets looks up the addresses of the given SRV records' targets which the given additional records don't
+// resolve, e.g. targets outside the zone or which the plugin has no address for, and adds them to the additional
+// records. The SRV records whose targets still can't be resolved are dropped rather than answered dangling. The given
+// slices, which can be shared with concurrent queries, aren't modified.
+func (lh *Lighthouse) resolveSRVTargets(ctx context.Context, state request.Request, records,
+	extras []dns.RR) (resolvedRecords, resolvedExtras []dns.RR) {
+	resolved := map[string]bool{}
+
+	for _, rr := range extras {
+		if rrtype := rr.Header().Rrtype; rrtype == dns.TypeA || rrtype == dns.TypeAAAA {
+			resolved[strings.ToLower(rr.Header().Name)] = true
+		}
+	}
+
+	resolvedExtras = append(resolvedExtras, extras...)
+
+	for _, rr := range records {
+		srv, ok := rr.(*dns.SRV)
+		if !ok {
+			resolvedRecords = append(resolvedRecords, rr)
+			continue
+		}
+
+		target := strings.ToLower(srv.Target)
+
+		if _, known := resolved[target]; !known {
+			addresses := lh.lookupSRVTarget(ctx, state, srv.Target)
+			resolved[target] = len(addresses) > 0
+			resolvedExtras = append(resolvedExtras, addresses...)
+
+			srvTargetLookupsCount.WithLabelValues(resolutionResult(resolved[target])).Inc()
+		}
+
+		if !resolved[target] {
+			log.Debugf("Dropping the SRV record of %q for its unresolvable target %q", srv.Hdr.Name, srv.Target)
+			continue
+		}
+
+		resolvedRecords = append(resolvedRecords, rr)
+	}
+
+	return resolvedRecords, resolvedExtras
+}
+
+// lookupSRVTarget returns the A and AAAA records, only the latter in IPv6-only mode, of the given target, along with
+// the CNAMEs leading to them, resolved through the upstream. It returns no records if the target has no address.
+func (lh *Lighthouse) lookupSRVTarget(ctx context.Context, state request.Request, target string) []dns.RR {
+	var records []dns.RR
+
+	found := false
+	seen := map[string]bool{}
+
+	qtypes := []uint16{dns.TypeA, dns.TypeAAAA}
+	if lh.ipv6Only {
+		qtypes = qtypes[1:]
+	}
+
+	for _, qtype := range qtypes {
+		m, err := lh.upstream.Lookup(ctx, state, target, qtype)
+		if err != nil {
+			log.Debugf("Error looking up the %s records of SRV target %q: %v", dns.TypeToString[qtype], target, err)
+			continue
+		}
+
+		for _, rr := range m.Answer {
+			switch rr.Header().Rrtype {
+			case qtype:
+				found = true
+			case dns.TypeCNAME:
+				// The CNAMEs are answered to both lookups
+				if seen[rr.String()] {
+					continue
+				}
+
+				seen[rr.String()] = true
+			default:
+				continue
+			}
+
+			records = append(records, rr)
+		}
+	}
+
+	if !found {
+		return nil
+	}
+
+	return records
+}
+
+func resolutionResult(resolved bool) string {
+	if resolved {
+		return "resolved"
+	}
+
+	return "unresolved"
+}
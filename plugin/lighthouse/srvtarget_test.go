@@ -0,0 +1,143 @@
+/*
+SPDX-License-Identifier: Apache-2.0
+
+Copyright Contributors to the Submariner project.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package lighthouse
+
+import (
+	"context"
+	"errors"
+
+	"github.com/coredns/coredns/request"
+	"github.com/miekg/dns"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("SRV target resolution", func() {
+	const (
+		qname    = "_http._tcp.service1.namespace1.svc.clusterset.local."
+		local    = "pod1.cluster1.service1.namespace1.svc.clusterset.local."
+		external = "db.example.com."
+		missing  = "missing.example.com."
+	)
+
+	var (
+		lh       *Lighthouse
+		upstream *fakeUpstream
+		state    request.Request
+		localA   dns.RR
+	)
+
+	BeforeEach(func() {
+		upstream = &fakeUpstream{records: map[string][]dns.RR{
+			external: {
+				&dns.CNAME{Hdr: dns.RR_Header{Name: external, Rrtype: dns.TypeCNAME, Class: dns.ClassINET}, Target: "host.example.com."},
+				aRecord("host.example.com.", "192.0.2.10"),
+			},
+		}}
+		lh = &Lighthouse{upstream: upstream}
+
+		m := new(dns.Msg)
+		m.SetQuestion(qname, dns.TypeSRV)
+		state = request.Request{Req: m}
+		localA = aRecord(local, "100.96.1.1")
+	})
+
+	resolve := func(targets ...string) (records, extras []dns.RR) {
+		for _, target := range targets {
+			records = append(records, &dns.SRV{Hdr: dns.RR_Header{Name: qname, Rrtype: dns.TypeSRV, Class: dns.ClassINET},
+				Port: 80, Target: target})
+		}
+
+		return lh.resolveSRVTargets(context.TODO(), state, records, []dns.RR{localA})
+	}
+
+	It("should not look up the targets which the additional records resolve", func() {
+		records, extras := resolve(local)
+		Expect(records).To(HaveLen(1))
+		Expect(extras).To(Equal([]dns.RR{localA}))
+		Expect(upstream.lookups).To(BeEmpty())
+	})
+
+	It("should add the addresses of the other targets, with their CNAMEs, to the additional records", func() {
+		records, extras := resolve(local, external)
+		Expect(records).To(HaveLen(2))
+		Expect(extras).To(HaveLen(3))
+		Expect(extras[1].Header().Rrtype).To(Equal(dns.TypeCNAME))
+		Expect(extras[2].(*dns.A).A.String()).To(Equal("192.0.2.10"))
+	})
+
+	It("should drop the SRV records whose targets can't be resolved", func() {
+		records, extras := resolve(local, missing)
+		Expect(records).To(HaveLen(1))
+		Expect(records[0].(*dns.SRV).Target).To(Equal(local))
+		Expect(extras).To(Equal([]dns.RR{localA}))
+	})
+
+	It("should look up each target once", func() {
+		records, _ := resolve(external, external)
+		Expect(records).To(HaveLen(2))
+		Expect(upstream.lookups).To(Equal([]string{external}))
+	})
+
+	It("should not modify the given records", func() {
+		records := []dns.RR{&dns.SRV{Hdr: dns.RR_Header{Name: qname, Rrtype: dns.TypeSRV}, Target: missing}}
+		extras := make([]dns.RR, 1, 2)
+		extras[0] = localA
+
+		resolvedRecords, resolvedExtras := lh.resolveSRVTargets(context.TODO(), state, records, extras)
+		Expect(resolvedRecords).To(BeEmpty())
+		Expect(resolvedExtras).To(HaveLen(1))
+		Expect(records).To(HaveLen(1))
+		Expect(extras[:2][1]).To(BeNil())
+	})
+})
+
+// fakeUpstream answers the A lookups of the names it has records for, recording the names looked up.
+type fakeUpstream struct {
+	records map[string][]dns.RR
+	lookups []string
+}
+
+func (u *fakeUpstream) Lookup(ctx context.Context, state request.Request, name string, qtype uint16) (*dns.Msg, error) {
+	if qtype == dns.TypeA {
+		u.lookups = append(u.lookups, name)
+	}
+
+	records, ok := u.records[name]
+	if !ok {
+		return nil, errors.New("no such name")
+	}
+
+	m := new(dns.Msg)
+	m.SetQuestion(name, qtype)
+
+	for _, rr := range records {
+		if rrtype := rr.Header().Rrtype; rrtype == qtype || rrtype == dns.TypeCNAME {
+			m.Answer = append(m.Answer, rr)
+		}
+	}
+
+	return m, nil
+}
+
+func aRecord(name, ip string) dns.RR {
+	rr, err := dns.NewRR(name + " 5 IN A " + ip)
+	Expect(err).To(Succeed())
+
+	return rr
+}
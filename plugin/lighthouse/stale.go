@@ -0,0 +1,80 @@
+/*
+SPDX-License-Identifier: Apache-2.0
+
+Copyright Contributors to the Submariner project.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package lighthouse
+
+import (
+	"time"
+)
+
+// The defaults follow RFC 8767's recommendations for serving stale data.
+const (
+	defaultStaleWindow = 24 * time.Hour
+	defaultStaleTTL    = uint32(30)
+)
+
+// serveStale answers from the last known ServiceImports and EndpointSlices while they can't be refreshed from the API
+// server, as RFC 8767 describes for caching resolvers: answers are given with a longer TTL, so that clients don't keep
+// querying for records which can't change, until the data has been stale for longer than the window.
+type serveStale struct {
+	window time.Duration
+	ttl    uint32
+	// sources report since when each of the informers feeding the maps has been unable to reach the API server
+	sources []func() (time.Time, bool)
+}
+
+// staleFor returns the longest time any source has been disconnected for, and whether any is.
+func (s *serveStale) staleFor() (time.Duration, bool) {
+	var longest time.Duration
+
+	stale := false
+
+	for _, source := range s.sources {
+		if since, disconnected := source(); disconnected {
+			stale = true
+
+			if d := time.Since(since); d > longest {
+				longest = d
+			}
+		}
+	}
+
+	return longest, stale
+}
+
+// staleTTL returns the TTL to answer with, and true, if the maps are stale but still within the window.
+func (lh *Lighthouse) staleTTL() (uint32, bool) {
+	if lh.stale == nil {
+		return 0, false
+	}
+
+	d, stale := lh.stale.staleFor()
+
+	return lh.stale.ttl, stale && d <= lh.stale.window
+}
+
+// staleExpired returns true if the maps have been stale for longer than the window, in which case they mustn't be
+// answered from anymore.
+func (lh *Lighthouse) staleExpired() bool {
+	if lh.stale == nil {
+		return false
+	}
+
+	d, stale := lh.stale.staleFor()
+
+	return stale && d > lh.stale.window
+}
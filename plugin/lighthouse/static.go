@@ -0,0 +1,77 @@
+/*
+SPDX-License-Identifier: Apache-2.0
+
+Copyright Contributors to the Submariner project.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package lighthouse
+
+import (
+	"github.com/submariner-io/lighthouse/pkg/endpointslice"
+	"github.com/submariner-io/lighthouse/pkg/serviceimport"
+	discovery "k8s.io/api/discovery/v1beta1"
+	mcsv1a1 "sigs.k8s.io/mcs-api/pkg/apis/v1alpha1"
+)
+
+// NewStatic returns a handler answering for the given zones from a fixed set of ServiceImports and EndpointSlices,
+// with every cluster connected and every service healthy. It serves queries without a Kubernetes cluster, e.g. to
+// generate load against large sets of services.
+func NewStatic(zones []string, serviceImports []*mcsv1a1.ServiceImport, endpointSlices []*discovery.EndpointSlice) *Lighthouse {
+	siMap := serviceimport.NewMap()
+	for _, si := range serviceImports {
+		siMap.Put(si)
+	}
+
+	esMap := endpointslice.NewMap()
+	for _, es := range endpointSlices {
+		esMap.Put(es)
+	}
+
+	return NewWithStatus(zones, siMap, esMap, staticStatus{}, staticStatus{}, staticStatus{})
+}
+
+// NewWithStatus returns a handler answering for the given zones from the given maps, with the cluster connectivity,
+// service health and local services reported by the given implementations. The maps and the status can change while
+// it serves queries, e.g. to simulate clusters without Kubernetes.
+func NewWithStatus(zones []string, serviceImports *serviceimport.Map, endpointSlices *endpointslice.Map,
+	clusterStatus ClusterStatus, endpointsStatus EndpointsStatus, localServices LocalServices) *Lighthouse {
+	return &Lighthouse{
+		Zones:           zones,
+		ttl:             defaultTTL,
+		serviceImports:  serviceImports,
+		endpointSlices:  endpointSlices,
+		clusterStatus:   clusterStatus,
+		endpointsStatus: endpointsStatus,
+		localServices:   localServices,
+	}
+}
+
+// staticStatus reports every cluster as connected and every service as healthy, and has no local services.
+type staticStatus struct{}
+
+func (staticStatus) IsConnected(clusterID string) bool {
+	return true
+}
+
+func (staticStatus) LocalClusterID() string {
+	return ""
+}
+
+func (staticStatus) HealthScore(name, namespace, clusterID string) float64 {
+	return 1
+}
+
+func (staticStatus) GetIP(name, namespace string) (*serviceimport.DNSRecord, bool) {
+	return nil, false
+}
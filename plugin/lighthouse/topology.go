@@ -0,0 +1,82 @@
+/*
+SPDX-License-Identifier: Apache-2.0
+
+Copyright Contributors to the Submariner project.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package lighthouse
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/submariner-io/lighthouse/pkg/serviceimport"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/rest"
+)
+
+// topologyPreference restricts the answers for headless services to the endpoints closest to this CoreDNS instance:
+// those in its zone if there are any, otherwise those in its region, otherwise all of them.
+type topologyPreference struct {
+	zone   string
+	region string
+}
+
+func (t *topologyPreference) filter(records []serviceimport.DNSRecord) []serviceimport.DNSRecord {
+	if t == nil {
+		return records
+	}
+
+	var sameZone, sameRegion []serviceimport.DNSRecord
+
+	for i := range records {
+		switch {
+		case t.zone != "" && records[i].Zone == t.zone:
+			sameZone = append(sameZone, records[i])
+		case t.region != "" && records[i].Region == t.region:
+			sameRegion = append(sameRegion, records[i])
+		}
+	}
+
+	if len(sameZone) > 0 {
+		return sameZone
+	}
+
+	if len(sameRegion) > 0 {
+		return sameRegion
+	}
+
+	return records
+}
+
+// nodeTopology returns the zone and region labels of the given node.
+func nodeTopology(cfg *rest.Config, nodeName string) (zone, region string, err error) {
+	client, err := dynamic.NewForConfig(cfg)
+	if err != nil {
+		return "", "", fmt.Errorf("error creating the node client: %v", err)
+	}
+
+	node, err := client.Resource(schema.GroupVersionResource{Version: "v1", Resource: "nodes"}).Get(context.TODO(), nodeName,
+		metav1.GetOptions{})
+	if err != nil {
+		return "", "", fmt.Errorf("error retrieving node %q: %v", nodeName, err)
+	}
+
+	labels := node.GetLabels()
+
+	return labels[corev1.LabelZoneFailureDomainStable], labels[corev1.LabelZoneRegionStable], nil
+}
@@ -0,0 +1,50 @@
+/*
+SPDX-License-Identifier: Apache-2.0
+
+Copyright Contributors to the Submariner project.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package lighthouse
+
+import (
+	"context"
+	"strings"
+
+	"github.com/miekg/dns"
+	ot "github.com/opentracing/opentracing-go"
+)
+
+const (
+	tagName     = "lighthouse.submariner.io/name"
+	tagType     = "lighthouse.submariner.io/type"
+	tagClusters = "lighthouse.submariner.io/clusters"
+	tagAnswers  = "lighthouse.submariner.io/answers"
+)
+
+// When the trace plugin is enabled, plugin.NextOrFailure starts a span for each plugin it calls and passes it in the
+// context; these helpers annotate the span for the current ServeDNS invocation, and do nothing when tracing is off.
+
+func traceQuery(ctx context.Context, qname string, qtype uint16) {
+	if span := ot.SpanFromContext(ctx); span != nil {
+		span.SetTag(tagName, qname)
+		span.SetTag(tagType, dns.Type(qtype).String())
+	}
+}
+
+func traceAnswer(ctx context.Context, clusters []string, answers int) {
+	if span := ot.SpanFromContext(ctx); span != nil {
+		span.SetTag(tagClusters, strings.Join(clusters, ","))
+		span.SetTag(tagAnswers, answers)
+	}
+}
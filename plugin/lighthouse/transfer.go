@@ -0,0 +1,369 @@
+/*
+SPDX-License-Identifier: Apache-2.0
+
+Copyright Contributors to the Submariner project.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package lighthouse
+
+import (
+	"net"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/coredns/coredns/request"
+	"github.com/miekg/dns"
+	"github.com/submariner-io/lighthouse/pkg/serviceimport"
+)
+
+const (
+	// transferEnvelopeSize is the number of records sent in each message of a zone transfer
+	transferEnvelopeSize = 100
+	// transferHistorySize is the number of transferred versions of the zones kept to answer IXFR queries with
+	// differences
+	transferHistorySize = 8
+	// transferFudge is the time difference, in seconds, allowed between the TSIG signatures and the clock
+	transferFudge = 300
+)
+
+// zoneSerial is the serial of the zones' SOA record. It starts from the time the plugin starts, and is bumped whenever
+// a ServiceImport or EndpointSlice changes, so that secondary servers can tell when to transfer the zones again.
+type zoneSerial struct {
+	value uint32
+}
+
+func newZoneSerial() *zoneSerial {
+	return &zoneSerial{value: uint32(time.Now().Unix())}
+}
+
+// get returns the current serial; without a serial, the current time is used.
+func (s *zoneSerial) get() uint32 {
+	if s == nil {
+		return uint32(time.Now().Unix())
+	}
+
+	return atomic.LoadUint32(&s.value)
+}
+
+func (s *zoneSerial) bump() {
+	if s != nil {
+		atomic.AddUint32(&s.value, 1)
+	}
+}
+
+// zoneTransfer answers AXFR and IXFR queries signed with its TSIG key, from the allowed networks. It keeps the
+// versions of the zones last transferred so that IXFR queries from these versions are answered with the differences.
+type zoneTransfer struct {
+	keyName  string
+	secret   string
+	networks []*net.IPNet
+
+	mutex   sync.Mutex
+	history []zoneVersion
+}
+
+type zoneVersion struct {
+	zone    string
+	serial  uint32
+	records []dns.RR
+}
+
+func (t *zoneTransfer) allowed(ip string) bool {
+	if len(t.networks) == 0 {
+		return true
+	}
+
+	address := net.ParseIP(ip)
+
+	for _, network := range t.networks {
+		if address != nil && network.Contains(address) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// verify checks that the query is signed with the transfer's key.
+func (t *zoneTransfer) verify(r *dns.Msg) (*dns.TSIG, error) {
+	tsig := r.IsTsig()
+	if tsig == nil {
+		return nil, dns.ErrSig
+	}
+
+	if !strings.EqualFold(tsig.Hdr.Name, t.keyName) {
+		return nil, dns.ErrSecret
+	}
+
+	// The query is packed again, as plugins don't get the wire format; the TSIG record is never compressed and a
+	// transfer query has nothing else to compress, so this gives back the signed bytes
+	buf, err := r.Pack()
+	if err != nil {
+		return nil, err
+	}
+
+	return tsig, dns.TsigVerify(buf, t.secret, "", false)
+}
+
+func (t *zoneTransfer) version(zone string, serial uint32) ([]dns.RR, bool) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	for i := range t.history {
+		if t.history[i].zone == zone && t.history[i].serial == serial {
+			return t.history[i].records, true
+		}
+	}
+
+	return nil, false
+}
+
+func (t *zoneTransfer) transferred(zone string, serial uint32, records []dns.RR) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	for i := range t.history {
+		if t.history[i].zone == zone && t.history[i].serial == serial {
+			return
+		}
+	}
+
+	t.history = append(t.history, zoneVersion{zone: zone, serial: serial, records: records})
+	if len(t.history) > transferHistorySize {
+		t.history = t.history[len(t.history)-transferHistorySize:]
+	}
+}
+
+// transferResponse answers an AXFR or IXFR query for the zone. IXFR queries from a version of the zone transferred
+// before are answered with the differences, from the current version with its SOA record only, and otherwise with the
+// whole zone, as RFC 1995 allows.
+func (lh *Lighthouse) transferResponse(state request.Request) (int, error) {
+	if !lh.transfer.allowed(state.IP()) {
+		log.Debugf("Refusing the transfer of %q to %s, which isn't allowed", state.Zone, state.IP())
+		return dns.RcodeRefused, lh.error("transfer not allowed")
+	}
+
+	tsig, err := lh.transfer.verify(state.Req)
+	if err != nil {
+		log.Debugf("Refusing the transfer of %q to %s: %v", state.Zone, state.IP(), err)
+		return dns.RcodeRefused, lh.error("transfer not authenticated")
+	}
+
+	serial := lh.serial.get()
+	soa := lh.soa(state.Zone).(*dns.SOA)
+	soa.Serial = serial
+
+	var answer []dns.RR
+
+	if state.QType() == dns.TypeIXFR {
+		clientSerial, ok := ixfrSerial(state.Req)
+		if !ok {
+			return dns.RcodeFormatError, lh.error("IXFR query without an SOA record")
+		}
+
+		// Over UDP, the client is told to retry over TCP with the SOA record alone
+		if clientSerial == serial || state.Proto() == "udp" {
+			return lh.writeTransfer(state, tsig, []dns.RR{soa})
+		}
+
+		if previous, found := lh.transfer.version(state.Zone, clientSerial); found {
+			records := lh.zoneRecords(state.Zone)
+			lh.transfer.transferred(state.Zone, serial, records)
+
+			previousSOA := dns.Copy(soa).(*dns.SOA)
+			previousSOA.Serial = clientSerial
+
+			deleted, added := zoneDifferences(previous, records)
+
+			answer = append(append(append([]dns.RR{soa, previousSOA}, deleted...), soa), added...)
+			transfersCount.WithLabelValues("ixfr").Inc()
+
+			return lh.writeTransfer(state, tsig, append(answer, soa))
+		}
+	} else if state.Proto() == "udp" {
+		return dns.RcodeRefused, lh.error("AXFR over UDP")
+	}
+
+	records := lh.zoneRecords(state.Zone)
+	lh.transfer.transferred(state.Zone, serial, records)
+
+	answer = append(append([]dns.RR{soa}, records...), soa)
+	transfersCount.WithLabelValues("axfr").Inc()
+
+	return lh.writeTransfer(state, tsig, answer)
+}
+
+// writeTransfer writes the given records in as many messages as needed, each signed with the transfer's key as RFC
+// 8945 specifies for multi-message responses.
+func (lh *Lighthouse) writeTransfer(state request.Request, tsig *dns.TSIG, records []dns.RR) (int, error) {
+	requestMAC := tsig.MAC
+
+	for i := 0; i < len(records); i += transferEnvelopeSize {
+		end := i + transferEnvelopeSize
+		if end > len(records) {
+			end = len(records)
+		}
+
+		m := newResponse(state.Req)
+		m.Answer = records[i:end]
+		m.SetTsig(tsig.Hdr.Name, tsig.Algorithm, transferFudge, time.Now().Unix())
+
+		buf, mac, err := dns.TsigGenerate(m, lh.transfer.secret, requestMAC, i > 0)
+		if err != nil {
+			log.Errorf("Failed to sign the transfer of %q: %v", state.Zone, err)
+			return dns.RcodeServerFailure, lh.error("failed to sign transfer")
+		}
+
+		if _, err := state.W.Write(buf); err != nil {
+			log.Errorf("Failed to write the transfer of %q: %v", state.Zone, err)
+			return dns.RcodeServerFailure, lh.error("failed to write response")
+		}
+
+		requestMAC = mac
+	}
+
+	return dns.RcodeSuccess, nil
+}
+
+// zoneRecords returns the records of every service in the zone, sorted: for each service, the A or AAAA records and
+// SRV records of all its clusters, as well as those of each cluster, and for headless services, those of each endpoint.
+func (lh *Lighthouse) zoneRecords(zone string) []dns.RR {
+	key := serviceimport.RRKey{Qclass: dns.ClassINET}
+	srvRecordTTL := lh.getRecordTTL(srvTTL)
+	seen := map[string]bool{}
+	records := []dns.RR{}
+
+	add := func(rrs ...dns.RR) {
+		for _, rr := range rrs {
+			if s := rr.String(); !seen[s] {
+				seen[s] = true
+				records = append(records, rr)
+			}
+		}
+	}
+
+	services := lh.serviceImports.Dump()
+	services = append(services, lh.endpointSlices.Dump()...)
+
+	for i := range services {
+		service := &services[i]
+		if lh.isExcludedNamespace(service.Namespace) {
+			continue
+		}
+
+		name := service.Name + "." + service.Namespace + ".svc." + zone
+		key.TTL = lh.getAddressTTL(service.Headless)
+
+		for j := range service.Records {
+			record := &service.Records[j]
+
+			target := record.ClusterName + "." + name
+			if service.Headless {
+				target = record.HostName + "." + target
+			}
+
+			for _, owner := range []string{name, record.ClusterName + "." + name, target} {
+				key.Name = owner
+				add(buildAddressRecords(record.Addresses(), key, lh.additionalAddressType())...)
+			}
+
+			if !service.Headless {
+				target = name
+			}
+
+			for _, port := range record.Ports {
+				srv := &dns.SRV{Hdr: dns.RR_Header{Name: name, Rrtype: dns.TypeSRV, Class: dns.ClassINET, Ttl: srvRecordTTL},
+					Priority: lh.srvPriority(record.ClusterName), Weight: 50, Port: uint16(port.Port), Target: target}
+				named := dns.Copy(srv)
+				named.Header().Name = "_" + strings.ToLower(port.Name) + "._" + strings.ToLower(string(port.Protocol)) + "." + name
+
+				add(srv, named)
+			}
+		}
+	}
+
+	sort.Slice(records, func(i, j int) bool {
+		return records[i].String() < records[j].String()
+	})
+
+	return records
+}
+
+// zoneDifferences returns the records of the previous version of a zone which aren't in the current one, and those of
+// the current version which weren't in the previous one.
+func zoneDifferences(previous, current []dns.RR) (deleted, added []dns.RR) {
+	inPrevious := make(map[string]bool, len(previous))
+	for _, rr := range previous {
+		inPrevious[rr.String()] = true
+	}
+
+	inCurrent := make(map[string]bool, len(current))
+
+	for _, rr := range current {
+		inCurrent[rr.String()] = true
+
+		if !inPrevious[rr.String()] {
+			added = append(added, rr)
+		}
+	}
+
+	for _, rr := range previous {
+		if !inCurrent[rr.String()] {
+			deleted = append(deleted, rr)
+		}
+	}
+
+	return deleted, added
+}
+
+// ixfrSerial returns the serial of the client's version of the zone, given in the authority section of IXFR queries.
+func ixfrSerial(r *dns.Msg) (uint32, bool) {
+	for _, rr := range r.Ns {
+		if soa, ok := rr.(*dns.SOA); ok {
+			return soa.Serial, true
+		}
+	}
+
+	return 0, false
+}
+
+// parseNetworks parses the given CIDRs or single addresses.
+func parseNetworks(args []string) ([]*net.IPNet, error) {
+	networks := make([]*net.IPNet, 0, len(args))
+
+	for _, arg := range args {
+		// A single address is allowed on its own
+		if ip := net.ParseIP(arg); ip != nil {
+			bits := net.IPv6len * 8
+			if ip.To4() != nil {
+				bits = net.IPv4len * 8
+			}
+
+			arg += "/" + strconv.Itoa(bits)
+		}
+
+		_, network, err := net.ParseCIDR(arg)
+		if err != nil {
+			return nil, err
+		}
+
+		networks = append(networks, network)
+	}
+
+	return networks, nil
+}
@@ -0,0 +1,281 @@
+/*
+SPDX-License-Identifier: Apache-2.0
+
+Copyright Contributors to the Submariner project.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package lighthouse
+
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+	"net"
+	"time"
+
+	"github.com/coredns/coredns/plugin/test"
+	"github.com/miekg/dns"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/submariner-io/lighthouse/pkg/endpointslice"
+	mcsv1a1 "sigs.k8s.io/mcs-api/pkg/apis/v1alpha1"
+)
+
+var _ = Describe("Zone transfers", func() {
+	const (
+		zone    = "clusterset.local."
+		keyName = "transfer."
+	)
+
+	var (
+		lh       *Lighthouse
+		secret   string
+		writer   *transferWriter
+		qname    string
+		aRecord1 string
+	)
+
+	BeforeEach(func() {
+		secret = base64.StdEncoding.EncodeToString([]byte("0123456789abcdef0123456789abcdef"))
+		qname = fmt.Sprintf("%s.%s.svc.%s", service1, namespace1, zone)
+		aRecord1 = fmt.Sprintf("%s\t5\tIN\tA\t%s", qname, serviceIP)
+
+		mockCs := NewMockClusterStatus()
+		mockCs.clusterStatusMap[clusterID] = true
+		mockEs := NewMockEndpointStatus()
+		mockEs.endpointStatusMap[clusterID] = true
+
+		lh = &Lighthouse{
+			Zones:           []string{zone},
+			serviceImports:  setupServiceImportMap(),
+			endpointSlices:  endpointslice.NewMap(),
+			clusterStatus:   mockCs,
+			endpointsStatus: mockEs,
+			localServices:   NewMockLocalServices(),
+			ttl:             defaultTTL,
+			serial:          newZoneSerial(),
+			transfer:        &zoneTransfer{keyName: keyName, secret: secret},
+		}
+
+		writer = &transferWriter{tcp: true, remote: net.ParseIP("10.0.0.1")}
+	})
+
+	signedQuery := func(qtype uint16, serial uint32, key string) *dns.Msg {
+		m := new(dns.Msg)
+		m.SetQuestion(zone, qtype)
+
+		if qtype == dns.TypeIXFR {
+			m.Ns = []dns.RR{&dns.SOA{Hdr: dns.RR_Header{Name: zone, Rrtype: dns.TypeSOA, Class: dns.ClassINET}, Ns: zone,
+				Mbox: zone, Serial: serial}}
+		}
+
+		m.SetTsig(keyName, dns.HmacSHA256, transferFudge, time.Now().Unix())
+
+		buf, _, err := dns.TsigGenerate(m, key, "", false)
+		Expect(err).To(Succeed())
+
+		// The server unpacks the signed query before passing it to the plugins
+		r := new(dns.Msg)
+		Expect(r.Unpack(buf)).To(Succeed())
+
+		return r
+	}
+
+	transfer := func(r *dns.Msg) []dns.RR {
+		writer.messages = nil
+
+		code, err := lh.ServeDNS(context.TODO(), writer, r)
+		Expect(err).To(Succeed())
+		Expect(code).To(Equal(dns.RcodeSuccess))
+
+		return writer.verifiedAnswer(r.IsTsig().MAC, secret)
+	}
+
+	expectRefused := func(r *dns.Msg) {
+		code, err := lh.ServeDNS(context.TODO(), writer, r)
+		Expect(err).To(HaveOccurred())
+		Expect(code).To(Equal(dns.RcodeRefused))
+		Expect(writer.messages).To(BeEmpty())
+	}
+
+	serialOf := func(rr dns.RR) uint32 {
+		soa, ok := rr.(*dns.SOA)
+		Expect(ok).To(BeTrue())
+
+		return soa.Serial
+	}
+
+	When("a signed AXFR query is received", func() {
+		It("should answer with every record of the zone between its SOA records, in signed messages", func() {
+			answer := transfer(signedQuery(dns.TypeAXFR, 0, secret))
+
+			Expect(len(answer)).To(BeNumerically(">", 2))
+			Expect(serialOf(answer[0])).To(Equal(lh.serial.get()))
+			Expect(serialOf(answer[len(answer)-1])).To(Equal(lh.serial.get()))
+			Expect(rrStrings(answer)).To(ContainElements(aRecord1,
+				fmt.Sprintf("%s.%s\t5\tIN\tA\t%s", clusterID, qname, serviceIP),
+				fmt.Sprintf("_%s._%s.%s\t5\tIN\tSRV\t0 50 %d %s", portName1, "tcp", qname, portNumber1, qname)))
+		})
+	})
+
+	When("the zone holds more records than fit in a message", func() {
+		BeforeEach(func() {
+			for i := 0; i < transferEnvelopeSize; i++ {
+				lh.serviceImports.Put(newServiceImport(namespace1, fmt.Sprintf("service-%d", i), clusterID,
+					fmt.Sprintf("100.96.%d.%d", i/256, i%256), portName1, portNumber1, protocol1, mcsv1a1.ClusterSetIP))
+			}
+		})
+
+		It("should answer in several messages, each signed", func() {
+			answer := transfer(signedQuery(dns.TypeAXFR, 0, secret))
+
+			Expect(len(writer.messages)).To(BeNumerically(">", 1))
+			Expect(rrStrings(answer)).To(ContainElement(aRecord1))
+		})
+	})
+
+	When("an AXFR query isn't signed", func() {
+		It("should refuse it", func() {
+			m := new(dns.Msg)
+			m.SetQuestion(zone, dns.TypeAXFR)
+			expectRefused(m)
+		})
+	})
+
+	When("an AXFR query is signed with another key", func() {
+		It("should refuse it", func() {
+			expectRefused(signedQuery(dns.TypeAXFR, 0, base64.StdEncoding.EncodeToString([]byte("another secret"))))
+		})
+	})
+
+	When("an AXFR query comes from a network which isn't allowed", func() {
+		BeforeEach(func() {
+			var err error
+			lh.transfer.networks, err = parseNetworks([]string{"192.168.0.0/16", "10.0.0.2"})
+			Expect(err).To(Succeed())
+		})
+
+		It("should refuse it", func() {
+			expectRefused(signedQuery(dns.TypeAXFR, 0, secret))
+		})
+	})
+
+	When("an AXFR query is received over UDP", func() {
+		It("should refuse it", func() {
+			writer.tcp = false
+			expectRefused(signedQuery(dns.TypeAXFR, 0, secret))
+		})
+	})
+
+	When("an IXFR query is received from the current version of the zone", func() {
+		It("should answer with the SOA record only", func() {
+			answer := transfer(signedQuery(dns.TypeIXFR, lh.serial.get(), secret))
+			Expect(answer).To(HaveLen(1))
+			Expect(serialOf(answer[0])).To(Equal(lh.serial.get()))
+		})
+	})
+
+	When("an IXFR query is received from a version of the zone transferred before", func() {
+		It("should answer with the differences", func() {
+			previous := lh.serial.get()
+			transfer(signedQuery(dns.TypeAXFR, 0, secret))
+
+			lh.serviceImports.Put(newServiceImport(namespace1, service1, clusterID2, serviceIP2, portName1, portNumber1,
+				protocol1, mcsv1a1.ClusterSetIP))
+			lh.serial.bump()
+
+			answer := transfer(signedQuery(dns.TypeIXFR, previous, secret))
+			Expect(serialOf(answer[0])).To(Equal(previous + 1))
+			Expect(serialOf(answer[1])).To(Equal(previous))
+			Expect(serialOf(answer[2])).To(Equal(previous + 1))
+			Expect(serialOf(answer[len(answer)-1])).To(Equal(previous + 1))
+			Expect(rrStrings(answer[3 : len(answer)-1])).To(ConsistOf(
+				fmt.Sprintf("%s\t5\tIN\tA\t%s", qname, serviceIP2),
+				fmt.Sprintf("%s.%s\t5\tIN\tA\t%s", clusterID2, qname, serviceIP2)))
+		})
+	})
+
+	When("an IXFR query is received from an unknown version of the zone", func() {
+		It("should answer with the whole zone", func() {
+			answer := transfer(signedQuery(dns.TypeIXFR, lh.serial.get()-10, secret))
+			Expect(serialOf(answer[0])).To(Equal(lh.serial.get()))
+			Expect(rrStrings(answer)).To(ContainElement(aRecord1))
+		})
+	})
+})
+
+// transferWriter records the messages written as they're sent, over TCP or UDP, from the given remote address.
+type transferWriter struct {
+	test.ResponseWriter
+	tcp      bool
+	remote   net.IP
+	messages [][]byte
+}
+
+func (w *transferWriter) verifiedAnswer(requestMAC, secret string) []dns.RR {
+	var answer []dns.RR
+
+	for i, buf := range w.messages {
+		Expect(dns.TsigVerify(buf, secret, requestMAC, i > 0)).To(Succeed())
+
+		m := new(dns.Msg)
+		Expect(m.Unpack(buf)).To(Succeed())
+		answer = append(answer, m.Answer...)
+
+		requestMAC = m.IsTsig().MAC
+	}
+
+	return answer
+}
+
+func (w *transferWriter) LocalAddr() net.Addr {
+	if w.tcp {
+		return &net.TCPAddr{IP: net.ParseIP("127.0.0.1"), Port: 53}
+	}
+
+	return &net.UDPAddr{IP: net.ParseIP("127.0.0.1"), Port: 53}
+}
+
+func (w *transferWriter) RemoteAddr() net.Addr {
+	if w.tcp {
+		return &net.TCPAddr{IP: w.remote, Port: 40212}
+	}
+
+	return &net.UDPAddr{IP: w.remote, Port: 40212}
+}
+
+func (w *transferWriter) WriteMsg(m *dns.Msg) error {
+	buf, err := m.Pack()
+	if err != nil {
+		return err
+	}
+
+	_, err = w.Write(buf)
+
+	return err
+}
+
+func (w *transferWriter) Write(buf []byte) (int, error) {
+	w.messages = append(w.messages, buf)
+	return len(buf), nil
+}
+
+func rrStrings(records []dns.RR) []string {
+	result := make([]string, len(records))
+	for i := range records {
+		result[i] = records[i].String()
+	}
+
+	return result
+}
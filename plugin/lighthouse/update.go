@@ -0,0 +1,43 @@
+/*
+SPDX-License-Identifier: Apache-2.0
+
+Copyright Contributors to the Submariner project.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package lighthouse
+
+import (
+	"github.com/coredns/coredns/request"
+	"github.com/miekg/dns"
+)
+
+// refuseUpdate refuses a dynamic update for the zone, signed or not: its records are synthesized from the
+// ServiceImports and EndpointSlices, so they can't be updated. The update is logged with its source, since clients
+// probing with updates are worth knowing about.
+func (lh *Lighthouse) refuseUpdate(state request.Request) (int, error) {
+	signed := "false"
+
+	if tsig := state.Req.IsTsig(); tsig != nil {
+		signed = "true"
+
+		log.Infof("Refusing the dynamic update of %q from %s, signed with TSIG key %q", state.Name(), state.IP(),
+			tsig.Hdr.Name)
+	} else {
+		log.Infof("Refusing the dynamic update of %q from %s", state.Name(), state.IP())
+	}
+
+	refusedUpdatesCount.WithLabelValues(signed).Inc()
+
+	return dns.RcodeRefused, lh.error("dynamic updates not supported")
+}
@@ -0,0 +1,120 @@
+/*
+SPDX-License-Identifier: Apache-2.0
+
+Copyright Contributors to the Submariner project.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package lighthouse
+
+import (
+	"context"
+	"sort"
+
+	"github.com/coredns/coredns/plugin/pkg/dnsutil"
+	"github.com/coredns/coredns/request"
+	"github.com/miekg/dns"
+	"github.com/submariner-io/lighthouse/pkg/serviceimport"
+)
+
+// defaultWildcardLimit is the number of services answered for a wildcard query unless configured otherwise.
+const defaultWildcardLimit = 100
+
+// isWildcard checks whether the request is for all the services in a namespace, i.e. *.namespace.svc.zone.
+func isWildcard(pReq recordRequest) bool {
+	return pReq.service == "*" && pReq.cluster == "" && pReq.hostname == "" && pReq.port == ""
+}
+
+// wildcardResponse answers a query for all the services in a namespace with the records of each service, owned by
+// the service's own name, as the kubernetes plugin does. Only the first wildcardLimit services, sorted by name, are
+// answered; if the response doesn't fit the client's UDP buffer size, it's truncated like any other response so that
+// the client retries over TCP.
+func (lh *Lighthouse) wildcardResponse(ctx context.Context, zone string, state request.Request, pReq recordRequest) (int, error) {
+	services := lh.namespaceServices(pReq.namespace)
+	if len(services) == 0 {
+		log.Debugf("No services found for the wildcard query %q", state.QName())
+		return lh.nextOrFailure(state.Name(), ctx, state.W, state.Req, dns.RcodeNameError, "record not found")
+	}
+
+	if len(services) > lh.wildcardLimit {
+		log.Debugf("Answering the wildcard query for %q with the first %d of %d services", state.QName(), lh.wildcardLimit,
+			len(services))
+		services = services[:lh.wildcardLimit]
+	}
+
+	a := newResponse(state.Req)
+
+	var dnsRecords []serviceimport.DNSRecord
+
+	for _, service := range services {
+		svcReq := pReq
+		svcReq.service = service
+
+		req := state.Req.Copy()
+		req.Question[0].Name = dnsutil.Join(service, pReq.namespace, Svc, zone)
+		svcState := request.Request{W: state.W, Req: req, Zone: zone}
+
+		answers := lh.assembleAnswers(zone, svcState, svcReq)
+		if !answers.found {
+			continue
+		}
+
+		a.Answer = append(a.Answer, answers.records...)
+		a.Extra = append(a.Extra, answers.extras...)
+		dnsRecords = append(dnsRecords, answers.dnsRecords...)
+	}
+
+	if len(a.Answer) == 0 {
+		log.Debugf("No records of type %d found for the wildcard query %q", state.QType(), state.QName())
+		return lh.noData(ctx, state)
+	}
+
+	lh.sign(state, a, zone)
+
+	a.Compress = true
+	state.SizeAndDo(a)
+	a = state.Scrub(a)
+
+	clusters := clustersOf(dnsRecords)
+	traceAnswer(ctx, clusters, len(a.Answer))
+	pReq.logEntry.answered(clusters)
+
+	if wErr := state.W.WriteMsg(a); wErr != nil {
+		log.Errorf("Failed to write message %#v: %v", a, wErr)
+		return dns.RcodeServerFailure, lh.error("failed to write response")
+	}
+
+	return dns.RcodeSuccess, nil
+}
+
+// namespaceServices returns the sorted names of the services, with a ClusterSetIP or headless, in the given namespace.
+func (lh *Lighthouse) namespaceServices(namespace string) []string {
+	names := map[string]bool{}
+
+	for _, name := range lh.serviceImports.Services(namespace) {
+		names[name] = true
+	}
+
+	for _, name := range lh.endpointSlices.Services(namespace) {
+		names[name] = true
+	}
+
+	services := make([]string, 0, len(names))
+	for name := range names {
+		services = append(services, name)
+	}
+
+	sort.Strings(services)
+
+	return services
+}
@@ -0,0 +1,101 @@
+// +build conformance
+
+/*
+SPDX-License-Identifier: Apache-2.0
+
+Copyright Contributors to the Submariner project.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package conformance
+
+import (
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/miekg/dns"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+// The suite is configured with environment variables describing the live deployment to check. Services are given as
+// namespace/name and must have been exported, and imported in the cluster whose DNS server is checked, beforehand.
+const (
+	// Address (host:port) of the DNS server answering for the clusterset zone, e.g. a cluster's kube-dns service
+	dnsServerEnv = "LIGHTHOUSE_CONFORMANCE_DNS_SERVER"
+	// The clusterset zone, clusterset.local. by default
+	zoneEnv = "LIGHTHOUSE_CONFORMANCE_ZONE"
+	// An exported ClusterSetIP service; its requirements are skipped if unset
+	serviceEnv = "LIGHTHOUSE_CONFORMANCE_SERVICE"
+	// An exported headless service with ready endpoints; its requirements are skipped if unset
+	headlessServiceEnv = "LIGHTHOUSE_CONFORMANCE_HEADLESS_SERVICE"
+	// A named port of the services, as name/protocol, e.g. http/tcp; the named port requirements are skipped if unset
+	portEnv = "LIGHTHOUSE_CONFORMANCE_PORT"
+	// File the compliance matrix is written to, in addition to the standard output
+	reportEnv = "LIGHTHOUSE_CONFORMANCE_REPORT"
+
+	defaultZone = "clusterset.local."
+)
+
+type serviceRef struct {
+	namespace string
+	name      string
+}
+
+var deployment struct {
+	dnsServer string
+	zone      string
+	service   *serviceRef
+	headless  *serviceRef
+	portName  string
+	protocol  string
+}
+
+func TestConformance(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecsWithDefaultAndCustomReporters(t, "MCS API DNS Conformance Suite",
+		[]Reporter{&complianceReporter{path: os.Getenv(reportEnv)}})
+}
+
+var _ = BeforeSuite(func() {
+	deployment.dnsServer = os.Getenv(dnsServerEnv)
+	Expect(deployment.dnsServer).ToNot(BeEmpty(), "%s must be set to the address of the DNS server to check", dnsServerEnv)
+
+	deployment.zone = dns.Fqdn(os.Getenv(zoneEnv))
+	if deployment.zone == "." {
+		deployment.zone = defaultZone
+	}
+
+	deployment.service = parseServiceRef(serviceEnv)
+	deployment.headless = parseServiceRef(headlessServiceEnv)
+
+	if port := os.Getenv(portEnv); port != "" {
+		parts := strings.Split(port, "/")
+		Expect(parts).To(HaveLen(2), "%s must be set to name/protocol", portEnv)
+
+		deployment.portName, deployment.protocol = strings.ToLower(parts[0]), strings.ToLower(parts[1])
+	}
+})
+
+func parseServiceRef(env string) *serviceRef {
+	value := os.Getenv(env)
+	if value == "" {
+		return nil
+	}
+
+	parts := strings.Split(value, "/")
+	Expect(parts).To(HaveLen(2), "%s must be set to namespace/name", env)
+
+	return &serviceRef{namespace: parts[0], name: parts[1]}
+}
@@ -0,0 +1,236 @@
+// +build conformance
+
+/*
+SPDX-License-Identifier: Apache-2.0
+
+Copyright Contributors to the Submariner project.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package conformance
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/miekg/dns"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"k8s.io/apimachinery/pkg/util/rand"
+)
+
+// The requirements are those of the DNS specification of the Multi-Cluster Services API (KEP-1645):
+// https://github.com/kubernetes/enhancements/tree/master/keps/sig-multicluster/1645-multi-cluster-services-api
+var _ = Describe("ClusterSetIP services", func() {
+	BeforeEach(func() {
+		if deployment.service == nil {
+			Skip(serviceEnv + " isn't set")
+		}
+	})
+
+	It("should resolve <service>.<ns>.svc.<zone> to A records", func() {
+		r := query(serviceName(deployment.service), dns.TypeA)
+		Expect(r.Rcode).To(Equal(dns.RcodeSuccess))
+		Expect(answersOfType(r, dns.TypeA)).ToNot(BeEmpty(), "No A record answered")
+	})
+
+	It("should answer SRV queries for <service>.<ns>.svc.<zone> with the service's ports", func() {
+		name := serviceName(deployment.service)
+
+		r := query(name, dns.TypeSRV)
+		Expect(r.Rcode).To(Equal(dns.RcodeSuccess))
+
+		srvs := answersOfType(r, dns.TypeSRV)
+		Expect(srvs).ToNot(BeEmpty(), "No SRV record answered")
+
+		for _, rr := range srvs {
+			Expect(strings.ToLower(rr.(*dns.SRV).Target)).To(Equal(name), "Unexpected SRV target")
+		}
+	})
+
+	It("should answer SRV queries for _<port>._<protocol>.<service>.<ns>.svc.<zone>", func() {
+		if deployment.portName == "" {
+			Skip(portEnv + " isn't set")
+		}
+
+		name := serviceName(deployment.service)
+
+		r := query(portName(name), dns.TypeSRV)
+		Expect(r.Rcode).To(Equal(dns.RcodeSuccess))
+
+		srvs := answersOfType(r, dns.TypeSRV)
+		Expect(srvs).ToNot(BeEmpty(), "No SRV record answered")
+
+		for _, rr := range srvs {
+			Expect(strings.ToLower(rr.(*dns.SRV).Target)).To(Equal(name), "Unexpected SRV target")
+		}
+	})
+
+	It("should answer NXDOMAIN for a port the service doesn't have", func() {
+		r := query("_"+rand.String(8)+"._tcp."+serviceName(deployment.service), dns.TypeSRV)
+		Expect(r.Rcode).To(Equal(dns.RcodeNameError))
+	})
+
+	It("should answer AAAA queries for <service>.<ns>.svc.<zone> with NOERROR", func() {
+		r := query(serviceName(deployment.service), dns.TypeAAAA)
+		Expect(r.Rcode).To(Equal(dns.RcodeSuccess))
+
+		for _, rr := range r.Answer {
+			Expect(rr.Header().Rrtype).To(Equal(dns.TypeAAAA), "Unexpected record %s", rr)
+		}
+	})
+})
+
+var _ = Describe("Headless services", func() {
+	BeforeEach(func() {
+		if deployment.headless == nil {
+			Skip(headlessServiceEnv + " isn't set")
+		}
+	})
+
+	It("should resolve <service>.<ns>.svc.<zone> to the A records of the endpoints", func() {
+		r := query(serviceName(deployment.headless), dns.TypeA)
+		Expect(r.Rcode).To(Equal(dns.RcodeSuccess))
+		Expect(answersOfType(r, dns.TypeA)).ToNot(BeEmpty(), "No A record answered")
+	})
+
+	It("should answer SRV queries with the endpoints' <hostname>.<clusterid>.<service>.<ns>.svc.<zone> names", func() {
+		Expect(endpointNames()).ToNot(BeEmpty())
+	})
+
+	It("should resolve <hostname>.<clusterid>.<service>.<ns>.svc.<zone> to the endpoint's A record", func() {
+		for _, name := range endpointNames() {
+			r := query(name, dns.TypeA)
+			Expect(r.Rcode).To(Equal(dns.RcodeSuccess), "Unexpected rcode for %q", name)
+			Expect(answersOfType(r, dns.TypeA)).To(HaveLen(1), "Unexpected A records for %q", name)
+		}
+	})
+
+	It("should answer SRV queries for _<port>._<protocol>.<service>.<ns>.svc.<zone> with the endpoints' names", func() {
+		if deployment.portName == "" {
+			Skip(portEnv + " isn't set")
+		}
+
+		r := query(portName(serviceName(deployment.headless)), dns.TypeSRV)
+		Expect(r.Rcode).To(Equal(dns.RcodeSuccess))
+
+		srvs := answersOfType(r, dns.TypeSRV)
+		Expect(srvs).ToNot(BeEmpty(), "No SRV record answered")
+
+		for _, rr := range srvs {
+			Expect(strings.ToLower(rr.(*dns.SRV).Target)).To(HaveSuffix("."+serviceName(deployment.headless)),
+				"Unexpected SRV target")
+		}
+	})
+
+	It("should answer NXDOMAIN for a hostname the service doesn't have", func() {
+		names := endpointNames()
+
+		labels := dns.SplitDomainName(names[0])
+		labels[0] = rand.String(8)
+
+		r := query(dns.Fqdn(strings.Join(labels, ".")), dns.TypeA)
+		Expect(r.Rcode).To(Equal(dns.RcodeNameError))
+	})
+})
+
+var _ = Describe("Names which don't exist", func() {
+	It("should answer NXDOMAIN for a service which isn't exported", func() {
+		namespace := "default"
+		if deployment.service != nil {
+			namespace = deployment.service.namespace
+		}
+
+		r := query(serviceName(&serviceRef{namespace: namespace, name: "conformance-" + rand.String(8)}), dns.TypeA)
+		Expect(r.Rcode).To(Equal(dns.RcodeNameError))
+	})
+
+	It("should answer NXDOMAIN for a namespace which doesn't exist", func() {
+		r := query(serviceName(&serviceRef{namespace: "conformance-" + rand.String(8), name: "service"}), dns.TypeA)
+		Expect(r.Rcode).To(Equal(dns.RcodeNameError))
+	})
+})
+
+func serviceName(service *serviceRef) string {
+	return strings.ToLower(fmt.Sprintf("%s.%s.svc.%s", service.name, service.namespace, deployment.zone))
+}
+
+func portName(name string) string {
+	return fmt.Sprintf("_%s._%s.%s", deployment.portName, deployment.protocol, name)
+}
+
+// endpointNames returns the per-cluster names of the headless service's endpoints, as targeted by its SRV records.
+func endpointNames() []string {
+	service := serviceName(deployment.headless)
+
+	r := query(service, dns.TypeSRV)
+	Expect(r.Rcode).To(Equal(dns.RcodeSuccess))
+
+	names := []string{}
+
+	for _, rr := range answersOfType(r, dns.TypeSRV) {
+		target := strings.ToLower(rr.(*dns.SRV).Target)
+
+		// The target must have a hostname and a cluster ID label in front of the service's name
+		Expect(target).To(HaveSuffix("."+service), "Unexpected SRV target")
+		Expect(dns.CountLabel(target)).To(Equal(dns.CountLabel(service)+2), "SRV target %q isn't a per-cluster name", target)
+
+		names = append(names, target)
+	}
+
+	Expect(names).ToNot(BeEmpty(), "No SRV record answered")
+
+	return names
+}
+
+func answersOfType(r *dns.Msg, qtype uint16) []dns.RR {
+	var answers []dns.RR
+
+	for _, rr := range r.Answer {
+		if rr.Header().Rrtype == qtype {
+			answers = append(answers, rr)
+		}
+	}
+
+	return answers
+}
+
+// query sends the given query to the DNS server, retrying over TCP if the response is truncated.
+func query(name string, qtype uint16) *dns.Msg {
+	m := new(dns.Msg)
+	m.SetQuestion(dns.Fqdn(name), qtype)
+
+	client := &dns.Client{Timeout: 5 * time.Second}
+
+	var (
+		r   *dns.Msg
+		err error
+	)
+
+	for attempt := 0; attempt < 3; attempt++ {
+		r, _, err = client.Exchange(m, deployment.dnsServer)
+		if err == nil && r.Truncated {
+			client.Net = "tcp"
+			r, _, err = client.Exchange(m, deployment.dnsServer)
+		}
+
+		if err == nil {
+			return r
+		}
+	}
+
+	Expect(err).To(Succeed(), "Failed to query %q for %s", name, dns.TypeToString[qtype])
+
+	return nil
+}
@@ -0,0 +1,125 @@
+// +build conformance
+
+/*
+SPDX-License-Identifier: Apache-2.0
+
+Copyright Contributors to the Submariner project.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package conformance
+
+import (
+	"fmt"
+	"io/ioutil"
+	"strings"
+
+	"github.com/onsi/ginkgo/config"
+	"github.com/onsi/ginkgo/types"
+)
+
+const (
+	passed  = "PASS"
+	failed  = "FAIL"
+	skipped = "SKIP"
+)
+
+type requirementResult struct {
+	area        string
+	requirement string
+	result      string
+	reason      string
+}
+
+// complianceReporter collects the outcome of each requirement, i.e. each spec, and reports them as a compliance
+// matrix at the end of the suite.
+type complianceReporter struct {
+	path    string
+	results []requirementResult
+}
+
+func (r *complianceReporter) SpecSuiteWillBegin(_ config.GinkgoConfigType, _ *types.SuiteSummary) {
+}
+
+func (r *complianceReporter) BeforeSuiteDidRun(_ *types.SetupSummary) {
+}
+
+func (r *complianceReporter) SpecWillRun(_ *types.SpecSummary) {
+}
+
+func (r *complianceReporter) SpecDidComplete(summary *types.SpecSummary) {
+	texts := []string{}
+
+	for _, text := range summary.ComponentTexts {
+		if text != "[Top Level]" {
+			texts = append(texts, text)
+		}
+	}
+
+	if len(texts) < 2 {
+		return
+	}
+
+	result := requirementResult{area: texts[0], requirement: strings.Join(texts[1:], " ")}
+
+	switch {
+	case summary.Passed():
+		result.result = passed
+	case summary.Skipped(), summary.Pending():
+		result.result = skipped
+		result.reason = summary.Failure.Message
+	default:
+		result.result = failed
+		result.reason = summary.Failure.Message
+	}
+
+	r.results = append(r.results, result)
+}
+
+func (r *complianceReporter) AfterSuiteDidRun(_ *types.SetupSummary) {
+}
+
+func (r *complianceReporter) SpecSuiteDidEnd(_ *types.SuiteSummary) {
+	matrix := r.matrix()
+
+	fmt.Print("\n" + matrix)
+
+	if r.path != "" {
+		if err := ioutil.WriteFile(r.path, []byte(matrix), 0o644); err != nil {
+			fmt.Printf("Failed to write the compliance matrix to %q: %v\n", r.path, err)
+		}
+	}
+}
+
+// matrix renders the results as a Markdown table, followed by the number of requirements met.
+func (r *complianceReporter) matrix() string {
+	var b strings.Builder
+
+	counts := map[string]int{}
+
+	b.WriteString("| Area | Requirement | Result | Reason |\n")
+	b.WriteString("| ---- | ----------- | ------ | ------ |\n")
+
+	for _, result := range r.results {
+		counts[result.result]++
+
+		reason := strings.ReplaceAll(strings.TrimSpace(result.reason), "\n", " ")
+		fmt.Fprintf(&b, "| %s | %s | %s | %s |\n", result.area, result.requirement, result.result,
+			strings.ReplaceAll(reason, "|", "\\|"))
+	}
+
+	fmt.Fprintf(&b, "\n%d of %d requirements met, %d failed, %d skipped\n", counts[passed], counts[passed]+counts[failed],
+		counts[failed], counts[skipped])
+
+	return b.String()
+}
@@ -0,0 +1,160 @@
+/*
+SPDX-License-Identifier: Apache-2.0
+
+Copyright Contributors to the Submariner project.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+// Package dnssim simulates a clusterset in-process: the lighthouse plugin answers real DNS queries, over UDP on the
+// loopback interface, from synthetic ServiceImports and EndpointSlices representing a number of clusters and
+// services, with the clusters' connectivity and the services' health under the test's control. It validates
+// behavior across the handler, the maps and the status interfaces without Submariner clusters.
+package dnssim
+
+import (
+	"context"
+	"fmt"
+	"net"
+
+	"github.com/miekg/dns"
+	"github.com/submariner-io/lighthouse/pkg/endpointslice"
+	"github.com/submariner-io/lighthouse/pkg/serviceimport"
+	"github.com/submariner-io/lighthouse/plugin/lighthouse"
+)
+
+// Zone is the zone the simulated clusterset is answered for.
+const Zone = "clusterset.local."
+
+// Topology describes the simulated clusterset: Services services, spread over Namespaces namespaces, are each exported
+// by all the Clusters clusters. Every HeadlessEvery-th service, starting with the first, is headless, with Endpoints
+// endpoints in each cluster; if HeadlessEvery is 0, no service is.
+type Topology struct {
+	Clusters      int
+	Services      int
+	Namespaces    int
+	HeadlessEvery int
+	Endpoints     int
+}
+
+// Simulation serves DNS queries for a simulated clusterset.
+type Simulation struct {
+	Topology
+	// Status controls the clusters' connectivity, the services' health and the local cluster
+	Status *Status
+
+	serviceImports *serviceimport.Map
+	endpointSlices *endpointslice.Map
+	handler        *lighthouse.Lighthouse
+	server         *dns.Server
+	client         *dns.Client
+}
+
+// New returns a simulation of the given topology, with every service exported, every cluster connected and every
+// service healthy.
+func New(topology Topology) *Simulation {
+	s := &Simulation{
+		Topology:       topology,
+		Status:         newStatus(),
+		serviceImports: serviceimport.NewMap(),
+		endpointSlices: endpointslice.NewMap(),
+		client:         &dns.Client{},
+	}
+
+	for i := 0; i < topology.Services; i++ {
+		s.AddService(i)
+	}
+
+	s.handler = lighthouse.NewWithStatus([]string{Zone}, s.serviceImports, s.endpointSlices, s.Status, s.Status,
+		localServices{s})
+
+	return s
+}
+
+// Start starts serving queries on a random port of the loopback interface.
+func (s *Simulation) Start() error {
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		return fmt.Errorf("error listening for queries: %v", err)
+	}
+
+	started := make(chan struct{})
+	s.server = &dns.Server{PacketConn: conn, Handler: s, NotifyStartedFunc: func() {
+		close(started)
+	}}
+
+	errCh := make(chan error, 1)
+
+	go func() {
+		errCh <- s.server.ActivateAndServe()
+	}()
+
+	select {
+	case <-started:
+		return nil
+	case err := <-errCh:
+		return fmt.Errorf("error serving queries: %v", err)
+	}
+}
+
+// Stop stops serving queries.
+func (s *Simulation) Stop() error {
+	if s.server == nil {
+		return nil
+	}
+
+	return s.server.Shutdown()
+}
+
+// Address returns the address queries are served on.
+func (s *Simulation) Address() string {
+	return s.server.PacketConn.LocalAddr().String()
+}
+
+// Query sends a query of the given type for the given name to the simulation, as a client would.
+func (s *Simulation) Query(name string, qtype uint16) (*dns.Msg, error) {
+	m := new(dns.Msg)
+	m.SetQuestion(dns.Fqdn(name), qtype)
+
+	r, _, err := s.client.Exchange(m, s.Address())
+
+	return r, err
+}
+
+// ServeDNS passes the query to the plugin, and answers it with the returned response code if the plugin didn't write
+// a response, as CoreDNS does for the last plugin of a server block.
+func (s *Simulation) ServeDNS(w dns.ResponseWriter, r *dns.Msg) {
+	writer := &recordingWriter{ResponseWriter: w}
+
+	rcode, _ := s.handler.ServeDNS(context.Background(), writer, r)
+	if writer.written {
+		return
+	}
+
+	if rcode == dns.RcodeSuccess {
+		rcode = dns.RcodeServerFailure
+	}
+
+	m := new(dns.Msg)
+	m.SetRcode(r, rcode)
+	_ = w.WriteMsg(m)
+}
+
+type recordingWriter struct {
+	dns.ResponseWriter
+	written bool
+}
+
+func (w *recordingWriter) WriteMsg(m *dns.Msg) error {
+	w.written = true
+	return w.ResponseWriter.WriteMsg(m)
+}
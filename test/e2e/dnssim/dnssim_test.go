@@ -0,0 +1,177 @@
+/*
+SPDX-License-Identifier: Apache-2.0
+
+Copyright Contributors to the Submariner project.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package dnssim_test
+
+import (
+	"github.com/miekg/dns"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/submariner-io/lighthouse/test/e2e/dnssim"
+)
+
+const (
+	clusterSetIPService = 1
+	headlessService     = 0
+)
+
+var _ = Describe("Simulated clusterset", func() {
+	var sim *dnssim.Simulation
+
+	BeforeEach(func() {
+		sim = dnssim.New(dnssim.Topology{
+			Clusters:      3,
+			Services:      10,
+			Namespaces:    2,
+			HeadlessEvery: 5,
+			Endpoints:     2,
+		})
+
+		Expect(sim.Start()).To(Succeed())
+	})
+
+	AfterEach(func() {
+		Expect(sim.Stop()).To(Succeed())
+	})
+
+	When("a ClusterSetIP service is queried", func() {
+		It("should answer with the service's IP in one of the clusters", func() {
+			ips := aRecordIPs(query(sim, sim.FQDN(clusterSetIPService), dns.TypeA))
+			Expect(ips).To(HaveLen(1))
+			Expect(allClusterIPs(sim, clusterSetIPService)).To(ContainElement(ips[0]))
+		})
+	})
+
+	When("a headless service is queried", func() {
+		It("should answer with all its endpoints in all the clusters", func() {
+			var expected []string
+
+			for c := 0; c < sim.Clusters; c++ {
+				for e := 0; e < sim.Endpoints; e++ {
+					expected = append(expected, sim.EndpointIP(headlessService, c, e))
+				}
+			}
+
+			Expect(aRecordIPs(query(sim, sim.FQDN(headlessService), dns.TypeA))).To(ConsistOf(expected))
+		})
+	})
+
+	When("an SRV query is sent for a ClusterSetIP service", func() {
+		It("should answer with the service's port", func() {
+			r := query(sim, sim.FQDN(clusterSetIPService), dns.TypeSRV)
+			Expect(r.Answer).To(HaveLen(1))
+
+			srv, ok := r.Answer[0].(*dns.SRV)
+			Expect(ok).To(BeTrue())
+			Expect(srv.Port).To(Equal(uint16(dnssim.Port)))
+		})
+	})
+
+	When("clusters are disconnected", func() {
+		BeforeEach(func() {
+			for c := 1; c < sim.Clusters; c++ {
+				sim.Status.SetConnected(dnssim.ClusterID(c), false)
+			}
+		})
+
+		It("should only answer with the connected cluster's IP", func() {
+			for i := 0; i < 5; i++ {
+				Expect(aRecordIPs(query(sim, sim.FQDN(clusterSetIPService), dns.TypeA))).To(
+					Equal([]string{sim.ClusterIP(clusterSetIPService, 0)}))
+			}
+		})
+	})
+
+	When("the service is unhealthy in some clusters", func() {
+		BeforeEach(func() {
+			name, namespace := sim.ServiceName(clusterSetIPService)
+			for c := 0; c < sim.Clusters-1; c++ {
+				sim.Status.SetHealthy(name, namespace, dnssim.ClusterID(c), false)
+			}
+		})
+
+		It("should only answer with the healthy cluster's IP", func() {
+			for i := 0; i < 5; i++ {
+				Expect(aRecordIPs(query(sim, sim.FQDN(clusterSetIPService), dns.TypeA))).To(
+					Equal([]string{sim.ClusterIP(clusterSetIPService, sim.Clusters-1)}))
+			}
+		})
+	})
+
+	When("the service is exported by the local cluster", func() {
+		BeforeEach(func() {
+			sim.Status.SetLocalCluster(dnssim.ClusterID(1))
+		})
+
+		It("should answer with the local cluster's IP", func() {
+			for i := 0; i < 5; i++ {
+				Expect(aRecordIPs(query(sim, sim.FQDN(clusterSetIPService), dns.TypeA))).To(
+					Equal([]string{sim.ClusterIP(clusterSetIPService, 1)}))
+			}
+		})
+	})
+
+	When("a service is removed", func() {
+		BeforeEach(func() {
+			sim.RemoveService(clusterSetIPService)
+		})
+
+		It("should answer NXDOMAIN", func() {
+			r := query(sim, sim.FQDN(clusterSetIPService), dns.TypeA)
+			Expect(r.Rcode).To(Equal(dns.RcodeNameError))
+		})
+
+		Context("and added again", func() {
+			BeforeEach(func() {
+				sim.AddService(clusterSetIPService)
+			})
+
+			It("should answer with the service's IP again", func() {
+				Expect(allClusterIPs(sim, clusterSetIPService)).To(ContainElement(
+					aRecordIPs(query(sim, sim.FQDN(clusterSetIPService), dns.TypeA))[0]))
+			})
+		})
+	})
+})
+
+func query(sim *dnssim.Simulation, name string, qtype uint16) *dns.Msg {
+	r, err := sim.Query(name, qtype)
+	Expect(err).To(Succeed())
+
+	return r
+}
+
+func aRecordIPs(r *dns.Msg) []string {
+	ips := []string{}
+
+	for _, rr := range r.Answer {
+		if a, ok := rr.(*dns.A); ok {
+			ips = append(ips, a.A.String())
+		}
+	}
+
+	return ips
+}
+
+func allClusterIPs(sim *dnssim.Simulation, i int) []string {
+	ips := make([]string, sim.Clusters)
+	for c := range ips {
+		ips[c] = sim.ClusterIP(i, c)
+	}
+
+	return ips
+}
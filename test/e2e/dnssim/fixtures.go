@@ -0,0 +1,182 @@
+/*
+SPDX-License-Identifier: Apache-2.0
+
+Copyright Contributors to the Submariner project.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package dnssim
+
+import (
+	"fmt"
+
+	lhconstants "github.com/submariner-io/lighthouse/pkg/constants"
+	"github.com/submariner-io/lighthouse/pkg/serviceimport"
+	corev1 "k8s.io/api/core/v1"
+	discovery "k8s.io/api/discovery/v1beta1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	mcsv1a1 "sigs.k8s.io/mcs-api/pkg/apis/v1alpha1"
+)
+
+const (
+	// PortName, Protocol and Port describe the single port of every simulated service
+	PortName = "http"
+	Protocol = corev1.ProtocolTCP
+	Port     = int32(80)
+)
+
+// ClusterID returns the ID of the c-th cluster.
+func ClusterID(c int) string {
+	return fmt.Sprintf("cluster-%d", c)
+}
+
+// ServiceName returns the name and namespace of the i-th service.
+func (s *Simulation) ServiceName(i int) (name, namespace string) {
+	return fmt.Sprintf("service-%d", i), fmt.Sprintf("namespace-%d", i%s.Namespaces)
+}
+
+// FQDN returns the clusterset name of the i-th service.
+func (s *Simulation) FQDN(i int) string {
+	name, namespace := s.ServiceName(i)
+	return fmt.Sprintf("%s.%s.svc.%s", name, namespace, Zone)
+}
+
+// IsHeadless returns whether the i-th service is headless.
+func (s *Simulation) IsHeadless(i int) bool {
+	return s.HeadlessEvery > 0 && i%s.HeadlessEvery == 0
+}
+
+// ClusterIP returns the IP of the i-th service in the c-th cluster, if it isn't headless.
+func (s *Simulation) ClusterIP(i, c int) string {
+	return fmt.Sprintf("10.%d.%d.%d", 100+c, i/256%256, i%256)
+}
+
+// EndpointIP returns the IP of the e-th endpoint of the i-th service in the c-th cluster, if it's headless.
+func (s *Simulation) EndpointIP(i, c, e int) string {
+	return fmt.Sprintf("100.%d.%d.%d", 96+c, i%256, e%256)
+}
+
+// EndpointHostname returns the hostname of the e-th endpoint of a headless service.
+func EndpointHostname(e int) string {
+	return fmt.Sprintf("pod-%d", e)
+}
+
+// AddService exports the i-th service from all the clusters, replacing its current ServiceImports and EndpointSlices.
+func (s *Simulation) AddService(i int) {
+	for c := 0; c < s.Clusters; c++ {
+		s.serviceImports.Put(s.serviceImport(i, c))
+
+		if s.IsHeadless(i) {
+			s.endpointSlices.Put(s.endpointSlice(i, c))
+		}
+	}
+}
+
+// RemoveService removes the i-th service's ServiceImports and EndpointSlices, as if it were unexported.
+func (s *Simulation) RemoveService(i int) {
+	for c := 0; c < s.Clusters; c++ {
+		if s.IsHeadless(i) {
+			s.endpointSlices.Remove(s.endpointSlice(i, c))
+		}
+
+		s.serviceImports.Remove(s.serviceImport(i, c))
+	}
+}
+
+func (s *Simulation) serviceImport(i, c int) *mcsv1a1.ServiceImport {
+	name, namespace := s.ServiceName(i)
+
+	siType, ips := mcsv1a1.ClusterSetIP, []string{s.ClusterIP(i, c)}
+	if s.IsHeadless(i) {
+		siType, ips = mcsv1a1.Headless, []string{}
+	}
+
+	return &mcsv1a1.ServiceImport{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      name + "-" + namespace + "-" + ClusterID(c),
+			Namespace: "submariner-operator",
+			Annotations: map[string]string{
+				lhconstants.OriginName:      name,
+				lhconstants.OriginNamespace: namespace,
+			},
+			Labels: map[string]string{lhconstants.LabelSourceCluster: ClusterID(c)},
+		},
+		Spec: mcsv1a1.ServiceImportSpec{
+			Type:  siType,
+			IPs:   ips,
+			Ports: []mcsv1a1.ServicePort{{Name: PortName, Protocol: Protocol, Port: Port}},
+		},
+		Status: mcsv1a1.ServiceImportStatus{Clusters: []mcsv1a1.ClusterStatus{{Cluster: ClusterID(c)}}},
+	}
+}
+
+func (s *Simulation) endpointSlice(i, c int) *discovery.EndpointSlice {
+	name, namespace := s.ServiceName(i)
+	portName, protocol, port := PortName, Protocol, Port
+	ready := true
+
+	endpoints := make([]discovery.Endpoint, s.Endpoints)
+
+	for e := range endpoints {
+		hostname := EndpointHostname(e)
+		endpoints[e] = discovery.Endpoint{
+			Addresses:  []string{s.EndpointIP(i, c, e)},
+			Hostname:   &hostname,
+			Conditions: discovery.EndpointConditions{Ready: &ready},
+		}
+	}
+
+	return &discovery.EndpointSlice{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      name + "-" + ClusterID(c),
+			Namespace: namespace,
+			Labels: map[string]string{
+				discovery.LabelManagedBy:         lhconstants.LabelValueManagedBy,
+				lhconstants.LabelSourceNamespace: namespace,
+				lhconstants.LabelSourceCluster:   ClusterID(c),
+				lhconstants.LabelSourceName:      name,
+			},
+		},
+		AddressType: discovery.AddressTypeIPv4,
+		Endpoints:   endpoints,
+		Ports:       []discovery.EndpointPort{{Name: &portName, Protocol: &protocol, Port: &port}},
+	}
+}
+
+// localServices answers for the services of the local cluster, if one is set, with their IPs in that cluster.
+type localServices struct {
+	sim *Simulation
+}
+
+func (l localServices) GetIP(name, namespace string) (*serviceimport.DNSRecord, bool) {
+	local := l.sim.Status.LocalClusterID()
+	if local == "" {
+		return nil, false
+	}
+
+	for c := 0; c < l.sim.Clusters; c++ {
+		if ClusterID(c) != local {
+			continue
+		}
+
+		for i := 0; i < l.sim.Services; i++ {
+			if n, ns := l.sim.ServiceName(i); n == name && ns == namespace && !l.sim.IsHeadless(i) {
+				ip := l.sim.ClusterIP(i, c)
+				return &serviceimport.DNSRecord{IP: ip, IPs: []string{ip}, ClusterName: local,
+					Ports: []mcsv1a1.ServicePort{{Name: PortName, Protocol: Protocol, Port: Port}}}, true
+			}
+		}
+	}
+
+	return nil, false
+}
@@ -0,0 +1,105 @@
+/*
+SPDX-License-Identifier: Apache-2.0
+
+Copyright Contributors to the Submariner project.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package dnssim
+
+import (
+	"sync"
+)
+
+// Status reports the simulated clusters' connectivity, the health of the services' endpoints and the local cluster,
+// as the gateway, EndpointSlice and Service controllers do in a real deployment. Every cluster is connected and every
+// service healthy until told otherwise.
+type Status struct {
+	mutex          sync.RWMutex
+	localClusterID string
+	disconnected   map[string]bool
+	healthScores   map[string]float64
+}
+
+func newStatus() *Status {
+	return &Status{
+		disconnected: map[string]bool{},
+		healthScores: map[string]float64{},
+	}
+}
+
+// SetConnected sets whether the given cluster is connected.
+func (s *Status) SetConnected(clusterID string, connected bool) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	s.disconnected[clusterID] = !connected
+}
+
+// SetHealthy sets whether the given service has healthy endpoints in the given cluster.
+func (s *Status) SetHealthy(name, namespace, clusterID string, healthy bool) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	score := 0.0
+	if healthy {
+		score = 1
+	}
+
+	s.healthScores[healthKey(name, namespace, clusterID)] = score
+}
+
+// SetHealthScore sets the share of the given service's endpoints which are healthy in the given cluster.
+func (s *Status) SetHealthScore(name, namespace, clusterID string, score float64) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	s.healthScores[healthKey(name, namespace, clusterID)] = score
+}
+
+// SetLocalCluster sets the ID of the cluster the simulated DNS server runs in; none by default.
+func (s *Status) SetLocalCluster(clusterID string) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	s.localClusterID = clusterID
+}
+
+func (s *Status) IsConnected(clusterID string) bool {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	return !s.disconnected[clusterID]
+}
+
+func (s *Status) LocalClusterID() string {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	return s.localClusterID
+}
+
+func (s *Status) HealthScore(name, namespace, clusterID string) float64 {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	if score, found := s.healthScores[healthKey(name, namespace, clusterID)]; found {
+		return score
+	}
+
+	return 1
+}
+
+func healthKey(name, namespace, clusterID string) string {
+	return name + "/" + namespace + "/" + clusterID
+}
@@ -0,0 +1,303 @@
+// +build integration
+
+/*
+SPDX-License-Identifier: Apache-2.0
+
+Copyright Contributors to the Submariner project.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package integration
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/coredns/caddy"
+	"github.com/coredns/coredns/core/dnsserver"
+	coretest "github.com/coredns/coredns/test"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/submariner-io/admiral/pkg/syncer/broker"
+	"github.com/submariner-io/admiral/pkg/util"
+	"github.com/submariner-io/lighthouse/pkg/agent/controller"
+	_ "github.com/submariner-io/lighthouse/plugin/lighthouse"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/tools/clientcmd"
+	"k8s.io/klog"
+	mcsv1a1 "sigs.k8s.io/mcs-api/pkg/apis/v1alpha1"
+	mcsClientset "sigs.k8s.io/mcs-api/pkg/client/clientset/versioned"
+)
+
+const (
+	// Comma-separated kubeconfigs of two existing clusters to use instead of creating kind clusters
+	kubeconfigsEnv = "LIGHTHOUSE_INTEGRATION_KUBECONFIGS"
+	// If set to "true", kind clusters created by the suite are not deleted when it completes
+	keepClustersEnv = "LIGHTHOUSE_INTEGRATION_KEEP_CLUSTERS"
+
+	brokerNamespace = "submariner-k8s-broker"
+	agentNamespace  = "submariner-operator"
+	clusterSetZone  = "clusterset.local."
+)
+
+var (
+	clusters        []*testCluster
+	createdClusters []string
+	coreDNS         *caddy.Instance
+	dnsAddress      string
+	stopCh          chan struct{}
+)
+
+type testCluster struct {
+	id         string
+	kubeconfig string
+	restConfig *rest.Config
+	kubeClient kubernetes.Interface
+	dynClient  dynamic.Interface
+	mcsClient  mcsClientset.Interface
+}
+
+func init() {
+	klog.InitFlags(nil)
+
+	err := mcsv1a1.AddToScheme(scheme.Scheme)
+	if err != nil {
+		panic(err)
+	}
+}
+
+func TestIntegration(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "Lighthouse Integration Suite")
+}
+
+var _ = BeforeSuite(func() {
+	kubeconfigs := strings.Split(os.Getenv(kubeconfigsEnv), ",")
+	if len(kubeconfigs) != 2 {
+		kubeconfigs = []string{createKindCluster("lighthouse-integration-1"), createKindCluster("lighthouse-integration-2")}
+	}
+
+	for i, kubeconfig := range kubeconfigs {
+		clusters = append(clusters, newTestCluster(fmt.Sprintf("cluster%d", i+1), kubeconfig))
+	}
+
+	clusters[0].ensureNamespace(brokerNamespace)
+
+	stopCh = make(chan struct{})
+
+	for i, c := range clusters {
+		c.installCRDs()
+		c.ensureNamespace(agentNamespace)
+		c.startAgent(clusters[0], i)
+	}
+
+	startCoreDNS(clusters[1])
+}, 600)
+
+var _ = AfterSuite(func() {
+	if coreDNS != nil {
+		coreDNS.Stop()
+	}
+
+	if stopCh != nil {
+		close(stopCh)
+	}
+
+	if os.Getenv(keepClustersEnv) == "true" {
+		return
+	}
+
+	for _, name := range createdClusters {
+		cmd := exec.Command("kind", "delete", "cluster", "--name", name)
+		cmd.Stdout, cmd.Stderr = GinkgoWriter, GinkgoWriter
+		Expect(cmd.Run()).To(Succeed())
+	}
+}, 300)
+
+func createKindCluster(name string) string {
+	kubeconfig := filepath.Join(os.TempDir(), "kind-config-"+name)
+
+	cmd := exec.Command("kind", "create", "cluster", "--name", name, "--kubeconfig", kubeconfig, "--wait", "5m")
+	cmd.Stdout, cmd.Stderr = GinkgoWriter, GinkgoWriter
+	Expect(cmd.Run()).To(Succeed(), "Error creating kind cluster %q", name)
+
+	createdClusters = append(createdClusters, name)
+
+	return kubeconfig
+}
+
+func newTestCluster(id, kubeconfig string) *testCluster {
+	restConfig, err := clientcmd.BuildConfigFromFlags("", kubeconfig)
+	Expect(err).To(Succeed())
+
+	c := &testCluster{id: id, kubeconfig: kubeconfig, restConfig: restConfig}
+
+	c.kubeClient, err = kubernetes.NewForConfig(restConfig)
+	Expect(err).To(Succeed())
+
+	c.dynClient, err = dynamic.NewForConfig(restConfig)
+	Expect(err).To(Succeed())
+
+	c.mcsClient, err = mcsClientset.NewForConfig(restConfig)
+	Expect(err).To(Succeed())
+
+	return c
+}
+
+func (c *testCluster) ensureNamespace(name string) {
+	_, err := c.kubeClient.CoreV1().Namespaces().Create(context.TODO(), &corev1.Namespace{
+		ObjectMeta: metav1.ObjectMeta{Name: name},
+	}, metav1.CreateOptions{})
+	if !apierrors.IsAlreadyExists(err) {
+		Expect(err).To(Succeed())
+	}
+}
+
+// installCRDs creates minimal, schema-less MCS API CRDs so that the suite does not depend on external manifests.
+func (c *testCluster) installCRDs() {
+	crdClient := c.dynClient.Resource(schema.GroupVersionResource{
+		Group:    "apiextensions.k8s.io",
+		Version:  "v1",
+		Resource: "customresourcedefinitions",
+	})
+
+	for _, kind := range []string{"ServiceExport", "ServiceImport"} {
+		_, err := crdClient.Create(context.TODO(), newCRD(kind), metav1.CreateOptions{})
+		if !apierrors.IsAlreadyExists(err) {
+			Expect(err).To(Succeed())
+		}
+	}
+}
+
+func newCRD(kind string) *unstructured.Unstructured {
+	group := mcsv1a1.SchemeGroupVersion.Group
+	singular := strings.ToLower(kind)
+
+	return &unstructured.Unstructured{Object: map[string]interface{}{
+		"apiVersion": "apiextensions.k8s.io/v1",
+		"kind":       "CustomResourceDefinition",
+		"metadata": map[string]interface{}{
+			"name": singular + "s." + group,
+		},
+		"spec": map[string]interface{}{
+			"group": group,
+			"scope": "Namespaced",
+			"names": map[string]interface{}{
+				"plural":   singular + "s",
+				"singular": singular,
+				"kind":     kind,
+				"listKind": kind + "List",
+			},
+			"versions": []interface{}{
+				map[string]interface{}{
+					"name":    mcsv1a1.SchemeGroupVersion.Version,
+					"served":  true,
+					"storage": true,
+					"subresources": map[string]interface{}{
+						"status": map[string]interface{}{},
+					},
+					"schema": map[string]interface{}{
+						"openAPIV3Schema": map[string]interface{}{
+							"type":                                 "object",
+							"x-kubernetes-preserve-unknown-fields": true,
+						},
+					},
+				},
+			},
+		},
+	}}
+}
+
+func (c *testCluster) awaitRestMapper() meta.RESTMapper {
+	var restMapper meta.RESTMapper
+
+	Eventually(func() error {
+		var err error
+
+		restMapper, err = util.BuildRestMapper(c.restConfig)
+		if err != nil {
+			return err
+		}
+
+		for _, kind := range []string{"ServiceExport", "ServiceImport"} {
+			_, err = restMapper.RESTMapping(schema.GroupKind{Group: mcsv1a1.SchemeGroupVersion.Group, Kind: kind})
+			if err != nil {
+				return err
+			}
+		}
+
+		return nil
+	}, 60*time.Second, time.Second).Should(Succeed())
+
+	return restMapper
+}
+
+func (c *testCluster) startAgent(brokerCluster *testCluster, index int) {
+	agent, err := controller.New(&controller.AgentSpecification{
+		ClusterID: c.id,
+		Namespace: agentNamespace,
+	}, broker.SyncerConfig{
+		LocalRestConfig: c.restConfig,
+		LocalClient:     c.dynClient,
+		RestMapper:      c.awaitRestMapper(),
+		Scheme:          scheme.Scheme,
+		BrokerClient:    brokerCluster.dynClient,
+		BrokerNamespace: brokerNamespace,
+	}, c.kubeClient, controller.AgentConfig{
+		ServiceImportCounterName: fmt.Sprintf("submariner_service_import_%d", index),
+		ServiceExportCounterName: fmt.Sprintf("submariner_service_export_%d", index),
+	})
+	Expect(err).To(Succeed())
+
+	Expect(agent.Start(stopCh)).To(Succeed())
+}
+
+// startCoreDNS runs an in-process CoreDNS server with the lighthouse plugin, watching the given cluster.
+func startCoreDNS(c *testCluster) {
+	Expect(os.Setenv("SUBMARINER_CLUSTERID", c.id)).To(Succeed())
+	Expect(flag.Set("kubeconfig", c.kubeconfig)).To(Succeed())
+
+	directives := []string{}
+
+	for _, d := range dnsserver.Directives {
+		directives = append(directives, d)
+		if d == "kubernetes" {
+			directives = append(directives, "lighthouse")
+		}
+	}
+
+	dnsserver.Directives = directives
+
+	var err error
+
+	coreDNS, dnsAddress, _, err = coretest.CoreDNSServerAndPorts(clusterSetZone + `:0 {
+		lighthouse
+	}`)
+	Expect(err).To(Succeed())
+}
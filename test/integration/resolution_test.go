@@ -0,0 +1,174 @@
+// +build integration
+
+/*
+SPDX-License-Identifier: Apache-2.0
+
+Copyright Contributors to the Submariner project.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package integration
+
+import (
+	"context"
+	"crypto/rand"
+	"fmt"
+	"math/big"
+	"os"
+	"time"
+
+	"github.com/miekg/dns"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	mcsv1a1 "sigs.k8s.io/mcs-api/pkg/apis/v1alpha1"
+)
+
+const (
+	serviceName  = "nginx"
+	defaultImage = "nginxinc/nginx-unprivileged:stable-alpine"
+	imageEnv     = "LIGHTHOUSE_INTEGRATION_IMAGE"
+	awaitTimeout = 2 * time.Minute
+)
+
+var _ = Describe("Cross-cluster resolution", func() {
+	var (
+		namespace string
+		qname     string
+	)
+
+	BeforeEach(func() {
+		n, err := rand.Int(rand.Reader, big.NewInt(100000))
+		Expect(err).To(Succeed())
+
+		namespace = fmt.Sprintf("lh-integration-%d", n.Int64())
+		qname = fmt.Sprintf("%s.%s.svc.%s", serviceName, namespace, clusterSetZone)
+
+		for _, c := range clusters {
+			c.ensureNamespace(namespace)
+		}
+	})
+
+	AfterEach(func() {
+		for _, c := range clusters {
+			Expect(c.kubeClient.CoreV1().Namespaces().Delete(context.TODO(), namespace, metav1.DeleteOptions{})).To(Succeed())
+		}
+	})
+
+	When("a service is exported from a remote cluster", func() {
+		It("should resolve to the remote service IP and stop resolving once unexported", func() {
+			svc := clusters[0].createExportedService(namespace)
+			awaitIPs(qname, svc.Spec.ClusterIP)
+
+			clusters[0].deleteServiceExport(namespace)
+			awaitRcode(qname, dns.RcodeNameError)
+		})
+	})
+
+	When("a service is exported from both clusters", func() {
+		It("should prefer the local cluster and fail over to the remote cluster when local backends are gone", func() {
+			remote := clusters[0].createExportedService(namespace)
+			local := clusters[1].createExportedService(namespace)
+			awaitIPs(qname, local.Spec.ClusterIP)
+
+			clusters[1].deleteBackend(namespace)
+			awaitIPs(qname, remote.Spec.ClusterIP)
+		})
+	})
+})
+
+func (c *testCluster) createExportedService(namespace string) *corev1.Service {
+	labels := map[string]string{"app": serviceName}
+
+	image := os.Getenv(imageEnv)
+	if image == "" {
+		image = defaultImage
+	}
+
+	_, err := c.kubeClient.CoreV1().Pods(namespace).Create(context.TODO(), &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Name: serviceName, Labels: labels},
+		Spec: corev1.PodSpec{
+			Containers: []corev1.Container{{Name: serviceName, Image: image}},
+		},
+	}, metav1.CreateOptions{})
+	Expect(err).To(Succeed())
+
+	svc, err := c.kubeClient.CoreV1().Services(namespace).Create(context.TODO(), &corev1.Service{
+		ObjectMeta: metav1.ObjectMeta{Name: serviceName},
+		Spec: corev1.ServiceSpec{
+			Selector: labels,
+			Ports: []corev1.ServicePort{{
+				Name:       "http",
+				Protocol:   corev1.ProtocolTCP,
+				Port:       80,
+				TargetPort: intstr.FromInt(8080),
+			}},
+		},
+	}, metav1.CreateOptions{})
+	Expect(err).To(Succeed())
+
+	_, err = c.mcsClient.MulticlusterV1alpha1().ServiceExports(namespace).Create(context.TODO(), &mcsv1a1.ServiceExport{
+		ObjectMeta: metav1.ObjectMeta{Name: serviceName},
+	}, metav1.CreateOptions{})
+	Expect(err).To(Succeed())
+
+	return svc
+}
+
+func (c *testCluster) deleteServiceExport(namespace string) {
+	Expect(c.mcsClient.MulticlusterV1alpha1().ServiceExports(namespace).Delete(context.TODO(), serviceName,
+		metav1.DeleteOptions{})).To(Succeed())
+}
+
+func (c *testCluster) deleteBackend(namespace string) {
+	Expect(c.kubeClient.CoreV1().Pods(namespace).Delete(context.TODO(), serviceName, metav1.DeleteOptions{})).To(Succeed())
+}
+
+func query(qname string) (*dns.Msg, error) {
+	m := new(dns.Msg)
+	m.SetQuestion(qname, dns.TypeA)
+
+	return dns.Exchange(m, dnsAddress)
+}
+
+func awaitIPs(qname string, ips ...string) {
+	Eventually(func() []string {
+		r, err := query(qname)
+		if err != nil {
+			return nil
+		}
+
+		found := []string{}
+
+		for _, rr := range r.Answer {
+			if a, ok := rr.(*dns.A); ok {
+				found = append(found, a.A.String())
+			}
+		}
+
+		return found
+	}, awaitTimeout, time.Second).Should(ConsistOf(ips), "Unexpected A records for %q", qname)
+}
+
+func awaitRcode(qname string, rcode int) {
+	Eventually(func() int {
+		r, err := query(qname)
+		if err != nil {
+			return -1
+		}
+
+		return r.Rcode
+	}, awaitTimeout, time.Second).Should(Equal(rcode), "Unexpected rcode for %q", qname)
+}